@@ -3,8 +3,11 @@ module myip
 go 1.24.1
 
 require (
+	github.com/akhfa/myip/ipdetect v0.0.0
+	github.com/akhfa/myip/pkg/client v0.0.0
 	github.com/swaggo/http-swagger/v2 v2.0.2
-	github.com/swaggo/swag v1.16.4
+	golang.org/x/sys v0.18.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
@@ -16,7 +19,13 @@ require (
 	github.com/josharian/intern v1.0.0 // indirect
 	github.com/mailru/easyjson v0.7.6 // indirect
 	github.com/swaggo/files/v2 v2.0.0 // indirect
-	golang.org/x/sys v0.18.0 // indirect
+	github.com/swaggo/swag v1.16.4 // indirect
 	golang.org/x/tools v0.7.0 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
+
+// The detection library is developed in this repository and versioned separately with
+// ipdetect/vX.Y.Z tags; the server always builds against the local copy.
+replace github.com/akhfa/myip/ipdetect => ./ipdetect
+
+// myip watch polls the server through the Go client, built from the local copy the same way
+replace github.com/akhfa/myip/pkg/client => ./pkg/client
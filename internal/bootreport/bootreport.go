@@ -0,0 +1,62 @@
+package bootreport
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+
+	"myip/internal/models"
+	"myip/internal/problem"
+)
+
+// Store keeps the most recent boot report for the admin endpoint
+type Store struct {
+	mu     sync.RWMutex
+	report *models.BootReport
+}
+
+// NewStore creates an empty boot report store
+func NewStore() *Store {
+	return &Store{}
+}
+
+// Set replaces the stored report
+func (s *Store) Set(report *models.BootReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report = report
+}
+
+// Get returns the stored report, or nil if none has been recorded yet
+func (s *Store) Get() *models.BootReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.report
+}
+
+// Log writes the report to the standard logger as a single JSON line
+func Log(report *models.BootReport) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		log.Printf("Failed to encode boot report: %v", err)
+		return
+	}
+	log.Printf("boot report: %s", data)
+}
+
+// Handler serves the latest boot report as JSON
+func (s *Store) Handler(w http.ResponseWriter, r *http.Request) {
+	report := s.Get()
+	if report == nil {
+		problem.Error(w, r, http.StatusServiceUnavailable, "Boot report not available")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, "Failed to encode boot report")
+		return
+	}
+}
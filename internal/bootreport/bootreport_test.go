@@ -0,0 +1,62 @@
+package bootreport
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"myip/internal/models"
+)
+
+func TestHandlerNotAvailable(t *testing.T) {
+	store := NewStore()
+
+	rr := httptest.NewRecorder()
+	store.Handler(rr, httptest.NewRequest("GET", "/admin/boot-report", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 before a report is set, got %d", rr.Code)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	store := NewStore()
+	store.Set(&models.BootReport{
+		Version:    "1.2.3",
+		Endpoints:  []string{"/", "/json"},
+		ConfigHash: "abc",
+	})
+
+	rr := httptest.NewRecorder()
+	store.Handler(rr, httptest.NewRequest("GET", "/admin/boot-report", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var report models.BootReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+
+	if report.Version != "1.2.3" || len(report.Endpoints) != 2 || report.ConfigHash != "abc" {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	Log(&models.BootReport{Version: "dev"})
+
+	if !strings.Contains(buf.String(), `boot report: {"version":"dev"`) {
+		t.Errorf("Expected JSON boot report in log, got %q", buf.String())
+	}
+}
@@ -0,0 +1,214 @@
+// Package breaker guards calls to external dependencies such as DNS resolvers, RDAP registries,
+// and feed downloads with circuit breakers. After Threshold consecutive failures a breaker opens
+// and calls fail at once for a backoff that doubles with every failed retry, so a slow or broken
+// upstream costs requests an immediate error rather than a timeout each.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"myip/internal/logging"
+)
+
+// Breaker states
+const (
+	// StateClosed lets calls through
+	StateClosed = "closed"
+	// StateOpen fails calls until the backoff has passed
+	StateOpen = "open"
+	// StateHalfOpen lets a single trial call through once the backoff has passed; its outcome
+	// closes the breaker or opens it again for twice as long
+	StateHalfOpen = "half-open"
+)
+
+// states lists the states in the order metrics report them
+var states = []string{StateClosed, StateOpen, StateHalfOpen}
+
+// Settings control every breaker
+type Settings struct {
+	// Threshold is the number of consecutive failures that opens a breaker; 0 disables them
+	Threshold int
+	// Backoff is how long a breaker first stays open
+	Backoff time.Duration
+	// MaxBackoff caps the backoff as it doubles
+	MaxBackoff time.Duration
+}
+
+var settings atomic.Pointer[Settings]
+
+func init() {
+	Configure(Settings{})
+}
+
+// Configure replaces the breaker settings. Open breakers keep their current backoff.
+func Configure(s Settings) {
+	settings.Store(&s)
+}
+
+// OpenError is returned instead of calling a dependency whose breaker is open
+type OpenError struct {
+	Name       string
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("%s unavailable after repeated failures, retrying in %s", e.Name, e.RetryAfter.Round(time.Second))
+}
+
+// Breaker tracks the failures of one dependency
+type Breaker struct {
+	name string
+	now  func() time.Time
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	backoff   time.Duration
+	openUntil time.Time
+	// trial is set while the half-open trial call is in flight
+	trial bool
+	trips int64
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]*Breaker)
+)
+
+// Get returns the breaker of the dependency name, creating it closed on first use
+func Get(name string) *Breaker {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	b, ok := registry[name]
+	if !ok {
+		b = &Breaker{name: name, state: StateClosed, now: time.Now}
+		registry[name] = b
+	}
+	return b
+}
+
+// Allow reports whether a call may be made, returning an *OpenError when it may not. Every
+// allowed call must be followed by Done.
+func (b *Breaker) Allow() error {
+	if settings.Load().Threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	switch b.state {
+	case StateOpen:
+		if now.Before(b.openUntil) {
+			return &OpenError{Name: b.name, RetryAfter: b.openUntil.Sub(now)}
+		}
+		b.state = StateHalfOpen
+		b.trial = true
+	case StateHalfOpen:
+		if b.trial {
+			return &OpenError{Name: b.name, RetryAfter: time.Second}
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// Done records the outcome of an allowed call: nil for success, or the error it failed with.
+// Callers pass nil for errors that show the dependency is healthy, such as a record not found.
+// Cancellation by the caller's client says nothing about the dependency and is not counted.
+func (b *Breaker) Done(err error) {
+	s := settings.Load()
+	if s.Threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	switch {
+	case errors.Is(err, context.Canceled):
+		if b.state == StateHalfOpen {
+			b.state, b.openUntil = StateOpen, b.now()
+		}
+	case err == nil:
+		if b.state != StateClosed {
+			logging.Infof("Circuit breaker %s closed", b.name)
+		}
+		b.state, b.failures, b.backoff = StateClosed, 0, 0
+	case b.state == StateOpen:
+		// A call allowed before the breaker opened failed as well
+	default:
+		b.failures++
+		if b.state == StateHalfOpen || b.failures >= s.Threshold {
+			b.open(s, err)
+		}
+	}
+}
+
+// open trips the breaker after err, doubling the backoff of the previous trip
+func (b *Breaker) open(s *Settings, err error) {
+	if b.state == StateHalfOpen && b.backoff > 0 {
+		b.backoff = min(2*b.backoff, s.MaxBackoff)
+	} else {
+		b.backoff = s.Backoff
+	}
+	b.state, b.openUntil = StateOpen, b.now().Add(b.backoff)
+	b.trips++
+	logging.Warnf("Circuit breaker %s opened for %s after %d consecutive failures: %v", b.name, b.backoff, b.failures, err)
+}
+
+// Do calls fn unless the breaker is open, recording its error with Done
+func (b *Breaker) Do(fn func() error) error {
+	if err := b.Allow(); err != nil {
+		return err
+	}
+	err := fn()
+	b.Done(err)
+	return err
+}
+
+// State returns the state of the breaker and the number of times it opened
+func (b *Breaker) State() (string, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state, b.trips
+}
+
+// WriteMetrics writes the state and trip count of every breaker in the Prometheus text
+// exposition format
+func WriteMetrics(w io.Writer) error {
+	registryMu.Lock()
+	breakers := make([]*Breaker, 0, len(registry))
+	for _, b := range registry {
+		breakers = append(breakers, b)
+	}
+	registryMu.Unlock()
+	slices.SortFunc(breakers, func(a, b *Breaker) int { return strings.Compare(a.name, b.name) })
+
+	var state, trips strings.Builder
+	state.WriteString("# HELP myip_circuit_breaker_state Circuit breaker state of each external dependency, 1 for the current state.\n")
+	state.WriteString("# TYPE myip_circuit_breaker_state gauge\n")
+	trips.WriteString("# HELP myip_circuit_breaker_trips_total Times the circuit breaker of each external dependency opened.\n")
+	trips.WriteString("# TYPE myip_circuit_breaker_trips_total counter\n")
+	for _, b := range breakers {
+		current, count := b.State()
+		for _, s := range states {
+			value := 0
+			if s == current {
+				value = 1
+			}
+			fmt.Fprintf(&state, "myip_circuit_breaker_state{name=%q,state=%q} %d\n", b.name, s, value)
+		}
+		fmt.Fprintf(&trips, "myip_circuit_breaker_trips_total{name=%q} %d\n", b.name, count)
+	}
+	_, err := io.WriteString(w, state.String()+trips.String())
+	return err
+}
@@ -0,0 +1,120 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errUpstream = errors.New("upstream failed")
+
+// newTestBreaker creates an unregistered breaker whose clock reads *now
+func newTestBreaker(now *time.Time) *Breaker {
+	return &Breaker{name: "test", state: StateClosed, now: func() time.Time { return *now }}
+}
+
+func TestBreaker(t *testing.T) {
+	defer Configure(Settings{})
+	Configure(Settings{Threshold: 2, Backoff: time.Second, MaxBackoff: 3 * time.Second})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := newTestBreaker(&now)
+
+	fail := func() error { return errUpstream }
+	succeed := func() error { return nil }
+
+	if err := b.Do(fail); err != errUpstream {
+		t.Fatalf("Expected the upstream error, got %v", err)
+	}
+	if state, _ := b.State(); state != StateClosed {
+		t.Fatalf("Expected the breaker closed below the threshold, got %s", state)
+	}
+	b.Do(fail)
+
+	var open *OpenError
+	if err := b.Do(succeed); !errors.As(err, &open) || open.RetryAfter != time.Second {
+		t.Fatalf("Expected the breaker open for 1s, got %v", err)
+	}
+
+	// The trial after the backoff fails, doubling it, and the next one is capped
+	for _, backoff := range []time.Duration{2 * time.Second, 3 * time.Second} {
+		now = now.Add(time.Hour)
+		if err := b.Do(fail); err != errUpstream {
+			t.Fatalf("Expected a trial call, got %v", err)
+		}
+		if err := b.Allow(); !errors.As(err, &open) || open.RetryAfter != backoff {
+			t.Fatalf("Expected the breaker open for %s, got %v", backoff, err)
+		}
+	}
+
+	now = now.Add(time.Hour)
+	if err := b.Do(succeed); err != nil {
+		t.Fatalf("Expected the trial call to succeed, got %v", err)
+	}
+	if state, trips := b.State(); state != StateClosed || trips != 3 {
+		t.Errorf("Expected the breaker closed after 3 trips, got %s and %d", state, trips)
+	}
+
+	// A success resets the failure count and backoff
+	b.Do(fail)
+	b.Do(succeed)
+	b.Do(fail)
+	if state, _ := b.State(); state != StateClosed {
+		t.Errorf("Expected non-consecutive failures to keep the breaker closed, got %s", state)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	defer Configure(Settings{})
+	Configure(Settings{Threshold: 1, Backoff: time.Second, MaxBackoff: time.Minute})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := newTestBreaker(&now)
+	b.Do(func() error { return errUpstream })
+
+	now = now.Add(2 * time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected a trial call, got %v", err)
+	}
+	var open *OpenError
+	if err := b.Allow(); !errors.As(err, &open) {
+		t.Errorf("Expected a single trial call at a time, got %v", err)
+	}
+
+	// A canceled trial says nothing about the dependency and allows another at once
+	b.Done(context.Canceled)
+	if err := b.Allow(); err != nil {
+		t.Errorf("Expected another trial after a canceled one, got %v", err)
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := newTestBreaker(&now)
+	for range 10 {
+		if err := b.Do(func() error { return errUpstream }); err != errUpstream {
+			t.Fatalf("Expected calls to go through while breakers are disabled, got %v", err)
+		}
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	defer Configure(Settings{})
+	Configure(Settings{Threshold: 1, Backoff: time.Minute, MaxBackoff: time.Minute})
+	Get("metrics-test").Do(func() error { return errUpstream })
+
+	var out strings.Builder
+	if err := WriteMetrics(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE myip_circuit_breaker_state gauge",
+		`myip_circuit_breaker_state{name="metrics-test",state="open"} 1`,
+		`myip_circuit_breaker_state{name="metrics-test",state="closed"} 0`,
+		`myip_circuit_breaker_trips_total{name="metrics-test"} 1`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected metrics to contain %s, got:\n%s", want, out.String())
+		}
+	}
+}
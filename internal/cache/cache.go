@@ -0,0 +1,78 @@
+// Package cache provides the lookup cache shared by the RDAP, reverse DNS, and rate limiting
+// subsystems: an in-process Memory store, or a Redis store so that every replica of a deployment
+// sees the same lookup results and rate limits.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"myip/internal/logging"
+)
+
+var logger = logging.For("cache")
+
+// Cache backends selected by CACHE_BACKEND
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// DefaultMaxEntries bounds the entries of a Memory store
+const DefaultMaxEntries = 10000
+
+// Store holds expiring values, fixed-window counters, and token buckets. Implementations are
+// safe for concurrent use.
+type Store interface {
+	// Get returns the value stored at key, reporting false when it is missing or expired
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set stores value at key for ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete removes key, succeeding when it does not exist
+	Delete(ctx context.Context, key string) error
+
+	// Incr increments the counter at key, starting a window of the given length when the counter
+	// does not exist, and returns the new count and the time left in the window
+	Incr(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error)
+
+	// Take removes a token from the bucket at key, which holds up to burst tokens and refills
+	// burst tokens per period, reporting whether one was available and, if not, how long until
+	// one is. A missing bucket is full.
+	Take(ctx context.Context, key string, burst int, period time.Duration) (bool, time.Duration, error)
+
+	// Close releases the store's connections
+	Close() error
+}
+
+// GetJSON decodes the value stored at key into out, reporting whether it was found. Store
+// errors and undecodable values are logged and treated as misses, so lookups fall back to their
+// source when the cache is unavailable.
+func GetJSON(ctx context.Context, store Store, key string, out any) bool {
+	value, ok, err := store.Get(ctx, key)
+	if err != nil {
+		logging.Warnf("Cache read for %s failed: %v", key, err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(value, out); err != nil {
+		logging.Warnf("Ignoring undecodable cache entry %s: %v", key, err)
+		return false
+	}
+	return true
+}
+
+// SetJSON stores the JSON encoding of value at key for ttl, logging failures
+func SetJSON(ctx context.Context, store Store, key string, value any, ttl time.Duration) {
+	data, err := json.Marshal(value)
+	if err == nil {
+		err = store.Set(ctx, key, data, ttl)
+	}
+	if err != nil {
+		logging.Warnf("Cache write for %s failed: %v", key, err)
+	}
+}
@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// entry is a stored value, counter, or token bucket with its expiry
+type entry struct {
+	value   []byte
+	count   int64
+	tokens  float64
+	updated time.Time
+	expires time.Time
+}
+
+// Memory is a Store held in process memory, bounded to a maximum number of entries
+type Memory struct {
+	mu         sync.Mutex
+	entries    map[string]*entry
+	maxEntries int
+	now        func() time.Time
+}
+
+// NewMemory creates a Memory store holding at most maxEntries values and counters
+func NewMemory(maxEntries int) *Memory {
+	return &Memory{
+		entries:    make(map[string]*entry),
+		maxEntries: maxEntries,
+		now:        time.Now,
+	}
+}
+
+// Get returns the unexpired value stored at key
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok || !m.now().Before(e.expires) || e.value == nil {
+		return nil, false, nil
+	}
+	return e.value, true, nil
+}
+
+// Set stores value at key for ttl
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.makeRoom(now, key)
+	m.entries[key] = &entry{value: append([]byte{}, value...), expires: now.Add(ttl)}
+	return nil
+}
+
+// Delete removes key
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// Incr increments the counter at key, starting a new window when it is missing or expired
+func (m *Memory) Incr(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	e, ok := m.entries[key]
+	if !ok || !now.Before(e.expires) {
+		m.makeRoom(now, key)
+		e = &entry{expires: now.Add(window)}
+		m.entries[key] = e
+	}
+	e.count++
+	return e.count, e.expires.Sub(now), nil
+}
+
+// Take removes a token from the bucket at key after refilling it for the time since it was last
+// used. The bucket expires once it would be full again, which is the same as missing.
+func (m *Memory) Take(ctx context.Context, key string, burst int, period time.Duration) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	burst = max(burst, 1)
+	interval := float64(period) / float64(burst)
+
+	now := m.now()
+	e, ok := m.entries[key]
+	if !ok || !now.Before(e.expires) {
+		m.makeRoom(now, key)
+		e = &entry{tokens: float64(burst), updated: now}
+		m.entries[key] = e
+	}
+	e.tokens = min(float64(burst), e.tokens+float64(now.Sub(e.updated))/interval)
+	e.updated = now
+
+	var wait time.Duration
+	allowed := e.tokens >= 1
+	if allowed {
+		e.tokens--
+	} else {
+		wait = time.Duration(math.Ceil((1 - e.tokens) * interval))
+	}
+	e.expires = now.Add(time.Duration(math.Ceil((float64(burst) - e.tokens) * interval)))
+	return allowed, wait, nil
+}
+
+// Close does nothing; a Memory store holds no connections
+func (m *Memory) Close() error {
+	return nil
+}
+
+// makeRoom evicts entries so key can be added: expired entries first, then arbitrary ones
+func (m *Memory) makeRoom(now time.Time, key string) {
+	if _, ok := m.entries[key]; ok || len(m.entries) < m.maxEntries {
+		return
+	}
+	for k, e := range m.entries {
+		if !now.Before(e.expires) {
+			delete(m.entries, k)
+		}
+	}
+	for k := range m.entries {
+		if len(m.entries) < m.maxEntries {
+			break
+		}
+		delete(m.entries, k)
+	}
+}
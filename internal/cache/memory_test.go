@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryGetSet(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(10)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	if _, ok, _ := m.Get(ctx, "a"); ok {
+		t.Error("Expected a miss for an unknown key")
+	}
+
+	m.Set(ctx, "a", []byte("1"), time.Minute)
+	if value, ok, err := m.Get(ctx, "a"); !ok || err != nil || string(value) != "1" {
+		t.Errorf("Get() = %q, %t, %v, want 1", value, ok, err)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok, _ := m.Get(ctx, "a"); ok {
+		t.Error("Expected the entry to expire")
+	}
+
+	m.Set(ctx, "b", []byte("2"), time.Minute)
+	m.Delete(ctx, "b")
+	if _, ok, _ := m.Get(ctx, "b"); ok {
+		t.Error("Expected the entry to be deleted")
+	}
+}
+
+func TestMemoryIncr(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(10)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	for want := int64(1); want <= 3; want++ {
+		count, ttl, err := m.Incr(ctx, "n", time.Minute)
+		if count != want || ttl != time.Minute || err != nil {
+			t.Errorf("Incr() = %d, %v, %v, want %d, 1m", count, ttl, err, want)
+		}
+	}
+
+	now = now.Add(40 * time.Second)
+	if count, ttl, _ := m.Incr(ctx, "n", time.Minute); count != 4 || ttl != 20*time.Second {
+		t.Errorf("Incr() = %d, %v, want 4 with 20s left", count, ttl)
+	}
+
+	// A new window starts once the old one ends
+	now = now.Add(20 * time.Second)
+	if count, ttl, _ := m.Incr(ctx, "n", time.Minute); count != 1 || ttl != time.Minute {
+		t.Errorf("Incr() = %d, %v, want a new window", count, ttl)
+	}
+
+	// Counters are not values
+	if _, ok, _ := m.Get(ctx, "n"); ok {
+		t.Error("Expected Get to miss a counter")
+	}
+}
+
+func TestMemoryTake(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(10)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _, err := m.Take(ctx, "b", 3, time.Minute); !ok || err != nil {
+			t.Fatalf("Take() %d = %t, %v, want a token from the burst", i+1, ok, err)
+		}
+	}
+	if ok, wait, _ := m.Take(ctx, "b", 3, time.Minute); ok || wait != 20*time.Second {
+		t.Errorf("Take() = %t, %v, want no token for 20s", ok, wait)
+	}
+
+	// One token is refilled every 20s
+	now = now.Add(25 * time.Second)
+	if ok, _, _ := m.Take(ctx, "b", 3, time.Minute); !ok {
+		t.Error("Expected a refilled token")
+	}
+	if ok, wait, _ := m.Take(ctx, "b", 3, time.Minute); ok || wait != 15*time.Second {
+		t.Errorf("Take() = %t, %v, want no token for 15s", ok, wait)
+	}
+
+	// The bucket expires once full again, and refills no further than the burst
+	now = now.Add(time.Hour)
+	if _, ok := m.entries["b"]; ok && now.Before(m.entries["b"].expires) {
+		t.Error("Expected a full bucket to expire")
+	}
+	for i := 0; i < 3; i++ {
+		m.Take(ctx, "b", 3, time.Minute)
+	}
+	if ok, _, _ := m.Take(ctx, "b", 3, time.Minute); ok {
+		t.Error("Expected the bucket to hold at most the burst")
+	}
+}
+
+func TestMemoryBounded(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(3)
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		m.Set(ctx, key, []byte(key), time.Minute)
+	}
+	if len(m.entries) != 3 {
+		t.Errorf("Expected 3 entries, got %d", len(m.entries))
+	}
+	if _, ok, _ := m.Get(ctx, "e"); !ok {
+		t.Error("Expected the newest entry to be kept")
+	}
+}
+
+func TestJSONHelpers(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(10)
+
+	SetJSON(ctx, m, "names", []string{"a.example", "b.example"}, time.Minute)
+	var names []string
+	if !GetJSON(ctx, m, "names", &names) || len(names) != 2 || names[1] != "b.example" {
+		t.Errorf("GetJSON() = %v", names)
+	}
+
+	m.Set(ctx, "broken", []byte("{"), time.Minute)
+	if GetJSON(ctx, m, "broken", &names) {
+		t.Error("Expected an undecodable entry to be a miss")
+	}
+	if GetJSON(ctx, m, "missing", &names) {
+		t.Error("Expected a missing entry to be a miss")
+	}
+}
@@ -0,0 +1,322 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Redis connection settings
+const (
+	redisDialTimeout    = 2 * time.Second
+	redisCommandTimeout = time.Second
+	redisMaxIdle        = 16
+	redisDefaultPort    = "6379"
+)
+
+// incrScript increments a counter and starts its window when it is new, returning the count and
+// the milliseconds left in the window in a single round trip
+const incrScript = `local n = redis.call('INCR', KEYS[1])
+if n == 1 or redis.call('PTTL', KEYS[1]) < 0 then redis.call('PEXPIRE', KEYS[1], ARGV[1]) end
+return {n, redis.call('PTTL', KEYS[1])}`
+
+// takeScript removes a token from a bucket hash of tokens and last update time, refilled from the
+// server clock so that replicas with skewed clocks agree, returning 1 or 0 for whether a token was
+// taken and the milliseconds until one is available. ARGV holds the burst and the refill period
+// in milliseconds. The hash expires once the bucket would be full again.
+const takeScript = `if redis.replicate_commands then redis.replicate_commands() end
+local burst = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2]) / burst
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1]) or burst
+local updated = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(now - updated, 0) / interval)
+local allowed, wait = 0, 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  wait = math.ceil((1 - tokens) * interval)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', now)
+redis.call('PEXPIRE', KEYS[1], math.max(math.ceil((burst - tokens) * interval), 1))
+return {allowed, wait}`
+
+// redisError is an error reply from the server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// Redis is a Store kept in a Redis server, shared by every replica using the same server and
+// key prefix. It speaks RESP over a small pool of connections, so it needs no client library.
+type Redis struct {
+	addr      string
+	tlsConfig *tls.Config
+	username  string
+	password  string
+	db        int
+	prefix    string
+
+	idle chan *redisConn
+}
+
+// redisConn is a pooled connection with its reply reader
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedis creates a Redis store for a URL such as redis://:password@cache:6379/2, or rediss://
+// for TLS, prefixing every key with prefix. Connections are opened on first use.
+func NewRedis(rawURL, prefix string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("invalid REDIS_URL %q: scheme must be redis or rediss", u.Redacted())
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid REDIS_URL %q: missing host", u.Redacted())
+	}
+
+	r := &Redis{
+		addr:   u.Host,
+		prefix: prefix,
+		idle:   make(chan *redisConn, redisMaxIdle),
+	}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), redisDefaultPort)
+	}
+	if u.Scheme == "rediss" {
+		r.tlsConfig = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	}
+	if u.User != nil {
+		r.username = u.User.Username()
+		r.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil || r.db < 0 {
+			return nil, fmt.Errorf("invalid REDIS_URL %q: database must be a number", u.Redacted())
+		}
+	}
+	return r, nil
+}
+
+// Get returns the value stored at key
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := r.do(ctx, "GET", r.prefix+key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected GET reply %T", reply)
+	}
+	return value, true, nil
+}
+
+// Set stores value at key for ttl
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := r.do(ctx, "SET", r.prefix+key, string(value), "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	return err
+}
+
+// Delete removes key
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	_, err := r.do(ctx, "DEL", r.prefix+key)
+	return err
+}
+
+// Incr increments the counter at key, starting a window of the given length when it is new
+func (r *Redis) Incr(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	reply, err := r.do(ctx, "EVAL", incrScript, "1", r.prefix+key, strconv.FormatInt(max(window.Milliseconds(), 1), 10))
+	if err != nil {
+		return 0, 0, err
+	}
+	values, ok := reply.([]any)
+	if !ok || len(values) != 2 {
+		return 0, 0, fmt.Errorf("redis: unexpected EVAL reply %v", reply)
+	}
+	count, countOK := values[0].(int64)
+	ttl, ttlOK := values[1].(int64)
+	if !countOK || !ttlOK {
+		return 0, 0, fmt.Errorf("redis: unexpected EVAL reply %v", reply)
+	}
+	return count, time.Duration(max(ttl, 0)) * time.Millisecond, nil
+}
+
+// Take removes a token from the bucket at key in a single round trip
+func (r *Redis) Take(ctx context.Context, key string, burst int, period time.Duration) (bool, time.Duration, error) {
+	reply, err := r.do(ctx, "EVAL", takeScript, "1", r.prefix+key,
+		strconv.Itoa(max(burst, 1)), strconv.FormatInt(max(period.Milliseconds(), 1), 10))
+	if err != nil {
+		return false, 0, err
+	}
+	values, ok := reply.([]any)
+	if !ok || len(values) != 2 {
+		return false, 0, fmt.Errorf("redis: unexpected EVAL reply %v", reply)
+	}
+	allowed, allowedOK := values[0].(int64)
+	wait, waitOK := values[1].(int64)
+	if !allowedOK || !waitOK {
+		return false, 0, fmt.Errorf("redis: unexpected EVAL reply %v", reply)
+	}
+	return allowed == 1, time.Duration(max(wait, 0)) * time.Millisecond, nil
+}
+
+// Close closes the idle connections
+func (r *Redis) Close() error {
+	for {
+		select {
+		case conn := <-r.idle:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do sends a command and returns its reply: nil, int64, string, []byte, or []any. Error replies
+// are returned as errors, and the connection is reused unless the exchange failed.
+func (r *Redis) do(ctx context.Context, args ...string) (any, error) {
+	conn, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisCommandTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	reply, err := conn.command(args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		conn.Close()
+		return nil, err
+	}
+
+	select {
+	case r.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+// conn returns an idle connection or dials a new one, authenticating and selecting the database
+func (r *Redis) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-r.idle:
+		return conn, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: redisDialTimeout}
+	var raw net.Conn
+	var err error
+	if r.tlsConfig != nil {
+		raw, err = (&tls.Dialer{NetDialer: dialer, Config: r.tlsConfig}).DialContext(ctx, "tcp", r.addr)
+	} else {
+		raw, err = dialer.DialContext(ctx, "tcp", r.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	logger.Debugf("Connected to Redis at %s", r.addr)
+
+	conn := &redisConn{Conn: raw, reader: bufio.NewReader(raw)}
+	conn.SetDeadline(time.Now().Add(redisCommandTimeout))
+
+	if r.password != "" {
+		args := []string{"AUTH", r.password}
+		if r.username != "" {
+			args = []string{"AUTH", r.username, r.password}
+		}
+		if _, err := conn.command(args...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if r.db != 0 {
+		if _, err := conn.command("SELECT", strconv.Itoa(r.db)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// command writes a command as a RESP array of bulk strings and reads the reply
+func (c *redisConn) command(args ...string) (any, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.Conn, b.String()); err != nil {
+		return nil, err
+	}
+	return readReply(c.reader)
+}
+
+// readReply parses a RESP2 reply
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(body)
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:size], nil
+	case '*':
+		count, err := strconv.Atoi(body)
+		if err != nil || count < 0 {
+			return nil, err
+		}
+		values := make([]any, count)
+		for i := range values {
+			if values[i], err = readReply(r); err != nil {
+				var replyErr redisError
+				if !errors.As(err, &replyErr) {
+					return nil, err
+				}
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
@@ -0,0 +1,247 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a single-database RESP server supporting the commands the store sends
+type fakeRedis struct {
+	t        *testing.T
+	listener net.Listener
+	password string
+
+	mu       sync.Mutex
+	values   map[string]string
+	ttls     map[string]time.Duration
+	commands []string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{t: t, listener: l, password: password, values: map[string]string{}, ttls: map[string]time.Duration{}}
+	t.Cleanup(func() { l.Close() })
+	go f.serve()
+	return f
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		f.mu.Lock()
+		f.commands = append(f.commands, args[0])
+		reply := ""
+		switch {
+		case args[0] == "AUTH":
+			if args[len(args)-1] == f.password {
+				authed = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "SELECT":
+			reply = "+OK\r\n"
+		case args[0] == "GET":
+			if value, ok := f.values[args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case args[0] == "SET":
+			f.values[args[1]] = args[2]
+			ms, _ := strconv.Atoi(args[4])
+			f.ttls[args[1]] = time.Duration(ms) * time.Millisecond
+			reply = "+OK\r\n"
+		case args[0] == "DEL":
+			_, ok := f.values[args[1]]
+			delete(f.values, args[1])
+			reply = ":0\r\n"
+			if ok {
+				reply = ":1\r\n"
+			}
+		case args[0] == "EVAL" && args[1] == takeScript:
+			// Buckets never refill here: a token is available for the first burst requests
+			key := args[3]
+			n, _ := strconv.Atoi(f.values[key])
+			n++
+			f.values[key] = strconv.Itoa(n)
+			burst, _ := strconv.Atoi(args[4])
+			ms, _ := strconv.Atoi(args[5])
+			if n <= burst {
+				reply = "*2\r\n:1\r\n:0\r\n"
+			} else {
+				reply = fmt.Sprintf("*2\r\n:0\r\n:%d\r\n", ms/burst)
+			}
+		case args[0] == "EVAL":
+			key := args[3]
+			n, _ := strconv.Atoi(f.values[key])
+			n++
+			f.values[key] = strconv.Itoa(n)
+			if n == 1 {
+				ms, _ := strconv.Atoi(args[4])
+				f.ttls[key] = time.Duration(ms) * time.Millisecond
+			}
+			reply = fmt.Sprintf("*2\r\n:%d\r\n:%d\r\n", n, f.ttls[key].Milliseconds())
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand parses a RESP array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	reply, err := readReply(r)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]any)
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("not a command: %v", reply)
+	}
+	args := make([]string, len(items))
+	for i, item := range items {
+		args[i] = string(item.([]byte))
+	}
+	return args, nil
+}
+
+func TestRedisStore(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	store, err := NewRedis("redis://:secret@"+server.listener.Addr().String()+"/2", "myip:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	if _, ok, err := store.Get(ctx, "missing"); ok || err != nil {
+		t.Errorf("Get(missing) = %t, %v, want a miss", ok, err)
+	}
+
+	value := "line one\r\nline two"
+	if err := store.Set(ctx, "rdap:203.0.113.7", []byte(value), time.Hour); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got, ok, err := store.Get(ctx, "rdap:203.0.113.7"); !ok || err != nil || string(got) != value {
+		t.Errorf("Get() = %q, %t, %v, want %q", got, ok, err, value)
+	}
+
+	if err := store.Delete(ctx, "rdap:203.0.113.7"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, ok, err := store.Get(ctx, "rdap:203.0.113.7"); ok || err != nil {
+		t.Errorf("Get() after Delete = %t, %v, want a miss", ok, err)
+	}
+
+	for want := int64(1); want <= 2; want++ {
+		count, ttl, err := store.Incr(ctx, "limit:dns:203.0.113.7", time.Minute)
+		if count != want || ttl != time.Minute || err != nil {
+			t.Errorf("Incr() = %d, %v, %v, want %d, 1m", count, ttl, err, want)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		ok, wait, err := store.Take(ctx, "bucket:dns:203.0.113.7", 2, time.Minute)
+		if want := i < 2; ok != want || err != nil {
+			t.Errorf("Take() %d = %t, %v, %v, want %t", i+1, ok, wait, err, want)
+		}
+		if i == 2 && wait != 30*time.Second {
+			t.Errorf("Take() wait = %v, want 30s", wait)
+		}
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.ttls["myip:rdap:203.0.113.7"] != time.Hour {
+		t.Errorf("Expected prefixed key with 1h TTL, got %v", server.ttls)
+	}
+	// Connections are reused: one AUTH and SELECT for all commands
+	if got := strings.Join(server.commands, " "); got != "AUTH SELECT GET SET GET DEL GET EVAL EVAL EVAL EVAL EVAL" {
+		t.Errorf("Commands = %s", got)
+	}
+}
+
+func TestRedisErrors(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	store, err := NewRedis("redis://:wrong@"+server.listener.Addr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Get(context.Background(), "a"); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("Expected an authentication error, got %v", err)
+	}
+
+	store, err = NewRedis("redis://127.0.0.1:1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Incr(context.Background(), "a", time.Minute); err == nil {
+		t.Error("Expected a connection error")
+	}
+}
+
+func TestNewRedis(t *testing.T) {
+	tests := []struct {
+		url     string
+		addr    string
+		db      int
+		tls     bool
+		wantErr bool
+	}{
+		{url: "redis://cache", addr: "cache:6379"},
+		{url: "redis://user:pw@cache:6380/3", addr: "cache:6380", db: 3},
+		{url: "rediss://cache.example.com", addr: "cache.example.com:6379", tls: true},
+		{url: "http://cache", wantErr: true},
+		{url: "redis://", wantErr: true},
+		{url: "redis://cache/x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		r, err := NewRedis(tt.url, "")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewRedis(%q) error = %v, wantErr %t", tt.url, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if r.addr != tt.addr || r.db != tt.db || (r.tlsConfig != nil) != tt.tls {
+			t.Errorf("NewRedis(%q) = addr %s db %d tls %t", tt.url, r.addr, r.db, r.tlsConfig != nil)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Package capture keeps the last requests to the IP detection endpoints in memory, sanitized and
+// with their detection results, so operators can see what a user's request looked like when it
+// was detected wrongly.
+package capture
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"myip/internal/ip"
+	"myip/internal/middleware"
+	"myip/internal/models"
+	"myip/internal/privacy"
+	"myip/internal/problem"
+	"myip/internal/requestid"
+)
+
+// MaxSize bounds the number of requests a buffer keeps
+const MaxSize = 10000
+
+// Redacted replaces the values of headers carrying credentials
+const Redacted = "[redacted]"
+
+// sensitiveHeaders carry credentials and are never captured, in canonical form
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"X-Api-Key":           true,
+	"X-Auth-Token":        true,
+}
+
+// Buffer is a ring buffer of the most recent requests
+type Buffer struct {
+	mu       sync.Mutex
+	requests []models.CapturedRequest
+	next     int
+	full     bool
+	now      func() time.Time
+}
+
+// New creates a buffer keeping the last size requests
+func New(size int) *Buffer {
+	return &Buffer{requests: make([]models.CapturedRequest, size), now: time.Now}
+}
+
+// Record adds a request, replacing the oldest once the buffer is full
+func (b *Buffer) Record(request models.CapturedRequest) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests[b.next] = request
+	b.next++
+	if b.next == len(b.requests) {
+		b.next = 0
+		b.full = true
+	}
+}
+
+// Recent returns up to limit captured requests, newest first, keeping only those detected as
+// clientIP unless it is empty
+func (b *Buffer) Recent(limit int, clientIP string) []models.CapturedRequest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := b.next
+	if b.full {
+		count = len(b.requests)
+	}
+	result := make([]models.CapturedRequest, 0, min(limit, count))
+	for i := 1; i <= count && len(result) < limit; i++ {
+		request := b.requests[(b.next-i+len(b.requests))%len(b.requests)]
+		if clientIP == "" || request.ClientIP == clientIP {
+			result = append(result, request)
+		}
+	}
+	return result
+}
+
+// capture builds the sanitized record of r, answered with status
+func (b *Buffer) capture(r *http.Request, status int) models.CapturedRequest {
+	addresses := ip.Extract(r)
+	headers := make(map[string][]string, len(r.Header))
+	for name, values := range r.Header {
+		if sensitiveHeaders[name] {
+			headers[name] = []string{Redacted}
+			continue
+		}
+		sanitized := make([]string, len(values))
+		for i, value := range values {
+			sanitized[i] = privacy.Text(value)
+		}
+		headers[name] = sanitized
+	}
+
+	return models.CapturedRequest{
+		Time:            b.now().UTC().Format(time.RFC3339Nano),
+		RequestID:       requestid.FromContext(r.Context()),
+		Method:          r.Method,
+		Path:            privacy.Text(r.URL.RequestURI()),
+		RemoteAddr:      privacy.Text(r.RemoteAddr),
+		Headers:         headers,
+		Status:          status,
+		ClientIP:        privacy.IP(addresses.ClientIP),
+		DetectedVia:     addresses.Source,
+		IPv4Address:     privacy.IP(addresses.IPv4),
+		IPv6Address:     privacy.IP(addresses.IPv6),
+		SpoofingReasons: ip.SpoofingReasons(r),
+	}
+}
+
+// Middleware records each request after it is answered
+func (b *Buffer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := middleware.NewStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+		b.Record(b.capture(r, rec.Status))
+	})
+}
+
+// Handler serves the captured requests, newest first; ?limit= caps how many and ?ip= keeps only
+// those detected as that client IP
+func (b *Buffer) Handler(w http.ResponseWriter, r *http.Request) {
+	limit := len(b.requests)
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid limit parameter: expected a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, limit)
+	}
+	clientIP := r.URL.Query().Get("ip")
+	if clientIP != "" && !ip.IsValid(clientIP) {
+		http.Error(w, "Invalid ip parameter: expected an IP address", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	report := models.CapturedRequests{Capacity: len(b.requests), Requests: b.Recent(limit, privacy.IP(clientIP))}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, "Failed to encode captured requests")
+	}
+}
@@ -0,0 +1,130 @@
+package capture
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"myip/internal/models"
+	"myip/internal/privacy"
+)
+
+func TestRecent(t *testing.T) {
+	buffer := New(3)
+	if recent := buffer.Recent(10, ""); len(recent) != 0 {
+		t.Fatalf("Expected an empty buffer, got %v", recent)
+	}
+
+	for _, clientIP := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.1", "192.0.2.3"} {
+		buffer.Record(models.CapturedRequest{ClientIP: clientIP})
+	}
+
+	var got []string
+	for _, request := range buffer.Recent(10, "") {
+		got = append(got, request.ClientIP)
+	}
+	if want := []string{"192.0.2.3", "192.0.2.1", "192.0.2.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the last three requests newest first, got %v", got)
+	}
+	if recent := buffer.Recent(1, ""); len(recent) != 1 || recent[0].ClientIP != "192.0.2.3" {
+		t.Errorf("Expected the newest request, got %v", recent)
+	}
+	if recent := buffer.Recent(10, "192.0.2.1"); len(recent) != 1 {
+		t.Errorf("Expected one request from 192.0.2.1 left in the buffer, got %v", recent)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	buffer := New(10)
+	buffer.now = func() time.Time { return time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC) }
+	handler := buffer.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	req := httptest.NewRequest("GET", "/json?format=json", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=secret")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	recent := buffer.Recent(10, "")
+	if len(recent) != 1 {
+		t.Fatalf("Expected one captured request, got %d", len(recent))
+	}
+	got := recent[0]
+	if got.Time != "2024-01-01T12:00:00Z" || got.Method != "GET" || got.Path != "/json?format=json" || got.Status != http.StatusTeapot {
+		t.Errorf("Unexpected request details: %+v", got)
+	}
+	if got.ClientIP != "203.0.113.7" || got.DetectedVia != "X-Forwarded-For" || got.RemoteAddr != "10.0.0.1:1234" {
+		t.Errorf("Unexpected detection result: %+v", got)
+	}
+	for _, name := range []string{"Authorization", "Cookie"} {
+		if values := got.Headers[name]; len(values) != 1 || values[0] != Redacted {
+			t.Errorf("Expected %s to be redacted, got %q", name, values)
+		}
+	}
+	if values := got.Headers["X-Forwarded-For"]; len(values) != 1 || values[0] != "203.0.113.7" {
+		t.Errorf("Expected X-Forwarded-For to be captured, got %q", values)
+	}
+}
+
+func TestMiddlewarePrivacyMode(t *testing.T) {
+	defer privacy.Configure(false, false)
+	privacy.Configure(true, false)
+
+	buffer := New(10)
+	handler := buffer.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	got := buffer.Recent(1, "")[0]
+	if got.ClientIP != "203.0.113.0" || got.Headers["X-Forwarded-For"][0] != "203.0.113.0" || got.RemoteAddr != "10.0.0.0:1234" {
+		t.Errorf("Expected truncated addresses, got %+v", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	buffer := New(5)
+	buffer.Record(models.CapturedRequest{ClientIP: "192.0.2.1"})
+	buffer.Record(models.CapturedRequest{ClientIP: "192.0.2.2"})
+
+	tests := []struct {
+		query  string
+		status int
+		count  int
+	}{
+		{"", http.StatusOK, 2},
+		{"?limit=1", http.StatusOK, 1},
+		{"?ip=192.0.2.1", http.StatusOK, 1},
+		{"?ip=198.51.100.1", http.StatusOK, 0},
+		{"?limit=0", http.StatusBadRequest, 0},
+		{"?limit=abc", http.StatusBadRequest, 0},
+		{"?ip=nope", http.StatusBadRequest, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			buffer.Handler(rr, httptest.NewRequest("GET", "/debug/requests"+test.query, nil))
+			if rr.Code != test.status {
+				t.Fatalf("Expected status %d, got %d", test.status, rr.Code)
+			}
+			if test.status != http.StatusOK {
+				return
+			}
+			var report models.CapturedRequests
+			if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Capacity != 5 || len(report.Requests) != test.count {
+				t.Errorf("Expected %d of 5 requests, got %d of %d", test.count, len(report.Requests), report.Capacity)
+			}
+		})
+	}
+}
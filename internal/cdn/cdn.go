@@ -0,0 +1,62 @@
+package cdn
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"myip/internal/models"
+)
+
+// Header is the response header echoing the CDN request ID, so a response can be matched to the
+// CDN's own logs
+const Header = "X-CDN-Ray-ID"
+
+// providers maps the request ID header each CDN adds to the origin request, in detection order.
+// The headers are named in canonical form, which Header.Get looks up without converting.
+var providers = []struct {
+	header string
+	name   string
+}{
+	{"Cf-Ray", "cloudflare"},
+	{"X-Amz-Cf-Id", "cloudfront"},
+	{"X-Azure-Ref", "azure-front-door"},
+	{"X-Akamai-Request-Id", "akamai"},
+	{"X-Cloud-Trace-Context", "google-cloud"},
+}
+
+// validID restricts accepted IDs to a bounded set of characters found in CDN request IDs, so the
+// value is safe to log and echo
+var validID = regexp.MustCompile(`^[A-Za-z0-9._:/;=+-]{1,128}$`)
+
+type contextKey struct{}
+
+// FromRequest returns the CDN request ID carried by r, or nil when it did not come through a known CDN
+func FromRequest(r *http.Request) *models.CDNTrace {
+	for _, p := range providers {
+		if id := r.Header.Get(p.header); id != "" && validID.MatchString(id) {
+			return &models.CDNTrace{Provider: p.name, RayID: id}
+		}
+	}
+	return nil
+}
+
+// FromContext returns the CDN trace stored by Middleware, or nil if there is none
+func FromContext(ctx context.Context) *models.CDNTrace {
+	trace, _ := ctx.Value(contextKey{}).(*models.CDNTrace)
+	return trace
+}
+
+// Middleware records the CDN request ID in the request context and echoes it in the response header
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace := FromRequest(r)
+		if trace == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set(Header, trace.RayID)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, trace)))
+	})
+}
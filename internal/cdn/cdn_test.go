@@ -0,0 +1,63 @@
+package cdn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"myip/internal/models"
+)
+
+func TestFromRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected *models.CDNTrace
+	}{
+		{"cloudflare", map[string]string{"CF-Ray": "8a1b2c3d4e5f6789-AMS"}, &models.CDNTrace{Provider: "cloudflare", RayID: "8a1b2c3d4e5f6789-AMS"}},
+		{"cloudfront", map[string]string{"X-Amz-Cf-Id": "Qm9vLTJ1c2VyX2lkPQ==_abc-123"}, &models.CDNTrace{Provider: "cloudfront", RayID: "Qm9vLTJ1c2VyX2lkPQ==_abc-123"}},
+		{"google", map[string]string{"X-Cloud-Trace-Context": "105445aa7843bc8bf206b12000100000/1;o=1"}, &models.CDNTrace{Provider: "google-cloud", RayID: "105445aa7843bc8bf206b12000100000/1;o=1"}},
+		{"cloudflare first", map[string]string{"X-Azure-Ref": "0abc", "CF-Ray": "8a1b-SIN"}, &models.CDNTrace{Provider: "cloudflare", RayID: "8a1b-SIN"}},
+		{"invalid id skipped", map[string]string{"CF-Ray": "<script>", "X-Azure-Ref": "0abc"}, &models.CDNTrace{Provider: "azure-front-door", RayID: "0abc"}},
+		{"no cdn", map[string]string{"X-Request-ID": "abc"}, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			for key, value := range tc.headers {
+				req.Header.Set(key, value)
+			}
+
+			got := FromRequest(req)
+			if (got == nil) != (tc.expected == nil) || (got != nil && *got != *tc.expected) {
+				t.Errorf("Expected %+v, got %+v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var seen *models.CDNTrace
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = FromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.Header.Set("CF-Ray", "8a1b2c3d4e5f6789-AMS")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Header().Get(Header) != "8a1b2c3d4e5f6789-AMS" {
+		t.Errorf("Expected %s header to echo the ray ID, got %q", Header, rr.Header().Get(Header))
+	}
+	if seen == nil || seen.Provider != "cloudflare" {
+		t.Errorf("Expected the trace in the request context, got %+v", seen)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/json", nil))
+	if rr.Header().Get(Header) != "" || seen != nil {
+		t.Errorf("Expected no trace without a CDN header, got %q %+v", rr.Header().Get(Header), seen)
+	}
+}
@@ -0,0 +1,317 @@
+// Package cluster makes replicas sharing a Redis cache aware of each other. Every node publishes
+// a heartbeat with its health and configuration hash and a snapshot of its request statistics,
+// and applies configuration reloads requested on any node, so the replicas of a deployment can
+// be inspected and reconfigured as one.
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"myip/internal/cache"
+	"myip/internal/logging"
+	"myip/internal/models"
+	"myip/internal/problem"
+)
+
+var logger = logging.For("cluster")
+
+// Node statuses reported in ClusterNode.Status
+const (
+	StatusOK          = "ok"
+	StatusMaintenance = "maintenance"
+)
+
+// Keys of the cluster state in the shared store
+const (
+	registryKey    = "cluster:nodes"
+	nodeKeyPrefix  = "cluster:node:"
+	statsKeyPrefix = "cluster:stats:"
+	reloadKey      = "cluster:reload"
+)
+
+// missedHeartbeats is how many heartbeats a node may miss before it drops out of the cluster
+const missedHeartbeats = 3
+
+// registryTTL is how long the node registry is kept without changes; it is rewritten well before
+const registryTTL = 24 * time.Hour
+
+// reloadTTL is how long a reload request is kept for nodes to pick up
+const reloadTTL = 24 * time.Hour
+
+// heartbeatTimeout bounds each heartbeat's store operations
+const heartbeatTimeout = 5 * time.Second
+
+// registry lists the IDs of the nodes in the cluster. Nodes add themselves and remove the nodes
+// whose heartbeat expired; Updated lets them refresh it before it expires.
+type registry struct {
+	Nodes   []string  `json:"nodes"`
+	Updated time.Time `json:"updated"`
+}
+
+// Options configure a Node
+type Options struct {
+	// ID identifies the node, such as its hostname; it must be unique in the cluster
+	ID string
+	// URL is the address other nodes and operators reach the node at, if any
+	URL string
+	// Version is the build version of the node
+	Version string
+	// Interval is the time between heartbeats
+	Interval time.Duration
+	// Maintenance reports whether the node is in maintenance mode
+	Maintenance func() bool
+	// Stats returns the node's request statistics to share; nil when statistics are off
+	Stats func() *models.StatsReport
+	// Reload re-reads the configuration, when another node requests a reload
+	Reload func()
+}
+
+// Node is this replica's membership in the cluster
+type Node struct {
+	store      cache.Store
+	opts       Options
+	startedAt  time.Time
+	configHash atomic.Pointer[string]
+	now        func() time.Time
+
+	// mu guards the reload request seen last; joined is false until the first heartbeat, which
+	// records the current request without acting on it
+	mu         sync.Mutex
+	lastReload string
+	joined     bool
+}
+
+// New creates the node described by opts, keeping the cluster state in store
+func New(store cache.Store, opts Options) *Node {
+	n := &Node{store: store, opts: opts, now: time.Now}
+	n.startedAt = n.now()
+	n.SetConfigHash("")
+	return n
+}
+
+// SetConfigHash sets the hash of the configuration in effect, which the node publishes so
+// diverging replicas can be spotted
+func (n *Node) SetConfigHash(hash string) {
+	n.configHash.Store(&hash)
+}
+
+// Heartbeat publishes the node and its statistics, registers it, and applies a configuration
+// reload requested since the previous heartbeat
+func (n *Node) Heartbeat(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, heartbeatTimeout)
+	defer cancel()
+
+	ttl := missedHeartbeats * n.opts.Interval
+	if err := n.set(ctx, nodeKeyPrefix+n.opts.ID, n.entry(), ttl); err != nil {
+		return err
+	}
+	if n.opts.Stats != nil {
+		if err := n.set(ctx, statsKeyPrefix+n.opts.ID, n.opts.Stats(), ttl); err != nil {
+			return err
+		}
+	}
+	if err := n.register(ctx); err != nil {
+		return err
+	}
+	return n.checkReload(ctx)
+}
+
+// entry describes the node as published in its heartbeat
+func (n *Node) entry() models.ClusterNode {
+	status := StatusOK
+	if n.opts.Maintenance != nil && n.opts.Maintenance() {
+		status = StatusMaintenance
+	}
+	return models.ClusterNode{
+		ID:         n.opts.ID,
+		URL:        n.opts.URL,
+		Version:    n.opts.Version,
+		Status:     status,
+		ConfigHash: *n.configHash.Load(),
+		StartedAt:  n.startedAt.UTC().Format(time.RFC3339),
+		LastSeen:   n.now().UTC().Format(time.RFC3339),
+	}
+}
+
+// register adds the node to the registry and drops the nodes whose heartbeat expired. The
+// registry is only written when it changes or is due for a refresh, so concurrent updates, which
+// can lose a write, are rare; a node lost that way adds itself again on its next heartbeat.
+func (n *Node) register(ctx context.Context) error {
+	var reg registry
+	if _, err := n.get(ctx, registryKey, &reg); err != nil {
+		return err
+	}
+
+	nodes := []string{n.opts.ID}
+	for _, id := range reg.Nodes {
+		if id == n.opts.ID {
+			continue
+		}
+		if _, ok, err := n.store.Get(ctx, nodeKeyPrefix+id); err != nil {
+			return err
+		} else if ok {
+			nodes = append(nodes, id)
+		}
+	}
+	slices.Sort(nodes)
+
+	now := n.now()
+	if slices.Equal(nodes, reg.Nodes) && now.Sub(reg.Updated) < registryTTL/2 {
+		return nil
+	}
+	logger.Debugf("Updating cluster registry: %v", nodes)
+	return n.set(ctx, registryKey, registry{Nodes: nodes, Updated: now}, registryTTL)
+}
+
+// checkReload reloads the configuration when a reload was requested since the last heartbeat
+func (n *Node) checkReload(ctx context.Context) error {
+	value, _, err := n.store.Get(ctx, reloadKey)
+	if err != nil {
+		return err
+	}
+	token := string(value)
+
+	n.mu.Lock()
+	requested := n.joined && token != "" && token != n.lastReload
+	n.lastReload, n.joined = token, true
+	n.mu.Unlock()
+
+	if requested {
+		logging.Infof("Cluster reload requested (%s), reloading", token)
+		n.opts.Reload()
+	}
+	return nil
+}
+
+// BroadcastReload reloads the configuration of this node now and of every other node on its
+// next heartbeat
+func (n *Node) BroadcastReload(ctx context.Context) error {
+	token := n.opts.ID + "@" + strconv.FormatInt(n.now().UnixNano(), 10)
+	if err := n.store.Set(ctx, reloadKey, []byte(token), reloadTTL); err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	n.lastReload = token
+	n.mu.Unlock()
+
+	n.opts.Reload()
+	return nil
+}
+
+// Nodes returns the nodes in the cluster, ordered by ID
+func (n *Node) Nodes(ctx context.Context) ([]models.ClusterNode, error) {
+	var reg registry
+	if _, err := n.get(ctx, registryKey, &reg); err != nil {
+		return nil, err
+	}
+
+	nodes := make([]models.ClusterNode, 0, len(reg.Nodes))
+	for _, id := range reg.Nodes {
+		var node models.ClusterNode
+		ok, err := n.get(ctx, nodeKeyPrefix+id, &node)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			node.Self = id == n.opts.ID
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
+}
+
+// PeerStats returns the statistics last shared by the other nodes. Nodes whose statistics
+// cannot be read are left out, so the cluster view degrades to the local one.
+func (n *Node) PeerStats(ctx context.Context) []*models.StatsReport {
+	var reg registry
+	if _, err := n.get(ctx, registryKey, &reg); err != nil {
+		logging.Warnf("Cluster statistics unavailable: %v", err)
+		return nil
+	}
+
+	var reports []*models.StatsReport
+	for _, id := range reg.Nodes {
+		if id == n.opts.ID {
+			continue
+		}
+		var report models.StatsReport
+		if ok, err := n.get(ctx, statsKeyPrefix+id, &report); err != nil {
+			logging.Warnf("Statistics of node %s unavailable: %v", id, err)
+		} else if ok {
+			reports = append(reports, &report)
+		}
+	}
+	return reports
+}
+
+// Status describes the cluster as seen from this node
+func (n *Node) Status(ctx context.Context) (*models.ClusterStatus, error) {
+	nodes, err := n.Nodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status := &models.ClusterStatus{Node: n.opts.ID, ConfigConsistent: true, Nodes: nodes}
+	for _, node := range nodes {
+		if node.ConfigHash != nodes[0].ConfigHash {
+			status.ConfigConsistent = false
+		}
+	}
+	return status, nil
+}
+
+// Handler serves the nodes in the cluster with their health and whether they run the same
+// configuration
+func (n *Node) Handler(w http.ResponseWriter, r *http.Request) {
+	status, err := n.Status(r.Context())
+	if err != nil {
+		logging.Warnf("Cluster state unavailable: %v", err)
+		problem.Error(w, r, http.StatusServiceUnavailable, "Cluster state unavailable")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, "Failed to encode cluster state")
+	}
+}
+
+// ReloadHandler reloads the configuration of every node in the cluster
+func (n *Node) ReloadHandler(w http.ResponseWriter, r *http.Request) {
+	if err := n.BroadcastReload(r.Context()); err != nil {
+		logging.Warnf("Cluster reload failed: %v", err)
+		problem.Error(w, r, http.StatusServiceUnavailable, "Cluster state unavailable")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// set stores the JSON encoding of value at key for ttl
+func (n *Node) set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", key, err)
+	}
+	return n.store.Set(ctx, key, data, ttl)
+}
+
+// get decodes the value stored at key into out, reporting whether it was found
+func (n *Node) get(ctx context.Context, key string, out any) (bool, error) {
+	data, ok, err := n.store.Get(ctx, key)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, fmt.Errorf("decoding %s: %w", key, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,155 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"myip/internal/cache"
+	"myip/internal/models"
+)
+
+// newTestNode creates a node on store whose clock reads *now
+func newTestNode(store cache.Store, id string, now *time.Time, reloads *int) *Node {
+	n := New(store, Options{
+		ID:       id,
+		Version:  "1.2.3",
+		Interval: 10 * time.Second,
+		Stats: func() *models.StatsReport {
+			return &models.StatsReport{Requests: 1, Formats: []models.StatsCount{{Name: id, Requests: 1}}}
+		},
+		Reload: func() { *reloads++ },
+	})
+	n.now = func() time.Time { return *now }
+	return n
+}
+
+func TestNodes(t *testing.T) {
+	ctx := context.Background()
+	store := cache.NewMemory(100)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var reloads int
+	a := newTestNode(store, "a", &now, &reloads)
+	b := newTestNode(store, "b", &now, &reloads)
+	a.SetConfigHash("abc")
+	b.SetConfigHash("abc")
+
+	for _, n := range []*Node{a, b, a} {
+		if err := n.Heartbeat(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	status, err := b.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Node != "b" || !status.ConfigConsistent || len(status.Nodes) != 2 {
+		t.Fatalf("Expected nodes a and b with the same configuration, got %+v", status)
+	}
+	if node := status.Nodes[0]; node.ID != "a" || node.Self || node.Status != StatusOK || node.Version != "1.2.3" {
+		t.Errorf("Unexpected node %+v", node)
+	}
+	if !status.Nodes[1].Self {
+		t.Error("Expected b to be marked as the node serving the status")
+	}
+
+	b.SetConfigHash("def")
+	if err := b.Heartbeat(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := a.Status(ctx); status.ConfigConsistent {
+		t.Error("Expected diverging configuration hashes to be reported")
+	}
+
+	reports := a.PeerStats(ctx)
+	if len(reports) != 1 || reports[0].Formats[0].Name != "b" {
+		t.Errorf("Expected the statistics of b alone, got %+v", reports)
+	}
+}
+
+func TestReload(t *testing.T) {
+	ctx := context.Background()
+	store := cache.NewMemory(100)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var reloadsA, reloadsB int
+	a := newTestNode(store, "a", &now, &reloadsA)
+	b := newTestNode(store, "b", &now, &reloadsB)
+
+	// A request made before a node joins is not applied when it does
+	if err := a.BroadcastReload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Heartbeat(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if reloadsA != 1 || reloadsB != 0 {
+		t.Fatalf("Expected only the requesting node to reload, got %d and %d", reloadsA, reloadsB)
+	}
+
+	now = now.Add(time.Second)
+	if err := a.BroadcastReload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []*Node{a, b, b} {
+		if err := n.Heartbeat(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if reloadsA != 2 || reloadsB != 1 {
+		t.Errorf("Expected each node to reload once per request, got %d and %d", reloadsA, reloadsB)
+	}
+}
+
+func TestExpiredNodesDropOut(t *testing.T) {
+	ctx := context.Background()
+	store := cache.NewMemory(100)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var reloads int
+	a := newTestNode(store, "a", &now, &reloads)
+	b := newTestNode(store, "b", &now, &reloads)
+	for _, n := range []*Node{a, b} {
+		if err := n.Heartbeat(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// b stops sending heartbeats and its entry expires
+	store.Delete(ctx, nodeKeyPrefix+"b")
+	if err := a.Heartbeat(ctx); err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := a.Nodes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].ID != "a" {
+		t.Errorf("Expected b to drop out, got %+v", nodes)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	store := cache.NewMemory(100)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var reloads int
+	n := newTestNode(store, "a", &now, &reloads)
+	if err := n.Heartbeat(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	n.Handler(rr, httptest.NewRequest("GET", "/cluster", nil))
+	var status models.ClusterStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil || len(status.Nodes) != 1 {
+		t.Errorf("Expected the cluster status, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	n.ReloadHandler(rr, httptest.NewRequest("POST", "/admin/cluster/reload", nil))
+	if rr.Code != http.StatusAccepted || reloads != 1 {
+		t.Errorf("Expected 202 and a reload, got %d and %d reloads", rr.Code, reloads)
+	}
+}
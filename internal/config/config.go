@@ -1,32 +1,878 @@
 package config
 
-import "os"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"myip/internal/capture"
+	"myip/internal/i18n"
+	"myip/internal/ip"
+	"myip/internal/models"
+	"myip/internal/proxyprofile"
+	"myip/internal/quota"
+
+	"github.com/akhfa/myip/ipdetect"
+)
 
 // Config holds application configuration
 type Config struct {
 	Port string
 	Host string
+
+	// PathNormalization controls how non-canonical paths such as /IPv6/ are handled:
+	// "rewrite" (default), "redirect", or "off"
+	PathNormalization string
+
+	// ListenSockets is the number of listening sockets bound to the HTTP port with SO_REUSEPORT,
+	// each with its own accept loop; 1 (default) opens a single ordinary socket
+	ListenSockets int
+
+	// UnixSocket is the path of a Unix domain socket served instead of the TCP port, for use behind
+	// a local reverse proxy; UnixSocketMode sets its file permissions. Both are ignored when
+	// systemd passes in activated sockets.
+	UnixSocket     string
+	UnixSocketMode os.FileMode
+
+	// Routes selects the route sets served by the main listener, joined by "+" such as "ip+docs",
+	// or "all" (default). Listeners adds TCP listeners as "addr=sets" entries, such as
+	// "127.0.0.1:9090=health+metrics+admin", each with its own routes.
+	Routes    string
+	Listeners []string
+
+	// EnabledEndpoints, when set, limits the endpoints registered to the listed paths, and
+	// DisabledEndpoints leaves the listed paths out, such as "/headers,/echo". An entry covers the
+	// paths below it, so "/admin" covers every admin endpoint. Left-out paths answer 404.
+	EnabledEndpoints  []string
+	DisabledEndpoints []string
+
+	// MaxBodyBytes caps the size of request bodies
+	MaxBodyBytes int64
+
+	// Connection handling: the largest accepted request header block, how long idle keep-alive
+	// connections are kept, and whether HTTP keep-alives are enabled at all
+	MaxHeaderBytes int
+	IdleTimeout    time.Duration
+	KeepAlive      bool
+
+	// ProxyProtocol reads a PROXY protocol (v1 or v2) header from accepted connections so
+	// RemoteAddr is the client behind a TCP load balancer: "off" (default), "required" to close
+	// connections without one, or "optional"
+	ProxyProtocol string
+
+	// TCP socket options for accepted connections: TCPNoDelay disables Nagle's algorithm, and
+	// TCPLinger is the SO_LINGER timeout in seconds (-1 keeps the OS default, 0 resets on close)
+	TCPNoDelay bool
+	TCPLinger  int
+
+	// Concurrency limits on the service endpoints: the number of requests handled at once across
+	// all clients and per client IP; 0 disables the limit. Reloadable.
+	MaxInFlight      int
+	MaxInFlightPerIP int
+
+	// Instance metadata published by /about: InstanceName, the operator's ContactEmail for abuse
+	// reports, and PrivacyPolicyURL. Reloadable.
+	InstanceName     string
+	ContactEmail     string
+	PrivacyPolicyURL string
+
+	// ConfigFile is an optional YAML or KEY=VALUE file watched for changes;
+	// environment variables take precedence over its values
+	ConfigFile          string
+	ConfigWatchInterval time.Duration
+
+	// Reloadable settings, re-applied on SIGHUP or when ConfigFile changes
+	LogLevel        string
+	LogDebugModules []string
+	TrustedProxies  []string
+	HeaderPriority  []string
+
+	// XFFStrategy selects the address taken from X-Forwarded-For and similar headers: "leftmost"
+	// (default), "rightmost", or "rightmost-untrusted", the last address outside TrustedProxies.
+	// Reloadable.
+	XFFStrategy string
+
+	// ProxyProfiles names built-in platform profiles such as "cloudflare" or "aws-alb". They
+	// default HeaderPriority and XFFStrategy when those are unset and add the platform's ranges
+	// to TrustedProxies. Published ranges are refreshed every ProxyRangesRefresh, or never when 0.
+	// Reloadable, except that ranges are only refreshed for the profiles set at startup.
+	ProxyProfiles      []string
+	ProxyRangesRefresh time.Duration
+
+	// ShadowHeaderPriority and ShadowXFFStrategy describe a candidate detection configuration run
+	// alongside the one in effect, counting and logging the requests on which they disagree
+	// without changing responses. Shadow detection is off while both are unset; an unset one
+	// takes the value in effect. Reloadable.
+	ShadowHeaderPriority []string
+	ShadowXFFStrategy    string
+
+	// NAT64Prefixes are the network's own NAT64 prefixes, recognized along with 64:ff9b::/96 and
+	// 64:ff9b:1::/48. Reloadable.
+	NAT64Prefixes []string
+
+	// StrictValidation handles requests whose proxy-header client IP is private or bogon while the
+	// peer is public: "off" (default), "warn" to add a warning to /json and /info, or "reject"
+	// to refuse them with 400. Reloadable.
+	StrictValidation string
+
+	// ClientIPResponseHeader names the response header carrying the client IP on every endpoint,
+	// X-Client-IP by default, or "off" to leave it out. Reloadable.
+	ClientIPResponseHeader string
+
+	// Data minimization: PrivacyMode truncates the client addresses written to logs and statistics
+	// to their /24 or /48 network, and PrivacyOmitUserAgent leaves the User-Agent out of responses.
+	// Reloadable.
+	PrivacyMode          bool
+	PrivacyOmitUserAgent bool
+
+	// STUNAddr is the UDP address of the STUN Binding responder; disabled when empty
+	STUNAddr string
+
+	// ConnectivityIPv4URL and ConnectivityIPv6URL are base URLs of hostnames resolving only to the
+	// service's IPv4 and only to its IPv6 addresses, such as https://ipv4.example.com, which the
+	// /connectivity page loads to test each address family; a family is left untested when unset
+	ConnectivityIPv4URL string
+	ConnectivityIPv6URL string
+
+	// TLS certificate and key; the server listens with HTTPS when both are set
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// AdminToken protects the /admin/ endpoints; admin endpoints are disabled when empty
+	AdminToken string
+
+	// APIKeys lists "key=tier" entries for clients sending the X-API-Key header, and QuotaTiers
+	// "tier=requests/period" entries giving each tier daily or monthly request quotas, such as
+	// "free=1000/day,pro=100000/month". Requests without a key are not counted. Reloadable.
+	APIKeys    []string
+	QuotaTiers []string
+
+	// PprofEnabled serves the net/http/pprof profiling endpoints under /debug/pprof/, protected
+	// by AdminToken
+	PprofEnabled bool
+
+	// Maintenance mode settings
+	MaintenanceMode       bool
+	MaintenanceMessage    string
+	MaintenanceRetryAfter time.Duration
+
+	// DNS lookup endpoint settings. DNSRateLimitAlgorithm is "window" (default) for fixed
+	// one-minute windows or "bucket" for token buckets refilled over the minute.
+	DNSAllowlist          []string
+	DNSRateLimit          int
+	DNSRateLimitAlgorithm string
+	DNSTimeout            time.Duration
+
+	// OutboundIPPreference orders the addresses tried by outbound connections: "auto" (default),
+	// "ipv6" to prefer AAAA records on IPv6-only hosts behind NAT64, or "ipv4"
+	OutboundIPPreference string
+
+	// RDAP lookups for /whois: registry bootstrap URL, query timeout, result cache lifetime,
+	// and the number of queries per minute sent to the registries
+	RDAPURL       string
+	RDAPTimeout   time.Duration
+	RDAPCacheTTL  time.Duration
+	RDAPRateLimit int
+
+	// Threat-intel feeds checked for the client IP: a "name=source" entry per feed, where source
+	// is a local file or http(s) URL, refreshed every ThreatFeedRefresh; disabled when empty
+	ThreatFeeds       []string
+	ThreatFeedRefresh time.Duration
+	ThreatFeedTimeout time.Duration
+
+	// Circuit breakers around DNS, RDAP, and feed downloads: BreakerThreshold consecutive failures
+	// suspend calls for BreakerBackoff, doubling up to BreakerMaxBackoff while retries fail; 0 disables
+	BreakerThreshold  int
+	BreakerBackoff    time.Duration
+	BreakerMaxBackoff time.Duration
+
+	// IP type classification: an optional ip2asn database and a prefix list checked before the bundled ranges
+	IPASNDB        string
+	IPTypePrefixes string
+
+	// GeoLite2 updates: with a MaxMind account and license key, the ASN and country CSV editions
+	// are downloaded from GeoIPDownloadURL every GeoIPUpdateInterval, replacing IPASNDB's ranges
+	MaxMindAccountID    string
+	MaxMindLicenseKey   string
+	GeoIPDownloadURL    string
+	GeoIPUpdateInterval time.Duration
+
+	// ResponseCacheEntries bounds the cache of pre-serialized /, /ipv6, and /json responses; 0 disables it
+	ResponseCacheEntries int
+
+	// Plain-text IP responses from / and /ipv6: PlainTextNewline appends a trailing newline and
+	// PlainTextCharset declares charset=utf-8; both are off by default for byte-for-byte ipify compatibility
+	PlainTextNewline bool
+	PlainTextCharset bool
+
+	// DefaultLocale is the language of the plain-text /info page for requests whose Accept-Language
+	// names no supported language
+	DefaultLocale string
+
+	// ?template= rendering of /info: TemplateDir holds operator templates (*.tmpl) selected with
+	// ?template=@name, and TemplateInline accepts templates given in the query string
+	TemplateDir    string
+	TemplateInline bool
+
+	// /.well-known/ routing: WellKnownDir holds static files such as security.txt, and
+	// ACMEChallenges serves HTTP-01 challenges registered through /admin/acme-challenge/
+	WellKnownDir   string
+	ACMEChallenges bool
+
+	// RobotsTxtFile replaces the default /robots.txt, which keeps crawlers out of /headers and /echo
+	RobotsTxtFile string
+
+	// Request budgets of the service endpoints: RequestTimeout bounds each request's context, and
+	// RequestTimeouts holds "/path=duration" entries overriding it for single endpoints; 0 disables
+	// the deadline
+	RequestTimeout  time.Duration
+	RequestTimeouts []string
+
+	// Response delay shaping (?delay=) for testing client timeouts
+	DelayEnabled bool
+	DelayMax     time.Duration
+
+	// Caching headers of the IP detection endpoints for CDNs and caching proxies: "off" (default),
+	// "no-store", or "vary" to let caches keep responses for EdgeCacheMaxAge keyed on the client
+	// IP headers
+	EdgeCache       string
+	EdgeCacheMaxAge time.Duration
+
+	// Enrichment provider degradation: a "name=policy" entry per provider (omit, stale, or fail),
+	// the timeout for each lookup, and how long stale results may be served
+	EnrichPolicies []string
+	EnrichTimeout  time.Duration
+	EnrichStaleTTL time.Duration
+
+	// Service level objectives tracked in-process and reported at /slo
+	SLOAvailabilityTarget float64
+	SLOLatencyTarget      time.Duration
+
+	// Lookup cache shared by /whois, /hostname, and the DNS and RDAP rate limits: "memory"
+	// (default) or "redis" at RedisURL, with every key prefixed by CachePrefix. RDNSCacheTTL is
+	// how long /hostname results are cached; 0 disables it.
+	CacheBackend string
+	RedisURL     string
+	CachePrefix  string
+	RDNSCacheTTL time.Duration
+
+	// Cluster mode for replicas sharing the Redis cache: each node, identified by ClusterNodeID
+	// (the hostname by default) and reachable at ClusterAdvertiseURL, sends a heartbeat every
+	// ClusterHeartbeat, shares its request statistics, and applies reloads requested on any node
+	ClusterEnabled      bool
+	ClusterNodeID       string
+	ClusterAdvertiseURL string
+	ClusterHeartbeat    time.Duration
+
+	// Scanner bans: a client requesting ScannerBanThreshold paths probed by vulnerability scanners
+	// within ScannerBanWindow gets 403 for ScannerBanDuration; 0 only counts the requests
+	ScannerBanThreshold int
+	ScannerBanWindow    time.Duration
+	ScannerBanDuration  time.Duration
+
+	// StatsWindow is the sliding window of the request statistics served at /stats; 0 disables them
+	StatsWindow time.Duration
+
+	// RequestCaptureSize is the number of recent requests to the IP detection endpoints kept for
+	// /debug/requests; 0 disables capturing
+	RequestCaptureSize int
+
+	// settings are the keys read with their effective values and origins, sorted by key
+	settings []models.ConfigSetting
+
+	// invalid holds the values that could not be parsed and fell back to their defaults
+	invalid []error
+}
+
+// DefaultMaintenanceMessage is the message template returned while in maintenance mode
+const DefaultMaintenanceMessage = "Service is under maintenance. Please retry in {{.RetryAfter}} seconds."
+
+// filePath is the config file given on the command line; it takes precedence over CONFIG_FILE
+var filePath string
+
+// SetFile sets the config file to read, overriding the CONFIG_FILE environment variable
+func SetFile(path string) {
+	filePath = path
 }
 
-// Load loads configuration from environment variables
+// Load loads configuration from environment variables and the optional config file.
+// A config file that cannot be read is logged and ignored.
 func Load() *Config {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	cfg, err := Read()
+	if err != nil {
+		log.Printf("Ignoring config file: %v", err)
+	}
+	return cfg
+}
+
+// Read loads configuration like Load but reports config file errors to the caller.
+// The returned Config is always usable; on error it reflects the environment only.
+func Read() (*Config, error) {
+	src := source{settings: make(map[string]models.ConfigSetting), invalid: new([]error)}
+
+	configFile := filePath
+	if configFile == "" {
+		configFile = os.Getenv("CONFIG_FILE")
+	}
+
+	var fileErr error
+	if configFile != "" {
+		src.file, fileErr = readFile(configFile)
+	}
+
+	// Proxy profiles supply the defaults of the detection settings
+	proxyProfiles := src.getList("PROXY_PROFILES")
+	profileHeaders, profileStrategy := proxyprofile.Defaults(proxyProfiles)
+	if profileStrategy == "" {
+		profileStrategy = "leftmost"
+	}
+	headerPriority := src.getList("HEADER_PRIORITY")
+	if len(headerPriority) == 0 {
+		headerPriority = profileHeaders
+		src.record("HEADER_PRIORITY", strings.Join(headerPriority, ","), false)
+	}
+
+	cfg := &Config{
+		Port:                   src.get("PORT", "8080"),
+		Host:                   src.get("HOST", "localhost:8080"),
+		PathNormalization:      src.getChoice("PATH_NORMALIZATION", "rewrite", "rewrite", "redirect", "off"),
+		ListenSockets:          src.getInt("LISTEN_SOCKETS", 1),
+		UnixSocket:             src.get("UNIX_SOCKET", ""),
+		UnixSocketMode:         src.getFileMode("UNIX_SOCKET_MODE", 0o660),
+		Routes:                 src.get("ROUTES", "all"),
+		Listeners:              src.getList("LISTENERS"),
+		EnabledEndpoints:       src.getList("ENABLED_ENDPOINTS"),
+		DisabledEndpoints:      src.getList("DISABLED_ENDPOINTS"),
+		MaxBodyBytes:           int64(src.getInt("MAX_BODY_BYTES", 64<<10)),
+		MaxHeaderBytes:         src.getInt("MAX_HEADER_BYTES", 1<<20),
+		IdleTimeout:            src.getDuration("IDLE_TIMEOUT", 60*time.Second),
+		KeepAlive:              src.getBool("KEEP_ALIVE", true),
+		ProxyProtocol:          src.getChoice("PROXY_PROTOCOL", "off", "off", "required", "optional"),
+		TCPNoDelay:             src.getBool("TCP_NODELAY", true),
+		TCPLinger:              src.getInt("TCP_LINGER", -1),
+		MaxInFlight:            src.getInt("MAX_IN_FLIGHT", 0),
+		MaxInFlightPerIP:       src.getInt("MAX_IN_FLIGHT_PER_IP", 0),
+		InstanceName:           src.get("INSTANCE_NAME", ""),
+		ContactEmail:           src.get("CONTACT_EMAIL", ""),
+		PrivacyPolicyURL:       src.get("PRIVACY_POLICY_URL", ""),
+		ConfigFile:             configFile,
+		ConfigWatchInterval:    src.getDuration("CONFIG_WATCH_INTERVAL", 5*time.Second),
+		LogLevel:               src.get("LOG_LEVEL", "info"),
+		LogDebugModules:        src.getList("LOG_DEBUG_MODULES"),
+		TrustedProxies:         src.getList("TRUSTED_PROXIES"),
+		HeaderPriority:         headerPriority,
+		XFFStrategy:            src.getChoice("XFF_STRATEGY", profileStrategy, "leftmost", "rightmost", "rightmost-untrusted"),
+		ProxyProfiles:          proxyProfiles,
+		ProxyRangesRefresh:     src.getDuration("PROXY_RANGES_REFRESH", 24*time.Hour),
+		ShadowHeaderPriority:   src.getList("SHADOW_HEADER_PRIORITY"),
+		ShadowXFFStrategy:      src.getChoice("SHADOW_XFF_STRATEGY", "", "leftmost", "rightmost", "rightmost-untrusted"),
+		NAT64Prefixes:          src.getList("NAT64_PREFIXES"),
+		StrictValidation:       src.getChoice("STRICT_VALIDATION", "off", "off", "warn", "reject"),
+		ClientIPResponseHeader: src.get("CLIENT_IP_RESPONSE_HEADER", ip.DefaultResponseHeader),
+		PrivacyMode:            src.getBool("PRIVACY_MODE", false),
+		PrivacyOmitUserAgent:   src.getBool("PRIVACY_OMIT_USER_AGENT", false),
+		STUNAddr:               src.get("STUN_ADDR", ""),
+		ConnectivityIPv4URL:    src.get("CONNECTIVITY_IPV4_URL", ""),
+		ConnectivityIPv6URL:    src.get("CONNECTIVITY_IPV6_URL", ""),
+		TLSCertFile:            src.get("TLS_CERT_FILE", ""),
+		TLSKeyFile:             src.get("TLS_KEY_FILE", ""),
+		AdminToken:             src.get("ADMIN_TOKEN", ""),
+		APIKeys:                src.getList("API_KEYS"),
+		QuotaTiers:             src.getList("QUOTA_TIERS"),
+		PprofEnabled:           src.getBool("PPROF_ENABLED", false),
+		MaintenanceMode:        src.getBool("MAINTENANCE_MODE", false),
+		MaintenanceMessage:     src.get("MAINTENANCE_MESSAGE", DefaultMaintenanceMessage),
+		MaintenanceRetryAfter:  src.getDuration("MAINTENANCE_RETRY_AFTER", 5*time.Minute),
+		DNSAllowlist:           src.getList("DNS_ALLOWLIST"),
+		DNSRateLimit:           src.getInt("DNS_RATE_LIMIT", 30),
+		DNSRateLimitAlgorithm:  src.getChoice("DNS_RATE_LIMIT_ALGORITHM", "window", "window", "bucket"),
+		DNSTimeout:             src.getDuration("DNS_TIMEOUT", 3*time.Second),
+		OutboundIPPreference:   src.getChoice("OUTBOUND_IP_PREFERENCE", "auto", "auto", "ipv6", "ipv4"),
+		RDAPURL:                src.get("RDAP_URL", "https://rdap.org/ip/"),
+		RDAPTimeout:            src.getDuration("RDAP_TIMEOUT", 5*time.Second),
+		RDAPCacheTTL:           src.getDuration("RDAP_CACHE_TTL", 24*time.Hour),
+		RDAPRateLimit:          src.getInt("RDAP_RATE_LIMIT", 60),
+		ThreatFeeds:            src.getList("THREAT_FEEDS"),
+		ThreatFeedRefresh:      src.getDuration("THREAT_FEED_REFRESH", time.Hour),
+		ThreatFeedTimeout:      src.getDuration("THREAT_FEED_TIMEOUT", 30*time.Second),
+		BreakerThreshold:       src.getInt("BREAKER_THRESHOLD", 5),
+		BreakerBackoff:         src.getDuration("BREAKER_BACKOFF", 5*time.Second),
+		BreakerMaxBackoff:      src.getDuration("BREAKER_MAX_BACKOFF", 5*time.Minute),
+		IPASNDB:                src.get("IP_ASN_DB", ""),
+		MaxMindAccountID:       src.get("MAXMIND_ACCOUNT_ID", ""),
+		MaxMindLicenseKey:      src.get("MAXMIND_LICENSE_KEY", ""),
+		GeoIPDownloadURL:       src.get("GEOIP_DOWNLOAD_URL", "https://download.maxmind.com/geoip/databases/"),
+		GeoIPUpdateInterval:    src.getDuration("GEOIP_UPDATE_INTERVAL", 7*24*time.Hour),
+		IPTypePrefixes:         src.get("IP_TYPE_PREFIXES", ""),
+		ResponseCacheEntries:   src.getInt("RESPONSE_CACHE_ENTRIES", 10000),
+		PlainTextNewline:       src.getBool("PLAIN_TEXT_NEWLINE", false),
+		PlainTextCharset:       src.getBool("PLAIN_TEXT_CHARSET", false),
+		DefaultLocale:          strings.ToLower(src.get("DEFAULT_LOCALE", i18n.Default)),
+		TemplateDir:            src.get("TEMPLATE_DIR", ""),
+		TemplateInline:         src.getBool("TEMPLATE_INLINE", true),
+		WellKnownDir:           src.get("WELL_KNOWN_DIR", ""),
+		RobotsTxtFile:          src.get("ROBOTS_TXT_FILE", ""),
+		ACMEChallenges:         src.getBool("ACME_CHALLENGES", false),
+		RequestTimeout:         src.getDuration("REQUEST_TIMEOUT", 10*time.Second),
+		RequestTimeouts:        src.getList("REQUEST_TIMEOUTS"),
+		DelayEnabled:           src.getBool("DELAY_ENABLED", false),
+		DelayMax:               src.getDuration("DELAY_MAX", 5*time.Second),
+		EdgeCache:              src.getChoice("EDGE_CACHE", "off", "off", "no-store", "vary"),
+		EdgeCacheMaxAge:        src.getDuration("EDGE_CACHE_MAX_AGE", time.Minute),
+		EnrichPolicies:         src.getList("ENRICH_POLICIES"),
+		EnrichTimeout:          src.getDuration("ENRICH_TIMEOUT", 2*time.Second),
+		EnrichStaleTTL:         src.getDuration("ENRICH_STALE_TTL", time.Hour),
+		SLOAvailabilityTarget:  src.getFloat("SLO_AVAILABILITY_TARGET", 0.999),
+		SLOLatencyTarget:       src.getDuration("SLO_LATENCY_TARGET", 250*time.Millisecond),
+		CacheBackend:           src.getChoice("CACHE_BACKEND", "memory", "memory", "redis"),
+		RedisURL:               src.get("REDIS_URL", ""),
+		CachePrefix:            src.get("CACHE_PREFIX", "myip:"),
+		ClusterEnabled:         src.getBool("CLUSTER_ENABLED", false),
+		ClusterNodeID:          src.get("CLUSTER_NODE_ID", ""),
+		ClusterAdvertiseURL:    src.get("CLUSTER_ADVERTISE_URL", ""),
+		ClusterHeartbeat:       src.getDuration("CLUSTER_HEARTBEAT", 10*time.Second),
+		RDNSCacheTTL:           src.getDuration("RDNS_CACHE_TTL", time.Hour),
+		ScannerBanThreshold:    src.getInt("SCANNER_BAN_THRESHOLD", 0),
+		ScannerBanWindow:       src.getDuration("SCANNER_BAN_WINDOW", 10*time.Minute),
+		ScannerBanDuration:     src.getDuration("SCANNER_BAN_DURATION", time.Hour),
+		StatsWindow:            src.getDuration("STATS_WINDOW", time.Hour),
+		RequestCaptureSize:     src.getInt("REQUEST_CAPTURE_SIZE", 0),
+	}
+
+	switch {
+	case filePath != "":
+		src.settings["CONFIG_FILE"] = models.ConfigSetting{Key: "CONFIG_FILE", Value: filePath, Source: SourceFlag}
+	default:
+		src.record("CONFIG_FILE", configFile, configFile != "")
+	}
+	cfg.settings = make([]models.ConfigSetting, 0, len(src.settings))
+	for _, setting := range src.settings {
+		cfg.settings = append(cfg.settings, setting)
+	}
+	sort.Slice(cfg.settings, func(i, j int) bool { return cfg.settings[i].Key < cfg.settings[j].Key })
+	cfg.invalid = *src.invalid
+	return cfg, fileErr
+}
+
+// maxHeaderBytesLimit bounds MaxHeaderBytes so a misconfiguration cannot let clients pin large buffers
+const maxHeaderBytesLimit = 16 << 20
+
+// Validate reports settings whose values are out of range
+func (c *Config) Validate() error {
+	if len(c.invalid) > 0 {
+		return c.invalid[0]
+	}
+	if c.UnixSocket == "" {
+		if err := checkPort("PORT", c.Port); err != nil {
+			return err
+		}
+	}
+	if c.STUNAddr != "" {
+		_, port, err := net.SplitHostPort(c.STUNAddr)
+		if err != nil {
+			return fmt.Errorf("STUN_ADDR must be host:port such as :3478, got %q", c.STUNAddr)
+		}
+		if err := checkPort("STUN_ADDR", port); err != nil {
+			return err
+		}
+	}
+	if _, err := ipdetect.ParseCIDRs(c.TrustedProxies); err != nil {
+		return fmt.Errorf("TRUSTED_PROXIES must list CIDR ranges or addresses: %v", err)
+	}
+	if _, err := proxyprofile.Lookup(c.ProxyProfiles); err != nil {
+		return fmt.Errorf("PROXY_PROFILES: %v", err)
+	}
+	if _, err := ip.ParseNAT64Prefixes(c.NAT64Prefixes); err != nil {
+		return fmt.Errorf("NAT64_PREFIXES must list IPv6 prefixes: %v", err)
+	}
+	if c.ClientIPResponseHeader != "" && c.ClientIPResponseHeader != "off" && !ip.ValidHeaderName(c.ClientIPResponseHeader) {
+		return fmt.Errorf("CLIENT_IP_RESPONSE_HEADER must be a header name or off, got %q", c.ClientIPResponseHeader)
+	}
+	if c.ContactEmail != "" {
+		if address, err := mail.ParseAddress(c.ContactEmail); err != nil || address.Address != c.ContactEmail {
+			return fmt.Errorf("CONTACT_EMAIL must be an email address such as abuse@example.com, got %q", c.ContactEmail)
+		}
+	}
+	if c.PrivacyPolicyURL != "" {
+		if u, err := url.Parse(c.PrivacyPolicyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("PRIVACY_POLICY_URL must be an http or https URL, got %q", c.PrivacyPolicyURL)
+		}
+	}
+	if err := checkBaseURL("CONNECTIVITY_IPV4_URL", c.ConnectivityIPv4URL); err != nil {
+		return err
+	}
+	if err := checkBaseURL("CONNECTIVITY_IPV6_URL", c.ConnectivityIPv6URL); err != nil {
+		return err
+	}
+	for _, endpoints := range []struct {
+		key   string
+		paths []string
+	}{
+		{"ENABLED_ENDPOINTS", c.EnabledEndpoints},
+		{"DISABLED_ENDPOINTS", c.DisabledEndpoints},
+	} {
+		for _, path := range endpoints.paths {
+			if !strings.HasPrefix(path, "/") {
+				return fmt.Errorf("%s must list paths such as /headers, got %q", endpoints.key, path)
+			}
+		}
+	}
+	if _, err := quota.Parse(c.APIKeys, c.QuotaTiers); err != nil {
+		return fmt.Errorf("API_KEYS and QUOTA_TIERS: %v", err)
+	}
+	if c.ProxyRangesRefresh != 0 && c.ProxyRangesRefresh < time.Minute {
+		return fmt.Errorf("PROXY_RANGES_REFRESH must be 0 (never) or at least 1m, got %s", c.ProxyRangesRefresh)
 	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together; HTTPS needs both")
+	}
+	for _, file := range []struct{ key, path string }{
+		{"TLS_CERT_FILE", c.TLSCertFile},
+		{"TLS_KEY_FILE", c.TLSKeyFile},
+		{"IP_ASN_DB", c.IPASNDB},
+		{"IP_TYPE_PREFIXES", c.IPTypePrefixes},
+		{"ROBOTS_TXT_FILE", c.RobotsTxtFile},
+	} {
+		if err := checkPath(file.key, file.path, false); err != nil {
+			return err
+		}
+	}
+	for _, dir := range []struct{ key, path string }{
+		{"TEMPLATE_DIR", c.TemplateDir},
+		{"WELL_KNOWN_DIR", c.WellKnownDir},
+	} {
+		if err := checkPath(dir.key, dir.path, true); err != nil {
+			return err
+		}
+	}
+	if !i18n.IsSupported(c.DefaultLocale) {
+		return fmt.Errorf("DEFAULT_LOCALE must be one of %s, got %q", strings.Join(i18n.Supported(), ", "), c.DefaultLocale)
+	}
+	if c.MaxHeaderBytes < 4<<10 || c.MaxHeaderBytes > maxHeaderBytesLimit {
+		return fmt.Errorf("MAX_HEADER_BYTES must be between %d and %d, got %d", 4<<10, maxHeaderBytesLimit, c.MaxHeaderBytes)
+	}
+	if c.TCPLinger < -1 {
+		return fmt.Errorf("TCP_LINGER must be -1 (OS default) or a number of seconds, got %d", c.TCPLinger)
+	}
+	if c.MaxInFlight < 0 || c.MaxInFlightPerIP < 0 {
+		return fmt.Errorf("MAX_IN_FLIGHT and MAX_IN_FLIGHT_PER_IP must not be negative, got %d and %d", c.MaxInFlight, c.MaxInFlightPerIP)
+	}
+	if c.ListenSockets < 1 {
+		return fmt.Errorf("LISTEN_SOCKETS must be at least 1, got %d", c.ListenSockets)
+	}
+	if c.StatsWindow != 0 && (c.StatsWindow < time.Minute || c.StatsWindow > 7*24*time.Hour) {
+		return fmt.Errorf("STATS_WINDOW must be 0 (disabled) or between 1m and 168h, got %s", c.StatsWindow)
+	}
+	if c.RequestCaptureSize < 0 || c.RequestCaptureSize > capture.MaxSize {
+		return fmt.Errorf("REQUEST_CAPTURE_SIZE must be between 0 (disabled) and %d, got %d", capture.MaxSize, c.RequestCaptureSize)
+	}
+	if c.RequestTimeout < 0 {
+		return fmt.Errorf("REQUEST_TIMEOUT must not be negative, got %s", c.RequestTimeout)
+	}
+	if c.ScannerBanThreshold < 0 {
+		return fmt.Errorf("SCANNER_BAN_THRESHOLD must not be negative, got %d", c.ScannerBanThreshold)
+	}
+	if c.ScannerBanThreshold > 0 && (c.ScannerBanWindow <= 0 || c.ScannerBanDuration <= 0) {
+		return fmt.Errorf("SCANNER_BAN_WINDOW and SCANNER_BAN_DURATION must be positive when SCANNER_BAN_THRESHOLD is set, got %s and %s", c.ScannerBanWindow, c.ScannerBanDuration)
+	}
+	// Bans key on the detected client IP, which anyone can forge while every peer's headers are trusted
+	if c.ScannerBanThreshold > 0 && len(c.TrustedProxies) == 0 && len(c.ProxyProfiles) == 0 {
+		return fmt.Errorf("SCANNER_BAN_THRESHOLD requires TRUSTED_PROXIES or PROXY_PROFILES, otherwise a forged X-Forwarded-For could get any address banned")
+	}
+	if c.CacheBackend == "redis" && c.RedisURL == "" {
+		return fmt.Errorf("REDIS_URL must be set when CACHE_BACKEND is redis")
+	}
+	if c.EdgeCache == "vary" && c.EdgeCacheMaxAge < time.Second {
+		return fmt.Errorf("EDGE_CACHE_MAX_AGE must be at least 1s when EDGE_CACHE is vary, got %s", c.EdgeCacheMaxAge)
+	}
+	if c.ACMEChallenges && c.AdminToken == "" {
+		return fmt.Errorf("ADMIN_TOKEN must be set when ACME_CHALLENGES is enabled")
+	}
+	if c.PprofEnabled && c.AdminToken == "" {
+		return fmt.Errorf("ADMIN_TOKEN must be set when PPROF_ENABLED is enabled")
+	}
+	if c.RequestCaptureSize > 0 && c.AdminToken == "" {
+		return fmt.Errorf("ADMIN_TOKEN must be set when REQUEST_CAPTURE_SIZE is set")
+	}
+	if c.ClusterEnabled && c.CacheBackend != "redis" {
+		return fmt.Errorf("CACHE_BACKEND must be redis when CLUSTER_ENABLED is enabled; the nodes share state through Redis")
+	}
+	if c.ClusterEnabled && c.AdminToken == "" {
+		return fmt.Errorf("ADMIN_TOKEN must be set when CLUSTER_ENABLED is enabled")
+	}
+	if c.ClusterEnabled && c.ClusterHeartbeat < time.Second {
+		return fmt.Errorf("CLUSTER_HEARTBEAT must be at least 1s, got %s", c.ClusterHeartbeat)
+	}
+	if err := checkBaseURL("CLUSTER_ADVERTISE_URL", c.ClusterAdvertiseURL); err != nil {
+		return err
+	}
+	if (c.MaxMindAccountID == "") != (c.MaxMindLicenseKey == "") {
+		return fmt.Errorf("MAXMIND_ACCOUNT_ID and MAXMIND_LICENSE_KEY must be set together")
+	}
+	if c.MaxMindLicenseKey != "" && c.GeoIPUpdateInterval < time.Hour {
+		return fmt.Errorf("GEOIP_UPDATE_INTERVAL must be at least 1h, got %s", c.GeoIPUpdateInterval)
+	}
+	if err := checkBaseURL("GEOIP_DOWNLOAD_URL", c.GeoIPDownloadURL); err != nil {
+		return err
+	}
+	if c.BreakerThreshold < 0 {
+		return fmt.Errorf("BREAKER_THRESHOLD must not be negative, got %d", c.BreakerThreshold)
+	}
+	if c.BreakerThreshold > 0 && c.BreakerBackoff < time.Second {
+		return fmt.Errorf("BREAKER_BACKOFF must be at least 1s, got %s", c.BreakerBackoff)
+	}
+	if c.BreakerThreshold > 0 && c.BreakerMaxBackoff < c.BreakerBackoff {
+		return fmt.Errorf("BREAKER_MAX_BACKOFF must be at least BREAKER_BACKOFF (%s), got %s", c.BreakerBackoff, c.BreakerMaxBackoff)
+	}
+	if c.UnixSocket != "" && c.ListenSockets > 1 {
+		return fmt.Errorf("LISTEN_SOCKETS must be 1 when UNIX_SOCKET is set, got %d", c.ListenSockets)
+	}
+	return nil
+}
+
+// checkPort reports an error unless port, the value of key, is a TCP or UDP port number
+func checkPort(key, port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%s must be a port between 1 and 65535, got %q", key, port)
+	}
+	return nil
+}
 
-	host := os.Getenv("HOST")
-	if host == "" {
-		host = "localhost:8080"
+// checkBaseURL reports an error when raw, the value of key, is set but is not an http or https URL
+// with a host and without a query
+func checkBaseURL(key, raw string) error {
+	if raw == "" {
+		return nil
 	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("%s must be an http or https URL such as https://ipv4.example.com, got %q", key, raw)
+	}
+	return nil
+}
+
+// checkPath reports an error when path, the value of key, is set but cannot be opened, or is not
+// a directory when dir is set or a regular file otherwise
+func checkPath(key, path string, dir bool) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%s cannot be read: %v", key, err)
+	}
+	defer f.Close()
 
-	return &Config{
-		Port: port,
-		Host: host,
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("%s cannot be read: %v", key, err)
+	}
+	switch {
+	case dir && !info.IsDir():
+		return fmt.Errorf("%s must be a directory, %s is a file", key, path)
+	case !dir && info.IsDir():
+		return fmt.Errorf("%s must be a file, %s is a directory", key, path)
 	}
+	return nil
+}
+
+// TLSEnabled reports whether both a TLS certificate and key are configured
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
 }
 
 // GetAddr returns the server address string
 func (c *Config) GetAddr() string {
 	return ":" + c.Port
 }
+
+// Hash returns a short, stable fingerprint of the effective configuration.
+// Secrets are excluded so the hash can be published safely.
+func (c *Config) Hash() string {
+	redacted := *c
+	redacted.AdminToken = ""
+	redacted.APIKeys = nil
+	redacted.MaxMindLicenseKey = ""
+	redacted.RedisURL = redactURL(c.RedisURL)
+
+	data, err := json.Marshal(redacted)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// redactURL removes the password from a URL such as REDIS_URL
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Redacted()
+}
+
+// source resolves configuration keys from the environment, falling back to config file values
+type source struct {
+	file map[string]string
+
+	// settings records the effective value and origin of every key read, when not nil
+	settings map[string]models.ConfigSetting
+
+	// invalid collects the values that failed to parse, when not nil
+	invalid *[]error
+}
+
+// lookup returns the environment value for key, or the config file value when the variable is unset or empty
+func (s source) lookup(key string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return s.file[key]
+}
+
+// record notes the effective value of key, which came from the environment or config file when
+// set and from the default otherwise
+func (s source) record(key, value string, set bool) {
+	if s.settings == nil {
+		return
+	}
+	origin := SourceDefault
+	if set {
+		origin = SourceFile
+		if os.Getenv(key) != "" {
+			origin = SourceEnv
+		}
+	}
+	s.settings[key] = models.ConfigSetting{Key: key, Value: value, Source: origin}
+}
+
+// reject notes that the value set for key is not a valid want
+func (s source) reject(key, want string) {
+	if s.invalid == nil {
+		return
+	}
+	*s.invalid = append(*s.invalid, fmt.Errorf("%s must be %s, got %q", key, want, s.lookup(key)))
+}
+
+// get returns the value for key or the fallback when unset or empty
+func (s source) get(key, fallback string) string {
+	if value := s.lookup(key); value != "" {
+		s.record(key, value, true)
+		return value
+	}
+	s.record(key, fallback, false)
+	return fallback
+}
+
+// getChoice returns the lowercased value for key when it is one of choices, otherwise the fallback
+func (s source) getChoice(key, fallback string, choices ...string) string {
+	value := strings.ToLower(s.lookup(key))
+	for _, choice := range choices {
+		if value == choice {
+			s.record(key, value, true)
+			return value
+		}
+	}
+	if value != "" {
+		s.reject(key, "one of "+strings.Join(choices, ", "))
+	}
+	s.record(key, fallback, false)
+	return fallback
+}
+
+// getBool parses a boolean value, returning the fallback when unset or invalid
+func (s source) getBool(key string, fallback bool) bool {
+	raw := s.lookup(key)
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		if raw != "" {
+			s.reject(key, "true or false")
+		}
+		value = fallback
+	}
+	s.record(key, strconv.FormatBool(value), err == nil)
+	return value
+}
+
+// getDuration parses a duration value (e.g. "30s", "5m"), returning the fallback when unset or invalid
+func (s source) getDuration(key string, fallback time.Duration) time.Duration {
+	raw := s.lookup(key)
+	value, err := time.ParseDuration(raw)
+	set := err == nil && value >= 0
+	if !set {
+		if raw != "" {
+			s.reject(key, "a non-negative duration such as 30s or 5m")
+		}
+		value = fallback
+	}
+	s.record(key, value.String(), set)
+	return value
+}
+
+// getInt parses an integer value, returning the fallback when unset or invalid
+func (s source) getInt(key string, fallback int) int {
+	raw := s.lookup(key)
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		if raw != "" {
+			s.reject(key, "an integer")
+		}
+		value = fallback
+	}
+	s.record(key, strconv.Itoa(value), err == nil)
+	return value
+}
+
+// getFileMode parses an octal permission value such as "0660", returning the fallback when unset or invalid
+func (s source) getFileMode(key string, fallback os.FileMode) os.FileMode {
+	raw := s.lookup(key)
+	value, err := strconv.ParseUint(raw, 8, 32)
+	set := err == nil && value <= 0o777
+	if !set {
+		if raw != "" {
+			s.reject(key, "an octal file mode such as 0660")
+		}
+		value = uint64(fallback)
+	}
+	s.record(key, fmt.Sprintf("%04o", value), set)
+	return os.FileMode(value)
+}
+
+// getFloat parses a float value, returning the fallback when unset or invalid
+func (s source) getFloat(key string, fallback float64) float64 {
+	raw := s.lookup(key)
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		if raw != "" {
+			s.reject(key, "a number")
+		}
+		value = fallback
+	}
+	s.record(key, strconv.FormatFloat(value, 'g', -1, 64), err == nil)
+	return value
+}
+
+// getList parses a comma-separated value, skipping empty entries
+func (s source) getList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(s.lookup(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	s.record(key, strings.Join(list, ","), len(list) > 0)
+	return list
+}
@@ -2,7 +2,12 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadDefaultPort(t *testing.T) {
@@ -116,3 +121,908 @@ func TestLoadEmptyHostFallback(t *testing.T) {
 		t.Errorf("Expected default host localhost:8080 when HOST is empty, got %s", cfg.Host)
 	}
 }
+
+func TestLoadMaintenanceDefaults(t *testing.T) {
+	os.Unsetenv("MAINTENANCE_MODE")
+	os.Unsetenv("MAINTENANCE_MESSAGE")
+	os.Unsetenv("MAINTENANCE_RETRY_AFTER")
+
+	cfg := Load()
+
+	if cfg.MaintenanceMode {
+		t.Error("Expected maintenance mode to be disabled by default")
+	}
+
+	if cfg.MaintenanceMessage != DefaultMaintenanceMessage {
+		t.Errorf("Expected default maintenance message, got %s", cfg.MaintenanceMessage)
+	}
+
+	if cfg.MaintenanceRetryAfter != 5*time.Minute {
+		t.Errorf("Expected default retry after 5m, got %v", cfg.MaintenanceRetryAfter)
+	}
+}
+
+func TestLoadMaintenanceCustom(t *testing.T) {
+	os.Setenv("MAINTENANCE_MODE", "true")
+	os.Setenv("MAINTENANCE_MESSAGE", "Migrating")
+	os.Setenv("MAINTENANCE_RETRY_AFTER", "30s")
+	os.Setenv("ADMIN_TOKEN", "secret")
+	defer os.Unsetenv("MAINTENANCE_MODE")
+	defer os.Unsetenv("MAINTENANCE_MESSAGE")
+	defer os.Unsetenv("MAINTENANCE_RETRY_AFTER")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	cfg := Load()
+
+	if !cfg.MaintenanceMode {
+		t.Error("Expected maintenance mode to be enabled")
+	}
+
+	if cfg.MaintenanceMessage != "Migrating" {
+		t.Errorf("Expected custom maintenance message, got %s", cfg.MaintenanceMessage)
+	}
+
+	if cfg.MaintenanceRetryAfter != 30*time.Second {
+		t.Errorf("Expected retry after 30s, got %v", cfg.MaintenanceRetryAfter)
+	}
+
+	if cfg.AdminToken != "secret" {
+		t.Errorf("Expected admin token secret, got %s", cfg.AdminToken)
+	}
+}
+
+func TestLoadMaintenanceInvalidValues(t *testing.T) {
+	os.Setenv("MAINTENANCE_MODE", "sometimes")
+	os.Setenv("MAINTENANCE_RETRY_AFTER", "soon")
+	defer os.Unsetenv("MAINTENANCE_MODE")
+	defer os.Unsetenv("MAINTENANCE_RETRY_AFTER")
+
+	cfg := Load()
+
+	if cfg.MaintenanceMode {
+		t.Error("Expected invalid MAINTENANCE_MODE to fall back to false")
+	}
+
+	if cfg.MaintenanceRetryAfter != 5*time.Minute {
+		t.Errorf("Expected invalid retry after to fall back to 5m, got %v", cfg.MaintenanceRetryAfter)
+	}
+}
+
+func TestLoadDNSSettings(t *testing.T) {
+	os.Setenv("DNS_ALLOWLIST", "example.com, ,example.org")
+	os.Setenv("DNS_RATE_LIMIT", "5")
+	os.Setenv("DNS_RATE_LIMIT_ALGORITHM", "Bucket")
+	os.Setenv("DNS_TIMEOUT", "1s")
+	defer os.Unsetenv("DNS_ALLOWLIST")
+	defer os.Unsetenv("DNS_RATE_LIMIT")
+	defer os.Unsetenv("DNS_RATE_LIMIT_ALGORITHM")
+	defer os.Unsetenv("DNS_TIMEOUT")
+
+	cfg := Load()
+
+	if len(cfg.DNSAllowlist) != 2 || cfg.DNSAllowlist[0] != "example.com" || cfg.DNSAllowlist[1] != "example.org" {
+		t.Errorf("Expected allowlist [example.com example.org], got %v", cfg.DNSAllowlist)
+	}
+
+	if cfg.DNSRateLimit != 5 {
+		t.Errorf("Expected DNS rate limit 5, got %d", cfg.DNSRateLimit)
+	}
+
+	if cfg.DNSRateLimitAlgorithm != "bucket" {
+		t.Errorf("Expected DNS rate limit algorithm bucket, got %q", cfg.DNSRateLimitAlgorithm)
+	}
+
+	if cfg.DNSTimeout != time.Second {
+		t.Errorf("Expected DNS timeout 1s, got %v", cfg.DNSTimeout)
+	}
+}
+
+func TestLoadDNSDefaults(t *testing.T) {
+	os.Unsetenv("DNS_ALLOWLIST")
+	os.Setenv("DNS_RATE_LIMIT", "lots")
+	defer os.Unsetenv("DNS_RATE_LIMIT")
+
+	cfg := Load()
+
+	if len(cfg.DNSAllowlist) != 0 {
+		t.Errorf("Expected empty allowlist, got %v", cfg.DNSAllowlist)
+	}
+
+	if cfg.DNSRateLimit != 30 {
+		t.Errorf("Expected default DNS rate limit 30, got %d", cfg.DNSRateLimit)
+	}
+
+	if cfg.DNSRateLimitAlgorithm != "window" {
+		t.Errorf("Expected default DNS rate limit algorithm window, got %q", cfg.DNSRateLimitAlgorithm)
+	}
+
+	if cfg.DNSTimeout != 3*time.Second {
+		t.Errorf("Expected default DNS timeout 3s, got %v", cfg.DNSTimeout)
+	}
+}
+
+func TestLoadDelaySettings(t *testing.T) {
+	os.Unsetenv("DELAY_ENABLED")
+	os.Unsetenv("DELAY_MAX")
+
+	cfg := Load()
+
+	if cfg.DelayEnabled {
+		t.Error("Expected delay shaping to be disabled by default")
+	}
+
+	if cfg.DelayMax != 5*time.Second {
+		t.Errorf("Expected default delay cap 5s, got %v", cfg.DelayMax)
+	}
+
+	os.Setenv("DELAY_ENABLED", "true")
+	os.Setenv("DELAY_MAX", "2s")
+	defer os.Unsetenv("DELAY_ENABLED")
+	defer os.Unsetenv("DELAY_MAX")
+
+	cfg = Load()
+
+	if !cfg.DelayEnabled {
+		t.Error("Expected delay shaping to be enabled")
+	}
+
+	if cfg.DelayMax != 2*time.Second {
+		t.Errorf("Expected delay cap 2s, got %v", cfg.DelayMax)
+	}
+}
+
+func TestHash(t *testing.T) {
+	a := &Config{Port: "8080", Host: "localhost:8080"}
+	b := &Config{Port: "8080", Host: "localhost:8080"}
+
+	if a.Hash() == "" {
+		t.Fatal("Expected non-empty config hash")
+	}
+
+	if a.Hash() != b.Hash() {
+		t.Error("Expected identical configs to have the same hash")
+	}
+
+	b.Port = "3000"
+	if a.Hash() == b.Hash() {
+		t.Error("Expected different configs to have different hashes")
+	}
+
+	// Secrets do not affect the hash
+	c := &Config{Port: "8080", Host: "localhost:8080", AdminToken: "secret"}
+	if a.Hash() != c.Hash() {
+		t.Error("Expected admin token to be excluded from the hash")
+	}
+
+	d := &Config{Port: "8080", Host: "localhost:8080", RedisURL: "redis://:one@cache:6379"}
+	e := &Config{Port: "8080", Host: "localhost:8080", RedisURL: "redis://:two@cache:6379"}
+	if d.Hash() != e.Hash() {
+		t.Error("Expected the Redis password to be excluded from the hash")
+	}
+
+	f := &Config{Port: "8080", Host: "localhost:8080", MaxMindLicenseKey: "key"}
+	if a.Hash() != f.Hash() {
+		t.Error("Expected the MaxMind license key to be excluded from the hash")
+	}
+}
+
+func TestLoadSLOSettings(t *testing.T) {
+	os.Unsetenv("SLO_AVAILABILITY_TARGET")
+	os.Unsetenv("SLO_LATENCY_TARGET")
+
+	cfg := Load()
+
+	if cfg.SLOAvailabilityTarget != 0.999 {
+		t.Errorf("Expected default availability target 0.999, got %v", cfg.SLOAvailabilityTarget)
+	}
+
+	if cfg.SLOLatencyTarget != 250*time.Millisecond {
+		t.Errorf("Expected default latency target 250ms, got %v", cfg.SLOLatencyTarget)
+	}
+
+	os.Setenv("SLO_AVAILABILITY_TARGET", "0.99")
+	os.Setenv("SLO_LATENCY_TARGET", "50ms")
+	defer os.Unsetenv("SLO_AVAILABILITY_TARGET")
+	defer os.Unsetenv("SLO_LATENCY_TARGET")
+
+	cfg = Load()
+
+	if cfg.SLOAvailabilityTarget != 0.99 {
+		t.Errorf("Expected availability target 0.99, got %v", cfg.SLOAvailabilityTarget)
+	}
+
+	if cfg.SLOLatencyTarget != 50*time.Millisecond {
+		t.Errorf("Expected latency target 50ms, got %v", cfg.SLOLatencyTarget)
+	}
+}
+
+func TestLoadReloadableDefaults(t *testing.T) {
+	os.Unsetenv("LOG_LEVEL")
+	os.Unsetenv("LOG_DEBUG_MODULES")
+	os.Unsetenv("TRUSTED_PROXIES")
+	os.Unsetenv("HEADER_PRIORITY")
+	os.Unsetenv("CONFIG_FILE")
+
+	cfg := Load()
+
+	if cfg.LogLevel != "info" {
+		t.Errorf("Expected default log level info, got %s", cfg.LogLevel)
+	}
+
+	if len(cfg.LogDebugModules) != 0 {
+		t.Errorf("Expected no debug modules, got %v", cfg.LogDebugModules)
+	}
+
+	if len(cfg.TrustedProxies) != 0 || len(cfg.HeaderPriority) != 0 {
+		t.Errorf("Expected no trusted proxies or header priority override, got %v and %v",
+			cfg.TrustedProxies, cfg.HeaderPriority)
+	}
+
+	if cfg.ConfigWatchInterval != 5*time.Second {
+		t.Errorf("Expected default watch interval 5s, got %v", cfg.ConfigWatchInterval)
+	}
+}
+
+func TestTLSEnabled(t *testing.T) {
+	tests := []struct {
+		cert, key string
+		expected  bool
+	}{
+		{"", "", false},
+		{"tls.crt", "", false},
+		{"", "tls.key", false},
+		{"tls.crt", "tls.key", true},
+	}
+
+	for _, test := range tests {
+		cfg := &Config{TLSCertFile: test.cert, TLSKeyFile: test.key}
+		if cfg.TLSEnabled() != test.expected {
+			t.Errorf("TLSEnabled() with cert %q and key %q = %t, expected %t",
+				test.cert, test.key, cfg.TLSEnabled(), test.expected)
+		}
+	}
+}
+
+func TestLoadPathNormalization(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"", "rewrite"},
+		{"redirect", "redirect"},
+		{"OFF", "off"},
+		{"sideways", "rewrite"},
+	}
+
+	for _, test := range tests {
+		os.Setenv("PATH_NORMALIZATION", test.value)
+		cfg := Load()
+		if cfg.PathNormalization != test.expected {
+			t.Errorf("PATH_NORMALIZATION=%q: expected %s, got %s", test.value, test.expected, cfg.PathNormalization)
+		}
+	}
+	os.Unsetenv("PATH_NORMALIZATION")
+}
+
+func TestLoadMaxBodyBytes(t *testing.T) {
+	os.Unsetenv("MAX_BODY_BYTES")
+
+	if cfg := Load(); cfg.MaxBodyBytes != 64<<10 {
+		t.Errorf("Expected default body limit 65536, got %d", cfg.MaxBodyBytes)
+	}
+
+	os.Setenv("MAX_BODY_BYTES", "1024")
+	defer os.Unsetenv("MAX_BODY_BYTES")
+
+	if cfg := Load(); cfg.MaxBodyBytes != 1024 {
+		t.Errorf("Expected body limit 1024, got %d", cfg.MaxBodyBytes)
+	}
+}
+
+func TestLoadEnrichSettings(t *testing.T) {
+	os.Unsetenv("ENRICH_POLICIES")
+	os.Unsetenv("ENRICH_TIMEOUT")
+	os.Unsetenv("ENRICH_STALE_TTL")
+
+	cfg := Load()
+
+	if len(cfg.EnrichPolicies) != 0 || cfg.EnrichTimeout != 2*time.Second || cfg.EnrichStaleTTL != time.Hour {
+		t.Errorf("Unexpected enrichment defaults: %v %v %v", cfg.EnrichPolicies, cfg.EnrichTimeout, cfg.EnrichStaleTTL)
+	}
+
+	os.Setenv("ENRICH_POLICIES", "geo=stale,rdap=fail")
+	defer os.Unsetenv("ENRICH_POLICIES")
+
+	cfg = Load()
+
+	if len(cfg.EnrichPolicies) != 2 || cfg.EnrichPolicies[1] != "rdap=fail" {
+		t.Errorf("Expected two enrichment policies, got %v", cfg.EnrichPolicies)
+	}
+}
+
+func TestLoadRDAPSettings(t *testing.T) {
+	os.Unsetenv("RDAP_URL")
+	os.Unsetenv("RDAP_TIMEOUT")
+	os.Unsetenv("RDAP_CACHE_TTL")
+	os.Setenv("RDAP_RATE_LIMIT", "10")
+	defer os.Unsetenv("RDAP_RATE_LIMIT")
+
+	cfg := Load()
+
+	if cfg.RDAPURL != "https://rdap.org/ip/" {
+		t.Errorf("Expected default RDAP URL, got %s", cfg.RDAPURL)
+	}
+	if cfg.RDAPTimeout != 5*time.Second || cfg.RDAPCacheTTL != 24*time.Hour {
+		t.Errorf("Unexpected RDAP timeout %v or cache TTL %v", cfg.RDAPTimeout, cfg.RDAPCacheTTL)
+	}
+	if cfg.RDAPRateLimit != 10 {
+		t.Errorf("Expected RDAP rate limit 10, got %d", cfg.RDAPRateLimit)
+	}
+}
+
+func TestLoadOutboundIPPreference(t *testing.T) {
+	os.Unsetenv("OUTBOUND_IP_PREFERENCE")
+
+	if cfg := Load(); cfg.OutboundIPPreference != "auto" {
+		t.Errorf("Expected default preference auto, got %s", cfg.OutboundIPPreference)
+	}
+
+	os.Setenv("OUTBOUND_IP_PREFERENCE", "IPv6")
+	defer os.Unsetenv("OUTBOUND_IP_PREFERENCE")
+
+	if cfg := Load(); cfg.OutboundIPPreference != "ipv6" {
+		t.Errorf("Expected preference ipv6, got %s", cfg.OutboundIPPreference)
+	}
+}
+
+func TestLoadStrictValidation(t *testing.T) {
+	os.Unsetenv("STRICT_VALIDATION")
+
+	if cfg := Load(); cfg.StrictValidation != "off" {
+		t.Errorf("Expected strict validation off by default, got %s", cfg.StrictValidation)
+	}
+
+	os.Setenv("STRICT_VALIDATION", "Reject")
+	defer os.Unsetenv("STRICT_VALIDATION")
+
+	if cfg := Load(); cfg.StrictValidation != "reject" {
+		t.Errorf("Expected strict validation reject, got %s", cfg.StrictValidation)
+	}
+}
+
+func TestLoadClientIPResponseHeader(t *testing.T) {
+	os.Unsetenv("CLIENT_IP_RESPONSE_HEADER")
+
+	if cfg := Load(); cfg.ClientIPResponseHeader != "X-Client-IP" {
+		t.Errorf("Expected X-Client-IP by default, got %s", cfg.ClientIPResponseHeader)
+	}
+
+	os.Setenv("CLIENT_IP_RESPONSE_HEADER", "off")
+	defer os.Unsetenv("CLIENT_IP_RESPONSE_HEADER")
+
+	if cfg := Load(); cfg.ClientIPResponseHeader != "off" {
+		t.Errorf("Expected off, got %s", cfg.ClientIPResponseHeader)
+	}
+}
+
+func TestLoadEdgeCache(t *testing.T) {
+	os.Unsetenv("EDGE_CACHE")
+	os.Unsetenv("EDGE_CACHE_MAX_AGE")
+
+	if cfg := Load(); cfg.EdgeCache != "off" || cfg.EdgeCacheMaxAge != time.Minute {
+		t.Errorf("Expected edge caching off with a 1m max age by default, got %s %v", cfg.EdgeCache, cfg.EdgeCacheMaxAge)
+	}
+
+	os.Setenv("EDGE_CACHE", "vary")
+	os.Setenv("EDGE_CACHE_MAX_AGE", "5m")
+	defer os.Unsetenv("EDGE_CACHE")
+	defer os.Unsetenv("EDGE_CACHE_MAX_AGE")
+
+	if cfg := Load(); cfg.EdgeCache != "vary" || cfg.EdgeCacheMaxAge != 5*time.Minute {
+		t.Errorf("Expected vary with a 5m max age, got %s %v", cfg.EdgeCache, cfg.EdgeCacheMaxAge)
+	}
+}
+
+func TestLoadXFFStrategy(t *testing.T) {
+	os.Unsetenv("XFF_STRATEGY")
+
+	if cfg := Load(); cfg.XFFStrategy != "leftmost" {
+		t.Errorf("Expected the leftmost strategy by default, got %s", cfg.XFFStrategy)
+	}
+
+	os.Setenv("XFF_STRATEGY", "Rightmost-Untrusted")
+	defer os.Unsetenv("XFF_STRATEGY")
+
+	if cfg := Load(); cfg.XFFStrategy != "rightmost-untrusted" {
+		t.Errorf("Expected the rightmost-untrusted strategy, got %s", cfg.XFFStrategy)
+	}
+}
+
+func TestLoadProxyProfiles(t *testing.T) {
+	os.Setenv("PROXY_PROFILES", "cloudflare,aws-alb")
+	defer os.Unsetenv("PROXY_PROFILES")
+	os.Unsetenv("HEADER_PRIORITY")
+	os.Unsetenv("XFF_STRATEGY")
+
+	cfg := Load()
+	if len(cfg.ProxyProfiles) != 2 || cfg.ProxyRangesRefresh != 24*time.Hour {
+		t.Errorf("Expected two profiles refreshed daily, got %v every %s", cfg.ProxyProfiles, cfg.ProxyRangesRefresh)
+	}
+	if len(cfg.HeaderPriority) != 2 || cfg.HeaderPriority[0] != "CF-Connecting-IP" || cfg.HeaderPriority[1] != "X-Forwarded-For" {
+		t.Errorf("Expected the profiles' header priority, got %v", cfg.HeaderPriority)
+	}
+	if cfg.XFFStrategy != "rightmost" {
+		t.Errorf("Expected the profiles' strategy, got %s", cfg.XFFStrategy)
+	}
+
+	// Explicit settings win over the profiles
+	os.Setenv("HEADER_PRIORITY", "X-Real-IP")
+	defer os.Unsetenv("HEADER_PRIORITY")
+	os.Setenv("XFF_STRATEGY", "leftmost")
+	defer os.Unsetenv("XFF_STRATEGY")
+
+	cfg = Load()
+	if len(cfg.HeaderPriority) != 1 || cfg.HeaderPriority[0] != "X-Real-IP" || cfg.XFFStrategy != "leftmost" {
+		t.Errorf("Expected explicit settings, got %v and %s", cfg.HeaderPriority, cfg.XFFStrategy)
+	}
+}
+
+func TestLoadShadowDetection(t *testing.T) {
+	os.Unsetenv("SHADOW_HEADER_PRIORITY")
+	os.Unsetenv("SHADOW_XFF_STRATEGY")
+	if cfg := Load(); cfg.ShadowHeaderPriority != nil || cfg.ShadowXFFStrategy != "" {
+		t.Errorf("Expected shadow detection off by default, got %v and %q", cfg.ShadowHeaderPriority, cfg.ShadowXFFStrategy)
+	}
+
+	os.Setenv("SHADOW_HEADER_PRIORITY", "X-Real-IP, X-Forwarded-For")
+	defer os.Unsetenv("SHADOW_HEADER_PRIORITY")
+	os.Setenv("SHADOW_XFF_STRATEGY", "Rightmost")
+	defer os.Unsetenv("SHADOW_XFF_STRATEGY")
+
+	cfg := Load()
+	if len(cfg.ShadowHeaderPriority) != 2 || cfg.ShadowHeaderPriority[0] != "X-Real-IP" || cfg.ShadowXFFStrategy != "rightmost" {
+		t.Errorf("Expected the shadow settings, got %v and %q", cfg.ShadowHeaderPriority, cfg.ShadowXFFStrategy)
+	}
+}
+
+func TestLoadDefaultLocale(t *testing.T) {
+	os.Unsetenv("DEFAULT_LOCALE")
+	if cfg := Load(); cfg.DefaultLocale != "en" {
+		t.Errorf("Expected the en locale by default, got %s", cfg.DefaultLocale)
+	}
+
+	os.Setenv("DEFAULT_LOCALE", "ID")
+	defer os.Unsetenv("DEFAULT_LOCALE")
+	if cfg := Load(); cfg.DefaultLocale != "id" {
+		t.Errorf("Expected the id locale, got %s", cfg.DefaultLocale)
+	}
+}
+
+func TestLoadPrivacyMode(t *testing.T) {
+	os.Unsetenv("PRIVACY_MODE")
+	os.Unsetenv("PRIVACY_OMIT_USER_AGENT")
+
+	if cfg := Load(); cfg.PrivacyMode || cfg.PrivacyOmitUserAgent {
+		t.Error("Expected privacy mode off by default")
+	}
+
+	os.Setenv("PRIVACY_MODE", "true")
+	os.Setenv("PRIVACY_OMIT_USER_AGENT", "true")
+	defer os.Unsetenv("PRIVACY_MODE")
+	defer os.Unsetenv("PRIVACY_OMIT_USER_AGENT")
+
+	if cfg := Load(); !cfg.PrivacyMode || !cfg.PrivacyOmitUserAgent {
+		t.Error("Expected privacy mode and User-Agent omission enabled")
+	}
+}
+
+func TestLoadScannerBans(t *testing.T) {
+	os.Unsetenv("SCANNER_BAN_THRESHOLD")
+
+	cfg := Load()
+	if cfg.ScannerBanThreshold != 0 || cfg.ScannerBanWindow != 10*time.Minute || cfg.ScannerBanDuration != time.Hour {
+		t.Errorf("Unexpected scanner ban defaults %d, %s, %s", cfg.ScannerBanThreshold, cfg.ScannerBanWindow, cfg.ScannerBanDuration)
+	}
+
+	os.Setenv("SCANNER_BAN_THRESHOLD", "5")
+	defer os.Unsetenv("SCANNER_BAN_THRESHOLD")
+
+	if cfg := Load(); cfg.ScannerBanThreshold != 5 {
+		t.Errorf("Expected a threshold of 5, got %d", cfg.ScannerBanThreshold)
+	}
+}
+
+func TestLoadRequestTimeouts(t *testing.T) {
+	os.Unsetenv("REQUEST_TIMEOUT")
+	os.Unsetenv("REQUEST_TIMEOUTS")
+
+	if cfg := Load(); cfg.RequestTimeout != 10*time.Second || cfg.RequestTimeouts != nil {
+		t.Errorf("Unexpected request timeout defaults %s, %q", cfg.RequestTimeout, cfg.RequestTimeouts)
+	}
+
+	os.Setenv("REQUEST_TIMEOUT", "4s")
+	os.Setenv("REQUEST_TIMEOUTS", "/whois=8s,/hostname=2s")
+	defer os.Unsetenv("REQUEST_TIMEOUT")
+	defer os.Unsetenv("REQUEST_TIMEOUTS")
+
+	cfg := Load()
+	if cfg.RequestTimeout != 4*time.Second || !slices.Equal(cfg.RequestTimeouts, []string{"/whois=8s", "/hostname=2s"}) {
+		t.Errorf("Unexpected request timeouts %s, %q", cfg.RequestTimeout, cfg.RequestTimeouts)
+	}
+}
+
+func TestLoadListeners(t *testing.T) {
+	os.Unsetenv("ROUTES")
+	os.Unsetenv("LISTENERS")
+
+	if cfg := Load(); cfg.Routes != "all" || cfg.Listeners != nil {
+		t.Errorf("Expected all routes on a single listener by default, got %q and %q", cfg.Routes, cfg.Listeners)
+	}
+
+	os.Setenv("ROUTES", "ip+docs")
+	os.Setenv("LISTENERS", "127.0.0.1:9090=health+metrics+admin")
+	defer os.Unsetenv("ROUTES")
+	defer os.Unsetenv("LISTENERS")
+
+	cfg := Load()
+	if cfg.Routes != "ip+docs" || !slices.Equal(cfg.Listeners, []string{"127.0.0.1:9090=health+metrics+admin"}) {
+		t.Errorf("Unexpected listeners %q and %q", cfg.Routes, cfg.Listeners)
+	}
+}
+
+func TestLoadEndpoints(t *testing.T) {
+	os.Unsetenv("ENABLED_ENDPOINTS")
+	os.Unsetenv("DISABLED_ENDPOINTS")
+
+	if cfg := Load(); cfg.EnabledEndpoints != nil || cfg.DisabledEndpoints != nil {
+		t.Errorf("Expected every endpoint enabled by default, got %q and %q", cfg.EnabledEndpoints, cfg.DisabledEndpoints)
+	}
+
+	os.Setenv("DISABLED_ENDPOINTS", "/headers, /echo,,/swagger")
+	defer os.Unsetenv("DISABLED_ENDPOINTS")
+
+	if cfg := Load(); !slices.Equal(cfg.DisabledEndpoints, []string{"/headers", "/echo", "/swagger"}) {
+		t.Errorf("Unexpected disabled endpoints %q", cfg.DisabledEndpoints)
+	}
+}
+
+func TestLoadProxyProtocol(t *testing.T) {
+	os.Unsetenv("PROXY_PROTOCOL")
+
+	if cfg := Load(); cfg.ProxyProtocol != "off" {
+		t.Errorf("Expected PROXY protocol off by default, got %s", cfg.ProxyProtocol)
+	}
+
+	os.Setenv("PROXY_PROTOCOL", "Required")
+	defer os.Unsetenv("PROXY_PROTOCOL")
+
+	if cfg := Load(); cfg.ProxyProtocol != "required" {
+		t.Errorf("Expected PROXY protocol required, got %s", cfg.ProxyProtocol)
+	}
+}
+
+func TestLoadThreatFeedSettings(t *testing.T) {
+	os.Unsetenv("THREAT_FEED_REFRESH")
+	os.Unsetenv("THREAT_FEED_TIMEOUT")
+	os.Setenv("THREAT_FEEDS", "drop=https://www.spamhaus.org/drop/drop.txt, local=/etc/denylist.txt")
+	defer os.Unsetenv("THREAT_FEEDS")
+
+	cfg := Load()
+
+	if len(cfg.ThreatFeeds) != 2 || cfg.ThreatFeeds[1] != "local=/etc/denylist.txt" {
+		t.Errorf("Expected two threat feeds, got %v", cfg.ThreatFeeds)
+	}
+	if cfg.ThreatFeedRefresh != time.Hour || cfg.ThreatFeedTimeout != 30*time.Second {
+		t.Errorf("Unexpected threat feed refresh %v or timeout %v", cfg.ThreatFeedRefresh, cfg.ThreatFeedTimeout)
+	}
+}
+
+func TestLoadIPTypeSettings(t *testing.T) {
+	os.Setenv("IP_ASN_DB", "/var/lib/myip/ip2asn-combined.tsv.gz")
+	defer os.Unsetenv("IP_ASN_DB")
+	os.Unsetenv("IP_TYPE_PREFIXES")
+
+	cfg := Load()
+
+	if cfg.IPASNDB != "/var/lib/myip/ip2asn-combined.tsv.gz" || cfg.IPTypePrefixes != "" {
+		t.Errorf("Unexpected IP type settings %q %q", cfg.IPASNDB, cfg.IPTypePrefixes)
+	}
+}
+
+func TestLoadPlainTextSettings(t *testing.T) {
+	os.Unsetenv("PLAIN_TEXT_CHARSET")
+	os.Setenv("PLAIN_TEXT_NEWLINE", "true")
+	defer os.Unsetenv("PLAIN_TEXT_NEWLINE")
+
+	cfg := Load()
+
+	if !cfg.PlainTextNewline || cfg.PlainTextCharset {
+		t.Errorf("Expected newline without charset, got newline=%t charset=%t", cfg.PlainTextNewline, cfg.PlainTextCharset)
+	}
+}
+
+func TestLoadTemplateSettings(t *testing.T) {
+	os.Unsetenv("TEMPLATE_DIR")
+	os.Unsetenv("TEMPLATE_INLINE")
+	if cfg := Load(); cfg.TemplateDir != "" || !cfg.TemplateInline {
+		t.Errorf("Expected inline templates without a template dir by default, got dir=%q inline=%t", cfg.TemplateDir, cfg.TemplateInline)
+	}
+
+	os.Setenv("TEMPLATE_DIR", "/etc/myip/templates")
+	os.Setenv("TEMPLATE_INLINE", "false")
+	defer os.Unsetenv("TEMPLATE_DIR")
+	defer os.Unsetenv("TEMPLATE_INLINE")
+
+	if cfg := Load(); cfg.TemplateDir != "/etc/myip/templates" || cfg.TemplateInline {
+		t.Errorf("Expected named templates only, got dir=%q inline=%t", cfg.TemplateDir, cfg.TemplateInline)
+	}
+}
+
+func TestLoadWellKnownSettings(t *testing.T) {
+	os.Unsetenv("WELL_KNOWN_DIR")
+	os.Unsetenv("ACME_CHALLENGES")
+	if cfg := Load(); cfg.WellKnownDir != "" || cfg.ACMEChallenges {
+		t.Errorf("Expected /.well-known/ disabled by default, got dir=%q acme=%t", cfg.WellKnownDir, cfg.ACMEChallenges)
+	}
+
+	os.Setenv("WELL_KNOWN_DIR", "/srv/well-known")
+	os.Setenv("ACME_CHALLENGES", "true")
+	defer os.Unsetenv("WELL_KNOWN_DIR")
+	defer os.Unsetenv("ACME_CHALLENGES")
+
+	if cfg := Load(); cfg.WellKnownDir != "/srv/well-known" || !cfg.ACMEChallenges {
+		t.Errorf("Expected the directory and ACME challenges, got dir=%q acme=%t", cfg.WellKnownDir, cfg.ACMEChallenges)
+	}
+}
+
+func TestLoadCacheSettings(t *testing.T) {
+	for _, key := range []string{"CACHE_BACKEND", "REDIS_URL", "CACHE_PREFIX", "RDNS_CACHE_TTL"} {
+		os.Unsetenv(key)
+	}
+	cfg := Load()
+	if cfg.CacheBackend != "memory" || cfg.CachePrefix != "myip:" || cfg.RDNSCacheTTL != time.Hour {
+		t.Errorf("Unexpected cache defaults %q %q %v", cfg.CacheBackend, cfg.CachePrefix, cfg.RDNSCacheTTL)
+	}
+
+	os.Setenv("CACHE_BACKEND", "redis")
+	os.Setenv("REDIS_URL", "redis://cache:6379/1")
+	defer os.Unsetenv("CACHE_BACKEND")
+	defer os.Unsetenv("REDIS_URL")
+
+	cfg = Load()
+	if cfg.CacheBackend != "redis" || cfg.RedisURL != "redis://cache:6379/1" {
+		t.Errorf("Unexpected cache settings %q %q", cfg.CacheBackend, cfg.RedisURL)
+	}
+
+	os.Setenv("CACHE_BACKEND", "memcached")
+	if cfg := Load(); cfg.CacheBackend != "memory" {
+		t.Errorf("Expected unknown backends to fall back to memory, got %q", cfg.CacheBackend)
+	}
+}
+
+func TestLoadListenSockets(t *testing.T) {
+	os.Unsetenv("LISTEN_SOCKETS")
+	if cfg := Load(); cfg.ListenSockets != 1 {
+		t.Errorf("Expected a single listen socket by default, got %d", cfg.ListenSockets)
+	}
+
+	os.Setenv("LISTEN_SOCKETS", "8")
+	defer os.Unsetenv("LISTEN_SOCKETS")
+
+	if cfg := Load(); cfg.ListenSockets != 8 {
+		t.Errorf("Expected 8 listen sockets, got %d", cfg.ListenSockets)
+	}
+}
+
+func TestLoadConnectionSettings(t *testing.T) {
+	for _, key := range []string{"MAX_HEADER_BYTES", "IDLE_TIMEOUT", "KEEP_ALIVE", "TCP_NODELAY", "TCP_LINGER"} {
+		os.Unsetenv(key)
+	}
+
+	cfg := Load()
+	if cfg.MaxHeaderBytes != 1<<20 || cfg.IdleTimeout != 60*time.Second || !cfg.KeepAlive || !cfg.TCPNoDelay || cfg.TCPLinger != -1 {
+		t.Errorf("Unexpected connection defaults %+v", cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected defaults to be valid, got %v", err)
+	}
+
+	os.Setenv("KEEP_ALIVE", "false")
+	os.Setenv("TCP_LINGER", "0")
+	defer os.Unsetenv("KEEP_ALIVE")
+	defer os.Unsetenv("TCP_LINGER")
+
+	if cfg := Load(); cfg.KeepAlive || cfg.TCPLinger != 0 {
+		t.Errorf("Expected keep-alive off and linger 0, got %t %d", cfg.KeepAlive, cfg.TCPLinger)
+	}
+}
+
+func TestLoadInFlightLimits(t *testing.T) {
+	os.Unsetenv("MAX_IN_FLIGHT")
+	os.Unsetenv("MAX_IN_FLIGHT_PER_IP")
+	if cfg := Load(); cfg.MaxInFlight != 0 || cfg.MaxInFlightPerIP != 0 {
+		t.Errorf("Expected in-flight limits disabled by default, got %d and %d", cfg.MaxInFlight, cfg.MaxInFlightPerIP)
+	}
+
+	os.Setenv("MAX_IN_FLIGHT", "500")
+	os.Setenv("MAX_IN_FLIGHT_PER_IP", "10")
+	defer os.Unsetenv("MAX_IN_FLIGHT")
+	defer os.Unsetenv("MAX_IN_FLIGHT_PER_IP")
+
+	if cfg := Load(); cfg.MaxInFlight != 500 || cfg.MaxInFlightPerIP != 10 {
+		t.Errorf("Expected limits 500 and 10, got %d and %d", cfg.MaxInFlight, cfg.MaxInFlightPerIP)
+	}
+}
+
+func TestLoadUnixSocket(t *testing.T) {
+	os.Unsetenv("UNIX_SOCKET")
+	os.Unsetenv("UNIX_SOCKET_MODE")
+	if cfg := Load(); cfg.UnixSocket != "" || cfg.UnixSocketMode != 0o660 {
+		t.Errorf("Expected no unix socket with mode 0660 by default, got %q %o", cfg.UnixSocket, cfg.UnixSocketMode)
+	}
+
+	os.Setenv("UNIX_SOCKET", "/run/myip/myip.sock")
+	os.Setenv("UNIX_SOCKET_MODE", "0666")
+	defer os.Unsetenv("UNIX_SOCKET")
+	defer os.Unsetenv("UNIX_SOCKET_MODE")
+
+	if cfg := Load(); cfg.UnixSocket != "/run/myip/myip.sock" || cfg.UnixSocketMode != 0o666 {
+		t.Errorf("Expected /run/myip/myip.sock with mode 0666, got %q %o", cfg.UnixSocket, cfg.UnixSocketMode)
+	}
+
+	for _, invalid := range []string{"rw-rw----", "0999", "01777"} {
+		os.Setenv("UNIX_SOCKET_MODE", invalid)
+		if cfg := Load(); cfg.UnixSocketMode != 0o660 {
+			t.Errorf("UNIX_SOCKET_MODE=%q: expected fallback 0660, got %o", invalid, cfg.UnixSocketMode)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Config{Port: "8080", MaxHeaderBytes: 1 << 20, TCPLinger: -1, ListenSockets: 1, DefaultLocale: "en"}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "cert.pem")
+	if err := os.WriteFile(file, []byte("test"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	withPaths := valid
+	withPaths.TLSCertFile, withPaths.TLSKeyFile, withPaths.IPTypePrefixes, withPaths.RobotsTxtFile = file, file, file, file
+	withPaths.TemplateDir, withPaths.WellKnownDir = dir, dir
+	withPaths.TrustedProxies = []string{"10.0.0.0/8", "2001:db8::1"}
+	withPaths.STUNAddr = ":3478"
+	withPaths.MaxMindAccountID, withPaths.MaxMindLicenseKey, withPaths.GeoIPUpdateInterval = "123456", "key", 7*24*time.Hour
+	withPaths.GeoIPDownloadURL = "https://download.maxmind.com/geoip/databases/"
+	withPaths.BreakerThreshold, withPaths.BreakerBackoff, withPaths.BreakerMaxBackoff = 5, 5*time.Second, 5*time.Minute
+	withPaths.ClusterAdvertiseURL = "https://node1.example.com:8080"
+	withPaths.ContactEmail, withPaths.PrivacyPolicyURL = "abuse@example.com", "https://example.com/privacy?lang=en"
+	withPaths.ConnectivityIPv4URL, withPaths.ConnectivityIPv6URL = "https://ipv4.example.com", "http://[2001:db8::1]:8080/myip"
+	withPaths.ProxyProfiles, withPaths.ProxyRangesRefresh = []string{"cloudflare", "aws-alb"}, time.Hour
+	withPaths.ScannerBanThreshold, withPaths.ScannerBanWindow, withPaths.ScannerBanDuration = 5, time.Minute, time.Hour
+	withPaths.UnixSocket, withPaths.Port = "/run/myip.sock", ""
+	withPaths.APIKeys, withPaths.QuotaTiers = []string{"k3y=free"}, []string{"free=100/day", "free=1000/month"}
+	withPaths.EnabledEndpoints, withPaths.DisabledEndpoints = []string{"/", "/json", "/admin"}, []string{"/admin/config"}
+	if err := withPaths.Validate(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+	}{
+		{"header limit too small", func(c *Config) { c.MaxHeaderBytes = 512 }},
+		{"header limit too large", func(c *Config) { c.MaxHeaderBytes = 1 << 30 }},
+		{"negative linger", func(c *Config) { c.TCPLinger = -5 }},
+		{"no listen sockets", func(c *Config) { c.ListenSockets = 0 }},
+		{"reuseport with unix socket", func(c *Config) { c.UnixSocket = "/run/myip.sock"; c.ListenSockets = 2 }},
+		{"negative in-flight cap", func(c *Config) { c.MaxInFlight = -1 }},
+		{"API key with unknown tier", func(c *Config) { c.APIKeys, c.QuotaTiers = []string{"k3y=gold"}, []string{"free=100/day"} }},
+		{"enabled endpoint without slash", func(c *Config) { c.EnabledEndpoints = []string{"json"} }},
+		{"disabled endpoint without slash", func(c *Config) { c.DisabledEndpoints = []string{"/echo", "headers"} }},
+		{"negative per-IP cap", func(c *Config) { c.MaxInFlightPerIP = -1 }},
+		{"stats window too short", func(c *Config) { c.StatsWindow = 30 * time.Second }},
+		{"stats window too long", func(c *Config) { c.StatsWindow = 30 * 24 * time.Hour }},
+		{"invalid NAT64 prefix", func(c *Config) { c.NAT64Prefixes = []string{"2001:db8:64::/80"} }},
+		{"IPv4 NAT64 prefix", func(c *Config) { c.NAT64Prefixes = []string{"192.0.2.0/24"} }},
+		{"invalid client IP response header", func(c *Config) { c.ClientIPResponseHeader = "X Client IP" }},
+		{"invalid contact email", func(c *Config) { c.ContactEmail = "Abuse <abuse@example.com>" }},
+		{"relative privacy policy URL", func(c *Config) { c.PrivacyPolicyURL = "/privacy" }},
+		{"cluster without redis", func(c *Config) { c.ClusterEnabled, c.AdminToken = true, "secret" }},
+		{"cluster without admin token", func(c *Config) {
+			c.ClusterEnabled, c.CacheBackend, c.RedisURL = true, "redis", "redis://localhost:6379"
+		}},
+		{"cluster heartbeat too short", func(c *Config) {
+			c.ClusterEnabled, c.CacheBackend, c.RedisURL, c.AdminToken = true, "redis", "redis://localhost:6379", "secret"
+			c.ClusterHeartbeat = 100 * time.Millisecond
+		}},
+		{"MaxMind license key without account", func(c *Config) { c.MaxMindLicenseKey = "key" }},
+		{"MaxMind account without license key", func(c *Config) { c.MaxMindAccountID = "123456" }},
+		{"GeoIP update interval too short", func(c *Config) {
+			c.MaxMindAccountID, c.MaxMindLicenseKey, c.GeoIPUpdateInterval = "123456", "key", time.Minute
+		}},
+		{"GeoIP download URL without scheme", func(c *Config) { c.GeoIPDownloadURL = "download.maxmind.com" }},
+		{"negative breaker threshold", func(c *Config) { c.BreakerThreshold = -1 }},
+		{"breaker backoff too short", func(c *Config) {
+			c.BreakerThreshold, c.BreakerBackoff, c.BreakerMaxBackoff = 5, 100*time.Millisecond, time.Minute
+		}},
+		{"breaker max backoff below backoff", func(c *Config) {
+			c.BreakerThreshold, c.BreakerBackoff, c.BreakerMaxBackoff = 5, time.Minute, time.Second
+		}},
+		{"connectivity URL without scheme", func(c *Config) { c.ConnectivityIPv4URL = "ipv4.example.com" }},
+		{"connectivity URL with query", func(c *Config) { c.ConnectivityIPv6URL = "https://ipv6.example.com/?x=1" }},
+		{"negative request capture size", func(c *Config) { c.RequestCaptureSize = -1 }},
+		{"request capture size too large", func(c *Config) { c.RequestCaptureSize = 100000 }},
+		{"redis without a URL", func(c *Config) { c.CacheBackend = "redis" }},
+		{"ACME challenges without an admin token", func(c *Config) { c.ACMEChallenges = true }},
+		{"pprof without an admin token", func(c *Config) { c.PprofEnabled = true }},
+		{"request capture without an admin token", func(c *Config) { c.RequestCaptureSize = 100 }},
+		{"edge cache vary without a max age", func(c *Config) { c.EdgeCache = "vary" }},
+		{"negative request timeout", func(c *Config) { c.RequestTimeout = -time.Second }},
+		{"negative scanner ban threshold", func(c *Config) { c.ScannerBanThreshold = -1 }},
+		{"scanner bans without a duration", func(c *Config) { c.ScannerBanThreshold = 5; c.ScannerBanWindow = time.Minute }},
+		{"scanner bans without trusted proxies", func(c *Config) {
+			c.ScannerBanThreshold, c.ScannerBanWindow, c.ScannerBanDuration = 5, time.Minute, time.Hour
+		}},
+		{"non-numeric port", func(c *Config) { c.Port = "http" }},
+		{"port out of range", func(c *Config) { c.Port = "70000" }},
+		{"port zero", func(c *Config) { c.Port = "0" }},
+		{"STUN address without a port", func(c *Config) { c.STUNAddr = "3478" }},
+		{"STUN port out of range", func(c *Config) { c.STUNAddr = ":99999" }},
+		{"invalid trusted proxy", func(c *Config) { c.TrustedProxies = []string{"10.0.0.0/33"} }},
+		{"TLS certificate without a key", func(c *Config) { c.TLSCertFile = file }},
+		{"missing TLS files", func(c *Config) { c.TLSCertFile, c.TLSKeyFile = "/nonexistent/cert.pem", "/nonexistent/key.pem" }},
+		{"directory as ASN database", func(c *Config) { c.IPASNDB = dir }},
+		{"missing prefix file", func(c *Config) { c.IPTypePrefixes = filepath.Join(dir, "missing.txt") }},
+		{"file as template directory", func(c *Config) { c.TemplateDir = file }},
+		{"missing well-known directory", func(c *Config) { c.WellKnownDir = filepath.Join(dir, "missing") }},
+		{"unsupported locale", func(c *Config) { c.DefaultLocale = "fr" }},
+		{"unknown proxy profile", func(c *Config) { c.ProxyProfiles = []string{"haproxy"} }},
+		{"conflicting proxy profiles", func(c *Config) { c.ProxyProfiles = []string{"aws-alb", "gcp-lb"} }},
+		{"proxy ranges refreshed too often", func(c *Config) { c.ProxyRangesRefresh = time.Second }},
+	}
+	for _, tc := range tests {
+		cfg := valid
+		tc.modify(&cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected a validation error", tc.name)
+		}
+	}
+}
+
+func TestValidateUnparsableValues(t *testing.T) {
+	tests := []struct {
+		key, value string
+	}{
+		{"MAX_IN_FLIGHT", "ten"},
+		{"DNS_RATE_LIMIT", "abc"},
+		{"KEEP_ALIVE", "sometimes"},
+		{"REQUEST_TIMEOUT", "soon"},
+		{"REQUEST_TIMEOUT", "-5s"},
+		{"SLO_AVAILABILITY_TARGET", "high"},
+		{"UNIX_SOCKET_MODE", "rw"},
+		{"EDGE_CACHE", "always"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.key+"="+tc.value, func(t *testing.T) {
+			t.Setenv(tc.key, tc.value)
+
+			cfg, err := Read()
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tc.key) || !strings.Contains(err.Error(), strconv.Quote(tc.value)) {
+				t.Errorf("Expected an error naming %s and %q, got %v", tc.key, tc.value, err)
+			}
+		})
+	}
+
+	t.Setenv("PORT", "80a")
+	cfg, _ := Read()
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "PORT") {
+		t.Errorf("Expected an error naming PORT, got %v", err)
+	}
+}
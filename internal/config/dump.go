@@ -0,0 +1,76 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"myip/internal/models"
+	"myip/internal/problem"
+)
+
+// Origins of a setting's value in ConfigSetting.Source
+const (
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceFlag    = "flag"
+	SourceDefault = "default"
+)
+
+// masked replaces the value of a secret that is set
+const masked = "********"
+
+// secrets are the keys whose values are masked in Settings
+var secrets = map[string]bool{
+	"ADMIN_TOKEN":         true,
+	"MAXMIND_LICENSE_KEY": true,
+}
+
+// Settings returns every key read with its effective value and origin, sorted by key. Secrets
+// and the keys of API_KEYS are masked, and the REDIS_URL password is removed.
+func (c *Config) Settings() []models.ConfigSetting {
+	settings := make([]models.ConfigSetting, len(c.settings))
+	for i, setting := range c.settings {
+		switch {
+		case secrets[setting.Key] && setting.Value != "":
+			setting.Value = masked
+		case setting.Key == "REDIS_URL" && setting.Value != "":
+			setting.Value = redactURL(setting.Value)
+		case setting.Key == "API_KEYS" && setting.Value != "":
+			setting.Value = maskAPIKeys(setting.Value)
+		}
+		settings[i] = setting
+	}
+	return settings
+}
+
+// DumpHandler serves the configuration returned by current with secrets masked
+func DumpHandler(current func() *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := current()
+		response := &models.ConfigDump{
+			Hash:     cfg.Hash(),
+			File:     cfg.ConfigFile,
+			Settings: cfg.Settings(),
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			problem.Error(w, r, http.StatusInternalServerError, "Failed to encode JSON response")
+		}
+	}
+}
+
+// maskAPIKeys masks the keys of "key=tier" entries, keeping their tiers
+func maskAPIKeys(value string) string {
+	entries := strings.Split(value, ",")
+	for i, entry := range entries {
+		if _, tier, ok := strings.Cut(entry, "="); ok {
+			entries[i] = masked + "=" + strings.TrimSpace(tier)
+		} else if strings.TrimSpace(entry) != "" {
+			entries[i] = masked
+		}
+	}
+	return strings.Join(entries, ",")
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"myip/internal/models"
+)
+
+func TestSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myip.env")
+	writeFile(t, path, "LOG_LEVEL=debug\nPORT=9000\nMAXMIND_LICENSE_KEY=file-secret\n")
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("PORT", "3000")
+	t.Setenv("ADMIN_TOKEN", "env-secret")
+	t.Setenv("REDIS_URL", "redis://:hunter2@cache:6379/0")
+	t.Setenv("LISTEN_SOCKETS", "many")
+	t.Setenv("API_KEYS", "k3y-one=free, k3y-two=pro")
+
+	cfg, err := Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings := make(map[string]models.ConfigSetting)
+	for _, setting := range cfg.Settings() {
+		settings[setting.Key] = setting
+	}
+	expected := map[string]models.ConfigSetting{
+		"PORT":                {Key: "PORT", Value: "3000", Source: SourceEnv},
+		"LOG_LEVEL":           {Key: "LOG_LEVEL", Value: "debug", Source: SourceFile},
+		"HOST":                {Key: "HOST", Value: "localhost:8080", Source: SourceDefault},
+		"LISTEN_SOCKETS":      {Key: "LISTEN_SOCKETS", Value: "1", Source: SourceDefault},
+		"CONFIG_FILE":         {Key: "CONFIG_FILE", Value: path, Source: SourceEnv},
+		"STATS_WINDOW":        {Key: "STATS_WINDOW", Value: "1h0m0s", Source: SourceDefault},
+		"UNIX_SOCKET_MODE":    {Key: "UNIX_SOCKET_MODE", Value: "0660", Source: SourceDefault},
+		"ADMIN_TOKEN":         {Key: "ADMIN_TOKEN", Value: masked, Source: SourceEnv},
+		"MAXMIND_LICENSE_KEY": {Key: "MAXMIND_LICENSE_KEY", Value: masked, Source: SourceFile},
+		"REDIS_URL":           {Key: "REDIS_URL", Value: "redis://:xxxxx@cache:6379/0", Source: SourceEnv},
+		"CONTACT_EMAIL":       {Key: "CONTACT_EMAIL", Value: "", Source: SourceDefault},
+		"API_KEYS":            {Key: "API_KEYS", Value: "********=free,********=pro", Source: SourceEnv},
+	}
+	for key, want := range expected {
+		if got := settings[key]; got != want {
+			t.Errorf("Expected %+v, got %+v", want, got)
+		}
+	}
+
+	keys := cfg.Settings()
+	for i := 1; i < len(keys); i++ {
+		if keys[i-1].Key >= keys[i].Key {
+			t.Fatalf("Expected settings sorted by key, got %s before %s", keys[i-1].Key, keys[i].Key)
+		}
+	}
+	if cfg.settings[0].Key != keys[0].Key || strings.Contains(cfg.AdminToken, "*") {
+		t.Error("Expected masking to leave the configuration itself unchanged")
+	}
+}
+
+func TestDumpHandler(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "env-secret")
+	cfg, err := Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	DumpHandler(func() *Config { return cfg })(rr, httptest.NewRequest("GET", "/admin/config", nil))
+
+	if rr.Code != http.StatusOK || rr.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("Expected an uncached 200, got %d %v", rr.Code, rr.Header())
+	}
+	if strings.Contains(rr.Body.String(), "env-secret") {
+		t.Fatalf("Expected the admin token to be masked, got %s", rr.Body.String())
+	}
+	var dump models.ConfigDump
+	if err := json.Unmarshal(rr.Body.Bytes(), &dump); err != nil {
+		t.Fatal(err)
+	}
+	if dump.Hash != cfg.Hash() || len(dump.Settings) < 100 {
+		t.Errorf("Expected the hash and every setting, got %s and %d settings", dump.Hash, len(dump.Settings))
+	}
+}
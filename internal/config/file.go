@@ -0,0 +1,171 @@
+package config
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// readFile loads a config file into environment-style keys. The format is chosen by
+// extension: .yaml/.yml files are structured, anything else is KEY=VALUE.
+func readFile(path string) (map[string]string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var tree map[string]any
+		if err := yaml.Unmarshal(data, &tree); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return flattenFile(path, tree)
+	case ".toml":
+		// TOML is refused rather than misread as KEY=VALUE lines
+		return nil, fmt.Errorf("%s: TOML config files are not supported, use YAML or KEY=VALUE", path)
+	default:
+		return readEnvFile(path)
+	}
+}
+
+// flattenFile maps a structured config tree onto environment variable names, so
+// {dns: {rate_limit: 10}} becomes DNS_RATE_LIMIT=10 and lists become comma-separated values
+func flattenFile(path string, tree map[string]any) (map[string]string, error) {
+	values := make(map[string]string)
+	for key, value := range tree {
+		if err := flatten(key, value, values); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return values, nil
+}
+
+func flatten(key string, value any, values map[string]string) error {
+	name := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+
+	switch v := value.(type) {
+	case map[string]any:
+		for child, childValue := range v {
+			if err := flatten(key+"_"+child, childValue, values); err != nil {
+				return err
+			}
+		}
+	case map[any]any:
+		for child, childValue := range v {
+			if err := flatten(key+"_"+fmt.Sprint(child), childValue, values); err != nil {
+				return err
+			}
+		}
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			scalar, err := scalarString(item)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			items = append(items, scalar)
+		}
+		values[name] = strings.Join(items, ",")
+	default:
+		scalar, err := scalarString(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		values[name] = scalar
+	}
+	return nil
+}
+
+// scalarString formats a scalar config value the way it would be written in an environment variable
+func scalarString(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", value)
+	}
+}
+
+// readEnvFile parses a config file of KEY=VALUE lines. Blank lines and lines starting
+// with # are ignored, and values may be wrapped in single or double quotes.
+func readEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNumber)
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+// Watch polls path every interval and calls onChange when its modification time or size
+// changes. It returns when ctx is cancelled.
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	stat := func() (time.Time, int64) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, -1
+		}
+		return info.ModTime(), info.Size()
+	}
+
+	lastMod, lastSize := stat()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			mod, size := stat()
+			if !mod.Equal(lastMod) || size != lastSize {
+				lastMod, lastSize = mod, size
+				onChange()
+			}
+		}
+	}
+}
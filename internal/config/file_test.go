@@ -0,0 +1,228 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myip.env")
+	writeFile(t, path, `# comment
+LOG_LEVEL=debug
+
+export TRUSTED_PROXIES = "10.0.0.0/8, 192.168.0.0/16"
+MAINTENANCE_MESSAGE='Back soon'
+EMPTY=
+`)
+
+	values, err := readEnvFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"LOG_LEVEL":           "debug",
+		"TRUSTED_PROXIES":     "10.0.0.0/8, 192.168.0.0/16",
+		"MAINTENANCE_MESSAGE": "Back soon",
+		"EMPTY":               "",
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("Expected %s=%q, got %q", key, value, values[key])
+		}
+	}
+}
+
+func TestReadEnvFileErrors(t *testing.T) {
+	if _, err := readEnvFile(filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+
+	path := filepath.Join(t.TempDir(), "bad.env")
+	writeFile(t, path, "LOG_LEVEL debug\n")
+	if _, err := readEnvFile(path); err == nil {
+		t.Error("Expected error for line without '='")
+	}
+}
+
+func TestReadWithConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myip.env")
+	writeFile(t, path, "LOG_LEVEL=debug\nHEADER_PRIORITY=X-Real-IP,X-Forwarded-For\nPORT=9000\n")
+
+	os.Setenv("CONFIG_FILE", path)
+	os.Setenv("PORT", "3000")
+	defer os.Unsetenv("CONFIG_FILE")
+	defer os.Unsetenv("PORT")
+
+	cfg, err := Read()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if cfg.ConfigFile != path {
+		t.Errorf("Expected config file %s, got %s", path, cfg.ConfigFile)
+	}
+
+	if cfg.LogLevel != "debug" {
+		t.Errorf("Expected log level from file, got %s", cfg.LogLevel)
+	}
+
+	if len(cfg.HeaderPriority) != 2 || cfg.HeaderPriority[0] != "X-Real-IP" {
+		t.Errorf("Expected header priority from file, got %v", cfg.HeaderPriority)
+	}
+
+	// Environment variables take precedence over the file
+	if cfg.Port != "3000" {
+		t.Errorf("Expected PORT from environment, got %s", cfg.Port)
+	}
+}
+
+func TestReadWithMissingConfigFile(t *testing.T) {
+	os.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.env"))
+	defer os.Unsetenv("CONFIG_FILE")
+
+	cfg, err := Read()
+	if err == nil {
+		t.Error("Expected error for missing config file")
+	}
+	if cfg == nil || cfg.Port != "8080" {
+		t.Errorf("Expected usable defaults despite the error, got %+v", cfg)
+	}
+
+	// Load logs the error and still returns a config
+	if Load() == nil {
+		t.Error("Expected Load to return a config")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myip.env")
+	writeFile(t, path, "LOG_LEVEL=info\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	go Watch(ctx, path, 10*time.Millisecond, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	// Let the watcher record the initial state before modifying the file
+	time.Sleep(30 * time.Millisecond)
+	writeFile(t, path, "LOG_LEVEL=debug\n")
+
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected change notification")
+	}
+}
+
+func TestReadYAMLFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myip.yaml")
+	writeFile(t, path, `
+port: 9000
+log_level: debug
+trusted_proxies:
+  - 10.0.0.0/8
+  - 192.168.0.0/16
+tls:
+  cert_file: /etc/myip/tls.crt
+  key_file: /etc/myip/tls.key
+dns:
+  rate-limit: 10
+  timeout: 2s
+slo:
+  availability_target: 0.995
+maintenance:
+  mode: true
+`)
+
+	values, err := readFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"PORT":                    "9000",
+		"LOG_LEVEL":               "debug",
+		"TRUSTED_PROXIES":         "10.0.0.0/8,192.168.0.0/16",
+		"TLS_CERT_FILE":           "/etc/myip/tls.crt",
+		"TLS_KEY_FILE":            "/etc/myip/tls.key",
+		"DNS_RATE_LIMIT":          "10",
+		"DNS_TIMEOUT":             "2s",
+		"SLO_AVAILABILITY_TARGET": "0.995",
+		"MAINTENANCE_MODE":        "true",
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("Expected %s=%q, got %q", key, value, values[key])
+		}
+	}
+}
+
+func TestReadTOMLFileRejected(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myip.toml")
+	writeFile(t, path, "port = 9000\n")
+
+	if _, err := readFile(path); err == nil || !strings.Contains(err.Error(), "TOML") {
+		t.Errorf("Expected TOML files to be rejected, got %v", err)
+	}
+}
+
+func TestReadStructuredFileErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlPath := filepath.Join(dir, "bad.yml")
+	writeFile(t, yamlPath, "port: [unclosed\n")
+	if _, err := readFile(yamlPath); err == nil {
+		t.Error("Expected error for invalid YAML")
+	}
+
+	nestedList := filepath.Join(dir, "nested.yaml")
+	writeFile(t, nestedList, "trusted_proxies:\n  - cidr: 10.0.0.0/8\n")
+	if _, err := readFile(nestedList); err == nil {
+		t.Error("Expected error for list of maps")
+	}
+
+	if _, err := readFile(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("Expected error for missing YAML file")
+	}
+}
+
+func TestSetFileOverridesEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	envPath := filepath.Join(dir, "env.yaml")
+	flagPath := filepath.Join(dir, "flag.yaml")
+	writeFile(t, envPath, "log_level: warn\n")
+	writeFile(t, flagPath, "log_level: error\n")
+
+	os.Setenv("CONFIG_FILE", envPath)
+	defer os.Unsetenv("CONFIG_FILE")
+	defer SetFile("")
+
+	SetFile(flagPath)
+
+	cfg, err := Read()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if cfg.ConfigFile != flagPath || cfg.LogLevel != "error" {
+		t.Errorf("Expected -config file to take precedence, got %s with level %s", cfg.ConfigFile, cfg.LogLevel)
+	}
+}
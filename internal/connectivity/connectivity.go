@@ -0,0 +1,79 @@
+// Package connectivity serves a page testing which address families a browser reaches the
+// service over, like test-ipv6.com: the browser loads /both from a hostname resolving only to
+// IPv4, one resolving only to IPv6, and the page's own dual-stack host, and reports which loads
+// succeed and how long each took.
+package connectivity
+
+import (
+	"embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"myip/internal/guide"
+	"myip/internal/models"
+	"myip/internal/problem"
+)
+
+// Address families tested
+const (
+	FamilyIPv4      = "ipv4"
+	FamilyIPv6      = "ipv6"
+	FamilyDualStack = "dual-stack"
+)
+
+// TimeoutMs is how long the page waits for each test before reporting it failed
+const TimeoutMs = 10000
+
+// testPath is the endpoint each test loads, as JSONP so no cross-origin headers are needed
+const testPath = "/both?format=jsonp"
+
+//go:embed templates
+var templates embed.FS
+
+var page = template.Must(template.ParseFS(templates, "templates/connectivity.html.tmpl"))
+
+// Handler serves the connectivity test page to browsers and its test plan as JSON otherwise.
+// ipv4URL and ipv6URL are base URLs of hostnames resolving only to the service's IPv4 and only
+// to its IPv6 addresses; the family is left untested when one is empty.
+func Handler(ipv4URL, ipv6URL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		plan := Plan(guide.BaseURL(r), ipv4URL, ipv6URL)
+		w.Header().Set("Cache-Control", "no-store")
+
+		if strings.Contains(r.Header.Get("Accept"), "text/html") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if err := page.Execute(w, plan); err != nil {
+				problem.Error(w, r, http.StatusInternalServerError, "Failed to render connectivity test")
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(plan); err != nil {
+			problem.Error(w, r, http.StatusInternalServerError, "Failed to encode connectivity test")
+		}
+	}
+}
+
+// Plan returns the tests run from a page served at baseURL
+func Plan(baseURL, ipv4URL, ipv6URL string) models.ConnectivityPlan {
+	plan := models.ConnectivityPlan{Tests: []models.ConnectivityTest{}, Untested: []string{}, TimeoutMs: TimeoutMs}
+	for _, test := range []struct{ name, family, base string }{
+		{"IPv4 only", FamilyIPv4, ipv4URL},
+		{"IPv6 only", FamilyIPv6, ipv6URL},
+		{"Dual stack", FamilyDualStack, baseURL},
+	} {
+		if test.base == "" {
+			plan.Untested = append(plan.Untested, test.family)
+			continue
+		}
+		plan.Tests = append(plan.Tests, models.ConnectivityTest{
+			Name:   test.name,
+			Family: test.family,
+			URL:    strings.TrimSuffix(test.base, "/") + testPath,
+		})
+	}
+	return plan
+}
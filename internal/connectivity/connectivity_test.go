@@ -0,0 +1,65 @@
+package connectivity
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"myip/internal/models"
+)
+
+func TestHandlerJSON(t *testing.T) {
+	req := httptest.NewRequest("GET", "/connectivity", nil)
+	req.Host = "ip.example.com"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rr := httptest.NewRecorder()
+
+	Handler("https://ipv4.example.com/", "")(rr, req)
+
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json, got %s", ct)
+	}
+	var plan models.ConnectivityPlan
+	if err := json.Unmarshal(rr.Body.Bytes(), &plan); err != nil {
+		t.Fatalf("Failed to decode plan: %v", err)
+	}
+	want := models.ConnectivityPlan{
+		Tests: []models.ConnectivityTest{
+			{Name: "IPv4 only", Family: FamilyIPv4, URL: "https://ipv4.example.com/both?format=jsonp"},
+			{Name: "Dual stack", Family: FamilyDualStack, URL: "https://ip.example.com/both?format=jsonp"},
+		},
+		Untested:  []string{FamilyIPv6},
+		TimeoutMs: TimeoutMs,
+	}
+	if !reflect.DeepEqual(plan, want) {
+		t.Errorf("Expected %+v, got %+v", want, plan)
+	}
+}
+
+func TestHandlerHTML(t *testing.T) {
+	req := httptest.NewRequest("GET", "/connectivity", nil)
+	req.Host = "localhost:8080"
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	rr := httptest.NewRecorder()
+
+	Handler("http://ipv4.localhost:8080", "http://ipv6.localhost:8080")(rr, req)
+
+	if ct := rr.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Expected text/html, got %s", ct)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{
+		`"url":"http://ipv4.localhost:8080/both?format=jsonp"`,
+		`"url":"http://ipv6.localhost:8080/both?format=jsonp"`,
+		`"url":"http://localhost:8080/both?format=jsonp"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected page to contain %s, got:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "Not tested") {
+		t.Error("Expected every family to be tested")
+	}
+}
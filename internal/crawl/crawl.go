@@ -0,0 +1,49 @@
+// Package crawl serves /robots.txt and /favicon.ico, which browsers and crawlers request from
+// every site, so they get an answer instead of a 404 and crawlers stay out of the pages that
+// echo request details.
+package crawl
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// DefaultRobots keeps crawlers out of the endpoints that echo request headers back
+const DefaultRobots = "User-agent: *\nDisallow: /headers\nDisallow: /echo\n"
+
+// cacheControl lets browsers and CDNs keep both files for a day
+const cacheControl = "public, max-age=86400"
+
+//go:embed favicon.ico
+var favicon []byte
+
+// LoadRobots returns the robots.txt served: the contents of file, or DefaultRobots when file is
+// empty
+func LoadRobots(file string) ([]byte, error) {
+	if file == "" {
+		return []byte(DefaultRobots), nil
+	}
+	body, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ROBOTS_TXT_FILE: %w", err)
+	}
+	return body, nil
+}
+
+// RobotsHandler serves body as /robots.txt
+func RobotsHandler(body []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Write(body)
+	}
+}
+
+// FaviconHandler serves the embedded icon as /favicon.ico
+func FaviconHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "image/x-icon")
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Write(favicon)
+}
@@ -0,0 +1,57 @@
+package crawl
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRobots(t *testing.T) {
+	body, err := LoadRobots("")
+	if err != nil || string(body) != DefaultRobots {
+		t.Errorf("Expected the default robots.txt, got %q, %v", body, err)
+	}
+
+	file := filepath.Join(t.TempDir(), "robots.txt")
+	custom := "User-agent: *\nDisallow: /\n"
+	if err := os.WriteFile(file, []byte(custom), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if body, err := LoadRobots(file); err != nil || string(body) != custom {
+		t.Errorf("Expected the file contents, got %q, %v", body, err)
+	}
+
+	if _, err := LoadRobots(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestRobotsHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	RobotsHandler([]byte(DefaultRobots))(rr, httptest.NewRequest("GET", "/robots.txt", nil))
+
+	if ct := rr.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("Expected text/plain, got %s", ct)
+	}
+	if rr.Body.String() != DefaultRobots {
+		t.Errorf("Expected %q, got %q", DefaultRobots, rr.Body.String())
+	}
+}
+
+func TestFaviconHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	FaviconHandler(rr, httptest.NewRequest("GET", "/favicon.ico", nil))
+
+	if ct := rr.Header().Get("Content-Type"); ct != "image/x-icon" {
+		t.Errorf("Expected image/x-icon, got %s", ct)
+	}
+	// ICO files start with a reserved zero word and type 1
+	if !bytes.HasPrefix(rr.Body.Bytes(), []byte{0, 0, 1, 0}) {
+		t.Errorf("Expected an ICO file, got % x", rr.Body.Bytes()[:min(rr.Body.Len(), 8)])
+	}
+	if rr.Header().Get("Cache-Control") == "" {
+		t.Error("Expected the icon to be cacheable")
+	}
+}
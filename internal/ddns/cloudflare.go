@@ -0,0 +1,115 @@
+package ddns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// cloudflareAPI is the Cloudflare v4 API root
+const cloudflareAPI = "https://api.cloudflare.com/client/v4"
+
+func init() {
+	Register("cloudflare", newCloudflare)
+}
+
+// cloudflare updates records through the Cloudflare API with a token allowed to edit the zone's
+// DNS (CLOUDFLARE_API_TOKEN) and the zone's ID (CLOUDFLARE_ZONE_ID)
+type cloudflare struct {
+	api    string
+	token  string
+	zoneID string
+}
+
+func newCloudflare(getenv func(string) string) (Provider, error) {
+	values, err := required(getenv, "CLOUDFLARE_API_TOKEN", "CLOUDFLARE_ZONE_ID")
+	if err != nil {
+		return nil, fmt.Errorf("cloudflare: %w", err)
+	}
+	return &cloudflare{api: cloudflareAPI, token: values[0], zoneID: values[1]}, nil
+}
+
+// cloudflareRecord is a DNS record in API requests and responses
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+}
+
+// cloudflareResponse is the envelope of every API response
+type cloudflareResponse struct {
+	Success bool            `json:"success"`
+	Errors  []cloudflareMsg `json:"errors"`
+	Result  json.RawMessage `json:"result"`
+}
+
+type cloudflareMsg struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Update changes the existing record when it holds another address, or creates it
+func (c *cloudflare) Update(ctx context.Context, record Record) error {
+	query := url.Values{"type": {record.Type}, "name": {record.Name}}
+	var existing []cloudflareRecord
+	if err := c.call(ctx, http.MethodGet, "/dns_records?"+query.Encode(), nil, &existing); err != nil {
+		return err
+	}
+
+	body := cloudflareRecord{Type: record.Type, Name: record.Name, Content: record.IP, TTL: record.TTL}
+	if len(existing) == 0 {
+		return c.call(ctx, http.MethodPost, "/dns_records", body, nil)
+	}
+	if existing[0].Content == record.IP && existing[0].TTL == record.TTL {
+		return nil
+	}
+	return c.call(ctx, http.MethodPatch, "/dns_records/"+url.PathEscape(existing[0].ID), body, nil)
+}
+
+// call sends an API request below the zone, decoding the result into out when it is not nil
+func (c *cloudflare) call(ctx context.Context, method, path string, in, out any) error {
+	var payload []byte
+	if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	target := c.api + "/zones/" + url.PathEscape(c.zoneID) + path
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	status, body, err := send(req)
+	if err != nil {
+		return err
+	}
+	var resp cloudflareResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("cloudflare: %s %s answered %d with an undecodable body", method, path, status)
+	}
+	if !resp.Success {
+		messages := make([]string, len(resp.Errors))
+		for i, e := range resp.Errors {
+			messages[i] = fmt.Sprintf("%s (%d)", e.Message, e.Code)
+		}
+		return errors.New("cloudflare: " + strings.Join(messages, "; "))
+	}
+	if out != nil {
+		return json.Unmarshal(resp.Result, out)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package ddns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeCloudflare serves the DNS record endpoints of one zone
+type fakeCloudflare struct {
+	records  []cloudflareRecord
+	requests []string
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `{"success":false,"errors":[{"code":10000,"message":"Authentication error"}]}`)
+		return
+	}
+
+	var result any
+	switch r.Method {
+	case http.MethodGet:
+		var matches []cloudflareRecord
+		for _, record := range f.records {
+			if record.Type == r.URL.Query().Get("type") && record.Name == r.URL.Query().Get("name") {
+				matches = append(matches, record)
+			}
+		}
+		result = matches
+	case http.MethodPost:
+		var record cloudflareRecord
+		json.NewDecoder(r.Body).Decode(&record)
+		record.ID = fmt.Sprintf("rec%d", len(f.records)+1)
+		f.records = append(f.records, record)
+		result = record
+	case http.MethodPatch:
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		for i := range f.records {
+			if f.records[i].ID == id {
+				json.NewDecoder(r.Body).Decode(&f.records[i])
+				f.records[i].ID = id
+				result = f.records[i]
+			}
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "errors": []any{}, "result": result})
+}
+
+func TestCloudflareUpdate(t *testing.T) {
+	fake := &fakeCloudflare{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c := &cloudflare{api: server.URL, token: "token", zoneID: "zone1"}
+	ctx := context.Background()
+	record := Record{Name: "home.example.com", Type: "A", IP: "203.0.113.7", TTL: 300}
+
+	// Created, left alone, then changed
+	for _, ip := range []string{"203.0.113.7", "203.0.113.7", "198.51.100.4"} {
+		record.IP = ip
+		if err := c.Update(ctx, record); err != nil {
+			t.Fatalf("Update(%s) error = %v", ip, err)
+		}
+	}
+
+	want := "GET /zones/zone1/dns_records POST /zones/zone1/dns_records GET /zones/zone1/dns_records " +
+		"GET /zones/zone1/dns_records PATCH /zones/zone1/dns_records/rec1"
+	if got := strings.Join(fake.requests, " "); got != want {
+		t.Errorf("Requests = %s", got)
+	}
+	if len(fake.records) != 1 || fake.records[0].Content != "198.51.100.4" {
+		t.Errorf("Expected one record holding the new address, got %+v", fake.records)
+	}
+}
+
+func TestCloudflareError(t *testing.T) {
+	server := httptest.NewServer(&fakeCloudflare{})
+	defer server.Close()
+
+	c := &cloudflare{api: server.URL, token: "wrong", zoneID: "zone1"}
+	err := c.Update(context.Background(), Record{Name: "home.example.com", Type: "A", IP: "203.0.113.7"})
+	if err == nil || !strings.Contains(err.Error(), "Authentication error (10000)") {
+		t.Errorf("Expected the API error, got %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+// Package ddns updates DNS records with the public address reported by "myip watch", turning it
+// into a dynamic DNS agent. Each DNS host is a Provider registered under a name, configured from
+// the environment so API credentials stay out of command lines.
+package ddns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"myip/internal/logging"
+	"myip/internal/watch"
+)
+
+// DefaultTTL is the TTL, in seconds, of the records written
+const DefaultTTL = 300
+
+// Provider settings
+const (
+	requestTimeout = 15 * time.Second
+	maxBodySize    = 1 << 20
+	userAgent      = "myip-ddns"
+)
+
+// Record is a DNS record to point at the public address
+type Record struct {
+	// Name is the fully qualified record name, such as "home.example.com"
+	Name string
+
+	// Type is "A" or "AAAA"
+	Type string
+
+	// IP is the address the record should hold
+	IP string
+
+	// TTL in seconds
+	TTL int
+}
+
+// Provider updates records at a DNS host
+type Provider interface {
+	// Update creates or replaces the record so that it holds only record.IP
+	Update(ctx context.Context, record Record) error
+}
+
+// Factory creates a Provider from settings looked up with getenv, such as an API token
+type Factory func(getenv func(string) string) (Provider, error)
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]Factory)
+)
+
+// Register makes a provider available under name. It panics when the name is already taken,
+// since providers register themselves at init.
+func Register(name string, factory Factory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	if _, ok := providers[name]; ok {
+		panic("ddns: provider " + name + " registered twice")
+	}
+	providers[name] = factory
+}
+
+// Providers returns the names of the registered providers, sorted
+func Providers() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// NewProvider creates the provider registered under name
+func NewProvider(name string, getenv func(string) string) (Provider, error) {
+	providersMu.RLock()
+	factory, ok := providers[name]
+	providersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown DNS provider %q (available: %s)", name, strings.Join(Providers(), ", "))
+	}
+	return factory(getenv)
+}
+
+// Updater is a watch.Notifier pointing records at each new address
+type Updater struct {
+	provider Provider
+	records  []string
+	ttl      int
+}
+
+// NewUpdater creates an Updater keeping the named records up to date through provider. A ttl of
+// zero means DefaultTTL.
+func NewUpdater(provider Provider, records []string, ttl int) (*Updater, error) {
+	if len(records) == 0 {
+		return nil, errors.New("no DNS records to update")
+	}
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	if ttl < 0 {
+		return nil, fmt.Errorf("TTL must be positive, got %d", ttl)
+	}
+	return &Updater{provider: provider, records: records, ttl: ttl}, nil
+}
+
+// Notify updates every record to the new address, attempting all of them before returning
+// their errors
+func (u *Updater) Notify(ctx context.Context, event watch.Event) error {
+	recordType := "A"
+	if event.Family == watch.FamilyIPv6 {
+		recordType = "AAAA"
+	}
+
+	var errs []error
+	for _, name := range u.records {
+		record := Record{Name: name, Type: recordType, IP: event.IP, TTL: u.ttl}
+		if err := u.provider.Update(ctx, record); err != nil {
+			errs = append(errs, fmt.Errorf("updating %s %s: %w", recordType, name, err))
+			continue
+		}
+		logging.Infof("Pointed %s record %s at %s", recordType, name, event.IP)
+	}
+	return errors.Join(errs...)
+}
+
+// httpClient sends provider API requests
+var httpClient = &http.Client{Timeout: requestTimeout}
+
+// send performs an API request and returns the response status and body
+func send(req *http.Request) (int, []byte, error) {
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	return resp.StatusCode, body, err
+}
+
+// required looks up the settings a provider cannot work without
+func required(getenv func(string) string, keys ...string) ([]string, error) {
+	values := make([]string, len(keys))
+	var missing []string
+	for i, key := range keys {
+		if values[i] = strings.TrimSpace(getenv(key)); values[i] == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	return values, nil
+}
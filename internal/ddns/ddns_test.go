@@ -0,0 +1,85 @@
+package ddns
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"myip/internal/watch"
+)
+
+// fakeProvider records updates, failing those for names in fail
+type fakeProvider struct {
+	updates []Record
+	fail    map[string]bool
+}
+
+func (p *fakeProvider) Update(ctx context.Context, record Record) error {
+	p.updates = append(p.updates, record)
+	if p.fail[record.Name] {
+		return errors.New("zone not found")
+	}
+	return nil
+}
+
+// env returns a getenv function serving values
+func env(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+func TestUpdaterNotify(t *testing.T) {
+	provider := &fakeProvider{fail: map[string]bool{"b.example.com": true}}
+	u, err := NewUpdater(provider, []string{"a.example.com", "b.example.com", "c.example.com"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = u.Notify(context.Background(), watch.Event{IP: "2001:db8::1", Family: watch.FamilyIPv6})
+	if err == nil || !strings.Contains(err.Error(), "b.example.com") {
+		t.Errorf("Expected the failed record in the error, got %v", err)
+	}
+	// The failure does not stop the remaining records
+	if len(provider.updates) != 3 {
+		t.Fatalf("Expected 3 updates, got %+v", provider.updates)
+	}
+	want := Record{Name: "c.example.com", Type: "AAAA", IP: "2001:db8::1", TTL: DefaultTTL}
+	if provider.updates[2] != want {
+		t.Errorf("Update = %+v, want %+v", provider.updates[2], want)
+	}
+
+	if err := u.Notify(context.Background(), watch.Event{IP: "203.0.113.7", Family: watch.FamilyIPv4}); err == nil {
+		t.Error("Expected the failing record to fail again")
+	}
+	if provider.updates[3].Type != "A" {
+		t.Errorf("Expected an A record for IPv4, got %+v", provider.updates[3])
+	}
+}
+
+func TestNewUpdaterValidates(t *testing.T) {
+	if _, err := NewUpdater(&fakeProvider{}, nil, 0); err == nil {
+		t.Error("Expected an error without records")
+	}
+	if _, err := NewUpdater(&fakeProvider{}, []string{"a.example.com"}, -1); err == nil {
+		t.Error("Expected an error for a negative TTL")
+	}
+}
+
+func TestProviders(t *testing.T) {
+	if got := strings.Join(Providers(), ","); got != "cloudflare,duckdns,route53" {
+		t.Errorf("Providers() = %s", got)
+	}
+
+	if _, err := NewProvider("bind", env(nil)); err == nil || !strings.Contains(err.Error(), "cloudflare") {
+		t.Errorf("Expected an unknown provider error listing the available ones, got %v", err)
+	}
+
+	_, err := NewProvider("route53", env(map[string]string{"AWS_ACCESS_KEY_ID": "AKID"}))
+	if err == nil || !strings.Contains(err.Error(), "ROUTE53_HOSTED_ZONE_ID, AWS_SECRET_ACCESS_KEY") {
+		t.Errorf("Expected the missing settings to be named, got %v", err)
+	}
+
+	if _, err := NewProvider("duckdns", env(map[string]string{"DUCKDNS_TOKEN": "t"})); err != nil {
+		t.Errorf("NewProvider(duckdns) error = %v", err)
+	}
+}
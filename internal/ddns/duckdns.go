@@ -0,0 +1,63 @@
+package ddns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// duckDNSAPI is the DuckDNS update endpoint
+const duckDNSAPI = "https://www.duckdns.org/update"
+
+func init() {
+	Register("duckdns", newDuckDNS)
+}
+
+// duckDNS updates duckdns.org subdomains with the account token (DUCKDNS_TOKEN). Records may be
+// given as "home" or "home.duckdns.org".
+type duckDNS struct {
+	api   string
+	token string
+}
+
+func newDuckDNS(getenv func(string) string) (Provider, error) {
+	values, err := required(getenv, "DUCKDNS_TOKEN")
+	if err != nil {
+		return nil, fmt.Errorf("duckdns: %w", err)
+	}
+	return &duckDNS{api: duckDNSAPI, token: values[0]}, nil
+}
+
+// Update sets the subdomain's address of the record's family, answered with "OK" or "KO"
+func (d *duckDNS) Update(ctx context.Context, record Record) error {
+	query := url.Values{
+		"domains": {strings.TrimSuffix(strings.TrimSuffix(record.Name, "."), ".duckdns.org")},
+		"token":   {d.token},
+	}
+	if record.Type == "AAAA" {
+		query.Set("ipv6", record.IP)
+	} else {
+		query.Set("ip", record.IP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.api+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	status, body, err := send(req)
+	if err != nil {
+		// The token is in the URL, so transport errors quoting it are not passed on
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("duckdns: %w", err)
+	}
+	if status != http.StatusOK || !strings.HasPrefix(string(body), "OK") {
+		return fmt.Errorf("duckdns: update rejected with %d %q; check the token and domain", status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package ddns
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDuckDNSUpdate(t *testing.T) {
+	var queries []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query())
+		if r.URL.Query().Get("token") != "token" {
+			io.WriteString(w, "KO")
+			return
+		}
+		io.WriteString(w, "OK")
+	}))
+	defer server.Close()
+
+	d := &duckDNS{api: server.URL, token: "token"}
+	ctx := context.Background()
+	if err := d.Update(ctx, Record{Name: "home.duckdns.org", Type: "A", IP: "203.0.113.7"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := d.Update(ctx, Record{Name: "home", Type: "AAAA", IP: "2001:db8::1"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if queries[0].Get("domains") != "home" || queries[0].Get("ip") != "203.0.113.7" {
+		t.Errorf("Unexpected IPv4 query %v", queries[0])
+	}
+	if queries[1].Get("domains") != "home" || queries[1].Get("ipv6") != "2001:db8::1" || queries[1].Has("ip") {
+		t.Errorf("Unexpected IPv6 query %v", queries[1])
+	}
+
+	d.token = "wrong"
+	err := d.Update(ctx, Record{Name: "home", Type: "A", IP: "203.0.113.7"})
+	if err == nil || !strings.Contains(err.Error(), `"KO"`) {
+		t.Errorf("Expected a rejected update, got %v", err)
+	}
+}
+
+func TestDuckDNSHidesToken(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	d := &duckDNS{api: server.URL, token: "s3cret-token"}
+	err := d.Update(context.Background(), Record{Name: "home", Type: "A", IP: "203.0.113.7"})
+	if err == nil || strings.Contains(err.Error(), "s3cret-token") {
+		t.Errorf("Expected a connection error without the token, got %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package ddns
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Route 53 is a global service signed for us-east-1
+const (
+	route53API     = "https://route53.amazonaws.com"
+	route53Region  = "us-east-1"
+	route53Service = "route53"
+	route53XMLNS   = "https://route53.amazonaws.com/doc/2013-04-01/"
+)
+
+func init() {
+	Register("route53", newRoute53)
+}
+
+// awsCredentials sign requests with Signature Version 4
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// route53 upserts records in a hosted zone (ROUTE53_HOSTED_ZONE_ID) with the standard
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and optional AWS_SESSION_TOKEN credentials
+type route53 struct {
+	api    string
+	zoneID string
+	creds  awsCredentials
+	now    func() time.Time
+}
+
+func newRoute53(getenv func(string) string) (Provider, error) {
+	values, err := required(getenv, "ROUTE53_HOSTED_ZONE_ID", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY")
+	if err != nil {
+		return nil, fmt.Errorf("route53: %w", err)
+	}
+	return &route53{
+		api:    route53API,
+		zoneID: strings.TrimPrefix(values[0], "/hostedzone/"),
+		creds: awsCredentials{
+			accessKeyID:     values[1],
+			secretAccessKey: values[2],
+			sessionToken:    strings.TrimSpace(getenv("AWS_SESSION_TOKEN")),
+		},
+		now: time.Now,
+	}, nil
+}
+
+// changeBatchRequest is the body of ChangeResourceRecordSets
+type changeBatchRequest struct {
+	XMLName xml.Name `xml:"ChangeResourceRecordSetsRequest"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	Comment string   `xml:"ChangeBatch>Comment"`
+	Changes []change `xml:"ChangeBatch>Changes>Change"`
+}
+
+type change struct {
+	Action string   `xml:"Action"`
+	Name   string   `xml:"ResourceRecordSet>Name"`
+	Type   string   `xml:"ResourceRecordSet>Type"`
+	TTL    int      `xml:"ResourceRecordSet>TTL"`
+	Values []string `xml:"ResourceRecordSet>ResourceRecords>ResourceRecord>Value"`
+}
+
+// route53Error is the body of a failed request
+type route53Error struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// Update upserts the record, which replaces any existing addresses
+func (r *route53) Update(ctx context.Context, record Record) error {
+	payload, err := xml.Marshal(changeBatchRequest{
+		XMLNS:   route53XMLNS,
+		Comment: "myip ddns",
+		Changes: []change{{
+			Action: "UPSERT",
+			Name:   record.Name,
+			Type:   record.Type,
+			TTL:    record.TTL,
+			Values: []string{record.IP},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	payload = append([]byte(xml.Header), payload...)
+
+	target := r.api + "/2013-04-01/hostedzone/" + url.PathEscape(r.zoneID) + "/rrset/"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/xml")
+	signV4(req, payload, r.creds, route53Region, route53Service, r.now())
+
+	status, body, err := send(req)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		var resp route53Error
+		if xml.Unmarshal(body, &resp) == nil && resp.Code != "" {
+			return fmt.Errorf("route53: %s: %s", resp.Code, resp.Message)
+		}
+		return fmt.Errorf("route53: ChangeResourceRecordSets answered %d", status)
+	}
+	return nil
+}
+
+// signV4 adds AWS Signature Version 4 headers to req, signing its host, Content-Type, and
+// X-Amz-* headers along with the query and payload
+func signV4(req *http.Request, payload []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.Join(values, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hexSHA256(payload),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.accessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQuery sorts the query by key and value and encodes spaces as %20, as SigV4 requires
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,84 @@
+package ddns
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignV4(t *testing.T) {
+	// The example request from the AWS Signature Version 4 documentation
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds := awsCredentials{accessKeyID: "AKIDEXAMPLE", secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+
+	signV4(req, nil, creds, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %s, want %s", got, want)
+	}
+	if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+		t.Errorf("X-Amz-Date = %s", got)
+	}
+}
+
+func TestRoute53Update(t *testing.T) {
+	var path, auth, token string
+	var body changeBatchRequest
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth, token = r.URL.Path, r.Header.Get("Authorization"), r.Header.Get("X-Amz-Security-Token")
+		data, _ := io.ReadAll(r.Body)
+		xml.Unmarshal(data, &body)
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			io.WriteString(w, `<ErrorResponse><Error><Type>Sender</Type><Code>NoSuchHostedZone</Code><Message>No hosted zone found with ID: Z1</Message></Error></ErrorResponse>`)
+		}
+	}))
+	defer server.Close()
+
+	provider, err := newRoute53(env(map[string]string{
+		"ROUTE53_HOSTED_ZONE_ID": "/hostedzone/Z1",
+		"AWS_ACCESS_KEY_ID":      "AKID",
+		"AWS_SECRET_ACCESS_KEY":  "secret",
+		"AWS_SESSION_TOKEN":      "session",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := provider.(*route53)
+	r.api = server.URL
+
+	record := Record{Name: "home.example.com", Type: "AAAA", IP: "2001:db8::1", TTL: 60}
+	if err := r.Update(context.Background(), record); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if path != "/2013-04-01/hostedzone/Z1/rrset/" {
+		t.Errorf("Path = %s", path)
+	}
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/us-east-1/route53/aws4_request") ||
+		!strings.Contains(auth, "x-amz-security-token") || token != "session" {
+		t.Errorf("Unexpected signature %q with token %q", auth, token)
+	}
+	if len(body.Changes) != 1 {
+		t.Fatalf("Expected one change, got %+v", body)
+	}
+	if got := body.Changes[0]; got.Action != "UPSERT" || got.Name != record.Name || got.Type != "AAAA" || got.TTL != 60 ||
+		len(got.Values) != 1 || got.Values[0] != "2001:db8::1" {
+		t.Errorf("Unexpected change %+v", got)
+	}
+
+	status = http.StatusNotFound
+	err = r.Update(context.Background(), record)
+	if err == nil || !strings.Contains(err.Error(), "NoSuchHostedZone: No hosted zone found") {
+		t.Errorf("Expected the Route 53 error, got %v", err)
+	}
+}
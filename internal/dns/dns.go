@@ -0,0 +1,362 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"myip/internal/breaker"
+	"myip/internal/cache"
+	"myip/internal/ip"
+	"myip/internal/logging"
+	"myip/internal/models"
+	"myip/internal/problem"
+	"myip/internal/ratelimit"
+)
+
+var logger = logging.For("dns")
+
+// Resolver is the subset of net.Resolver used by the handler
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupCNAME(ctx context.Context, host string) (string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// Handler resolves caller-specified hostnames from the server's vantage point
+type Handler struct {
+	resolver  Resolver
+	allowlist []string
+	limiter   *ratelimit.Limiter
+	timeout   time.Duration
+	breaker   *breaker.Breaker
+
+	// ptrCache holds /hostname results for ptrTTL; PTR lookups are not cached while it is nil
+	ptrCache cache.Store
+	ptrTTL   time.Duration
+}
+
+// NewHandler creates a DNS lookup handler.
+// When allowlist is non-empty only hostnames equal to or under one of its domains may be resolved.
+func NewHandler(resolver Resolver, allowlist []string, limiter *ratelimit.Limiter, timeout time.Duration) *Handler {
+	normalized := make([]string, 0, len(allowlist))
+	for _, domain := range allowlist {
+		domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain != "" {
+			normalized = append(normalized, domain)
+		}
+	}
+
+	return &Handler{
+		resolver:  resolver,
+		allowlist: normalized,
+		limiter:   limiter,
+		timeout:   timeout,
+		breaker:   breaker.Get("dns"),
+	}
+}
+
+// CachePTR caches the PTR names found by /hostname in store for ttl. Failed lookups and
+// addresses without PTR records are not cached.
+func (h *Handler) CachePTR(store cache.Store, ttl time.Duration) {
+	h.ptrCache = store
+	h.ptrTTL = ttl
+}
+
+// isValidHostname checks that name is a syntactically valid DNS hostname (not an IP literal)
+func isValidHostname(name string) bool {
+	if len(name) == 0 || len(name) > 253 || ip.IsValid(name) {
+		return false
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// isAllowed checks the hostname against the allowlist
+func (h *Handler) isAllowed(name string) bool {
+	if len(h.allowlist) == 0 {
+		return true
+	}
+
+	for _, domain := range h.allowlist {
+		if name == domain || strings.HasSuffix(name, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP handles /dns requests
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(r.URL.Query().Get("name"))), ".")
+	if name == "" {
+		http.Error(w, "Missing name parameter", http.StatusBadRequest)
+		return
+	}
+
+	if !isValidHostname(name) {
+		http.Error(w, "Invalid hostname", http.StatusBadRequest)
+		return
+	}
+
+	if !h.isAllowed(name) {
+		logger.Debugf("Rejected lookup for %s: not in allowlist", name)
+		http.Error(w, "Hostname not allowed", http.StatusForbidden)
+		return
+	}
+
+	recordType := strings.ToUpper(r.URL.Query().Get("type"))
+	if recordType != "" && recordType != "MX" && recordType != "TXT" {
+		http.Error(w, "Unsupported record type", http.StatusBadRequest)
+		return
+	}
+
+	if h.limiter != nil {
+		clientIP, _ := ip.ExtractClientIP(r)
+		if ok, retryAfter := h.limiter.Allow(clientIP); !ok {
+			problem.Error(w, r, http.StatusTooManyRequests, "Rate limit exceeded",
+				problem.WithRetryAfter(retryAfter),
+				problem.WithRateLimit(h.limiter.Limit(), 0, retryAfter))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	var response *models.DNSResponse
+	err := h.guard(func() (err error) {
+		response, err = h.lookup(ctx, name, recordType)
+		return err
+	})
+	logger.Debugf("Lookup for %s (type %q) took %v, err=%v", name, recordType, time.Since(start), err)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			http.Error(w, "Hostname not found", http.StatusNotFound)
+			return
+		}
+		if suspended(w, r, err) {
+			return
+		}
+		if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+			problem.Error(w, r, http.StatusGatewayTimeout, "DNS lookup exceeded the request budget")
+			return
+		}
+		log.Printf("DNS lookup for %s failed: %v", name, err)
+		problem.Error(w, r, http.StatusBadGateway, "DNS lookup failed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, "Failed to encode JSON response")
+		return
+	}
+}
+
+// lookup resolves the address records for name plus the optional extra record type
+func (h *Handler) lookup(ctx context.Context, name, recordType string) (*models.DNSResponse, error) {
+	addrs, err := h.resolver.LookupIPAddr(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.DNSResponse{
+		Hostname:      name,
+		IPv4Addresses: []string{},
+		IPv6Addresses: []string{},
+		Timestamp:     time.Now().UTC().Format(time.RFC3339),
+	}
+
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			response.IPv4Addresses = append(response.IPv4Addresses, addr.IP.String())
+		} else {
+			response.IPv6Addresses = append(response.IPv6Addresses, addr.IP.String())
+		}
+	}
+
+	// CNAME lookup failures are not fatal; the address records are the primary result
+	if cname, err := h.resolver.LookupCNAME(ctx, name); err == nil {
+		cname = strings.TrimSuffix(cname, ".")
+		if cname != name {
+			response.CNAME = cname
+		}
+	}
+
+	switch recordType {
+	case "MX":
+		records, err := h.resolver.LookupMX(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("MX lookup: %w", err)
+		}
+		for _, mx := range records {
+			response.MX = append(response.MX, fmt.Sprintf("%d %s", mx.Pref, strings.TrimSuffix(mx.Host, ".")))
+		}
+	case "TXT":
+		records, err := h.resolver.LookupTXT(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("TXT lookup: %w", err)
+		}
+		response.TXT = records
+	}
+
+	return response, nil
+}
+
+// Hostname handles /hostname requests, reverse-resolving the client IP
+func (h *Handler) Hostname(w http.ResponseWriter, r *http.Request) {
+	clientIP, _ := ip.ExtractClientIP(r)
+
+	if h.limiter != nil {
+		if ok, retryAfter := h.limiter.Allow(clientIP); !ok {
+			problem.Error(w, r, http.StatusTooManyRequests, "Rate limit exceeded",
+				problem.WithRetryAfter(retryAfter),
+				problem.WithRateLimit(h.limiter.Limit(), 0, retryAfter))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	names, err := h.lookupPTR(ctx, clientIP)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			http.Error(w, "No PTR record for "+clientIP, http.StatusNotFound)
+			return
+		}
+		if suspended(w, r, err) {
+			return
+		}
+		if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+			problem.Error(w, r, http.StatusGatewayTimeout, "DNS lookup exceeded the request budget")
+			return
+		}
+		log.Printf("PTR lookup for %s failed: %v", clientIP, err)
+		problem.Error(w, r, http.StatusBadGateway, "DNS lookup failed")
+		return
+	}
+	if len(names) == 0 {
+		http.Error(w, "No PTR record for "+clientIP, http.StatusNotFound)
+		return
+	}
+
+	response := describeHostname(clientIP, names)
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, "Failed to encode JSON response")
+		return
+	}
+}
+
+// lookupPTR returns the PTR names of addr, from the cache when possible
+func (h *Handler) lookupPTR(ctx context.Context, addr string) ([]string, error) {
+	key := "ptr:" + addr
+	var names []string
+	if h.ptrCache != nil && cache.GetJSON(ctx, h.ptrCache, key, &names) {
+		logger.Debugf("PTR cache hit for %s", addr)
+		return names, nil
+	}
+
+	err := h.guard(func() (err error) {
+		names, err = h.resolver.LookupAddr(ctx, addr)
+		return err
+	})
+	logger.Debugf("PTR lookup for %s returned %v, err=%v", addr, names, err)
+	if err == nil && len(names) > 0 && h.ptrCache != nil && h.ptrTTL > 0 {
+		cache.SetJSON(ctx, h.ptrCache, key, names, h.ptrTTL)
+	}
+	return names, err
+}
+
+// guard calls fn through the resolver's circuit breaker. Names that do not exist are answers
+// from a healthy resolver and do not count as failures.
+func (h *Handler) guard(fn func() error) error {
+	if err := h.breaker.Allow(); err != nil {
+		return err
+	}
+	err := fn()
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		h.breaker.Done(nil)
+	} else {
+		h.breaker.Done(err)
+	}
+	return err
+}
+
+// suspended writes a 503 response when err comes from an open circuit breaker
+func suspended(w http.ResponseWriter, r *http.Request, err error) bool {
+	var open *breaker.OpenError
+	if !errors.As(err, &open) {
+		return false
+	}
+	problem.Error(w, r, http.StatusServiceUnavailable, "DNS lookups suspended after repeated failures",
+		problem.WithRetryAfter(open.RetryAfter))
+	return true
+}
+
+// describeHostname builds the /hostname response for the PTR names of clientIP
+func describeHostname(clientIP string, names []string) *models.HostnameResponse {
+	for i, name := range names {
+		names[i] = strings.TrimSuffix(strings.ToLower(name), ".")
+	}
+
+	name := names[0]
+	response := &models.HostnameResponse{
+		IP:        clientIP,
+		Hostname:  name,
+		Unicode:   name,
+		Display:   name,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	if len(names) > 1 {
+		response.Aliases = names[1:]
+	}
+
+	unicodeName, idn, err := toUnicode(name)
+	response.IsIDN = idn
+	response.Valid = err == nil && isValidHostname(name)
+	if err != nil {
+		// An undecodable label is shown as received; the ASCII form is always safe to display
+		response.SafeDisplay = true
+		return response
+	}
+
+	response.Unicode = unicodeName
+	response.SafeDisplay = isSafeDisplay(unicodeName)
+	if response.SafeDisplay {
+		response.Display = unicodeName
+	}
+	return response
+}
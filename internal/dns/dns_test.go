@@ -0,0 +1,380 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"myip/internal/breaker"
+	"myip/internal/cache"
+	"myip/internal/models"
+	"myip/internal/ratelimit"
+)
+
+// fakeResolver returns canned records for testing
+type fakeResolver struct {
+	addrs []net.IPAddr
+	cname string
+	mx    []*net.MX
+	txt   []string
+	ptr   []string
+	err   error
+}
+
+func (f *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return f.addrs, f.err
+}
+
+func (f *fakeResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	return f.cname, nil
+}
+
+func (f *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return f.mx, nil
+}
+
+func (f *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return f.txt, nil
+}
+
+func (f *fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	return f.ptr, f.err
+}
+
+func newFakeResolver() *fakeResolver {
+	return &fakeResolver{
+		addrs: []net.IPAddr{
+			{IP: net.ParseIP("203.0.113.10")},
+			{IP: net.ParseIP("2001:db8::10")},
+		},
+		cname: "edge.example.net.",
+		mx:    []*net.MX{{Host: "mail.example.com.", Pref: 10}},
+		txt:   []string{"v=spf1 -all"},
+	}
+}
+
+func TestIsValidHostname(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"example.com", true},
+		{"sub-domain.example.com", true},
+		{"_dmarc.example.com", true},
+		{"localhost", true},
+		{"", false},
+		{"-bad.example.com", false},
+		{"bad-.example.com", false},
+		{"double..dot.com", false},
+		{"spaces in.com", false},
+		{"203.0.113.1", false},
+		{"2001:db8::1", false},
+	}
+
+	for _, test := range tests {
+		if result := isValidHostname(test.name); result != test.expected {
+			t.Errorf("isValidHostname(%q) = %t, expected %t", test.name, result, test.expected)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		allowlist    []string
+		expectedCode int
+	}{
+		{"Missing name", "", nil, http.StatusBadRequest},
+		{"Invalid name", "?name=-bad-", nil, http.StatusBadRequest},
+		{"IP literal", "?name=203.0.113.1", nil, http.StatusBadRequest},
+		{"Unsupported type", "?name=example.com&type=SRV", nil, http.StatusBadRequest},
+		{"Not allowlisted", "?name=example.org", []string{"example.com"}, http.StatusForbidden},
+		{"Suffix trick not allowlisted", "?name=badexample.com", []string{"example.com"}, http.StatusForbidden},
+		{"Allowlisted subdomain", "?name=www.example.com", []string{"Example.com."}, http.StatusOK},
+		{"No allowlist", "?name=example.org", nil, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := NewHandler(newFakeResolver(), test.allowlist, nil, time.Second)
+
+			req := httptest.NewRequest("GET", "/dns"+test.query, nil)
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != test.expectedCode {
+				t.Errorf("Expected status %d, got %d", test.expectedCode, rr.Code)
+			}
+		})
+	}
+}
+
+func TestHandlerResponse(t *testing.T) {
+	h := NewHandler(newFakeResolver(), nil, nil, time.Second)
+
+	req := httptest.NewRequest("GET", "/dns?name=Example.com.&type=mx", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %s", contentType)
+	}
+
+	var response models.DNSResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+
+	if response.Hostname != "example.com" {
+		t.Errorf("Expected normalized hostname example.com, got %s", response.Hostname)
+	}
+	if len(response.IPv4Addresses) != 1 || response.IPv4Addresses[0] != "203.0.113.10" {
+		t.Errorf("Unexpected IPv4 addresses: %v", response.IPv4Addresses)
+	}
+	if len(response.IPv6Addresses) != 1 || response.IPv6Addresses[0] != "2001:db8::10" {
+		t.Errorf("Unexpected IPv6 addresses: %v", response.IPv6Addresses)
+	}
+	if response.CNAME != "edge.example.net" {
+		t.Errorf("Expected CNAME edge.example.net, got %s", response.CNAME)
+	}
+	if len(response.MX) != 1 || response.MX[0] != "10 mail.example.com" {
+		t.Errorf("Unexpected MX records: %v", response.MX)
+	}
+	if len(response.TXT) != 0 {
+		t.Errorf("Expected no TXT records unless requested, got %v", response.TXT)
+	}
+}
+
+func TestHandlerLookupErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		expectedCode int
+	}{
+		{"Not found", &net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true}, http.StatusNotFound},
+		{"Server failure", errors.New("server misbehaving"), http.StatusBadGateway},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolver := newFakeResolver()
+			resolver.err = test.err
+			h := NewHandler(resolver, nil, nil, time.Second)
+
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest("GET", "/dns?name=example.com", nil))
+
+			if rr.Code != test.expectedCode {
+				t.Errorf("Expected status %d, got %d", test.expectedCode, rr.Code)
+			}
+		})
+	}
+}
+
+func TestHandlerRequestBudget(t *testing.T) {
+	resolver := newFakeResolver()
+	resolver.err = context.DeadlineExceeded
+	h := NewHandler(resolver, nil, nil, time.Second)
+
+	// The request's own deadline, as set by the timeout middleware, has passed
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/dns?name=example.com", nil).WithContext(ctx))
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected 504 once the request budget is spent, got %d", rr.Code)
+	}
+
+	// DNS_TIMEOUT expiring within the budget is an upstream failure
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/dns?name=example.com", nil))
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502 for a lookup timing out on its own, got %d", rr.Code)
+	}
+}
+
+func TestHandlerRateLimit(t *testing.T) {
+	h := NewHandler(newFakeResolver(), nil, ratelimit.New(1, time.Minute), time.Second)
+
+	for i, expectedCode := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest("GET", "/dns?name=example.com", nil)
+		req.RemoteAddr = "203.0.113.1:12345"
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		if rr.Code != expectedCode {
+			t.Errorf("Request %d: expected status %d, got %d", i+1, expectedCode, rr.Code)
+		}
+
+		if rr.Code == http.StatusTooManyRequests {
+			if rr.Header().Get("Retry-After") == "" {
+				t.Error("Expected Retry-After header on rate limited response")
+			}
+			if rr.Header().Get("RateLimit-Limit") != "1" || rr.Header().Get("RateLimit-Remaining") != "0" {
+				t.Errorf("Expected RateLimit headers, got %v", rr.Header())
+			}
+		}
+	}
+}
+
+func TestHostname(t *testing.T) {
+	tests := []struct {
+		name     string
+		ptr      []string
+		expected models.HostnameResponse
+	}{
+		{
+			name: "ASCII name",
+			ptr:  []string{"Host-10.Example.net."},
+			expected: models.HostnameResponse{Hostname: "host-10.example.net", Unicode: "host-10.example.net",
+				Display: "host-10.example.net", Valid: true, SafeDisplay: true},
+		},
+		{
+			name: "IDN",
+			ptr:  []string{"xn--mnchen-3ya.example.de.", "alias.example.de."},
+			expected: models.HostnameResponse{Hostname: "xn--mnchen-3ya.example.de", Unicode: "münchen.example.de",
+				Display: "münchen.example.de", IsIDN: true, Valid: true, SafeDisplay: true,
+				Aliases: []string{"alias.example.de"}},
+		},
+		{
+			name: "Mixed-script IDN",
+			ptr:  []string{"xn--pple-43d.example.com."},
+			expected: models.HostnameResponse{Hostname: "xn--pple-43d.example.com", Unicode: "аpple.example.com",
+				Display: "xn--pple-43d.example.com", IsIDN: true, Valid: true},
+		},
+		{
+			name: "Undecodable label",
+			ptr:  []string{"xn--mnchen-3y.example.de."},
+			expected: models.HostnameResponse{Hostname: "xn--mnchen-3y.example.de", Unicode: "xn--mnchen-3y.example.de",
+				Display: "xn--mnchen-3y.example.de", SafeDisplay: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolver := newFakeResolver()
+			resolver.ptr = test.ptr
+			h := NewHandler(resolver, nil, nil, time.Second)
+
+			req := httptest.NewRequest("GET", "/hostname", nil)
+			req.RemoteAddr = "203.0.113.10:12345"
+			rr := httptest.NewRecorder()
+			h.Hostname(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", rr.Code)
+			}
+
+			var response models.HostnameResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			test.expected.IP = "203.0.113.10"
+			test.expected.Timestamp = response.Timestamp
+			if !reflect.DeepEqual(response, test.expected) {
+				t.Errorf("Expected %+v, got %+v", test.expected, response)
+			}
+		})
+	}
+}
+
+func TestHostnameCache(t *testing.T) {
+	resolver := newFakeResolver()
+	resolver.ptr = []string{"Host-10.Example.net."}
+	h := NewHandler(resolver, nil, nil, time.Second)
+	h.CachePTR(cache.NewMemory(10), time.Hour)
+
+	hostname := func() string {
+		req := httptest.NewRequest("GET", "/hostname", nil)
+		req.RemoteAddr = "203.0.113.10:12345"
+		rr := httptest.NewRecorder()
+		h.Hostname(rr, req)
+
+		var response models.HostnameResponse
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return response.Hostname
+	}
+
+	if got := hostname(); got != "host-10.example.net" {
+		t.Fatalf("Expected host-10.example.net, got %q", got)
+	}
+
+	// The cached names are served without asking the resolver again
+	resolver.ptr = []string{"changed.example.net."}
+	if got := hostname(); got != "host-10.example.net" {
+		t.Errorf("Expected the cached name, got %q", got)
+	}
+}
+
+func TestHostnameLookupErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		ptr          []string
+		err          error
+		expectedCode int
+	}{
+		{"Not found", nil, &net.DNSError{Err: "no such host", Name: "203.0.113.10", IsNotFound: true}, http.StatusNotFound},
+		{"No records", nil, nil, http.StatusNotFound},
+		{"Server failure", nil, errors.New("server misbehaving"), http.StatusBadGateway},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolver := newFakeResolver()
+			resolver.ptr = test.ptr
+			resolver.err = test.err
+			h := NewHandler(resolver, nil, nil, time.Second)
+
+			rr := httptest.NewRecorder()
+			h.Hostname(rr, httptest.NewRequest("GET", "/hostname", nil))
+
+			if rr.Code != test.expectedCode {
+				t.Errorf("Expected status %d, got %d", test.expectedCode, rr.Code)
+			}
+		})
+	}
+}
+
+func TestHandlerBreaker(t *testing.T) {
+	defer breaker.Configure(breaker.Settings{})
+	breaker.Configure(breaker.Settings{Threshold: 2, Backoff: time.Minute, MaxBackoff: time.Minute})
+
+	resolver := newFakeResolver()
+	h := NewHandler(resolver, nil, nil, time.Second)
+	h.breaker = breaker.Get("dns-test")
+
+	// Missing names are answers and never open the breaker
+	resolver.err = &net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true}
+	for range 3 {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/dns?name=example.com", nil))
+	}
+
+	resolver.err = errors.New("server misbehaving")
+	expected := []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusServiceUnavailable}
+	for i, code := range expected {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/dns?name=example.com", nil))
+		if rr.Code != code {
+			t.Errorf("Request %d: expected status %d, got %d", i+1, code, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	h.Hostname(rr, httptest.NewRequest("GET", "/hostname", nil))
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected /hostname to be suspended with Retry-After, got %d %v", rr.Code, rr.Header())
+	}
+}
@@ -0,0 +1,193 @@
+package dns
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// acePrefix marks a label holding a punycode-encoded internationalized name (RFC 5890)
+const acePrefix = "xn--"
+
+// Punycode parameters (RFC 3492 section 5)
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+var errInvalidPunycode = errors.New("invalid punycode")
+
+// decodePunycode decodes a punycode string without its ACE prefix (RFC 3492 section 6.2)
+func decodePunycode(encoded string) (string, error) {
+	var output []rune
+	pos := 0
+	if i := strings.LastIndexByte(encoded, '-'); i >= 0 {
+		for _, c := range encoded[:i] {
+			if c >= utf8.RuneSelf {
+				return "", errInvalidPunycode
+			}
+			output = append(output, c)
+		}
+		pos = i + 1
+	}
+
+	n, bias, i := punyInitialN, punyInitialBias, 0
+	for pos < len(encoded) {
+		oldi, w := i, 1
+		for k := punyBase; ; k += punyBase {
+			if pos >= len(encoded) {
+				return "", errInvalidPunycode
+			}
+			digit, ok := punyDigit(encoded[pos])
+			pos++
+			if !ok || digit > (math.MaxInt32-i)/w {
+				return "", errInvalidPunycode
+			}
+			i += digit * w
+
+			t := min(max(k-bias, punyTMin), punyTMax)
+			if digit < t {
+				break
+			}
+			if w > math.MaxInt32/(punyBase-t) {
+				return "", errInvalidPunycode
+			}
+			w *= punyBase - t
+		}
+
+		points := len(output) + 1
+		bias = punyAdapt(i-oldi, points, oldi == 0)
+		if i/points > math.MaxInt32-n {
+			return "", errInvalidPunycode
+		}
+		n += i / points
+		i %= points
+		if n > utf8.MaxRune || !utf8.ValidRune(rune(n)) {
+			return "", errInvalidPunycode
+		}
+
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = rune(n)
+		i++
+	}
+	return string(output), nil
+}
+
+// punyDigit returns the value of a punycode digit; letters are case-insensitive
+func punyDigit(c byte) (int, bool) {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return int(c - 'a'), true
+	case c >= 'A' && c <= 'Z':
+		return int(c - 'A'), true
+	case c >= '0' && c <= '9':
+		return int(c-'0') + 26, true
+	}
+	return 0, false
+}
+
+// punyAdapt is the bias adaptation function (RFC 3492 section 6.1)
+func punyAdapt(delta, points int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / points
+
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+// toUnicode converts the ACE labels of an ASCII hostname to Unicode. It reports whether any label
+// was internationalized, and fails when one does not decode to a non-ASCII name.
+func toUnicode(host string) (string, bool, error) {
+	labels := strings.Split(host, ".")
+	idn := false
+	for i, label := range labels {
+		if !strings.HasPrefix(label, acePrefix) {
+			continue
+		}
+		decoded, err := decodePunycode(label[len(acePrefix):])
+		if err != nil {
+			return host, idn, err
+		}
+		if isASCII(decoded) {
+			return host, idn, errInvalidPunycode
+		}
+		labels[i] = decoded
+		idn = true
+	}
+	return strings.Join(labels, "."), idn, nil
+}
+
+// isASCII reports whether s contains only ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// hanScripts are the East Asian scripts routinely mixed within one word; they count as a single script
+var hanScripts = []*unicode.RangeTable{unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Bopomofo}
+
+// safeDisplayScripts are the scripts checked by isSafeDisplay; letters outside them are
+// not displayed in Unicode
+var safeDisplayScripts = map[string]*unicode.RangeTable{
+	"Latin": unicode.Latin, "Greek": unicode.Greek, "Cyrillic": unicode.Cyrillic,
+	"Armenian": unicode.Armenian, "Georgian": unicode.Georgian, "Hebrew": unicode.Hebrew,
+	"Arabic": unicode.Arabic, "Devanagari": unicode.Devanagari, "Bengali": unicode.Bengali,
+	"Tamil": unicode.Tamil, "Thai": unicode.Thai, "Lao": unicode.Lao, "Ethiopic": unicode.Ethiopic,
+}
+
+// isSafeDisplay reports whether a decoded hostname can be shown in Unicode without risk of
+// spoofing: no invisible, control, or bidirectional formatting characters, and no label mixing
+// letters from several scripts, the usual form of homograph attacks such as a Cyrillic "а"
+// in an otherwise Latin name.
+func isSafeDisplay(host string) bool {
+	for _, label := range strings.Split(host, ".") {
+		script := ""
+		for _, r := range label {
+			if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) || unicode.IsSpace(r) {
+				return false
+			}
+			if !unicode.IsLetter(r) {
+				continue
+			}
+			current := letterScript(r)
+			if current == "" || (script != "" && current != script) {
+				return false
+			}
+			script = current
+		}
+	}
+	return true
+}
+
+// letterScript names the script of a letter for isSafeDisplay, or "" when it is not recognized
+func letterScript(r rune) string {
+	if unicode.In(r, hanScripts...) {
+		return "Han"
+	}
+	for name, table := range safeDisplayScripts {
+		if unicode.Is(table, r) {
+			return name
+		}
+	}
+	return ""
+}
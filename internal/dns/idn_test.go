@@ -0,0 +1,76 @@
+package dns
+
+import "testing"
+
+func TestDecodePunycode(t *testing.T) {
+	tests := []struct {
+		encoded  string
+		expected string
+	}{
+		{"mnchen-3ya", "münchen"},
+		{"bcher-kva", "bücher"},
+		{"80ak6aa92e", "аррӏе"},
+		{"r8jz45g", "例え"},
+		{"MNCHEN-3YA", "MüNCHEN"},
+	}
+
+	for _, test := range tests {
+		decoded, err := decodePunycode(test.encoded)
+		if err != nil {
+			t.Errorf("decodePunycode(%q) failed: %v", test.encoded, err)
+			continue
+		}
+		if decoded != test.expected {
+			t.Errorf("decodePunycode(%q) = %q, expected %q", test.encoded, decoded, test.expected)
+		}
+	}
+
+	for _, invalid := range []string{"mnchen-3y", "abc-!!", "99999999999"} {
+		if _, err := decodePunycode(invalid); err == nil {
+			t.Errorf("Expected decodePunycode(%q) to fail", invalid)
+		}
+	}
+}
+
+func TestToUnicode(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+		idn      bool
+		valid    bool
+	}{
+		{"host.example.com", "host.example.com", false, true},
+		{"xn--mnchen-3ya.example.de", "münchen.example.de", true, true},
+		{"xn--abc-.example.com", "xn--abc-.example.com", false, false},
+		{"xn--mnchen-3y.example.de", "xn--mnchen-3y.example.de", false, false},
+	}
+
+	for _, test := range tests {
+		host, idn, err := toUnicode(test.host)
+		if host != test.expected || idn != test.idn || (err == nil) != test.valid {
+			t.Errorf("toUnicode(%q) = %q, %t, %v; expected %q, %t, valid=%t",
+				test.host, host, idn, err, test.expected, test.idn, test.valid)
+		}
+	}
+}
+
+func TestIsSafeDisplay(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected bool
+	}{
+		{"host.example.com", true},
+		{"münchen.example.de", true},
+		{"аррӏе.example.com", true},
+		{"例え.jp", true},
+		{"аpple.example.com", false},
+		{"zero\u200bwidth.example.com", false},
+		{"right\u202eleft.example.com", false},
+	}
+
+	for _, test := range tests {
+		if result := isSafeDisplay(test.host); result != test.expected {
+			t.Errorf("isSafeDisplay(%q) = %t, expected %t", test.host, result, test.expected)
+		}
+	}
+}
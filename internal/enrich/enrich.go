@@ -0,0 +1,274 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"myip/internal/logging"
+	"myip/internal/models"
+	"myip/internal/problem"
+)
+
+// Degradation policies applied when a provider lookup fails
+const (
+	// PolicyOmit leaves the provider's section out of the response
+	PolicyOmit = "omit"
+	// PolicyStale serves the last successful result for the IP, omitting the section when there is none
+	PolicyStale = "stale"
+	// PolicyFail fails the whole request with 503 Service Unavailable
+	PolicyFail = "fail"
+)
+
+// Section states reported in response meta
+const (
+	StateOK      = "ok"
+	StateStale   = "stale"
+	StateOmitted = "omitted"
+)
+
+// maxStaleEntries bounds the per-provider cache of results kept for PolicyStale
+const maxStaleEntries = 10000
+
+// ErrDegraded is returned by Enrich when a provider with PolicyFail could not be consulted
+var ErrDegraded = errors.New("required enrichment provider unavailable")
+
+var logger = logging.For("enrich")
+
+// Provider looks up additional information about an IP address from an external source
+type Provider interface {
+	Name() string
+	Lookup(ctx context.Context, ip string) (any, error)
+}
+
+// cached is a previous successful lookup result
+type cached struct {
+	value any
+	at    time.Time
+}
+
+// provider tracks a registered provider and its recent health
+type provider struct {
+	Provider
+	policy string
+
+	mu          sync.Mutex
+	stale       map[string]cached
+	lastError   string
+	lastFailure time.Time
+	lastSuccess time.Time
+	failing     bool
+}
+
+// Enricher consults the registered providers and applies each provider's degradation policy
+type Enricher struct {
+	mu        sync.RWMutex
+	providers []*provider
+	policies  map[string]string
+	timeout   time.Duration
+	staleTTL  time.Duration
+	now       func() time.Time
+
+	// proxyRanges reports the refresh state of the proxy ranges in Status when set
+	proxyRanges func() []models.ProxyRangesStatus
+}
+
+// ParsePolicies parses "name=policy" entries such as "geo=stale" into a policy per provider
+func ParsePolicies(entries []string) (map[string]string, error) {
+	policies := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		name, policy, ok := strings.Cut(entry, "=")
+		name, policy = strings.TrimSpace(name), strings.ToLower(strings.TrimSpace(policy))
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid enrichment policy %q, expected name=policy", entry)
+		}
+
+		switch policy {
+		case PolicyOmit, PolicyStale, PolicyFail:
+			policies[name] = policy
+		default:
+			return nil, fmt.Errorf("unknown enrichment policy %q for %s (valid: omit, stale, fail)", policy, name)
+		}
+	}
+	return policies, nil
+}
+
+// New creates an enricher; providers default to PolicyOmit unless listed in policies.
+// Each lookup is bounded by timeout, and stale results older than staleTTL are discarded.
+func New(policies map[string]string, timeout, staleTTL time.Duration) *Enricher {
+	return &Enricher{
+		policies: policies,
+		timeout:  timeout,
+		staleTTL: staleTTL,
+		now:      time.Now,
+	}
+}
+
+// Register adds a provider to every subsequent enrichment
+func (e *Enricher) Register(p Provider) {
+	policy, ok := e.policies[p.Name()]
+	if !ok {
+		policy = PolicyOmit
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.providers = append(e.providers, &provider{Provider: p, policy: policy, stale: make(map[string]cached)})
+}
+
+// Enrich looks up ip with every provider concurrently and returns the sections to include in
+// the response along with meta describing any degradation. ErrDegraded is returned when a
+// provider with PolicyFail fails.
+func (e *Enricher) Enrich(ctx context.Context, ip string) (map[string]any, *models.EnrichmentMeta, error) {
+	e.mu.RLock()
+	providers := e.providers
+	e.mu.RUnlock()
+
+	meta := &models.EnrichmentMeta{Sections: make([]models.EnrichmentSection, len(providers))}
+	values := make([]any, len(providers))
+
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			values[i], meta.Sections[i] = e.lookup(ctx, p, ip)
+		}()
+	}
+	wg.Wait()
+
+	sections := make(map[string]any, len(providers))
+	var err error
+	for i, section := range meta.Sections {
+		switch {
+		case section.State == StateOmitted && section.Policy == PolicyFail:
+			err = ErrDegraded
+			meta.Degraded = true
+		case section.State != StateOK:
+			meta.Degraded = true
+		}
+		if values[i] != nil {
+			sections[section.Name] = values[i]
+		}
+	}
+	return sections, meta, err
+}
+
+// lookup consults a single provider and applies its policy on failure
+func (e *Enricher) lookup(ctx context.Context, p *provider, ip string) (any, models.EnrichmentSection) {
+	section := models.EnrichmentSection{Name: p.Name(), Policy: p.policy, State: StateOK}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, e.timeout)
+	value, err := p.Lookup(lookupCtx, ip)
+	cancel()
+
+	now := e.now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err == nil {
+		p.failing = false
+		p.lastSuccess = now
+		if p.policy == PolicyStale {
+			if len(p.stale) >= maxStaleEntries {
+				for key := range p.stale {
+					delete(p.stale, key)
+					break
+				}
+			}
+			p.stale[ip] = cached{value: value, at: now}
+		}
+		return value, section
+	}
+
+	logger.Debugf("Provider %s failed for %s: %v", p.Name(), ip, err)
+	p.failing = true
+	p.lastFailure = now
+	p.lastError = err.Error()
+
+	if p.policy == PolicyStale {
+		if entry, ok := p.stale[ip]; ok && now.Sub(entry.at) <= e.staleTTL {
+			section.State = StateStale
+			section.AgeSeconds = int64(now.Sub(entry.at).Seconds())
+			return entry.value, section
+		}
+	}
+
+	section.State = StateOmitted
+	return nil, section
+}
+
+// SetProxyRanges adds the refresh state of the proxy ranges to Status; nil removes it
+func (e *Enricher) SetProxyRanges(status func() []models.ProxyRangesStatus) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.proxyRanges = status
+}
+
+// Status reports the health of every provider. The service is ready unless a provider
+// with PolicyFail is currently failing.
+func (e *Enricher) Status() *models.ReadinessStatus {
+	e.mu.RLock()
+	providers, proxyRanges := e.providers, e.proxyRanges
+	e.mu.RUnlock()
+
+	status := &models.ReadinessStatus{
+		Status:    "ready",
+		Providers: make([]models.ProviderStatus, 0, len(providers)),
+		Timestamp: e.now().UTC().Format(time.RFC3339),
+	}
+	if proxyRanges != nil {
+		status.ProxyRanges = proxyRanges()
+	}
+
+	for _, p := range providers {
+		p.mu.Lock()
+		state := models.ProviderStatus{Name: p.Name(), Policy: p.policy, State: "healthy"}
+		if p.failing {
+			state.State = "degraded"
+			state.LastError = p.lastError
+			state.LastFailure = p.lastFailure.UTC().Format(time.RFC3339)
+			if p.policy == PolicyFail {
+				status.Status = "not ready"
+			}
+		}
+		p.mu.Unlock()
+
+		status.Providers = append(status.Providers, state)
+	}
+
+	sort.Slice(status.Providers, func(i, j int) bool {
+		return status.Providers[i].Name < status.Providers[j].Name
+	})
+	return status
+}
+
+// ReadyHandler serves the readiness probe with the degradation state of every provider
+func (e *Enricher) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	status := e.Status()
+
+	if status.Status != "ready" {
+		var failing []string
+		for _, p := range status.Providers {
+			if p.State == "degraded" && p.Policy == PolicyFail {
+				failing = append(failing, p.Name)
+			}
+		}
+		problem.Error(w, r, http.StatusServiceUnavailable,
+			"Required enrichment provider unavailable: "+strings.Join(failing, ", "))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, "Failed to encode readiness status")
+		return
+	}
+}
@@ -0,0 +1,172 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"myip/internal/models"
+)
+
+// fakeProvider returns value, or err when set
+type fakeProvider struct {
+	name  string
+	value any
+	err   error
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) Lookup(ctx context.Context, ip string) (any, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.value, nil
+}
+
+func section(meta *models.EnrichmentMeta, name string) models.EnrichmentSection {
+	for _, s := range meta.Sections {
+		if s.Name == name {
+			return s
+		}
+	}
+	return models.EnrichmentSection{}
+}
+
+func TestParsePolicies(t *testing.T) {
+	policies, err := ParsePolicies([]string{"geo=stale", " rdap = FAIL "})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if policies["geo"] != PolicyStale || policies["rdap"] != PolicyFail {
+		t.Errorf("Unexpected policies %v", policies)
+	}
+
+	for _, invalid := range []string{"geo", "=omit", "geo=ignore"} {
+		if _, err := ParsePolicies([]string{invalid}); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestEnrichPolicies(t *testing.T) {
+	geo := &fakeProvider{name: "geo", value: "NL"}
+	asn := &fakeProvider{name: "asn", value: 64496}
+	rdap := &fakeProvider{name: "rdap", value: "EXAMPLE-NET"}
+
+	e := New(map[string]string{"geo": PolicyStale, "rdap": PolicyFail}, time.Second, time.Hour)
+	e.Register(geo)
+	e.Register(asn)
+	e.Register(rdap)
+
+	sections, meta, err := e.Enrich(context.Background(), "203.0.113.1")
+	if err != nil || meta.Degraded || len(sections) != 3 {
+		t.Fatalf("Expected healthy enrichment, got %v %+v %v", sections, meta, err)
+	}
+
+	// Stale keeps serving the previous result; omit drops the section
+	geo.err = errors.New("timeout")
+	asn.err = errors.New("timeout")
+
+	sections, meta, err = e.Enrich(context.Background(), "203.0.113.1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !meta.Degraded {
+		t.Error("Expected degraded meta")
+	}
+	if sections["geo"] != "NL" || section(meta, "geo").State != StateStale {
+		t.Errorf("Expected stale geo section, got %v %+v", sections["geo"], section(meta, "geo"))
+	}
+	if _, ok := sections["asn"]; ok || section(meta, "asn").State != StateOmitted {
+		t.Errorf("Expected omitted asn section, got %+v", section(meta, "asn"))
+	}
+
+	// Nothing stale for an IP that was never looked up
+	if sections, _, _ := e.Enrich(context.Background(), "198.51.100.1"); sections["geo"] != nil {
+		t.Errorf("Expected no stale geo for new IP, got %v", sections["geo"])
+	}
+
+	// Fail fails the request
+	rdap.err = errors.New("registry down")
+	if _, _, err := e.Enrich(context.Background(), "203.0.113.1"); !errors.Is(err, ErrDegraded) {
+		t.Errorf("Expected ErrDegraded, got %v", err)
+	}
+}
+
+func TestStaleTTL(t *testing.T) {
+	geo := &fakeProvider{name: "geo", value: "NL"}
+	e := New(map[string]string{"geo": PolicyStale}, time.Second, time.Minute)
+	e.Register(geo)
+
+	now := time.Now()
+	e.now = func() time.Time { return now }
+	e.Enrich(context.Background(), "203.0.113.1")
+
+	geo.err = errors.New("timeout")
+	now = now.Add(2 * time.Minute)
+
+	sections, meta, _ := e.Enrich(context.Background(), "203.0.113.1")
+	if sections["geo"] != nil || section(meta, "geo").State != StateOmitted {
+		t.Errorf("Expected expired stale value to be omitted, got %v", sections["geo"])
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	geo := &fakeProvider{name: "geo", value: "NL"}
+	rdap := &fakeProvider{name: "rdap", value: "EXAMPLE-NET"}
+	e := New(map[string]string{"rdap": PolicyFail}, time.Second, time.Hour)
+	e.Register(geo)
+	e.Register(rdap)
+
+	// Degraded omit-policy providers do not affect readiness
+	geo.err = errors.New("timeout")
+	e.Enrich(context.Background(), "203.0.113.1")
+
+	rr := httptest.NewRecorder()
+	e.ReadyHandler(rr, httptest.NewRequest("GET", "/readyz", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+
+	var status models.ReadinessStatus
+	if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode readiness status: %v", err)
+	}
+	if len(status.Providers) != 2 || status.Providers[0].Name != "geo" || status.Providers[0].State != "degraded" {
+		t.Errorf("Expected degraded geo provider, got %+v", status.Providers)
+	}
+	if status.ProxyRanges != nil {
+		t.Errorf("Expected no proxy range status while unset, got %+v", status.ProxyRanges)
+	}
+
+	e.SetProxyRanges(func() []models.ProxyRangesStatus {
+		return []models.ProxyRangesStatus{{Profile: "cloudfront", Source: "bundled", LastError: "timeout"}}
+	})
+	if status := e.Status(); len(status.ProxyRanges) != 1 || status.ProxyRanges[0].Profile != "cloudfront" || status.Status != "ready" {
+		t.Errorf("Expected the proxy range status without affecting readiness, got %+v", status)
+	}
+
+	rdap.err = errors.New("registry down")
+	e.Enrich(context.Background(), "203.0.113.1")
+
+	rr = httptest.NewRecorder()
+	e.ReadyHandler(rr, httptest.NewRequest("GET", "/readyz", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 when a fail-policy provider is down, got %d", rr.Code)
+	}
+
+	// Recovery restores readiness
+	rdap.err = nil
+	e.Enrich(context.Background(), "203.0.113.1")
+
+	rr = httptest.NewRecorder()
+	e.ReadyHandler(rr, httptest.NewRequest("GET", "/readyz", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 after recovery, got %d", rr.Code)
+	}
+}
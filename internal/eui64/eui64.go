@@ -0,0 +1,114 @@
+// Package eui64 analyzes the interface identifier of IPv6 addresses: whether it was derived from
+// the interface's MAC address (modified EUI-64), and so identifies the device across networks, or
+// is randomized as with privacy extensions.
+package eui64
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"myip/internal/ip"
+	"myip/internal/models"
+)
+
+// Interface identifier types reported in IPv6Analysis.InterfaceIDType
+const (
+	// TypeEUI64 identifiers embed the interface's MAC address with ff:fe in the middle (RFC 4291)
+	TypeEUI64 = "eui-64"
+	// TypeISATAP identifiers embed an IPv4 address after 0000:5efe or 0200:5efe (RFC 5214)
+	TypeISATAP = "isatap"
+	// TypeTeredo identifiers hold the Teredo client's flags, port, and IPv4 address rather than
+	// an interface identifier (RFC 4380)
+	TypeTeredo = "teredo"
+	// TypeLowByte identifiers are manually assigned, with only the low 16 bits set, as in ::1
+	TypeLowByte = "low-byte"
+	// TypeRandomized identifiers match no pattern, as with temporary (RFC 8981) or stable
+	// opaque (RFC 7217) addresses, which cannot be told apart
+	TypeRandomized = "randomized"
+)
+
+//go:embed data/oui.txt
+var bundledOUIs []byte
+
+// vendors maps OUIs to vendor names
+var vendors = mustParseOUIs(bundledOUIs)
+
+// mustParseOUIs parses the bundled OUI list, panicking on a malformed entry
+func mustParseOUIs(data []byte) map[[3]byte]string {
+	ouis, err := parseOUIs(data)
+	if err != nil {
+		panic(fmt.Sprintf("eui64: bundled OUI list: %v", err))
+	}
+	return ouis
+}
+
+// parseOUIs parses "<OUI> <vendor>" lines, skipping blank lines and # comments
+func parseOUIs(data []byte) (map[[3]byte]string, error) {
+	ouis := make(map[[3]byte]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		prefix, vendor, ok := strings.Cut(text, " ")
+		mac, err := net.ParseMAC(prefix + ":00:00:00")
+		if !ok || err != nil || strings.TrimSpace(vendor) == "" {
+			return nil, fmt.Errorf("line %d: expected \"<OUI> <vendor>\", got %q", line, text)
+		}
+		ouis[[3]byte(mac[:3])] = strings.TrimSpace(vendor)
+	}
+	return ouis, scanner.Err()
+}
+
+// Analyze describes the interface identifier of addr, which must be an IPv6 address
+func Analyze(addr netip.Addr) *models.IPv6Analysis {
+	b := addr.As16()
+	iid := b[8:]
+	analysis := &models.IPv6Analysis{
+		IP:              addr.String(),
+		Prefix64:        netip.PrefixFrom(addr, 64).Masked().String(),
+		InterfaceID:     fmt.Sprintf("%02x%02x:%02x%02x:%02x%02x:%02x%02x", iid[0], iid[1], iid[2], iid[3], iid[4], iid[5], iid[6], iid[7]),
+		InterfaceIDType: interfaceIDType(iid),
+	}
+	tunnelType, embeddedIPv4 := ip.Tunnel(addr)
+	if tunnelType != "" {
+		analysis.TunnelType = tunnelType
+		analysis.EmbeddedIPv4 = embeddedIPv4.String()
+	}
+	if tunnelType == ip.TunnelTeredo {
+		analysis.InterfaceIDType = TypeTeredo
+	}
+
+	switch analysis.InterfaceIDType {
+	case TypeEUI64:
+		// The universal/local bit is inverted in the identifier
+		mac := net.HardwareAddr{iid[0] ^ 0x02, iid[1], iid[2], iid[5], iid[6], iid[7]}
+		analysis.MAC = mac.String()
+		analysis.MACLocallyAdministered = mac[0]&0x02 != 0
+		if !analysis.MACLocallyAdministered {
+			analysis.MACVendor = vendors[[3]byte(mac[:3])]
+		}
+	case TypeRandomized:
+		analysis.PrivacyAddress = true
+	}
+	return analysis
+}
+
+// interfaceIDType classifies the 64-bit interface identifier iid
+func interfaceIDType(iid []byte) string {
+	switch {
+	case iid[3] == 0xff && iid[4] == 0xfe:
+		return TypeEUI64
+	case iid[0]&^0x02 == 0 && iid[1] == 0 && iid[2] == 0x5e && iid[3] == 0xfe:
+		return TypeISATAP
+	case bytes.Equal(iid[:6], make([]byte, 6)):
+		return TypeLowByte
+	}
+	return TypeRandomized
+}
@@ -0,0 +1,115 @@
+package eui64
+
+import (
+	"net/netip"
+	"testing"
+
+	"myip/internal/models"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name string
+		addr string
+		want models.IPv6Analysis
+	}{
+		{
+			name: "EUI-64 with a known vendor",
+			addr: "2001:db8:0:a:ba27:ebff:fe12:3456",
+			want: models.IPv6Analysis{
+				IP: "2001:db8:0:a:ba27:ebff:fe12:3456", Prefix64: "2001:db8:0:a::/64",
+				InterfaceID: "ba27:ebff:fe12:3456", InterfaceIDType: TypeEUI64,
+				MAC: "b8:27:eb:12:34:56", MACVendor: "Raspberry Pi",
+			},
+		},
+		{
+			name: "EUI-64 with an unknown vendor",
+			addr: "2001:db8::212:34ff:fe56:789a",
+			want: models.IPv6Analysis{
+				IP: "2001:db8::212:34ff:fe56:789a", Prefix64: "2001:db8::/64",
+				InterfaceID: "0212:34ff:fe56:789a", InterfaceIDType: TypeEUI64,
+				MAC: "00:12:34:56:78:9a",
+			},
+		},
+		{
+			name: "EUI-64 with a locally administered MAC",
+			addr: "2001:db8::5054:ff:fe12:3456",
+			want: models.IPv6Analysis{
+				IP: "2001:db8::5054:ff:fe12:3456", Prefix64: "2001:db8::/64",
+				InterfaceID: "5054:00ff:fe12:3456", InterfaceIDType: TypeEUI64,
+				MAC: "52:54:00:12:34:56", MACLocallyAdministered: true,
+			},
+		},
+		{
+			name: "Randomized",
+			addr: "2001:db8::3c1d:9a2e:71f4:b5c8",
+			want: models.IPv6Analysis{
+				IP: "2001:db8::3c1d:9a2e:71f4:b5c8", Prefix64: "2001:db8::/64",
+				InterfaceID: "3c1d:9a2e:71f4:b5c8", InterfaceIDType: TypeRandomized, PrivacyAddress: true,
+			},
+		},
+		{
+			name: "Low byte",
+			addr: "2001:db8::53",
+			want: models.IPv6Analysis{
+				IP: "2001:db8::53", Prefix64: "2001:db8::/64",
+				InterfaceID: "0000:0000:0000:0053", InterfaceIDType: TypeLowByte,
+			},
+		},
+		{
+			name: "ISATAP",
+			addr: "2001:db8::200:5efe:c000:201",
+			want: models.IPv6Analysis{
+				IP: "2001:db8::200:5efe:c000:201", Prefix64: "2001:db8::/64",
+				InterfaceID: "0200:5efe:c000:0201", InterfaceIDType: TypeISATAP,
+				TunnelType: "isatap", EmbeddedIPv4: "192.0.2.1",
+			},
+		},
+		{
+			name: "Teredo",
+			addr: "2001:0:4136:e378:8000:63bf:3fff:fdd2",
+			want: models.IPv6Analysis{
+				IP: "2001:0:4136:e378:8000:63bf:3fff:fdd2", Prefix64: "2001:0:4136:e378::/64",
+				InterfaceID: "8000:63bf:3fff:fdd2", InterfaceIDType: TypeTeredo,
+				TunnelType: "teredo", EmbeddedIPv4: "192.0.2.45",
+			},
+		},
+		{
+			name: "6to4",
+			addr: "2002:c000:201::1",
+			want: models.IPv6Analysis{
+				IP: "2002:c000:201::1", Prefix64: "2002:c000:201::/64",
+				InterfaceID: "0000:0000:0000:0001", InterfaceIDType: TypeLowByte,
+				TunnelType: "6to4", EmbeddedIPv4: "192.0.2.1",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Analyze(netip.MustParseAddr(test.addr)); *got != test.want {
+				t.Errorf("Expected %+v, got %+v", test.want, *got)
+			}
+		})
+	}
+}
+
+func TestParseOUIs(t *testing.T) {
+	ouis, err := parseOUIs([]byte("# comment\n\nb8:27:eb Raspberry Pi\n00:50:56 VMware\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ouis) != 2 || ouis[[3]byte{0xb8, 0x27, 0xeb}] != "Raspberry Pi" {
+		t.Errorf("Expected two vendors, got %v", ouis)
+	}
+
+	for _, invalid := range []string{"b8:27:eb", "b8:27 Raspberry Pi", "zz:27:eb Raspberry Pi"} {
+		if _, err := parseOUIs([]byte(invalid)); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+
+	if len(vendors) == 0 {
+		t.Error("Expected the bundled OUI list to be loaded")
+	}
+}
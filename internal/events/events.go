@@ -0,0 +1,180 @@
+// Package events streams a summary of each request to operators as server-sent events, so live
+// traffic to a public instance can be watched without shipping logs.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"myip/internal/ip"
+	"myip/internal/middleware"
+	"myip/internal/models"
+	"myip/internal/privacy"
+	"myip/internal/problem"
+	"myip/internal/requestid"
+)
+
+// MaxSubscribers bounds the streams open at once, as each costs a connection held open
+const MaxSubscribers = 10
+
+// subscriberBuffer is the number of events a stream may fall behind by before events are dropped
+// for it, so a slow subscriber never delays requests
+const subscriberBuffer = 256
+
+// heartbeatInterval spaces the comments sent on an idle stream, keeping proxies from closing it
+const heartbeatInterval = 15 * time.Second
+
+// Lookup returns the autonomous system and country code of ip; ok is false when unknown
+type Lookup func(ip string) (asn uint32, country string, ok bool)
+
+// subscriber is an open stream
+type subscriber struct {
+	events  chan models.RequestEvent
+	dropped atomic.Int64
+}
+
+// Hub fans request summaries out to the open streams. Requests are only summarized while a stream
+// is open.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+	active      atomic.Int32
+	lookup      Lookup
+	now         func() time.Time
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// New creates a hub. lookup may be nil, in which case events carry no country.
+func New(lookup Lookup) *Hub {
+	return &Hub{
+		subscribers: make(map[*subscriber]struct{}),
+		lookup:      lookup,
+		now:         time.Now,
+		closed:      make(chan struct{}),
+	}
+}
+
+// Close ends the open streams, for a server shutting down, whose graceful shutdown would
+// otherwise wait for them
+func (h *Hub) Close() {
+	h.closeOnce.Do(func() { close(h.closed) })
+}
+
+// subscribe opens a stream, reporting false when MaxSubscribers are already open
+func (h *Hub) subscribe() (*subscriber, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.subscribers) >= MaxSubscribers {
+		return nil, false
+	}
+	s := &subscriber{events: make(chan models.RequestEvent, subscriberBuffer)}
+	h.subscribers[s] = struct{}{}
+	h.active.Add(1)
+	return s, true
+}
+
+// unsubscribe closes the stream of s
+func (h *Hub) unsubscribe(s *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers, s)
+	h.active.Add(-1)
+}
+
+// publish hands event to every open stream, dropping it for those too far behind
+func (h *Hub) publish(event models.RequestEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscribers {
+		select {
+		case s.events <- event:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Middleware publishes a summary of each request once it is answered, while a stream is open
+func (h *Hub) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.active.Load() == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := h.now()
+		rec := middleware.NewStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		clientIP, _ := ip.ExtractClientIP(r)
+		event := models.RequestEvent{
+			Time:       start.UTC().Format(time.RFC3339Nano),
+			RequestID:  requestid.FromContext(r.Context()),
+			IP:         privacy.IP(clientIP),
+			Method:     r.Method,
+			Endpoint:   privacy.Text(r.URL.Path),
+			Status:     rec.Status,
+			DurationMs: float64(h.now().Sub(start).Microseconds()) / 1000,
+		}
+		if h.lookup != nil && ip.IsValid(clientIP) && !ip.IsPrivate(clientIP) {
+			if _, country, ok := h.lookup(clientIP); ok && country != "None" {
+				event.Country = country
+			}
+		}
+		h.publish(event)
+	})
+}
+
+// Handler streams the summary of each request as a "request" event holding a
+// models.RequestEvent. Events a slow client missed are reported in a "dropped" event before the
+// next one, and an idle stream gets a comment every heartbeatInterval.
+func (h *Hub) Handler(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.subscribe()
+	if !ok {
+		problem.Error(w, r, http.StatusServiceUnavailable, "Too many open event streams", problem.WithRetryAfter(time.Minute))
+		return
+	}
+	defer h.unsubscribe(s)
+
+	// The stream outlives the server's write timeout
+	controller := http.NewResponseController(w)
+	controller.SetWriteDeadline(time.Time{})
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-store")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if controller.Flush() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.closed:
+			return
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": keepalive\n\n")
+		case event := <-s.events:
+			if dropped := s.dropped.Swap(0); dropped > 0 {
+				fmt.Fprintf(w, "event: dropped\ndata: {\"dropped\":%d}\n\n", dropped)
+			}
+			data, _ := json.Marshal(event)
+			_, err = fmt.Fprintf(w, "event: request\ndata: %s\n\n", data)
+		}
+		if err != nil || controller.Flush() != nil {
+			return
+		}
+	}
+}
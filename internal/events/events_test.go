@@ -0,0 +1,110 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"myip/internal/models"
+)
+
+// readEvent reads the next event from an event stream, skipping comments
+func readEvent(t *testing.T, reader *bufio.Reader) (string, string) {
+	t.Helper()
+	var name, data string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Stream ended: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && name != "":
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	h := New(func(ip string) (uint32, string, bool) { return 64496, "DE", true })
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", h.Handler)
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/missing", http.NotFound)
+	server := httptest.NewServer(h.Middleware(mux))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", response.Header.Get("Content-Type"))
+	}
+
+	req := httptest.NewRequest("GET", "/missing", nil)
+	req.RemoteAddr = "203.0.113.7:1234"
+	h.Middleware(mux).ServeHTTP(httptest.NewRecorder(), req)
+
+	reader := bufio.NewReader(response.Body)
+	name, data := readEvent(t, reader)
+	var event models.RequestEvent
+	if err := json.Unmarshal([]byte(data), &event); name != "request" || err != nil {
+		t.Fatalf("Expected a request event, got %q %s", name, data)
+	}
+	if event.IP != "203.0.113.7" || event.Country != "DE" || event.Method != "GET" || event.Endpoint != "/missing" ||
+		event.Status != http.StatusNotFound || event.Time == "" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	h.Close()
+	if _, err := reader.ReadString('\n'); err == nil {
+		t.Error("Expected the stream to end once the hub is closed")
+	}
+}
+
+func TestStreamLimit(t *testing.T) {
+	h := New(nil)
+	for range MaxSubscribers {
+		if _, ok := h.subscribe(); !ok {
+			t.Fatal("Expected a stream slot")
+		}
+	}
+	rr := httptest.NewRecorder()
+	h.Handler(rr, httptest.NewRequest("GET", "/events", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 once every stream slot is taken, got %d", rr.Code)
+	}
+}
+
+func TestDropped(t *testing.T) {
+	h := New(nil)
+	s, _ := h.subscribe()
+	for range subscriberBuffer + 3 {
+		h.publish(models.RequestEvent{Endpoint: "/"})
+	}
+	if dropped := s.dropped.Load(); dropped != 3 {
+		t.Errorf("Expected 3 events dropped for a full stream, got %d", dropped)
+	}
+
+	// Requests are not summarized without an open stream
+	h.unsubscribe(s)
+	called := false
+	h.now = func() time.Time {
+		called = true
+		return time.Now()
+	}
+	h.Middleware(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if called {
+		t.Error("Expected no summary without an open stream")
+	}
+}
@@ -0,0 +1,50 @@
+package features
+
+import (
+	"sort"
+	"sync"
+)
+
+// Build profiles, selected with the "minimal" build tag
+const (
+	// ProfileFull includes every module (default)
+	ProfileFull = "full"
+	// ProfileMinimal includes the core IP endpoints only, for routers and edge devices
+	ProfileMinimal = "minimal"
+)
+
+var (
+	mu       sync.RWMutex
+	compiled = make(map[string]bool)
+)
+
+// Register records that a module is compiled into the binary; it is called from init functions
+func Register(modules ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, module := range modules {
+		compiled[module] = true
+	}
+}
+
+// Enabled reports whether a module is compiled into the binary
+func Enabled(module string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return compiled[module]
+}
+
+// Compiled returns the sorted names of the modules compiled into the binary
+func Compiled() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	modules := make([]string, 0, len(compiled))
+	for module := range compiled {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	return modules
+}
@@ -0,0 +1,28 @@
+package features
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	Register("zeta", "alpha")
+	Register("alpha")
+
+	if !Enabled("alpha") || !Enabled("zeta") {
+		t.Error("Expected registered modules to be enabled")
+	}
+	if Enabled("missing") {
+		t.Error("Expected unregistered module to be disabled")
+	}
+
+	if got := strings.Join(Compiled(), ","); got != "alpha,zeta" {
+		t.Errorf("Expected sorted modules alpha,zeta, got %s", got)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	if Profile != ProfileFull && Profile != ProfileMinimal {
+		t.Errorf("Unexpected profile %q", Profile)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build minimal
+
+package features
+
+// Profile is the build profile of the binary
+const Profile = ProfileMinimal
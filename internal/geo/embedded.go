@@ -0,0 +1,19 @@
+//go:build geodata
+
+package geo
+
+import (
+	_ "embed"
+
+	"myip/internal/features"
+)
+
+func init() {
+	features.Register("geodata")
+}
+
+// tagged reports that the binary was built with the geodata tag, and should hold the table
+const tagged = true
+
+//go:embed data/countries.txt.gz
+var embedded []byte
@@ -0,0 +1,9 @@
+//go:build !geodata
+
+package geo
+
+// embedded is empty without the geodata build tag, leaving /geo to the ASN database
+var embedded []byte
+
+// tagged reports that the binary was built without the geodata tag
+const tagged = false
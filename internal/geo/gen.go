@@ -0,0 +1,154 @@
+//go:build ignore
+
+// gen writes data/countries.txt.gz, the table compiled in with the geodata build tag, from the
+// delegation statistics published by the five regional internet registries. Run it with
+// "go generate ./internal/geo" or "make geodata".
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sources are the registries' extended delegation statistics
+var sources = []string{
+	"https://ftp.afrinic.net/pub/stats/afrinic/delegated-afrinic-extended-latest",
+	"https://ftp.apnic.net/stats/apnic/delegated-apnic-extended-latest",
+	"https://ftp.arin.net/pub/stats/arin/delegated-arin-extended-latest",
+	"https://ftp.lacnic.net/pub/stats/lacnic/delegated-lacnic-extended-latest",
+	"https://ftp.ripe.net/pub/stats/ripencc/delegated-ripencc-extended-latest",
+}
+
+const output = "data/countries.txt.gz"
+
+type countryRange struct {
+	start, end netip.Addr
+	country    string
+}
+
+func main() {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	var ranges []countryRange
+	for _, source := range sources {
+		parsed, err := fetch(client, source)
+		if err != nil {
+			log.Fatalf("%s: %v", source, err)
+		}
+		log.Printf("%s: %d ranges", source, len(parsed))
+		ranges = append(ranges, parsed...)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start.Less(ranges[j].start) })
+	ranges = merge(ranges)
+	if len(ranges) == 0 {
+		log.Fatalf("No ranges parsed; keeping %s", output)
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	zw, err := gzip.NewWriterLevel(f, gzip.BestCompression)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := bufio.NewWriter(zw)
+	fmt.Fprintf(w, "# Generated by gen.go from the RIR delegation statistics on %s\n", time.Now().UTC().Format("2006-01-02"))
+	for _, r := range ranges {
+		fmt.Fprintf(w, "%s %s %s\n", r.start, r.end, r.country)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Wrote %d ranges to %s", len(ranges), output)
+}
+
+// fetch parses the allocated and assigned address blocks of a delegation statistics file, whose
+// records read "registry|cc|type|start|value|date|status[|extensions]"; value is the number of
+// addresses for ipv4 and the prefix length for ipv6
+func fetch(client *http.Client, source string) ([]countryRange, error) {
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("returned %s", resp.Status)
+	}
+
+	var ranges []countryRange
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "|")
+		if len(fields) < 7 || fields[1] == "" || fields[1] == "*" {
+			continue
+		}
+		if fields[6] != "allocated" && fields[6] != "assigned" {
+			continue
+		}
+		start, err := netip.ParseAddr(fields[3])
+		if err != nil {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[4], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		var end netip.Addr
+		switch fields[2] {
+		case "ipv4":
+			end = add(start, new(big.Int).SetUint64(value-1))
+		case "ipv6":
+			prefix, err := start.Prefix(int(value))
+			if err != nil {
+				continue
+			}
+			end = add(prefix.Addr(), new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(128-value)), big.NewInt(1)))
+		default:
+			continue
+		}
+		ranges = append(ranges, countryRange{start: start, end: end, country: strings.ToUpper(fields[1])})
+	}
+	return ranges, scanner.Err()
+}
+
+// add returns addr plus n
+func add(addr netip.Addr, n *big.Int) netip.Addr {
+	b := addr.AsSlice()
+	sum := new(big.Int).Add(new(big.Int).SetBytes(b), n).FillBytes(make([]byte, len(b)))
+	result, _ := netip.AddrFromSlice(sum)
+	return result
+}
+
+// merge joins adjacent ranges of the same country, which keeps the table coarse
+func merge(ranges []countryRange) []countryRange {
+	var merged []countryRange
+	for _, r := range ranges {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			if last.country == r.country && last.start.Is4() == r.start.Is4() && last.end.Next() == r.start {
+				last.end = r.end
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
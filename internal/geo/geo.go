@@ -0,0 +1,162 @@
+// Package geo serves /geo, the country of the client IP. Countries come from the ASN database
+// when one is configured, otherwise from a coarse IP-to-country table compiled into binaries
+// built with the geodata tag, generated from the regional internet registries' delegation
+// statistics by "go generate".
+package geo
+
+//go:generate go run gen.go
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"myip/internal/ip"
+	"myip/internal/logging"
+	"myip/internal/models"
+	"myip/internal/problem"
+)
+
+// Sources reported in GeoResponse.Source
+const (
+	// SourceDatabase is the ASN database: IP_ASN_DB or the GeoLite2 editions
+	SourceDatabase = "asn-db"
+	// SourceEmbedded is the table compiled into the binary
+	SourceEmbedded = "embedded"
+)
+
+var logger = logging.For("geo")
+
+// Lookup returns the autonomous system and country code of ip; ok is false when unknown
+type Lookup func(ip string) (asn uint32, country string, ok bool)
+
+// countryRange maps an address range to a country code
+type countryRange struct {
+	start, end netip.Addr
+	country    string
+}
+
+var (
+	tableOnce sync.Once
+	table     []countryRange
+)
+
+// loadTable parses the embedded table on first use
+func loadTable() []countryRange {
+	tableOnce.Do(func() {
+		if len(embedded) == 0 {
+			return
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(embedded))
+		if err != nil {
+			logging.Errorf("Embedded country table unreadable: %v", err)
+			return
+		}
+		defer zr.Close()
+		table = parseTable(bufio.NewScanner(zr))
+		logger.Debugf("Loaded %d embedded country ranges", len(table))
+	})
+	return table
+}
+
+// parseTable parses "start end CC" lines, skipping comments and malformed lines, sorted by
+// start address
+func parseTable(scanner *bufio.Scanner) []countryRange {
+	var ranges []countryRange
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		start, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			continue
+		}
+		end, err := netip.ParseAddr(fields[1])
+		if err != nil {
+			continue
+		}
+		ranges = append(ranges, countryRange{start: start, end: end, country: fields[2]})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start.Less(ranges[j].start) })
+	return ranges
+}
+
+// Embedded reports whether the binary holds a non-empty country table
+func Embedded() bool {
+	return len(loadTable()) > 0
+}
+
+// CheckEmbedded reports an error when the binary was built with the geodata tag around the empty
+// placeholder table, which would leave /geo without data
+func CheckEmbedded() error {
+	if tagged && !Embedded() {
+		return errors.New(`the embedded country table is empty; run "make geodata" before building with -tags geodata`)
+	}
+	return nil
+}
+
+// Country returns the country code of ip from the embedded table
+func Country(ipStr string) (string, bool) {
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return "", false
+	}
+	return lookupTable(loadTable(), addr.Unmap())
+}
+
+// lookupTable finds the range of ranges containing addr
+func lookupTable(ranges []countryRange, addr netip.Addr) (string, bool) {
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].end.Compare(addr) >= 0 })
+	if i == len(ranges) || ranges[i].start.Compare(addr) > 0 {
+		return "", false
+	}
+	return ranges[i].country, true
+}
+
+// Handler serves the country of the client IP from lookup, falling back to the embedded table.
+// lookup may be nil when no ASN database is configured.
+func Handler(lookup Lookup) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clientIP, _ := ip.ExtractClientIP(r)
+		if !ip.IsValid(clientIP) {
+			http.Error(w, "Unable to determine client IP", http.StatusBadRequest)
+			return
+		}
+		if ip.IsPrivate(clientIP) {
+			http.Error(w, "No country data for private addresses", http.StatusBadRequest)
+			return
+		}
+
+		response := &models.GeoResponse{IP: clientIP, Timestamp: time.Now().UTC().Format(time.RFC3339)}
+		if lookup != nil {
+			if asn, country, ok := lookup(clientIP); ok && country != "" {
+				response.Country, response.ASN, response.Source = country, asn, SourceDatabase
+			}
+		}
+		if response.Country == "" {
+			if country, ok := Country(clientIP); ok {
+				response.Country, response.Source = country, SourceEmbedded
+			}
+		}
+		if response.Country == "" {
+			http.Error(w, "No country data for address", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			problem.Error(w, r, http.StatusInternalServerError, "Failed to encode JSON response")
+			return
+		}
+	}
+}
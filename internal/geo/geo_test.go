@@ -0,0 +1,127 @@
+package geo
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"myip/internal/models"
+)
+
+const sampleTable = "# Generated by gen.go\n" +
+	"81.0.0.0 81.0.255.255 DE\n" +
+	"203.0.113.0 203.0.113.255 NL\n" +
+	"not an address\n" +
+	"2001:db8:: 2001:db8:ffff:ffff:ffff:ffff:ffff:ffff JP\n"
+
+// useTable replaces the embedded table with data for the duration of the test
+func useTable(t *testing.T, data string) {
+	loadTable()
+	previous := table
+	table = parseTable(bufio.NewScanner(strings.NewReader(data)))
+	t.Cleanup(func() { table = previous })
+}
+
+func TestCountry(t *testing.T) {
+	useTable(t, sampleTable)
+
+	tests := []struct {
+		ip      string
+		country string
+		ok      bool
+	}{
+		{"203.0.113.7", "NL", true},
+		{"::ffff:81.0.1.2", "DE", true},
+		{"2001:db8::1", "JP", true},
+		{"198.51.100.1", "", false},
+		{"invalid", "", false},
+	}
+	for _, test := range tests {
+		if country, ok := Country(test.ip); country != test.country || ok != test.ok {
+			t.Errorf("Country(%s) = %q, %v, expected %q, %v", test.ip, country, ok, test.country, test.ok)
+		}
+	}
+	if !Embedded() {
+		t.Error("Expected a table to be reported as embedded")
+	}
+}
+
+func TestLookupTableEdges(t *testing.T) {
+	ranges := parseTable(bufio.NewScanner(strings.NewReader(sampleTable)))
+	for ip, expected := range map[string]string{
+		"81.0.0.0":       "DE",
+		"81.0.255.255":   "DE",
+		"81.1.0.0":       "",
+		"203.0.112.255":  "",
+		"203.0.113.255":  "NL",
+		"2001:db9::":     "",
+		"80.255.255.255": "",
+	} {
+		if got, _ := lookupTable(ranges, netip.MustParseAddr(ip)); got != expected {
+			t.Errorf("lookupTable(%s) = %q, expected %q", ip, got, expected)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	useTable(t, sampleTable)
+	database := func(ip string) (uint32, string, bool) {
+		if ip == "81.0.0.1" {
+			return 64501, "AT", true
+		}
+		return 0, "", false
+	}
+
+	tests := []struct {
+		name         string
+		lookup       Lookup
+		clientIP     string
+		expectedCode int
+		country      string
+		source       string
+	}{
+		{"Database", database, "81.0.0.1", http.StatusOK, "AT", SourceDatabase},
+		{"Embedded fallback", database, "203.0.113.7", http.StatusOK, "NL", SourceEmbedded},
+		{"Embedded only", nil, "81.0.0.1", http.StatusOK, "DE", SourceEmbedded},
+		{"Unknown", database, "198.51.100.1", http.StatusNotFound, "", ""},
+		{"Private", nil, "192.168.1.10", http.StatusBadRequest, "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/geo", nil)
+			req.Header.Set("CF-Connecting-IP", test.clientIP)
+			rr := httptest.NewRecorder()
+			Handler(test.lookup)(rr, req)
+
+			if rr.Code != test.expectedCode {
+				t.Fatalf("Expected status %d, got %d: %s", test.expectedCode, rr.Code, rr.Body.String())
+			}
+			if test.expectedCode != http.StatusOK {
+				return
+			}
+			var response models.GeoResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.IP != test.clientIP || response.Country != test.country || response.Source != test.source {
+				t.Errorf("Unexpected response %+v", response)
+			}
+		})
+	}
+}
+
+func TestCheckEmbedded(t *testing.T) {
+	useTable(t, sampleTable)
+	if err := CheckEmbedded(); err != nil {
+		t.Errorf("Expected a loaded table to pass, got %v", err)
+	}
+
+	useTable(t, "")
+	if err := CheckEmbedded(); (err != nil) != tagged {
+		t.Errorf("Expected an error for an empty table only with the geodata tag, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package guide
+
+import (
+	"embed"
+	htmltemplate "html/template"
+	"net/http"
+	"strings"
+	texttemplate "text/template"
+
+	"myip/internal/models"
+	"myip/internal/problem"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var (
+	htmlPage = htmltemplate.Must(htmltemplate.ParseFS(templates, "templates/docs.html.tmpl"))
+	textPage = texttemplate.Must(texttemplate.ParseFS(templates, "templates/docs.txt.tmpl"))
+)
+
+// page is the data rendered by the templates
+type page struct {
+	Title   string
+	Version string
+	BaseURL string
+	Routes  []route
+}
+
+// route is a documented route with its curl examples
+type route struct {
+	models.RouteInfo
+	Commands []string
+}
+
+// Handler serves usage documentation generated from routes: HTML for browsers, plain text otherwise.
+// routes is called on every request so the page always matches the registered routes.
+func Handler(title, version string, routes func() []models.RouteInfo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := page{Title: title, Version: version, BaseURL: BaseURL(r)}
+		for _, info := range routes() {
+			data.Routes = append(data.Routes, route{RouteInfo: info, Commands: commands(data.BaseURL, info)})
+		}
+
+		var err error
+		if strings.Contains(r.Header.Get("Accept"), "text/html") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			err = htmlPage.Execute(w, data)
+		} else {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			err = textPage.Execute(w, data)
+		}
+		if err != nil {
+			problem.Error(w, r, http.StatusInternalServerError, "Failed to render documentation")
+		}
+	}
+}
+
+// BaseURL returns the scheme and host the client used to reach the service
+func BaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ","); proto == "https" || proto == "http" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
+
+// commands returns the curl commands for the route without a query and with each example
+func commands(base string, info models.RouteInfo) []string {
+	prefix := "curl"
+	if info.Method != http.MethodGet {
+		prefix += " -X " + info.Method
+	}
+	if info.Auth == "bearer" {
+		prefix += ` -H "Authorization: Bearer $ADMIN_TOKEN"`
+	}
+
+	url := base + examplePath(info.Path)
+	cmds := []string{prefix + ` "` + url + `"`}
+	for _, query := range info.Examples {
+		cmds = append(cmds, prefix+` "`+url+query+`"`)
+	}
+	return cmds
+}
+
+// examplePath replaces {name} wildcards with <name> placeholders and drops the {$} end anchor
+func examplePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+		name := strings.TrimSuffix(strings.Trim(segment, "{}"), "...")
+		if name == "$" {
+			segments[i] = ""
+			continue
+		}
+		segments[i] = "<" + name + ">"
+	}
+	return strings.Join(segments, "/")
+}
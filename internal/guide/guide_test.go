@@ -0,0 +1,81 @@
+package guide
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"myip/internal/models"
+)
+
+func testRoutes() []models.RouteInfo {
+	return []models.RouteInfo{
+		{Method: "GET", Path: "/{$}", Description: "IPv4 address", Auth: "none", Stability: "stable", Examples: []string{"?format=json"}},
+		{Method: "GET", Path: "/lookup/{ip}", Description: "Look up an IP", Auth: "none", Stability: "experimental"},
+		{Method: "POST", Path: "/admin/maintenance", Description: "Toggle <maintenance>", Auth: "bearer", Stability: "stable"},
+	}
+}
+
+func TestHandlerText(t *testing.T) {
+	req := httptest.NewRequest("GET", "/docs", nil)
+	req.Host = "ip.example.com"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rr := httptest.NewRecorder()
+
+	Handler("MyIP", "1.2.3", testRoutes)(rr, req)
+
+	if ct := rr.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("Expected text/plain, got %s", ct)
+	}
+
+	body := rr.Body.String()
+	for _, want := range []string{
+		"MyIP 1.2.3",
+		`curl "https://ip.example.com/"`,
+		`curl "https://ip.example.com/?format=json"`,
+		`curl "https://ip.example.com/lookup/<ip>"`,
+		"[experimental]",
+		`curl -X POST -H "Authorization: Bearer $ADMIN_TOKEN" "https://ip.example.com/admin/maintenance"`,
+		"[auth: bearer]",
+		`fetch("https://ip.example.com/json")`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected docs to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestHandlerHTML(t *testing.T) {
+	req := httptest.NewRequest("GET", "/docs", nil)
+	req.Host = "localhost:8080"
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	rr := httptest.NewRecorder()
+
+	Handler("MyIP", "dev", testRoutes)(rr, req)
+
+	if ct := rr.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Expected text/html, got %s", ct)
+	}
+
+	body := rr.Body.String()
+	if !strings.Contains(body, "<code>GET /lookup/{ip}</code>") || !strings.Contains(body, "http://localhost:8080/?format=json") {
+		t.Errorf("Expected rendered routes, got:\n%s", body)
+	}
+	if strings.Contains(body, "Toggle <maintenance>") {
+		t.Error("Expected route descriptions to be HTML-escaped")
+	}
+}
+
+func TestExamplePath(t *testing.T) {
+	tests := map[string]string{
+		"/json":            "/json",
+		"/{$}":             "/",
+		"/lookup/{ip}":     "/lookup/<ip>",
+		"/files/{rest...}": "/files/<rest>",
+	}
+	for path, want := range tests {
+		if got := examplePath(path); got != want {
+			t.Errorf("examplePath(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"myip/internal/models"
+	"myip/internal/problem"
+)
+
+// about holds the instance metadata served by /about; it is empty while unset
+var about atomic.Pointer[models.AboutInfo]
+
+// SetAbout sets the instance metadata served by /about
+func SetAbout(info models.AboutInfo) {
+	about.Store(&info)
+}
+
+// AboutHandler serves the operator's published metadata for the instance: its name, contacts,
+// privacy policy, and the limits clients are held to
+func AboutHandler(w http.ResponseWriter, r *http.Request) {
+	var info models.AboutInfo
+	if current := about.Load(); current != nil {
+		info = *current
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&info); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, "Failed to encode instance metadata")
+	}
+}
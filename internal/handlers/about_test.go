@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"myip/internal/models"
+)
+
+func TestAboutHandler(t *testing.T) {
+	defer about.Store(nil)
+
+	serve := func() (models.AboutInfo, string) {
+		rr := httptest.NewRecorder()
+		AboutHandler(rr, httptest.NewRequest("GET", "/about", nil))
+		var info models.AboutInfo
+		if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+			t.Fatalf("Failed to decode %q: %v", rr.Body.String(), err)
+		}
+		return info, rr.Body.String()
+	}
+
+	if info, body := serve(); info != (models.AboutInfo{}) {
+		t.Errorf("Expected empty metadata while unset, got %s", body)
+	}
+
+	want := models.AboutInfo{
+		Name:             "ip.example.com",
+		ContactEmail:     "abuse@example.com",
+		PrivacyPolicyURL: "https://example.com/privacy",
+		RateLimits:       models.RateLimits{DNSLookupsPerMinute: 30, MaxInFlightPerIP: 4},
+	}
+	SetAbout(want)
+	if info, body := serve(); info != want {
+		t.Errorf("Expected %+v, got %s", want, body)
+	}
+}
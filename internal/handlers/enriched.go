@@ -0,0 +1,44 @@
+//go:build !minimal
+
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"myip/internal/enrich"
+	"myip/internal/ip"
+	"myip/internal/models"
+)
+
+// EnrichedJSONHandler serves the JSON response with a section from every enrichment provider.
+// Sections are omitted or served stale according to each provider's degradation policy, and
+// the request fails with 503 when a provider with the fail policy is unavailable.
+func EnrichedJSONHandler(e *enrich.Enricher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := ip.GetInfo(r)
+		defer ip.ReleaseInfo(info)
+		detection := time.Since(start)
+
+		// Pollers selecting a few fields should not wait for the providers
+		if fields, err := parseFields(r.URL.Query().Get("fields")); err != nil || (fields != nil &&
+			!slices.Contains(fields, "enrichment") && !slices.Contains(fields, "meta")) {
+			writeInfo(w, r, info, detection)
+			return
+		}
+
+		sections, meta, err := e.Enrich(r.Context(), info.ClientIP)
+		if err != nil {
+			writeError(w, r, formatJSON, http.StatusServiceUnavailable, models.ErrorEnrichmentUnavailable, err.Error())
+			return
+		}
+		if len(meta.Sections) > 0 {
+			info.Enrichment = sections
+			info.Meta = meta
+		}
+
+		writeInfo(w, r, info, detection)
+	}
+}
@@ -0,0 +1,74 @@
+//go:build !minimal
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"myip/internal/enrich"
+	"myip/internal/models"
+)
+
+// staticProvider is an enrichment provider returning a fixed value or error
+type staticProvider struct {
+	value any
+	err   error
+}
+
+func (p *staticProvider) Name() string { return "geo" }
+
+func (p *staticProvider) Lookup(ctx context.Context, ip string) (any, error) {
+	return p.value, p.err
+}
+
+func TestEnrichedJSONHandler(t *testing.T) {
+	provider := &staticProvider{value: map[string]string{"country": "NL"}}
+	e := enrich.New(map[string]string{"geo": enrich.PolicyFail}, time.Second, time.Hour)
+	e.Register(provider)
+
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+	rr := httptest.NewRecorder()
+	EnrichedJSONHandler(e)(rr, req)
+
+	var info models.IPInfo
+	if err := json.NewDecoder(rr.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if info.Enrichment["geo"] == nil || info.Meta == nil || info.Meta.Degraded {
+		t.Errorf("Expected healthy geo enrichment, got %+v %+v", info.Enrichment, info.Meta)
+	}
+
+	provider.err = errors.New("database unavailable")
+	rr = httptest.NewRecorder()
+	EnrichedJSONHandler(e)(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 when a fail-policy provider is down, got %d", rr.Code)
+	}
+
+	// Selecting fields without the enrichment sections skips the providers
+	req = httptest.NewRequest("GET", "/json?fields=client_ip", nil)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+	rr = httptest.NewRecorder()
+	EnrichedJSONHandler(e)(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "{\"client_ip\":\"203.0.113.1\"}\n" {
+		t.Errorf("Expected only client_ip without enrichment, got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestEnrichedJSONHandlerWithoutProviders(t *testing.T) {
+	rr := httptest.NewRecorder()
+	EnrichedJSONHandler(enrich.New(nil, time.Second, time.Hour))(rr, httptest.NewRequest("GET", "/json", nil))
+
+	if strings.Contains(rr.Body.String(), "meta") || strings.Contains(rr.Body.String(), "enrichment") {
+		t.Errorf("Expected no enrichment fields without providers, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"myip/internal/models"
+	"myip/internal/problem"
+	"myip/internal/requestid"
+)
+
+// Response formats negotiated with the format query parameter
+const (
+	formatText  = "text"
+	formatJSON  = "json"
+	formatJSONP = "jsonp"
+)
+
+// negotiatedFormat returns the response format requested with ?format=, defaulting to plain text
+func negotiatedFormat(r *http.Request) string {
+	format := queryValue(r, "format")
+	switch {
+	case isJSONPFormat(format):
+		return formatJSONP
+	case isJSONFormat(format):
+		return formatJSON
+	}
+	return formatText
+}
+
+// writeError reports an error in the given response format. JSON and JSONP clients receive an
+// ErrorResponse with the machine-readable code; plain text clients receive the message, or a
+// problem+json response for 5xx and 429 statuses.
+func writeError(w http.ResponseWriter, r *http.Request, format string, status int, code, message string) {
+	if format == formatText {
+		if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+			problem.Error(w, r, status, message)
+			return
+		}
+		http.Error(w, message, status)
+		return
+	}
+
+	writeErrorResponse(w, r, format, &models.ErrorResponse{Error: code, Message: message, Status: status})
+}
+
+// writeErrorResponse writes response as a JSON or JSONP error body, adding the request ID
+func writeErrorResponse(w http.ResponseWriter, r *http.Request, format string, response *models.ErrorResponse) {
+	status := response.Status
+	problem.Hints(w, status)
+
+	response.RequestID = requestid.FromContext(r.Context())
+	body, _ := json.Marshal(response)
+
+	if format == formatJSONP {
+		w.Header().Set("Content-Type", "application/javascript")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "%s(%s);", sanitizeCallback(r.URL.Query().Get("callback")), body)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
+
+// NotFoundHandler answers requests for paths without a route with 404 and the available
+// endpoints, as returned by endpoints: in plain text, or as an ErrorResponse for ?format=json,
+// ?format=jsonp, or an Accept header asking for JSON
+func NotFoundHandler(endpoints func() []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := fallbackFormat(r)
+		if format == formatText {
+			http.Error(w, "404 page not found\n\nAvailable endpoints:\n"+strings.Join(endpoints(), "\n"), http.StatusNotFound)
+			return
+		}
+		writeErrorResponse(w, r, format, &models.ErrorResponse{
+			Error:     models.ErrorNotFound,
+			Message:   "No endpoint at " + r.URL.Path,
+			Status:    http.StatusNotFound,
+			Endpoints: endpoints(),
+		})
+	}
+}
+
+// MethodNotAllowedHandler answers requests for a path that does not support their method with
+// 405, in the formats of NotFoundHandler. The router sets the Allow header.
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	format := fallbackFormat(r)
+	if format == formatText {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeErrorResponse(w, r, format, &models.ErrorResponse{
+		Error:   models.ErrorMethodNotAllowed,
+		Message: r.Method + " is not supported by " + r.URL.Path,
+		Status:  http.StatusMethodNotAllowed,
+	})
+}
+
+// fallbackFormat returns the format of a response for a request no route matched, which may
+// come from an API client that sets Accept rather than ?format=
+func fallbackFormat(r *http.Request) string {
+	if format := negotiatedFormat(r); format != formatText {
+		return format
+	}
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		return formatJSON
+	}
+	return formatText
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"myip/internal/models"
+)
+
+func TestNotFoundHonorsFormat(t *testing.T) {
+	tests := []struct {
+		target      string
+		contentType string
+		prefix      string
+	}{
+		{"/ipv6", "text/plain; charset=utf-8", ""},
+		{"/ipv6?format=json", "application/json", "{"},
+		{"/ipv6?format=JSON", "application/json", "{"},
+		{"/ipv6?format=jsonp&callback=getip", "application/javascript", "getip("},
+		{"/ipv6?format=jsonp&callback=alert(1)", "application/javascript", "callback("},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.target, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.target, nil)
+			req.RemoteAddr = "203.0.113.1:12345"
+			rr := httptest.NewRecorder()
+			IPv6Handler(rr, req)
+
+			if rr.Code != http.StatusNotFound {
+				t.Fatalf("Expected status 404, got %d", rr.Code)
+			}
+			if got := rr.Header().Get("Content-Type"); got != tc.contentType {
+				t.Errorf("Expected Content-Type %q, got %q", tc.contentType, got)
+			}
+			if rr.Header().Get("Retry-After") != "" {
+				t.Error("Expected no Retry-After on 404")
+			}
+
+			body := strings.TrimSpace(rr.Body.String())
+			if tc.prefix == "" {
+				if body != "No IPv6 address found" {
+					t.Errorf("Expected plain text message, got %q", body)
+				}
+				return
+			}
+
+			if !strings.HasPrefix(body, tc.prefix) {
+				t.Fatalf("Expected body to start with %q, got %q", tc.prefix, body)
+			}
+			if tc.prefix != "{" {
+				body = strings.TrimSuffix(strings.TrimPrefix(body, tc.prefix), ");")
+			}
+
+			var response models.ErrorResponse
+			if err := json.Unmarshal([]byte(body), &response); err != nil {
+				t.Fatalf("Failed to decode error response %q: %v", body, err)
+			}
+			expected := models.ErrorResponse{
+				Error:   models.ErrorIPv6NotFound,
+				Message: "No IPv6 address found",
+				Status:  http.StatusNotFound,
+			}
+			if !reflect.DeepEqual(response, expected) {
+				t.Errorf("Expected %+v, got %+v", expected, response)
+			}
+		})
+	}
+}
+
+func TestIPv4NotFoundCode(t *testing.T) {
+	req := httptest.NewRequest("GET", "/?format=json", nil)
+	req.RemoteAddr = "[2001:db8::1]:12345"
+	rr := httptest.NewRecorder()
+	IPv4Handler(rr, req)
+
+	var response models.ErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode error response: %v", err)
+	}
+	if rr.Code != http.StatusNotFound || response.Error != models.ErrorIPv4NotFound {
+		t.Errorf("Expected 404 %s, got %d %+v", models.ErrorIPv4NotFound, rr.Code, response)
+	}
+}
+
+func TestWriteErrorServerErrors(t *testing.T) {
+	for _, format := range []string{formatText, formatJSON, formatJSONP} {
+		t.Run(format, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			rr.Header().Set("Content-Length", "42")
+			writeError(rr, httptest.NewRequest("GET", "/json", nil), format,
+				http.StatusInternalServerError, models.ErrorEncodingFailed, "Failed to encode JSON response")
+
+			if rr.Code != http.StatusInternalServerError {
+				t.Errorf("Expected status 500, got %d", rr.Code)
+			}
+			if rr.Header().Get("Retry-After") == "" {
+				t.Error("Expected Retry-After on 500")
+			}
+			if rr.Header().Get("Content-Length") != "" {
+				t.Error("Expected stale Content-Length to be removed")
+			}
+			if format == formatText {
+				if got := rr.Header().Get("Content-Type"); got != "application/problem+json" {
+					t.Errorf("Expected problem+json for plain text clients, got %q", got)
+				}
+				return
+			}
+			if !strings.Contains(rr.Body.String(), `"error":"encoding_failed"`) {
+				t.Errorf("Expected error code in body, got %q", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestNotFoundHandler(t *testing.T) {
+	handler := NotFoundHandler(func() []string { return []string{"GET /", "GET /json"} })
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest("GET", "/foo", nil))
+	if rr.Code != http.StatusNotFound || !strings.HasPrefix(rr.Body.String(), "404 page not found") ||
+		!strings.Contains(rr.Body.String(), "Available endpoints:\nGET /\nGET /json") {
+		t.Errorf("Unexpected plain text 404 %d %q", rr.Code, rr.Body.String())
+	}
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/foo?format=json", nil),
+		func() *http.Request {
+			req := httptest.NewRequest("GET", "/foo", nil)
+			req.Header.Set("Accept", "application/json")
+			return req
+		}(),
+	} {
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		var response models.ErrorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode %q: %v", rr.Body.String(), err)
+		}
+		expected := models.ErrorResponse{
+			Error:     models.ErrorNotFound,
+			Message:   "No endpoint at /foo",
+			Status:    http.StatusNotFound,
+			Endpoints: []string{"GET /", "GET /json"},
+		}
+		if !reflect.DeepEqual(response, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, response)
+		}
+	}
+}
+
+func TestMethodNotAllowedHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	MethodNotAllowedHandler(rr, httptest.NewRequest("POST", "/json", nil))
+	if rr.Code != http.StatusMethodNotAllowed || strings.TrimSpace(rr.Body.String()) != "Method Not Allowed" {
+		t.Errorf("Unexpected plain text 405 %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	MethodNotAllowedHandler(rr, httptest.NewRequest("POST", "/json?format=json", nil))
+	var response models.ErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error != models.ErrorMethodNotAllowed || response.Status != http.StatusMethodNotAllowed {
+		t.Errorf("Unexpected JSON 405 %+v", response)
+	}
+}
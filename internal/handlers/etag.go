@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"myip/internal/models"
+)
+
+// FNV-64a parameters
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// fnv64a adds s to the FNV-64a hash h, without the allocation of hash/fnv's interface
+func fnv64a(h uint64, s string) uint64 {
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime64
+	}
+	return h
+}
+
+// infoETag returns a weak entity tag for the JSON response of info to r from stable, the part of
+// its infoKey leaving out the timestamp and the CDN request ID, which change on every request. So
+// pollers checking whether their address changed can revalidate with If-None-Match, the tag also
+// leaves out the detection time and covers the query and proxy chain instead. Responses with
+// enrichment sections, which change with the providers' data, have an empty stable key and get
+// no tag.
+func infoETag(r *http.Request, info *models.IPInfo, stable string) string {
+	if stable == "" {
+		return ""
+	}
+
+	h := fnv64a(fnvOffset64, stable)
+	h = fnv64a(h, "\x00")
+	h = fnv64a(h, r.URL.RawQuery)
+	for _, hop := range info.Hops {
+		h = fnv64a(h, "\x02")
+		h = fnv64a(h, hop.IP)
+		h = fnv64a(h, "\x00")
+		h = fnv64a(h, hop.Source)
+		h = fnv64a(h, "\x00")
+		h = fnv64a(h, hop.Class)
+		h = fnv64a(h, string(flag(hop.Trusted)))
+		h = fnv64a(h, hop.Via)
+	}
+
+	var buf [24]byte
+	tag := append(buf[:0], `W/"`...)
+	tag = strconv.AppendUint(tag, h, 16)
+	tag = append(tag, '"')
+	return string(tag)
+}
+
+// notModified reports whether the request's If-None-Match matches etag, comparing weakly as
+// RFC 9110 requires for If-None-Match
+func notModified(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNotModified answers a conditional request whose representation is unchanged
+func writeNotModified(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusNotModified)
+}
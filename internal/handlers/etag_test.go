@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"myip/internal/models"
+)
+
+func TestJSONHandlerETag(t *testing.T) {
+	request := func(target, remoteAddr, ifNoneMatch string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.RemoteAddr = remoteAddr
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rr := httptest.NewRecorder()
+		JSONHandler(rr, req)
+		return rr
+	}
+
+	rr := request("/json", "203.0.113.1:1234", "")
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("Expected 200 with a weak ETag, got %d %q", rr.Code, etag)
+	}
+
+	// Revalidation answers 304 without a body while the address stays the same
+	for _, ifNoneMatch := range []string{etag, strings.TrimPrefix(etag, "W/"), `"other", ` + etag, "*"} {
+		rr = request("/json", "203.0.113.1:1234", ifNoneMatch)
+		if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 || rr.Header().Get("ETag") != etag {
+			t.Errorf("If-None-Match %s: expected an empty 304 with the ETag, got %d %q", ifNoneMatch, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Behind a CDN, every request carries a new request ID
+	rr = request("/json", "203.0.113.1:1234", "", "Cf-Ray", "8a1b2c3d4e5f6a7b-AMS")
+	cdnTag := rr.Header().Get("ETag")
+	if rr = request("/json", "203.0.113.1:1234", cdnTag, "Cf-Ray", "9c8d7e6f5a4b3c2d-FRA"); rr.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a new Cf-Ray, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// A new address or another representation is sent in full
+	if rr = request("/json", "203.0.113.2:1234", etag); rr.Code != http.StatusOK || rr.Header().Get("ETag") == etag {
+		t.Errorf("Expected 200 with a new ETag for a new address, got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+	if rr = request("/json?fields=client_ip", "203.0.113.1:1234", etag); rr.Code != http.StatusOK || rr.Header().Get("ETag") == etag {
+		t.Errorf("Expected 200 with a new ETag for selected fields, got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+}
+
+func TestInfoETag(t *testing.T) {
+	req := httptest.NewRequest("GET", "/json", nil)
+	tag := func(info *models.IPInfo) string {
+		key, stable := infoKey(info)
+		return infoETag(req, info, key[:stable])
+	}
+	info := &models.IPInfo{ClientIP: "203.0.113.1", Timestamp: "2024-01-01T00:00:00Z", DetectionMs: 0.1,
+		CDN: &models.CDNTrace{Provider: "cloudflare", RayID: "8a1b2c3d4e5f6a7b-AMS"}}
+	etag := tag(info)
+
+	later := *info
+	later.Timestamp, later.DetectionMs = "2024-01-01T00:00:05Z", 0.2
+	later.CDN = &models.CDNTrace{Provider: "cloudflare", RayID: "9c8d7e6f5a4b3c2d-FRA"}
+	if tag(&later) != etag {
+		t.Error("Expected the timestamp, detection time, and CDN request ID to leave the ETag unchanged")
+	}
+
+	other := *info
+	other.CDN = &models.CDNTrace{Provider: "cloudfront", RayID: "8a1b2c3d4e5f6a7b-AMS"}
+	if tag(&other) == etag {
+		t.Error("Expected the CDN provider to change the ETag")
+	}
+
+	verbose := *info
+	verbose.Hops = []models.Hop{{IP: "203.0.113.1", Source: "RemoteAddr", Class: "public"}}
+	if tag(&verbose) == etag {
+		t.Error("Expected the hops to change the ETag")
+	}
+
+	if infoETag(req, info, "") != "" {
+		t.Error("Expected no ETag for responses with enrichment, which have no key")
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"myip/internal/models"
+)
+
+// jsonField locates a struct field by its JSON name
+type jsonField struct {
+	index     int
+	omitEmpty bool
+}
+
+// infoFields maps the JSON names of the models.IPInfo fields selectable with ?fields=
+var infoFields = jsonFields(reflect.TypeFor[models.IPInfo]())
+
+// jsonFields returns the JSON-encoded fields of a struct type by name
+func jsonFields(t reflect.Type) map[string]jsonField {
+	fields := make(map[string]jsonField, t.NumField())
+	for i := range t.NumField() {
+		name, options, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = jsonField{index: i, omitEmpty: options == "omitempty"}
+	}
+	return fields
+}
+
+// parseFields parses ?fields=, a comma-separated list of IPInfo JSON field names, dropping
+// duplicates. It returns nil when no fields are requested.
+func parseFields(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || slices.Contains(fields, name) {
+			continue
+		}
+		if _, ok := infoFields[name]; !ok {
+			names := make([]string, 0, len(infoFields))
+			for known := range infoFields {
+				names = append(names, known)
+			}
+			slices.Sort(names)
+			return nil, fmt.Errorf("unknown field %q, valid fields are %s", name, strings.Join(names, ", "))
+		}
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+// selectFields encodes the requested fields of info as a JSON object, in the requested order.
+// Optional fields that are empty are left out, as in the full response.
+func selectFields(info *models.IPInfo, fields []string) ([]byte, error) {
+	v := reflect.ValueOf(info).Elem()
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, name := range fields {
+		field := infoFields[name]
+		value := v.Field(field.index)
+		if field.omitEmpty && isEmptyValue(value) {
+			continue
+		}
+		encoded, err := json.Marshal(value.Interface())
+		if err != nil {
+			return nil, err
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, "%q:", name)
+		buf.Write(encoded)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// isEmptyValue reports whether encoding/json omits v from a field tagged omitempty
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"myip/internal/models"
+)
+
+func TestParseFields(t *testing.T) {
+	fields, err := parseFields(" client_ip,ipv4_address,,client_ip ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(fields, ",") != "client_ip,ipv4_address" {
+		t.Errorf("Unexpected fields %v", fields)
+	}
+
+	if fields, err := parseFields(""); fields != nil || err != nil {
+		t.Errorf("Expected no fields, got %v %v", fields, err)
+	}
+
+	_, err = parseFields("client_ip,ClientIP")
+	if err == nil || !strings.Contains(err.Error(), `"ClientIP"`) || !strings.Contains(err.Error(), "is_cloudflare") {
+		t.Errorf("Expected an error listing the valid fields, got %v", err)
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	info := &models.IPInfo{ClientIP: "203.0.113.1", IsCloudflare: true}
+
+	body, err := selectFields(info, []string{"is_cloudflare", "ipv6_address", "client_ip", "port", "all_candidates"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"is_cloudflare":true,"ipv6_address":"","client_ip":"203.0.113.1"}`; string(body) != want {
+		t.Errorf("selectFields() = %s, want %s", body, want)
+	}
+
+	if body, _ := selectFields(info, []string{"port"}); string(body) != "{}" {
+		t.Errorf("Expected an empty object for an omitted field, got %s", body)
+	}
+}
+
+func TestJSONHandlerFields(t *testing.T) {
+	req := httptest.NewRequest("GET", "/json?fields=client_ip,ipv4_address,is_cloudflare", nil)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+	req.RemoteAddr = "192.168.1.1:12345"
+
+	rr := httptest.NewRecorder()
+	JSONHandler(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected a JSON response, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if want := "{\"client_ip\":\"203.0.113.1\",\"ipv4_address\":\"203.0.113.1\",\"is_cloudflare\":true}\n"; rr.Body.String() != want {
+		t.Errorf("Expected %q, got %q", want, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	JSONHandler(rr, httptest.NewRequest("GET", "/json?fields=client_ip,region", nil))
+	var response models.ErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusBadRequest || response.Error != models.ErrorInvalidFields {
+		t.Errorf("Expected 400 invalid_fields, got %d %+v", rr.Code, response)
+	}
+}
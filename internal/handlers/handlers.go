@@ -1,16 +1,226 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"net/netip"
 	"regexp"
-
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"myip/internal/eui64"
+	"myip/internal/i18n"
 	"myip/internal/ip"
 	"myip/internal/models"
+	"myip/internal/privacy"
+	"myip/internal/problem"
+	"myip/internal/respcache"
+)
+
+// responseCache holds pre-serialized responses for plain /, /ipv6, and /json requests; caching is off while it is unset
+var responseCache atomic.Pointer[respcache.Cache]
+
+// SetResponseCache enables the response cache for /, /ipv6, and /json requests without query parameters;
+// a nil cache disables it
+func SetResponseCache(cache *respcache.Cache) {
+	responseCache.Store(cache)
+}
+
+// PlainText controls the formatting of plain-text IP responses. The zero value matches ipify
+// byte for byte: the bare address served as text/plain.
+type PlainText struct {
+	// Newline appends "\n" to the address; ?newline= overrides it per request
+	Newline bool
+
+	// Charset declares charset=utf-8 in the Content-Type
+	Charset bool
+}
+
+// plainText holds the plain-text response options; ipify formatting is used while it is unset
+var plainText atomic.Pointer[PlainText]
+
+// SetPlainText sets the formatting of plain-text IP responses
+func SetPlainText(options PlainText) {
+	plainText.Store(&options)
+}
+
+// Content-Type values the IP endpoints assign straight into the header map, sparing Header.Set
+// an allocation per response; they are shared between responses and never modified
+var (
+	textPlainType        = []string{"text/plain"}
+	textPlainCharsetType = []string{"text/plain; charset=utf-8"}
+	jsonType             = []string{"application/json"}
+	javascriptType       = []string{"application/javascript"}
 )
 
+// writePlainIP writes addr (or another plain-text value such as a port) as a plain-text response,
+// from the response cache for requests without query parameters
+func writePlainIP(w http.ResponseWriter, r *http.Request, addr string) {
+	var options PlainText
+	if p := plainText.Load(); p != nil {
+		options = *p
+	}
+
+	contentType := textPlainType
+	if options.Charset {
+		contentType = textPlainCharsetType
+	}
+
+	if r.URL.RawQuery != "" {
+		if newline, ok := queryBool(r, "newline"); ok {
+			options.Newline = newline
+		}
+	} else if cache := responseCache.Load(); cache != nil {
+		body := addr
+		if options.Newline {
+			body += "\n"
+		}
+		response, _ := cache.Get(body, func() ([]byte, error) { return []byte(body), nil })
+		respcache.Write(w, r, contentType[0], response)
+		return
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	buf.WriteString(addr)
+	if options.Newline {
+		buf.WriteByte('\n')
+	}
+
+	w.Header()["Content-Type"] = contentType
+	w.Write(buf.Bytes())
+}
+
+// writeIPObject writes addr as the JSON object {"ip":addr}, wrapped in the request's callback for
+// JSONP. It assembles the response by hand rather than through encoding/json, producing the same
+// bytes: a trailing newline for JSON, as json.Encoder writes, and none inside the JSONP call.
+func writeIPObject(w http.ResponseWriter, r *http.Request, format, addr string) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if format == formatJSONP {
+		buf.WriteString(sanitizeCallback(queryValue(r, "callback")))
+		buf.WriteByte('(')
+	}
+	buf.WriteString(`{"ip":`)
+	writeJSONString(buf, addr)
+	buf.WriteByte('}')
+
+	header := w.Header()
+	if format == formatJSONP {
+		buf.WriteString(");")
+		header["Content-Type"] = javascriptType
+		w.Write(buf.Bytes())
+		return
+	}
+
+	buf.WriteByte('\n')
+	header["Content-Type"] = jsonType
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Failed to encode JSON response for %s: %v", addr, err)
+		writeError(w, r, format, http.StatusInternalServerError, models.ErrorEncodingFailed, "Failed to encode JSON response")
+	}
+}
+
+// writeJSONString writes s as a JSON string. Addresses made of digits, hex letters, dots, and
+// colons need no escaping; anything else, such as an IPv6 zone, goes through encoding/json.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F' || c == '.' || c == ':') {
+			quoted, _ := json.Marshal(s)
+			buf.Write(quoted)
+			return
+		}
+	}
+	buf.WriteByte('"')
+	buf.WriteString(s)
+	buf.WriteByte('"')
+}
+
+// infoKey identifies the JSON serialization of info. It must include every field of
+// models.IPInfo except the enrichment sections, which are never cached. The timestamp and the CDN
+// request ID, which change on every request, come last: key[:stable] covers everything else and
+// is what infoETag hashes.
+func infoKey(info *models.IPInfo) (key string, stable int) {
+	var b strings.Builder
+	b.Grow(128 + len(info.UserAgent))
+	for _, part := range []string{
+		info.ClientIP, info.DetectedVia, info.IPv4Address, info.IPv6Address,
+		info.UserAgent, info.IPType, info.Warning, info.TunnelType, info.EmbeddedIPv4,
+	} {
+		b.WriteString(part)
+		b.WriteByte(0)
+	}
+	b.WriteString(strconv.Itoa(info.Port))
+	b.WriteByte(0)
+	b.WriteByte(flag(info.IsPrivateIP))
+	b.WriteByte(flag(info.IsCloudflare))
+	b.WriteByte(flag(info.SpoofingSuspected))
+	switch {
+	case info.IsListed == nil:
+		b.WriteByte('-')
+	default:
+		b.WriteByte(flag(*info.IsListed))
+	}
+	for _, feed := range info.ThreatFeeds {
+		b.WriteByte(0)
+		b.WriteString(feed)
+	}
+	for _, reason := range info.SpoofingReasons {
+		b.WriteByte(3)
+		b.WriteString(reason)
+	}
+	for _, candidate := range info.AllCandidates {
+		b.WriteByte(2)
+		b.WriteString(candidate.IP)
+		b.WriteByte(0)
+		b.WriteString(candidate.Source)
+	}
+	if info.CDN != nil {
+		b.WriteByte(1)
+		b.WriteString(info.CDN.Provider)
+	}
+	if c := info.Confidence; c != nil {
+		b.WriteByte(4)
+		b.WriteString(strconv.FormatFloat(c.Score, 'f', 2, 64))
+		b.WriteByte(0)
+		b.WriteString(c.Level)
+		for _, factor := range c.Factors {
+			b.WriteByte(0)
+			b.WriteString(factor)
+		}
+		for _, header := range c.Fallback {
+			b.WriteByte(5)
+			b.WriteString(header)
+		}
+	}
+
+	stable = b.Len()
+	b.WriteByte(6)
+	b.WriteString(info.Timestamp)
+	if info.CDN != nil {
+		b.WriteByte(0)
+		b.WriteString(info.CDN.RayID)
+	}
+	return b.String(), stable
+}
+
+// flag encodes a boolean as a single key byte
+func flag(value bool) byte {
+	if value {
+		return '1'
+	}
+	return '0'
+}
+
 // isJSONFormat checks if format parameter equals "json" case-insensitively
 // Optimized for performance - avoids string allocation from ToLower()
 func isJSONFormat(format string) bool {
@@ -63,231 +273,550 @@ func sanitizeCallback(callback string) string {
 }
 
 // IPv4Handler handles requests for IPv4 addresses only
-// @Summary Get IPv4 address
-// @Description Returns the client's IPv4 address in plain text format, JSON format if format=json, or JSONP format if format=jsonp is specified (case-insensitive). Callback parameter only works with format=jsonp.
-// @Tags IP Detection
-// @Accept json
-// @Produce plain,json
-// @Param format query string false "Response format (json for JSON response, jsonp for JSONP response)"
-// @Param callback query string false "Callback function name for JSONP response. Only works with format=jsonp. Without format=jsonp, callback parameter is ignored and returns plain text (ipify.org compatible behavior). (default: callback)"
-// @Success 200 {string} string "IPv4 address (plain text)"
-// @Success 200 {object} map[string]string "IP address in JSON format: {\"ip\": \"192.168.1.1\"}"
-// @Success 200 {string} string "IP address in JSONP format: callback({\"ip\": \"192.168.1.1\"}) or getip({\"ip\": \"192.168.1.1\"}) with custom callback"
-// @Failure 404 {string} string "No IPv4 address found"
-// @Router / [get]
 func IPv4Handler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
 	ipv4 := ip.FindIPv4(r)
+	format := negotiatedFormat(r)
 
 	if ipv4 == "" {
-		http.Error(w, "No IPv4 address found", http.StatusNotFound)
+		writeError(w, r, format, http.StatusNotFound, models.ErrorIPv4NotFound, "No IPv4 address found")
 		return
 	}
 
-	// Check format parameter
-	format := r.URL.Query().Get("format")
-	callback := r.URL.Query().Get("callback")
+	if verbose, _ := queryBool(r, "verbose"); verbose {
+		writeDetectedIP(w, r, format, ipv4, start)
+		return
+	}
 
-	// Check if JSONP format is requested (case-insensitive, optimized)
-	if isJSONPFormat(format) {
-		sanitizedCallback := sanitizeCallback(callback)
+	if format != formatText {
+		writeIPObject(w, r, format, ipv4)
+		return
+	}
 
-		w.Header().Set("Content-Type", "application/javascript")
+	// Default plain text response
+	writePlainIP(w, r, ipv4)
+}
 
-		// Use proper JSON encoding to prevent injection attacks
-		response := map[string]string{"ip": ipv4}
-		jsonBytes, err := json.Marshal(response)
-		if err != nil {
-			log.Printf("Failed to encode JSONP response for IPv4 %s: %v", ipv4, err)
-			http.Error(w, "Failed to encode JSONP response", http.StatusInternalServerError)
-			return
-		}
+// IPv6Handler handles requests for IPv6 addresses only
+func IPv6Handler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ipv6 := ip.FindIPv6(r)
+	format := negotiatedFormat(r)
 
-		fmt.Fprintf(w, "%s(%s);", sanitizedCallback, string(jsonBytes))
+	if ipv6 == "" {
+		writeError(w, r, format, http.StatusNotFound, models.ErrorIPv6NotFound, "No IPv6 address found")
 		return
 	}
 
-	// Check if JSON format is requested (case-insensitive, optimized)
-	if isJSONFormat(format) {
-		w.Header().Set("Content-Type", "application/json")
-		response := map[string]string{"ip": ipv4}
-
-		if err := json.NewEncoder(w).Encode(response); err != nil {
-			log.Printf("Failed to encode JSON response for IPv4 %s: %v", ipv4, err)
-			http.Error(w, "Failed to encode JSON response", http.StatusInternalServerError)
-			return
+	// Expand the address when the compressed form was declined
+	if compress, ok := queryBool(r, "compress"); ok && !compress {
+		if addr, err := netip.ParseAddr(ipv6); err == nil {
+			ipv6 = addr.StringExpanded()
 		}
+	}
+
+	if verbose, _ := queryBool(r, "verbose"); verbose {
+		writeDetectedIP(w, r, format, ipv6, start)
+		return
+	}
+
+	if format != formatText {
+		writeIPObject(w, r, format, ipv6)
 		return
 	}
 
 	// Default plain text response
-	w.Header().Set("Content-Type", "text/plain")
-	fmt.Fprint(w, ipv4)
+	writePlainIP(w, r, ipv6)
 }
 
-// IPv6Handler handles requests for IPv6 addresses only
-// @Summary Get IPv6 address
-// @Description Returns the client's IPv6 address in plain text format, JSON format if format=json, or JSONP format if format=jsonp is specified (case-insensitive). Callback parameter only works with format=jsonp.
-// @Tags IP Detection
-// @Accept json
-// @Produce plain,json
-// @Param format query string false "Response format (json for JSON response, jsonp for JSONP response)"
-// @Param callback query string false "Callback function name for JSONP response. Only works with format=jsonp. Without format=jsonp, callback parameter is ignored and returns plain text (ipify.org compatible behavior). (default: callback)"
-// @Success 200 {string} string "IPv6 address (plain text)"
-// @Success 200 {object} map[string]string "IP address in JSON format: {\"ip\": \"2001:db8::1\"}"
-// @Success 200 {string} string "IP address in JSONP format: callback({\"ip\": \"2001:db8::1\"}) or getip({\"ip\": \"2001:db8::1\"}) with custom callback"
-// @Failure 404 {string} string "No IPv6 address found"
-// @Router /ipv6 [get]
-func IPv6Handler(w http.ResponseWriter, r *http.Request) {
+// IPv6ExpandHandler returns the compressed and fully expanded forms of the client's IPv6 address
+// and its /64 prefix
+func IPv6ExpandHandler(w http.ResponseWriter, r *http.Request) {
 	ipv6 := ip.FindIPv6(r)
-
 	if ipv6 == "" {
-		http.Error(w, "No IPv6 address found", http.StatusNotFound)
+		writeError(w, r, formatJSON, http.StatusNotFound, models.ErrorIPv6NotFound, "No IPv6 address found")
 		return
 	}
 
-	// Check format parameter
-	format := r.URL.Query().Get("format")
-	callback := r.URL.Query().Get("callback")
+	addr, err := netip.ParseAddr(ipv6)
+	if err != nil {
+		writeError(w, r, formatJSON, http.StatusNotFound, models.ErrorIPv6NotFound, "No IPv6 address found")
+		return
+	}
+	response := &models.IPv6Forms{
+		IP:         ipv6,
+		Compressed: addr.String(),
+		Expanded:   addr.StringExpanded(),
+		Prefix64:   netip.PrefixFrom(addr, 64).Masked().String(),
+	}
 
-	// Check if JSONP format is requested (case-insensitive, optimized)
-	if isJSONPFormat(format) {
-		sanitizedCallback := sanitizeCallback(callback)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, r, formatJSON, http.StatusInternalServerError, models.ErrorEncodingFailed, "Failed to encode JSON response")
+		return
+	}
+}
 
-		w.Header().Set("Content-Type", "application/javascript")
+// IPv6AnalyzeHandler reports whether the interface identifier of the client's IPv6 address
+// embeds its MAC address or is randomized by privacy extensions
+func IPv6AnalyzeHandler(w http.ResponseWriter, r *http.Request) {
+	addr, err := netip.ParseAddr(ip.FindIPv6(r))
+	if err != nil {
+		writeError(w, r, formatJSON, http.StatusNotFound, models.ErrorIPv6NotFound, "No IPv6 address found")
+		return
+	}
 
-		// Use proper JSON encoding to prevent injection attacks
-		response := map[string]string{"ip": ipv6}
-		jsonBytes, err := json.Marshal(response)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(eui64.Analyze(addr.WithZone(""))); err != nil {
+		writeError(w, r, formatJSON, http.StatusInternalServerError, models.ErrorEncodingFailed, "Failed to encode JSON response")
+		return
+	}
+}
+
+// NAT64Handler reports whether an IPv6 address is in a NAT64 prefix and the IPv4 address it maps
+// to. It checks ?ip= when given, such as an address DNS64 synthesized for ipv4only.arpa, and
+// otherwise the client's IPv6 address.
+func NAT64Handler(w http.ResponseWriter, r *http.Request) {
+	var addr netip.Addr
+	if value := r.URL.Query().Get("ip"); value != "" {
+		parsed, err := netip.ParseAddr(value)
+		if err != nil || !parsed.Is6() || parsed.Is4In6() {
+			writeError(w, r, formatJSON, http.StatusBadRequest, models.ErrorInvalidIP, "Invalid ip parameter: expected an IPv6 address")
+			return
+		}
+		addr = parsed
+	} else {
+		parsed, err := netip.ParseAddr(ip.FindIPv6(r))
 		if err != nil {
-			log.Printf("Failed to encode JSONP response for IPv6 %s: %v", ipv6, err)
-			http.Error(w, "Failed to encode JSONP response", http.StatusInternalServerError)
+			writeError(w, r, formatJSON, http.StatusNotFound, models.ErrorIPv6NotFound, "No IPv6 address found")
 			return
 		}
+		addr = parsed
+	}
+	addr = addr.WithZone("")
 
-		fmt.Fprintf(w, "%s(%s);", sanitizedCallback, string(jsonBytes))
+	response := &models.NAT64Info{IP: addr.String()}
+	for _, prefix := range ip.NAT64Prefixes() {
+		response.Prefixes = append(response.Prefixes, prefix.String())
+	}
+	if prefix, ipv4, ok := ip.NAT64(addr); ok {
+		response.NAT64 = true
+		response.Prefix = prefix.String()
+		response.EmbeddedIPv4 = ipv4.String()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, r, formatJSON, http.StatusInternalServerError, models.ErrorEncodingFailed, "Failed to encode JSON response")
 		return
 	}
+}
 
-	// Check if JSON format is requested (case-insensitive, optimized)
-	if isJSONFormat(format) {
-		w.Header().Set("Content-Type", "application/json")
-		response := map[string]string{"ip": ipv6}
+// BothHandler returns the client's IPv4 and IPv6 addresses in one response, so dual-stack
+// clients need not query / and /ipv6 separately and handle their 404s
+func BothHandler(w http.ResponseWriter, r *http.Request) {
+	var response models.DualStack
+	addresses := ip.Extract(r)
+	if addresses.IPv4 != "" {
+		response.IPv4 = &addresses.IPv4
+	}
+	if addresses.IPv6 != "" {
+		response.IPv6 = &addresses.IPv6
+	}
 
-		if err := json.NewEncoder(w).Encode(response); err != nil {
-			log.Printf("Failed to encode JSON response for IPv6 %s: %v", ipv6, err)
-			http.Error(w, "Failed to encode JSON response", http.StatusInternalServerError)
-			return
-		}
+	jsonBytes, err := json.Marshal(&response)
+	if err != nil {
+		writeError(w, r, formatJSON, http.StatusInternalServerError, models.ErrorEncodingFailed, "Failed to encode JSON response")
 		return
 	}
 
-	// Default plain text response
-	w.Header().Set("Content-Type", "text/plain")
-	fmt.Fprint(w, ipv6)
+	if negotiatedFormat(r) == formatJSONP {
+		w.Header().Set("Content-Type", "application/javascript")
+		fmt.Fprintf(w, "%s(%s);", sanitizeCallback(r.URL.Query().Get("callback")), jsonBytes)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(jsonBytes, '\n'))
+}
+
+// PortHandler returns the client's source TCP port, as translated by any NAT along the way
+func PortHandler(w http.ResponseWriter, r *http.Request) {
+	port := ip.ClientPort(r)
+	format := negotiatedFormat(r)
+
+	if port == 0 {
+		writeError(w, r, format, http.StatusNotFound, models.ErrorPortUnavailable, "Source port not available behind a proxy")
+		return
+	}
+
+	switch format {
+	case formatJSONP:
+		w.Header().Set("Content-Type", "application/javascript")
+		fmt.Fprintf(w, "%s({\"port\":%d});", sanitizeCallback(r.URL.Query().Get("callback")), port)
+	case formatJSON:
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, "{\"port\":%d}\n", port)
+	default:
+		writePlainIP(w, r, strconv.Itoa(port))
+	}
 }
 
 // InfoHandler provides detailed IP information in plain text
-// @Summary Get detailed IP information
-// @Description Returns comprehensive IP information including detection method, private IP status, and Cloudflare detection in plain text format
-// @Tags IP Detection
-// @Accept json
-// @Produce plain
-// @Success 200 {string} string "Detailed IP information in plain text"
-// @Router /info [get]
 func InfoHandler(w http.ResponseWriter, r *http.Request) {
 	info := ip.GetInfo(r)
+	defer ip.ReleaseInfo(info)
 
-	w.Header().Set("Content-Type", "text/plain")
+	if !localizeTimestamp(r, info) {
+		writeError(w, r, formatText, http.StatusBadRequest, models.ErrorInvalidTimezone, "Unknown time zone, expected an IANA name such as Europe/Berlin or auto")
+		return
+	}
+
+	if value := r.URL.Query().Get("template"); value != "" {
+		writeTemplate(w, info, value)
+		return
+	}
+
+	locale := i18n.Negotiate(r.Header.Get("Accept-Language"), defaultLocale())
+	m := i18n.Lookup(locale)
 
-	fmt.Fprintf(w, "Your IP Address: %s\n", info.ClientIP)
-	fmt.Fprintf(w, "Detection Method: %s\n", info.DetectedVia)
-	fmt.Fprintf(w, "Is Private IP: %t\n", info.IsPrivateIP)
-	fmt.Fprintf(w, "Behind Cloudflare: %t\n", info.IsCloudflare)
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	fmt.Fprintf(buf, "%s: %s\n", m.YourIP, info.ClientIP)
+	fmt.Fprintf(buf, "%s: %s\n", m.DetectionMethod, info.DetectedVia)
+	fmt.Fprintf(buf, "%s: %s\n", m.IsPrivateIP, m.Bool(info.IsPrivateIP))
+	fmt.Fprintf(buf, "%s: %s\n", m.BehindCloudflare, m.Bool(info.IsCloudflare))
 
 	if info.IPv4Address != "" {
-		fmt.Fprintf(w, "IPv4 Address: %s\n", info.IPv4Address)
+		fmt.Fprintf(buf, "%s: %s\n", m.IPv4Address, info.IPv4Address)
 	}
 	if info.IPv6Address != "" {
-		fmt.Fprintf(w, "IPv6 Address: %s\n", info.IPv6Address)
+		fmt.Fprintf(buf, "%s: %s\n", m.IPv6Address, info.IPv6Address)
+	}
+	if info.Port != 0 {
+		fmt.Fprintf(buf, "%s: %d\n", m.SourcePort, info.Port)
+	}
+	if info.IPType != "" {
+		fmt.Fprintf(buf, "%s: %s\n", m.IPType, info.IPType)
+	}
+	if info.Warning != "" {
+		fmt.Fprintf(buf, "%s: %s\n", m.Warning, info.Warning)
+	}
+	for _, reason := range info.SpoofingReasons {
+		fmt.Fprintf(buf, "%s: %s\n", m.SpoofingSuspected, reason)
+	}
+
+	fmt.Fprintf(buf, "%s: %s\n", m.Timestamp, info.Timestamp)
+	if info.LocalTimestamp != "" {
+		fmt.Fprintf(buf, "%s: %s (%s)\n", m.LocalTime, info.LocalTimestamp, info.Timezone)
 	}
 
-	fmt.Fprintf(w, "Timestamp: %s\n", info.Timestamp)
+	// English is plain ASCII and keeps the historical bare text/plain
+	header := w.Header()
+	header.Set("Content-Type", "text/plain")
+	if locale != i18n.Default {
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	header.Set("Content-Language", locale)
+	header.Add("Vary", "Accept-Language")
+	w.Write(buf.Bytes())
+}
+
+// infoLocale holds the locale of the /info page for requests without a supported Accept-Language
+var infoLocale atomic.Pointer[string]
+
+// SetDefaultLocale sets the locale of the /info page for requests whose Accept-Language names no
+// supported language
+func SetDefaultLocale(l string) {
+	infoLocale.Store(&l)
+}
+
+// defaultLocale returns the configured default locale, or i18n.Default when unset
+func defaultLocale() string {
+	if l := infoLocale.Load(); l != nil {
+		return *l
+	}
+	return i18n.Default
 }
 
 // JSONHandler provides comprehensive JSON response
-// @Summary Get IP information in JSON format
-// @Description Returns comprehensive IP information in JSON format including all detected addresses, detection method, and metadata
-// @Tags IP Detection
-// @Accept json
-// @Produce json
-// @Success 200 {object} models.IPInfo "IP information in JSON format"
-// @Failure 500 {string} string "Failed to encode JSON response"
-// @Router /json [get]
 func JSONHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
 	info := ip.GetInfo(r)
+	defer ip.ReleaseInfo(info)
 
-	w.Header().Set("Content-Type", "application/json")
+	writeInfo(w, r, info, time.Since(start))
+}
 
-	if err := json.NewEncoder(w).Encode(info); err != nil {
-		http.Error(w, "Failed to encode JSON response", http.StatusInternalServerError)
+// writeInfo encodes info as the JSON response, from the response cache for plain requests
+// without enrichment sections. ?verbose=1 adds the reconstructed proxy chain and the detection
+// time, ?fields= limits the response to a comma-separated list of fields, and ?tz= adds the
+// timestamp in a time zone. Responses carry an ETag, answering a matching If-None-Match with 304.
+func writeInfo(w http.ResponseWriter, r *http.Request, info *models.IPInfo, detection time.Duration) {
+	fields, err := parseFields(queryValue(r, "fields"))
+	if err != nil {
+		writeError(w, r, formatJSON, http.StatusBadRequest, models.ErrorInvalidFields, "Invalid fields: "+err.Error())
 		return
 	}
+	if !localizeTimestamp(r, info) {
+		writeError(w, r, formatJSON, http.StatusBadRequest, models.ErrorInvalidTimezone, "Unknown time zone, expected an IANA name such as Europe/Berlin or auto")
+		return
+	}
+
+	if verbose, _ := queryBool(r, "verbose"); verbose {
+		info.Hops = ip.Chain(r)
+		info.DetectionMs = durationMs(detection)
+	}
+
+	// The key identifies the response for both the ETag and the response cache
+	var key string
+	var stable int
+	if info.Enrichment == nil && info.Meta == nil {
+		key, stable = infoKey(info)
+	}
+
+	etag := infoETag(r, info, key[:stable])
+	if etag != "" {
+		if notModified(r, etag) {
+			writeNotModified(w, etag)
+			return
+		}
+		w.Header().Set("ETag", etag)
+	}
+
+	if fields != nil {
+		body, err := selectFields(info, fields)
+		if err != nil {
+			writeError(w, r, formatJSON, http.StatusInternalServerError, models.ErrorEncodingFailed, "Failed to encode JSON response")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(append(body, '\n'))
+		return
+	}
+
+	if cache := responseCache.Load(); cache != nil && r.URL.RawQuery == "" && key != "" {
+		response, err := cache.Get(key, func() ([]byte, error) {
+			body, err := json.Marshal(info)
+			return append(body, '\n'), err
+		})
+		if err != nil {
+			writeError(w, r, formatJSON, http.StatusInternalServerError, models.ErrorEncodingFailed, "Failed to encode JSON response")
+			return
+		}
+		respcache.Write(w, r, "application/json", response)
+		return
+	}
+
+	// Encode into a pooled buffer first, so a failed encoding can still be answered with a 500
+	buf := getJSONBuffer()
+	defer putJSONBuffer(buf)
+	if err := buf.encoder.Encode(info); err != nil {
+		writeError(w, r, formatJSON, http.StatusInternalServerError, models.ErrorEncodingFailed, "Failed to encode JSON response")
+		return
+	}
+
+	w.Header()["Content-Type"] = jsonType
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		writeError(w, r, formatJSON, http.StatusInternalServerError, models.ErrorEncodingFailed, "Failed to encode JSON response")
+	}
 }
 
-// HeadersHandler shows all HTTP headers and IP details for debugging
-// @Summary Debug headers and connection information
-// @Description Returns all HTTP headers, IP detection details, and connection information for debugging purposes
-// @Tags Debug
-// @Accept json
-// @Produce plain
-// @Success 200 {string} string "Complete debugging information including headers and connection details"
-// @Router /headers [get]
+// HeadersHandler shows all HTTP headers and IP details for debugging. ?filter= takes a
+// comma-separated list of case-insensitive header name prefixes, such as "X-Forwarded-,CF-", to
+// show only matching headers, and ?format=json returns them as a HeadersResponse.
 func HeadersHandler(w http.ResponseWriter, r *http.Request) {
 	info := ip.GetInfo(r)
+	defer ip.ReleaseInfo(info)
+
+	names := headerNames(r.Header, r.URL.Query().Get("filter"))
+
+	if format := negotiatedFormat(r); format != formatText {
+		response := &models.HeadersResponse{
+			ClientIP:    info.ClientIP,
+			DetectedVia: info.DetectedVia,
+			Headers:     make(map[string][]string, len(names)),
+			RemoteAddr:  r.RemoteAddr,
+			Method:      r.Method,
+			URL:         r.URL.String(),
+			Protocol:    r.Proto,
+		}
+		for _, name := range names {
+			response.Headers[name] = r.Header[name]
+		}
+
+		jsonBytes, err := json.Marshal(response)
+		if err != nil {
+			writeError(w, r, format, http.StatusInternalServerError, models.ErrorEncodingFailed, "Failed to encode JSON response")
+			return
+		}
+		if format == formatJSONP {
+			w.Header().Set("Content-Type", "application/javascript")
+			fmt.Fprintf(w, "%s(%s);", sanitizeCallback(r.URL.Query().Get("callback")), jsonBytes)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(append(jsonBytes, '\n'))
+		return
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	fmt.Fprintf(buf, "=== IP INFORMATION ===\n")
+	fmt.Fprintf(buf, "Client IP: %s\n", info.ClientIP)
+	fmt.Fprintf(buf, "Detection Method: %s\n", info.DetectedVia)
+	fmt.Fprintf(buf, "IPv4 Address: %s\n", info.IPv4Address)
+	fmt.Fprintf(buf, "IPv6 Address: %s\n", info.IPv6Address)
+	fmt.Fprintf(buf, "Is Private IP: %t\n", info.IsPrivateIP)
+	fmt.Fprintf(buf, "Behind Cloudflare: %t\n", info.IsCloudflare)
+	fmt.Fprintf(buf, "Timestamp: %s\n", info.Timestamp)
+
+	fmt.Fprintf(buf, "\n=== HTTP HEADERS ===\n")
+
+	for _, name := range names {
+		for _, value := range r.Header[name] {
+			fmt.Fprintf(buf, "%s: %s\n", name, value)
+		}
+	}
+
+	fmt.Fprintf(buf, "\n=== CONNECTION INFO ===\n")
+	fmt.Fprintf(buf, "Remote Address: %s\n", r.RemoteAddr)
+	fmt.Fprintf(buf, "Method: %s\n", r.Method)
+	fmt.Fprintf(buf, "URL: %s\n", r.URL.String())
+	fmt.Fprintf(buf, "Protocol: %s\n", r.Proto)
 
 	w.Header().Set("Content-Type", "text/plain")
+	w.Write(buf.Bytes())
+}
+
+// headerNames returns the sorted names of the headers matching one of the comma-separated,
+// case-insensitive prefixes in filter, or of all headers when filter is empty. User-Agent is left
+// out when privacy settings omit it.
+func headerNames(header http.Header, filter string) []string {
+	var prefixes []string
+	for _, prefix := range strings.Split(filter, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			prefixes = append(prefixes, strings.ToLower(prefix))
+		}
+	}
 
-	fmt.Fprintf(w, "=== IP INFORMATION ===\n")
-	fmt.Fprintf(w, "Client IP: %s\n", info.ClientIP)
-	fmt.Fprintf(w, "Detection Method: %s\n", info.DetectedVia)
-	fmt.Fprintf(w, "IPv4 Address: %s\n", info.IPv4Address)
-	fmt.Fprintf(w, "IPv6 Address: %s\n", info.IPv6Address)
-	fmt.Fprintf(w, "Is Private IP: %t\n", info.IsPrivateIP)
-	fmt.Fprintf(w, "Behind Cloudflare: %t\n", info.IsCloudflare)
-	fmt.Fprintf(w, "Timestamp: %s\n", info.Timestamp)
-
-	fmt.Fprintf(w, "\n=== HTTP HEADERS ===\n")
-
-	// Sort headers for consistent output
-	for name, values := range r.Header {
-		for _, value := range values {
-			fmt.Fprintf(w, "%s: %s\n", name, value)
+	names := make([]string, 0, len(header))
+	for name := range header {
+		if name == "User-Agent" && privacy.OmitUserAgent() {
+			continue
+		}
+		if len(prefixes) == 0 || slices.ContainsFunc(prefixes, func(prefix string) bool {
+			return strings.HasPrefix(strings.ToLower(name), prefix)
+		}) {
+			names = append(names, name)
 		}
 	}
+	slices.Sort(names)
+	return names
+}
+
+// processStart is when the process started, the origin of the uptime reported by /health
+var processStart = time.Now()
 
-	fmt.Fprintf(w, "\n=== CONNECTION INFO ===\n")
-	fmt.Fprintf(w, "Remote Address: %s\n", r.RemoteAddr)
-	fmt.Fprintf(w, "Method: %s\n", r.Method)
-	fmt.Fprintf(w, "URL: %s\n", r.URL.String())
-	fmt.Fprintf(w, "Protocol: %s\n", r.Proto)
+// RequestCounter reports the requests served since the process started
+type RequestCounter interface {
+	Totals() models.RequestTotals
 }
 
-// HealthHandler provides health check endpoint
-// @Summary Health check
-// @Description Returns service health status and timestamp
-// @Tags Health
-// @Accept json
-// @Produce json
-// @Success 200 {object} models.HealthResponse "Service health status"
-// @Failure 500 {string} string "Failed to encode health response"
-// @Router /health [get]
+// requestCounter holds the configured RequestCounter; /health omits request totals while it is unset
+var requestCounter atomic.Pointer[RequestCounter]
+
+// SetRequestCounter adds request totals to /health; a nil counter removes them
+func SetRequestCounter(counter RequestCounter) {
+	if counter == nil {
+		requestCounter.Store(nil)
+		return
+	}
+	requestCounter.Store(&counter)
+}
+
+// HealthHandler provides health check endpoint, with uptime, goroutine, memory, and request
+// statistics so it doubles as a lightweight status probe
 func HealthHandler(w http.ResponseWriter, r *http.Request) {
 	response := models.NewHealthResponse("healthy")
+	response.UptimeSeconds = time.Since(processStart).Truncate(time.Millisecond).Seconds()
+	response.Goroutines = runtime.NumGoroutine()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	response.Memory = &models.MemoryStats{
+		HeapAllocBytes: mem.HeapAlloc,
+		HeapObjects:    mem.HeapObjects,
+		SysBytes:       mem.Sys,
+		NumGC:          mem.NumGC,
+	}
+	if counter := requestCounter.Load(); counter != nil {
+		totals := (*counter).Totals()
+		response.Requests = &totals
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Failed to encode health response", http.StatusInternalServerError)
+		problem.Error(w, r, http.StatusInternalServerError, "Failed to encode health response")
 		return
 	}
 }
+
+// LivezHandler provides a liveness probe that stays green during maintenance mode
+func LivezHandler(w http.ResponseWriter, r *http.Request) {
+	response := models.NewHealthResponse("alive")
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, "Failed to encode liveness response")
+		return
+	}
+}
+
+// proxyRanges holds the function reporting the refresh state of the proxy ranges; the readiness
+// probe omits it while unset
+var proxyRanges atomic.Pointer[func() []models.ProxyRangesStatus]
+
+// SetProxyRanges adds the refresh state of the proxy ranges to the readiness probe; nil removes it
+func SetProxyRanges(status func() []models.ProxyRangesStatus) {
+	if status == nil {
+		proxyRanges.Store(nil)
+		return
+	}
+	proxyRanges.Store(&status)
+}
+
+// ReadyHandler provides a readiness probe for builds without enrichment providers
+func ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	response := &models.ReadinessStatus{
+		Status:    "ready",
+		Providers: []models.ProviderStatus{},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	if status := proxyRanges.Load(); status != nil {
+		response.ProxyRanges = (*status)()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, "Failed to encode readiness status")
+		return
+	}
+}
+
+// VersionHandler serves the build information, profile, and compiled-in modules
+func VersionHandler(info *models.VersionInfo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(info); err != nil {
+			problem.Error(w, r, http.StatusInternalServerError, "Failed to encode version information")
+			return
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"myip/internal/respcache"
 )
 
 // BenchmarkIsJSONFormat benchmarks our optimized case-insensitive comparison
@@ -69,3 +74,104 @@ func BenchmarkHandlerJSONCheck(b *testing.B) {
 		}
 	})
 }
+
+// BenchmarkHotEndpoints compares the IP endpoints with and without the pre-serialized response cache
+func BenchmarkHotEndpoints(b *testing.B) {
+	endpoints := []struct {
+		name     string
+		handler  http.HandlerFunc
+		target   string
+		encoding string
+	}{
+		{"IPv4", IPv4Handler, "/", ""},
+		{"JSON", JSONHandler, "/json", ""},
+		{"JSONGzip", JSONHandler, "/json", "gzip"},
+		{"Info", InfoHandler, "/info", ""},
+		{"Headers", HeadersHandler, "/headers", ""},
+	}
+
+	for _, endpoint := range endpoints {
+		req := httptest.NewRequest("GET", endpoint.target, nil)
+		req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+		req.Header.Set("User-Agent", "curl/8.0")
+		if endpoint.encoding != "" {
+			req.Header.Set("Accept-Encoding", endpoint.encoding)
+		}
+
+		for _, cached := range []bool{false, true} {
+			name := endpoint.name + "/Uncached"
+			if cached {
+				name = endpoint.name + "/Cached"
+				SetResponseCache(respcache.New(1000, time.Minute))
+			}
+
+			b.Run(name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					endpoint.handler(httptest.NewRecorder(), req)
+				}
+			})
+			SetResponseCache(nil)
+		}
+	}
+}
+
+// discardWriter is a ResponseWriter that keeps only its headers, so benchmarks measure the
+// handler rather than httptest.ResponseRecorder's buffering
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+// BenchmarkIPPath measures the IPv4 and IPv6 endpoints in each response format; plain / is the
+// dominant request and should not allocate
+func BenchmarkIPPath(b *testing.B) {
+	requests := []struct {
+		name    string
+		handler http.HandlerFunc
+		target  string
+		ip      string
+	}{
+		{"IPv4/Plain", IPv4Handler, "/", "203.0.113.1"},
+		{"IPv4/Newline", IPv4Handler, "/?newline=true", "203.0.113.1"},
+		{"IPv4/JSON", IPv4Handler, "/?format=json", "203.0.113.1"},
+		{"IPv4/JSONP", IPv4Handler, "/?format=jsonp&callback=cb", "203.0.113.1"},
+		{"IPv6/Plain", IPv6Handler, "/", "2001:db8::1"},
+		{"IPv6/Expanded", IPv6Handler, "/?compress=false", "2001:db8::1"},
+		{"IPv6/JSON", IPv6Handler, "/?format=json", "2001:db8::1"},
+	}
+
+	for _, request := range requests {
+		req := httptest.NewRequest("GET", request.target, nil)
+		req.Header.Set("CF-Connecting-IP", request.ip)
+		w := &discardWriter{header: make(http.Header)}
+
+		b.Run(request.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				clear(w.header)
+				request.handler(w, req)
+			}
+		})
+	}
+}
+
+// BenchmarkJSONPath measures uncached /json under parallel load, where every allocation is paid
+// for by the garbage collector across all cores
+func BenchmarkJSONPath(b *testing.B) {
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+	req.Header.Set("User-Agent", "curl/8.0")
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		w := &discardWriter{header: make(http.Header)}
+		for pb.Next() {
+			clear(w.header)
+			JSONHandler(w, req)
+		}
+	})
+}
@@ -1,15 +1,21 @@
 package handlers
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"myip/internal/models"
+	"myip/internal/privacy"
+	"myip/internal/respcache"
 )
 
 func TestIPv4Handler(t *testing.T) {
@@ -123,6 +129,28 @@ func TestIPv6Handler(t *testing.T) {
 	}
 }
 
+// TestDualStackListener covers IPv4 clients of a listener bound to [::], whose RemoteAddr is an
+// IPv4-mapped IPv6 address
+func TestDualStackListener(t *testing.T) {
+	for _, remoteAddr := range []string{"[::ffff:203.0.113.1]:12345", "[::ffff:cb00:7101]:12345"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		IPv4Handler(rr, req)
+		if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != "203.0.113.1" {
+			t.Errorf("%s: / = %d %q, want 203.0.113.1", remoteAddr, rr.Code, rr.Body.String())
+		}
+
+		req = httptest.NewRequest("GET", "/ipv6", nil)
+		req.RemoteAddr = remoteAddr
+		rr = httptest.NewRecorder()
+		IPv6Handler(rr, req)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s: /ipv6 = %d %q, want 404", remoteAddr, rr.Code, rr.Body.String())
+		}
+	}
+}
+
 func TestInfoHandler(t *testing.T) {
 	req := httptest.NewRequest("GET", "/info", nil)
 	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
@@ -160,6 +188,53 @@ func TestInfoHandler(t *testing.T) {
 	}
 }
 
+func TestInfoHandlerLocalized(t *testing.T) {
+	tests := []struct {
+		name, acceptLanguage, defaultLocale string
+		wantLocale                          string
+		wantLines                           []string
+	}{
+		{"accept-language", "id-ID,id;q=0.9,en;q=0.8", "", "id", []string{"Alamat IP Anda: 203.0.113.1", "Melalui Cloudflare: ya", "IP Privat: tidak"}},
+		{"region subtag", "zh-CN", "", "zh", []string{"您的 IP 地址: 203.0.113.1", "经由 Cloudflare: 是"}},
+		{"configured default", "fr-FR", "es", "es", []string{"Tu dirección IP: 203.0.113.1", "Detrás de Cloudflare: sí"}},
+		{"english", "fr-FR, en;q=0.5", "es", "en", []string{"Your IP Address: 203.0.113.1", "Behind Cloudflare: true"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.defaultLocale != "" {
+				SetDefaultLocale(tt.defaultLocale)
+				defer SetDefaultLocale("en")
+			}
+			req := httptest.NewRequest("GET", "/info", nil)
+			req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+
+			rr := httptest.NewRecorder()
+			InfoHandler(rr, req)
+
+			for _, line := range tt.wantLines {
+				if !strings.Contains(rr.Body.String(), line+"\n") {
+					t.Errorf("Expected body to contain %q, got %s", line, rr.Body.String())
+				}
+			}
+			if got := rr.Header().Get("Content-Language"); got != tt.wantLocale {
+				t.Errorf("Content-Language = %q, want %q", got, tt.wantLocale)
+			}
+			wantType := "text/plain; charset=utf-8"
+			if tt.wantLocale == "en" {
+				wantType = "text/plain"
+			}
+			if got := rr.Header().Get("Content-Type"); got != wantType {
+				t.Errorf("Content-Type = %q, want %q", got, wantType)
+			}
+			if got := rr.Header().Get("Vary"); got != "Accept-Language" {
+				t.Errorf("Vary = %q, want Accept-Language", got)
+			}
+		})
+	}
+}
+
 func TestJSONHandler(t *testing.T) {
 	req := httptest.NewRequest("GET", "/json", nil)
 	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
@@ -203,6 +278,77 @@ func TestJSONHandler(t *testing.T) {
 	}
 }
 
+func TestSpoofingReport(t *testing.T) {
+	newRequest := func(target string) *http.Request {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+		req.Header.Set("True-Client-IP", "198.51.100.7")
+		req.RemoteAddr = "192.168.1.1:12345"
+		return req
+	}
+
+	rr := httptest.NewRecorder()
+	JSONHandler(rr, newRequest("/json"))
+	var response map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	reasons, _ := response["spoofing_reasons"].([]any)
+	if response["spoofing_suspected"] != true || len(reasons) != 1 {
+		t.Errorf("Expected one spoofing reason, got %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	InfoHandler(rr, newRequest("/info"))
+	if !strings.Contains(rr.Body.String(), "Spoofing Suspected: CF-Connecting-IP 203.0.113.1 and True-Client-IP 198.51.100.7 name different clients") {
+		t.Errorf("Expected the spoofing reason in /info, got %s", rr.Body.String())
+	}
+
+	// The flag is always present so clients can rely on it
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.RemoteAddr = "203.0.113.1:12345"
+	JSONHandler(rr, req)
+	if !strings.Contains(rr.Body.String(), `"spoofing_suspected":false`) || strings.Contains(rr.Body.String(), "spoofing_reasons") {
+		t.Errorf("Expected spoofing_suspected false without reasons, got %s", rr.Body.String())
+	}
+}
+
+func TestJSONHandlerVerbose(t *testing.T) {
+	req := httptest.NewRequest("GET", "/json?verbose=1", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.1, 198.51.100.4")
+	req.Header.Set("Via", "1.1 cdn-edge, 1.1 lb")
+	req.RemoteAddr = "10.0.0.2:12345"
+
+	rr := httptest.NewRecorder()
+	JSONHandler(rr, req)
+
+	var response models.IPInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+	want := []models.Hop{
+		{IP: "203.0.113.1", Source: "X-Forwarded-For", Class: "public"},
+		{IP: "198.51.100.4", Source: "X-Forwarded-For", Class: "public", Via: "1.1 cdn-edge"},
+		{IP: "10.0.0.2", Source: "RemoteAddr", Class: "private", Via: "1.1 lb"},
+	}
+	if !reflect.DeepEqual(response.Hops, want) {
+		t.Errorf("Expected hops %+v, got %+v", want, response.Hops)
+	}
+	if response.DetectionMs <= 0 {
+		t.Errorf("Expected the detection time, got %v", response.DetectionMs)
+	}
+
+	// Hops and timing are only included on request
+	req = httptest.NewRequest("GET", "/json", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.1, 198.51.100.4")
+	rr = httptest.NewRecorder()
+	JSONHandler(rr, req)
+	if strings.Contains(rr.Body.String(), `"hops"`) || strings.Contains(rr.Body.String(), `"detection_ms"`) {
+		t.Errorf("Expected no hops or timing without verbose, got %s", rr.Body.String())
+	}
+}
+
 func TestHeadersHandler(t *testing.T) {
 	req := httptest.NewRequest("GET", "/headers", nil)
 	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
@@ -247,6 +393,26 @@ func TestHeadersHandler(t *testing.T) {
 	}
 }
 
+func TestHeadersHandlerOmitUserAgent(t *testing.T) {
+	defer privacy.Configure(false, false)
+	privacy.Configure(false, true)
+
+	req := httptest.NewRequest("GET", "/headers", nil)
+	req.Header.Set("User-Agent", "TestAgent/1.0")
+	req.Header.Set("X-Custom-Header", "test-value")
+
+	rr := httptest.NewRecorder()
+	HeadersHandler(rr, req)
+
+	body := rr.Body.String()
+	if strings.Contains(body, "TestAgent") {
+		t.Errorf("Expected the User-Agent omitted, got %s", body)
+	}
+	if !strings.Contains(body, "X-Custom-Header: test-value") {
+		t.Errorf("Expected the other headers listed, got %s", body)
+	}
+}
+
 func TestHealthHandler(t *testing.T) {
 	req, err := http.NewRequest("GET", "/health", nil)
 	if err != nil {
@@ -282,6 +448,103 @@ func TestHealthHandler(t *testing.T) {
 	if response.Timestamp == "" {
 		t.Error("Expected timestamp to be set")
 	}
+	if response.Goroutines < 1 || response.Memory == nil || response.Memory.SysBytes == 0 {
+		t.Errorf("Expected runtime statistics, got %d goroutines and memory %+v", response.Goroutines, response.Memory)
+	}
+	if response.Requests != nil {
+		t.Errorf("Expected no request totals without a counter, got %+v", response.Requests)
+	}
+}
+
+// fixedCounter reports constant request totals
+type fixedCounter models.RequestTotals
+
+func (c fixedCounter) Totals() models.RequestTotals { return models.RequestTotals(c) }
+
+func TestHealthHandlerRequestTotals(t *testing.T) {
+	SetRequestCounter(fixedCounter{Total: 42, Errors: 2})
+	defer SetRequestCounter(nil)
+
+	rr := httptest.NewRecorder()
+	HealthHandler(rr, httptest.NewRequest("GET", "/health", nil))
+
+	var response models.HealthResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Requests == nil || *response.Requests != (models.RequestTotals{Total: 42, Errors: 2}) {
+		t.Errorf("Expected 42 requests with 2 errors, got %+v", response.Requests)
+	}
+}
+
+func TestLivezHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/livez", nil)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(LivezHandler)
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusOK)
+	}
+
+	var response models.HealthResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Errorf("Failed to parse JSON response: %v", err)
+	}
+
+	if response.Status != "alive" {
+		t.Errorf("Expected status 'alive', got %s", response.Status)
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	ReadyHandler(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	var response models.ReadinessStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+
+	if rr.Code != http.StatusOK || response.Status != "ready" || response.Providers == nil {
+		t.Errorf("Expected ready status with empty providers, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestReadyHandlerProxyRanges(t *testing.T) {
+	SetProxyRanges(func() []models.ProxyRangesStatus {
+		return []models.ProxyRangesStatus{{Profile: "cloudflare", Source: "published", Ranges: 22}}
+	})
+	defer SetProxyRanges(nil)
+
+	rr := httptest.NewRecorder()
+	ReadyHandler(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	var response models.ReadinessStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+	if len(response.ProxyRanges) != 1 || response.ProxyRanges[0].Profile != "cloudflare" || response.ProxyRanges[0].Ranges != 22 {
+		t.Errorf("Expected the proxy range status, got %s", rr.Body.String())
+	}
+}
+
+func TestVersionHandler(t *testing.T) {
+	info := &models.VersionInfo{Version: "1.2.3", Profile: "minimal", Features: []string{"ip"}}
+
+	rr := httptest.NewRecorder()
+	VersionHandler(info)(rr, httptest.NewRequest("GET", "/version", nil))
+
+	var response models.VersionInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+
+	if response.Version != "1.2.3" || response.Profile != "minimal" || len(response.Features) != 1 {
+		t.Errorf("Unexpected version response %s", rr.Body.String())
+	}
 }
 
 // Additional tests for better coverage
@@ -429,6 +692,63 @@ func TestInfoHandlerEmptyIPv4(t *testing.T) {
 	}
 }
 
+func TestHeadersHandlerJSONFilter(t *testing.T) {
+	req := httptest.NewRequest("GET", "/headers?format=json&filter=x-forwarded-,%20CF-", nil)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+	req.Header.Set("CF-Ray", "8f1a2b3c4d5e6f70-AMS")
+	req.Header.Add("X-Forwarded-For", "203.0.113.1")
+	req.Header.Add("X-Forwarded-For", "10.0.0.1")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("User-Agent", "TestAgent/1.0")
+	req.RemoteAddr = "192.168.1.1:12345"
+
+	rr := httptest.NewRecorder()
+	HeadersHandler(rr, req)
+
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %q", ct)
+	}
+
+	var response models.HeadersResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+	if response.ClientIP != "203.0.113.1" || response.RemoteAddr != "192.168.1.1:12345" || response.Method != "GET" {
+		t.Errorf("Unexpected connection details: %+v", response)
+	}
+
+	want := map[string][]string{
+		"Cf-Connecting-Ip":  {"203.0.113.1"},
+		"Cf-Ray":            {"8f1a2b3c4d5e6f70-AMS"},
+		"X-Forwarded-For":   {"203.0.113.1", "10.0.0.1"},
+		"X-Forwarded-Proto": {"https"},
+	}
+	if !reflect.DeepEqual(response.Headers, want) {
+		t.Errorf("Expected headers %v, got %v", want, response.Headers)
+	}
+}
+
+func TestHeadersHandlerTextFilter(t *testing.T) {
+	req := httptest.NewRequest("GET", "/headers?filter=X-Forwarded-", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-For", "203.0.113.1")
+	req.Header.Set("User-Agent", "TestAgent/1.0")
+
+	rr := httptest.NewRecorder()
+	HeadersHandler(rr, req)
+
+	body := rr.Body.String()
+	if strings.Contains(body, "User-Agent:") {
+		t.Errorf("Expected User-Agent to be filtered out, body: %s", body)
+	}
+	// Matching headers are listed in name order
+	forwardedFor := strings.Index(body, "X-Forwarded-For: 203.0.113.1")
+	forwardedProto := strings.Index(body, "X-Forwarded-Proto: https")
+	if forwardedFor < 0 || forwardedProto < forwardedFor {
+		t.Errorf("Expected sorted X-Forwarded-* headers, body: %s", body)
+	}
+}
+
 func TestHeadersHandlerEmptyAddresses(t *testing.T) {
 	// Test headers handler with minimal IP info
 	req := httptest.NewRequest("GET", "/headers", nil)
@@ -1747,9 +2067,13 @@ func TestJSONEncodingErrors(t *testing.T) {
 			t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, fw.statusCode)
 		}
 
-		// Check that the error content type was set by http.Error
-		if fw.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
-			t.Errorf("Expected Content-Type to be text/plain; charset=utf-8, got %s", fw.Header().Get("Content-Type"))
+		// The error is reported in the negotiated JSON format
+		if fw.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("Expected Content-Type to be application/json, got %s", fw.Header().Get("Content-Type"))
+		}
+
+		if fw.Header().Get("Retry-After") == "" {
+			t.Error("Expected Retry-After header on 500 response")
 		}
 	})
 
@@ -1807,3 +2131,457 @@ func TestJSONEncodingErrors(t *testing.T) {
 		}
 	})
 }
+
+func TestResponseCacheMatchesUncachedResponses(t *testing.T) {
+	requests := []struct {
+		handler http.HandlerFunc
+		target  string
+	}{
+		{IPv4Handler, "/"},
+		{IPv4Handler, "/?format=json"},
+		{IPv4Handler, "/?format=jsonp&callback=cb"},
+		{JSONHandler, "/json"},
+		{JSONHandler, "/json?pretty=1"},
+	}
+
+	serve := func(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+		req.Header.Set("User-Agent", "curl/8.0")
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+
+	for _, tc := range requests {
+		uncached := serve(tc.handler, tc.target)
+
+		SetResponseCache(respcache.New(100, time.Minute))
+		first := serve(tc.handler, tc.target)
+		second := serve(tc.handler, tc.target)
+		SetResponseCache(nil)
+
+		// Timestamps may tick between requests; compare bodies made in the same second
+		if strings.Contains(uncached.Body.String(), "timestamp") {
+			first.Body = bytes.NewBufferString(strings.Split(first.Body.String(), `"timestamp"`)[0])
+			second.Body = bytes.NewBufferString(strings.Split(second.Body.String(), `"timestamp"`)[0])
+			uncached.Body = bytes.NewBufferString(strings.Split(uncached.Body.String(), `"timestamp"`)[0])
+		}
+
+		for _, rr := range []*httptest.ResponseRecorder{first, second} {
+			if rr.Body.String() != uncached.Body.String() {
+				t.Errorf("%s: cached body %q differs from uncached %q", tc.target, rr.Body.String(), uncached.Body.String())
+			}
+			if rr.Header().Get("Content-Type") != uncached.Header().Get("Content-Type") {
+				t.Errorf("%s: cached Content-Type %q differs from %q", tc.target, rr.Header().Get("Content-Type"), uncached.Header().Get("Content-Type"))
+			}
+		}
+	}
+}
+
+func TestResponseCacheGzip(t *testing.T) {
+	SetResponseCache(respcache.New(100, time.Minute))
+	defer SetResponseCache(nil)
+
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	JSONHandler(rr, req)
+
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip-encoded /json, got headers %v", rr.Header())
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info models.IPInfo
+	if err := json.NewDecoder(zr).Decode(&info); err != nil || info.ClientIP != "203.0.113.1" {
+		t.Errorf("Expected decodable JSON for 203.0.113.1, got %+v, err=%v", info, err)
+	}
+}
+
+// TestInfoKeyCoversAllFields guards against new IPInfo fields being left out of the cache key
+func TestInfoKeyCoversAllFields(t *testing.T) {
+	listed := true
+	base := models.IPInfo{}
+	baseKey, _ := infoKey(&base)
+
+	infoType := reflect.TypeOf(base)
+	for i := 0; i < infoType.NumField(); i++ {
+		field := infoType.Field(i)
+		if field.Name == "Enrichment" || field.Name == "Meta" {
+			continue // responses with enrichment are never cached
+		}
+		if field.Name == "Hops" || field.Name == "DetectionMs" || field.Name == "LocalTimestamp" || field.Name == "Timezone" {
+			continue // only added with ?verbose= or ?tz=, and requests with a query are never cached
+		}
+
+		changed := base
+		value := reflect.ValueOf(&changed).Elem().Field(i)
+		switch value.Kind() {
+		case reflect.String:
+			value.SetString("x")
+		case reflect.Bool:
+			value.SetBool(true)
+		case reflect.Int:
+			value.SetInt(1)
+		case reflect.Ptr:
+			if value.Type() == reflect.TypeOf(&listed) {
+				value.Set(reflect.ValueOf(&listed))
+			} else {
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+		case reflect.Slice:
+			elem := reflect.New(value.Type().Elem()).Elem()
+			if elem.Kind() == reflect.String {
+				elem.SetString("x")
+			} else {
+				elem.Field(0).SetString("x")
+			}
+			value.Set(reflect.Append(value, elem))
+		default:
+			t.Fatalf("Unhandled field kind %s for %s", value.Kind(), field.Name)
+		}
+
+		if key, _ := infoKey(&changed); key == baseKey {
+			t.Errorf("Field %s is not part of the response cache key", field.Name)
+		}
+	}
+}
+
+func TestPlainTextOptions(t *testing.T) {
+	defer SetPlainText(PlainText{})
+
+	tests := []struct {
+		name        string
+		options     PlainText
+		target      string
+		body        string
+		contentType string
+	}{
+		{"ipify default", PlainText{}, "/", "203.0.113.1", "text/plain"},
+		{"newline", PlainText{Newline: true}, "/", "203.0.113.1\n", "text/plain"},
+		{"charset", PlainText{Charset: true}, "/", "203.0.113.1", "text/plain; charset=utf-8"},
+		{"newline param", PlainText{}, "/?newline=true", "203.0.113.1\n", "text/plain"},
+		{"newline param off", PlainText{Newline: true}, "/?newline=0", "203.0.113.1", "text/plain"},
+		{"invalid newline param", PlainText{Newline: true}, "/?newline=maybe", "203.0.113.1\n", "text/plain"},
+		{"json unaffected", PlainText{Newline: true, Charset: true}, "/?format=json", "{\"ip\":\"203.0.113.1\"}\n", "application/json"},
+	}
+
+	for _, cached := range []bool{false, true} {
+		for _, tc := range tests {
+			t.Run(fmt.Sprintf("%s cached=%t", tc.name, cached), func(t *testing.T) {
+				SetPlainText(tc.options)
+				if cached {
+					SetResponseCache(respcache.New(100, time.Minute))
+					defer SetResponseCache(nil)
+				}
+
+				req := httptest.NewRequest("GET", tc.target, nil)
+				req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+				rr := httptest.NewRecorder()
+				IPv4Handler(rr, req)
+
+				if rr.Body.String() != tc.body {
+					t.Errorf("Expected body %q, got %q", tc.body, rr.Body.String())
+				}
+				if got := rr.Header().Get("Content-Type"); got != tc.contentType {
+					t.Errorf("Expected Content-Type %q, got %q", tc.contentType, got)
+				}
+			})
+		}
+	}
+}
+
+func TestBufferPool(t *testing.T) {
+	buf := getBuffer()
+	buf.WriteString("leftover")
+	putBuffer(buf)
+
+	if reused := getBuffer(); reused.Len() != 0 {
+		t.Errorf("Expected an empty buffer from the pool, got %q", reused.String())
+	}
+
+	large := getBuffer()
+	large.Grow(maxPooledBuffer + 1)
+	putBuffer(large)
+	for i := 0; i < 10; i++ {
+		if getBuffer() == large {
+			t.Fatal("Expected oversized buffers to be dropped from the pool")
+		}
+	}
+}
+
+func TestIPv6ExpandHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/ipv6/expand", nil)
+	req.Header.Set("CF-Connecting-IP", "2001:DB8:0:0:1a2b::1")
+	rr := httptest.NewRecorder()
+	IPv6ExpandHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var response models.IPv6Forms
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	expected := models.IPv6Forms{
+		IP:         "2001:DB8:0:0:1a2b::1",
+		Compressed: "2001:db8::1a2b:0:0:1",
+		Expanded:   "2001:0db8:0000:0000:1a2b:0000:0000:0001",
+		Prefix64:   "2001:db8::/64",
+	}
+	if response != expected {
+		t.Errorf("Expected %+v, got %+v", expected, response)
+	}
+}
+
+func TestIPv6ExpandHandlerNotFound(t *testing.T) {
+	req := httptest.NewRequest("GET", "/ipv6/expand", nil)
+	req.RemoteAddr = "203.0.113.1:12345"
+	rr := httptest.NewRecorder()
+	IPv6ExpandHandler(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), models.ErrorIPv6NotFound) {
+		t.Errorf("Expected error code in body, got %q", rr.Body.String())
+	}
+}
+
+func TestIPv6AnalyzeHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/ipv6/analyze", nil)
+	req.Header.Set("CF-Connecting-IP", "2001:db8:1:2:ba27:ebff:fe12:3456")
+	rr := httptest.NewRecorder()
+	IPv6AnalyzeHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var response models.IPv6Analysis
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Prefix64 != "2001:db8:1:2::/64" || response.InterfaceIDType != "eui-64" || response.MAC != "b8:27:eb:12:34:56" || response.MACVendor != "Raspberry Pi" {
+		t.Errorf("Expected an EUI-64 address of a Raspberry Pi, got %+v", response)
+	}
+
+	req = httptest.NewRequest("GET", "/ipv6/analyze", nil)
+	req.RemoteAddr = "203.0.113.1:12345"
+	rr = httptest.NewRecorder()
+	IPv6AnalyzeHandler(rr, req)
+	if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), models.ErrorIPv6NotFound) {
+		t.Errorf("Expected 404 without an IPv6 address, got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestNAT64Handler(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		remote string
+		status int
+		want   models.NAT64Info
+	}{
+		{"Client behind NAT64", "/nat64", "[64:ff9b::c000:221]:1234", http.StatusOK,
+			models.NAT64Info{IP: "64:ff9b::c000:221", NAT64: true, Prefix: "64:ff9b::/96", EmbeddedIPv4: "192.0.2.33"}},
+		{"Native client", "/nat64", "[2001:db8::1]:1234", http.StatusOK, models.NAT64Info{IP: "2001:db8::1"}},
+		{"Synthesized address", "/nat64?ip=64:ff9b::c000:2aa", "203.0.113.1:1234", http.StatusOK,
+			models.NAT64Info{IP: "64:ff9b::c000:2aa", NAT64: true, Prefix: "64:ff9b::/96", EmbeddedIPv4: "192.0.2.170"}},
+		{"IPv4 parameter", "/nat64?ip=192.0.2.1", "203.0.113.1:1234", http.StatusBadRequest, models.NAT64Info{}},
+		{"No IPv6 address", "/nat64", "203.0.113.1:1234", http.StatusNotFound, models.NAT64Info{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", test.target, nil)
+			req.RemoteAddr = test.remote
+			rr := httptest.NewRecorder()
+			NAT64Handler(rr, req)
+
+			if rr.Code != test.status {
+				t.Fatalf("Expected status %d, got %d: %s", test.status, rr.Code, rr.Body.String())
+			}
+			if test.status != http.StatusOK {
+				return
+			}
+			var response models.NAT64Info
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(response.Prefixes) != 2 {
+				t.Errorf("Expected the well-known prefixes, got %v", response.Prefixes)
+			}
+			response.Prefixes = nil
+			if !reflect.DeepEqual(response, test.want) {
+				t.Errorf("Expected %+v, got %+v", test.want, response)
+			}
+		})
+	}
+}
+
+func TestIPv6HandlerCompress(t *testing.T) {
+	tests := []struct {
+		target   string
+		expected string
+	}{
+		{"/ipv6", "2001:db8::1"},
+		{"/ipv6?compress=true", "2001:db8::1"},
+		{"/ipv6?compress=false", "2001:0db8:0000:0000:0000:0000:0000:0001"},
+		{"/ipv6?compress=false&format=json", "{\"ip\":\"2001:0db8:0000:0000:0000:0000:0000:0001\"}"},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest("GET", tc.target, nil)
+		req.Header.Set("CF-Connecting-IP", "2001:db8::1")
+		rr := httptest.NewRecorder()
+		IPv6Handler(rr, req)
+
+		if got := strings.TrimSpace(rr.Body.String()); got != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.target, tc.expected, got)
+		}
+	}
+}
+
+func TestBothHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		headers     map[string]string
+		remoteAddr  string
+		body        string
+		contentType string
+	}{
+		{"dual stack", "/both", map[string]string{"X-Forwarded-For": "2001:db8::1, 203.0.113.1"}, "10.0.0.1:54321", "{\"ipv4\":\"203.0.113.1\",\"ipv6\":\"2001:db8::1\"}\n", "application/json"},
+		{"IPv4 only", "/both", nil, "203.0.113.1:54321", "{\"ipv4\":\"203.0.113.1\",\"ipv6\":null}\n", "application/json"},
+		{"IPv6 only", "/both", nil, "[2001:db8::1]:443", "{\"ipv4\":null,\"ipv6\":\"2001:db8::1\"}\n", "application/json"},
+		{"jsonp", "/both?format=jsonp&callback=cb", nil, "203.0.113.1:54321", "cb({\"ipv4\":\"203.0.113.1\",\"ipv6\":null});", "application/javascript"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.target, nil)
+			req.RemoteAddr = tc.remoteAddr
+			for key, value := range tc.headers {
+				req.Header.Set(key, value)
+			}
+			rr := httptest.NewRecorder()
+			BothHandler(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Errorf("Expected status 200, got %d", rr.Code)
+			}
+			if rr.Body.String() != tc.body {
+				t.Errorf("Expected body %q, got %q", tc.body, rr.Body.String())
+			}
+			if got := rr.Header().Get("Content-Type"); got != tc.contentType {
+				t.Errorf("Expected Content-Type %q, got %q", tc.contentType, got)
+			}
+		})
+	}
+}
+
+func TestPortHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		headers     map[string]string
+		remoteAddr  string
+		code        int
+		body        string
+		contentType string
+	}{
+		{"plain", "/port", nil, "203.0.113.1:54321", http.StatusOK, "54321", "text/plain"},
+		{"json", "/port?format=json", nil, "[2001:db8::1]:443", http.StatusOK, "{\"port\":443}\n", "application/json"},
+		{"jsonp", "/port?format=jsonp&callback=cb", nil, "203.0.113.1:54321", http.StatusOK, "cb({\"port\":54321});", "application/javascript"},
+		{"behind proxy", "/port?format=json", map[string]string{"X-Forwarded-For": "198.51.100.7"}, "10.0.0.1:54321", http.StatusNotFound, "", "application/json"},
+		{"no port", "/port", nil, "203.0.113.1", http.StatusNotFound, "", "text/plain; charset=utf-8"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.target, nil)
+			req.RemoteAddr = tc.remoteAddr
+			for key, value := range tc.headers {
+				req.Header.Set(key, value)
+			}
+			rr := httptest.NewRecorder()
+			PortHandler(rr, req)
+
+			if rr.Code != tc.code {
+				t.Errorf("Expected status %d, got %d", tc.code, rr.Code)
+			}
+			if tc.body != "" && rr.Body.String() != tc.body {
+				t.Errorf("Expected body %q, got %q", tc.body, rr.Body.String())
+			}
+			if got := rr.Header().Get("Content-Type"); got != tc.contentType {
+				t.Errorf("Expected Content-Type %q, got %q", tc.contentType, got)
+			}
+		})
+	}
+}
+
+// TestPlainPathAllocations guards the zero-allocation plain-text path of / and /ipv6
+func TestPlainPathAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are unreliable under the race detector")
+	}
+	for _, tt := range []struct {
+		name    string
+		handler http.HandlerFunc
+		ip      string
+	}{
+		{"IPv4", IPv4Handler, "203.0.113.1"},
+		{"IPv6", IPv6Handler, "2001:db8::1"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("CF-Connecting-IP", tt.ip)
+			w := &discardWriter{header: make(http.Header)}
+
+			allocs := testing.AllocsPerRun(100, func() {
+				clear(w.header)
+				tt.handler(w, req)
+			})
+			if allocs != 0 {
+				t.Errorf("Expected no allocations, got %v per request", allocs)
+			}
+		})
+	}
+}
+
+// TestIPObjectEncoding checks the hand-built JSON and JSONP responses against encoding/json
+func TestIPObjectEncoding(t *testing.T) {
+	for _, addr := range []string{"203.0.113.1", "2001:db8::1", "fe80::1%eth0", `fe80::1%"<x>`} {
+		expected, _ := json.Marshal(map[string]string{"ip": addr})
+
+		var buf bytes.Buffer
+		buf.WriteString(`{"ip":`)
+		writeJSONString(&buf, addr)
+		buf.WriteByte('}')
+		if buf.String() != string(expected) {
+			t.Errorf("writeJSONString(%q) produced %s, expected %s", addr, buf.String(), expected)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/?format=jsonp&callback=cb", nil)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+	w := httptest.NewRecorder()
+	IPv4Handler(w, req)
+	if body := w.Body.String(); body != `cb({"ip":"203.0.113.1"});` {
+		t.Errorf("Unexpected JSONP body %q", body)
+	}
+
+	req = httptest.NewRequest("GET", "/?format=json", nil)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+	w = httptest.NewRecorder()
+	IPv4Handler(w, req)
+	if body := w.Body.String(); body != "{\"ip\":\"203.0.113.1\"}\n" {
+		t.Errorf("Unexpected JSON body %q", body)
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"myip/internal/ip"
+)
+
+// maxPadSize bounds /pad responses; it covers jumbo frames and a full IP datagram
+const maxPadSize = 64 << 10
+
+// PadHandler returns a response of exactly the requested size, for probing the path MTU and
+// middleboxes that truncate or rewrite responses. The body starts with the client IP when it
+// fits and always ends with a newline, so truncation is recognizable.
+func PadHandler(w http.ResponseWriter, r *http.Request) {
+	size, err := strconv.Atoi(r.URL.Query().Get("size"))
+	if err != nil || size < 1 || size > maxPadSize {
+		http.Error(w, "Invalid size parameter: expected 1 to "+strconv.Itoa(maxPadSize), http.StatusBadRequest)
+		return
+	}
+
+	clientIP, _ := ip.ExtractClientIP(r)
+	body := make([]byte, 0, size)
+	if line := clientIP + "\n"; len(line) < size {
+		body = append(body, line...)
+	}
+	body = append(body, filler[:size-len(body)-1]...)
+	body = append(body, '\n')
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Content-Length", strconv.Itoa(size))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(body)
+}
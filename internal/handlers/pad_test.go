@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPadHandler(t *testing.T) {
+	tests := []struct {
+		size   int
+		prefix string
+	}{
+		{1, "\n"},
+		{12, "..........."},
+		{13, "203.0.113.1\n\n"},
+		{1500, "203.0.113.1\n..."},
+		{65536, "203.0.113.1\n..."},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest("GET", "/pad?size="+strconv.Itoa(tc.size), nil)
+		req.RemoteAddr = "203.0.113.1:54321"
+		rr := httptest.NewRecorder()
+		PadHandler(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("size %d: expected status 200, got %d", tc.size, rr.Code)
+		}
+		body := rr.Body.String()
+		if len(body) != tc.size {
+			t.Errorf("size %d: got %d bytes", tc.size, len(body))
+		}
+		if got := rr.Header().Get("Content-Length"); got != strconv.Itoa(tc.size) {
+			t.Errorf("size %d: expected matching Content-Length, got %s", tc.size, got)
+		}
+		if !strings.HasPrefix(body, tc.prefix) || !strings.HasSuffix(body, "\n") {
+			t.Errorf("size %d: expected body starting with %q and ending with a newline, got %.20q", tc.size, tc.prefix, body)
+		}
+	}
+}
+
+func TestPadHandlerInvalidSize(t *testing.T) {
+	for _, target := range []string{"/pad", "/pad?size=0", "/pad?size=65537", "/pad?size=big"} {
+		rr := httptest.NewRecorder()
+		PadHandler(rr, httptest.NewRequest("GET", target, nil))
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", target, rr.Code)
+		}
+	}
+}
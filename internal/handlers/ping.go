@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"myip/internal/models"
+)
+
+// Limits on the chunked /ping response, which must stay cheap enough to serve to anyone
+const (
+	maxPingChunks        = 100
+	defaultPingChunkSize = 1024
+	maxPingChunkSize     = 64 << 10
+)
+
+// filler pads /ping chunks and /pad responses, sliced to the requested size
+var filler = func() []byte {
+	b := make([]byte, maxPingChunkSize)
+	for i := range b {
+		b[i] = '.'
+	}
+	return b
+}()
+
+// PingHandler reports when the request was received, for latency measurement. The client's send
+// time in ?t= is echoed with a one-way estimate; ?chunks= streams flushed chunks instead, with
+// the receive time in the X-Server-Time-Ms header.
+func PingHandler(w http.ResponseWriter, r *http.Request) {
+	received := time.Now()
+	query := r.URL.Query()
+
+	response := &models.PingResponse{
+		ServerTime:   received.UTC().Format(time.RFC3339Nano),
+		ServerTimeMs: received.UnixMilli(),
+	}
+	if value := query.Get("t"); value != "" {
+		sent, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || sent <= 0 {
+			http.Error(w, "Invalid t parameter: expected Unix milliseconds", http.StatusBadRequest)
+			return
+		}
+		oneWay := float64(received.UnixMicro()-sent*1000) / 1000
+		response.ClientTimeMs = &sent
+		response.OneWayMs = &oneWay
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	if value := query.Get("chunks"); value != "" {
+		chunks, err := strconv.Atoi(value)
+		if err != nil || chunks < 1 || chunks > maxPingChunks {
+			http.Error(w, "Invalid chunks parameter: expected 1 to "+strconv.Itoa(maxPingChunks), http.StatusBadRequest)
+			return
+		}
+		size := defaultPingChunkSize
+		if value := query.Get("chunk_size"); value != "" {
+			size, err = strconv.Atoi(value)
+			if err != nil || size < 1 || size > maxPingChunkSize {
+				http.Error(w, "Invalid chunk_size parameter: expected 1 to "+strconv.Itoa(maxPingChunkSize), http.StatusBadRequest)
+				return
+			}
+		}
+		writePingChunks(w, response, chunks, size)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, r, formatJSON, http.StatusInternalServerError, models.ErrorEncodingFailed, "Failed to encode JSON response")
+	}
+}
+
+// writePingChunks streams chunks of size bytes, flushing each so the client sees them arrive
+// one by one where the connection supports it
+func writePingChunks(w http.ResponseWriter, response *models.PingResponse, chunks, size int) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(chunks*size))
+	w.Header().Set("X-Server-Time-Ms", strconv.FormatInt(response.ServerTimeMs, 10))
+	if response.OneWayMs != nil {
+		w.Header().Set("X-One-Way-Ms", strconv.FormatFloat(*response.OneWayMs, 'f', 3, 64))
+	}
+
+	controller := http.NewResponseController(w)
+	for range chunks {
+		if _, err := w.Write(filler[:size]); err != nil {
+			return
+		}
+		controller.Flush()
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"myip/internal/models"
+)
+
+func TestPingHandler(t *testing.T) {
+	sent := time.Now().Add(-50 * time.Millisecond).UnixMilli()
+	req := httptest.NewRequest("GET", "/ping?t="+strconv.FormatInt(sent, 10), nil)
+	rr := httptest.NewRecorder()
+	PingHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Expected Cache-Control no-store, got %q", got)
+	}
+
+	var response models.PingResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, response.ServerTime); err != nil {
+		t.Errorf("Expected an RFC 3339 server time, got %q", response.ServerTime)
+	}
+	if response.ClientTimeMs == nil || *response.ClientTimeMs != sent {
+		t.Errorf("Expected client time %d to be echoed, got %v", sent, response.ClientTimeMs)
+	}
+	if response.OneWayMs == nil || *response.OneWayMs < 50 || *response.OneWayMs > 5000 {
+		t.Errorf("Expected a one-way estimate of at least 50ms, got %v", response.OneWayMs)
+	}
+}
+
+func TestPingHandlerWithoutClientTime(t *testing.T) {
+	req := httptest.NewRequest("GET", "/ping", nil)
+	rr := httptest.NewRecorder()
+	PingHandler(rr, req)
+
+	if strings.Contains(rr.Body.String(), "client_time_ms") || strings.Contains(rr.Body.String(), "one_way_ms") {
+		t.Errorf("Expected no client time fields, got %s", rr.Body.String())
+	}
+}
+
+func TestPingHandlerChunks(t *testing.T) {
+	req := httptest.NewRequest("GET", "/ping?chunks=3&chunk_size=10", nil)
+	rr := httptest.NewRecorder()
+	PingHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if got := rr.Body.Len(); got != 30 {
+		t.Errorf("Expected 30 bytes, got %d", got)
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/octet-stream" {
+		t.Errorf("Expected an octet stream, got %q", got)
+	}
+	if rr.Header().Get("X-Server-Time-Ms") == "" {
+		t.Error("Expected the receive time in X-Server-Time-Ms")
+	}
+	if !rr.Flushed {
+		t.Error("Expected the chunks to be flushed")
+	}
+}
+
+func TestPingHandlerInvalidParameters(t *testing.T) {
+	for _, target := range []string{
+		"/ping?t=yesterday",
+		"/ping?t=-5",
+		"/ping?chunks=0",
+		"/ping?chunks=101",
+		"/ping?chunks=1&chunk_size=0",
+		"/ping?chunks=1&chunk_size=65537",
+	} {
+		rr := httptest.NewRecorder()
+		PingHandler(rr, httptest.NewRequest("GET", target, nil))
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", target, rr.Code)
+		}
+	}
+}
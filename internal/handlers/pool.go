@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// maxPooledBuffer is the largest buffer returned to the pool; buffers grown by unusually large
+// responses, such as /headers with many headers, are left to the garbage collector
+const maxPooledBuffer = 16 << 10
+
+// bufferPool recycles the buffers responses are assembled in before being written
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from the pool
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns buf to the pool once its contents have been written
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// jsonBuffer is a buffer together with a json.Encoder writing into it, so a pooled buffer also
+// spares each JSON response its encoder
+type jsonBuffer struct {
+	bytes.Buffer
+	encoder *json.Encoder
+}
+
+// jsonBufferPool recycles the buffers /json responses are encoded into before being written
+var jsonBufferPool = sync.Pool{
+	New: func() any {
+		buf := new(jsonBuffer)
+		buf.encoder = json.NewEncoder(&buf.Buffer)
+		return buf
+	},
+}
+
+// getJSONBuffer returns an empty JSON buffer from the pool
+func getJSONBuffer() *jsonBuffer {
+	buf := jsonBufferPool.Get().(*jsonBuffer)
+	buf.Reset()
+	return buf
+}
+
+// putJSONBuffer returns buf to the pool once its contents have been written
+func putJSONBuffer(buf *jsonBuffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	jsonBufferPool.Put(buf)
+}
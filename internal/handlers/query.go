@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// queryValue returns the first value of the named query parameter like r.URL.Query().Get, without
+// parsing the query into a map. Most requests to the IP endpoints carry no query at all, and
+// those that do are scanned in place unless they need unescaping.
+func queryValue(r *http.Request, name string) string {
+	query := r.URL.RawQuery
+	if query == "" {
+		return ""
+	}
+	if strings.ContainsAny(query, "%+;") {
+		return r.URL.Query().Get(name)
+	}
+
+	for query != "" {
+		var pair string
+		pair, query, _ = strings.Cut(query, "&")
+		if key, value, _ := strings.Cut(pair, "="); key == name {
+			return value
+		}
+	}
+	return ""
+}
+
+// queryBool parses the named boolean query parameter, reporting ok=false when it is absent or
+// not a boolean
+func queryBool(r *http.Request, name string) (value, ok bool) {
+	raw := queryValue(r, name)
+	if raw == "" {
+		return false, false
+	}
+	value, err := strconv.ParseBool(raw)
+	return value, err == nil
+}
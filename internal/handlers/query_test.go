@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryValue(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"", ""},
+		{"format=json", "json"},
+		{"newline=true&format=jsonp", "jsonp"},
+		{"format=json&format=text", "json"},
+		{"format", ""},
+		{"formats=json", ""},
+		{"&&format=json", "json"},
+		{"format=a%20b", "a b"},
+		{"format=a+b", "a b"},
+		{"for%6Dat=json", "json"},
+		{"format=json;x=1", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/?"+tt.query, nil)
+			if got := queryValue(req, "format"); got != tt.expected {
+				t.Errorf("queryValue(%q) = %q, expected %q", tt.query, got, tt.expected)
+			}
+			if got := req.URL.Query().Get("format"); got != tt.expected {
+				t.Errorf("url.Values.Get(%q) = %q, expected %q", tt.query, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestQueryBool(t *testing.T) {
+	tests := []struct {
+		query string
+		value bool
+		ok    bool
+	}{
+		{"", false, false},
+		{"newline=true", true, true},
+		{"newline=0", false, true},
+		{"newline=", false, false},
+		{"newline=maybe", false, false},
+		{"format=json", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/?"+tt.query, nil)
+			value, ok := queryBool(req, "newline")
+			if value != tt.value || ok != tt.ok {
+				t.Errorf("queryBool(%q) = %v, %v, expected %v, %v", tt.query, value, ok, tt.value, tt.ok)
+			}
+		})
+	}
+}
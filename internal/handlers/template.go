@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"text/template/parse"
+
+	"myip/internal/models"
+)
+
+// Limits on ?template= rendering: the length of an inline template, the width or precision of a
+// printf verb in one, and the size of the rendered output
+const (
+	maxInlineTemplate = 1024
+	maxFormatWidth    = 64
+	maxTemplateOutput = 64 << 10
+)
+
+// namedTemplatePrefix marks a ?template= value naming a configured template rather than
+// holding one
+const namedTemplatePrefix = "@"
+
+// Templates controls ?template= rendering of /info. The zero value disables it.
+type Templates struct {
+	// Inline accepts templates given in the query string
+	Inline bool
+
+	// Named are the configured templates selected with ?template=@name
+	Named map[string]*template.Template
+}
+
+// templates holds the template settings; ?template= is rejected while it is unset
+var templates atomic.Pointer[Templates]
+
+// SetTemplates sets the templates available to ?template=
+func SetTemplates(t Templates) {
+	templates.Store(&t)
+}
+
+var (
+	errTemplateOutput = fmt.Errorf("template output exceeds %d bytes", maxTemplateOutput)
+	errFormatWidth    = fmt.Errorf("printf width and precision are limited to %d", maxFormatWidth)
+)
+
+// inlineFuncs replaces printf in inline templates with a version refusing huge padding, which
+// would let a short template allocate gigabytes
+var inlineFuncs = template.FuncMap{
+	"printf": func(format string, args ...any) (string, error) {
+		if err := checkFormat(format); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(format, args...), nil
+	},
+}
+
+// LoadTemplates parses every *.tmpl file in dir as a named template, keyed by its file name
+// without the extension. An empty dir loads none.
+func LoadTemplates(dir string) (map[string]*template.Template, error) {
+	named := make(map[string]*template.Template)
+	if dir == "" {
+		return named, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading template: %w", err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %s: %w", path, err)
+		}
+		named[name] = tmpl
+	}
+	return named, nil
+}
+
+// parseInline parses a template from the query string. Since it comes from an untrusted client,
+// template definitions and calls are refused and range may only iterate over a field, without
+// nesting, so execution time stays linear in the size of the response data.
+func parseInline(text string) (*template.Template, error) {
+	if len(text) > maxInlineTemplate {
+		return nil, fmt.Errorf("template longer than %d bytes", maxInlineTemplate)
+	}
+	tmpl, err := template.New("inline").Funcs(inlineFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, errors.New("template definitions are not allowed")
+	}
+	if tmpl.Tree == nil {
+		return tmpl, nil
+	}
+	return tmpl, checkInline(tmpl.Tree.Root, false)
+}
+
+// checkInline walks the parse tree of an inline template rejecting constructs whose cost is not
+// bounded by the response data
+func checkInline(node parse.Node, inRange bool) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkInline(child, inRange); err != nil {
+				return err
+			}
+		}
+	case *parse.TemplateNode:
+		return errors.New("template calls are not allowed")
+	case *parse.RangeNode:
+		if inRange {
+			return errors.New("nested range is not allowed")
+		}
+		if !rangesOverField(n.Pipe) {
+			return errors.New("range may only iterate over a field such as .AllCandidates")
+		}
+		if err := checkInline(n.List, true); err != nil {
+			return err
+		}
+		return checkInline(n.ElseList, true)
+	case *parse.IfNode:
+		if err := checkInline(n.List, inRange); err != nil {
+			return err
+		}
+		return checkInline(n.ElseList, inRange)
+	case *parse.WithNode:
+		if err := checkInline(n.List, inRange); err != nil {
+			return err
+		}
+		return checkInline(n.ElseList, inRange)
+	}
+	return nil
+}
+
+// rangesOverField reports whether a range pipeline is a single field reference, ruling out
+// ranging over integers
+func rangesOverField(pipe *parse.PipeNode) bool {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	_, ok := pipe.Cmds[0].Args[0].(*parse.FieldNode)
+	return ok
+}
+
+// checkFormat rejects printf formats with a width or precision above maxFormatWidth, or taken
+// from an argument with *
+func checkFormat(format string) error {
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		number := 0
+		for i++; i < len(format) && strings.IndexByte("+-# 0123456789.[]*", format[i]) >= 0; i++ {
+			switch c := format[i]; {
+			case c == '*':
+				return errFormatWidth
+			case c >= '0' && c <= '9':
+				if number = number*10 + int(c-'0'); number > maxFormatWidth {
+					return errFormatWidth
+				}
+			default:
+				number = 0
+			}
+		}
+	}
+	return nil
+}
+
+// limitedBuffer collects template output, failing once it exceeds maxTemplateOutput
+type limitedBuffer struct {
+	*bytes.Buffer
+}
+
+func (b limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > maxTemplateOutput {
+		return 0, errTemplateOutput
+	}
+	return b.Buffer.Write(p)
+}
+
+// writeTemplate renders info through the template selected by ?template=, answering 400 Bad
+// Request when it is unknown, invalid, or fails to execute
+func writeTemplate(w http.ResponseWriter, info *models.IPInfo, value string) {
+	settings := templates.Load()
+	if settings == nil {
+		settings = &Templates{}
+	}
+
+	var tmpl *template.Template
+	if name, ok := strings.CutPrefix(value, namedTemplatePrefix); ok {
+		if tmpl = settings.Named[name]; tmpl == nil {
+			http.Error(w, fmt.Sprintf("Unknown template %q", name), http.StatusBadRequest)
+			return
+		}
+	} else {
+		if !settings.Inline {
+			http.Error(w, "Inline templates are disabled; use ?template=@name", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if tmpl, err = parseInline(value); err != nil {
+			http.Error(w, "Invalid template: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := tmpl.Execute(limitedBuffer{buf}, info); err != nil {
+		http.Error(w, "Template failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write(buf.Bytes())
+}
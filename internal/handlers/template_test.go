@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// templateRequest serves /info with the given ?template= value
+func templateRequest(value string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/info?template="+url.QueryEscape(value), nil)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+	req.Header.Set("X-Forwarded-For", "198.51.100.9")
+	req.RemoteAddr = "192.168.1.1:12345"
+
+	rr := httptest.NewRecorder()
+	InfoHandler(rr, req)
+	return rr
+}
+
+func TestInfoTemplate(t *testing.T) {
+	SetTemplates(Templates{Inline: true})
+	defer templates.Store(nil)
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"fields", "{{.ClientIP}} via {{.DetectedVia}}", "203.0.113.1 via CF-Connecting-IP"},
+		{"conditional", "{{if .IsCloudflare}}cf{{else}}direct{{end}}", "cf"},
+		{"range", "{{range .AllCandidates}}{{.IP}}={{.Source}};{{end}}", "203.0.113.1=CF-Connecting-IP;198.51.100.9=X-Forwarded-For;"},
+		{"printf", `{{printf "%-12s|" .ClientIP}}`, "203.0.113.1 |"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := templateRequest(tt.template)
+			if rr.Code != http.StatusOK || rr.Body.String() != tt.want {
+				t.Errorf("template %q = %d %q, want %q", tt.template, rr.Code, rr.Body.String(), tt.want)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "text/plain" {
+				t.Errorf("Content-Type = %q, want text/plain", ct)
+			}
+		})
+	}
+}
+
+func TestInfoTemplateRejected(t *testing.T) {
+	SetTemplates(Templates{Inline: true})
+	defer templates.Store(nil)
+
+	tests := []struct {
+		name     string
+		template string
+	}{
+		{"syntax", "{{.ClientIP"},
+		{"unknown field", "{{.Country}}"},
+		{"too long", strings.Repeat("x", maxInlineTemplate+1)},
+		{"define", `{{define "x"}}{{end}}{{.ClientIP}}`},
+		{"template call", `{{template "inline"}}`},
+		{"nested range", "{{range .AllCandidates}}{{range $.AllCandidates}}{{end}}{{end}}"},
+		{"range over integer", "{{range 1000000000}}{{end}}"},
+		{"printf width", `{{printf "%999999999d" 1}}`},
+		{"printf star", `{{printf "%*d" 100000000 1}}`},
+		{"named unknown", "@missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := templateRequest(tt.template)
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("template %q = %d %q, want 400", tt.template, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestInfoTemplateDisabled(t *testing.T) {
+	templates.Store(nil)
+	if rr := templateRequest("{{.ClientIP}}"); rr.Code != http.StatusBadRequest {
+		t.Errorf("inline template without settings = %d, want 400", rr.Code)
+	}
+
+	// An empty parameter serves the regular response
+	req := httptest.NewRequest("GET", "/info?template=", nil)
+	rr := httptest.NewRecorder()
+	InfoHandler(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Your IP Address:") {
+		t.Errorf("empty template = %d %q, want the plain /info response", rr.Code, rr.Body.String())
+	}
+}
+
+func TestNamedTemplates(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "short.tmpl"), []byte("{{.ClientIP}}\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "nested.tmpl"), []byte(`{{define "ip"}}{{.IP}}{{end}}{{range .AllCandidates}}{{template "ip" .}} {{end}}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "huge.tmpl"), []byte(`{{printf "%70000s" ""}}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("{{"), 0o644)
+
+	named, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatalf("LoadTemplates() error = %v", err)
+	}
+	if len(named) != 3 {
+		t.Fatalf("LoadTemplates() loaded %d templates, want 3", len(named))
+	}
+
+	SetTemplates(Templates{Named: named})
+	defer templates.Store(nil)
+
+	if rr := templateRequest("@short"); rr.Code != http.StatusOK || rr.Body.String() != "203.0.113.1\n" {
+		t.Errorf("@short = %d %q", rr.Code, rr.Body.String())
+	}
+	// Named templates are trusted and may use constructs refused inline
+	if rr := templateRequest("@nested"); rr.Code != http.StatusOK || rr.Body.String() != "203.0.113.1 198.51.100.9 " {
+		t.Errorf("@nested = %d %q", rr.Code, rr.Body.String())
+	}
+	if rr := templateRequest("@huge"); rr.Code != http.StatusBadRequest {
+		t.Errorf("@huge = %d, want 400 for output over the limit", rr.Code)
+	}
+	if rr := templateRequest("{{.ClientIP}}"); rr.Code != http.StatusBadRequest {
+		t.Errorf("inline template with Inline disabled = %d, want 400", rr.Code)
+	}
+
+	os.WriteFile(filepath.Join(dir, "broken.tmpl"), []byte("{{.ClientIP"), 0o644)
+	if _, err := LoadTemplates(dir); err == nil {
+		t.Error("LoadTemplates() accepted an invalid template")
+	}
+	if named, err := LoadTemplates(""); err != nil || len(named) != 0 {
+		t.Errorf("LoadTemplates(\"\") = %v, %v, want none", named, err)
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	for format, ok := range map[string]bool{
+		"%s":       true,
+		"%-64s|%d": true,
+		"%08.3f":   true,
+		"100%%":    true,
+		"%65s":     false,
+		"%.100f":   false,
+		"%*d":      false,
+		"%[1]*d":   false,
+	} {
+		if err := checkFormat(format); (err == nil) != ok {
+			t.Errorf("checkFormat(%q) = %v, want ok %t", format, err, ok)
+		}
+	}
+}
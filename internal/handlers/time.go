@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"myip/internal/models"
+)
+
+// maxSyncSkew is the largest skew, including the request's one-way latency, at which the client
+// clock still counts as synchronized. Certificate validity checks tolerate far more, but a clock
+// off by more than a couple of seconds is not being kept in sync.
+const maxSyncSkew = 2 * time.Second
+
+// TimeHandler reports the server's time in several formats, matching the Date header it sends.
+// A client timestamp in ?t=, as Unix seconds, Unix milliseconds, or RFC 3339, is compared
+// against the receive time to estimate the client clock's skew.
+func TimeHandler(w http.ResponseWriter, r *http.Request) {
+	received := time.Now()
+	utc := received.UTC()
+
+	response := &models.TimeResponse{
+		UTC:      utc.Format(time.RFC3339Nano),
+		Unix:     received.Unix(),
+		UnixMs:   received.UnixMilli(),
+		HTTPDate: utc.Format(http.TimeFormat),
+		RFC1123Z: utc.Format(time.RFC1123Z),
+	}
+	if value := r.URL.Query().Get("t"); value != "" {
+		client, ok := parseClientTime(value)
+		if !ok {
+			http.Error(w, "Invalid t parameter: expected Unix seconds, Unix milliseconds, or RFC 3339", http.StatusBadRequest)
+			return
+		}
+		skew := client.Sub(received)
+		skewMs := float64(skew.Microseconds()) / 1000
+		synchronized := skew.Abs() <= maxSyncSkew
+		response.ClientTime = client.UTC().Format(time.RFC3339Nano)
+		response.SkewMs = &skewMs
+		response.Synchronized = &synchronized
+	}
+
+	w.Header().Set("Date", response.HTTPDate)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, r, formatJSON, http.StatusInternalServerError, models.ErrorEncodingFailed, "Failed to encode JSON response")
+	}
+}
+
+// parseClientTime parses an RFC 3339 timestamp or a Unix timestamp, read as milliseconds when it
+// is too large to be seconds before the year 5138
+func parseClientTime(value string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, true
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}, false
+	}
+	if n >= 1e11 {
+		return time.UnixMilli(n), true
+	}
+	return time.Unix(n, 0), true
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"myip/internal/models"
+)
+
+func TestTimeHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	TimeHandler(rr, httptest.NewRequest("GET", "/time", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var response models.TimeResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if rr.Header().Get("Date") != response.HTTPDate {
+		t.Errorf("Expected the Date header %q to match http_date %q", rr.Header().Get("Date"), response.HTTPDate)
+	}
+	utc, err := time.Parse(time.RFC3339Nano, response.UTC)
+	if err != nil || utc.UnixMilli() != response.UnixMs || utc.Unix() != response.Unix {
+		t.Errorf("Expected consistent times, got %+v", response)
+	}
+	if _, err := time.Parse(time.RFC1123Z, response.RFC1123Z); err != nil {
+		t.Errorf("Expected an RFC 1123 time, got %q", response.RFC1123Z)
+	}
+	if strings.Contains(rr.Body.String(), "skew_ms") {
+		t.Errorf("Expected no skew without a client time, got %s", rr.Body.String())
+	}
+}
+
+func TestTimeHandlerSkew(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name         string
+		t            string
+		minSkew      time.Duration
+		maxSkew      time.Duration
+		synchronized bool
+	}{
+		{"Unix seconds in sync", strconv.FormatInt(now.Unix(), 10), -2 * time.Second, time.Second, true},
+		{"Unix milliseconds behind", strconv.FormatInt(now.Add(-time.Hour).UnixMilli(), 10), -time.Hour - time.Second, -time.Hour + time.Second, false},
+		{"RFC 3339 ahead", now.Add(10 * time.Minute).UTC().Format(time.RFC3339Nano), 10*time.Minute - time.Second, 10 * time.Minute, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			TimeHandler(rr, httptest.NewRequest("GET", "/time?t="+test.t, nil))
+
+			var response models.TimeResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.SkewMs == nil || response.Synchronized == nil {
+				t.Fatalf("Expected a skew estimate, got %s", rr.Body.String())
+			}
+			skew := time.Duration(*response.SkewMs * float64(time.Millisecond))
+			if skew < test.minSkew || skew > test.maxSkew {
+				t.Errorf("Expected a skew between %v and %v, got %v", test.minSkew, test.maxSkew, skew)
+			}
+			if *response.Synchronized != test.synchronized {
+				t.Errorf("Expected synchronized %v, got %v", test.synchronized, *response.Synchronized)
+			}
+		})
+	}
+}
+
+func TestTimeHandlerInvalidClientTime(t *testing.T) {
+	for _, value := range []string{"yesterday", "-5", "0", "2024-01-01"} {
+		rr := httptest.NewRecorder()
+		TimeHandler(rr, httptest.NewRequest("GET", "/time?t="+value, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for t=%s, got %d", value, rr.Code)
+		}
+	}
+}
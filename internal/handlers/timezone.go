@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"myip/internal/models"
+)
+
+// timezoneAuto selects the visitor's time zone reported by the CDN, see timezoneHeader
+const timezoneAuto = "auto"
+
+// timezoneHeader carries the visitor's IANA time zone, derived from the client IP by Cloudflare's
+// "Add visitor location headers" managed transform
+const timezoneHeader = "Cf-Timezone"
+
+// zones caches the *time.Location of each time zone loaded, sparing a tz database read per
+// request. Only valid zones are stored, so it is bounded by the tz database.
+var zones sync.Map
+
+// loadZone returns the IANA time zone name, rejecting "Local", which would reveal the server's
+// own zone
+func loadZone(name string) (*time.Location, bool) {
+	if cached, ok := zones.Load(name); ok {
+		return cached.(*time.Location), true
+	}
+	if name == "" || name == "Local" {
+		return nil, false
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, false
+	}
+	zones.Store(name, location)
+	return location, true
+}
+
+// localizeTimestamp sets info.LocalTimestamp to info.Timestamp in the time zone named by ?tz=,
+// an IANA name such as Europe/Berlin, or the one in timezoneHeader for ?tz=auto. It reports false
+// for an unknown zone name; ?tz=auto without a valid header leaves info unchanged.
+func localizeTimestamp(r *http.Request, info *models.IPInfo) bool {
+	name := queryValue(r, "tz")
+	if name == "" {
+		return true
+	}
+	auto := name == timezoneAuto
+	if auto {
+		name = r.Header.Get(timezoneHeader)
+	}
+
+	location, ok := loadZone(name)
+	if !ok {
+		// A missing or unknown zone from the CDN is not the client's mistake
+		return auto
+	}
+	timestamp, err := time.Parse(time.RFC3339, info.Timestamp)
+	if err != nil {
+		return true
+	}
+	info.LocalTimestamp, info.Timezone = timestamp.In(location).Format(time.RFC3339), location.String()
+	return true
+}
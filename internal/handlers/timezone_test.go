@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"myip/internal/models"
+)
+
+func TestTimezone(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		header   string
+		status   int
+		timezone string
+	}{
+		{"no zone", "", "", http.StatusOK, ""},
+		{"named zone", "?tz=Europe/Berlin", "", http.StatusOK, "Europe/Berlin"},
+		{"zone from the CDN", "?tz=auto", "Asia/Jakarta", http.StatusOK, "Asia/Jakarta"},
+		{"auto without the header", "?tz=auto", "", http.StatusOK, ""},
+		{"auto with an unknown zone", "?tz=auto", "Mars/Olympus_Mons", http.StatusOK, ""},
+		{"unknown zone", "?tz=Mars/Olympus_Mons", "", http.StatusBadRequest, ""},
+		{"server zone", "?tz=Local", "", http.StatusBadRequest, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/json"+test.query, nil)
+			if test.header != "" {
+				req.Header.Set(timezoneHeader, test.header)
+			}
+			rr := httptest.NewRecorder()
+			JSONHandler(rr, req)
+			if rr.Code != test.status {
+				t.Fatalf("Expected status %d, got %d: %s", test.status, rr.Code, rr.Body.String())
+			}
+			if test.status != http.StatusOK {
+				var response models.ErrorResponse
+				if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil || response.Error != models.ErrorInvalidTimezone {
+					t.Errorf("Expected an invalid_timezone error, got %s", rr.Body.String())
+				}
+				return
+			}
+
+			var response models.IPInfo
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse JSON response: %v", err)
+			}
+			if response.Timezone != test.timezone {
+				t.Errorf("Expected time zone %q, got %q", test.timezone, response.Timezone)
+			}
+			if test.timezone == "" {
+				if response.LocalTimestamp != "" {
+					t.Errorf("Expected no local timestamp, got %q", response.LocalTimestamp)
+				}
+				return
+			}
+			utc, err := time.Parse(time.RFC3339, response.Timestamp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			local, err := time.Parse(time.RFC3339, response.LocalTimestamp)
+			if err != nil {
+				t.Fatalf("Expected an RFC 3339 local timestamp, got %q", response.LocalTimestamp)
+			}
+			if !local.Equal(utc) || strings.HasSuffix(response.LocalTimestamp, "Z") {
+				t.Errorf("Expected %s in %s, got %s", response.Timestamp, test.timezone, response.LocalTimestamp)
+			}
+		})
+	}
+}
+
+func TestInfoHandlerTimezone(t *testing.T) {
+	req := httptest.NewRequest("GET", "/info?tz=Asia/Tokyo", nil)
+	rr := httptest.NewRecorder()
+	InfoHandler(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "+09:00 (Asia/Tokyo)\n") {
+		t.Errorf("Expected the local time line, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/info?tz=Nowhere", nil)
+	rr = httptest.NewRecorder()
+	InfoHandler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown time zone, got %d", rr.Code)
+	}
+}
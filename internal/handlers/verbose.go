@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"myip/internal/ip"
+	"myip/internal/models"
+)
+
+// writeDetectedIP answers ?verbose=1 on / and /ipv6 with addr, the source it was taken from, and
+// every candidate considered, timed from start. The response is JSON, or JSONP with format=jsonp,
+// since the plain-text format has room for the address alone.
+func writeDetectedIP(w http.ResponseWriter, r *http.Request, format, addr string, start time.Time) {
+	candidates := ip.Candidates(r)
+	response := models.DetectedIP{
+		IP:            addr,
+		DetectedVia:   addressSource(r, addr, candidates),
+		AllCandidates: candidates,
+		DetectionMs:   durationMs(time.Since(start)),
+	}
+	if response.AllCandidates == nil {
+		response.AllCandidates = []models.IPCandidate{}
+	}
+
+	jsonBytes, err := json.Marshal(&response)
+	if err != nil {
+		writeError(w, r, formatJSON, http.StatusInternalServerError, models.ErrorEncodingFailed, "Failed to encode JSON response")
+		return
+	}
+
+	if format == formatJSONP {
+		w.Header().Set("Content-Type", "application/javascript")
+		fmt.Fprintf(w, "%s(%s);", sanitizeCallback(r.URL.Query().Get("callback")), jsonBytes)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(jsonBytes, '\n'))
+}
+
+// addressSource returns the header addr was found in, or RemoteAddr. The candidates only hold
+// public addresses, so a private one is attributed to the client IP's source when they match.
+func addressSource(r *http.Request, addr string, candidates []models.IPCandidate) string {
+	want, err := netip.ParseAddr(addr)
+	if err != nil {
+		return ""
+	}
+	for _, candidate := range candidates {
+		if got, err := netip.ParseAddr(candidate.IP); err == nil && got == want {
+			return candidate.Source
+		}
+	}
+	clientIP, source := ip.ExtractClientIP(r)
+	if got, err := netip.ParseAddr(clientIP); err == nil && got == want {
+		return source
+	}
+	return ""
+}
+
+// durationMs converts d to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"myip/internal/models"
+)
+
+func TestVerboseIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		target     string
+		xff        string
+		remoteAddr string
+		want       models.DetectedIP
+	}{
+		{
+			name:       "IPv4 from a header",
+			handler:    IPv4Handler,
+			target:     "/?verbose=1",
+			xff:        "203.0.113.1, 198.51.100.4",
+			remoteAddr: "10.0.0.2:12345",
+			want: models.DetectedIP{IP: "203.0.113.1", DetectedVia: "X-Forwarded-For", AllCandidates: []models.IPCandidate{
+				{IP: "203.0.113.1", Source: "X-Forwarded-For"},
+				{IP: "198.51.100.4", Source: "X-Forwarded-For"},
+			}},
+		},
+		{
+			name:       "Private IPv4 from the connection",
+			handler:    IPv4Handler,
+			target:     "/?verbose=true&format=json",
+			remoteAddr: "10.0.0.2:12345",
+			want:       models.DetectedIP{IP: "10.0.0.2", DetectedVia: "RemoteAddr", AllCandidates: []models.IPCandidate{}},
+		},
+		{
+			name:       "Expanded IPv6",
+			handler:    IPv6Handler,
+			target:     "/ipv6?verbose=1&compress=false",
+			remoteAddr: "[2001:db8::1]:12345",
+			want: models.DetectedIP{IP: "2001:0db8:0000:0000:0000:0000:0000:0001", DetectedVia: "RemoteAddr", AllCandidates: []models.IPCandidate{
+				{IP: "2001:db8::1", Source: "RemoteAddr"},
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", test.target, nil)
+			if test.xff != "" {
+				req.Header.Set("X-Forwarded-For", test.xff)
+			}
+			req.RemoteAddr = test.remoteAddr
+
+			rr := httptest.NewRecorder()
+			test.handler(rr, req)
+			if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Expected JSON, got %s", ct)
+			}
+
+			var got models.DetectedIP
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to parse JSON response %q: %v", rr.Body.String(), err)
+			}
+			if got.DetectionMs <= 0 {
+				t.Errorf("Expected the detection time, got %v", got.DetectionMs)
+			}
+			got.DetectionMs = 0
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestVerboseIPJSONP(t *testing.T) {
+	req := httptest.NewRequest("GET", "/?verbose=1&format=jsonp&callback=show", nil)
+	req.RemoteAddr = "203.0.113.1:12345"
+
+	rr := httptest.NewRecorder()
+	IPv4Handler(rr, req)
+	body := rr.Body.String()
+	if !strings.HasPrefix(body, `show({"ip":"203.0.113.1","detected_via":"RemoteAddr"`) || !strings.HasSuffix(body, ");") {
+		t.Errorf("Expected a JSONP call with the detection details, got %s", body)
+	}
+}
+
+func TestVerboseIPOff(t *testing.T) {
+	req := httptest.NewRequest("GET", "/?verbose=0", nil)
+	req.RemoteAddr = "203.0.113.1:12345"
+
+	rr := httptest.NewRecorder()
+	IPv4Handler(rr, req)
+	if body := rr.Body.String(); strings.TrimSpace(body) != "203.0.113.1" {
+		t.Errorf("Expected the plain address without verbose, got %q", body)
+	}
+}
@@ -0,0 +1,122 @@
+// Package i18n localizes the text shown to end users, such as the plain-text /info page, from
+// message catalogs embedded in the binary. Adding a language takes a catalog file in locales/
+// named after its language subtag.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Default is the locale used when neither the request nor the configuration selects one
+const Default = "en"
+
+//go:embed locales/*.json
+var files embed.FS
+
+// Messages is a message catalog
+type Messages struct {
+	YourIP            string `json:"your_ip"`
+	DetectionMethod   string `json:"detection_method"`
+	IsPrivateIP       string `json:"is_private_ip"`
+	BehindCloudflare  string `json:"behind_cloudflare"`
+	IPv4Address       string `json:"ipv4_address"`
+	IPv6Address       string `json:"ipv6_address"`
+	SourcePort        string `json:"source_port"`
+	IPType            string `json:"ip_type"`
+	Warning           string `json:"warning"`
+	SpoofingSuspected string `json:"spoofing_suspected"`
+	Timestamp         string `json:"timestamp"`
+	LocalTime         string `json:"local_time"`
+	True              string `json:"true"`
+	False             string `json:"false"`
+}
+
+// Bool returns the localized form of b
+func (m *Messages) Bool(b bool) string {
+	if b {
+		return m.True
+	}
+	return m.False
+}
+
+// catalogs maps each supported locale to its messages; it is never modified after init
+var catalogs = load()
+
+// load parses the embedded catalogs, panicking on a malformed one as it is part of the build
+func load() map[string]*Messages {
+	entries, err := files.ReadDir("locales")
+	if err != nil {
+		panic("i18n: " + err.Error())
+	}
+
+	loaded := make(map[string]*Messages, len(entries))
+	for _, entry := range entries {
+		data, err := files.ReadFile("locales/" + entry.Name())
+		if err != nil {
+			panic("i18n: " + err.Error())
+		}
+		var messages Messages
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic("i18n: " + entry.Name() + ": " + err.Error())
+		}
+		loaded[strings.TrimSuffix(entry.Name(), path.Ext(entry.Name()))] = &messages
+	}
+	return loaded
+}
+
+// Supported returns the supported locales in order
+func Supported() []string {
+	locales := make([]string, 0, len(catalogs))
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	slices.Sort(locales)
+	return locales
+}
+
+// IsSupported reports whether locale has a catalog
+func IsSupported(locale string) bool {
+	_, ok := catalogs[locale]
+	return ok
+}
+
+// Lookup returns the messages of locale, or those of Default when it is not supported
+func Lookup(locale string) *Messages {
+	if messages, ok := catalogs[locale]; ok {
+		return messages
+	}
+	return catalogs[Default]
+}
+
+// Negotiate picks the supported locale the client prefers according to an Accept-Language
+// header, matching on the language subtag so that zh-CN selects zh. It returns fallback when
+// the header is empty or names no supported language.
+func Negotiate(acceptLanguage, fallback string) string {
+	best, bestQ := fallback, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		language, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if !IsSupported(language) {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		// Earlier entries win ties, as clients list languages in order of preference
+		if q > bestQ {
+			best, bestQ = language, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,56 @@
+package i18n
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestCatalogsComplete(t *testing.T) {
+	if want := []string{"en", "es", "id", "zh"}; !slices.Equal(Supported(), want) {
+		t.Errorf("Supported() = %v, want %v", Supported(), want)
+	}
+
+	for _, locale := range Supported() {
+		messages := reflect.ValueOf(*Lookup(locale))
+		for i := range messages.NumField() {
+			if messages.Field(i).String() == "" {
+				t.Errorf("%s catalog lacks %s", locale, messages.Type().Field(i).Name)
+			}
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if got := Lookup("id").YourIP; got != "Alamat IP Anda" {
+		t.Errorf("Lookup(id).YourIP = %q", got)
+	}
+	if got := Lookup("fr"); got != Lookup(Default) {
+		t.Error("Expected an unsupported locale to fall back to the default catalog")
+	}
+	if got := Lookup("es").Bool(true); got != "sí" {
+		t.Errorf("Bool(true) = %q", got)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header, fallback, want string
+	}{
+		{"", "en", "en"},
+		{"", "id", "id"},
+		{"id", "en", "id"},
+		{"zh-CN,zh;q=0.9,en;q=0.8", "en", "zh"},
+		{"fr-FR,fr;q=0.9,es;q=0.8,en;q=0.7", "en", "es"},
+		{"en;q=0.5, ES-mx", "id", "es"},
+		{"en, id", "es", "en"},
+		{"fr, de", "id", "id"},
+		{"*", "es", "es"},
+		{"id;q=0, en;q=bogus", "zh", "zh"},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.header, tt.fallback); got != tt.want {
+			t.Errorf("Negotiate(%q, %q) = %q, want %q", tt.header, tt.fallback, got, tt.want)
+		}
+	}
+}
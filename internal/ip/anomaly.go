@@ -0,0 +1,114 @@
+package ip
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"myip/internal/logging"
+	"myip/internal/privacy"
+	"myip/internal/requestid"
+)
+
+// anomalyLogInterval spaces the anomaly log lines, so a client sending conflicting headers on
+// every request cannot flood the log; the anomalies in between are counted in the next line
+const anomalyLogInterval = time.Second
+
+// headerAnomalies holds an *atomic.Int64 per set of conflicting headers, keyed by their names
+// joined by "+" in priority order. The sets are bounded by the configured header priorities.
+var headerAnomalies sync.Map
+
+// anomalyLog spaces the anomaly log lines
+var anomalyLog struct {
+	mu         sync.Mutex
+	last       time.Time
+	suppressed int
+}
+
+// headerAnomaly is the JSON record logged for a request with conflicting client IP headers
+type headerAnomaly struct {
+	Event      string            `json:"event"`
+	RequestID  string            `json:"request_id,omitempty"`
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Peer       string            `json:"peer"`
+	ClientIP   string            `json:"client_ip"`
+	Source     string            `json:"source"`
+	Headers    map[string]string `json:"headers"`
+	Suppressed int               `json:"suppressed,omitempty"`
+}
+
+// AnomalyMiddleware counts and logs requests whose client IP headers name different public
+// addresses, see ipdetect.Detector.Conflicts. Each is logged as a warning holding a JSON record
+// of the headers, at most once per anomalyLogInterval, and counted in WriteAnomalyMetrics.
+// Responses are unaffected.
+func AnomalyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := detector()
+		if conflicts := d.Conflicts(r); conflicts != nil {
+			names := make([]string, len(conflicts))
+			headers := make(map[string]string, len(conflicts))
+			for i, conflict := range conflicts {
+				names[i] = conflict.Header
+				headers[conflict.Header] = privacy.IP(conflict.IP)
+			}
+			loadCounter(&headerAnomalies, strings.Join(names, "+"), func() any { return &atomic.Int64{} }).(*atomic.Int64).Add(1)
+
+			if suppressed, ok := logAnomaly(time.Now()); ok {
+				clientIP, source := d.ClientIP(r)
+				peer, _, err := net.SplitHostPort(r.RemoteAddr)
+				if err != nil {
+					peer = r.RemoteAddr
+				}
+				record, _ := json.Marshal(headerAnomaly{
+					Event:      "conflicting_client_ip_headers",
+					RequestID:  requestid.FromContext(r.Context()),
+					Method:     r.Method,
+					Path:       r.URL.Path,
+					Peer:       privacy.IP(peer),
+					ClientIP:   privacy.IP(clientIP),
+					Source:     source,
+					Headers:    headers,
+					Suppressed: suppressed,
+				})
+				logging.Warnf("Conflicting client IP headers: %s", record)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logAnomaly reports whether an anomaly seen at now is logged and how many were suppressed since
+// the last one logged
+func logAnomaly(now time.Time) (int, bool) {
+	anomalyLog.mu.Lock()
+	defer anomalyLog.mu.Unlock()
+
+	if now.Sub(anomalyLog.last) < anomalyLogInterval {
+		anomalyLog.suppressed++
+		return 0, false
+	}
+	suppressed := anomalyLog.suppressed
+	anomalyLog.last, anomalyLog.suppressed = now, 0
+	return suppressed, true
+}
+
+// WriteAnomalyMetrics writes the conflicting header counters in the Prometheus text exposition
+// format
+func WriteAnomalyMetrics(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# HELP myip_header_anomalies_total Requests whose client IP headers named different public addresses, by the conflicting headers.\n")
+	b.WriteString("# TYPE myip_header_anomalies_total counter\n")
+	for _, name := range sortedNames(&headerAnomalies) {
+		counter, _ := headerAnomalies.Load(name)
+		fmt.Fprintf(&b, "myip_header_anomalies_total{headers=%q} %d\n", name, counter.(*atomic.Int64).Load())
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
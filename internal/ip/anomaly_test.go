@@ -0,0 +1,86 @@
+package ip
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnomalyMiddleware(t *testing.T) {
+	defer Configure(Settings{})
+	Configure(Settings{HeaderPriority: []string{"X-Anomaly-Client-IP", "X-Anomaly-Forwarded-For"}})
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	anomalyLog.last = time.Time{}
+
+	served := 0
+	handler := AnomalyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	}))
+	serve := func(client, forwarded string) {
+		req := httptest.NewRequest("GET", "/json", nil)
+		req.Header.Set("X-Anomaly-Client-IP", client)
+		req.Header.Set("X-Anomaly-Forwarded-For", forwarded)
+		req.RemoteAddr = "198.51.100.20:1234"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	serve("203.0.113.9", "203.0.113.9, 10.0.0.2")
+	if buf.Len() != 0 {
+		t.Fatalf("Expected agreeing headers not to be logged, got %s", buf.String())
+	}
+
+	serve("203.0.113.9", "198.51.100.7")
+	serve("203.0.113.9", "198.51.100.8")
+	if served != 3 {
+		t.Errorf("Expected every request served, got %d", served)
+	}
+
+	// Only the first anomaly within the interval is logged
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected one log line, got %q", lines)
+	}
+	_, payload, ok := strings.Cut(lines[0], "Conflicting client IP headers: ")
+	var record headerAnomaly
+	if !ok || json.Unmarshal([]byte(payload), &record) != nil {
+		t.Fatalf("Expected a JSON record, got %q", lines[0])
+	}
+	if record.Event != "conflicting_client_ip_headers" || record.Peer != "198.51.100.20" || record.ClientIP != "203.0.113.9" ||
+		record.Source != "X-Anomaly-Client-IP" || record.Headers["X-Anomaly-Forwarded-For"] != "198.51.100.7" {
+		t.Errorf("Unexpected record %+v", record)
+	}
+
+	var metrics strings.Builder
+	if err := WriteAnomalyMetrics(&metrics); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(metrics.String(), `myip_header_anomalies_total{headers="X-Anomaly-Client-IP+X-Anomaly-Forwarded-For"} 2`) {
+		t.Errorf("Expected both anomalies counted, got:\n%s", metrics.String())
+	}
+}
+
+func TestLogAnomaly(t *testing.T) {
+	anomalyLog.last, anomalyLog.suppressed = time.Time{}, 0
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if suppressed, ok := logAnomaly(now); !ok || suppressed != 0 {
+		t.Errorf("Expected the first anomaly logged, got %v, %d", ok, suppressed)
+	}
+	for range 3 {
+		if _, ok := logAnomaly(now.Add(100 * time.Millisecond)); ok {
+			t.Error("Expected anomalies within the interval suppressed")
+		}
+	}
+	if suppressed, ok := logAnomaly(now.Add(anomalyLogInterval)); !ok || suppressed != 3 {
+		t.Errorf("Expected the next anomaly logged with 3 suppressed, got %v, %d", ok, suppressed)
+	}
+}
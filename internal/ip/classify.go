@@ -0,0 +1,29 @@
+package ip
+
+import "sync/atomic"
+
+// TypeClassifier reports the network type of an address: residential, mobile, hosting, or vpn
+type TypeClassifier interface {
+	Classify(ip string) string
+}
+
+// typeClassifier holds the configured TypeClassifier; GetInfo leaves IPType empty while it is unset
+var typeClassifier atomic.Pointer[TypeClassifier]
+
+// SetTypeClassifier enables network type classification in GetInfo; a nil classifier disables it
+func SetTypeClassifier(classifier TypeClassifier) {
+	if classifier == nil {
+		typeClassifier.Store(nil)
+		return
+	}
+	typeClassifier.Store(&classifier)
+}
+
+// networkType returns the network type of ip, or "" when no classifier is configured
+func networkType(ip string) string {
+	classifier := typeClassifier.Load()
+	if classifier == nil {
+		return ""
+	}
+	return (*classifier).Classify(ip)
+}
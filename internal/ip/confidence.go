@@ -0,0 +1,114 @@
+package ip
+
+import (
+	"math"
+	"net/http"
+
+	"myip/internal/models"
+
+	"github.com/akhfa/myip/ipdetect"
+)
+
+// Confidence levels
+const (
+	ConfidenceHigh   = "high"
+	ConfidenceMedium = "medium"
+	ConfidenceLow    = "low"
+)
+
+// Confidence factors, each lowering the score by its penalty
+const (
+	// FactorDirect: the client IP is the peer address of a request without proxy headers
+	FactorDirect = "direct_connection"
+	// FactorTrustedProxy: the client IP came from a header sent by a peer in TRUSTED_PROXIES
+	FactorTrustedProxy = "trusted_proxy"
+	// FactorCorroborated: another header names the same client IP
+	FactorCorroborated = "corroborated"
+	// FactorCloudflare: a Cloudflare header names the client IP
+	FactorCloudflare = "cloudflare"
+	// FactorUnverifiedProxy: the client IP came from a header while no TRUSTED_PROXIES are set,
+	// so any client could have sent it
+	FactorUnverifiedProxy = "unverified_proxy"
+	// FactorUntrustedHeaders: proxy headers from a peer outside TRUSTED_PROXIES were ignored, so
+	// the client IP may be a proxy's
+	FactorUntrustedHeaders = "untrusted_headers_ignored"
+	// FactorFallback: higher-priority headers held no valid address
+	FactorFallback = "fallback"
+	// FactorConflicting: headers name different public clients
+	FactorConflicting = "conflicting_headers"
+	// FactorSpoofing: the headers contradict the connection or each other, see Spoofing
+	FactorSpoofing = "spoofing_suspected"
+)
+
+// confidencePenalties are subtracted from a score of 1 for each factor present
+var confidencePenalties = map[string]float64{
+	FactorUnverifiedProxy:  0.4,
+	FactorUntrustedHeaders: 0.3,
+	FactorFallback:         0.1,
+	FactorConflicting:      0.4,
+	FactorSpoofing:         0.5,
+}
+
+// confidence rates the client IP detected on r with the settings of s, given the request's
+// spoofing reasons
+func confidence(s *state, r *http.Request, spoofing []string) *models.Confidence {
+	d := s.detector
+	trusted := len(s.settings.TrustedProxies) > 0
+	decision := d.Decide(r)
+	var factors []string
+	switch {
+	case decision.Source != ipdetect.SourceRemoteAddr && trusted:
+		factors = append(factors, FactorTrustedProxy)
+	case decision.Source != ipdetect.SourceRemoteAddr:
+		factors = append(factors, FactorUnverifiedProxy)
+	case decision.Untrusted:
+		factors = append(factors, FactorUntrustedHeaders)
+	case len(decision.Rejected) == 0:
+		factors = append(factors, FactorDirect)
+	}
+
+	clients := d.HeaderClients(r)
+	conflicting, corroborated, cloudflare := false, false, false
+	for _, client := range clients {
+		switch {
+		case client.IP != clients[0].IP:
+			conflicting = true
+		case client.IP != decision.ClientIP:
+		case client.Header == "CF-Connecting-IP" || client.Header == "True-Client-IP":
+			cloudflare = true
+			corroborated = corroborated || client.Header != decision.Source
+		case client.Header != decision.Source:
+			corroborated = true
+		}
+	}
+	if cloudflare && decision.Source != ipdetect.SourceRemoteAddr {
+		factors = append(factors, FactorCloudflare)
+	}
+	if corroborated && !conflicting && decision.Source != ipdetect.SourceRemoteAddr {
+		factors = append(factors, FactorCorroborated)
+	}
+	if len(decision.Rejected) > 0 {
+		factors = append(factors, FactorFallback)
+	}
+	if conflicting {
+		factors = append(factors, FactorConflicting)
+	}
+	if len(spoofing) > 0 {
+		factors = append(factors, FactorSpoofing)
+	}
+
+	score := 1.0
+	for _, factor := range factors {
+		score -= confidencePenalties[factor]
+	}
+	score = math.Round(max(score, 0)*100) / 100
+
+	level := ConfidenceLow
+	switch {
+	case score >= 0.8:
+		level = ConfidenceHigh
+	case score >= 0.5:
+		level = ConfidenceMedium
+	}
+	return &models.Confidence{Score: score, Level: level, Factors: factors, Fallback: decision.Rejected}
+}
@@ -0,0 +1,66 @@
+package ip
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestConfidence(t *testing.T) {
+	defer Configure(Settings{})
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		trusted    bool
+		headers    map[string]string
+		remoteAddr string
+		score      float64
+		level      string
+		factors    []string
+		fallback   []string
+	}{
+		{"direct connection", true, nil, "203.0.113.9:1234", 1, ConfidenceHigh, []string{FactorDirect}, nil},
+		{"headers agree behind a trusted proxy", true, map[string]string{"CF-Connecting-IP": "203.0.113.9", "X-Forwarded-For": "203.0.113.9"}, "10.0.0.1:1234",
+			1, ConfidenceHigh, []string{FactorTrustedProxy, FactorCloudflare, FactorCorroborated}, nil},
+		{"fallback past an invalid header", true, map[string]string{"X-Real-IP": "garbage", "X-Forwarded-For": "203.0.113.9"}, "10.0.0.1:1234",
+			0.9, ConfidenceHigh, []string{FactorTrustedProxy, FactorFallback}, []string{"X-Real-IP"}},
+		{"headers from an untrusted peer", true, map[string]string{"X-Forwarded-For": "203.0.113.9"}, "198.51.100.7:1234",
+			0.7, ConfidenceMedium, []string{FactorUntrustedHeaders}, nil},
+		{"headers disagree", true, map[string]string{"CF-Connecting-IP": "203.0.113.9", "X-Forwarded-For": "198.51.100.7"}, "10.0.0.1:1234",
+			0.6, ConfidenceMedium, []string{FactorTrustedProxy, FactorCloudflare, FactorConflicting}, nil},
+		{"no trusted proxies configured", false, map[string]string{"X-Forwarded-For": "203.0.113.9"}, "198.51.100.7:1234",
+			0.6, ConfidenceMedium, []string{FactorUnverifiedProxy}, nil},
+		{"Cloudflare header from a private peer", true, map[string]string{"CF-Connecting-IP": "203.0.113.9"}, "192.168.1.5:1234",
+			0.2, ConfidenceLow, []string{FactorUntrustedHeaders, FactorSpoofing}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := Settings{}
+			if test.trusted {
+				settings.TrustedProxies = trusted
+			}
+			Configure(settings)
+
+			req := httptest.NewRequest("GET", "/", nil)
+			for key, value := range test.headers {
+				req.Header.Set(key, value)
+			}
+			req.RemoteAddr = test.remoteAddr
+
+			confidence := GetInfo(req).Confidence
+			if confidence == nil {
+				t.Fatal("Expected a confidence rating")
+			}
+			if confidence.Score != test.score || confidence.Level != test.level {
+				t.Errorf("Expected %.2f %s, got %.2f %s", test.score, test.level, confidence.Score, confidence.Level)
+			}
+			if !reflect.DeepEqual(confidence.Factors, test.factors) || !reflect.DeepEqual(confidence.Fallback, test.fallback) {
+				t.Errorf("Expected factors %v and fallback %v, got %v and %v", test.factors, test.fallback, confidence.Factors, confidence.Fallback)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package ip
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sourceCounter counts the requests whose client IP came from one source, with the time of the
+// latest in Unix seconds
+type sourceCounter struct {
+	requests atomic.Int64
+	lastSeen atomic.Int64
+}
+
+// detectionSources and detectionRejected hold a *sourceCounter per source and an *atomic.Int64 per
+// header, keyed by header name or ipdetect.SourceRemoteAddr. The names are bounded by the
+// configured header priorities, so entries are never removed.
+var (
+	detectionSources   sync.Map
+	detectionRejected  sync.Map
+	detectionUntrusted atomic.Int64
+)
+
+// DetectionMetricsMiddleware records which source the client IP of each request came from and
+// which headers were passed over on the way, for WriteDetectionMetrics
+func DetectionMetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision := detector().Decide(r)
+		source := loadCounter(&detectionSources, decision.Source, func() any { return &sourceCounter{} }).(*sourceCounter)
+		source.requests.Add(1)
+		source.lastSeen.Store(time.Now().Unix())
+		for _, header := range decision.Rejected {
+			loadCounter(&detectionRejected, header, func() any { return &atomic.Int64{} }).(*atomic.Int64).Add(1)
+		}
+		if decision.Untrusted {
+			detectionUntrusted.Add(1)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// loadCounter returns the counter stored in m at name, storing one made by create on first use
+func loadCounter(m *sync.Map, name string, create func() any) any {
+	if counter, ok := m.Load(name); ok {
+		return counter
+	}
+	counter, _ := m.LoadOrStore(name, create())
+	return counter
+}
+
+// WriteDetectionMetrics writes the detection source counters in the Prometheus text exposition
+// format
+func WriteDetectionMetrics(w io.Writer) error {
+	var requests, lastSeen, invalid strings.Builder
+	requests.WriteString("# HELP myip_detection_source_total Requests on the detection endpoints by the source their client IP was taken from.\n")
+	requests.WriteString("# TYPE myip_detection_source_total counter\n")
+	lastSeen.WriteString("# HELP myip_detection_source_last_seen_timestamp_seconds Time of the latest request whose client IP was taken from each source.\n")
+	lastSeen.WriteString("# TYPE myip_detection_source_last_seen_timestamp_seconds gauge\n")
+	for _, name := range sortedNames(&detectionSources) {
+		counter, _ := detectionSources.Load(name)
+		source := counter.(*sourceCounter)
+		fmt.Fprintf(&requests, "myip_detection_source_total{source=%q} %d\n", name, source.requests.Load())
+		fmt.Fprintf(&lastSeen, "myip_detection_source_last_seen_timestamp_seconds{source=%q} %d\n", name, source.lastSeen.Load())
+	}
+	invalid.WriteString("# HELP myip_detection_rejected_total Proxy headers passed over because they held no valid address.\n")
+	invalid.WriteString("# TYPE myip_detection_rejected_total counter\n")
+	for _, name := range sortedNames(&detectionRejected) {
+		counter, _ := detectionRejected.Load(name)
+		fmt.Fprintf(&invalid, "myip_detection_rejected_total{header=%q} %d\n", name, counter.(*atomic.Int64).Load())
+	}
+	_, err := fmt.Fprintf(w, "%s%s%s"+
+		"# HELP myip_detection_untrusted_total Requests whose proxy headers were ignored because the peer is not a trusted proxy.\n"+
+		"# TYPE myip_detection_untrusted_total counter\n"+
+		"myip_detection_untrusted_total %d\n", requests.String(), lastSeen.String(), invalid.String(), detectionUntrusted.Load())
+	return err
+}
+
+// sortedNames returns the keys of m in order
+func sortedNames(m *sync.Map) []string {
+	var names []string
+	m.Range(func(key, _ any) bool {
+		names = append(names, key.(string))
+		return true
+	})
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,59 @@
+package ip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akhfa/myip/ipdetect"
+)
+
+func TestDetectionMetrics(t *testing.T) {
+	defer Configure(Settings{})
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Configure(Settings{HeaderPriority: []string{"X-Test-Client-IP", "X-Test-Forwarded-For"}, TrustedProxies: trusted})
+
+	handler := DetectionMetricsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(remoteAddr string, headers map[string]string) {
+		req := httptest.NewRequest("GET", "/", nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		req.RemoteAddr = remoteAddr
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	untrusted := detectionUntrusted.Load()
+	serve("10.0.0.1:1234", map[string]string{"X-Test-Client-IP": "203.0.113.1"})
+	serve("10.0.0.1:1234", map[string]string{"X-Test-Client-IP": "garbage", "X-Test-Forwarded-For": "203.0.113.2"})
+	serve("10.0.0.1:1234", map[string]string{"X-Test-Forwarded-For": "unknown"})
+	serve("198.51.100.7:1234", map[string]string{"X-Test-Client-IP": "203.0.113.1"})
+
+	var buf strings.Builder
+	if err := WriteDetectionMetrics(&buf); err != nil {
+		t.Fatal(err)
+	}
+	metrics := buf.String()
+	for _, expected := range []string{
+		`myip_detection_source_total{source="X-Test-Client-IP"} 1`,
+		`myip_detection_source_total{source="X-Test-Forwarded-For"} 1`,
+		`myip_detection_source_last_seen_timestamp_seconds{source="X-Test-Forwarded-For"} `,
+		`myip_detection_rejected_total{header="X-Test-Client-IP"} 1`,
+		`myip_detection_rejected_total{header="X-Test-Forwarded-For"} 1`,
+		"# TYPE myip_detection_untrusted_total counter",
+	} {
+		if !strings.Contains(metrics, expected) {
+			t.Errorf("Expected %q in the metrics, got:\n%s", expected, metrics)
+		}
+	}
+	if !strings.Contains(metrics, `myip_detection_source_total{source="`+ipdetect.SourceRemoteAddr+`"}`) {
+		t.Errorf("Expected the RemoteAddr fallbacks counted, got:\n%s", metrics)
+	}
+	if got := detectionUntrusted.Load() - untrusted; got != 1 {
+		t.Errorf("Expected one request with untrusted headers, got %d", got)
+	}
+}
@@ -2,7 +2,10 @@ package ip
 
 import (
 	"net/http/httptest"
+	"reflect"
 	"testing"
+
+	"myip/internal/models"
 )
 
 func TestIsValid(t *testing.T) {
@@ -298,6 +301,7 @@ func BenchmarkExtractClientIP(b *testing.B) {
 	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
 	req.Header.Set("X-Forwarded-For", "203.0.113.1, 10.0.0.1")
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		ExtractClientIP(req)
@@ -344,6 +348,7 @@ func BenchmarkFindIPv4(b *testing.B) {
 	req.RemoteAddr = "192.168.1.1:12345"
 	req.Header.Set("X-Forwarded-For", "203.0.113.1, 10.0.0.1, 192.168.1.1")
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		FindIPv4(req)
@@ -669,3 +674,48 @@ func TestIsCloudflareRequestTrueClientIP(t *testing.T) {
 		t.Error("Expected IsCloudflareRequest to return true for True-Client-IP header")
 	}
 }
+
+func TestCandidates(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "198.51.100.20:12345"
+	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+	req.Header.Set("X-Forwarded-For", "203.0.113.1, 10.0.0.1, unknown, 2001:db8:1::5, 198.51.100.20")
+	req.Header.Set("X-Real-IP", "192.168.1.1")
+
+	expected := []models.IPCandidate{
+		{IP: "203.0.113.1", Source: "CF-Connecting-IP"},
+		{IP: "2001:db8:1::5", Source: "X-Forwarded-For"},
+		{IP: "198.51.100.20", Source: "X-Forwarded-For"},
+	}
+	if got := Candidates(req); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestCandidatesPrivateOnly(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.5:12345"
+	req.Header.Set("X-Forwarded-For", "192.168.1.1")
+
+	if got := Candidates(req); got != nil {
+		t.Errorf("Expected no public candidates, got %+v", got)
+	}
+}
+
+func TestCandidatesUntrustedPeer(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Configure(Settings{TrustedProxies: trusted})
+	defer Configure(Settings{})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "198.51.100.20:12345"
+	req.Header.Set("X-Forwarded-For", "203.0.113.1")
+
+	expected := []models.IPCandidate{{IP: "198.51.100.20", Source: "RemoteAddr"}}
+	if got := Candidates(req); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected only RemoteAddr from an untrusted peer, got %+v", got)
+	}
+}
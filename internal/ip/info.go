@@ -4,23 +4,61 @@ import (
 	"net/http"
 	"time"
 
+	"myip/internal/cdn"
 	"myip/internal/models"
+	"myip/internal/privacy"
+
+	"github.com/akhfa/myip/ipdetect"
 )
 
-// GetInfo gets comprehensive IP information
+// GetInfo gets comprehensive IP information. The result comes from a pool; handlers that do not
+// retain it should hand it back with ReleaseInfo.
 func GetInfo(r *http.Request) *models.IPInfo {
-	clientIP, detectedVia := ExtractClientIP(r)
-	ipv4 := FindIPv4(r)
-	ipv6 := FindIPv6(r)
-
-	return &models.IPInfo{
-		ClientIP:     clientIP,
-		DetectedVia:  detectedVia,
-		IPv4Address:  ipv4,
-		IPv6Address:  ipv6,
-		IsPrivateIP:  IsPrivate(clientIP),
-		IsCloudflare: IsCloudflareRequest(r),
-		UserAgent:    r.Header.Get("User-Agent"),
-		Timestamp:    time.Now().UTC().Format(time.RFC3339),
+	s := current.Load()
+	d := s.detector
+	addresses := d.Extract(r)
+	clientIP, detectedVia := addresses.ClientIP, addresses.Source
+	isListed, threatFeeds := listedOn(clientIP)
+	spoofing := d.Spoofing(r)
+
+	var port int
+	if detectedVia == ipdetect.SourceRemoteAddr {
+		port = ipdetect.RemotePort(r)
+	}
+
+	tunnelType, embeddedIPv4 := tunnelOf(addresses.IPv6)
+
+	info := infoPool.Get().(*models.IPInfo)
+	*info = models.IPInfo{
+		ClientIP:      clientIP,
+		DetectedVia:   detectedVia,
+		IPv4Address:   addresses.IPv4,
+		IPv6Address:   addresses.IPv6,
+		IsPrivateIP:   IsPrivate(clientIP),
+		IsCloudflare:  IsCloudflareRequest(r),
+		TunnelType:    tunnelType,
+		EmbeddedIPv4:  embeddedIPv4,
+		IPType:        networkType(clientIP),
+		UserAgent:     userAgent(r),
+		IsListed:      isListed,
+		ThreatFeeds:   threatFeeds,
+		Timestamp:     time.Now().UTC().Format(time.RFC3339),
+		Port:          port,
+		AllCandidates: Candidates(r),
+		CDN:           cdn.FromRequest(r),
+		Warning:       strictWarning(r),
+
+		SpoofingSuspected: len(spoofing) > 0,
+		SpoofingReasons:   spoofing,
+		Confidence:        confidence(s, r, spoofing),
+	}
+	return info
+}
+
+// userAgent returns the request's User-Agent, or "" when privacy settings omit it
+func userAgent(r *http.Request) string {
+	if privacy.OmitUserAgent() {
+		return ""
 	}
+	return r.Header.Get("User-Agent")
 }
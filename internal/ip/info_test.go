@@ -3,6 +3,8 @@ package ip
 import (
 	"net/http/httptest"
 	"testing"
+
+	"myip/internal/privacy"
 )
 
 func TestGetInfo(t *testing.T) {
@@ -96,3 +98,84 @@ func TestGetInfoIPv6(t *testing.T) {
 		t.Errorf("Expected IPv6Address 2001:db8::1, got %s", info.IPv6Address)
 	}
 }
+
+// fakeListChecker lists every address under its feeds
+type fakeListChecker []string
+
+func (f fakeListChecker) Listed(ip string) []string { return f }
+
+func TestGetInfoThreatFeeds(t *testing.T) {
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.RemoteAddr = "203.0.113.1:12345"
+
+	if info := GetInfo(req); info.IsListed != nil || info.ThreatFeeds != nil {
+		t.Errorf("Expected no listing without a checker, got %v %v", info.IsListed, info.ThreatFeeds)
+	}
+
+	SetListChecker(fakeListChecker{"drop"})
+	defer SetListChecker(nil)
+
+	info := GetInfo(req)
+	if info.IsListed == nil || !*info.IsListed {
+		t.Fatal("Expected IsListed to be true")
+	}
+	if len(info.ThreatFeeds) != 1 || info.ThreatFeeds[0] != "drop" {
+		t.Errorf("Expected threat feeds [drop], got %v", info.ThreatFeeds)
+	}
+
+	SetListChecker(fakeListChecker(nil))
+	if info := GetInfo(req); info.IsListed == nil || *info.IsListed {
+		t.Error("Expected IsListed to be false when no feed lists the address")
+	}
+}
+
+// fakeTypeClassifier classifies every address as the same type
+type fakeTypeClassifier string
+
+func (f fakeTypeClassifier) Classify(ip string) string { return string(f) }
+
+func TestGetInfoIPType(t *testing.T) {
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.RemoteAddr = "203.0.113.1:12345"
+
+	if info := GetInfo(req); info.IPType != "" {
+		t.Errorf("Expected no IP type without a classifier, got %q", info.IPType)
+	}
+
+	SetTypeClassifier(fakeTypeClassifier("hosting"))
+	defer SetTypeClassifier(nil)
+
+	if info := GetInfo(req); info.IPType != "hosting" {
+		t.Errorf("Expected IP type hosting, got %q", info.IPType)
+	}
+}
+
+func TestGetInfoPort(t *testing.T) {
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.RemoteAddr = "203.0.113.1:54321"
+
+	if info := GetInfo(req); info.Port != 54321 {
+		t.Errorf("Expected port 54321 for a direct connection, got %d", info.Port)
+	}
+
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	if info := GetInfo(req); info.Port != 0 {
+		t.Errorf("Expected no port when the IP comes from a proxy header, got %d", info.Port)
+	}
+}
+
+func TestGetInfoOmitUserAgent(t *testing.T) {
+	defer privacy.Configure(false, false)
+
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.Header.Set("User-Agent", "TestAgent/1.0")
+
+	if info := GetInfo(req); info.UserAgent != "TestAgent/1.0" {
+		t.Errorf("Expected the User-Agent by default, got %q", info.UserAgent)
+	}
+
+	privacy.Configure(false, true)
+	if info := GetInfo(req); info.UserAgent != "" {
+		t.Errorf("Expected the User-Agent omitted, got %q", info.UserAgent)
+	}
+}
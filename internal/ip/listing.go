@@ -0,0 +1,32 @@
+package ip
+
+import "sync/atomic"
+
+// ListChecker reports the threat feeds an address is listed on
+type ListChecker interface {
+	Listed(ip string) []string
+}
+
+// listChecker holds the configured ListChecker; GetInfo skips list lookups while it is unset
+var listChecker atomic.Pointer[ListChecker]
+
+// SetListChecker enables threat feed lookups in GetInfo; a nil checker disables them
+func SetListChecker(checker ListChecker) {
+	if checker == nil {
+		listChecker.Store(nil)
+		return
+	}
+	listChecker.Store(&checker)
+}
+
+// listedOn returns whether ip is listed and on which feeds, or nil when no checker is configured
+func listedOn(ip string) (*bool, []string) {
+	checker := listChecker.Load()
+	if checker == nil {
+		return nil, nil
+	}
+
+	feeds := (*checker).Listed(ip)
+	listed := len(feeds) > 0
+	return &listed, feeds
+}
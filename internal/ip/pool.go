@@ -0,0 +1,23 @@
+package ip
+
+import (
+	"sync"
+
+	"myip/internal/models"
+)
+
+// infoPool recycles IPInfo structs between requests; see ReleaseInfo
+var infoPool = sync.Pool{
+	New: func() any { return new(models.IPInfo) },
+}
+
+// ReleaseInfo returns an IPInfo obtained from GetInfo to the pool once its response has been
+// written. The caller must not retain info, or anything referencing it, after the call.
+// Releasing is optional; unreleased structs are garbage collected as usual.
+func ReleaseInfo(info *models.IPInfo) {
+	if info == nil {
+		return
+	}
+	*info = models.IPInfo{}
+	infoPool.Put(info)
+}
@@ -0,0 +1,61 @@
+package ip
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestReleaseInfoResetsFields(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+	req.Header.Set("User-Agent", "first")
+
+	first := GetInfo(req)
+	first.Enrichment = map[string]any{"geo": "stale"}
+	ReleaseInfo(first)
+	ReleaseInfo(nil)
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "198.51.100.7:1234"
+	second := GetInfo(req)
+	defer ReleaseInfo(second)
+
+	if second.Enrichment != nil || second.UserAgent != "" || second.IsCloudflare {
+		t.Errorf("Expected a fresh IPInfo, got %+v", second)
+	}
+	if second.ClientIP != "198.51.100.7" {
+		t.Errorf("Expected ClientIP 198.51.100.7, got %s", second.ClientIP)
+	}
+}
+
+func TestReleaseInfoClearsEveryField(t *testing.T) {
+	info := GetInfo(httptest.NewRequest("GET", "/", nil))
+	ReleaseInfo(info)
+
+	// The struct is zeroed on release, so a pooled value never leaks a previous request's data
+	if !reflect.ValueOf(*info).IsZero() {
+		t.Errorf("Expected released IPInfo to be zeroed, got %+v", *info)
+	}
+}
+
+func BenchmarkGetInfo(b *testing.B) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.168.1.1:12345"
+	req.Header.Set("X-Forwarded-For", "203.0.113.1, 10.0.0.1, 192.168.1.1")
+	req.Header.Set("User-Agent", "curl/8.0")
+
+	b.Run("Released", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ReleaseInfo(GetInfo(req))
+		}
+	})
+
+	b.Run("Unreleased", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			GetInfo(req)
+		}
+	})
+}
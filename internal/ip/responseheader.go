@@ -0,0 +1,39 @@
+package ip
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultResponseHeader is the response header carrying the client IP unless configured otherwise
+const DefaultResponseHeader = "X-Client-IP"
+
+// ResponseHeaderMiddleware adds the client IP to every response in the Settings.ResponseHeader
+// header, so monitoring that only sends HEAD requests can read it without parsing a body
+func ResponseHeaderMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if name := CurrentSettings().ResponseHeader; name != "" {
+			if clientIP, _ := ExtractClientIP(r); clientIP != "" {
+				w.Header().Set(name, clientIP)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ValidHeaderName reports whether name is a valid HTTP header field name (RFC 9110)
+func ValidHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
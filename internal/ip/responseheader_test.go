@@ -0,0 +1,52 @@
+package ip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseHeaderMiddleware(t *testing.T) {
+	defer Configure(Settings{})
+
+	handler := ResponseHeaderMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("HEAD", "/health", nil)
+		req.Header.Set("X-Forwarded-For", "198.51.100.1")
+		req.RemoteAddr = "10.0.0.1:1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if got := serve().Header().Get(DefaultResponseHeader); got != "" {
+		t.Errorf("Expected no client IP header while unset, got %q", got)
+	}
+
+	Configure(Settings{ResponseHeader: "X-Seen-IP"})
+	rr := serve()
+	if got := rr.Header().Get("X-Seen-IP"); got != "198.51.100.1" {
+		t.Errorf("Expected X-Seen-IP 198.51.100.1, got %q", got)
+	}
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected the handler's status, got %d", rr.Code)
+	}
+}
+
+func TestValidHeaderName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"X-Client-IP":   true,
+		"x_seen.ip~1":   true,
+		"":              false,
+		"X Client IP":   false,
+		"X-Client-IP:":  false,
+		"X-Clïent-IP":   false,
+		"X-Client-IP\n": false,
+	} {
+		if got := ValidHeaderName(name); got != want {
+			t.Errorf("ValidHeaderName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
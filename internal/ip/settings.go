@@ -0,0 +1,106 @@
+package ip
+
+import (
+	"net"
+	"net/netip"
+	"sync/atomic"
+
+	"github.com/akhfa/myip/ipdetect"
+)
+
+// Settings holds the runtime-tunable detection settings
+type Settings struct {
+	// HeaderPriority lists the headers consulted for the client IP, highest priority first
+	HeaderPriority []string
+	// TrustedProxies restricts header-based detection to requests whose peer address falls
+	// in one of these ranges, or that arrived over a Unix domain socket. When empty, headers
+	// are trusted from any peer.
+	TrustedProxies []*net.IPNet
+	// Strategy selects the address taken from multi-address headers such as X-Forwarded-For
+	Strategy ipdetect.Strategy
+	// StrictValidation controls how requests failing Inconsistency are handled: StrictWarn
+	// reports the problem in IPInfo.Warning and StrictReject refuses the request. Empty or
+	// StrictOff disables the check.
+	StrictValidation string
+	// NAT64Prefixes are the network's own NAT64 prefixes, recognized by NAT64 and Tunnel along
+	// with DefaultNAT64Prefixes
+	NAT64Prefixes []netip.Prefix
+	// ResponseHeader names the response header ResponseHeaderMiddleware sets to the client IP;
+	// empty disables it
+	ResponseHeader string
+	// Shadow, when set, is a candidate detection configuration run alongside this one on the
+	// detection endpoints. Its result never reaches responses; disagreements are counted and
+	// logged, see ShadowMiddleware.
+	Shadow *ShadowSettings
+}
+
+// ShadowSettings is a candidate detection configuration evaluated by ShadowMiddleware. It shares
+// TrustedProxies with the settings in effect; an empty header priority uses theirs as well.
+type ShadowSettings struct {
+	HeaderPriority []string
+	Strategy       ipdetect.Strategy
+}
+
+// state pairs the settings with the detector built from them, so both are swapped at once
+type state struct {
+	settings Settings
+	detector *ipdetect.Detector
+	// shadow is built from settings.Shadow, or nil without one
+	shadow *ipdetect.Detector
+}
+
+var current atomic.Pointer[state]
+
+func init() {
+	Configure(Settings{})
+}
+
+// Configure replaces the detection settings. It is safe to call while requests are being served.
+// An empty header priority falls back to DefaultHeaderPriority.
+func Configure(settings Settings) {
+	if len(settings.HeaderPriority) == 0 {
+		settings.HeaderPriority = DefaultHeaderPriority
+	}
+	next := &state{
+		settings: settings,
+		detector: ipdetect.New(ipdetect.Options{
+			HeaderPriority: settings.HeaderPriority,
+			TrustedProxies: settings.TrustedProxies,
+			Strategy:       settings.Strategy,
+			Debugf:         logger.Debugf,
+		}),
+	}
+	if shadow := settings.Shadow; shadow != nil {
+		headerPriority := shadow.HeaderPriority
+		if len(headerPriority) == 0 {
+			headerPriority = settings.HeaderPriority
+		}
+		next.shadow = ipdetect.New(ipdetect.Options{
+			HeaderPriority: headerPriority,
+			TrustedProxies: settings.TrustedProxies,
+			Strategy:       shadow.Strategy,
+		})
+	}
+	current.Store(next)
+}
+
+// CurrentSettings returns the detection settings in effect
+func CurrentSettings() Settings {
+	return current.Load().settings
+}
+
+// detector returns the detector for the settings in effect
+func detector() *ipdetect.Detector {
+	return current.Load().detector
+}
+
+// ParseCIDRs parses a list of CIDR ranges; bare addresses are treated as single-host ranges
+func ParseCIDRs(list []string) ([]*net.IPNet, error) {
+	return ipdetect.ParseCIDRs(list)
+}
+
+// ParseStrategy parses an X-Forwarded-For strategy name: leftmost, rightmost, or
+// rightmost-untrusted
+func ParseStrategy(name string) (ipdetect.Strategy, error) {
+	return ipdetect.ParseStrategy(name)
+}
@@ -0,0 +1,108 @@
+package ip
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseCIDRs(t *testing.T) {
+	networks, err := ParseCIDRs([]string{"10.0.0.0/8", " 203.0.113.5 ", "2001:db8::1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"10.0.0.0/8", "203.0.113.5/32", "2001:db8::1/128"}
+	if len(networks) != len(expected) {
+		t.Fatalf("Expected %d networks, got %d", len(expected), len(networks))
+	}
+	for i, network := range networks {
+		if network.String() != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], network.String())
+		}
+	}
+
+	for _, invalid := range []string{"not-an-ip", "10.0.0.0/33"} {
+		if _, err := ParseCIDRs([]string{invalid}); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestConfigureHeaderPriority(t *testing.T) {
+	defer Configure(Settings{})
+
+	Configure(Settings{HeaderPriority: []string{"X-Custom-IP"}})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+	req.Header.Set("X-Custom-IP", "203.0.113.2")
+	req.RemoteAddr = "192.168.1.1:12345"
+
+	clientIP, method := ExtractClientIP(req)
+	if clientIP != "203.0.113.2" || method != "X-Custom-IP" {
+		t.Errorf("Expected 203.0.113.2 via X-Custom-IP, got %s via %s", clientIP, method)
+	}
+
+	// Resetting restores the default priority
+	Configure(Settings{})
+	if clientIP, _ := ExtractClientIP(req); clientIP != "203.0.113.1" {
+		t.Errorf("Expected default priority to prefer CF-Connecting-IP, got %s", clientIP)
+	}
+}
+
+func TestConfigureTrustedProxies(t *testing.T) {
+	defer Configure(Settings{})
+
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Configure(Settings{TrustedProxies: trusted})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		expectedIP string
+		expectedV4 string
+	}{
+		{"Trusted proxy", "10.1.2.3:12345", "203.0.113.1", "203.0.113.1"},
+		{"Untrusted peer", "198.51.100.7:12345", "198.51.100.7", "198.51.100.7"},
+		{"Unix socket peer", "@", "203.0.113.1", "203.0.113.1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("X-Forwarded-For", "203.0.113.1")
+			req.RemoteAddr = test.remoteAddr
+
+			if clientIP, _ := ExtractClientIP(req); clientIP != test.expectedIP {
+				t.Errorf("Expected client IP %s, got %s", test.expectedIP, clientIP)
+			}
+			if ipv4 := FindIPv4(req); ipv4 != test.expectedV4 {
+				t.Errorf("Expected IPv4 %s, got %s", test.expectedV4, ipv4)
+			}
+		})
+	}
+}
+
+func TestConfigureStrategy(t *testing.T) {
+	defer Configure(Settings{})
+
+	strategy, err := ParseStrategy("rightmost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Configure(Settings{Strategy: strategy})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", "192.0.2.66, 203.0.113.1")
+	req.RemoteAddr = "10.0.0.1:12345"
+	if clientIP, _ := ExtractClientIP(req); clientIP != "203.0.113.1" {
+		t.Errorf("Expected the rightmost address, got %s", clientIP)
+	}
+
+	if _, err := ParseStrategy("random"); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+}
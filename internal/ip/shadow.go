@@ -0,0 +1,58 @@
+package ip
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"myip/internal/logging"
+	"myip/internal/privacy"
+)
+
+var shadowLogger = logging.For("shadow")
+
+// shadowMatches and shadowMismatches count the requests on which the shadow detector agreed and
+// disagreed with the detector in effect
+var shadowMatches, shadowMismatches atomic.Int64
+
+// ShadowMiddleware runs the Settings.Shadow detector alongside the one in effect and records
+// whether they agree on the client IP. Responses are unaffected; mismatches are logged by the
+// "shadow" debug module and counted in WriteShadowMetrics.
+func ShadowMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s := current.Load(); s.shadow != nil {
+			compareShadow(s, r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// compareShadow counts and logs whether the shadow detector of s agrees with its detector on r
+func compareShadow(s *state, r *http.Request) {
+	clientIP, detectedVia := s.detector.ClientIP(r)
+	shadowIP, shadowVia := s.shadow.ClientIP(r)
+	if clientIP == shadowIP {
+		shadowMatches.Add(1)
+		return
+	}
+	shadowMismatches.Add(1)
+	shadowLogger.Debugf("Shadow detection mismatch: current %s via %s, shadow %s via %s",
+		privacy.IP(clientIP), detectedVia, privacy.IP(shadowIP), shadowVia)
+}
+
+// ShadowCounts returns the requests on which shadow detection agreed and disagreed with the
+// detection in effect
+func ShadowCounts() (matches, mismatches int64) {
+	return shadowMatches.Load(), shadowMismatches.Load()
+}
+
+// WriteShadowMetrics writes the shadow detection counters in the Prometheus text exposition format
+func WriteShadowMetrics(w io.Writer) error {
+	matches, mismatches := ShadowCounts()
+	_, err := fmt.Fprintf(w, "# HELP myip_shadow_detections_total Requests checked against the shadow detection settings, by whether the client IP agreed.\n"+
+		"# TYPE myip_shadow_detections_total counter\n"+
+		"myip_shadow_detections_total{result=\"match\"} %d\n"+
+		"myip_shadow_detections_total{result=\"mismatch\"} %d\n", matches, mismatches)
+	return err
+}
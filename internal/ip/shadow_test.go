@@ -0,0 +1,82 @@
+package ip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akhfa/myip/ipdetect"
+)
+
+func TestShadowMiddleware(t *testing.T) {
+	defer Configure(Settings{})
+
+	served := 0
+	handler := ShadowMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	}))
+	serve := func(xff string) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Forwarded-For", xff)
+		req.RemoteAddr = "10.0.0.1:1234"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Without shadow settings nothing is counted
+	matches, mismatches := ShadowCounts()
+	serve("198.51.100.1, 203.0.113.1")
+	if m, mm := ShadowCounts(); m != matches || mm != mismatches {
+		t.Errorf("Expected no shadow counts without shadow settings, got %d and %d", m-matches, mm-mismatches)
+	}
+
+	Configure(Settings{Shadow: &ShadowSettings{Strategy: ipdetect.Rightmost}})
+	serve("198.51.100.1")
+	serve("198.51.100.1, 203.0.113.1")
+	m, mm := ShadowCounts()
+	if m-matches != 1 || mm-mismatches != 1 {
+		t.Errorf("Expected one match and one mismatch, got %d and %d", m-matches, mm-mismatches)
+	}
+	if served != 3 {
+		t.Errorf("Expected every request served, got %d", served)
+	}
+
+	// The shadow result never replaces the detection in effect
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.1")
+	req.RemoteAddr = "10.0.0.1:1234"
+	if clientIP, _ := ExtractClientIP(req); clientIP != "198.51.100.1" {
+		t.Errorf("Expected the leftmost address in effect, got %s", clientIP)
+	}
+}
+
+func TestShadowHeaderPriority(t *testing.T) {
+	defer Configure(Settings{})
+
+	Configure(Settings{
+		HeaderPriority: []string{"X-Forwarded-For"},
+		Shadow:         &ShadowSettings{HeaderPriority: []string{"X-Real-IP"}},
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	req.Header.Set("X-Real-IP", "203.0.113.1")
+	req.RemoteAddr = "10.0.0.1:1234"
+
+	_, mismatches := ShadowCounts()
+	ShadowMiddleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+	if _, mm := ShadowCounts(); mm-mismatches != 1 {
+		t.Errorf("Expected the shadow header priority to disagree, got %d mismatches", mm-mismatches)
+	}
+}
+
+func TestWriteShadowMetrics(t *testing.T) {
+	var b strings.Builder
+	if err := WriteShadowMetrics(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# TYPE myip_shadow_detections_total counter", `myip_shadow_detections_total{result="match"}`, `myip_shadow_detections_total{result="mismatch"}`} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Expected %q in the metrics, got:\n%s", want, b.String())
+		}
+	}
+}
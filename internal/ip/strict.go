@@ -0,0 +1,46 @@
+package ip
+
+import "net/http"
+
+// Strict validation modes, see Settings.StrictValidation
+const (
+	StrictOff    = "off"
+	StrictWarn   = "warn"
+	StrictReject = "reject"
+)
+
+// Inconsistency describes why the client IP taken from a proxy header cannot be right, or returns
+// "" when it is plausible; see ipdetect.Detector.Inconsistency
+func Inconsistency(r *http.Request) string {
+	return detector().Inconsistency(r)
+}
+
+// SpoofingReasons lists the ways the request's proxy headers contradict its peer address or each
+// other; see ipdetect.Detector.Spoofing
+func SpoofingReasons(r *http.Request) []string {
+	return detector().Spoofing(r)
+}
+
+// strictWarning returns the Inconsistency of r when strict validation is enabled
+func strictWarning(r *http.Request) string {
+	switch CurrentSettings().StrictValidation {
+	case StrictWarn, StrictReject:
+		return Inconsistency(r)
+	}
+	return ""
+}
+
+// StrictMiddleware rejects requests failing Inconsistency with 400 Bad Request while strict
+// validation is set to StrictReject
+func StrictMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if CurrentSettings().StrictValidation == StrictReject {
+			if reason := Inconsistency(r); reason != "" {
+				logger.Debugf("Rejecting inconsistent request: %s", reason)
+				http.Error(w, "Inconsistent client address: "+reason, http.StatusBadRequest)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
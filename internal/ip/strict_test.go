@@ -0,0 +1,119 @@
+package ip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInconsistency(t *testing.T) {
+	tests := []struct {
+		name         string
+		header       string
+		remoteAddr   string
+		inconsistent bool
+	}{
+		{"Direct connection", "", "203.0.113.9:1234", false},
+		{"Public client behind public proxy", "198.51.100.1", "203.0.113.9:1234", false},
+		{"Public client behind private proxy", "198.51.100.1", "10.0.0.2:1234", false},
+		{"Private client behind private proxy", "192.168.1.5", "10.0.0.2:1234", false},
+		{"Private client behind public peer", "192.168.1.5", "203.0.113.9:1234", true},
+		{"Loopback client behind public peer", "127.0.0.1", "203.0.113.9:1234", true},
+		{"CGNAT client behind public peer", "100.64.1.1", "203.0.113.9:1234", true},
+		{"ULA client behind public peer", "fd00::1", "[2001:db8::9]:1234", true},
+		{"Unspecified client", "0.0.0.0", "10.0.0.2:1234", true},
+		{"Multicast client", "224.0.0.1", "10.0.0.2:1234", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if test.header != "" {
+				req.Header.Set("X-Forwarded-For", test.header)
+			}
+			req.RemoteAddr = test.remoteAddr
+
+			reason := Inconsistency(req)
+			if (reason != "") != test.inconsistent {
+				t.Errorf("Expected inconsistent=%t, got reason %q", test.inconsistent, reason)
+			}
+			if test.inconsistent && !strings.Contains(reason, "X-Forwarded-For") {
+				t.Errorf("Expected reason to name the header, got %q", reason)
+			}
+		})
+	}
+}
+
+func TestGetInfoStrictWarning(t *testing.T) {
+	defer Configure(Settings{})
+
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.Header.Set("X-Forwarded-For", "192.168.1.5")
+	req.RemoteAddr = "203.0.113.9:1234"
+
+	if info := GetInfo(req); info.Warning != "" {
+		t.Errorf("Expected no warning with strict validation off, got %q", info.Warning)
+	}
+
+	Configure(Settings{StrictValidation: StrictWarn})
+	if info := GetInfo(req); info.Warning == "" {
+		t.Error("Expected a warning with strict validation set to warn")
+	}
+}
+
+func TestGetInfoSpoofing(t *testing.T) {
+	defer Configure(Settings{})
+
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Configure(Settings{TrustedProxies: trusted})
+
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.9")
+	req.RemoteAddr = "10.0.0.1:1234"
+	if info := GetInfo(req); info.SpoofingSuspected || info.SpoofingReasons != nil {
+		t.Errorf("Expected no spoofing through a trusted proxy, got %q", info.SpoofingReasons)
+	}
+
+	// Cloudflare never connects from a private address
+	req.RemoteAddr = "192.168.1.5:1234"
+	info := GetInfo(req)
+	if !info.SpoofingSuspected || len(info.SpoofingReasons) != 1 || !strings.Contains(info.SpoofingReasons[0], "CF-Connecting-IP sent by 192.168.1.5") {
+		t.Errorf("Expected spoofed Cloudflare headers to be reported, got %t %q", info.SpoofingSuspected, info.SpoofingReasons)
+	}
+}
+
+func TestStrictMiddleware(t *testing.T) {
+	defer Configure(Settings{})
+
+	handler := StrictMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(forwarded string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Forwarded-For", forwarded)
+		req.RemoteAddr = "203.0.113.9:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, mode := range []string{"", StrictOff, StrictWarn} {
+		Configure(Settings{StrictValidation: mode})
+		if code := serve("192.168.1.5"); code != http.StatusOK {
+			t.Errorf("Mode %q: expected 200, got %d", mode, code)
+		}
+	}
+
+	Configure(Settings{StrictValidation: StrictReject})
+	if code := serve("192.168.1.5"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an inconsistent request, got %d", code)
+	}
+	if code := serve("198.51.100.1"); code != http.StatusOK {
+		t.Errorf("Expected 200 for a consistent request, got %d", code)
+	}
+}
@@ -0,0 +1,121 @@
+package ip
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// IPv6 transition mechanisms reported in IPInfo.TunnelType
+const (
+	// Tunnel6to4 addresses embed the IPv4 address of the site's relay router in 2002::/16 (RFC 3056)
+	Tunnel6to4 = "6to4"
+	// TunnelTeredo addresses embed the client's public IPv4 address, obfuscated, in 2001::/32
+	// (RFC 4380)
+	TunnelTeredo = "teredo"
+	// TunnelISATAP addresses embed the host's IPv4 address in an interface identifier starting
+	// 0000:5efe or 0200:5efe (RFC 5214)
+	TunnelISATAP = "isatap"
+	// TunnelNAT64 addresses embed the IPv4 address an IPv6-only client reached through a NAT64
+	// gateway, as in 464XLAT (RFC 6052)
+	TunnelNAT64 = "nat64"
+)
+
+// DefaultNAT64Prefixes are the well-known NAT64 prefix (RFC 6052) and the local-use prefix
+// (RFC 8215), recognized in addition to Settings.NAT64Prefixes
+var DefaultNAT64Prefixes = []netip.Prefix{
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+var (
+	prefix6to4   = netip.MustParsePrefix("2002::/16")
+	prefixTeredo = netip.MustParsePrefix("2001::/32")
+)
+
+// Tunnel reports the transition mechanism addr reaches the IPv6 Internet through and the IPv4
+// address it embeds, or "" and the zero Addr for native IPv6
+func Tunnel(addr netip.Addr) (string, netip.Addr) {
+	if !addr.Is6() || addr.Is4In6() {
+		return "", netip.Addr{}
+	}
+	if _, ipv4, ok := NAT64(addr); ok {
+		return TunnelNAT64, ipv4
+	}
+	b := addr.As16()
+	switch {
+	case prefix6to4.Contains(addr):
+		return Tunnel6to4, netip.AddrFrom4([4]byte(b[2:6]))
+	case prefixTeredo.Contains(addr):
+		return TunnelTeredo, netip.AddrFrom4([4]byte{^b[12], ^b[13], ^b[14], ^b[15]})
+	case b[8]&^0x02 == 0 && b[9] == 0 && b[10] == 0x5e && b[11] == 0xfe:
+		return TunnelISATAP, netip.AddrFrom4([4]byte(b[12:16]))
+	}
+	return "", netip.Addr{}
+}
+
+// NAT64Prefixes returns the NAT64 prefixes recognized: Settings.NAT64Prefixes, then
+// DefaultNAT64Prefixes
+func NAT64Prefixes() []netip.Prefix {
+	return append(slices.Clone(CurrentSettings().NAT64Prefixes), DefaultNAT64Prefixes...)
+}
+
+// NAT64 reports whether addr is in one of NAT64Prefixes, returning the prefix and the IPv4
+// address embedded after it
+func NAT64(addr netip.Addr) (netip.Prefix, netip.Addr, bool) {
+	for _, prefixes := range [][]netip.Prefix{CurrentSettings().NAT64Prefixes, DefaultNAT64Prefixes} {
+		for _, prefix := range prefixes {
+			if prefix.Contains(addr) {
+				return prefix, embeddedIPv4(addr, prefix.Bits()), true
+			}
+		}
+	}
+	return netip.Prefix{}, netip.Addr{}, false
+}
+
+// embeddedIPv4 extracts the IPv4 address following a NAT64 prefix of the given length, skipping
+// bits 64 to 71, which RFC 6052 reserves
+func embeddedIPv4(addr netip.Addr, bits int) netip.Addr {
+	b := addr.As16()
+	var ipv4 [4]byte
+	i := bits / 8
+	for n := range ipv4 {
+		if i == 8 {
+			i++
+		}
+		ipv4[n] = b[i]
+		i++
+	}
+	return netip.AddrFrom4(ipv4)
+}
+
+// ParseNAT64Prefixes parses NAT64 prefixes, which must be IPv6 prefixes of a length RFC 6052
+// allows: 32, 40, 48, 56, 64, or 96
+func ParseNAT64Prefixes(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, entry := range list {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(entry))
+		if err != nil {
+			return nil, err
+		}
+		if !prefix.Addr().Is6() || prefix.Addr().Is4In6() || !slices.Contains([]int{32, 40, 48, 56, 64, 96}, prefix.Bits()) {
+			return nil, fmt.Errorf("%s is not an IPv6 prefix of length 32, 40, 48, 56, 64, or 96", entry)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// tunnelOf returns Tunnel for the address ipv6, as strings for IPInfo
+func tunnelOf(ipv6 string) (tunnelType, embeddedIPv4 string) {
+	addr, err := netip.ParseAddr(ipv6)
+	if err != nil {
+		return "", ""
+	}
+	tunnelType, ipv4 := Tunnel(addr.WithZone(""))
+	if tunnelType == "" {
+		return "", ""
+	}
+	return tunnelType, ipv4.String()
+}
@@ -0,0 +1,110 @@
+package ip
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestTunnel(t *testing.T) {
+	tests := []struct {
+		addr       string
+		tunnelType string
+		ipv4       string
+	}{
+		{"2002:c000:201::1", Tunnel6to4, "192.0.2.1"},
+		{"2002:cb00:7101:1:ba27:ebff:fe12:3456", Tunnel6to4, "203.0.113.1"},
+		{"2001:0:4136:e378:8000:63bf:3fff:fdd2", TunnelTeredo, "192.0.2.45"},
+		{"2001:db8::200:5efe:c000:201", TunnelISATAP, "192.0.2.1"},
+		{"fe80::5efe:a00:1", TunnelISATAP, "10.0.0.1"},
+		{"2001:db8::1", "", ""},
+		{"2001:1::1", "", ""},
+		{"::ffff:192.0.2.1", "", ""},
+		{"192.0.2.1", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.addr, func(t *testing.T) {
+			tunnelType, ipv4 := Tunnel(netip.MustParseAddr(test.addr))
+			got := ""
+			if ipv4.IsValid() {
+				got = ipv4.String()
+			}
+			if tunnelType != test.tunnelType || got != test.ipv4 {
+				t.Errorf("Expected %q with %q, got %q with %q", test.tunnelType, test.ipv4, tunnelType, got)
+			}
+		})
+	}
+}
+
+func TestGetInfoTunnel(t *testing.T) {
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.RemoteAddr = "[2001:0:4136:e378:8000:63bf:3fff:fdd2]:1234"
+	info := GetInfo(req)
+	defer ReleaseInfo(info)
+	if info.TunnelType != TunnelTeredo || info.EmbeddedIPv4 != "192.0.2.45" {
+		t.Errorf("Expected a Teredo tunnel from 192.0.2.45, got %q and %q", info.TunnelType, info.EmbeddedIPv4)
+	}
+
+	req.RemoteAddr = "[2001:db8::1]:1234"
+	native := GetInfo(req)
+	defer ReleaseInfo(native)
+	if native.TunnelType != "" || native.EmbeddedIPv4 != "" {
+		t.Errorf("Expected no tunnel for native IPv6, got %q and %q", native.TunnelType, native.EmbeddedIPv4)
+	}
+}
+
+func TestNAT64(t *testing.T) {
+	defer Configure(Settings{})
+	custom, err := ParseNAT64Prefixes([]string{"2001:db8:122:344::/64", "2001:db8:100::/40"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Configure(Settings{NAT64Prefixes: custom})
+
+	tests := []struct {
+		addr   string
+		prefix string
+		ipv4   string
+	}{
+		{"64:ff9b::c000:221", "64:ff9b::/96", "192.0.2.33"},
+		{"64:ff9b::192.0.2.33", "64:ff9b::/96", "192.0.2.33"},
+		{"64:ff9b:1:c000:2:2100::", "64:ff9b:1::/48", "192.0.2.33"},
+		// RFC 6052 examples, with bits 64 to 71 skipped
+		{"2001:db8:1c0:2:21::", "2001:db8:100::/40", "192.0.2.33"},
+		{"2001:db8:122:344:c0:2:2100:0", "2001:db8:122:344::/64", "192.0.2.33"},
+		{"2001:db8::1", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.addr, func(t *testing.T) {
+			prefix, ipv4, ok := NAT64(netip.MustParseAddr(test.addr))
+			if ok != (test.prefix != "") {
+				t.Fatalf("Expected NAT64 %t, got %t", test.prefix != "", ok)
+			}
+			if ok && (prefix.String() != test.prefix || ipv4.String() != test.ipv4) {
+				t.Errorf("Expected %s in %s, got %s in %s", test.ipv4, test.prefix, ipv4, prefix)
+			}
+		})
+	}
+
+	if tunnelType, ipv4 := Tunnel(netip.MustParseAddr("64:ff9b::c000:221")); tunnelType != TunnelNAT64 || ipv4.String() != "192.0.2.33" {
+		t.Errorf("Expected a NAT64 tunnel from 192.0.2.33, got %q with %s", tunnelType, ipv4)
+	}
+	if got := len(NAT64Prefixes()); got != len(custom)+len(DefaultNAT64Prefixes) {
+		t.Errorf("Expected the configured and well-known prefixes, got %d", got)
+	}
+}
+
+func TestParseNAT64Prefixes(t *testing.T) {
+	for _, valid := range []string{"2001:db8::/32", "2001:db8:1::/48", "2001:db8:1:2::/64", "2001:db8::/96"} {
+		if _, err := ParseNAT64Prefixes([]string{valid}); err != nil {
+			t.Errorf("Expected %s to be accepted, got %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{"2001:db8::/80", "192.0.2.0/24", "::ffff:0:0/96", "2001:db8::1"} {
+		if _, err := ParseNAT64Prefixes([]string{invalid}); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
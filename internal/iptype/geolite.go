@@ -0,0 +1,305 @@
+package iptype
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"myip/internal/breaker"
+	"myip/internal/logging"
+)
+
+// GeoLite2 CSV editions downloaded by the updater
+const (
+	editionASN     = "GeoLite2-ASN-CSV"
+	editionCountry = "GeoLite2-Country-CSV"
+)
+
+// maxEditionBytes bounds the size of a downloaded edition
+const maxEditionBytes = 256 << 20
+
+// Updater keeps a Classifier's ASN ranges current with MaxMind's GeoLite2 ASN and country CSV
+// editions, downloaded with a MaxMind account ID and license key
+type Updater struct {
+	classifier *Classifier
+	httpClient *http.Client
+	baseURL    string
+	accountID  string
+	licenseKey string
+
+	// mu serializes updates; checksums are those of the editions in use
+	mu        sync.Mutex
+	checksums map[string]string
+}
+
+// NewUpdater creates an updater downloading editions from baseURL (MaxMind's
+// https://download.maxmind.com/geoip/databases/ or a mirror) through httpClient into c
+func NewUpdater(c *Classifier, httpClient *http.Client, baseURL, accountID, licenseKey string) *Updater {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return &Updater{
+		classifier: c,
+		httpClient: httpClient,
+		baseURL:    baseURL,
+		accountID:  accountID,
+		licenseKey: licenseKey,
+	}
+}
+
+// Update downloads the editions when MaxMind publishes new ones, verifies them against their
+// SHA-256 checksums, and swaps the parsed ranges into the classifier. Lookups in progress keep
+// the ranges they started with; on any failure the ranges in use are kept.
+func (u *Updater) Update(ctx context.Context) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	checksums := make(map[string]string, 2)
+	current := true
+	for _, edition := range []string{editionASN, editionCountry} {
+		sum, err := u.checksum(ctx, edition)
+		if err != nil {
+			return err
+		}
+		checksums[edition] = sum
+		current = current && u.checksums[edition] == sum
+	}
+	if current {
+		logger.Debugf("GeoLite2 editions unchanged")
+		return nil
+	}
+
+	archives := make(map[string][]byte, 2)
+	for edition, sum := range checksums {
+		data, err := u.get(ctx, edition, "zip")
+		if err != nil {
+			return err
+		}
+		actual := sha256.Sum256(data)
+		if hex.EncodeToString(actual[:]) != sum {
+			return fmt.Errorf("%s: checksum mismatch", edition)
+		}
+		archives[edition] = data
+	}
+
+	ranges, err := parseGeoLite(archives[editionASN], archives[editionCountry])
+	if err != nil {
+		return err
+	}
+	u.classifier.ranges.Store(&ranges)
+	u.checksums = checksums
+	logging.Infof("Loaded %d ASN ranges from GeoLite2", len(ranges))
+	return nil
+}
+
+// checksum returns the published SHA-256 checksum of edition's zip archive
+func (u *Updater) checksum(ctx context.Context, edition string) (string, error) {
+	data, err := u.get(ctx, edition, "zip.sha256")
+	if err != nil {
+		return "", err
+	}
+	// The checksum file reads "<sha256>  <file name>"
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 || len(fields[0]) != sha256.Size*2 {
+		return "", fmt.Errorf("%s: invalid checksum file", edition)
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// get downloads edition with the given suffix
+func (u *Updater) get(ctx context.Context, edition, suffix string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.baseURL+edition+"/download?suffix="+suffix, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(u.accountID, u.licenseKey)
+
+	var data []byte
+	err = breaker.Get("feed:" + req.URL.Host).Do(func() error {
+		resp, err := u.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s download returned %s", edition, resp.Status)
+		}
+		data, err = io.ReadAll(io.LimitReader(resp.Body, maxEditionBytes))
+		return err
+	})
+	return data, err
+}
+
+// parseGeoLite builds sorted ASN ranges from the ASN edition archive, with the country of each
+// range taken from the country edition archive
+func parseGeoLite(asnArchive, countryArchive []byte) ([]asnRange, error) {
+	countries, err := parseCountryBlocks(countryArchive)
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges []asnRange
+	err = readBlocks(asnArchive, "GeoLite2-ASN-Blocks", func(prefix netip.Prefix, row map[string]string) error {
+		asn, err := strconv.ParseUint(row["autonomous_system_number"], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid ASN %q", row["autonomous_system_number"])
+		}
+		start := prefix.Addr()
+		ranges = append(ranges, asnRange{
+			start:   start,
+			end:     lastAddr(prefix),
+			asn:     uint32(asn),
+			country: countries.lookup(start),
+			name:    row["autonomous_system_organization"],
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(ranges) == 0 {
+		return nil, errors.New("GeoLite2 ASN edition lists no networks")
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start.Less(ranges[j].start) })
+	return ranges, nil
+}
+
+// countryRange maps an address range to a country code
+type countryRange struct {
+	start, end netip.Addr
+	country    string
+}
+
+// countryRanges are sorted by start address
+type countryRanges []countryRange
+
+// lookup returns the country code of addr, or "" when no range holds it
+func (c countryRanges) lookup(addr netip.Addr) string {
+	i := sort.Search(len(c), func(i int) bool { return c[i].end.Compare(addr) >= 0 })
+	if i == len(c) || c[i].start.Compare(addr) > 0 {
+		return ""
+	}
+	return c[i].country
+}
+
+// parseCountryBlocks reads the country of each network in the country edition archive. Networks
+// without a located country fall back to the country they are registered in.
+func parseCountryBlocks(archive []byte) (countryRanges, error) {
+	codes := make(map[string]string)
+	err := readCSV(archive, "GeoLite2-Country-Locations-en.csv", func(row map[string]string) error {
+		codes[row["geoname_id"]] = row["country_iso_code"]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges countryRanges
+	err = readBlocks(archive, "GeoLite2-Country-Blocks", func(prefix netip.Prefix, row map[string]string) error {
+		country := codes[row["geoname_id"]]
+		if country == "" {
+			country = codes[row["registered_country_geoname_id"]]
+		}
+		if country != "" {
+			ranges = append(ranges, countryRange{start: prefix.Addr(), end: lastAddr(prefix), country: country})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start.Less(ranges[j].start) })
+	return ranges, nil
+}
+
+// readBlocks calls fn with the network and columns of each row of the IPv4 and IPv6 block files
+// named prefix in archive
+func readBlocks(archive []byte, prefix string, fn func(network netip.Prefix, row map[string]string) error) error {
+	for _, family := range []string{"-IPv4.csv", "-IPv6.csv"} {
+		err := readCSV(archive, prefix+family, func(row map[string]string) error {
+			network, err := netip.ParsePrefix(row["network"])
+			if err != nil {
+				return err
+			}
+			return fn(network.Masked(), row)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCSV calls fn with the columns, keyed by the header row, of each row of the file name in
+// the zip archive. Editions keep their files in a dated directory, so only base names are matched.
+func readCSV(archive []byte, name string, fn func(row map[string]string) error) error {
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+		if path.Base(f.Name) != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		r := csv.NewReader(rc)
+		r.ReuseRecord = true
+		header, err := r.Read()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		header = append([]string(nil), header...)
+		row := make(map[string]string, len(header))
+		for line := 2; ; line++ {
+			record, err := r.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			for i, column := range header {
+				row[column] = ""
+				if i < len(record) {
+					row[column] = record[i]
+				}
+			}
+			if err := fn(row); err != nil {
+				return fmt.Errorf("%s line %d: %w", name, line, err)
+			}
+		}
+	}
+	return fmt.Errorf("%s missing from archive", name)
+}
+
+// lastAddr returns the last address of prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(b)*8; bit++ {
+		b[bit/8] |= 0x80 >> (bit % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
@@ -0,0 +1,192 @@
+package iptype
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// zipEdition builds an edition archive holding files in a dated directory
+func zipEdition(t *testing.T, edition string, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(edition + "_20240102/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// geoLiteEditions returns the ASN and country editions listing asOrg for 203.0.113.0/24
+func geoLiteEditions(t *testing.T, asOrg string) map[string][]byte {
+	return map[string][]byte{
+		editionASN: zipEdition(t, editionASN, map[string]string{
+			"GeoLite2-ASN-Blocks-IPv4.csv": "network,autonomous_system_number,autonomous_system_organization\n" +
+				"203.0.113.0/24,64500," + asOrg + "\n" +
+				"198.51.100.0/24,64501,\"Example Broadband, Inc.\"\n",
+			"GeoLite2-ASN-Blocks-IPv6.csv": "network,autonomous_system_number,autonomous_system_organization\n" +
+				"2001:db8::/32,64502,EXAMPLE-MOBILE\n",
+		}),
+		editionCountry: zipEdition(t, editionCountry, map[string]string{
+			"GeoLite2-Country-Locations-en.csv": "geoname_id,locale_code,continent_code,continent_name,country_iso_code,country_name,is_in_european_union\n" +
+				"2750405,en,EU,Europe,NL,Netherlands,1\n" +
+				"2921044,en,EU,Europe,DE,Germany,1\n",
+			"GeoLite2-Country-Blocks-IPv4.csv": "network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider\n" +
+				"203.0.112.0/23,2750405,2750405,,0,0\n" +
+				"198.51.100.0/24,,2921044,,0,0\n",
+			"GeoLite2-Country-Blocks-IPv6.csv": "network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider\n",
+		}),
+	}
+}
+
+// newMaxMind starts a fake MaxMind download service serving *editions, counting archive downloads
+func newMaxMind(t *testing.T, editions *map[string][]byte, downloads *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if account, key, ok := r.BasicAuth(); !ok || account != "123456" || key != "license" {
+			http.Error(w, "invalid license key", http.StatusUnauthorized)
+			return
+		}
+		edition := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/geoip/databases/"), "/download")
+		data, ok := (*editions)[edition]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.URL.Query().Get("suffix") {
+		case "zip":
+			downloads.Add(1)
+			w.Write(data)
+		case "zip.sha256":
+			sum := sha256.Sum256(data)
+			w.Write([]byte(hex.EncodeToString(sum[:]) + "  " + edition + "_20240102.zip\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestUpdater(t *testing.T) {
+	editions := geoLiteEditions(t, "EXAMPLE-HOSTING-AS")
+	var downloads atomic.Int32
+	server := newMaxMind(t, &editions, &downloads)
+
+	c, err := New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := NewUpdater(c, server.Client(), server.URL+"/geoip/databases", "123456", "license")
+	if err := u.Update(context.Background()); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	tests := []struct {
+		ip      string
+		asn     uint32
+		country string
+		netType string
+	}{
+		{"203.0.113.7", 64500, "NL", TypeHosting},
+		{"198.51.100.1", 64501, "DE", TypeResidential},
+		{"2001:db8::1", 64502, "", TypeMobile},
+	}
+	for _, test := range tests {
+		asn, country, ok := c.Network(test.ip)
+		if !ok || asn != test.asn || country != test.country {
+			t.Errorf("Network(%s) = %d, %q, %v, expected %d, %q", test.ip, asn, country, ok, test.asn, test.country)
+		}
+		if got := c.Classify(test.ip); got != test.netType {
+			t.Errorf("Classify(%s) = %q, expected %q", test.ip, got, test.netType)
+		}
+	}
+
+	// Unchanged editions are not downloaded again
+	if err := u.Update(context.Background()); err != nil || downloads.Load() != 2 {
+		t.Errorf("Expected no downloads for unchanged editions, got %d, %v", downloads.Load(), err)
+	}
+
+	editions = geoLiteEditions(t, "EXAMPLE-VPN")
+	if err := u.Update(context.Background()); err != nil || downloads.Load() != 4 {
+		t.Fatalf("Expected new editions to be downloaded, got %d, %v", downloads.Load(), err)
+	}
+	if got := c.Classify("203.0.113.7"); got != TypeVPN {
+		t.Errorf("Expected the new edition in use, got %q", got)
+	}
+}
+
+func TestUpdaterKeepsRangesOnFailure(t *testing.T) {
+	editions := geoLiteEditions(t, "EXAMPLE-HOSTING-AS")
+	var downloads atomic.Int32
+	server := newMaxMind(t, &editions, &downloads)
+
+	c, err := New(writeFile(t, "ip2asn.tsv", sampleDB), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bad := NewUpdater(c, server.Client(), server.URL+"/geoip/databases/", "123456", "wrong")
+	if err := bad.Update(context.Background()); err == nil {
+		t.Error("Expected an invalid license key to fail the update")
+	}
+
+	// An archive that does not match its published checksum is rejected
+	u := NewUpdater(c, server.Client(), server.URL+"/geoip/databases/", "123456", "license")
+	u.httpClient = &http.Client{Transport: corruptArchives{server.Client().Transport}}
+	if err := u.Update(context.Background()); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+
+	if asn, _, ok := c.Network("1.0.0.1"); !ok || asn != 13335 {
+		t.Errorf("Expected the IP_ASN_DB ranges to be kept, got %d, %v", asn, ok)
+	}
+}
+
+// corruptArchives appends a byte to downloaded archives
+type corruptArchives struct {
+	next http.RoundTripper
+}
+
+func (c corruptArchives) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := c.next.RoundTrip(req)
+	if err != nil || req.URL.Query().Get("suffix") != "zip" {
+		return resp, err
+	}
+	var buf bytes.Buffer
+	buf.ReadFrom(resp.Body)
+	resp.Body.Close()
+	buf.WriteByte(0)
+	resp.Body = io.NopCloser(&buf)
+	return resp, nil
+}
+
+func TestLastAddr(t *testing.T) {
+	tests := map[string]string{
+		"203.0.113.0/24": "203.0.113.255",
+		"10.0.0.0/9":     "10.127.255.255",
+		"2001:db8::/32":  "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff",
+		"192.0.2.1/32":   "192.0.2.1",
+	}
+	for prefix, expected := range tests {
+		if got := lastAddr(netip.MustParsePrefix(prefix)); got.String() != expected {
+			t.Errorf("lastAddr(%s) = %s, expected %s", prefix, got, expected)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package iptype
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"myip/internal/logging"
+	"myip/internal/models"
+)
+
+// Network types reported in IPInfo.IPType
+const (
+	TypeResidential = "residential"
+	TypeMobile      = "mobile"
+	TypeHosting     = "hosting"
+	TypeVPN         = "vpn"
+)
+
+var logger = logging.For("iptype")
+
+//go:embed data/asns.txt
+var bundledASNs []byte
+
+//go:embed data/prefixes.txt
+var bundledPrefixes []byte
+
+// nameKeywords classify autonomous systems missing from the bundled list by their AS name, in order
+var nameKeywords = []struct {
+	keyword string
+	netType string
+}{
+	{"VPN", TypeVPN},
+	{"MOBILE", TypeMobile},
+	{"WIRELESS", TypeMobile},
+	{"CELLULAR", TypeMobile},
+	{"HOSTING", TypeHosting},
+	{"DATACENTER", TypeHosting},
+	{"DATA CENTER", TypeHosting},
+	{"CLOUD", TypeHosting},
+	{"SERVER", TypeHosting},
+	{"VPS", TypeHosting},
+	{"COLO", TypeHosting},
+}
+
+// typedPrefix is an address range with a known network type
+type typedPrefix struct {
+	prefix  netip.Prefix
+	netType string
+}
+
+// asnRange maps an address range to its autonomous system
+type asnRange struct {
+	start, end netip.Addr
+	asn        uint32
+	country    string
+	name       string
+}
+
+// Classifier classifies addresses as residential, mobile, hosting, or VPN
+type Classifier struct {
+	prefixes []typedPrefix
+	asnTypes map[uint32]string
+	datasets []models.BootDataset
+
+	// ranges is swapped whole when a GeoLite2 update replaces the ASN database
+	ranges atomic.Pointer[[]asnRange]
+}
+
+// New creates a Classifier from the bundled lists, the optional ip2asn database at asnDB
+// (tab-separated "range_start range_end AS_number country AS_description", optionally gzipped,
+// as published by iptoasn.com), and the optional prefix list at prefixFile. Without an ASN
+// database only the bundled and configured prefixes are classified.
+func New(asnDB, prefixFile string) (*Classifier, error) {
+	c := &Classifier{}
+
+	asnTypes, err := parseASNs(bundledASNs)
+	if err != nil {
+		return nil, fmt.Errorf("bundled ASN list: %w", err)
+	}
+	c.asnTypes = asnTypes
+
+	prefixes, err := parsePrefixes(bundledPrefixes)
+	if err != nil {
+		return nil, fmt.Errorf("bundled prefix list: %w", err)
+	}
+	c.prefixes = prefixes
+	c.addDataset("ip-type:bundled", append(append([]byte{}, bundledASNs...), bundledPrefixes...))
+
+	if prefixFile != "" {
+		data, err := os.ReadFile(prefixFile)
+		if err != nil {
+			return nil, err
+		}
+		custom, err := parsePrefixes(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", prefixFile, err)
+		}
+		// Configured prefixes are checked before the bundled ones
+		c.prefixes = append(custom, c.prefixes...)
+		c.addDataset("ip-type:prefixes", data)
+	}
+
+	if asnDB != "" {
+		data, err := readDatabase(asnDB)
+		if err != nil {
+			return nil, err
+		}
+		ranges, err := parseRanges(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", asnDB, err)
+		}
+		c.ranges.Store(&ranges)
+		c.addDataset("ip-type:asn-db", data)
+		logger.Debugf("Loaded %d ASN ranges from %s", len(ranges), asnDB)
+	}
+
+	return c, nil
+}
+
+// Classify returns the network type of ip, or "" when it cannot be determined
+func (c *Classifier) Classify(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	if addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+		return ""
+	}
+
+	for _, p := range c.prefixes {
+		if p.prefix.Contains(addr) {
+			return p.netType
+		}
+	}
+
+	r, ok := c.lookupASN(addr)
+	if !ok {
+		return ""
+	}
+	if netType, ok := c.asnTypes[r.asn]; ok {
+		return netType
+	}
+
+	name := strings.ToUpper(r.name)
+	for _, k := range nameKeywords {
+		if strings.Contains(name, k.keyword) {
+			return k.netType
+		}
+	}
+	return TypeResidential
+}
+
+// Network returns the autonomous system announcing ip and its registered country code, from the
+// ASN database; ok is false without a database or when ip is not routed
+func (c *Classifier) Network(ip string) (asn uint32, country string, ok bool) {
+	if c == nil {
+		return 0, "", false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return 0, "", false
+	}
+
+	r, ok := c.lookupASN(addr.Unmap())
+	if !ok {
+		return 0, "", false
+	}
+	return r.asn, r.country, true
+}
+
+// Datasets describes the loaded lists for the boot report
+func (c *Classifier) Datasets() []models.BootDataset {
+	if c == nil {
+		return nil
+	}
+	return c.datasets
+}
+
+// lookupASN finds the routed range containing addr
+func (c *Classifier) lookupASN(addr netip.Addr) (asnRange, bool) {
+	loaded := c.ranges.Load()
+	if loaded == nil {
+		return asnRange{}, false
+	}
+	ranges := *loaded
+	i := sort.Search(len(ranges), func(i int) bool {
+		return ranges[i].end.Compare(addr) >= 0
+	})
+	if i == len(ranges) || ranges[i].start.Compare(addr) > 0 || ranges[i].asn == 0 {
+		return asnRange{}, false
+	}
+	return ranges[i], true
+}
+
+// addDataset records a loaded list, versioned by its content hash
+func (c *Classifier) addDataset(name string, data []byte) {
+	sum := sha256.Sum256(data)
+	c.datasets = append(c.datasets, models.BootDataset{
+		Name:     name,
+		Version:  hex.EncodeToString(sum[:8]),
+		LoadedAt: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// readDatabase reads the ASN database, decompressing it when the file name ends in .gz
+func readDatabase(path string) ([]byte, error) {
+	if !strings.HasSuffix(path, ".gz") {
+		return os.ReadFile(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// lines calls fn with the fields of each non-empty line, stripping "#" comments
+func lines(data []byte, fn func(fields []string) error) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if err := fn(fields); err != nil {
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// parseType validates a network type name
+func parseType(name string) (string, error) {
+	switch name = strings.ToLower(name); name {
+	case TypeResidential, TypeMobile, TypeHosting, TypeVPN:
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown network type %q", name)
+}
+
+// parseASNs parses "AS<number> <type>" lines
+func parseASNs(data []byte) (map[uint32]string, error) {
+	types := make(map[uint32]string)
+	err := lines(data, func(fields []string) error {
+		if len(fields) != 2 {
+			return fmt.Errorf("expected \"AS<number> <type>\", got %q", strings.Join(fields, " "))
+		}
+		asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(fields[0]), "AS"), 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid ASN %q", fields[0])
+		}
+		netType, err := parseType(fields[1])
+		if err != nil {
+			return err
+		}
+		types[uint32(asn)] = netType
+		return nil
+	})
+	return types, err
+}
+
+// parsePrefixes parses "<CIDR> <type>" lines
+func parsePrefixes(data []byte) ([]typedPrefix, error) {
+	var prefixes []typedPrefix
+	err := lines(data, func(fields []string) error {
+		if len(fields) != 2 {
+			return fmt.Errorf("expected \"<CIDR> <type>\", got %q", strings.Join(fields, " "))
+		}
+		prefix, err := netip.ParsePrefix(fields[0])
+		if err != nil {
+			return err
+		}
+		netType, err := parseType(fields[1])
+		if err != nil {
+			return err
+		}
+		prefixes = append(prefixes, typedPrefix{prefix: prefix.Masked(), netType: netType})
+		return nil
+	})
+	return prefixes, err
+}
+
+// parseRanges parses the tab-separated ip2asn database, sorted by start address
+func parseRanges(data []byte) ([]asnRange, error) {
+	var ranges []asnRange
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 3 {
+			continue
+		}
+
+		start, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		end, err := netip.ParseAddr(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		asn, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid ASN %q", lineNo, fields[2])
+		}
+
+		r := asnRange{start: start.Unmap(), end: end.Unmap(), asn: uint32(asn)}
+		if len(fields) >= 4 {
+			r.country = fields[3]
+		}
+		if len(fields) >= 5 {
+			r.name = fields[4]
+		}
+		ranges = append(ranges, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start.Less(ranges[j].start) })
+	return ranges, nil
+}
@@ -0,0 +1,146 @@
+package iptype
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const sampleDB = "1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n" +
+	"3.0.0.0\t3.127.255.255\t16509\tUS\tAMAZON-02\n" +
+	"5.0.0.0\t5.0.0.255\t64500\tNL\tEXAMPLE-HOSTING-AS\n" +
+	"5.0.1.0\t5.0.1.255\t0\tNone\tNot routed\n" +
+	"81.0.0.0\t81.0.255.255\t64501\tDE\tEXAMPLE-BROADBAND\n" +
+	"2001:db8::\t2001:db8:ffff:ffff:ffff:ffff:ffff:ffff\t64502\tNL\tEXAMPLE-MOBILE\n"
+
+// writeFile writes data to a file in a temporary directory
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestClassify(t *testing.T) {
+	prefixes := writeFile(t, "prefixes.txt", "# office VPN egress\n81.0.42.0/24 vpn\n")
+	c, err := New(writeFile(t, "ip2asn.tsv", sampleDB), prefixes)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"104.16.1.1", TypeHosting},   // bundled Cloudflare prefix
+		{"3.5.1.1", TypeHosting},      // bundled ASN
+		{"5.0.0.7", TypeHosting},      // AS name keyword
+		{"81.0.1.1", TypeResidential}, // unlisted ASN
+		{"81.0.42.1", TypeVPN},        // configured prefix overrides the ASN
+		{"::ffff:81.0.1.1", TypeResidential},
+		{"2001:db8::1", TypeMobile},
+		{"5.0.1.1", ""},  // not routed
+		{"9.9.9.9", ""},  // not in the database
+		{"10.0.0.1", ""}, // private
+		{"invalid", ""},
+	}
+	for _, tt := range tests {
+		if got := c.Classify(tt.ip); got != tt.want {
+			t.Errorf("Classify(%s) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+
+	if datasets := c.Datasets(); len(datasets) != 3 || datasets[2].Name != "ip-type:asn-db" {
+		t.Errorf("Unexpected datasets %+v", datasets)
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	c, err := New(writeFile(t, "ip2asn.tsv", sampleDB), "")
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	tests := []struct {
+		ip      string
+		asn     uint32
+		country string
+		ok      bool
+	}{
+		{"3.5.1.1", 16509, "US", true},
+		{"::ffff:81.0.1.1", 64501, "DE", true},
+		{"2001:db8::1", 64502, "NL", true},
+		{"5.0.1.1", 0, "", false}, // not routed
+		{"9.9.9.9", 0, "", false},
+		{"invalid", 0, "", false},
+	}
+	for _, tt := range tests {
+		asn, country, ok := c.Network(tt.ip)
+		if asn != tt.asn || country != tt.country || ok != tt.ok {
+			t.Errorf("Network(%s) = %d, %q, %t, want %d, %q, %t", tt.ip, asn, country, ok, tt.asn, tt.country, tt.ok)
+		}
+	}
+
+	var none *Classifier
+	if _, _, ok := none.Network("3.5.1.1"); ok {
+		t.Error("Expected no network from a nil classifier")
+	}
+}
+
+func TestClassifyWithoutASNDatabase(t *testing.T) {
+	c, err := New("", "")
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	if got := c.Classify("2606:4700::1111"); got != TypeHosting {
+		t.Errorf("Expected bundled prefix to classify as hosting, got %q", got)
+	}
+	if got := c.Classify("81.0.1.1"); got != "" {
+		t.Errorf("Expected no classification without an ASN database, got %q", got)
+	}
+}
+
+func TestNewGzipDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ip2asn.tsv.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	zw.Write([]byte(sampleDB))
+	zw.Close()
+	f.Close()
+
+	c, err := New(path, "")
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if got := c.Classify("81.0.1.1"); got != TypeResidential {
+		t.Errorf("Expected residential, got %q", got)
+	}
+}
+
+func TestNewInvalidFiles(t *testing.T) {
+	if _, err := New("", writeFile(t, "prefixes.txt", "81.0.42.0/24 satellite\n")); err == nil {
+		t.Error("Expected error for unknown network type")
+	}
+	if _, err := New(writeFile(t, "ip2asn.tsv", "1.0.0.0\tnot-an-ip\t13335\tUS\tX\n"), ""); err == nil {
+		t.Error("Expected error for malformed ASN database")
+	}
+	if _, err := New(filepath.Join(t.TempDir(), "missing.tsv"), ""); err == nil {
+		t.Error("Expected error for missing ASN database")
+	}
+}
+
+func TestBundledLists(t *testing.T) {
+	if _, err := parseASNs(bundledASNs); err != nil {
+		t.Errorf("Bundled ASN list is invalid: %v", err)
+	}
+	if _, err := parsePrefixes(bundledPrefixes); err != nil {
+		t.Errorf("Bundled prefix list is invalid: %v", err)
+	}
+}
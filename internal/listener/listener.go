@@ -0,0 +1,52 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// ErrUnsupported is returned when more than one socket is requested on a platform without SO_REUSEPORT
+var ErrUnsupported = errors.New("SO_REUSEPORT is not supported on this platform")
+
+// Listen opens n listening sockets on addr. With n > 1 every socket is bound with SO_REUSEPORT so
+// the kernel spreads incoming connections across them, and each can be served by its own accept
+// loop instead of all acceptors contending for a single accept queue.
+func Listen(ctx context.Context, network, addr string, n int) ([]net.Listener, error) {
+	if n <= 1 {
+		var lc net.ListenConfig
+		l, err := lc.Listen(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{l}, nil
+	}
+
+	if !Supported {
+		return nil, ErrUnsupported
+	}
+
+	lc := net.ListenConfig{Control: reusePort}
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		l, err := lc.Listen(ctx, network, addr)
+		if err != nil {
+			Close(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, l)
+
+		// A zero port is resolved by the first socket; the others must bind the same one
+		if i == 0 {
+			addr = l.Addr().String()
+		}
+	}
+	return listeners, nil
+}
+
+// Close closes every listener
+func Close(listeners []net.Listener) {
+	for _, l := range listeners {
+		l.Close()
+	}
+}
@@ -0,0 +1,140 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestListenSingle(t *testing.T) {
+	listeners, err := Listen(context.Background(), "tcp", "127.0.0.1:0", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(listeners)
+
+	if len(listeners) != 1 {
+		t.Errorf("Expected one listener, got %d", len(listeners))
+	}
+}
+
+func TestListenReusePort(t *testing.T) {
+	listeners, err := Listen(context.Background(), "tcp", "127.0.0.1:0", 4)
+	if !Supported {
+		if !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("Expected ErrUnsupported, got %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(listeners)
+
+	if len(listeners) != 4 {
+		t.Fatalf("Expected 4 listeners, got %d", len(listeners))
+	}
+	addr := listeners[0].Addr().String()
+	for _, l := range listeners[1:] {
+		if l.Addr().String() != addr {
+			t.Errorf("Expected every socket on %s, got %s", addr, l.Addr())
+		}
+	}
+
+	// Every connection is accepted by one of the sockets
+	accepted := make(chan struct{}, 20)
+	for _, l := range listeners {
+		go acceptLoop(l, accepted)
+	}
+	for i := 0; i < 20; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+		<-accepted
+	}
+}
+
+func TestListenAddressInUse(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+
+	// A socket bound without SO_REUSEPORT cannot be shared
+	if listeners, err := Listen(context.Background(), "tcp", taken.Addr().String(), 2); err == nil {
+		Close(listeners)
+		t.Error("Expected binding a port held without SO_REUSEPORT to fail")
+	}
+}
+
+// acceptLoop accepts and closes connections until l is closed, signalling each on accepted
+func acceptLoop(l net.Listener, accepted chan<- struct{}) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		conn.Close()
+		if accepted != nil {
+			accepted <- struct{}{}
+		}
+	}
+}
+
+// BenchmarkAccept compares connection-accept throughput of a single socket against several
+// SO_REUSEPORT sockets, with clients connecting from every CPU. Run it with
+//
+//	go test -run '^$' -bench Accept -cpu 1,4,16 ./internal/listener
+func BenchmarkAccept(b *testing.B) {
+	counts := []int{1}
+	if Supported {
+		counts = append(counts, 2, 4)
+		if cpus := runtime.NumCPU(); cpus > 4 {
+			counts = append(counts, cpus)
+		}
+	}
+
+	for _, n := range counts {
+		b.Run(fmt.Sprintf("Sockets%d", n), func(b *testing.B) {
+			listeners, err := Listen(context.Background(), "tcp", "127.0.0.1:0", n)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var wg sync.WaitGroup
+			for _, l := range listeners {
+				wg.Add(1)
+				go func(l net.Listener) {
+					defer wg.Done()
+					acceptLoop(l, nil)
+				}(l)
+			}
+			addr := listeners[0].Addr().String()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				buf := make([]byte, 1)
+				for pb.Next() {
+					conn, err := net.Dial("tcp", addr)
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					// Wait for the server to accept and close the connection
+					conn.Read(buf)
+					conn.Close()
+				}
+			})
+			b.StopTimer()
+
+			Close(listeners)
+			wg.Wait()
+		})
+	}
+}
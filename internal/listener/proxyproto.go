@@ -0,0 +1,247 @@
+package listener
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"myip/internal/logging"
+)
+
+var proxyLogger = logging.For("proxyproto")
+
+// PROXY protocol handling modes
+const (
+	ProxyOff      = "off"
+	ProxyRequired = "required"
+	ProxyOptional = "optional"
+)
+
+// ProxyHeaderTimeout bounds how long a connection may take to send its PROXY protocol header
+const ProxyHeaderTimeout = 5 * time.Second
+
+// proxyV1Prefix starts a PROXY protocol v1 header, and proxyV2Signature a v2 header
+var (
+	proxyV1Prefix    = []byte("PROXY ")
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// proxyV1MaxLength is the longest valid v1 header, including the CRLF
+const proxyV1MaxLength = 107
+
+var (
+	errNoProxyHeader      = errors.New("connection did not start with a PROXY protocol header")
+	errInvalidProxyHeader = errors.New("invalid PROXY protocol header")
+)
+
+// proxyListener reads a PROXY protocol header from each accepted connection
+type proxyListener struct {
+	net.Listener
+	required bool
+}
+
+// ProxyProtocol wraps l so accepted connections report the client address carried in a PROXY
+// protocol (v1 or v2) header, as sent by HAProxy or an AWS Network Load Balancer, as their
+// RemoteAddr. With mode ProxyRequired connections without a valid header are closed; with
+// ProxyOptional they are served with their own peer address. ProxyOff returns l unchanged.
+//
+// The header is read on the connection's first Read or RemoteAddr call rather than in Accept, so a
+// slow client cannot stall the accept loop.
+func ProxyProtocol(l net.Listener, mode string) net.Listener {
+	if mode != ProxyRequired && mode != ProxyOptional {
+		return l
+	}
+	return &proxyListener{Listener: l, required: mode == ProxyRequired}
+}
+
+// Accept waits for the next connection and wraps it to parse its PROXY protocol header
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyConn{Conn: conn, reader: bufio.NewReader(conn), required: l.required}, nil
+}
+
+// proxyConn is a connection whose PROXY protocol header is parsed on first use
+type proxyConn struct {
+	net.Conn
+	reader   *bufio.Reader
+	required bool
+
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+// Read reads connection data following the PROXY protocol header
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address from the PROXY protocol header, or the peer address when
+// the header carries none
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	return c.remote
+}
+
+// readHeader parses the PROXY protocol header, closing the connection when it is invalid, or
+// missing while required
+func (c *proxyConn) readHeader() {
+	c.remote = c.Conn.RemoteAddr()
+
+	c.Conn.SetReadDeadline(time.Now().Add(ProxyHeaderTimeout))
+	addr, err := readProxyHeader(c.reader)
+	c.Conn.SetReadDeadline(time.Time{})
+
+	if !c.required && (errors.Is(err, errNoProxyHeader) || isIdle(err, c.reader)) {
+		return
+	}
+	if err != nil {
+		proxyLogger.Debugf("Rejecting connection from %s: %v", c.remote, err)
+		c.err = err
+		c.Conn.Close()
+		return
+	}
+	if addr != nil {
+		proxyLogger.Debugf("Connection from %s proxied for %s", c.remote, addr)
+		c.remote = addr
+	}
+}
+
+// isIdle reports whether err is a timeout before the client sent anything, as when a browser
+// preconnects; without a required header such connections are left to the HTTP server's timeouts
+func isIdle(err error, r *bufio.Reader) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout() && r.Buffered() == 0
+}
+
+// readProxyHeader consumes a PROXY protocol header from r and returns the client address it
+// carries, or nil for headers without one (v1 UNKNOWN, v2 LOCAL or unspecified family). It returns
+// errNoProxyHeader, consuming nothing, when r does not start with a header.
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch first[0] {
+	case proxyV1Prefix[0]:
+		if prefix, err := r.Peek(len(proxyV1Prefix)); err != nil || !bytes.Equal(prefix, proxyV1Prefix) {
+			return nil, errNoProxyHeader
+		}
+		return readProxyV1(r)
+	case proxyV2Signature[0]:
+		if signature, err := r.Peek(len(proxyV2Signature)); err != nil || !bytes.Equal(signature, proxyV2Signature) {
+			return nil, errNoProxyHeader
+		}
+		return readProxyV2(r)
+	}
+	return nil, errNoProxyHeader
+}
+
+// readProxyV1 parses a human-readable header such as "PROXY TCP4 203.0.113.7 10.0.0.2 41234 443"
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for len(line) < proxyV1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("%w: v1 header too long or not terminated by CRLF", errInvalidProxyHeader)
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("%w: malformed v1 header %q", errInvalidProxyHeader, line)
+	}
+
+	ip := net.ParseIP(fields[2])
+	if ip == nil || (ip.To4() != nil) != (fields[1] == "TCP4") {
+		return nil, fmt.Errorf("%w: bad v1 source address %q", errInvalidProxyHeader, fields[2])
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad v1 source port %q", errInvalidProxyHeader, fields[4])
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// PROXY protocol v2 commands and address families
+const (
+	proxyV2Local = 0x0
+	proxyV2Proxy = 0x1
+
+	proxyV2TCP4 = 0x11
+	proxyV2TCP6 = 0x21
+)
+
+// readProxyV2 parses a binary header: the signature, version and command, address family,
+// payload length, and a payload of addresses optionally followed by TLVs, which are skipped
+func readProxyV2(r *bufio.Reader) (net.Addr, error) {
+	var header [16]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	if version := header[12] >> 4; version != 2 {
+		return nil, fmt.Errorf("%w: unsupported v2 version %d", errInvalidProxyHeader, version)
+	}
+	command := header[12] & 0x0f
+	family := header[13]
+	length := int(binary.BigEndian.Uint16(header[14:16]))
+
+	var addrLength int
+	switch {
+	case command == proxyV2Local:
+	case command != proxyV2Proxy:
+		return nil, fmt.Errorf("%w: unsupported v2 command %d", errInvalidProxyHeader, command)
+	case family == proxyV2TCP4:
+		addrLength = 12
+	case family == proxyV2TCP6:
+		addrLength = 36
+	}
+	if length < addrLength {
+		return nil, fmt.Errorf("%w: v2 payload of %d bytes is too short", errInvalidProxyHeader, length)
+	}
+
+	payload := make([]byte, addrLength)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	if _, err := r.Discard(length - addrLength); err != nil {
+		return nil, err
+	}
+
+	switch addrLength {
+	case 12:
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 36:
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	}
+	// LOCAL connections, such as load balancer health checks, and unsupported families keep the
+	// peer address
+	return nil, nil
+}
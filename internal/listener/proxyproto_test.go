@@ -0,0 +1,169 @@
+package listener
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// proxyV2Header builds a v2 header for a TCP connection from src, with extra bytes of TLVs
+func proxyV2Header(command byte, src *net.TCPAddr, extra int) []byte {
+	header := append([]byte(nil), proxyV2Signature...)
+	header = append(header, 0x20|command)
+
+	var addresses []byte
+	family := byte(proxyV2TCP6)
+	dst := net.ParseIP("2001:db8::443")
+	if ip4 := src.IP.To4(); ip4 != nil {
+		family = proxyV2TCP4
+		addresses = append(append(addresses, ip4...), 10, 0, 0, 2)
+	} else {
+		addresses = append(append(addresses, src.IP.To16()...), dst...)
+	}
+	addresses = binary.BigEndian.AppendUint16(addresses, uint16(src.Port))
+	addresses = binary.BigEndian.AppendUint16(addresses, 443)
+	addresses = append(addresses, make([]byte, extra)...)
+
+	header = append(header, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addresses)))
+	return append(header, addresses...)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		err      error
+	}{
+		{"v1 TCP4", "PROXY TCP4 203.0.113.7 10.0.0.2 41234 443\r\nGET /", "203.0.113.7:41234", nil},
+		{"v1 TCP6", "PROXY TCP6 2001:db8::7 2001:db8::443 41234 443\r\nGET /", "[2001:db8::7]:41234", nil},
+		{"v1 UNKNOWN", "PROXY UNKNOWN\r\nGET /", "", nil},
+		{"v1 family mismatch", "PROXY TCP6 203.0.113.7 10.0.0.2 41234 443\r\n", "", errInvalidProxyHeader},
+		{"v1 bad port", "PROXY TCP4 203.0.113.7 10.0.0.2 70000 443\r\n", "", errInvalidProxyHeader},
+		{"v1 missing CRLF", "PROXY TCP4 203.0.113.7 10.0.0.2 41234 443\n", "", errInvalidProxyHeader},
+		{"v1 too long", "PROXY TCP4 " + strings.Repeat("1", 200) + "\r\n", "", errInvalidProxyHeader},
+		{"v2 TCP4", string(proxyV2Header(proxyV2Proxy, &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 41234}, 0)) + "GET /", "203.0.113.7:41234", nil},
+		{"v2 TCP6 with TLVs", string(proxyV2Header(proxyV2Proxy, &net.TCPAddr{IP: net.ParseIP("2001:db8::7"), Port: 41234}, 20)) + "GET /", "[2001:db8::7]:41234", nil},
+		{"v2 LOCAL", string(proxyV2Header(proxyV2Local, &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 41234}, 0)) + "GET /", "", nil},
+		{"v2 bad command", string(proxyV2Header(0x7, &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 41234}, 0)), "", errInvalidProxyHeader},
+		{"Plain HTTP", "GET / HTTP/1.1\r\n", "", errNoProxyHeader},
+		{"Not quite PROXY", "POST / HTTP/1.1\r\n", "", errNoProxyHeader},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(test.input))
+			addr, err := readProxyHeader(r)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Expected error %v, got %v", test.err, err)
+			}
+			if err != nil {
+				return
+			}
+
+			got := ""
+			if addr != nil {
+				got = addr.String()
+			}
+			if got != test.expected {
+				t.Errorf("Expected address %q, got %q", test.expected, got)
+			}
+
+			// The header is consumed, leaving the request
+			if rest, _ := io.ReadAll(r); string(rest) != "GET /" {
+				t.Errorf("Expected the request to follow the header, got %q", rest)
+			}
+		})
+	}
+}
+
+// serveProxied serves the peer address of each request on a listener wrapped with mode and
+// returns its address
+func serveProxied(t *testing.T, mode string) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.RemoteAddr)
+	})}
+	go server.Serve(ProxyProtocol(l, mode))
+	t.Cleanup(func() { server.Close() })
+	return l.Addr().String()
+}
+
+// request sends a GET request after prefix on a new connection and returns the response body
+func request(t *testing.T, addr, prefix string) (string, error) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprintf(conn, "%sGET / HTTP/1.1\r\nHost: myip\r\nConnection: close\r\n\r\n", prefix)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestProxyProtocolRequired(t *testing.T) {
+	addr := serveProxied(t, ProxyRequired)
+
+	body, err := request(t, addr, "PROXY TCP4 203.0.113.7 10.0.0.2 41234 443\r\n")
+	if err != nil || body != "203.0.113.7:41234" {
+		t.Errorf("Expected the proxied client address, got %q %v", body, err)
+	}
+
+	v2 := proxyV2Header(proxyV2Proxy, &net.TCPAddr{IP: net.ParseIP("2001:db8::7"), Port: 5000}, 0)
+	body, err = request(t, addr, string(v2))
+	if err != nil || body != "[2001:db8::7]:5000" {
+		t.Errorf("Expected the proxied v2 client address, got %q %v", body, err)
+	}
+
+	if body, err := request(t, addr, ""); err == nil {
+		t.Errorf("Expected a connection without a header to be closed, got %q", body)
+	}
+}
+
+func TestProxyProtocolOptional(t *testing.T) {
+	addr := serveProxied(t, ProxyOptional)
+
+	body, err := request(t, addr, "PROXY TCP4 203.0.113.7 10.0.0.2 41234 443\r\n")
+	if err != nil || body != "203.0.113.7:41234" {
+		t.Errorf("Expected the proxied client address, got %q %v", body, err)
+	}
+
+	body, err = request(t, addr, "")
+	if err != nil || !strings.HasPrefix(body, "127.0.0.1:") {
+		t.Errorf("Expected the peer address without a header, got %q %v", body, err)
+	}
+}
+
+func TestProxyProtocolOff(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if ProxyProtocol(l, ProxyOff) != l {
+		t.Error("Expected the listener to be returned unchanged")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package listener
+
+import "syscall"
+
+// Supported reports whether Listen can open more than one socket on this platform
+const Supported = false
+
+// reusePort is never called on platforms without SO_REUSEPORT
+func reusePort(network, address string, c syscall.RawConn) error {
+	return ErrUnsupported
+}
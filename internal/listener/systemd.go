@@ -0,0 +1,61 @@
+package listener
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// sdListenFDsStart is the first file descriptor passed by systemd socket activation
+const sdListenFDsStart = 3
+
+// Activated returns the listening sockets passed in by systemd socket activation, in the order of
+// the socket unit's Listen directives, or nil when the process was not socket-activated. Like
+// sd_listen_fds(3) it only accepts sockets meant for this process, and unsets the activation
+// variables so child processes do not claim them too.
+func Activated() ([]net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	return activated(os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES"), sdListenFDsStart)
+}
+
+// activated converts the count file descriptors starting at first into listeners when pid names
+// this process
+func activated(pid, count, names string, first int) ([]net.Listener, error) {
+	if pid == "" || count == "" {
+		return nil, nil
+	}
+	if n, err := strconv.Atoi(pid); err != nil || n != os.Getpid() {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", count)
+	}
+
+	fdNames := strings.Split(names, ":")
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		name := "LISTEN_FD_" + strconv.Itoa(first+i)
+		if i < len(fdNames) && fdNames[i] != "" {
+			name = fdNames[i]
+		}
+
+		// FileListener duplicates the descriptor, so the original is closed either way
+		f := os.NewFile(uintptr(first+i), name)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			Close(listeners)
+			return nil, fmt.Errorf("socket-activated file descriptor %d (%s): %w", first+i, name, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
@@ -0,0 +1,119 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package listener
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// passFD returns a descriptor for a fresh TCP listening socket, owned by the caller like the
+// descriptors systemd passes in
+func passFD(t *testing.T) (int, string) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd, l.Addr().String()
+}
+
+func TestActivated(t *testing.T) {
+	fd, addr := passFD(t)
+	pid := strconv.Itoa(os.Getpid())
+
+	listeners, err := activated(pid, "1", "http", fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Close(listeners)
+
+	if len(listeners) != 1 || listeners[0].Addr().String() != addr {
+		t.Fatalf("Expected the listener on %s, got %v", addr, listeners)
+	}
+
+	go func() {
+		if conn, err := listeners[0].Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Expected to connect to the activated socket: %v", err)
+	}
+	conn.Close()
+}
+
+func TestActivatedOtherProcess(t *testing.T) {
+	tests := []struct {
+		name  string
+		pid   string
+		count string
+	}{
+		{"Not activated", "", ""},
+		{"Other process", strconv.Itoa(os.Getpid() + 1), "1"},
+		{"Invalid pid", "self", "1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			listeners, err := activated(test.pid, test.count, "", sdListenFDsStart)
+			if err != nil || listeners != nil {
+				t.Errorf("Expected no listeners, got %v %v", listeners, err)
+			}
+		})
+	}
+}
+
+func TestActivatedInvalid(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+
+	if _, err := activated(pid, "many", "", sdListenFDsStart); err == nil {
+		t.Error("Expected an error for an invalid LISTEN_FDS")
+	}
+
+	// A descriptor that is not a socket cannot be served
+	f, err := os.CreateTemp(t.TempDir(), "fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := activated(pid, "1", "", fd); err == nil {
+		t.Error("Expected an error for a descriptor that is not a socket")
+	}
+}
+
+func TestActivatedUnsetsEnvironment(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", "http")
+
+	if listeners, err := Activated(); err != nil || listeners != nil {
+		t.Fatalf("Expected no listeners for another process, got %v %v", listeners, err)
+	}
+	for _, key := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		if value, ok := os.LookupEnv(key); ok {
+			t.Errorf("Expected %s to be unset, got %q", key, value)
+		}
+	}
+}
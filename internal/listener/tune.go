@@ -0,0 +1,38 @@
+package listener
+
+import "net"
+
+// TCPOptions are the socket options applied to every accepted TCP connection
+type TCPOptions struct {
+	// NoDelay disables Nagle's algorithm, which Go enables by default
+	NoDelay bool
+	// Linger is the SO_LINGER timeout in seconds; negative keeps the OS default and 0 discards
+	// unsent data and resets the connection on close
+	Linger int
+}
+
+// tunedListener applies TCPOptions to the connections it accepts
+type tunedListener struct {
+	net.Listener
+	options TCPOptions
+}
+
+// Tune wraps l so every accepted TCP connection gets options
+func Tune(l net.Listener, options TCPOptions) net.Listener {
+	return &tunedListener{Listener: l, options: options}
+}
+
+// Accept waits for the next connection and applies the socket options to it
+func (l *tunedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetNoDelay(l.options.NoDelay)
+		if l.options.Linger >= 0 {
+			tcp.SetLinger(l.options.Linger)
+		}
+	}
+	return conn, nil
+}
@@ -0,0 +1,44 @@
+package listener
+
+import (
+	"net"
+	"testing"
+)
+
+func TestTune(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Tune(inner, TCPOptions{NoDelay: false, Linger: 0})
+	defer l.Close()
+
+	go func() {
+		if conn, err := net.Dial("tcp", l.Addr().String()); err == nil {
+			conn.Close()
+		}
+	}()
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, ok := conn.(*net.TCPConn); !ok {
+		t.Errorf("Expected the accepted connection to stay a *net.TCPConn, got %T", conn)
+	}
+}
+
+func TestTuneAcceptError(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Tune(inner, TCPOptions{Linger: -1})
+	l.Close()
+
+	if _, err := l.Accept(); err == nil {
+		t.Error("Expected Accept on a closed listener to fail")
+	}
+}
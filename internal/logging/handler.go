@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"myip/internal/models"
+	"myip/internal/problem"
+)
+
+// levelRequest is the body accepted by PUT /admin/loglevel; omitted fields are left unchanged
+type levelRequest struct {
+	Level        *string   `json:"level"`
+	DebugModules *[]string `json:"debug_modules"`
+}
+
+// Status returns the current runtime log configuration
+func Status() *models.LogLevelStatus {
+	return &models.LogLevelStatus{
+		Level:        GetLevel().String(),
+		DebugModules: DebugModules(),
+	}
+}
+
+// Handler serves the log level admin endpoint.
+// GET returns the current configuration; PUT accepts {"level": "...", "debug_modules": [...]}.
+// Changes last until the next restart or configuration reload.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var request levelRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&request); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		level := GetLevel()
+		if request.Level != nil {
+			parsed, err := ParseLevel(*request.Level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			level = parsed
+		}
+
+		if request.DebugModules != nil {
+			if err := SetDebugModules(*request.DebugModules); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		SetLevel(level)
+		output(LevelInfo, "", "Log level set to %s, debug modules %v", level, DebugModules())
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(Status()); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, "Failed to encode log level status")
+		return
+	}
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"myip/internal/models"
+)
+
+func TestHandler(t *testing.T) {
+	defer SetLevel(LevelInfo)
+	defer SetDebugModules(nil)
+
+	tests := []struct {
+		name            string
+		method          string
+		body            string
+		expectedCode    int
+		expectedLevel   string
+		expectedModules []string
+	}{
+		{"Get status", "GET", "", http.StatusOK, "info", []string{}},
+		{"Set level", "PUT", `{"level":"warn"}`, http.StatusOK, "warn", []string{}},
+		{"Set modules", "PUT", `{"debug_modules":["detector","ratelimit"]}`, http.StatusOK, "warn", []string{"detector", "ratelimit"}},
+		{"Invalid level", "PUT", `{"level":"loud"}`, http.StatusBadRequest, "warn", []string{"detector", "ratelimit"}},
+		{"Unknown module", "PUT", `{"debug_modules":["bogus"]}`, http.StatusBadRequest, "warn", []string{"detector", "ratelimit"}},
+		{"Invalid JSON", "PUT", `{`, http.StatusBadRequest, "warn", []string{"detector", "ratelimit"}},
+		{"Clear modules", "PUT", `{"level":"debug","debug_modules":[]}`, http.StatusOK, "debug", []string{}},
+		{"Method not allowed", "POST", "", http.StatusMethodNotAllowed, "debug", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "/admin/loglevel", strings.NewReader(test.body))
+			rr := httptest.NewRecorder()
+			Handler(rr, req)
+
+			if rr.Code != test.expectedCode {
+				t.Errorf("Expected status %d, got %d", test.expectedCode, rr.Code)
+			}
+
+			if rr.Code == http.StatusOK {
+				var status models.LogLevelStatus
+				if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+					t.Fatalf("Failed to decode status: %v", err)
+				}
+				if status.Level != test.expectedLevel {
+					t.Errorf("Expected response level %s, got %s", test.expectedLevel, status.Level)
+				}
+			}
+
+			if GetLevel().String() != test.expectedLevel {
+				t.Errorf("Expected level %s, got %s", test.expectedLevel, GetLevel())
+			}
+
+			modules := DebugModules()
+			if strings.Join(modules, ",") != strings.Join(test.expectedModules, ",") {
+				t.Errorf("Expected modules %v, got %v", test.expectedModules, modules)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is a log severity level
+type Level int32
+
+// Supported log levels, from most to least verbose
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+// String returns the lowercase name of the level
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int32(l))
+}
+
+// ParseLevel parses a level name case-insensitively ("warning" is accepted as "warn")
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", name)
+}
+
+var currentLevel atomic.Int32
+
+// Modules are the subsystems whose debug logging can be enabled independently of the global level
+var Modules = []string{"detector", "geo", "dns", "ratelimit", "stun", "enrich", "rdap", "reputation", "iptype", "access", "proxyproto", "cache", "wellknown", "scanner", "proxyprofile", "shadow", "cluster", "scheduler", "quota"}
+
+// moduleDebug holds a debug flag per module; the map itself is never modified after init
+var moduleDebug = make(map[string]*atomic.Bool, len(Modules))
+
+func init() {
+	currentLevel.Store(int32(LevelInfo))
+	for _, module := range Modules {
+		moduleDebug[module] = &atomic.Bool{}
+	}
+}
+
+// SetLevel changes the minimum level that is logged
+func SetLevel(level Level) {
+	currentLevel.Store(int32(level))
+}
+
+// GetLevel returns the minimum level that is logged
+func GetLevel() Level {
+	return Level(currentLevel.Load())
+}
+
+// Enabled reports whether messages at level are currently logged
+func Enabled(level Level) bool {
+	return level >= GetLevel()
+}
+
+// SetDebugModules enables debug logging for exactly the given modules, regardless of the global level
+func SetDebugModules(modules []string) error {
+	for _, module := range modules {
+		if _, ok := moduleDebug[module]; !ok {
+			return fmt.Errorf("unknown log module %q", module)
+		}
+	}
+
+	enabled := make(map[string]bool, len(modules))
+	for _, module := range modules {
+		enabled[module] = true
+	}
+	for module, flag := range moduleDebug {
+		flag.Store(enabled[module])
+	}
+	return nil
+}
+
+// DebugModules returns the modules with debug logging enabled, in Modules order
+func DebugModules() []string {
+	modules := []string{}
+	for _, module := range Modules {
+		if moduleDebug[module].Load() {
+			modules = append(modules, module)
+		}
+	}
+	return modules
+}
+
+func logf(level Level, format string, args ...any) {
+	if !Enabled(level) {
+		return
+	}
+	output(level, "", format, args...)
+}
+
+func output(level Level, module, format string, args ...any) {
+	prefix := "[" + strings.ToUpper(level.String()) + "] "
+	if module != "" {
+		prefix += "[" + module + "] "
+	}
+	log.Printf(prefix+format, args...)
+}
+
+// Logger logs on behalf of a module, allowing its debug output to be enabled on its own
+type Logger struct {
+	module string
+	debug  *atomic.Bool
+}
+
+// For returns the logger for a module listed in Modules
+func For(module string) *Logger {
+	flag, ok := moduleDebug[module]
+	if !ok {
+		panic("logging: unknown module " + module)
+	}
+	return &Logger{module: module, debug: flag}
+}
+
+// DebugEnabled reports whether debug messages for the module are logged.
+// Callers can use it to skip building expensive debug arguments.
+func (l *Logger) DebugEnabled() bool {
+	return l.debug.Load() || Enabled(LevelDebug)
+}
+
+// Debugf logs a debug message if the module or global debug logging is enabled
+func (l *Logger) Debugf(format string, args ...any) {
+	if l.DebugEnabled() {
+		output(LevelDebug, l.module, format, args...)
+	}
+}
+
+// Debugf logs a debug message
+func Debugf(format string, args ...any) { logf(LevelDebug, format, args...) }
+
+// Infof logs an informational message
+func Infof(format string, args ...any) { logf(LevelInfo, format, args...) }
+
+// Warnf logs a warning
+func Warnf(format string, args ...any) { logf(LevelWarn, format, args...) }
+
+// Errorf logs an error
+func Errorf(format string, args ...any) { logf(LevelError, format, args...) }
@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Level
+		valid    bool
+	}{
+		{"debug", LevelDebug, true},
+		{"INFO", LevelInfo, true},
+		{" warn ", LevelWarn, true},
+		{"warning", LevelWarn, true},
+		{"Error", LevelError, true},
+		{"verbose", LevelInfo, false},
+		{"", LevelInfo, false},
+	}
+
+	for _, test := range tests {
+		level, err := ParseLevel(test.input)
+		if (err == nil) != test.valid {
+			t.Errorf("ParseLevel(%q) error = %v, expected valid=%t", test.input, err, test.valid)
+		}
+		if level != test.expected {
+			t.Errorf("ParseLevel(%q) = %v, expected %v", test.input, level, test.expected)
+		}
+	}
+}
+
+func TestLevelString(t *testing.T) {
+	if LevelWarn.String() != "warn" {
+		t.Errorf("Expected warn, got %s", LevelWarn.String())
+	}
+	if Level(42).String() != "level(42)" {
+		t.Errorf("Expected level(42), got %s", Level(42).String())
+	}
+}
+
+func TestLevelFiltering(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	defer SetLevel(LevelInfo)
+
+	SetLevel(LevelWarn)
+
+	Debugf("debug message")
+	Infof("info message")
+	Warnf("warn message")
+	Errorf("error message")
+
+	output := buf.String()
+	if strings.Contains(output, "debug message") || strings.Contains(output, "info message") {
+		t.Errorf("Expected debug and info to be filtered, got %q", output)
+	}
+	if !strings.Contains(output, "[WARN] warn message") || !strings.Contains(output, "[ERROR] error message") {
+		t.Errorf("Expected warn and error messages, got %q", output)
+	}
+
+	SetLevel(LevelDebug)
+	Debugf("now visible")
+	if !strings.Contains(buf.String(), "[DEBUG] now visible") {
+		t.Error("Expected debug message after lowering the level")
+	}
+}
+
+func TestModuleDebug(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	defer SetDebugModules(nil)
+
+	logger := For("dns")
+
+	logger.Debugf("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("Expected no output at info level, got %q", buf.String())
+	}
+
+	if err := SetDebugModules([]string{"dns"}); err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Debugf("lookup %s", "example.com")
+	For("detector").Debugf("not enabled")
+
+	output := buf.String()
+	if !strings.Contains(output, "[DEBUG] [dns] lookup example.com") {
+		t.Errorf("Expected module debug output, got %q", output)
+	}
+	if strings.Contains(output, "not enabled") {
+		t.Errorf("Expected other modules to stay quiet, got %q", output)
+	}
+
+	if modules := DebugModules(); len(modules) != 1 || modules[0] != "dns" {
+		t.Errorf("Expected [dns], got %v", modules)
+	}
+}
+
+func TestSetDebugModulesUnknown(t *testing.T) {
+	defer SetDebugModules(nil)
+
+	if err := SetDebugModules([]string{"dns"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetDebugModules([]string{"geo", "bogus"}); err == nil {
+		t.Error("Expected error for unknown module")
+	}
+
+	// A rejected update leaves the current modules untouched
+	if modules := DebugModules(); len(modules) != 1 || modules[0] != "dns" {
+		t.Errorf("Expected [dns] to remain enabled, got %v", modules)
+	}
+}
+
+func TestForUnknownModulePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for unknown module")
+		}
+	}()
+	For("bogus")
+}
@@ -0,0 +1,182 @@
+package maintenance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"myip/internal/models"
+	"myip/internal/problem"
+)
+
+// templateData is the data made available to the maintenance message template
+type templateData struct {
+	RetryAfter int
+	Since      string
+}
+
+// Mode holds the maintenance mode state shared between the middleware and the admin endpoint
+type Mode struct {
+	mu         sync.RWMutex
+	enabled    bool
+	since      time.Time
+	message    string
+	tmpl       *template.Template
+	retryAfter time.Duration
+}
+
+// New creates a maintenance mode controller with the given initial state.
+// The message is parsed as a text/template and may reference {{.RetryAfter}} and {{.Since}}.
+func New(enabled bool, message string, retryAfter time.Duration) (*Mode, error) {
+	tmpl, err := parseMessage(message)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Mode{
+		enabled:    enabled,
+		message:    message,
+		tmpl:       tmpl,
+		retryAfter: retryAfter,
+	}
+	if enabled {
+		m.since = time.Now().UTC()
+	}
+	return m, nil
+}
+
+func parseMessage(message string) (*template.Template, error) {
+	tmpl, err := template.New("maintenance").Parse(message)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenance message template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Enabled reports whether maintenance mode is currently active
+func (m *Mode) Enabled() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.enabled
+}
+
+// Enable turns maintenance mode on, optionally replacing the message template
+func (m *Mode) Enable(message string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if message != "" {
+		tmpl, err := parseMessage(message)
+		if err != nil {
+			return err
+		}
+		m.message = message
+		m.tmpl = tmpl
+	}
+
+	if !m.enabled {
+		m.enabled = true
+		m.since = time.Now().UTC()
+	}
+	return nil
+}
+
+// Disable turns maintenance mode off
+func (m *Mode) Disable() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.enabled = false
+	m.since = time.Time{}
+}
+
+// Status returns a snapshot of the current maintenance state
+func (m *Mode) Status() *models.MaintenanceStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status := &models.MaintenanceStatus{
+		Enabled:    m.enabled,
+		Message:    m.message,
+		RetryAfter: int(m.retryAfter.Seconds()),
+	}
+	if m.enabled {
+		status.Since = m.since.Format(time.RFC3339)
+	}
+	return status
+}
+
+// render executes the message template against the current state
+func (m *Mode) render() (string, time.Duration) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data := templateData{
+		RetryAfter: int(m.retryAfter.Seconds()),
+		Since:      m.since.Format(time.RFC3339),
+	}
+
+	var buf bytes.Buffer
+	if err := m.tmpl.Execute(&buf, data); err != nil {
+		log.Printf("Failed to render maintenance message: %v", err)
+		return m.message, m.retryAfter
+	}
+	return buf.String(), m.retryAfter
+}
+
+// Middleware returns 503 Service Unavailable with a Retry-After header while maintenance mode is enabled
+func (m *Mode) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		message, retryAfter := m.render()
+		problem.Error(w, r, http.StatusServiceUnavailable, message,
+			problem.WithRetryAfter(retryAfter))
+	})
+}
+
+// Handler serves the maintenance admin endpoint.
+// GET returns the current status; POST with enabled=true|false toggles the mode and
+// accepts an optional message parameter to replace the message template.
+func (m *Mode) Handler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+		if err != nil {
+			http.Error(w, "Invalid or missing enabled parameter", http.StatusBadRequest)
+			return
+		}
+
+		if enabled {
+			if err := m.Enable(r.FormValue("message")); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("Maintenance mode enabled")
+		} else {
+			m.Disable()
+			log.Printf("Maintenance mode disabled")
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(m.Status()); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, "Failed to encode maintenance status")
+		return
+	}
+}
@@ -0,0 +1,141 @@
+package maintenance
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"myip/internal/models"
+)
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestNewInvalidTemplate(t *testing.T) {
+	if _, err := New(false, "{{.RetryAfter", time.Minute); err == nil {
+		t.Error("Expected error for invalid message template")
+	}
+}
+
+func TestMiddlewareDisabled(t *testing.T) {
+	mode, err := New(false, "down", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	mode.Middleware(http.HandlerFunc(okHandler)).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 when maintenance is disabled, got %d", rr.Code)
+	}
+}
+
+func TestMiddlewareEnabled(t *testing.T) {
+	mode, err := New(true, "Back in {{.RetryAfter}}s", 90*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	mode.Middleware(http.HandlerFunc(okHandler)).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", rr.Code)
+	}
+
+	if retryAfter := rr.Header().Get("Retry-After"); retryAfter != "90" {
+		t.Errorf("Expected Retry-After 90, got %q", retryAfter)
+	}
+
+	var response models.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode problem response: %v", err)
+	}
+
+	if response.Detail != "Back in 90s" {
+		t.Errorf("Expected rendered message, got %q", response.Detail)
+	}
+}
+
+func TestEnableDisable(t *testing.T) {
+	mode, err := New(false, "down", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mode.Enable("migrating since {{.Since}}"); err != nil {
+		t.Fatalf("Unexpected error enabling maintenance: %v", err)
+	}
+
+	status := mode.Status()
+	if !status.Enabled || status.Since == "" {
+		t.Errorf("Expected enabled status with since timestamp, got %+v", status)
+	}
+	if status.Message != "migrating since {{.Since}}" {
+		t.Errorf("Expected message to be replaced, got %q", status.Message)
+	}
+
+	if err := mode.Enable("{{bad"); err == nil {
+		t.Error("Expected error for invalid template")
+	}
+
+	mode.Disable()
+	if mode.Enabled() {
+		t.Error("Expected maintenance to be disabled")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	mode, err := New(false, "down", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		method          string
+		form            url.Values
+		expectedCode    int
+		expectedEnabled bool
+	}{
+		{"Get status", "GET", nil, http.StatusOK, false},
+		{"Enable", "POST", url.Values{"enabled": {"true"}, "message": {"brb"}}, http.StatusOK, true},
+		{"Invalid enabled", "POST", url.Values{"enabled": {"maybe"}}, http.StatusBadRequest, true},
+		{"Disable", "POST", url.Values{"enabled": {"false"}}, http.StatusOK, false},
+		{"Method not allowed", "DELETE", nil, http.StatusMethodNotAllowed, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "/admin/maintenance", strings.NewReader(test.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rr := httptest.NewRecorder()
+			mode.Handler(rr, req)
+
+			if rr.Code != test.expectedCode {
+				t.Errorf("Expected status %d, got %d", test.expectedCode, rr.Code)
+			}
+
+			if rr.Code == http.StatusOK {
+				var status models.MaintenanceStatus
+				if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+					t.Fatalf("Failed to decode status: %v", err)
+				}
+				if status.Enabled != test.expectedEnabled {
+					t.Errorf("Expected enabled=%t, got %t", test.expectedEnabled, status.Enabled)
+				}
+			}
+
+			if mode.Enabled() != test.expectedEnabled {
+				t.Errorf("Expected mode enabled=%t", test.expectedEnabled)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"myip/internal/cdn"
+	"myip/internal/logging"
+	"myip/internal/requestid"
+)
+
+var accessLogger = logging.For("access")
+
+// AccessLog logs a key=value line per request when the access module's debug logging is enabled.
+// It must run inside requestid.Middleware and cdn.Middleware so the line carries the request ID
+// and the CDN ray ID for correlating origin logs with CDN logs.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !accessLogger.DebugEnabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rec := NewStatusRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		provider, ray := "-", "-"
+		if trace := cdn.FromContext(r.Context()); trace != nil {
+			provider, ray = trace.Provider, trace.RayID
+		}
+		accessLogger.Debugf("method=%s path=%q status=%d duration_ms=%.2f request_id=%s cdn=%s cdn_ray=%s",
+			r.Method, r.URL.Path, rec.Status, float64(time.Since(start).Microseconds())/1000,
+			requestid.FromContext(r.Context()), provider, ray)
+	})
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"myip/internal/cdn"
+	"myip/internal/logging"
+	"myip/internal/requestid"
+)
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	handler := requestid.Middleware(cdn.Middleware(AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))))
+
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.Header.Set(requestid.Header, "req-123")
+	req.Header.Set("CF-Ray", "8a1b2c3d4e5f6789-AMS")
+
+	// Disabled by default
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if buf.Len() != 0 {
+		t.Fatalf("Expected no access log without the access debug module, got %q", buf.String())
+	}
+
+	if err := logging.SetDebugModules([]string{"access"}); err != nil {
+		t.Fatal(err)
+	}
+	defer logging.SetDebugModules(nil)
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	line := buf.String()
+	for _, want := range []string{"[access]", `path="/json"`, "status=418", "request_id=req-123", "cdn=cloudflare", "cdn_ray=8a1b2c3d4e5f6789-AMS"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("Expected access log to contain %s, got %q", want, line)
+		}
+	}
+}
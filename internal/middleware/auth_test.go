@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminAuth(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		expectedCode  int
+	}{
+		{"Disabled without token", "", "Bearer anything", http.StatusNotFound},
+		{"Missing header", "secret", "", http.StatusUnauthorized},
+		{"Wrong scheme", "secret", "Basic secret", http.StatusUnauthorized},
+		{"Wrong token", "secret", "Bearer nope", http.StatusUnauthorized},
+		{"Valid token", "secret", "Bearer secret", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/maintenance", nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+
+			rr := httptest.NewRecorder()
+			AdminAuth(test.token, next).ServeHTTP(rr, req)
+
+			if rr.Code != test.expectedCode {
+				t.Errorf("Expected status %d, got %d", test.expectedCode, rr.Code)
+			}
+		})
+	}
+}
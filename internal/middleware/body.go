@@ -0,0 +1,17 @@
+package middleware
+
+import "net/http"
+
+// LimitBody rejects requests whose body exceeds max bytes with 413 Request Entity Too Large.
+// Bodies without a declared length are cut off at max while being read.
+func LimitBody(max int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > max {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+		next.ServeHTTP(w, r)
+	})
+}
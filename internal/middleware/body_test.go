@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitBody(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name         string
+		body         string
+		hideLength   bool
+		expectedCode int
+	}{
+		{"Within limit", "enabled=true", false, http.StatusOK},
+		{"Declared length over limit", strings.Repeat("x", 32), false, http.StatusRequestEntityTooLarge},
+		{"Undeclared length over limit", strings.Repeat("x", 32), true, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/admin/maintenance", strings.NewReader(test.body))
+			if test.hideLength {
+				req.ContentLength = -1
+			}
+
+			rr := httptest.NewRecorder()
+			LimitBody(16, next).ServeHTTP(rr, req)
+
+			if rr.Code != test.expectedCode {
+				t.Errorf("Expected status %d, got %d", test.expectedCode, rr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+// Delay holds responses for the duration given in the delay query parameter (e.g. ?delay=500ms),
+// capped at max, so client developers can exercise their timeout and retry configuration.
+func Delay(max time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := r.URL.Query().Get("delay")
+		if value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		delay, err := time.ParseDuration(value)
+		if err != nil || delay < 0 {
+			http.Error(w, "Invalid delay parameter", http.StatusBadRequest)
+			return
+		}
+
+		if delay > max {
+			delay = max
+		}
+
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			// Client gave up waiting; nothing left to respond to
+			return
+		}
+
+		w.Header().Set("X-Delay-Applied", delay.String())
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name            string
+		query           string
+		expectedCode    int
+		expectedApplied string
+	}{
+		{"No delay", "", http.StatusOK, ""},
+		{"Small delay", "?delay=10ms", http.StatusOK, "10ms"},
+		{"Capped delay", "?delay=1h", http.StatusOK, "20ms"},
+		{"Invalid delay", "?delay=soon", http.StatusBadRequest, ""},
+		{"Negative delay", "?delay=-1s", http.StatusBadRequest, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			Delay(20*time.Millisecond, next).ServeHTTP(rr, httptest.NewRequest("GET", "/"+test.query, nil))
+
+			if rr.Code != test.expectedCode {
+				t.Errorf("Expected status %d, got %d", test.expectedCode, rr.Code)
+			}
+
+			if applied := rr.Header().Get("X-Delay-Applied"); applied != test.expectedApplied {
+				t.Errorf("Expected X-Delay-Applied %q, got %q", test.expectedApplied, applied)
+			}
+		})
+	}
+}
+
+func TestDelayClientCancel(t *testing.T) {
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequest("GET", "/?delay=1s", nil).WithContext(ctx)
+	Delay(time.Second, next).ServeHTTP(httptest.NewRecorder(), req)
+
+	if called {
+		t.Error("Expected handler not to run after client cancelled")
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Edge cache modes, see EdgeCache
+const (
+	EdgeCacheOff     = "off"
+	EdgeCacheNoStore = "no-store"
+	EdgeCacheVary    = "vary"
+)
+
+// EdgeCache sets the caching headers of per-client responses for the CDNs and caching proxies in
+// front of the service. Vary names the headers returned by headers, the client IP headers the
+// response depends on, and Accept for format negotiation.
+//
+// EdgeCacheNoStore keeps every cache from storing the response, through Cache-Control and the
+// CDN-specific CDN-Cache-Control (RFC 9213) and Surrogate-Control, while the CDN still forwards
+// its client header such as CF-Connecting-IP. EdgeCacheVary lets shared caches keep responses
+// other than server errors for maxAge, keyed on those headers. It is only safe for caches that see
+// the client header, sitting behind the proxy that sets it, and for responses that depend on
+// nothing else. EdgeCacheOff leaves the headers alone.
+func EdgeCache(mode string, maxAge time.Duration, headers func() []string, next http.Handler) http.Handler {
+	if mode != EdgeCacheNoStore && mode != EdgeCacheVary {
+		return next
+	}
+	cacheControl := "private, no-store"
+	if mode == EdgeCacheVary {
+		cacheControl = "public, max-age=" + strconv.Itoa(int(maxAge/time.Second))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("Cache-Control", cacheControl)
+		if mode == EdgeCacheNoStore {
+			header.Set("CDN-Cache-Control", "no-store")
+			header.Set("Surrogate-Control", "no-store")
+		}
+		header.Add("Vary", strings.Join(headers(), ", ")+", Accept")
+		if mode == EdgeCacheVary {
+			w = &uncachedErrors{ResponseWriter: w}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// uncachedErrors marks server error responses as not storable
+type uncachedErrors struct {
+	http.ResponseWriter
+}
+
+// WriteHeader replaces Cache-Control on server errors before delegating
+func (w *uncachedErrors) WriteHeader(code int) {
+	if code >= http.StatusInternalServerError {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *uncachedErrors) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
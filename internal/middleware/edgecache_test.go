@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEdgeCache(t *testing.T) {
+	status := http.StatusOK
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+	headers := func() []string { return []string{"CF-Connecting-IP", "X-Forwarded-For"} }
+
+	serve := func(mode string) http.Header {
+		rr := httptest.NewRecorder()
+		EdgeCache(mode, 90*time.Second, headers, next).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+		return rr.Header()
+	}
+
+	if header := serve(EdgeCacheOff); len(header) != 0 {
+		t.Errorf("Expected no headers when off, got %v", header)
+	}
+
+	header := serve(EdgeCacheNoStore)
+	if header.Get("Cache-Control") != "private, no-store" || header.Get("CDN-Cache-Control") != "no-store" ||
+		header.Get("Surrogate-Control") != "no-store" {
+		t.Errorf("Unexpected no-store headers %v", header)
+	}
+	if vary := header.Get("Vary"); vary != "CF-Connecting-IP, X-Forwarded-For, Accept" {
+		t.Errorf("Unexpected Vary %q", vary)
+	}
+
+	header = serve(EdgeCacheVary)
+	if header.Get("Cache-Control") != "public, max-age=90" || header.Get("CDN-Cache-Control") != "" {
+		t.Errorf("Unexpected vary headers %v", header)
+	}
+	if vary := header.Get("Vary"); vary != "CF-Connecting-IP, X-Forwarded-For, Accept" {
+		t.Errorf("Unexpected Vary %q", vary)
+	}
+
+	status = http.StatusServiceUnavailable
+	if cacheControl := serve(EdgeCacheVary).Get("Cache-Control"); cacheControl != "no-store" {
+		t.Errorf("Expected server errors not to be stored, got %q", cacheControl)
+	}
+}
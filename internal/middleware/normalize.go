@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Path normalization modes
+const (
+	// NormalizeRewrite routes non-canonical paths internally as if the canonical path was requested
+	NormalizeRewrite = "rewrite"
+	// NormalizeRedirect redirects non-canonical paths to the canonical path
+	NormalizeRedirect = "redirect"
+	// NormalizeOff disables path normalization
+	NormalizeOff = "off"
+)
+
+// canonicalPath lowercases the path, collapses repeated slashes, and strips a trailing slash
+func canonicalPath(path string) string {
+	var b strings.Builder
+	b.Grow(len(path))
+
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '/' && b.Len() > 0 && path[i-1] == '/' {
+			continue
+		}
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		b.WriteByte(c)
+	}
+
+	canonical := b.String()
+	if len(canonical) > 1 {
+		canonical = strings.TrimSuffix(canonical, "/")
+	}
+	return canonical
+}
+
+// NormalizePath makes /ipv6/, /IPv6, and //ipv6 resolve the same as /ipv6, either by rewriting
+// the request path or by redirecting (301 for GET/HEAD, 308 otherwise so the method is kept).
+// Paths under any of the exempt prefixes are passed through unchanged.
+func NormalizePath(mode string, exempt []string, next http.Handler) http.Handler {
+	if mode == NormalizeOff {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range exempt {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		canonical := canonicalPath(r.URL.Path)
+		if canonical == r.URL.Path {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if mode == NormalizeRedirect {
+			target := *r.URL
+			target.Path = canonical
+			target.RawPath = ""
+
+			status := http.StatusMovedPermanently
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				status = http.StatusPermanentRedirect
+			}
+			http.Redirect(w, r, target.RequestURI(), status)
+			return
+		}
+
+		rewritten := r.Clone(r.Context())
+		rewritten.URL.Path = canonical
+		rewritten.URL.RawPath = ""
+		next.ServeHTTP(w, rewritten)
+	})
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCanonicalPath(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"/", "/"},
+		{"/ipv6", "/ipv6"},
+		{"/ipv6/", "/ipv6"},
+		{"/IPv6", "/ipv6"},
+		{"/IPV6/", "/ipv6"},
+		{"//ipv6", "/ipv6"},
+		{"/admin//boot-report/", "/admin/boot-report"},
+		{"//", "/"},
+	}
+
+	for _, test := range tests {
+		if result := canonicalPath(test.input); result != test.expected {
+			t.Errorf("canonicalPath(%q) = %q, expected %q", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	var seenPath string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenPath = r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name             string
+		mode             string
+		method           string
+		target           string
+		expectedCode     int
+		expectedPath     string
+		expectedLocation string
+	}{
+		{"Rewrite trailing slash", NormalizeRewrite, "GET", "/ipv6/", http.StatusOK, "/ipv6", ""},
+		{"Rewrite case", NormalizeRewrite, "GET", "/IPv6?format=json", http.StatusOK, "/ipv6", ""},
+		{"Canonical untouched", NormalizeRewrite, "GET", "/ipv6", http.StatusOK, "/ipv6", ""},
+		{"Exempt prefix", NormalizeRewrite, "GET", "/swagger/Index.html", http.StatusOK, "/swagger/Index.html", ""},
+		{"Redirect GET", NormalizeRedirect, "GET", "/IPv6/?format=json", http.StatusMovedPermanently, "", "/ipv6?format=json"},
+		{"Redirect POST keeps method", NormalizeRedirect, "POST", "/Admin/maintenance", http.StatusPermanentRedirect, "", "/admin/maintenance"},
+		{"Off", NormalizeOff, "GET", "/IPv6/", http.StatusOK, "/IPv6/", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			seenPath = ""
+			rr := httptest.NewRecorder()
+			NormalizePath(test.mode, []string{"/swagger/"}, next).ServeHTTP(rr, httptest.NewRequest(test.method, test.target, nil))
+
+			if rr.Code != test.expectedCode {
+				t.Errorf("Expected status %d, got %d", test.expectedCode, rr.Code)
+			}
+			if seenPath != test.expectedPath {
+				t.Errorf("Expected handler to see path %q, got %q", test.expectedPath, seenPath)
+			}
+			if location := rr.Header().Get("Location"); location != test.expectedLocation {
+				t.Errorf("Expected Location %q, got %q", test.expectedLocation, location)
+			}
+		})
+	}
+}
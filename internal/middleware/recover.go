@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"myip/internal/logging"
+	"myip/internal/problem"
+	"myip/internal/requestid"
+)
+
+// Recover turns a panicking handler into a 500 problem response and logs the panic with its stack
+// trace and request ID, instead of dropping the connection. http.ErrAbortHandler is re-raised so
+// handlers can still abort a response deliberately.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			logging.Errorf("Panic serving %s %s (request %s): %v\n%s",
+				r.Method, r.URL.Path, requestid.FromContext(r.Context()), v, debug.Stack())
+			problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
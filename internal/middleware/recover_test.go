@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"myip/internal/requestid"
+)
+
+func TestRecover(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	handler := requestid.Middleware(Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.Header.Set(requestid.Header, "req-123")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", rr.Code)
+	}
+	if rr.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("Expected a problem response, got %q", rr.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rr.Body.String(), "req-123") {
+		t.Errorf("Expected the request ID in the response, got %q", rr.Body.String())
+	}
+	if logged := buf.String(); !strings.Contains(logged, "boom") || !strings.Contains(logged, "req-123") {
+		t.Errorf("Expected the panic and request ID to be logged, got %q", logged)
+	}
+}
+
+func TestRecoverReraisesAbort(t *testing.T) {
+	handler := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if recover() != http.ErrAbortHandler {
+			t.Error("Expected http.ErrAbortHandler to propagate")
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusRecorder(t *testing.T) {
+	rr := httptest.NewRecorder()
+	rec := NewStatusRecorder(rr)
+
+	if rec.Status != http.StatusOK {
+		t.Errorf("Expected default status 200, got %d", rec.Status)
+	}
+
+	rec.WriteHeader(http.StatusTeapot)
+
+	if rec.Status != http.StatusTeapot {
+		t.Errorf("Expected recorded status 418, got %d", rec.Status)
+	}
+
+	if rr.Code != http.StatusTeapot {
+		t.Errorf("Expected status to be forwarded, got %d", rr.Code)
+	}
+
+	if rec.Unwrap() != rr {
+		t.Error("Expected Unwrap to return the underlying writer")
+	}
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"myip/internal/problem"
+)
+
+// Budgets holds the time each request may take: Default for every path without its own entry in
+// Paths. A budget of zero or less leaves requests without a deadline.
+type Budgets struct {
+	Default time.Duration
+	Paths   map[string]time.Duration
+}
+
+// ParseBudgets parses "path=duration" entries such as "/whois=8s" overriding the default budget
+func ParseBudgets(defaultBudget time.Duration, entries []string) (Budgets, error) {
+	budgets := Budgets{Default: defaultBudget, Paths: make(map[string]time.Duration, len(entries))}
+	for _, entry := range entries {
+		path, value, ok := strings.Cut(entry, "=")
+		path, value = strings.TrimSpace(path), strings.TrimSpace(value)
+		if !ok || !strings.HasPrefix(path, "/") {
+			return Budgets{}, fmt.Errorf("invalid request timeout %q, expected /path=duration", entry)
+		}
+		budget, err := time.ParseDuration(value)
+		if err != nil || budget < 0 {
+			return Budgets{}, fmt.Errorf("invalid request timeout %q for %s", value, path)
+		}
+		budgets.Paths[path] = budget
+	}
+	return budgets, nil
+}
+
+// For returns the budget of requests for path
+func (b Budgets) For(path string) time.Duration {
+	if budget, ok := b.Paths[path]; ok {
+		return budget
+	}
+	return b.Default
+}
+
+// Timeout gives each request a context deadline of its path's budget, so lookups made with
+// r.Context() give up in time. Handlers answer a request that ran out of time themselves, with
+// 504 Gateway Timeout for a failed upstream lookup; when a handler returns without writing
+// anything after the deadline, Timeout answers 504 for it.
+func Timeout(budgets Budgets, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		budget := budgets.For(r.URL.Path)
+		if budget <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), budget)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		tw := &timeoutWriter{ResponseWriter: w}
+		next.ServeHTTP(tw, r)
+
+		if !tw.written && timedOut(r) {
+			problem.Error(w, r, http.StatusGatewayTimeout, fmt.Sprintf("Request exceeded its %v budget", budget))
+		}
+	})
+}
+
+// timedOut reports whether the request's deadline has passed
+func timedOut(r *http.Request) bool {
+	return errors.Is(r.Context().Err(), context.DeadlineExceeded)
+}
+
+// timeoutWriter records whether the handler started a response
+type timeoutWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+// WriteHeader marks the response as started before delegating
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write marks the response as started before delegating
+func (w *timeoutWriter) Write(p []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *timeoutWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
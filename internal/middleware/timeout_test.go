@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBudgets(t *testing.T) {
+	budgets, err := ParseBudgets(10*time.Second, []string{"/whois=8s", " /hostname = 2s ", "/ping=0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		expected time.Duration
+	}{
+		{"/whois", 8 * time.Second},
+		{"/hostname", 2 * time.Second},
+		{"/ping", 0},
+		{"/json", 10 * time.Second},
+	}
+	for _, test := range tests {
+		if budget := budgets.For(test.path); budget != test.expected {
+			t.Errorf("For(%q) = %v, expected %v", test.path, budget, test.expected)
+		}
+	}
+
+	for _, invalid := range []string{"/whois", "whois=8s", "/whois=soon", "/whois=-1s"} {
+		if _, err := ParseBudgets(0, []string{invalid}); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	budgets := Budgets{Default: 20 * time.Millisecond, Paths: map[string]time.Duration{"/unbounded": 0}}
+
+	tests := []struct {
+		name         string
+		path         string
+		handler      http.HandlerFunc
+		expectedCode int
+	}{
+		{"Within budget", "/json", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}, http.StatusOK},
+		{"Handler gives up silently", "/json", func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}, http.StatusGatewayTimeout},
+		{"Handler answers the timeout itself", "/json", func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			w.WriteHeader(http.StatusBadGateway)
+		}, http.StatusBadGateway},
+		{"No deadline", "/unbounded", func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Deadline(); ok {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			Timeout(budgets, test.handler).ServeHTTP(rr, httptest.NewRequest("GET", test.path, nil))
+
+			if rr.Code != test.expectedCode {
+				t.Errorf("Expected status %d, got %d", test.expectedCode, rr.Code)
+			}
+			if test.expectedCode == http.StatusGatewayTimeout && !strings.Contains(rr.Body.String(), "20ms budget") {
+				t.Errorf("Expected the budget in the problem detail, got %s", rr.Body.String())
+			}
+		})
+	}
+}
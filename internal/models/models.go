@@ -12,12 +12,200 @@ type IPInfo struct {
 	IsCloudflare bool   `json:"is_cloudflare"`
 	UserAgent    string `json:"user_agent"`
 	Timestamp    string `json:"timestamp"`
+
+	// Port is the client's source TCP port; omitted when the client IP came from a proxy header,
+	// since the connection's port then belongs to the proxy
+	Port int `json:"port,omitempty"`
+
+	// AllCandidates lists every distinct public IP found in the trusted headers and RemoteAddr, in
+	// detection order, for requests that traverse several NATs and proxies
+	AllCandidates []IPCandidate `json:"all_candidates,omitempty"`
+
+	// TunnelType names the transition mechanism carrying the IPv6 address (6to4, teredo, or
+	// isatap) and EmbeddedIPv4 the IPv4 address inside it; both are omitted for native IPv6
+	TunnelType   string `json:"tunnel_type,omitempty"`
+	EmbeddedIPv4 string `json:"embedded_ipv4,omitempty"`
+
+	// IPType is the network type of the client IP (residential, mobile, hosting, or vpn); omitted when unknown
+	IPType string `json:"ip_type,omitempty"`
+
+	// IsListed and ThreatFeeds report threat feed listings; both are omitted when reputation lists are disabled
+	IsListed    *bool    `json:"is_listed,omitempty"`
+	ThreatFeeds []string `json:"threat_feeds,omitempty"`
+
+	// CDN identifies the CDN request that carried the client's request; omitted when not behind a known CDN
+	CDN *CDNTrace `json:"cdn,omitempty"`
+
+	// Warning explains why the detected client IP looks wrong when strict validation is enabled,
+	// such as a private address forwarded by a public peer; omitted when nothing is suspicious
+	Warning string `json:"warning,omitempty"`
+
+	// SpoofingSuspected reports proxy headers contradicting the peer address or each other in ways
+	// no honest proxy chain produces, such as forwarding headers naming the server itself;
+	// SpoofingReasons explains each contradiction and is omitted when there are none
+	SpoofingSuspected bool     `json:"spoofing_suspected"`
+	SpoofingReasons   []string `json:"spoofing_reasons,omitempty"`
+
+	// Confidence rates how far ClientIP can be trusted, from the agreement between the peer
+	// address, the trusted headers, and the Cloudflare headers
+	Confidence *Confidence `json:"confidence,omitempty"`
+
+	// Hops is the reconstructed path of the request from the client to the server, and
+	// DetectionMs the time taken to detect the addresses above, both included with ?verbose=1
+	Hops        []Hop   `json:"hops,omitempty"`
+	DetectionMs float64 `json:"detection_ms,omitempty"`
+
+	// LocalTimestamp is Timestamp in the time zone requested with ?tz=, which Timezone names
+	LocalTimestamp string `json:"local_timestamp,omitempty"`
+	Timezone       string `json:"timezone,omitempty"`
+
+	// Enrichment holds provider sections keyed by provider name; Meta reports how they were produced
+	Enrichment map[string]any  `json:"enrichment,omitempty"`
+	Meta       *EnrichmentMeta `json:"meta,omitempty"`
+}
+
+// Confidence rates the detected client IP for API consumers deciding whether to trust it
+type Confidence struct {
+	// Score runs from 0, no basis for trusting the address, to 1
+	Score float64 `json:"score"`
+
+	// Level buckets Score: high from 0.8, medium from 0.5, and low below
+	Level string `json:"level"`
+
+	// Factors name the observations behind the score, such as trusted_proxy or conflicting_headers
+	Factors []string `json:"factors"`
+
+	// Fallback lists the headers consulted before DetectedVia that held no valid address
+	Fallback []string `json:"fallback,omitempty"`
+}
+
+// IPv6Analysis describes how an IPv6 address's interface identifier was formed, served by
+// /ipv6/analyze, with the tunnel it arrived through as in IPInfo. MAC and MACVendor are set for EUI-64 identifiers, which embed the interface's
+// hardware address; the vendor is omitted when unknown or the address is locally administered.
+type IPv6Analysis struct {
+	IP              string `json:"ip"`
+	Prefix64        string `json:"prefix_64"`
+	InterfaceID     string `json:"interface_id"`
+	InterfaceIDType string `json:"interface_id_type"`
+	PrivacyAddress  bool   `json:"privacy_address"`
+	TunnelType      string `json:"tunnel_type,omitempty"`
+	EmbeddedIPv4    string `json:"embedded_ipv4,omitempty"`
+
+	MAC                    string `json:"mac,omitempty"`
+	MACVendor              string `json:"mac_vendor,omitempty"`
+	MACLocallyAdministered bool   `json:"mac_locally_administered,omitempty"`
+}
+
+// NAT64Info reports whether an IPv6 address is in a NAT64 prefix and the IPv4 address it maps
+// to, served by /nat64. Prefixes lists the NAT64 prefixes recognized.
+type NAT64Info struct {
+	IP           string   `json:"ip"`
+	NAT64        bool     `json:"nat64"`
+	Prefix       string   `json:"prefix,omitempty"`
+	EmbeddedIPv4 string   `json:"embedded_ipv4,omitempty"`
+	Prefixes     []string `json:"prefixes"`
+}
+
+// ConnectivityTest is a URL the /connectivity page loads to test one address family: ipv4 and
+// ipv6 through hostnames resolving only to that family, dual-stack through the page's own host.
+// The URL serves /both as JSONP, with the callback name left to append.
+type ConnectivityTest struct {
+	Name   string `json:"name"`
+	Family string `json:"family"`
+	URL    string `json:"url"`
+}
+
+// ConnectivityPlan lists the tests the /connectivity page runs; Untested names the families
+// whose hostname is not configured
+type ConnectivityPlan struct {
+	Tests     []ConnectivityTest `json:"tests"`
+	Untested  []string           `json:"untested"`
+	TimeoutMs int                `json:"timeout_ms"`
+}
+
+// DetectedIP is the address served by / or /ipv6 with ?verbose=1, with the source it was taken
+// from, every candidate considered, and the time taken to detect them
+type DetectedIP struct {
+	IP            string        `json:"ip"`
+	DetectedVia   string        `json:"detected_via"`
+	AllCandidates []IPCandidate `json:"all_candidates"`
+	DetectionMs   float64       `json:"detection_ms"`
+}
+
+// IPv6Forms is the canonical representations of an IPv6 address, served by /ipv6/expand
+type IPv6Forms struct {
+	IP         string `json:"ip"`
+	Compressed string `json:"compressed"`
+	Expanded   string `json:"expanded"`
+	Prefix64   string `json:"prefix_64"`
+}
+
+// DualStack is the client's IPv4 and IPv6 addresses, served by /both; an address the request
+// did not carry is null
+type DualStack struct {
+	IPv4 *string `json:"ipv4"`
+	IPv6 *string `json:"ipv6"`
+}
+
+// HeadersResponse is the request headers and connection details, served by /headers?format=json.
+// Headers are keyed by canonical name and limited to those matching ?filter= when given.
+type HeadersResponse struct {
+	ClientIP    string              `json:"client_ip"`
+	DetectedVia string              `json:"detected_via"`
+	Headers     map[string][]string `json:"headers"`
+	RemoteAddr  string              `json:"remote_addr"`
+	Method      string              `json:"method"`
+	URL         string              `json:"url"`
+	Protocol    string              `json:"protocol"`
+}
+
+// IPCandidate is a public IP found in the request and where it was found: a header name or "RemoteAddr"
+type IPCandidate struct {
+	IP     string `json:"ip"`
+	Source string `json:"source"`
+}
+
+// Hop is one address on the path of a request: the client first, then each proxy, ending with
+// the peer that connected to the server
+type Hop struct {
+	IP      string `json:"ip"`
+	Source  string `json:"source"`
+	Class   string `json:"class"`
+	Trusted bool   `json:"trusted"`
+	Via     string `json:"via,omitempty"`
+}
+
+// CDNTrace is the request ID assigned by the CDN in front of the service, for correlating its logs
+type CDNTrace struct {
+	Provider string `json:"provider"`
+	RayID    string `json:"ray_id"`
 }
 
 // HealthResponse represents the health check response
 type HealthResponse struct {
 	Status    string `json:"status"`
 	Timestamp string `json:"timestamp"`
+
+	// Runtime statistics, reported by /health only
+	UptimeSeconds float64        `json:"uptime_seconds,omitempty"`
+	Goroutines    int            `json:"goroutines,omitempty"`
+	Memory        *MemoryStats   `json:"memory,omitempty"`
+	Requests      *RequestTotals `json:"requests,omitempty"`
+}
+
+// MemoryStats summarizes the Go runtime's memory use
+type MemoryStats struct {
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	HeapObjects    uint64 `json:"heap_objects"`
+	SysBytes       uint64 `json:"sys_bytes"`
+	NumGC          uint32 `json:"num_gc"`
+}
+
+// RequestTotals counts the requests to the service endpoints since the process started, and
+// those answered with a 5xx status
+type RequestTotals struct {
+	Total  int64 `json:"total"`
+	Errors int64 `json:"errors"`
 }
 
 // NewHealthResponse creates a new health response with current timestamp
@@ -27,3 +215,362 @@ func NewHealthResponse(status string) *HealthResponse {
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
 	}
 }
+
+// PingResponse is the server's receive time for a /ping request, with the client's send time
+// echoed back when given
+type PingResponse struct {
+	// ServerTime is when the server received the request, in RFC 3339 form with nanoseconds and as
+	// Unix milliseconds
+	ServerTime   string `json:"server_time"`
+	ServerTimeMs int64  `json:"server_time_ms"`
+
+	// ClientTimeMs echoes the client's send time, and OneWayMs is the time from it to ServerTime.
+	// The estimate includes any offset between the two clocks; subtracting ClientTimeMs from the
+	// time the response arrives gives the round-trip time without that error.
+	ClientTimeMs *int64   `json:"client_time_ms,omitempty"`
+	OneWayMs     *float64 `json:"one_way_ms,omitempty"`
+}
+
+// TimeResponse is the server's time when a /time request was received, with the skew of the
+// client's clock when it sent its own
+type TimeResponse struct {
+	// UTC is the receive time in RFC 3339 form with nanoseconds; HTTPDate matches the Date header
+	UTC      string `json:"utc"`
+	Unix     int64  `json:"unix"`
+	UnixMs   int64  `json:"unix_ms"`
+	HTTPDate string `json:"http_date"`
+	RFC1123Z string `json:"rfc1123z"`
+
+	// SkewMs is the client time minus the receive time, positive when the client clock is ahead.
+	// It includes the request's one-way latency. Synchronized is false beyond 2 seconds.
+	ClientTime   string   `json:"client_time,omitempty"`
+	SkewMs       *float64 `json:"skew_ms,omitempty"`
+	Synchronized *bool    `json:"synchronized,omitempty"`
+}
+
+// ConfigSetting is the effective value of a configuration key and where it came from: "env",
+// "file", "flag", or "default"
+type ConfigSetting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// ConfigDump is the effective configuration served by /admin/config, with secrets masked
+type ConfigDump struct {
+	Hash     string          `json:"hash"`
+	File     string          `json:"file,omitempty"`
+	Settings []ConfigSetting `json:"settings"`
+}
+
+// MaintenanceStatus represents the maintenance mode admin response
+type MaintenanceStatus struct {
+	Enabled    bool   `json:"enabled"`
+	Message    string `json:"message"`
+	RetryAfter int    `json:"retry_after_seconds"`
+	Since      string `json:"since,omitempty"`
+}
+
+// DNSResponse represents the result of resolving a hostname from the server's vantage point
+type DNSResponse struct {
+	Hostname      string   `json:"hostname"`
+	IPv4Addresses []string `json:"ipv4_addresses"`
+	IPv6Addresses []string `json:"ipv6_addresses"`
+	CNAME         string   `json:"cname,omitempty"`
+	MX            []string `json:"mx,omitempty"`
+	TXT           []string `json:"txt,omitempty"`
+	Timestamp     string   `json:"timestamp"`
+}
+
+// HostnameResponse is the reverse DNS name of the client IP, served by /hostname
+type HostnameResponse struct {
+	IP string `json:"ip"`
+
+	// Hostname is the first PTR name in ASCII (punycode) form, and Unicode its decoded form.
+	// Display is the form safe to show to users: Unicode unless SafeDisplay is false.
+	Hostname string `json:"hostname"`
+	Unicode  string `json:"unicode"`
+	Display  string `json:"display"`
+
+	// IsIDN reports whether the name has internationalized labels, Valid whether it is a
+	// well-formed hostname whose labels decode, and SafeDisplay whether its Unicode form is free of
+	// invisible characters and mixed scripts
+	IsIDN       bool `json:"is_idn"`
+	Valid       bool `json:"valid"`
+	SafeDisplay bool `json:"safe_display"`
+
+	// Aliases lists any further PTR names for the IP in ASCII form
+	Aliases   []string `json:"aliases,omitempty"`
+	Timestamp string   `json:"timestamp"`
+}
+
+// BootReport is the machine-readable startup report emitted to the log and served at /admin/boot-report
+type BootReport struct {
+	Version    string          `json:"version"`
+	Commit     string          `json:"commit"`
+	BuildDate  string          `json:"build_date"`
+	GoVersion  string          `json:"go_version"`
+	StartedAt  string          `json:"started_at"`
+	Transports []BootTransport `json:"transports"`
+	Endpoints  []string        `json:"endpoints"`
+	Datasets   []BootDataset   `json:"datasets"`
+	ConfigHash string          `json:"config_hash"`
+}
+
+// BootTransport describes a listener enabled at startup
+type BootTransport struct {
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+}
+
+// BootDataset describes a dataset loaded at startup
+type BootDataset struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	LoadedAt   string `json:"loaded_at"`
+	AgeSeconds int64  `json:"age_seconds"`
+}
+
+// SLOReport represents the current service level indicators against the configured objectives
+type SLOReport struct {
+	AvailabilityTarget float64     `json:"availability_target"`
+	LatencyTargetMs    float64     `json:"latency_target_ms"`
+	Windows            []SLOWindow `json:"windows"`
+	Timestamp          string      `json:"timestamp"`
+}
+
+// SLOWindow holds the indicators for a single rolling window
+type SLOWindow struct {
+	Window               string  `json:"window"`
+	Requests             int64   `json:"requests"`
+	Errors               int64   `json:"errors"`
+	Availability         float64 `json:"availability"`
+	ErrorBudgetBurnRate  float64 `json:"error_budget_burn_rate"`
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"`
+	LatencyP99Ms         float64 `json:"latency_p99_ms"`
+	SlowRequests         int64   `json:"slow_requests"`
+	LatencyBurnRate      float64 `json:"latency_burn_rate"`
+}
+
+// StatsReport counts the requests to the IP detection endpoints over a sliding window, served by
+// /stats; each dimension lists its largest entries first
+type StatsReport struct {
+	Window           string       `json:"window"`
+	Requests         int64        `json:"requests"`
+	Countries        []StatsCount `json:"countries"`
+	ASNs             []StatsCount `json:"asns"`
+	DetectionMethods []StatsCount `json:"detection_methods"`
+	Formats          []StatsCount `json:"formats"`
+	Timestamp        string       `json:"timestamp"`
+}
+
+// StatsCount is the number of requests for one country, ASN, detection method, or format
+type StatsCount struct {
+	Name     string `json:"name"`
+	Requests int64  `json:"requests"`
+}
+
+// ClusterNode is a replica in the cluster as published by its last heartbeat. Status is "ok" or
+// "maintenance"; Self marks the node serving the response.
+type ClusterNode struct {
+	ID         string `json:"id"`
+	URL        string `json:"url,omitempty"`
+	Version    string `json:"version"`
+	Status     string `json:"status"`
+	ConfigHash string `json:"config_hash"`
+	StartedAt  string `json:"started_at"`
+	LastSeen   string `json:"last_seen"`
+	Self       bool   `json:"self,omitempty"`
+}
+
+// ClusterStatus lists the replicas sharing the cluster state, served by /cluster.
+// ConfigConsistent reports whether they all run the same configuration.
+type ClusterStatus struct {
+	Node             string        `json:"node"`
+	ConfigConsistent bool          `json:"config_consistent"`
+	Nodes            []ClusterNode `json:"nodes"`
+}
+
+// LogLevelStatus represents the runtime log configuration served by /admin/loglevel
+type LogLevelStatus struct {
+	Level        string   `json:"level"`
+	DebugModules []string `json:"debug_modules"`
+}
+
+// Problem is an RFC 9457 problem details error response
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ErrorResponse is the error body served to requests that negotiated the JSON or JSONP format
+type ErrorResponse struct {
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	Status    int    `json:"status"`
+	RequestID string `json:"request_id,omitempty"`
+
+	// Endpoints lists the registered routes as "METHOD /path", in responses to unknown paths
+	Endpoints []string `json:"endpoints,omitempty"`
+}
+
+// Machine-readable error codes reported in ErrorResponse.Error
+const (
+	ErrorIPv4NotFound          = "ipv4_not_found"
+	ErrorIPv6NotFound          = "ipv6_not_found"
+	ErrorPortUnavailable       = "port_unavailable"
+	ErrorEncodingFailed        = "encoding_failed"
+	ErrorEnrichmentUnavailable = "enrichment_unavailable"
+	ErrorInvalidFields         = "invalid_fields"
+	ErrorInvalidIP             = "invalid_ip"
+	ErrorInvalidTimezone       = "invalid_timezone"
+	ErrorNotFound              = "not_found"
+	ErrorMethodNotAllowed      = "method_not_allowed"
+)
+
+// RouteInfo documents a registered route, served by /routes
+type RouteInfo struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Description string `json:"description"`
+	Auth        string `json:"auth"`
+	RateLimit   string `json:"rate_limit"`
+	Stability   string `json:"stability"`
+
+	// Examples are query strings demonstrating the route, such as "?format=json"
+	Examples []string `json:"examples,omitempty"`
+}
+
+// EnrichmentMeta describes how each enrichment section of a response was produced
+type EnrichmentMeta struct {
+	Degraded bool                `json:"degraded"`
+	Sections []EnrichmentSection `json:"sections"`
+}
+
+// EnrichmentSection is the outcome of a single enrichment provider lookup
+type EnrichmentSection struct {
+	Name       string `json:"name"`
+	Policy     string `json:"policy"`
+	State      string `json:"state"`
+	AgeSeconds int64  `json:"age_seconds,omitempty"`
+}
+
+// ReadinessStatus represents the readiness probe response
+type ReadinessStatus struct {
+	Status      string              `json:"status"`
+	Providers   []ProviderStatus    `json:"providers"`
+	ProxyRanges []ProxyRangesStatus `json:"proxy_ranges,omitempty"`
+	Timestamp   string              `json:"timestamp"`
+}
+
+// ProxyRangesStatus is the refresh state of a proxy profile's published ranges. Source is
+// "published" once a feed was fetched and "bundled" while the built-in ranges are in use.
+type ProxyRangesStatus struct {
+	Profile     string `json:"profile"`
+	Source      string `json:"source"`
+	Ranges      int    `json:"ranges"`
+	RefreshedAt string `json:"refreshed_at,omitempty"`
+	LastError   string `json:"last_error,omitempty"`
+	LastFailure string `json:"last_failure,omitempty"`
+}
+
+// ProviderStatus is the health of a single enrichment provider
+type ProviderStatus struct {
+	Name        string `json:"name"`
+	Policy      string `json:"policy"`
+	State       string `json:"state"`
+	LastError   string `json:"last_error,omitempty"`
+	LastFailure string `json:"last_failure,omitempty"`
+}
+
+// WhoisResponse is the registry information for an IP address, from an RDAP query
+type WhoisResponse struct {
+	IP           string `json:"ip"`
+	Handle       string `json:"handle,omitempty"`
+	NetworkName  string `json:"network_name"`
+	Country      string `json:"country,omitempty"`
+	StartAddress string `json:"start_address,omitempty"`
+	EndAddress   string `json:"end_address,omitempty"`
+	AbuseContact string `json:"abuse_contact,omitempty"`
+	Registry     string `json:"registry,omitempty"`
+	Timestamp    string `json:"timestamp"`
+}
+
+// GeoResponse is the country of an IP address, served by /geo
+type GeoResponse struct {
+	IP        string `json:"ip"`
+	Country   string `json:"country"`
+	ASN       uint32 `json:"asn,omitempty"`
+	Source    string `json:"source"`
+	Timestamp string `json:"timestamp"`
+}
+
+// VersionInfo describes the build, served by /version
+type VersionInfo struct {
+	Version   string   `json:"version"`
+	Commit    string   `json:"commit"`
+	BuildDate string   `json:"build_date"`
+	GoVersion string   `json:"go_version"`
+	Profile   string   `json:"profile"`
+	Features  []string `json:"features"`
+}
+
+// AboutInfo is the metadata the operator publishes for the instance, served by /about. Empty
+// fields are not configured.
+type AboutInfo struct {
+	Name             string     `json:"name,omitempty"`
+	ContactEmail     string     `json:"contact_email,omitempty"`
+	PrivacyPolicyURL string     `json:"privacy_policy_url,omitempty"`
+	RateLimits       RateLimits `json:"rate_limits"`
+}
+
+// RateLimits are the limits clients of the instance are held to, 0 meaning unlimited: lookups
+// through /dns and /hostname per client IP, and requests handled at once in all and per client IP
+type RateLimits struct {
+	DNSLookupsPerMinute int `json:"dns_lookups_per_minute"`
+	MaxInFlight         int `json:"max_in_flight"`
+	MaxInFlightPerIP    int `json:"max_in_flight_per_ip"`
+}
+
+// RequestEvent summarizes an answered request, streamed by /events
+type RequestEvent struct {
+	Time      string `json:"time"`
+	RequestID string `json:"request_id,omitempty"`
+	IP        string `json:"ip"`
+
+	// Country is the client's country code, omitted when unknown or without an ASN database
+	Country string `json:"country,omitempty"`
+
+	Method     string  `json:"method"`
+	Endpoint   string  `json:"endpoint"`
+	Status     int     `json:"status"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// CapturedRequest is a sanitized recent request to the IP detection endpoints with the detection
+// result, served by /debug/requests
+type CapturedRequest struct {
+	Time        string              `json:"time"`
+	RequestID   string              `json:"request_id,omitempty"`
+	Method      string              `json:"method"`
+	Path        string              `json:"path"`
+	RemoteAddr  string              `json:"remote_addr"`
+	Headers     map[string][]string `json:"headers"`
+	Status      int                 `json:"status"`
+	ClientIP    string              `json:"client_ip"`
+	DetectedVia string              `json:"detected_via"`
+	IPv4Address string              `json:"ipv4_address,omitempty"`
+	IPv6Address string              `json:"ipv6_address,omitempty"`
+
+	SpoofingReasons []string `json:"spoofing_reasons,omitempty"`
+}
+
+// CapturedRequests lists the captured requests, newest first
+type CapturedRequests struct {
+	Capacity int               `json:"capacity"`
+	Requests []CapturedRequest `json:"requests"`
+}
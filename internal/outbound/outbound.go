@@ -0,0 +1,93 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Address family preferences for outbound connections
+const (
+	// PreferAuto leaves address selection to the Go dialer
+	PreferAuto = "auto"
+	// PreferIPv6 tries AAAA addresses before A addresses, for IPv6-only hosts behind NAT64
+	PreferIPv6 = "ipv6"
+	// PreferIPv4 tries A addresses before AAAA addresses
+	PreferIPv4 = "ipv4"
+)
+
+// Resolver is the subset of net.Resolver used to order addresses
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// errNoAddresses is returned when a host resolves to no addresses
+var errNoAddresses = errors.New("no addresses for host")
+
+// Order returns addrs with the preferred family first, keeping the resolver order within each family
+func Order(addrs []net.IPAddr, preference string) []net.IPAddr {
+	if preference != PreferIPv6 && preference != PreferIPv4 {
+		return addrs
+	}
+
+	ordered := make([]net.IPAddr, 0, len(addrs))
+	var rest []net.IPAddr
+	for _, addr := range addrs {
+		isIPv6 := addr.IP.To4() == nil
+		if isIPv6 == (preference == PreferIPv6) {
+			ordered = append(ordered, addr)
+		} else {
+			rest = append(rest, addr)
+		}
+	}
+	return append(ordered, rest...)
+}
+
+// DialContext returns a dial function that connects to the addresses of the preferred
+// family first, falling back to the other family when none of them answer
+func DialContext(preference string, resolver Resolver, timeout time.Duration) func(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	if preference != PreferIPv6 && preference != PreferIPv4 {
+		return dialer.DialContext
+	}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+
+		addrs, err := resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(addrs) == 0 {
+			return nil, errNoAddresses
+		}
+
+		var firstErr error
+		for _, addr := range Order(addrs, preference) {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr.IP.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, firstErr
+	}
+}
+
+// NewHTTPClient creates an HTTP client for outbound requests that honors the address family preference
+func NewHTTPClient(preference string, timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = DialContext(preference, net.DefaultResolver, timeout)
+
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
@@ -0,0 +1,90 @@
+package outbound
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// staticResolver resolves every host to the same addresses
+type staticResolver []net.IPAddr
+
+func (r staticResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return r, nil
+}
+
+func addrs(ips ...string) []net.IPAddr {
+	var result []net.IPAddr
+	for _, ip := range ips {
+		result = append(result, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return result
+}
+
+func TestOrder(t *testing.T) {
+	mixed := addrs("192.0.2.1", "2001:db8::1", "192.0.2.2", "2001:db8::2")
+
+	tests := []struct {
+		preference string
+		expected   []string
+	}{
+		{PreferAuto, []string{"192.0.2.1", "2001:db8::1", "192.0.2.2", "2001:db8::2"}},
+		{PreferIPv6, []string{"2001:db8::1", "2001:db8::2", "192.0.2.1", "192.0.2.2"}},
+		{PreferIPv4, []string{"192.0.2.1", "192.0.2.2", "2001:db8::1", "2001:db8::2"}},
+	}
+
+	for _, test := range tests {
+		ordered := Order(mixed, test.preference)
+		for i, addr := range ordered {
+			if addr.IP.String() != test.expected[i] {
+				t.Errorf("%s: expected %v, got %v", test.preference, test.expected, ordered)
+				break
+			}
+		}
+	}
+}
+
+func TestDialContextFallsBack(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("IPv4 loopback not available: %v", err)
+	}
+	defer listener.Close()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	// The preferred IPv6 address refuses connections; the IPv4 address answers
+	dial := DialContext(PreferIPv6, staticResolver(addrs("::1", "127.0.0.1")), time.Second)
+	conn, err := dial(context.Background(), "tcp", net.JoinHostPort("registry.example", port))
+	if err != nil {
+		t.Fatalf("Expected fallback to IPv4, got %v", err)
+	}
+	conn.Close()
+}
+
+func TestNewHTTPClientIPv6Loopback(t *testing.T) {
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback not available: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.RemoteAddr))
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	resp, err := NewHTTPClient(PreferIPv6, time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request over IPv6 failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.StatusCode)
+	}
+}
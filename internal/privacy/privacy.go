@@ -0,0 +1,107 @@
+// Package privacy implements data minimization for operators subject to GDPR-style rules: client
+// addresses written to logs and statistics are truncated to their /24 (IPv4) or /48 (IPv6)
+// network, and the User-Agent can be left out of responses.
+package privacy
+
+import (
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Prefix lengths addresses are truncated to, identifying a network rather than a subscriber
+const (
+	IPv4Bits = 24
+	IPv6Bits = 48
+)
+
+var (
+	truncate      atomic.Bool
+	omitUserAgent atomic.Bool
+)
+
+// Configure sets whether addresses are truncated and whether the User-Agent is omitted. It is
+// safe to call while requests are being served.
+func Configure(truncateIPs, omitUA bool) {
+	truncate.Store(truncateIPs)
+	omitUserAgent.Store(omitUA)
+}
+
+// Enabled reports whether addresses are truncated
+func Enabled() bool {
+	return truncate.Load()
+}
+
+// OmitUserAgent reports whether the User-Agent is left out of responses
+func OmitUserAgent() bool {
+	return omitUserAgent.Load()
+}
+
+// IP returns ip truncated to its network address, such as 203.0.113.0 for 203.0.113.7 or
+// 2001:db8:85a3:: for 2001:db8:85a3:8d3::1, when truncation is enabled. Other values are returned
+// unchanged.
+func IP(ip string) string {
+	if !Enabled() {
+		return ip
+	}
+	return Truncate(ip)
+}
+
+// Truncate returns ip truncated to its /24 or /48 network address, or ip unchanged when it is not
+// an address
+func Truncate(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if ip4 := parsed.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(IPv4Bits, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(IPv6Bits, 128)).String()
+}
+
+// candidatePattern matches runs of characters that may hold an address, with an optional port
+var candidatePattern = regexp.MustCompile(`[0-9A-Fa-f]*[:.][0-9A-Fa-f:.]*`)
+
+// Text truncates every address in s, including the host of host:port pairs, when truncation is
+// enabled
+func Text(s string) string {
+	if !Enabled() {
+		return s
+	}
+	return candidatePattern.ReplaceAllStringFunc(s, func(match string) string {
+		// Punctuation ending a sentence is not part of the address
+		candidate := strings.TrimRight(match, ".:")
+		suffix := match[len(candidate):]
+		if net.ParseIP(candidate) != nil {
+			return Truncate(candidate) + suffix
+		}
+		if host, port, err := net.SplitHostPort(candidate); err == nil && net.ParseIP(host) != nil {
+			return net.JoinHostPort(Truncate(host), port) + suffix
+		}
+		return match
+	})
+}
+
+// Writer returns a writer passing each write to w through Text, for use with log.SetOutput; the
+// log package writes one line per call
+func Writer(w io.Writer) io.Writer {
+	return writer{w}
+}
+
+type writer struct {
+	w io.Writer
+}
+
+// Write writes p with its addresses truncated, reporting the length of p on success
+func (w writer) Write(p []byte) (int, error) {
+	if !Enabled() {
+		return w.w.Write(p)
+	}
+	if _, err := io.WriteString(w.w, Text(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,64 @@
+package privacy
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{"203.0.113.7", "203.0.113.0"},
+		{"::ffff:203.0.113.7", "203.0.113.0"},
+		{"2001:db8:85a3:8d3::1", "2001:db8:85a3::"},
+		{"2001:db8::1", "2001:db8::"},
+		{"garbage", "garbage"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if result := Truncate(test.ip); result != test.expected {
+			t.Errorf("Truncate(%q) = %q, expected %q", test.ip, result, test.expected)
+		}
+	}
+}
+
+func TestIPAndText(t *testing.T) {
+	defer Configure(false, false)
+
+	line := "PTR lookup for 203.0.113.7 failed; peer [2001:db8:85a3:8d3::1]:443 via 198.51.100.2:8080 at 12:00:01 in 0.41ms, from 192.0.2.9."
+	if got := Text(line); got != line {
+		t.Errorf("Expected text unchanged while disabled, got %q", got)
+	}
+	if got := IP("203.0.113.7"); got != "203.0.113.7" {
+		t.Errorf("Expected the address unchanged while disabled, got %q", got)
+	}
+
+	Configure(true, false)
+	want := "PTR lookup for 203.0.113.0 failed; peer [2001:db8:85a3::]:443 via 198.51.100.0:8080 at 12:00:01 in 0.41ms, from 192.0.2.0."
+	if got := Text(line); got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+	if got := IP("203.0.113.7"); got != "203.0.113.0" {
+		t.Errorf("IP() = %q, want 203.0.113.0", got)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	defer Configure(false, false)
+	Configure(true, true)
+
+	var buf bytes.Buffer
+	logger := log.New(Writer(&buf), "", 0)
+	logger.Printf("Client IP %s detected via %s", "203.0.113.7", "X-Forwarded-For")
+
+	if got := buf.String(); got != "Client IP 203.0.113.0 detected via X-Forwarded-For\n" {
+		t.Errorf("Unexpected log line %q", got)
+	}
+	if !OmitUserAgent() {
+		t.Error("Expected the User-Agent to be omitted")
+	}
+}
@@ -0,0 +1,104 @@
+package problem
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"myip/internal/models"
+	"myip/internal/requestid"
+)
+
+// ContentType is the media type of problem detail responses
+const ContentType = "application/problem+json"
+
+// DefaultRetryAfter is advertised on 5xx and 429 responses that do not specify their own retry hint
+const DefaultRetryAfter = 5 * time.Second
+
+// options collects the optional response hints
+type options struct {
+	retryAfter time.Duration
+	rateLimit  *rateLimit
+}
+
+type rateLimit struct {
+	limit     int
+	remaining int
+	reset     time.Duration
+}
+
+// Option customizes an error response
+type Option func(*options)
+
+// WithRetryAfter overrides the Retry-After hint
+func WithRetryAfter(d time.Duration) Option {
+	return func(o *options) {
+		o.retryAfter = d
+	}
+}
+
+// WithRateLimit attaches RateLimit-Limit, RateLimit-Remaining, and RateLimit-Reset headers
+func WithRateLimit(limit, remaining int, reset time.Duration) Option {
+	return func(o *options) {
+		o.rateLimit = &rateLimit{limit: limit, remaining: remaining, reset: reset}
+	}
+}
+
+// seconds rounds a duration up to whole seconds, as required by Retry-After
+func seconds(d time.Duration) int {
+	s := int((d + time.Second - 1) / time.Second)
+	if s < 0 {
+		return 0
+	}
+	return s
+}
+
+// Error writes a problem+json error response. All 5xx and 429 responses must go through
+// Error, or Hints for handlers writing errors in a negotiated format, so clients consistently
+// receive retry hints and the request ID.
+func Error(w http.ResponseWriter, r *http.Request, status int, detail string, opts ...Option) {
+	Hints(w, status, opts...)
+	w.Header().Set("Content-Type", ContentType)
+
+	response := &models.Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: requestid.FromContext(r.Context()),
+	}
+
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Failed to write problem response for %s: %v", r.URL.Path, err)
+	}
+}
+
+// Hints prepares the headers of an error response: headers describing the body the handler
+// intended to send are dropped, and the retry and rate limit hints are set. The caller sets the
+// Content-Type and writes the status and body.
+func Hints(w http.ResponseWriter, status int, opts ...Option) {
+	o := options{retryAfter: DefaultRetryAfter}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	header := w.Header()
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+	header.Set("X-Content-Type-Options", "nosniff")
+
+	if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+		header.Set("Retry-After", strconv.Itoa(seconds(o.retryAfter)))
+	}
+
+	if o.rateLimit != nil {
+		header.Set("RateLimit-Limit", strconv.Itoa(o.rateLimit.limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(o.rateLimit.remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(seconds(o.rateLimit.reset)))
+	}
+}
@@ -0,0 +1,181 @@
+package problem
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"myip/internal/models"
+	"myip/internal/requestid"
+)
+
+func TestError(t *testing.T) {
+	var rr *httptest.ResponseRecorder
+	handler := requestid.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", "42")
+		Error(w, r, http.StatusInternalServerError, "Failed to encode JSON response")
+	}))
+
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.Header.Set(requestid.Header, "req-123")
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", rr.Code)
+	}
+	if contentType := rr.Header().Get("Content-Type"); contentType != ContentType {
+		t.Errorf("Expected Content-Type %s, got %s", ContentType, contentType)
+	}
+	if rr.Header().Get("Content-Length") != "" {
+		t.Error("Expected stale Content-Length to be removed")
+	}
+	if retryAfter := rr.Header().Get("Retry-After"); retryAfter != "5" {
+		t.Errorf("Expected default Retry-After 5, got %q", retryAfter)
+	}
+
+	var response models.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode problem: %v", err)
+	}
+
+	expected := models.Problem{
+		Type:      "about:blank",
+		Title:     "Internal Server Error",
+		Status:    http.StatusInternalServerError,
+		Detail:    "Failed to encode JSON response",
+		Instance:  "/json",
+		RequestID: "req-123",
+	}
+	if response != expected {
+		t.Errorf("Expected %+v, got %+v", expected, response)
+	}
+}
+
+func TestErrorRateLimit(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/dns", nil)
+
+	Error(rr, req, http.StatusTooManyRequests, "Rate limit exceeded",
+		WithRetryAfter(1500*time.Millisecond),
+		WithRateLimit(30, 0, 1500*time.Millisecond))
+
+	expectedHeaders := map[string]string{
+		"Retry-After":         "2",
+		"RateLimit-Limit":     "30",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "2",
+	}
+	for header, value := range expectedHeaders {
+		if got := rr.Header().Get(header); got != value {
+			t.Errorf("Expected %s %q, got %q", header, value, got)
+		}
+	}
+}
+
+func TestErrorClientErrorHasNoRetryAfter(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Error(rr, httptest.NewRequest("GET", "/", nil), http.StatusBadRequest, "bad")
+
+	if rr.Header().Get("Retry-After") != "" {
+		t.Error("Expected no Retry-After on 4xx responses other than 429")
+	}
+}
+
+// retryableStatuses are the status constants that must only be written through Error
+var retryableStatuses = map[string]bool{
+	"StatusTooManyRequests":               true,
+	"StatusInternalServerError":           true,
+	"StatusNotImplemented":                true,
+	"StatusBadGateway":                    true,
+	"StatusServiceUnavailable":            true,
+	"StatusGatewayTimeout":                true,
+	"StatusHTTPVersionNotSupported":       true,
+	"StatusVariantAlsoNegotiates":         true,
+	"StatusInsufficientStorage":           true,
+	"StatusLoopDetected":                  true,
+	"StatusNotExtended":                   true,
+	"StatusNetworkAuthenticationRequired": true,
+}
+
+// TestNoErrorsOutsideResponder fails if any non-test source writes a 5xx or 429 status
+// through http.Error or WriteHeader instead of problem.Error
+func TestNoErrorsOutsideResponder(t *testing.T) {
+	root := filepath.Join("..", "..")
+	fset := token.NewFileSet()
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			switch d.Name() {
+			case ".git", "docs", "test":
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			fn, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || (fn.Sel.Name != "Error" && fn.Sel.Name != "WriteHeader") {
+				return true
+			}
+			if pkg, ok := fn.X.(*ast.Ident); ok && pkg.Name == "problem" {
+				return true
+			}
+			for _, arg := range call.Args {
+				status, ok := arg.(*ast.SelectorExpr)
+				if !ok {
+					continue
+				}
+				if pkg, ok := status.X.(*ast.Ident); ok && pkg.Name == "http" && retryableStatuses[status.Sel.Name] {
+					t.Errorf("%s: %s written outside problem.Error", fset.Position(call.Pos()), status.Sel.Name)
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHints(t *testing.T) {
+	rr := httptest.NewRecorder()
+	rr.Header().Set("Content-Encoding", "gzip")
+
+	Hints(rr, http.StatusServiceUnavailable, WithRetryAfter(30*time.Second))
+
+	if rr.Header().Get("Retry-After") != "30" {
+		t.Errorf("Expected Retry-After 30, got %q", rr.Header().Get("Retry-After"))
+	}
+	if rr.Header().Get("Content-Encoding") != "" {
+		t.Error("Expected stale Content-Encoding to be removed")
+	}
+	if rr.Header().Get("Content-Type") != "" {
+		t.Error("Expected Content-Type to be left to the caller")
+	}
+}
@@ -0,0 +1,83 @@
+package proxyprofile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// Feed is a platform's published list of proxy ranges
+type Feed struct {
+	URL string
+
+	// Parse extracts the CIDR ranges from the downloaded document
+	Parse func(data []byte) ([]string, error)
+}
+
+// parseCloudflare parses Cloudflare's IP ranges API response
+func parseCloudflare(data []byte) ([]string, error) {
+	var response struct {
+		Success bool `json:"success"`
+		Result  struct {
+			IPv4CIDRs []string `json:"ipv4_cidrs"`
+			IPv6CIDRs []string `json:"ipv6_cidrs"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	if !response.Success {
+		return nil, errors.New("the API reported a failure")
+	}
+	return prefixes(append(response.Result.IPv4CIDRs, response.Result.IPv6CIDRs...))
+}
+
+// parseAWS returns a parser of AWS's ip-ranges.json keeping the ranges of service
+func parseAWS(service string) func(data []byte) ([]string, error) {
+	return func(data []byte) ([]string, error) {
+		var document struct {
+			Prefixes []struct {
+				IPPrefix string `json:"ip_prefix"`
+				Service  string `json:"service"`
+			} `json:"prefixes"`
+			IPv6Prefixes []struct {
+				IPv6Prefix string `json:"ipv6_prefix"`
+				Service    string `json:"service"`
+			} `json:"ipv6_prefixes"`
+		}
+		if err := json.Unmarshal(data, &document); err != nil {
+			return nil, err
+		}
+
+		var ranges []string
+		for _, p := range document.Prefixes {
+			if p.Service == service {
+				ranges = append(ranges, p.IPPrefix)
+			}
+		}
+		for _, p := range document.IPv6Prefixes {
+			if p.Service == service {
+				ranges = append(ranges, p.IPv6Prefix)
+			}
+		}
+		return prefixes(ranges)
+	}
+}
+
+// prefixes validates and normalizes CIDR ranges, failing on an invalid or empty list so a
+// truncated or changed document never replaces the ranges in use
+func prefixes(ranges []string) ([]string, error) {
+	if len(ranges) == 0 {
+		return nil, errors.New("no ranges listed")
+	}
+	normalized := make([]string, len(ranges))
+	for i, r := range ranges {
+		prefix, err := netip.ParsePrefix(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q", r)
+		}
+		normalized[i] = prefix.Masked().String()
+	}
+	return normalized, nil
+}
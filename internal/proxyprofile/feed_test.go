@@ -0,0 +1,54 @@
+package proxyprofile
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseCloudflare(t *testing.T) {
+	ranges, err := parseCloudflare([]byte(`{"result":{"ipv4_cidrs":["173.245.48.0/20","103.21.244.1/22"],"ipv6_cidrs":["2400:cb00::/32"],"etag":"38f79d050aa027e3be3865e495dcc9bc"},"success":true,"errors":[],"messages":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"173.245.48.0/20", "103.21.244.0/22", "2400:cb00::/32"}; !slices.Equal(ranges, want) {
+		t.Errorf("ranges = %v, want %v", ranges, want)
+	}
+
+	for name, data := range map[string]string{
+		"failure":       `{"result":{"ipv4_cidrs":["173.245.48.0/20"]},"success":false}`,
+		"no ranges":     `{"result":{},"success":true}`,
+		"invalid range": `{"result":{"ipv4_cidrs":["173.245.48.0/33"]},"success":true}`,
+		"not JSON":      `173.245.48.0/20`,
+	} {
+		if _, err := parseCloudflare([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestParseAWS(t *testing.T) {
+	document := []byte(`{
+  "syncToken": "1700000000",
+  "createDate": "2024-01-01-00-00-00",
+  "prefixes": [
+    {"ip_prefix": "13.32.0.0/15", "region": "GLOBAL", "service": "AMAZON", "network_border_group": "GLOBAL"},
+    {"ip_prefix": "13.32.0.0/15", "region": "GLOBAL", "service": "CLOUDFRONT", "network_border_group": "GLOBAL"},
+    {"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2", "service": "EC2", "network_border_group": "ap-northeast-2"}
+  ],
+  "ipv6_prefixes": [
+    {"ipv6_prefix": "2600:9000::/28", "region": "GLOBAL", "service": "CLOUDFRONT", "network_border_group": "GLOBAL"}
+  ]
+}`)
+
+	ranges, err := parseAWS("CLOUDFRONT")(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"13.32.0.0/15", "2600:9000::/28"}; !slices.Equal(ranges, want) {
+		t.Errorf("ranges = %v, want %v", ranges, want)
+	}
+
+	if _, err := parseAWS("S3")(document); err == nil {
+		t.Error("Expected an error for a service without ranges")
+	}
+}
@@ -0,0 +1,168 @@
+// Package proxyprofile holds built-in settings for common CDNs, load balancers, and hosting
+// platforms: the header carrying the client IP, the X-Forwarded-For strategy, and the address
+// ranges the platform's proxies connect from. Selecting a profile spares writing HEADER_PRIORITY,
+// XFF_STRATEGY, and TRUSTED_PROXIES by hand.
+package proxyprofile
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Profile describes how a platform forwards the client address
+type Profile struct {
+	Name string
+
+	// HeaderPriority lists the headers the platform sets to the client address
+	HeaderPriority []string
+
+	// Strategy is the X-Forwarded-For strategy matching how the platform appends to the header;
+	// empty leaves the default
+	Strategy string
+
+	// Ranges are the address ranges the platform's proxies connect from, bundled with the binary.
+	// Empty when the platform does not publish them or is the only way into the service.
+	Ranges []string
+
+	// Feeds are the platform's published lists of Ranges, fetched at runtime to replace the
+	// bundled ranges
+	Feeds []Feed
+}
+
+// profiles are the built-in profiles, in documentation order
+var profiles = []Profile{
+	{
+		Name:           "cloudflare",
+		HeaderPriority: []string{"CF-Connecting-IP"},
+		Ranges: []string{
+			"173.245.48.0/20", "103.21.244.0/22", "103.22.200.0/22", "103.31.4.0/22",
+			"141.101.64.0/18", "108.162.192.0/18", "190.93.240.0/20", "188.114.96.0/20",
+			"197.234.240.0/22", "198.41.128.0/17", "162.158.0.0/15", "104.16.0.0/13",
+			"104.24.0.0/14", "172.64.0.0/13", "131.0.72.0/22",
+			"2400:cb00::/32", "2606:4700::/32", "2803:f800::/32", "2405:b500::/32",
+			"2405:8100::/32", "2a06:98c0::/29", "2c0f:f248::/32",
+		},
+		Feeds: []Feed{{URL: "https://api.cloudflare.com/client/v4/ips", Parse: parseCloudflare}},
+	},
+	{
+		// The load balancer appends the client to X-Forwarded-For and connects from the VPC
+		Name:           "aws-alb",
+		HeaderPriority: []string{"X-Forwarded-For"},
+		Strategy:       "rightmost",
+		Ranges:         []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"},
+	},
+	{
+		// CloudFront appends the viewer to X-Forwarded-For
+		Name:           "cloudfront",
+		HeaderPriority: []string{"X-Forwarded-For"},
+		Strategy:       "rightmost",
+		Ranges: []string{
+			"3.160.0.0/14", "13.32.0.0/15", "13.35.0.0/16", "13.224.0.0/14", "18.64.0.0/14",
+			"18.154.0.0/15", "18.160.0.0/15", "18.164.0.0/15", "18.172.0.0/15", "52.84.0.0/15",
+			"54.182.0.0/16", "54.192.0.0/16", "54.230.0.0/17", "54.239.128.0/18", "54.240.128.0/18",
+			"64.252.64.0/18", "65.8.0.0/16", "65.9.0.0/17", "70.132.0.0/18", "99.84.0.0/16",
+			"99.86.0.0/16", "108.138.0.0/15", "108.156.0.0/14", "130.176.0.0/16", "143.204.0.0/16",
+			"204.246.164.0/22", "205.251.192.0/19", "205.251.249.0/24", "216.137.32.0/19",
+			"2600:9000::/28",
+		},
+		Feeds: []Feed{{URL: "https://ip-ranges.amazonaws.com/ip-ranges.json", Parse: parseAWS("CLOUDFRONT")}},
+	},
+	{
+		// The load balancer appends "<client>, <load balancer>" to X-Forwarded-For, so the client
+		// is the last address outside the Google front ends and the load balancer's own address,
+		// which has to be added to TRUSTED_PROXIES
+		Name:           "gcp-lb",
+		HeaderPriority: []string{"X-Forwarded-For"},
+		Strategy:       "rightmost-untrusted",
+		Ranges:         []string{"35.191.0.0/16", "130.211.0.0/22"},
+	},
+	{
+		Name:           "fastly",
+		HeaderPriority: []string{"Fastly-Client-IP"},
+		Ranges: []string{
+			"23.235.32.0/20", "43.249.72.0/22", "103.244.50.0/24", "103.245.222.0/23",
+			"103.245.224.0/24", "104.156.80.0/20", "140.248.64.0/18", "140.248.128.0/17",
+			"146.75.0.0/17", "151.101.0.0/16", "157.52.64.0/18", "167.82.0.0/17",
+			"167.82.128.0/20", "167.82.160.0/20", "167.82.224.0/20", "172.111.64.0/18",
+			"185.31.16.0/22", "199.27.72.0/21", "199.232.0.0/16",
+			"2a04:4e40::/32", "2a04:4e42::/32",
+		},
+	},
+	{
+		// Akamai's ranges are specific to each customer's Site Shield map, so they come from
+		// TRUSTED_PROXIES
+		Name:           "akamai",
+		HeaderPriority: []string{"True-Client-IP"},
+	},
+	{
+		Name:           "fly.io",
+		HeaderPriority: []string{"Fly-Client-IP"},
+	},
+	{
+		// The router appends the client to X-Forwarded-For
+		Name:           "heroku",
+		HeaderPriority: []string{"X-Forwarded-For"},
+		Strategy:       "rightmost",
+	},
+}
+
+// Names returns the names of the built-in profiles
+func Names() []string {
+	names := make([]string, len(profiles))
+	for i, p := range profiles {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// find returns the profile named name, ignoring case
+func find(name string) (Profile, bool) {
+	i := slices.IndexFunc(profiles, func(p Profile) bool { return strings.EqualFold(p.Name, name) })
+	if i < 0 {
+		return Profile{}, false
+	}
+	return profiles[i], true
+}
+
+// Lookup returns the named profiles, reporting unknown names and profiles whose X-Forwarded-For
+// strategies conflict
+func Lookup(names []string) ([]Profile, error) {
+	selected := make([]Profile, 0, len(names))
+	strategy := ""
+	for _, name := range names {
+		p, ok := find(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown proxy profile %q, expected one of %s", name, strings.Join(Names(), ", "))
+		}
+		if p.Strategy != "" {
+			if strategy != "" && strategy != p.Strategy {
+				return nil, fmt.Errorf("proxy profile %s needs the %s strategy, conflicting with %s", p.Name, p.Strategy, strategy)
+			}
+			strategy = p.Strategy
+		}
+		selected = append(selected, p)
+	}
+	return selected, nil
+}
+
+// Defaults returns the header priority and X-Forwarded-For strategy of the named profiles, in
+// order and without duplicates, or nil and "" when none are named. Unknown names are skipped;
+// Lookup reports them.
+func Defaults(names []string) (headers []string, strategy string) {
+	for _, name := range names {
+		p, ok := find(name)
+		if !ok {
+			continue
+		}
+		for _, header := range p.HeaderPriority {
+			if !slices.Contains(headers, header) {
+				headers = append(headers, header)
+			}
+		}
+		if strategy == "" {
+			strategy = p.Strategy
+		}
+	}
+	return headers, strategy
+}
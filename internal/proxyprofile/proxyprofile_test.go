@@ -0,0 +1,61 @@
+package proxyprofile
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/akhfa/myip/ipdetect"
+)
+
+func TestBundledRangesParse(t *testing.T) {
+	for _, p := range profiles {
+		if _, err := ipdetect.ParseCIDRs(p.Ranges); err != nil {
+			t.Errorf("profile %s: %v", p.Name, err)
+		}
+		if _, err := ipdetect.ParseStrategy(p.Strategy); p.Strategy != "" && err != nil {
+			t.Errorf("profile %s: %v", p.Name, err)
+		}
+		for _, feed := range p.Feeds {
+			if feed.URL == "" || feed.Parse == nil {
+				t.Errorf("profile %s has an incomplete feed", p.Name)
+			}
+		}
+		if len(p.HeaderPriority) == 0 {
+			t.Errorf("profile %s has no header priority", p.Name)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	selected, err := Lookup([]string{"Cloudflare", "aws-alb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != 2 || selected[0].Name != "cloudflare" || selected[1].Name != "aws-alb" {
+		t.Errorf("Lookup = %v", selected)
+	}
+
+	if _, err := Lookup([]string{"haproxy"}); err == nil {
+		t.Error("Expected an error for an unknown profile")
+	}
+	if _, err := Lookup([]string{"aws-alb", "gcp-lb"}); err == nil {
+		t.Error("Expected an error for conflicting strategies")
+	}
+	if _, err := Lookup([]string{"aws-alb", "heroku"}); err != nil {
+		t.Errorf("Expected profiles sharing a strategy to combine: %v", err)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	headers, strategy := Defaults([]string{"cloudflare", "aws-alb", "heroku", "unknown"})
+	if want := []string{"CF-Connecting-IP", "X-Forwarded-For"}; !slices.Equal(headers, want) {
+		t.Errorf("headers = %v, want %v", headers, want)
+	}
+	if strategy != "rightmost" {
+		t.Errorf("strategy = %q, want rightmost", strategy)
+	}
+
+	if headers, strategy := Defaults(nil); headers != nil || strategy != "" {
+		t.Errorf("Defaults(nil) = %v, %q", headers, strategy)
+	}
+}
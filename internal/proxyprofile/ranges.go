@@ -0,0 +1,174 @@
+package proxyprofile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"myip/internal/breaker"
+	"myip/internal/logging"
+	"myip/internal/models"
+)
+
+// maxFeedBytes bounds the size of a downloaded feed; AWS's ip-ranges.json lists every service
+const maxFeedBytes = 16 << 20
+
+var logger = logging.For("proxyprofile")
+
+// published is the refresh state of a profile's feeds
+type published struct {
+	ranges      []string
+	refreshedAt time.Time
+	lastError   string
+	lastFailure time.Time
+}
+
+// Ranges holds the proxy ranges of the profiles, refreshing them from the published feeds
+type Ranges struct {
+	httpClient *http.Client
+
+	mu    sync.RWMutex
+	state map[string]*published
+	now   func() time.Time
+}
+
+// NewRanges creates Ranges fetching feeds through httpClient. Until Refresh succeeds, the bundled
+// ranges are used.
+func NewRanges(httpClient *http.Client) *Ranges {
+	return &Ranges{httpClient: httpClient, state: make(map[string]*published), now: time.Now}
+}
+
+// For returns the proxy ranges of the named profiles: the published ranges of a profile once
+// they were fetched, and its bundled ranges otherwise. Unknown names are skipped.
+func (r *Ranges) For(names []string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var ranges []string
+	for _, name := range names {
+		p, ok := find(name)
+		if !ok {
+			continue
+		}
+		if state, ok := r.state[p.Name]; ok && state.ranges != nil {
+			ranges = append(ranges, state.ranges...)
+		} else {
+			ranges = append(ranges, p.Ranges...)
+		}
+	}
+	return ranges
+}
+
+// Refresh fetches the feeds of the named profiles, reporting whether any ranges changed. A
+// profile whose feeds fail to load keeps its previous ranges; the returned error describes the
+// first failure.
+func (r *Ranges) Refresh(ctx context.Context, names []string) (bool, error) {
+	selected, err := Lookup(names)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	var firstErr error
+	for _, p := range selected {
+		if len(p.Feeds) == 0 {
+			continue
+		}
+		ranges, err := r.fetch(ctx, p.Feeds)
+
+		r.mu.Lock()
+		state, ok := r.state[p.Name]
+		if !ok {
+			state = &published{}
+			r.state[p.Name] = state
+		}
+		if err != nil {
+			state.lastError, state.lastFailure = err.Error(), r.now()
+		} else {
+			previous := state.ranges
+			if previous == nil {
+				previous = p.Ranges
+			}
+			changed = changed || !slices.Equal(previous, ranges)
+			state.ranges, state.refreshedAt, state.lastError = ranges, r.now(), ""
+		}
+		r.mu.Unlock()
+
+		if err != nil {
+			logging.Warnf("Proxy ranges of %s not refreshed: %v", p.Name, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("proxy ranges of %s: %w", p.Name, err)
+			}
+			continue
+		}
+		logger.Debugf("Loaded %d proxy ranges of %s", len(ranges), p.Name)
+	}
+	return changed, firstErr
+}
+
+// Status reports the refresh state of every profile whose feeds were fetched, for the readiness
+// probe
+func (r *Ranges) Status() []models.ProxyRangesStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var statuses []models.ProxyRangesStatus
+	for _, p := range profiles {
+		state, ok := r.state[p.Name]
+		if !ok {
+			continue
+		}
+		status := models.ProxyRangesStatus{Profile: p.Name, Source: "bundled", Ranges: len(p.Ranges)}
+		if state.ranges != nil {
+			status.Source, status.Ranges = "published", len(state.ranges)
+			status.RefreshedAt = state.refreshedAt.UTC().Format(time.RFC3339)
+		}
+		if state.lastError != "" {
+			status.LastError = state.lastError
+			status.LastFailure = state.lastFailure.UTC().Format(time.RFC3339)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// fetch downloads and parses feeds, failing unless every feed lists valid ranges
+func (r *Ranges) fetch(ctx context.Context, feeds []Feed) ([]string, error) {
+	var ranges []string
+	for _, feed := range feeds {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, feed.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+		var data []byte
+		err = breaker.Get("feed:" + req.URL.Host).Do(func() error {
+			resp, err := r.httpClient.Do(req)
+			if err != nil {
+				return err
+			}
+			data, err = io.ReadAll(io.LimitReader(resp.Body, maxFeedBytes))
+			resp.Body.Close()
+			if err != nil {
+				return err
+			}
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("%s returned %s", feed.URL, resp.Status)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		parsed, err := feed.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", feed.URL, err)
+		}
+		ranges = append(ranges, parsed...)
+	}
+	return ranges, nil
+}
@@ -0,0 +1,96 @@
+package proxyprofile
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// publish serves document as the Cloudflare profile's feed for the duration of the test, failing
+// with 503 while document is empty
+func publish(t *testing.T, document *string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if *document == "" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, *document)
+	}))
+	t.Cleanup(server.Close)
+
+	original := profiles[0].Feeds
+	profiles[0].Feeds = []Feed{{URL: server.URL, Parse: parseCloudflare}}
+	t.Cleanup(func() { profiles[0].Feeds = original })
+}
+
+// cloudflareDocument is a Cloudflare IP ranges API response listing cidr
+func cloudflareDocument(cidr string) string {
+	return fmt.Sprintf(`{"result":{"ipv4_cidrs":[%q]},"success":true}`, cidr)
+}
+
+func TestRangesRefresh(t *testing.T) {
+	document := cloudflareDocument("198.51.100.0/24")
+	publish(t, &document)
+	ranges := NewRanges(http.DefaultClient)
+	ranges.now = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+	names := []string{"cloudflare", "fly.io"}
+
+	if got := ranges.For(names); !slices.Equal(got, profiles[0].Ranges) {
+		t.Errorf("Expected the bundled ranges before a refresh, got %v", got)
+	}
+	if status := ranges.Status(); len(status) != 0 {
+		t.Errorf("Expected no status before a refresh, got %v", status)
+	}
+
+	changed, err := ranges.Refresh(context.Background(), names)
+	if err != nil || !changed {
+		t.Fatalf("Refresh = %v, %v", changed, err)
+	}
+	if got := ranges.For(names); !slices.Equal(got, []string{"198.51.100.0/24"}) {
+		t.Errorf("For = %v", got)
+	}
+	status := ranges.Status()
+	if len(status) != 1 || status[0].Profile != "cloudflare" || status[0].Source != "published" ||
+		status[0].Ranges != 1 || status[0].RefreshedAt != "2024-01-01T00:00:00Z" || status[0].LastError != "" {
+		t.Errorf("Status = %+v", status)
+	}
+
+	if changed, err := ranges.Refresh(context.Background(), names); err != nil || changed {
+		t.Errorf("Refresh of an unchanged feed = %v, %v", changed, err)
+	}
+
+	// A failed refresh keeps the published ranges and reports the failure
+	document = ""
+	if changed, err := ranges.Refresh(context.Background(), names); err == nil || changed {
+		t.Errorf("Refresh of a failing feed = %v, %v", changed, err)
+	}
+	if got := ranges.For(names); !slices.Equal(got, []string{"198.51.100.0/24"}) {
+		t.Errorf("Expected the published ranges after a failed refresh, got %v", got)
+	}
+	if status := ranges.Status(); status[0].Source != "published" || status[0].LastError == "" || status[0].LastFailure == "" {
+		t.Errorf("Status after a failure = %+v", status)
+	}
+}
+
+func TestRangesRefreshFailure(t *testing.T) {
+	document := `{"result":{"ipv4_cidrs":["not-a-range"]},"success":true}`
+	publish(t, &document)
+	ranges := NewRanges(http.DefaultClient)
+
+	changed, err := ranges.Refresh(context.Background(), []string{"cloudflare"})
+	if err == nil || changed {
+		t.Fatalf("Refresh = %v, %v, want an error", changed, err)
+	}
+	if got := ranges.For([]string{"cloudflare"}); !slices.Equal(got, profiles[0].Ranges) {
+		t.Errorf("Expected the bundled ranges after a failed refresh, got %v", got)
+	}
+	status := ranges.Status()
+	if len(status) != 1 || status[0].Source != "bundled" || status[0].Ranges != len(profiles[0].Ranges) || status[0].LastError == "" {
+		t.Errorf("Status = %+v", status)
+	}
+}
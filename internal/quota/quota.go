@@ -0,0 +1,194 @@
+// Package quota enforces daily and monthly request quotas for clients identifying themselves with
+// an API key, so the service can run as a semi-public API with tiers. Requests without a key are
+// not counted.
+package quota
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"myip/internal/cache"
+	"myip/internal/logging"
+	"myip/internal/problem"
+)
+
+var logger = logging.For("quota")
+
+// Header carries the API key of a request
+const Header = "X-API-Key"
+
+// Quota periods, aligned to calendar days and months in UTC
+const (
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+)
+
+// storeTimeout bounds a counter update; requests are let through when it fails
+const storeTimeout = 500 * time.Millisecond
+
+// Limit allows Requests requests in each Period
+type Limit struct {
+	Requests int64
+	Period   string
+}
+
+// Settings assign each API key a tier and each tier its limits
+type Settings struct {
+	// Keys maps API keys to tier names
+	Keys map[string]string
+
+	// Tiers maps tier names to their limits; a key is rejected once any limit is used up
+	Tiers map[string][]Limit
+}
+
+// Parse parses "tier=limit/period" tier entries such as "free=1000/day", where a tier listed
+// more than once has every listed limit, and "key=tier" API key entries
+func Parse(keys, tiers []string) (Settings, error) {
+	settings := Settings{Keys: make(map[string]string, len(keys)), Tiers: make(map[string][]Limit)}
+	for _, entry := range tiers {
+		tier, value, ok := strings.Cut(entry, "=")
+		tier, value = strings.TrimSpace(tier), strings.TrimSpace(value)
+		requests, period, _ := strings.Cut(value, "/")
+		n, err := strconv.ParseInt(requests, 10, 64)
+		if !ok || tier == "" || err != nil || n < 1 || (period != PeriodDay && period != PeriodMonth) {
+			return Settings{}, fmt.Errorf("invalid quota tier %q, expected tier=requests/day or tier=requests/month", entry)
+		}
+		settings.Tiers[tier] = append(settings.Tiers[tier], Limit{Requests: n, Period: period})
+	}
+	for i, entry := range keys {
+		key, tier, ok := strings.Cut(entry, "=")
+		key, tier = strings.TrimSpace(key), strings.TrimSpace(tier)
+		// Entries are secrets, so errors name them by position
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return Settings{}, fmt.Errorf("invalid API key entry %d, expected key=tier", i+1)
+		}
+		if _, ok := settings.Tiers[tier]; !ok {
+			return Settings{}, fmt.Errorf("API key entry %d names unknown tier %q", i+1, tier)
+		}
+		if _, ok := settings.Keys[key]; ok {
+			return Settings{}, fmt.Errorf("API key entry %d repeats an earlier key", i+1)
+		}
+		settings.Keys[key] = tier
+	}
+	return settings, nil
+}
+
+// Quotas counts the requests of each API key in a cache store, shared by every replica when the
+// store is Redis
+type Quotas struct {
+	store    cache.Store
+	settings atomic.Pointer[Settings]
+	now      func() time.Time
+}
+
+// New creates quotas counting requests in store, with no API keys
+func New(store cache.Store) *Quotas {
+	q := &Quotas{store: store, now: time.Now}
+	q.settings.Store(&Settings{})
+	return q
+}
+
+// Configure replaces the API keys and tiers; counts are kept, so a key moved to another tier
+// keeps the requests it already made
+func (q *Quotas) Configure(settings Settings) {
+	q.settings.Store(&settings)
+}
+
+// usage is the state of one limit after counting a request
+type usage struct {
+	limit     int64
+	remaining int64
+	reset     time.Duration
+}
+
+// count adds a request of key to each limit, returning the most constraining usage and whether
+// every limit still allowed the request. Days are counted before months and counting stops at the
+// first exceeded limit, so a request rejected by a daily limit is never charged to the month,
+// whatever order the tier lists its limits in.
+func (q *Quotas) count(ctx context.Context, key string, limits []Limit) (usage, bool, error) {
+	now := q.now().UTC()
+	sum := sha256.Sum256([]byte(key))
+	id := hex.EncodeToString(sum[:8])
+
+	var tightest usage
+	first := true
+	for _, period := range []string{PeriodDay, PeriodMonth} {
+		stamp, end := now.Format("2006-01-02"), time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		if period == PeriodMonth {
+			stamp, end = now.Format("2006-01"), time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		}
+
+		// Limits of the same period share one counter
+		var count int64
+		counted := false
+		for _, limit := range limits {
+			if limit.Period != period {
+				continue
+			}
+			if !counted {
+				var err error
+				if count, _, err = q.store.Incr(ctx, "quota:"+id+":"+stamp, end.Sub(now)); err != nil {
+					return usage{}, true, err
+				}
+				counted = true
+			}
+
+			u := usage{limit: limit.Requests, remaining: max(limit.Requests-count, 0), reset: end.Sub(now)}
+			if first || u.remaining < tightest.remaining {
+				tightest, first = u, false
+			}
+			if count > limit.Requests {
+				return u, false, nil
+			}
+		}
+	}
+	return tightest, true, nil
+}
+
+// Middleware counts requests carrying an API key against the key's tier, adding the
+// X-RateLimit-Limit, X-RateLimit-Remaining, and X-RateLimit-Reset headers of its most used-up
+// limit. Unknown keys are rejected with 401 and keys over quota with 429 until the period
+// resets. Requests without a key pass through uncounted.
+func (q *Quotas) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		settings := q.settings.Load()
+		tier, ok := settings.Keys[key]
+		if !ok {
+			problem.Error(w, r, http.StatusUnauthorized, "Unknown API key")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), storeTimeout)
+		u, allowed, err := q.count(ctx, key, settings.Tiers[tier])
+		cancel()
+		if err != nil {
+			logging.Warnf("Quota accounting unavailable, allowing the request: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Set("X-RateLimit-Limit", strconv.FormatInt(u.limit, 10))
+		header.Set("X-RateLimit-Remaining", strconv.FormatInt(u.remaining, 10))
+		header.Set("X-RateLimit-Reset", strconv.FormatInt(int64((u.reset+time.Second-1)/time.Second), 10))
+		if !allowed {
+			logger.Debugf("Rejecting a %s tier request over its quota of %d", tier, u.limit)
+			problem.Error(w, r, http.StatusTooManyRequests, "API key quota exceeded", problem.WithRetryAfter(u.reset))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
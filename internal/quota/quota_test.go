@@ -0,0 +1,161 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"myip/internal/cache"
+)
+
+func TestParse(t *testing.T) {
+	settings, err := Parse([]string{"k3y-one=free", " k3y-two = pro "}, []string{"free=100/day", "pro=5000/day", "pro=100000/month"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Keys["k3y-one"] != "free" || settings.Keys["k3y-two"] != "pro" {
+		t.Errorf("Unexpected keys %v", settings.Keys)
+	}
+	if pro := settings.Tiers["pro"]; len(pro) != 2 || pro[1] != (Limit{Requests: 100000, Period: PeriodMonth}) {
+		t.Errorf("Unexpected pro limits %v", pro)
+	}
+
+	tests := []struct {
+		name  string
+		keys  []string
+		tiers []string
+	}{
+		{"tier without limit", nil, []string{"free"}},
+		{"unknown period", nil, []string{"free=100/week"}},
+		{"zero requests", nil, []string{"free=0/day"}},
+		{"key without tier", []string{"k3y"}, []string{"free=100/day"}},
+		{"unknown tier", []string{"k3y=gold"}, []string{"free=100/day"}},
+		{"repeated key", []string{"k3y=free", "k3y=free"}, []string{"free=100/day"}},
+	}
+	for _, test := range tests {
+		if _, err := Parse(test.keys, test.tiers); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	q := New(cache.NewMemory(100))
+	now := time.Date(2024, 3, 31, 23, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+	settings, err := Parse([]string{"free-key=free", "pro-key=pro"}, []string{"free=2/day", "pro=100/day", "pro=3/month"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Configure(settings)
+
+	handler := q.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	request := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/json", nil)
+		if key != "" {
+			req.Header.Set(Header, key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := request(""); rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Remaining") != "" {
+		t.Errorf("Expected requests without a key to pass uncounted, got %d %v", rr.Code, rr.Header())
+	}
+	if rr := request("wrong"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected an unknown key to be rejected with 401, got %d", rr.Code)
+	}
+
+	for i, remaining := range []string{"1", "0"} {
+		rr := request("free-key")
+		if rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Limit") != "2" || rr.Header().Get("X-RateLimit-Remaining") != remaining ||
+			rr.Header().Get("X-RateLimit-Reset") != "3600" {
+			t.Errorf("Request %d: expected 200 with %s remaining, got %d %v", i+1, remaining, rr.Code, rr.Header())
+		}
+	}
+	rr := request("free-key")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "3600" || rr.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("Expected 429 until midnight once the quota is used up, got %d %v", rr.Code, rr.Header())
+	}
+
+	// The monthly quota is tighter than the daily one
+	if rr := request("pro-key"); rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Limit") != "3" || rr.Header().Get("X-RateLimit-Remaining") != "2" {
+		t.Errorf("Expected the monthly quota in the headers, got %d %v", rr.Code, rr.Header())
+	}
+
+	// Quotas reset at the start of the next day and month in UTC
+	now = now.Add(2 * time.Hour)
+	if rr := request("free-key"); rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Errorf("Expected a new daily quota, got %d %v", rr.Code, rr.Header())
+	}
+	if rr := request("pro-key"); rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Remaining") != "2" {
+		t.Errorf("Expected a new monthly quota, got %d %v", rr.Code, rr.Header())
+	}
+}
+
+// A request rejected by the daily limit must not use up the monthly one, even when the month is
+// listed first
+func TestMiddlewareMonthBeforeDay(t *testing.T) {
+	q := New(cache.NewMemory(100))
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+	settings, err := Parse([]string{"free-key=free"}, []string{"free=5/month", "free=2/day"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Configure(settings)
+
+	handler := q.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/json", nil)
+		req.Header.Set(Header, "free-key")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i, code := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests} {
+		if rr := request(); rr.Code != code {
+			t.Errorf("Request %d: expected %d, got %d", i+1, code, rr.Code)
+		}
+	}
+
+	// Only the two allowed requests count against the month
+	now = now.Add(24 * time.Hour)
+	if rr := request(); rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Limit") != "2" || rr.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Errorf("Expected the next day's quota with the month untouched, got %d %v", rr.Code, rr.Header())
+	}
+	if rr := request(); rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("Expected the fourth allowed request of the month to pass, got %d %v", rr.Code, rr.Header())
+	}
+}
+
+// failingStore fails every counter update
+type failingStore struct {
+	cache.Store
+}
+
+func (failingStore) Incr(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	return 0, 0, errors.New("connection refused")
+}
+
+func TestMiddlewareStoreUnavailable(t *testing.T) {
+	q := New(failingStore{})
+	settings, err := Parse([]string{"free-key=free"}, []string{"free=1/day"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Configure(settings)
+
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.Header.Set(Header, "free-key")
+	rr := httptest.NewRecorder()
+	q.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected requests to be let through while the store is unavailable, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"myip/internal/ip"
+	"myip/internal/problem"
+)
+
+// ConcurrencyRetryAfter is the Retry-After hint sent with requests rejected by a Concurrency
+// limiter; in-flight requests finish quickly, so clients may retry almost immediately
+const ConcurrencyRetryAfter = time.Second
+
+// Concurrency caps the number of requests handled at once, both in total and per client IP, so a
+// flood of slow requests cannot exhaust a small instance
+type Concurrency struct {
+	mu       sync.Mutex
+	global   int
+	perKey   int
+	inFlight int
+	keys     map[string]int
+}
+
+// NewConcurrency creates a limiter allowing global requests in flight in total and perKey for
+// each key. A limit of zero or less disables that limit.
+func NewConcurrency(global, perKey int) *Concurrency {
+	return &Concurrency{
+		global: global,
+		perKey: perKey,
+		keys:   make(map[string]int),
+	}
+}
+
+// SetLimits changes both limits; requests already in flight are unaffected
+func (c *Concurrency) SetLimits(global, perKey int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.global = global
+	c.perKey = perKey
+}
+
+// Acquire admits a request for key, returning ok=false and the status to reject it with when a
+// limit is reached: 503 for the global cap and 429 for the per-key cap. Admitted requests must
+// call Release with the same key once they are done.
+func (c *Concurrency) Acquire(key string) (ok bool, status int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.global > 0 && c.inFlight >= c.global {
+		logger.Debugf("Rejecting %s: %d requests in flight", key, c.inFlight)
+		return false, http.StatusServiceUnavailable
+	}
+	if c.perKey > 0 && c.keys[key] >= c.perKey {
+		logger.Debugf("Rejecting %s: %d of its requests in flight", key, c.keys[key])
+		return false, http.StatusTooManyRequests
+	}
+
+	c.inFlight++
+	c.keys[key]++
+	return true, 0
+}
+
+// Release ends a request admitted by Acquire
+func (c *Concurrency) Release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inFlight--
+	if c.keys[key] <= 1 {
+		delete(c.keys, key)
+		return
+	}
+	c.keys[key]--
+}
+
+// InFlight returns the number of requests currently admitted
+func (c *Concurrency) InFlight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.inFlight
+}
+
+// Middleware rejects requests over the limits, keyed by the detected client IP, with a
+// problem+json 503 when the instance is saturated or 429 when the client holds too many
+func (c *Concurrency) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, _ := ip.ExtractClientIP(r)
+		ok, status := c.Acquire(key)
+		if !ok {
+			detail := "Too many requests in progress, please retry shortly"
+			if status == http.StatusTooManyRequests {
+				detail = "Too many concurrent requests from this client"
+			}
+			problem.Error(w, r, status, detail, problem.WithRetryAfter(ConcurrencyRetryAfter))
+			return
+		}
+		defer c.Release(key)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConcurrencyAcquire(t *testing.T) {
+	c := NewConcurrency(3, 2)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := c.Acquire("203.0.113.1"); !ok {
+			t.Fatalf("Expected request %d to be admitted", i+1)
+		}
+	}
+
+	if ok, status := c.Acquire("203.0.113.1"); ok || status != http.StatusTooManyRequests {
+		t.Errorf("Expected per-key limit to reject with 429, got ok=%t status=%d", ok, status)
+	}
+
+	if ok, _ := c.Acquire("203.0.113.2"); !ok {
+		t.Fatal("Expected a different key to be admitted")
+	}
+
+	if ok, status := c.Acquire("203.0.113.3"); ok || status != http.StatusServiceUnavailable {
+		t.Errorf("Expected global limit to reject with 503, got ok=%t status=%d", ok, status)
+	}
+
+	c.Release("203.0.113.1")
+	if ok, _ := c.Acquire("203.0.113.3"); !ok {
+		t.Error("Expected a released slot to be reusable")
+	}
+
+	c.Release("203.0.113.1")
+	c.Release("203.0.113.2")
+	c.Release("203.0.113.3")
+	if n := c.InFlight(); n != 0 {
+		t.Errorf("Expected nothing in flight, got %d", n)
+	}
+	if len(c.keys) != 0 {
+		t.Errorf("Expected released keys to be dropped, got %v", c.keys)
+	}
+}
+
+func TestConcurrencyDisabled(t *testing.T) {
+	c := NewConcurrency(0, 0)
+	for i := 0; i < 100; i++ {
+		if ok, _ := c.Acquire("203.0.113.1"); !ok {
+			t.Fatal("Expected disabled limiter to admit all requests")
+		}
+	}
+}
+
+func TestConcurrencySetLimits(t *testing.T) {
+	c := NewConcurrency(1, 0)
+	c.Acquire("a")
+	if ok, _ := c.Acquire("b"); ok {
+		t.Fatal("Expected global limit of 1 to reject the second request")
+	}
+
+	c.SetLimits(0, 1)
+	if ok, _ := c.Acquire("b"); !ok {
+		t.Error("Expected request to be admitted after lifting the global limit")
+	}
+	if ok, status := c.Acquire("a"); ok || status != http.StatusTooManyRequests {
+		t.Errorf("Expected new per-key limit to apply, got ok=%t status=%d", ok, status)
+	}
+}
+
+func TestConcurrencyMiddleware(t *testing.T) {
+	c := NewConcurrency(0, 1)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "203.0.113.1:1234"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-started
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.1:5678"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 for a second concurrent request, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Expected Retry-After 1, got %q", got)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Expected problem+json, got %q", ct)
+	}
+
+	close(release)
+	<-done
+	if n := c.InFlight(); n != 0 {
+		t.Errorf("Expected the finished request to be released, got %d in flight", n)
+	}
+}
@@ -0,0 +1,181 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"myip/internal/cache"
+	"myip/internal/logging"
+)
+
+var logger = logging.For("ratelimit")
+
+// window tracks the request count for a single key within the current window
+type window struct {
+	start time.Time
+	count int
+}
+
+// Rate limiting algorithms selected by RATE_LIMIT_ALGORITHM
+const (
+	// AlgorithmWindow counts requests in fixed windows of one period, so a client may send up to
+	// twice the limit around a window boundary
+	AlgorithmWindow = "window"
+
+	// AlgorithmBucket gives each key a token bucket holding the limit and refilling it evenly over
+	// the period, smoothing bursts across window boundaries
+	AlgorithmBucket = "bucket"
+)
+
+// sharedTimeout bounds a shared window update; the local window is used when it fails
+const sharedTimeout = 500 * time.Millisecond
+
+// Limiter is a fixed-window or token bucket rate limiter keyed by an arbitrary string (usually
+// the client IP)
+type Limiter struct {
+	mu      sync.Mutex
+	limit   int
+	period  time.Duration
+	windows map[string]*window
+	lastGC  time.Time
+	now     func() time.Time
+
+	// bucket selects AlgorithmBucket, with the local buckets kept in buckets
+	bucket  bool
+	buckets *cache.Memory
+
+	// store and name hold the windows in a shared cache instead; see Share
+	store cache.Store
+	name  string
+}
+
+// New creates a limiter allowing limit requests per key in each period.
+// A limit of zero or less disables limiting.
+func New(limit int, period time.Duration) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		period:  period,
+		windows: make(map[string]*window),
+		now:     time.Now,
+		buckets: cache.NewMemory(cache.DefaultMaxEntries),
+	}
+}
+
+// SetAlgorithm selects AlgorithmWindow or AlgorithmBucket; any other name selects
+// AlgorithmWindow. Existing windows and buckets are kept but no longer consulted.
+func (l *Limiter) SetAlgorithm(algorithm string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bucket = algorithm == AlgorithmBucket
+}
+
+// SetLimit changes the number of requests allowed per key in each period; existing windows keep their counts
+func (l *Limiter) SetLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+}
+
+// Limit returns the number of requests allowed per key in each period
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// Share keeps the limiter's windows or buckets in store under name, so that replicas using the
+// same store enforce one limit together. Keys fall back to the local windows or buckets while the
+// store is unavailable.
+func (l *Limiter) Share(store cache.Store, name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.store = store
+	l.name = name
+}
+
+// Allow reports whether a request for key is permitted and, if not, how long until the window
+// resets or the bucket holds a token again
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	limit, bucket, store, name := l.limit, l.bucket, l.store, l.name
+	l.mu.Unlock()
+
+	if limit <= 0 {
+		return true, 0
+	}
+	if store != nil {
+		allowed, retryAfter, err := l.allowShared(store, name, key, limit, bucket)
+		if err == nil {
+			return allowed, retryAfter
+		}
+		logging.Warnf("Shared rate limit %s unavailable, using the local limit: %v", name, err)
+	}
+	if bucket {
+		// A Memory store never fails
+		allowed, retryAfter, _ := l.buckets.Take(context.Background(), key, limit, l.period)
+		if !allowed {
+			logger.Debugf("Key %s exceeded %d requests per %v", key, limit, l.period)
+		}
+		return allowed, retryAfter
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.gc(now)
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.period {
+		l.windows[key] = &window{start: now, count: 1}
+		return true, 0
+	}
+
+	if w.count >= l.limit {
+		logger.Debugf("Key %s exceeded %d requests per %v", key, l.limit, l.period)
+		return false, w.start.Add(l.period).Sub(now)
+	}
+
+	w.count++
+	return true, 0
+}
+
+// allowShared counts the request in the shared window for key, or takes a token from its shared
+// bucket. Buckets use their own keys, since Redis cannot hold a bucket where a counter is.
+func (l *Limiter) allowShared(store cache.Store, name, key string, limit int, bucket bool) (bool, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sharedTimeout)
+	defer cancel()
+
+	if bucket {
+		allowed, retryAfter, err := store.Take(ctx, "bucket:"+name+":"+key, limit, l.period)
+		if err == nil && !allowed {
+			logger.Debugf("Key %s exceeded %d requests per %v across replicas", key, limit, l.period)
+		}
+		return allowed, retryAfter, err
+	}
+
+	count, remaining, err := store.Incr(ctx, "limit:"+name+":"+key, l.period)
+	if err != nil {
+		return false, 0, err
+	}
+	if count > int64(limit) {
+		logger.Debugf("Key %s exceeded %d requests per %v across replicas", key, limit, l.period)
+		return false, remaining, nil
+	}
+	return true, 0, nil
+}
+
+// gc drops expired windows at most once per period to keep memory bounded
+func (l *Limiter) gc(now time.Time) {
+	if now.Sub(l.lastGC) < l.period {
+		return
+	}
+	l.lastGC = now
+
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= l.period {
+			delete(l.windows, key)
+		}
+	}
+}
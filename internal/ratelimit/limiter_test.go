@@ -0,0 +1,197 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"myip/internal/cache"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("203.0.113.1"); !ok {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+
+	ok, retryAfter := l.Allow("203.0.113.1")
+	if ok {
+		t.Error("Expected third request to be limited")
+	}
+	if retryAfter != time.Minute {
+		t.Errorf("Expected retry after 1m, got %v", retryAfter)
+	}
+
+	// Other keys are tracked independently
+	if ok, _ := l.Allow("203.0.113.2"); !ok {
+		t.Error("Expected different key to be allowed")
+	}
+
+	// Window resets after the period
+	now = now.Add(time.Minute)
+	if ok, _ := l.Allow("203.0.113.1"); !ok {
+		t.Error("Expected request to be allowed after window reset")
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	l := New(0, time.Minute)
+
+	for i := 0; i < 100; i++ {
+		if ok, _ := l.Allow("203.0.113.1"); !ok {
+			t.Fatal("Expected disabled limiter to allow all requests")
+		}
+	}
+}
+
+func TestLimiterGC(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(1, time.Minute)
+	l.now = func() time.Time { return now }
+
+	l.Allow("a")
+	l.Allow("b")
+
+	now = now.Add(2 * time.Minute)
+	l.Allow("c")
+
+	if len(l.windows) != 1 {
+		t.Errorf("Expected expired windows to be collected, got %d entries", len(l.windows))
+	}
+}
+
+func TestLimiterSetLimit(t *testing.T) {
+	l := New(1, time.Minute)
+
+	l.Allow("203.0.113.1")
+	if ok, _ := l.Allow("203.0.113.1"); ok {
+		t.Fatal("Expected second request to be limited")
+	}
+
+	l.SetLimit(2)
+	if ok, _ := l.Allow("203.0.113.1"); !ok {
+		t.Error("Expected request to be allowed after raising the limit")
+	}
+
+	l.SetLimit(0)
+	if ok, _ := l.Allow("203.0.113.1"); !ok {
+		t.Error("Expected limiting to be disabled")
+	}
+}
+
+func TestLimiterShared(t *testing.T) {
+	store := cache.NewMemory(100)
+	first := New(2, time.Minute)
+	second := New(2, time.Minute)
+	first.Share(store, "dns")
+	second.Share(store, "dns")
+
+	if ok, _ := first.Allow("203.0.113.1"); !ok {
+		t.Fatal("Expected the first request to be allowed")
+	}
+	if ok, _ := second.Allow("203.0.113.1"); !ok {
+		t.Fatal("Expected the second request to be allowed")
+	}
+
+	// The limit applies across both limiters
+	ok, retryAfter := first.Allow("203.0.113.1")
+	if ok || retryAfter <= 0 || retryAfter > time.Minute {
+		t.Errorf("Expected the third request to be limited with a retry hint, got %t %v", ok, retryAfter)
+	}
+
+	// Limits with other names are counted separately
+	other := New(1, time.Minute)
+	other.Share(store, "rdap")
+	if ok, _ := other.Allow("203.0.113.1"); !ok {
+		t.Error("Expected a differently named limiter to be independent")
+	}
+}
+
+func TestLimiterBucket(t *testing.T) {
+	l := New(2, time.Minute)
+	l.SetAlgorithm(AlgorithmBucket)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("203.0.113.1"); !ok {
+			t.Fatalf("Expected request %d to use the burst", i+1)
+		}
+	}
+
+	// The bucket refills one token every 30s rather than all at once when a window ends
+	ok, retryAfter := l.Allow("203.0.113.1")
+	if ok || retryAfter <= 29*time.Second || retryAfter > 30*time.Second {
+		t.Errorf("Expected the third request to wait about 30s, got %t %v", ok, retryAfter)
+	}
+
+	if ok, _ := l.Allow("203.0.113.2"); !ok {
+		t.Error("Expected different key to be allowed")
+	}
+}
+
+func TestLimiterBucketShared(t *testing.T) {
+	store := cache.NewMemory(100)
+	first := New(1, time.Minute)
+	second := New(1, time.Minute)
+	for _, l := range []*Limiter{first, second} {
+		l.SetAlgorithm(AlgorithmBucket)
+		l.Share(store, "dns")
+	}
+
+	if ok, _ := first.Allow("203.0.113.1"); !ok {
+		t.Fatal("Expected the first request to be allowed")
+	}
+	if ok, retryAfter := second.Allow("203.0.113.1"); ok || retryAfter <= 0 {
+		t.Errorf("Expected the bucket to be shared, got %t %v", ok, retryAfter)
+	}
+
+	// A window limiter sharing the store counts separately rather than failing on the bucket
+	window := New(1, time.Minute)
+	window.Share(store, "dns")
+	if ok, _ := window.Allow("203.0.113.1"); !ok {
+		t.Error("Expected window counters to be kept apart from buckets")
+	}
+}
+
+// failingStore is a cache.Store whose counters are unavailable
+type failingStore struct {
+	cache.Store
+}
+
+func (failingStore) Incr(context.Context, string, time.Duration) (int64, time.Duration, error) {
+	return 0, 0, errors.New("connection refused")
+}
+
+func (failingStore) Take(context.Context, string, int, time.Duration) (bool, time.Duration, error) {
+	return false, 0, errors.New("connection refused")
+}
+
+func TestLimiterSharedFallback(t *testing.T) {
+	l := New(1, time.Minute)
+	l.Share(failingStore{}, "dns")
+
+	if ok, _ := l.Allow("203.0.113.1"); !ok {
+		t.Fatal("Expected the first request to be allowed by the local window")
+	}
+	if ok, _ := l.Allow("203.0.113.1"); ok {
+		t.Error("Expected the local window to limit the second request")
+	}
+}
+
+func TestLimiterBucketSharedFallback(t *testing.T) {
+	l := New(1, time.Minute)
+	l.SetAlgorithm(AlgorithmBucket)
+	l.Share(failingStore{}, "dns")
+
+	if ok, _ := l.Allow("203.0.113.1"); !ok {
+		t.Fatal("Expected the first request to be allowed by the local bucket")
+	}
+	if ok, _ := l.Allow("203.0.113.1"); ok {
+		t.Error("Expected the local bucket to limit the second request")
+	}
+}
@@ -0,0 +1,65 @@
+package rdap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"myip/internal/breaker"
+	"myip/internal/ip"
+	"myip/internal/problem"
+)
+
+// Handler serves registry information for the client's IP address
+type Handler struct {
+	client *Client
+}
+
+// NewHandler creates a /whois handler backed by client
+func NewHandler(client *Client) *Handler {
+	return &Handler{client: client}
+}
+
+// ServeHTTP handles /whois requests
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	clientIP, _ := ip.ExtractClientIP(r)
+	if !ip.IsValid(clientIP) {
+		http.Error(w, "Unable to determine client IP", http.StatusBadRequest)
+		return
+	}
+	if ip.IsPrivate(clientIP) {
+		http.Error(w, "No registry data for private addresses", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.client.Lookup(r.Context(), clientIP)
+	if err != nil {
+		var limited *RateLimitError
+		var open *breaker.OpenError
+		switch {
+		case errors.Is(err, ErrNotFound):
+			http.Error(w, "No registry data for address", http.StatusNotFound)
+		case errors.As(err, &limited):
+			problem.Error(w, r, http.StatusServiceUnavailable, "RDAP query budget exhausted",
+				problem.WithRetryAfter(limited.RetryAfter))
+		case errors.As(err, &open):
+			problem.Error(w, r, http.StatusServiceUnavailable, "RDAP lookups suspended after repeated failures",
+				problem.WithRetryAfter(open.RetryAfter))
+		case errors.Is(r.Context().Err(), context.DeadlineExceeded):
+			problem.Error(w, r, http.StatusGatewayTimeout, "RDAP lookup exceeded the request budget")
+		default:
+			log.Printf("RDAP lookup for %s failed: %v", clientIP, err)
+			problem.Error(w, r, http.StatusBadGateway, "RDAP lookup failed")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, "Failed to encode JSON response")
+		return
+	}
+}
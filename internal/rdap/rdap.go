@@ -0,0 +1,234 @@
+package rdap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"myip/internal/breaker"
+	"myip/internal/cache"
+	"myip/internal/logging"
+	"myip/internal/models"
+	"myip/internal/ratelimit"
+)
+
+// DefaultBaseURL is the RDAP bootstrap service, which redirects to the responsible registry
+const DefaultBaseURL = "https://rdap.org/ip/"
+
+// maxCacheEntries bounds the number of cached lookups
+const maxCacheEntries = 10000
+
+// maxResponseBytes bounds the size of a registry response
+const maxResponseBytes = 1 << 20
+
+// upstreamKey is the limiter key shared by all queries to the registries
+const upstreamKey = "upstream"
+
+var logger = logging.For("rdap")
+
+// ErrNotFound is returned when no registry holds data for the address
+var ErrNotFound = errors.New("no RDAP data for address")
+
+// RateLimitError is returned when the upstream query budget is exhausted
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("RDAP query budget exhausted, retry in %v", e.RetryAfter)
+}
+
+// cacheEntry is a cached lookup result. The expiry is kept with the response so that entries
+// written by replicas with a longer RDAP_CACHE_TTL are not served past this one's.
+type cacheEntry struct {
+	Response *models.WhoisResponse `json:"response"`
+	Expires  time.Time             `json:"expires"`
+}
+
+// Client queries RDAP registries, caching results and rate limiting outgoing queries
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	cacheTTL   time.Duration
+	limiter    *ratelimit.Limiter
+	breaker    *breaker.Breaker
+
+	store cache.Store
+	now   func() time.Time
+}
+
+// NewClient creates an RDAP client sending at most rateLimit queries per minute to baseURL
+// through httpClient. A rateLimit of zero or less disables the upstream limit.
+func NewClient(httpClient *http.Client, baseURL string, cacheTTL time.Duration, rateLimit int) *Client {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+
+	return &Client{
+		httpClient: httpClient,
+		baseURL:    baseURL,
+		cacheTTL:   cacheTTL,
+		limiter:    ratelimit.New(rateLimit, time.Minute),
+		breaker:    breaker.Get("rdap"),
+		store:      cache.NewMemory(maxCacheEntries),
+		now:        time.Now,
+	}
+}
+
+// SetStore keeps lookup results and the upstream query budget in store, so that replicas sharing
+// it query the registries once per address and stay within one RDAP_RATE_LIMIT together
+func (c *Client) SetStore(store cache.Store) {
+	c.store = store
+	c.limiter.Share(store, "rdap")
+}
+
+// Lookup returns the registry information for ip, from the cache when possible
+func (c *Client) Lookup(ctx context.Context, ip string) (*models.WhoisResponse, error) {
+	if response, ok := c.cached(ctx, ip); ok {
+		logger.Debugf("Cache hit for %s", ip)
+		return response, nil
+	}
+
+	// The breaker is checked first so that queries it refuses do not spend the budget
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	if ok, retryAfter := c.limiter.Allow(upstreamKey); !ok {
+		// A query that was never sent says nothing about the registries
+		c.breaker.Done(context.Canceled)
+		return nil, &RateLimitError{RetryAfter: retryAfter}
+	}
+
+	start := time.Now()
+	response, err := c.query(ctx, ip)
+	if errors.Is(err, ErrNotFound) {
+		c.breaker.Done(nil)
+	} else {
+		c.breaker.Done(err)
+	}
+	logger.Debugf("Query for %s took %v, err=%v", ip, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.SetJSON(ctx, c.store, cacheKey(ip), &cacheEntry{Response: response, Expires: c.now().Add(c.cacheTTL)}, c.cacheTTL)
+	return response, nil
+}
+
+// cacheKey is the store key of the lookup result for ip
+func cacheKey(ip string) string {
+	return "rdap:" + ip
+}
+
+// cached returns an unexpired cache entry for ip
+func (c *Client) cached(ctx context.Context, ip string) (*models.WhoisResponse, bool) {
+	var entry cacheEntry
+	if !cache.GetJSON(ctx, c.store, cacheKey(ip), &entry) || entry.Response == nil || c.now().After(entry.Expires) {
+		return nil, false
+	}
+	return entry.Response, true
+}
+
+// query performs the RDAP request for ip
+func (c *Client) query(ctx context.Context, ip string) (*models.WhoisResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+ip, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry returned %s", resp.Status)
+	}
+
+	var network ipNetwork
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&network); err != nil {
+		return nil, fmt.Errorf("invalid RDAP response: %w", err)
+	}
+
+	return &models.WhoisResponse{
+		IP:           ip,
+		Handle:       network.Handle,
+		NetworkName:  network.Name,
+		Country:      network.Country,
+		StartAddress: network.StartAddress,
+		EndAddress:   network.EndAddress,
+		AbuseContact: abuseContact(network.Entities),
+		Registry:     resp.Request.URL.Host,
+		Timestamp:    c.now().UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// ipNetwork is the subset of an RDAP IP network object (RFC 9083) used in the response
+type ipNetwork struct {
+	Handle       string   `json:"handle"`
+	Name         string   `json:"name"`
+	Country      string   `json:"country"`
+	StartAddress string   `json:"startAddress"`
+	EndAddress   string   `json:"endAddress"`
+	Entities     []entity `json:"entities"`
+}
+
+// entity is an RDAP entity with its jCard contact details
+type entity struct {
+	Roles      []string          `json:"roles"`
+	VCardArray []json.RawMessage `json:"vcardArray"`
+	Entities   []entity          `json:"entities"`
+}
+
+// abuseContact finds the email of the first entity with the abuse role, searching nested entities
+func abuseContact(entities []entity) string {
+	for _, e := range entities {
+		for _, role := range e.Roles {
+			if role == "abuse" {
+				if email := e.email(); email != "" {
+					return email
+				}
+			}
+		}
+		if email := abuseContact(e.Entities); email != "" {
+			return email
+		}
+	}
+	return ""
+}
+
+// email extracts the email property from the entity's jCard (RFC 7095)
+func (e entity) email() string {
+	if len(e.VCardArray) != 2 {
+		return ""
+	}
+
+	var properties [][]json.RawMessage
+	if err := json.Unmarshal(e.VCardArray[1], &properties); err != nil {
+		return ""
+	}
+
+	for _, property := range properties {
+		if len(property) < 4 {
+			continue
+		}
+		var name, value string
+		if json.Unmarshal(property[0], &name) != nil || name != "email" {
+			continue
+		}
+		if json.Unmarshal(property[3], &value) == nil {
+			return value
+		}
+	}
+	return ""
+}
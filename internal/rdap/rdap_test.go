@@ -0,0 +1,210 @@
+package rdap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"myip/internal/breaker"
+	"myip/internal/models"
+)
+
+const sampleNetwork = `{
+	"objectClassName": "ip network",
+	"handle": "NET-203-0-113-0-1",
+	"name": "EXAMPLE-NET",
+	"country": "NL",
+	"startAddress": "203.0.113.0",
+	"endAddress": "203.0.113.255",
+	"entities": [
+		{
+			"roles": ["registrant"],
+			"vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Example BV"]]],
+			"entities": [
+				{
+					"roles": ["abuse"],
+					"vcardArray": ["vcard", [["fn", {}, "text", "Abuse"], ["email", {}, "text", "abuse@example.net"]]]
+				}
+			]
+		}
+	]
+}`
+
+// newRegistry starts a fake RDAP registry counting its queries
+func newRegistry(t *testing.T, queries *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries.Add(1)
+		if !strings.HasPrefix(r.URL.Path, "/ip/203.0.113.") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rdap+json")
+		w.Write([]byte(sampleNetwork))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLookup(t *testing.T) {
+	var queries atomic.Int32
+	registry := newRegistry(t, &queries)
+	client := NewClient(&http.Client{Timeout: time.Second}, registry.URL+"/ip", time.Hour, 10)
+
+	response, err := client.Lookup(context.Background(), "203.0.113.7")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+
+	if response.NetworkName != "EXAMPLE-NET" || response.Country != "NL" || response.Handle != "NET-203-0-113-0-1" {
+		t.Errorf("Unexpected network details: %+v", response)
+	}
+	if response.AbuseContact != "abuse@example.net" {
+		t.Errorf("Expected nested abuse contact, got %q", response.AbuseContact)
+	}
+	if response.StartAddress != "203.0.113.0" || response.EndAddress != "203.0.113.255" {
+		t.Errorf("Unexpected range %s-%s", response.StartAddress, response.EndAddress)
+	}
+
+	// Cached results skip the registry
+	if _, err := client.Lookup(context.Background(), "203.0.113.7"); err != nil {
+		t.Fatalf("Cached lookup failed: %v", err)
+	}
+	if queries.Load() != 1 {
+		t.Errorf("Expected 1 registry query, got %d", queries.Load())
+	}
+
+	if _, err := client.Lookup(context.Background(), "198.51.100.1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestLookupCacheExpiry(t *testing.T) {
+	var queries atomic.Int32
+	registry := newRegistry(t, &queries)
+	client := NewClient(&http.Client{Timeout: time.Second}, registry.URL+"/ip/", time.Minute, 0)
+
+	now := time.Now()
+	client.now = func() time.Time { return now }
+
+	client.Lookup(context.Background(), "203.0.113.7")
+	now = now.Add(2 * time.Minute)
+	client.Lookup(context.Background(), "203.0.113.7")
+
+	if queries.Load() != 2 {
+		t.Errorf("Expected expired entry to be refreshed, got %d queries", queries.Load())
+	}
+}
+
+func TestLookupRateLimit(t *testing.T) {
+	var queries atomic.Int32
+	registry := newRegistry(t, &queries)
+	client := NewClient(&http.Client{Timeout: time.Second}, registry.URL+"/ip/", time.Hour, 1)
+
+	if _, err := client.Lookup(context.Background(), "203.0.113.1"); err != nil {
+		t.Fatalf("First lookup failed: %v", err)
+	}
+
+	_, err := client.Lookup(context.Background(), "203.0.113.2")
+	var limited *RateLimitError
+	if !errors.As(err, &limited) || limited.RetryAfter <= 0 {
+		t.Errorf("Expected RateLimitError with retry hint, got %v", err)
+	}
+
+	// Cached addresses are still served while the budget is exhausted
+	if _, err := client.Lookup(context.Background(), "203.0.113.1"); err != nil {
+		t.Errorf("Expected cached lookup to bypass the limit, got %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var queries atomic.Int32
+	registry := newRegistry(t, &queries)
+	handler := NewHandler(NewClient(&http.Client{Timeout: time.Second}, registry.URL+"/ip/", time.Hour, 10))
+
+	tests := []struct {
+		name         string
+		clientIP     string
+		expectedCode int
+	}{
+		{"Public IP", "203.0.113.7", http.StatusOK},
+		{"Private IP", "192.168.1.10", http.StatusBadRequest},
+		{"Unknown network", "198.51.100.1", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/whois", nil)
+			req.Header.Set("CF-Connecting-IP", test.clientIP)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != test.expectedCode {
+				t.Fatalf("Expected status %d, got %d: %s", test.expectedCode, rr.Code, rr.Body.String())
+			}
+
+			if test.expectedCode == http.StatusOK {
+				var response models.WhoisResponse
+				if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if response.IP != test.clientIP || response.AbuseContact != "abuse@example.net" {
+					t.Errorf("Unexpected response %+v", response)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlerUpstreamFailure(t *testing.T) {
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusInternalServerError)
+	}))
+	defer registry.Close()
+
+	req := httptest.NewRequest("GET", "/whois", nil)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.7")
+	rr := httptest.NewRecorder()
+	NewHandler(NewClient(&http.Client{Timeout: time.Second}, registry.URL, time.Hour, 10)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502, got %d", rr.Code)
+	}
+}
+
+func TestHandlerBreaker(t *testing.T) {
+	defer breaker.Configure(breaker.Settings{})
+	breaker.Configure(breaker.Settings{Threshold: 1, Backoff: time.Minute, MaxBackoff: time.Minute})
+
+	var queries atomic.Int32
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries.Add(1)
+		http.Error(w, "overloaded", http.StatusInternalServerError)
+	}))
+	defer registry.Close()
+
+	client := NewClient(&http.Client{Timeout: time.Second}, registry.URL, time.Hour, 10)
+	client.breaker = breaker.Get("rdap-test")
+	handler := NewHandler(client)
+
+	for i, code := range []int{http.StatusBadGateway, http.StatusServiceUnavailable} {
+		req := httptest.NewRequest("GET", "/whois", nil)
+		req.Header.Set("CF-Connecting-IP", "203.0.113.7")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != code {
+			t.Errorf("Request %d: expected status %d, got %d", i+1, code, rr.Code)
+		}
+	}
+	if queries.Load() != 1 {
+		t.Errorf("Expected the open breaker to stop queries, got %d", queries.Load())
+	}
+}
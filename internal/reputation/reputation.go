@@ -0,0 +1,223 @@
+package reputation
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"myip/internal/breaker"
+	"myip/internal/logging"
+	"myip/internal/models"
+)
+
+// maxFeedBytes bounds the size of a downloaded feed
+const maxFeedBytes = 16 << 20
+
+var logger = logging.For("reputation")
+
+// Feed is a threat-intel list of addresses and CIDR ranges, read from a local file or an http(s) URL
+type Feed struct {
+	Name   string
+	Source string
+}
+
+// ParseFeeds parses "name=source" entries such as "spamhaus-drop=https://www.spamhaus.org/drop/drop.txt"
+func ParseFeeds(entries []string) ([]Feed, error) {
+	feeds := make([]Feed, 0, len(entries))
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		name, source, ok := strings.Cut(entry, "=")
+		name, source = strings.TrimSpace(name), strings.TrimSpace(source)
+		if !ok || name == "" || source == "" {
+			return nil, fmt.Errorf("invalid threat feed %q, expected name=source", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate threat feed %q", name)
+		}
+		seen[name] = true
+		feeds = append(feeds, Feed{Name: name, Source: source})
+	}
+	return feeds, nil
+}
+
+// list is the loaded contents of a feed
+type list struct {
+	prefixes []netip.Prefix
+	version  string
+	loadedAt time.Time
+}
+
+// Lists holds the loaded threat feeds and reports which of them list an address
+type Lists struct {
+	httpClient *http.Client
+	feeds      []Feed
+
+	mu    sync.RWMutex
+	lists map[string]*list
+	now   func() time.Time
+}
+
+// New creates Lists for feeds, fetching URL sources through httpClient. Nothing is listed until Refresh is called.
+func New(httpClient *http.Client, feeds []Feed) *Lists {
+	return &Lists{
+		httpClient: httpClient,
+		feeds:      feeds,
+		lists:      make(map[string]*list),
+		now:        time.Now,
+	}
+}
+
+// Refresh reloads every feed. A feed that fails to load keeps its previous contents;
+// the returned error describes the first failure.
+func (l *Lists) Refresh(ctx context.Context) error {
+	var firstErr error
+	for _, feed := range l.feeds {
+		loaded, err := l.load(ctx, feed)
+		if err != nil {
+			logging.Warnf("Threat feed %s not refreshed: %v", feed.Name, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("threat feed %s: %w", feed.Name, err)
+			}
+			continue
+		}
+
+		l.mu.Lock()
+		l.lists[feed.Name] = loaded
+		l.mu.Unlock()
+		logger.Debugf("Loaded threat feed %s: %d entries, version %s", feed.Name, len(loaded.prefixes), loaded.version)
+	}
+	return firstErr
+}
+
+// Listed returns the names of the feeds listing ip, in configuration order
+func (l *Lists) Listed(ip string) []string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil
+	}
+	addr = addr.Unmap()
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var feeds []string
+	for _, feed := range l.feeds {
+		loaded, ok := l.lists[feed.Name]
+		if !ok {
+			continue
+		}
+		for _, prefix := range loaded.prefixes {
+			if prefix.Contains(addr) {
+				feeds = append(feeds, feed.Name)
+				break
+			}
+		}
+	}
+	return feeds
+}
+
+// Datasets describes the loaded feeds for the boot report
+func (l *Lists) Datasets() []models.BootDataset {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	now := l.now()
+	var datasets []models.BootDataset
+	for _, feed := range l.feeds {
+		loaded, ok := l.lists[feed.Name]
+		if !ok {
+			continue
+		}
+		datasets = append(datasets, models.BootDataset{
+			Name:       "threat-feed:" + feed.Name,
+			Version:    loaded.version,
+			LoadedAt:   loaded.loadedAt.UTC().Format(time.RFC3339),
+			AgeSeconds: int64(now.Sub(loaded.loadedAt).Seconds()),
+		})
+	}
+	return datasets
+}
+
+// load reads and parses a feed from its source
+func (l *Lists) load(ctx context.Context, feed Feed) (*list, error) {
+	data, err := l.read(ctx, feed.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	return &list{
+		prefixes: parse(data),
+		version:  hex.EncodeToString(sum[:8]),
+		loadedAt: l.now(),
+	}, nil
+}
+
+// read returns the contents of a local file or http(s) URL
+func (l *Lists) read(ctx context.Context, source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	err = breaker.Get("feed:" + req.URL.Host).Do(func() error {
+		resp, err := l.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("feed returned %s", resp.Status)
+		}
+		data, err = io.ReadAll(io.LimitReader(resp.Body, maxFeedBytes))
+		return err
+	})
+	return data, err
+}
+
+// parse extracts addresses and CIDR ranges, one per line. Text after ";" or "#" is a comment,
+// as in the Spamhaus DROP format ("192.0.2.0/24 ; SBL123"); unparseable lines are skipped.
+func parse(data []byte) []netip.Prefix {
+	var prefixes []netip.Prefix
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, ";#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if prefix, err := netip.ParsePrefix(fields[0]); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(fields[0]); err == nil {
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return prefixes
+}
@@ -0,0 +1,125 @@
+package reputation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const sampleDROP = `; Spamhaus DROP List 2026/10/15 - (c) 2026 The Spamhaus Project
+; Last-Modified: Thu, 15 Oct 2026 08:00:00 GMT
+192.0.2.0/24 ; SBL000001
+198.51.100.0/22 ; SBL000002
+`
+
+func TestParseFeeds(t *testing.T) {
+	feeds, err := ParseFeeds([]string{"drop=https://example.com/drop.txt", " local = /etc/denylist.txt"})
+	if err != nil {
+		t.Fatalf("ParseFeeds returned error: %v", err)
+	}
+	if len(feeds) != 2 || feeds[1].Name != "local" || feeds[1].Source != "/etc/denylist.txt" {
+		t.Errorf("Unexpected feeds %+v", feeds)
+	}
+
+	for _, entries := range [][]string{{"drop"}, {"=/tmp/list"}, {"a=/x", "a=/y"}} {
+		if _, err := ParseFeeds(entries); err == nil {
+			t.Errorf("Expected error for %v", entries)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	prefixes := parse([]byte(sampleDROP + "# comment\n203.0.113.7\n2001:db8::/32\nnot-an-ip\n"))
+	if len(prefixes) != 4 {
+		t.Fatalf("Expected 4 prefixes, got %v", prefixes)
+	}
+	if prefixes[2].String() != "203.0.113.7/32" {
+		t.Errorf("Expected bare address as /32, got %s", prefixes[2])
+	}
+}
+
+func TestListsFileAndURL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	if err := os.WriteFile(path, []byte("203.0.113.7\n192.0.2.10\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(sampleDROP))
+	}))
+	defer server.Close()
+
+	lists := New(server.Client(), []Feed{{Name: "drop", Source: server.URL}, {Name: "local", Source: path}})
+	if feeds := lists.Listed("192.0.2.10"); feeds != nil {
+		t.Errorf("Expected nothing listed before Refresh, got %v", feeds)
+	}
+
+	if err := lists.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+
+	tests := []struct {
+		ip    string
+		feeds string
+	}{
+		{"192.0.2.10", "drop,local"},
+		{"198.51.103.255", "drop"},
+		{"::ffff:203.0.113.7", "local"},
+		{"203.0.113.8", ""},
+		{"invalid", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(lists.Listed(tt.ip), ","); got != tt.feeds {
+			t.Errorf("Listed(%s) = %q, want %q", tt.ip, got, tt.feeds)
+		}
+	}
+
+	datasets := lists.Datasets()
+	if len(datasets) != 2 || datasets[0].Name != "threat-feed:drop" || datasets[0].Version == "" {
+		t.Errorf("Unexpected datasets %+v", datasets)
+	}
+}
+
+func TestRefreshKeepsPreviousListOnFailure(t *testing.T) {
+	var fail atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(sampleDROP))
+	}))
+	defer server.Close()
+
+	lists := New(server.Client(), []Feed{{Name: "drop", Source: server.URL}})
+	if err := lists.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+
+	fail.Store(true)
+	if err := lists.Refresh(context.Background()); err == nil {
+		t.Error("Expected error when the feed is unavailable")
+	}
+	if feeds := lists.Listed("192.0.2.1"); len(feeds) != 1 {
+		t.Errorf("Expected previous list to be kept, got %v", feeds)
+	}
+}
+
+func TestDatasetsNil(t *testing.T) {
+	var lists *Lists
+	if datasets := lists.Datasets(); datasets != nil {
+		t.Errorf("Expected no datasets for nil Lists, got %v", datasets)
+	}
+
+	lists = New(http.DefaultClient, nil)
+	lists.now = func() time.Time { return time.Unix(0, 0) }
+	if datasets := lists.Datasets(); datasets != nil {
+		t.Errorf("Expected no datasets before loading, got %v", datasets)
+	}
+}
@@ -0,0 +1,64 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		incoming   string
+		expectKept bool
+	}{
+		{"Generated when missing", "", false},
+		{"Incoming ID kept", "abc-123_DEF.4", true},
+		{"Invalid incoming ID replaced", "bad id\nwith newline", false},
+		{"Overlong incoming ID replaced", string(make([]byte, 65)), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var seen string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = FromContext(r.Context())
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if test.incoming != "" {
+				req.Header.Set(Header, test.incoming)
+			}
+
+			rr := httptest.NewRecorder()
+			Middleware(next).ServeHTTP(rr, req)
+
+			if seen == "" {
+				t.Fatal("Expected request ID in context")
+			}
+			if rr.Header().Get(Header) != seen {
+				t.Errorf("Expected response header %q to match context ID %q", rr.Header().Get(Header), seen)
+			}
+			if test.expectKept && seen != test.incoming {
+				t.Errorf("Expected incoming ID %q to be kept, got %q", test.incoming, seen)
+			}
+			if !test.expectKept && seen == test.incoming {
+				t.Errorf("Expected incoming ID %q to be replaced", test.incoming)
+			}
+		})
+	}
+}
+
+func TestFromContextEmpty(t *testing.T) {
+	if id := FromContext(context.Background()); id != "" {
+		t.Errorf("Expected empty ID, got %q", id)
+	}
+}
+
+func TestNewUnique(t *testing.T) {
+	a, b := New(), New()
+	if len(a) != 32 || a == b {
+		t.Errorf("Expected unique 32-character IDs, got %q and %q", a, b)
+	}
+}
@@ -0,0 +1,155 @@
+package respcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minGzipSize is the smallest body worth compressing; smaller bodies such as a bare IP
+// address are always sent as-is and without a Vary header
+const minGzipSize = 128
+
+// Response is a pre-serialized response body with its gzip-compressed form
+type Response struct {
+	Body []byte
+
+	length   []string
+	gzipOnce sync.Once
+	gzipped  []byte
+}
+
+// NewResponse wraps a serialized body
+func NewResponse(body []byte) *Response {
+	return &Response{Body: body, length: []string{strconv.Itoa(len(body))}}
+}
+
+// Gzipped returns the gzip-compressed body, compressing it on first use.
+// It returns nil when compression does not make the body smaller.
+func (r *Response) Gzipped() []byte {
+	if len(r.Body) < minGzipSize {
+		return nil
+	}
+	r.gzipOnce.Do(func() {
+		var buf bytes.Buffer
+		zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		zw.Write(r.Body)
+		zw.Close()
+		if buf.Len() < len(r.Body) {
+			r.gzipped = buf.Bytes()
+		}
+	})
+	return r.gzipped
+}
+
+// entry is a cached response
+type entry struct {
+	response *Response
+	expires  time.Time
+}
+
+// Cache holds pre-serialized responses keyed by everything that determines their body
+type Cache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	entries    map[string]entry
+	now        func() time.Time
+}
+
+// New creates a cache holding at most maxEntries responses for ttl each
+func New(maxEntries int, ttl time.Duration) *Cache {
+	return &Cache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		entries:    make(map[string]entry),
+		now:        time.Now,
+	}
+}
+
+// Get returns the cached response for key, serializing it with build on a miss
+func (c *Cache) Get(key string, build func() ([]byte, error)) (*Response, error) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && c.now().Before(e.expires) {
+		return e.response, nil
+	}
+
+	body, err := build()
+	if err != nil {
+		return nil, err
+	}
+	response := NewResponse(body)
+	c.store(key, response)
+	return response, nil
+}
+
+// Len returns the number of cached responses
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// store caches response for key, evicting expired entries when the cache is full
+func (c *Cache) store(key string, response *Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if len(c.entries) >= c.maxEntries {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		// Still full: drop an arbitrary entry
+		for k := range c.entries {
+			if len(c.entries) < c.maxEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = entry{response: response, expires: now.Add(c.ttl)}
+}
+
+// Write sends response with contentType, gzip-encoded when the client accepts it and compression helps
+func Write(w http.ResponseWriter, r *http.Request, contentType string, response *Response) {
+	header := w.Header()
+	header["Content-Type"] = []string{contentType}
+
+	if len(response.Body) >= minGzipSize {
+		header.Add("Vary", "Accept-Encoding")
+		if AcceptsGzip(r) {
+			if gzipped := response.Gzipped(); gzipped != nil {
+				header["Content-Encoding"] = []string{"gzip"}
+				header["Content-Length"] = []string{strconv.Itoa(len(gzipped))}
+				w.Write(gzipped)
+				return
+			}
+		}
+	}
+
+	header["Content-Length"] = response.length
+	w.Write(response.Body)
+}
+
+// AcceptsGzip reports whether the request's Accept-Encoding allows gzip
+func AcceptsGzip(r *http.Request) bool {
+	for _, coding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(coding), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), "gzip") && strings.TrimSpace(name) != "*" {
+			continue
+		}
+		// q=0 explicitly refuses the coding
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package respcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGet(t *testing.T) {
+	c := New(10, time.Minute)
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+
+	builds := 0
+	build := func() ([]byte, error) {
+		builds++
+		return []byte("203.0.113.1"), nil
+	}
+
+	for i := 0; i < 3; i++ {
+		response, err := c.Get("ipv4", build)
+		if err != nil || string(response.Body) != "203.0.113.1" {
+			t.Fatalf("Unexpected response %q, err=%v", response.Body, err)
+		}
+	}
+	if builds != 1 {
+		t.Errorf("Expected a single build, got %d", builds)
+	}
+
+	now = now.Add(time.Minute)
+	c.Get("ipv4", build)
+	if builds != 2 {
+		t.Errorf("Expected a rebuild after the TTL, got %d builds", builds)
+	}
+
+	if _, err := c.Get("broken", func() ([]byte, error) { return nil, errors.New("encode failed") }); err == nil {
+		t.Error("Expected build error to be returned")
+	}
+	if c.Len() != 1 {
+		t.Errorf("Expected failed builds not to be cached, got %d entries", c.Len())
+	}
+}
+
+func TestEviction(t *testing.T) {
+	c := New(2, time.Minute)
+	for _, key := range []string{"a", "b", "c"} {
+		c.Get(key, func() ([]byte, error) { return []byte(key), nil })
+	}
+	if c.Len() != 2 {
+		t.Errorf("Expected cache bounded at 2 entries, got %d", c.Len())
+	}
+}
+
+func TestWrite(t *testing.T) {
+	body := []byte(strings.Repeat(`{"client_ip":"203.0.113.1"}`, 10))
+	response := NewResponse(body)
+
+	rr := httptest.NewRecorder()
+	Write(rr, httptest.NewRequest("GET", "/json", nil), "application/json", response)
+	if !bytes.Equal(rr.Body.Bytes(), body) || rr.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Expected identity response, got %q", rr.Body.String())
+	}
+	if rr.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding, got %q", rr.Header().Get("Vary"))
+	}
+
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip")
+	rr = httptest.NewRecorder()
+	Write(rr, req, "application/json", response)
+
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip response, got headers %v", rr.Header())
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, _ := io.ReadAll(zr)
+	if !bytes.Equal(decoded, body) {
+		t.Errorf("Decoded body mismatch: %q", decoded)
+	}
+}
+
+func TestWriteSmallBodyUncompressed(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+
+	Write(rr, req, "text/plain", NewResponse([]byte("203.0.113.1")))
+
+	if rr.Header().Get("Content-Encoding") != "" || rr.Body.String() != "203.0.113.1" {
+		t.Errorf("Expected small body to be sent uncompressed, got %q", rr.Body.String())
+	}
+	if rr.Header().Get("Content-Length") != "11" {
+		t.Errorf("Expected Content-Length 11, got %s", rr.Header().Get("Content-Length"))
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := map[string]bool{
+		"":                  false,
+		"gzip":              true,
+		"deflate, GZIP":     true,
+		"gzip;q=0.5, br":    true,
+		"gzip;q=0":          false,
+		"br, *":             true,
+		"identity, deflate": false,
+	}
+	for header, want := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", header)
+		if got := AcceptsGzip(req); got != want {
+			t.Errorf("AcceptsGzip(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
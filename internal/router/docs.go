@@ -0,0 +1,228 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"myip/internal/models"
+	"myip/internal/problem"
+)
+
+// Docs returns the documentation of every registered route, sorted by path and method
+func (r *Router) Docs() []models.RouteInfo {
+	r.routes.mu.RLock()
+	defer r.routes.mu.RUnlock()
+
+	docs := make([]models.RouteInfo, 0, len(r.routes.docs))
+	for _, route := range r.routes.docs {
+		docs = append(docs, route.info)
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if docs[i].Path != docs[j].Path {
+			return docs[i].Path < docs[j].Path
+		}
+		return docs[i].Method < docs[j].Method
+	})
+	return docs
+}
+
+// Handler serves the route documentation
+func (r *Router) Handler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(r.Docs()); err != nil {
+		problem.Error(w, req, http.StatusInternalServerError, "Failed to encode routes")
+		return
+	}
+}
+
+// Param documents a query parameter of a route
+type Param struct {
+	Name        string
+	Description string
+
+	// Type is the OpenAPI type of the value: "string" when empty, "integer", "number", or "boolean"
+	Type     string
+	Enum     []string
+	Required bool
+}
+
+// response documents the body of a route's response in one media type
+type response struct {
+	status      int
+	description string
+	mediaType   string
+	body        any
+}
+
+// Query documents query parameters of the route
+func (rt *Route) Query(params ...Param) *Route {
+	rt.params = append(rt.params, params...)
+	return rt
+}
+
+// Returns documents a response of the route. Its schema is derived from body, a value of the Go
+// type encoded in the response, such as models.IPInfo{}; a string documents a plain-text body and
+// nil a body without schema. Calls for the same status with different media types add
+// alternative representations.
+func (rt *Route) Returns(status int, description, mediaType string, body any) *Route {
+	rt.responses = append(rt.responses, response{status, description, mediaType, body})
+	return rt
+}
+
+// Accepts documents the request body of the route in mediaType, with the schema derived from body
+// as for Returns
+func (rt *Route) Accepts(mediaType string, body any) *Route {
+	rt.request = &response{mediaType: mediaType, body: body}
+	return rt
+}
+
+// OpenAPI builds an OpenAPI 3 document describing the registered routes, with the schemas of
+// their documented responses as components
+func (r *Router) OpenAPI(title, version string) map[string]any {
+	r.routes.mu.RLock()
+	routes := append([]*Route(nil), r.routes.docs...)
+	r.routes.mu.RUnlock()
+
+	paths := map[string]map[string]any{}
+	schemes := map[string]any{}
+	types := &schemas{components: map[string]any{}}
+	for _, route := range routes {
+		doc := route.info
+		operation := map[string]any{
+			"summary":      doc.Description,
+			"deprecated":   doc.Stability == StabilityDeprecated,
+			"responses":    types.responses(append(append([]response(nil), route.responses...), route.shared...)),
+			"x-rate-limit": doc.RateLimit,
+			"x-stability":  doc.Stability,
+		}
+		if doc.Auth != "none" {
+			operation["security"] = []map[string][]string{{doc.Auth: {}}}
+			schemes[doc.Auth] = map[string]string{"type": "http", "scheme": doc.Auth}
+		}
+		if route.request != nil {
+			operation["requestBody"] = map[string]any{
+				"content": map[string]any{
+					route.request.mediaType: map[string]any{"schema": types.of(reflect.TypeOf(route.request.body))},
+				},
+			}
+		}
+		if params := append(pathParams(doc.Path), queryParams(route.params)...); len(params) > 0 {
+			operation["parameters"] = params
+		}
+
+		if paths[doc.Path] == nil {
+			paths[doc.Path] = map[string]any{}
+		}
+		paths[doc.Path][strings.ToLower(doc.Method)] = operation
+	}
+
+	document := map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]string{"title": title, "version": version},
+		"paths":   paths,
+	}
+	components := map[string]any{}
+	if len(schemes) > 0 {
+		components["securitySchemes"] = schemes
+	}
+	if len(types.components) > 0 {
+		components["schemas"] = types.components
+	}
+	if len(components) > 0 {
+		document["components"] = components
+	}
+	return document
+}
+
+// responses describes the documented responses by status, or a generic default response when
+// there are none
+func (s *schemas) responses(documented []response) map[string]any {
+	if len(documented) == 0 {
+		return map[string]any{"default": map[string]any{"description": "Response"}}
+	}
+
+	responses := map[string]any{}
+	for _, resp := range documented {
+		status := strconv.Itoa(resp.status)
+		entry, ok := responses[status].(map[string]any)
+		if !ok {
+			entry = map[string]any{"description": resp.description}
+			responses[status] = entry
+		}
+		if resp.mediaType == "" {
+			continue
+		}
+
+		content, ok := entry["content"].(map[string]any)
+		if !ok {
+			content = map[string]any{}
+			entry["content"] = content
+		}
+		media := map[string]any{}
+		if resp.body != nil {
+			media["schema"] = s.of(reflect.TypeOf(resp.body))
+		}
+		content[resp.mediaType] = media
+	}
+	return responses
+}
+
+// queryParams describes the documented query parameters
+func queryParams(documented []Param) []map[string]any {
+	params := make([]map[string]any, 0, len(documented))
+	for _, param := range documented {
+		schema := map[string]any{"type": "string"}
+		if param.Type != "" {
+			schema["type"] = param.Type
+		}
+		if len(param.Enum) > 0 {
+			schema["enum"] = param.Enum
+		}
+		params = append(params, map[string]any{
+			"name":        param.Name,
+			"in":          "query",
+			"description": param.Description,
+			"required":    param.Required,
+			"schema":      schema,
+		})
+	}
+	return params
+}
+
+// OpenAPIHandler serves the generated OpenAPI document
+func (r *Router) OpenAPIHandler(title, version string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(r.OpenAPI(title, version)); err != nil {
+			problem.Error(w, req, http.StatusInternalServerError, "Failed to encode OpenAPI document")
+			return
+		}
+	}
+}
+
+// pathParams describes the {name} wildcards in path as OpenAPI path parameters
+func pathParams(path string) []map[string]any {
+	var params []map[string]any
+	for _, segment := range strings.Split(path, "/") {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+		name := strings.TrimSuffix(strings.Trim(segment, "{}"), "...")
+		if name == "$" {
+			continue
+		}
+		params = append(params, map[string]any{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]string{"type": "string"},
+		})
+	}
+	return params
+}
@@ -0,0 +1,236 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"myip/internal/models"
+)
+
+func newDocumentedRouter() *Router {
+	r := New(http.NewServeMux())
+	r.Get("/json", func(w http.ResponseWriter, req *http.Request) {}).
+		Describe("Comprehensive JSON response").
+		Example("?pretty=1")
+	r.Get("/lookup/{ip}", func(w http.ResponseWriter, req *http.Request) {}).
+		Describe("Look up an IP").
+		RateLimit("lookup").
+		Stability(StabilityExperimental)
+
+	admin := r.Group("/admin").RequireAuth("bearer")
+	admin.Post("/maintenance", func(w http.ResponseWriter, req *http.Request) {}).
+		Describe("Toggle maintenance mode")
+	return r
+}
+
+func TestDocs(t *testing.T) {
+	docs := newDocumentedRouter().Docs()
+
+	expected := []models.RouteInfo{
+		{Method: "POST", Path: "/admin/maintenance", Description: "Toggle maintenance mode", Auth: "bearer", RateLimit: "none", Stability: StabilityStable},
+		{Method: "GET", Path: "/json", Description: "Comprehensive JSON response", Auth: "none", RateLimit: "none", Stability: StabilityStable, Examples: []string{"?pretty=1"}},
+		{Method: "GET", Path: "/lookup/{ip}", Description: "Look up an IP", Auth: "none", RateLimit: "lookup", Stability: StabilityExperimental},
+	}
+
+	if len(docs) != len(expected) {
+		t.Fatalf("Expected %d routes, got %d: %+v", len(expected), len(docs), docs)
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(docs[i], expected[i]) {
+			t.Errorf("Route %d: expected %+v, got %+v", i, expected[i], docs[i])
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := newDocumentedRouter()
+
+	rr := httptest.NewRecorder()
+	r.Handler(rr, httptest.NewRequest("GET", "/routes", nil))
+
+	if rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected application/json, got %s", rr.Header().Get("Content-Type"))
+	}
+
+	var docs []models.RouteInfo
+	if err := json.NewDecoder(rr.Body).Decode(&docs); err != nil {
+		t.Fatalf("Failed to decode routes: %v", err)
+	}
+	if len(docs) != 3 {
+		t.Errorf("Expected 3 routes, got %d", len(docs))
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	rr := httptest.NewRecorder()
+	newDocumentedRouter().OpenAPIHandler("MyIP API", "1.0")(rr, httptest.NewRequest("GET", "/openapi.json", nil))
+
+	var document struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Summary    string                `json:"summary"`
+			Stability  string                `json:"x-stability"`
+			Security   []map[string][]string `json:"security"`
+			Parameters []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+		} `json:"paths"`
+		Components struct {
+			SecuritySchemes map[string]any `json:"securitySchemes"`
+		} `json:"components"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&document); err != nil {
+		t.Fatalf("Failed to decode OpenAPI document: %v", err)
+	}
+
+	if document.OpenAPI != "3.0.3" {
+		t.Errorf("Expected OpenAPI 3.0.3, got %s", document.OpenAPI)
+	}
+
+	lookup := document.Paths["/lookup/{ip}"]["get"]
+	if lookup.Summary != "Look up an IP" || lookup.Stability != StabilityExperimental {
+		t.Errorf("Unexpected lookup operation: %+v", lookup)
+	}
+	if len(lookup.Parameters) != 1 || lookup.Parameters[0].Name != "ip" || lookup.Parameters[0].In != "path" {
+		t.Errorf("Expected ip path parameter, got %+v", lookup.Parameters)
+	}
+
+	if security := document.Paths["/admin/maintenance"]["post"].Security; len(security) != 1 {
+		t.Errorf("Expected admin route to require auth, got %+v", security)
+	}
+	if _, ok := document.Components.SecuritySchemes["bearer"]; !ok {
+		t.Error("Expected bearer security scheme")
+	}
+}
+
+// documentedResult is a response body with nested, optional, and nullable fields
+type documentedResult struct {
+	IP     string            `json:"ip"`
+	Port   int               `json:"port,omitempty"`
+	Listed *bool             `json:"listed"`
+	Tags   []string          `json:"tags,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Nested *documentedResult `json:"nested,omitempty"`
+	Secret string            `json:"-"`
+}
+
+func TestOpenAPIResponses(t *testing.T) {
+	r := New(http.NewServeMux())
+	r.Get("/lookup", func(w http.ResponseWriter, req *http.Request) {}).
+		Describe("Look up an IP").
+		Query(Param{Name: "format", Description: "Response format", Enum: []string{"json", "jsonp"}},
+			Param{Name: "limit", Type: "integer", Required: true}).
+		Returns(http.StatusOK, "Result", "application/json", documentedResult{}).
+		Returns(http.StatusOK, "Result", "text/plain", "").
+		Returns(http.StatusNotFound, "Not found", "application/json", models.ErrorResponse{}).
+		Returns(http.StatusNoContent, "Nothing", "", nil)
+	r.Get("/plain", func(w http.ResponseWriter, req *http.Request) {})
+
+	data, err := json.Marshal(r.OpenAPI("MyIP API", "1.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var document struct {
+		Paths map[string]map[string]struct {
+			Parameters []struct {
+				Name     string         `json:"name"`
+				In       string         `json:"in"`
+				Required bool           `json:"required"`
+				Schema   map[string]any `json:"schema"`
+			} `json:"parameters"`
+			Responses map[string]struct {
+				Description string                    `json:"description"`
+				Content     map[string]map[string]any `json:"content"`
+			} `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Required   []string                  `json:"required"`
+				Properties map[string]map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatal(err)
+	}
+
+	lookup := document.Paths["/lookup"]["get"]
+	if len(lookup.Parameters) != 2 || lookup.Parameters[0].In != "query" || !lookup.Parameters[1].Required ||
+		lookup.Parameters[1].Schema["type"] != "integer" || len(lookup.Parameters[0].Schema["enum"].([]any)) != 2 {
+		t.Errorf("Unexpected parameters %+v", lookup.Parameters)
+	}
+
+	ok := lookup.Responses["200"]
+	if ok.Description != "Result" || len(ok.Content) != 2 {
+		t.Fatalf("Expected JSON and plain-text 200 responses, got %+v", ok)
+	}
+	if ref := ok.Content["application/json"]["schema"].(map[string]any)["$ref"]; ref != "#/components/schemas/documentedResult" {
+		t.Errorf("Expected a schema reference, got %v", ref)
+	}
+	if schema := ok.Content["text/plain"]["schema"].(map[string]any); schema["type"] != "string" {
+		t.Errorf("Expected a string schema for plain text, got %v", schema)
+	}
+	if lookup.Responses["204"].Content != nil {
+		t.Error("Expected no content for 204")
+	}
+	if _, ok := document.Paths["/plain"]["get"].Responses["default"]; !ok {
+		t.Error("Expected a default response for an undocumented route")
+	}
+
+	result := document.Components.Schemas["documentedResult"]
+	if !reflect.DeepEqual(result.Required, []string{"ip", "listed"}) {
+		t.Errorf("Expected ip and listed to be required, got %v", result.Required)
+	}
+	if len(result.Properties) != 6 {
+		t.Errorf("Expected 6 properties, got %v", result.Properties)
+	}
+	if result.Properties["listed"]["nullable"] != true || result.Properties["port"]["type"] != "integer" {
+		t.Errorf("Unexpected property schemas %v", result.Properties)
+	}
+	if result.Properties["nested"]["nullable"] != true || result.Properties["nested"]["allOf"] == nil {
+		t.Errorf("Expected a nullable reference for nested, got %v", result.Properties["nested"])
+	}
+	if _, ok := document.Components.Schemas["ErrorResponse"]; !ok {
+		t.Error("Expected the ErrorResponse component")
+	}
+}
+
+func TestOpenAPISharedResponses(t *testing.T) {
+	r := New(http.NewServeMux())
+	service := r.Group("").Returns(http.StatusServiceUnavailable, "Maintenance", "application/problem+json", models.Problem{})
+	service.Get("/json", func(w http.ResponseWriter, req *http.Request) {}).
+		Returns(http.StatusOK, "Result", "application/json", models.IPInfo{})
+	service.Put("/level", func(w http.ResponseWriter, req *http.Request) {}).
+		Accepts("application/json", struct {
+			Level string `json:"level"`
+		}{}).
+		Returns(http.StatusServiceUnavailable, "Unavailable", "text/plain", "")
+	r.Get("/health", func(w http.ResponseWriter, req *http.Request) {})
+
+	document := r.OpenAPI("MyIP API", "1.0")
+	paths := document["paths"].(map[string]map[string]any)
+
+	responses := paths["/json"]["get"].(map[string]any)["responses"].(map[string]any)
+	if _, ok := responses["200"]; !ok {
+		t.Error("Expected the route's own response")
+	}
+	if unavailable, ok := responses["503"].(map[string]any); !ok || unavailable["description"] != "Maintenance" {
+		t.Errorf("Expected the shared 503 response, got %v", responses["503"])
+	}
+
+	level := paths["/level"]["put"].(map[string]any)
+	if unavailable := level["responses"].(map[string]any)["503"].(map[string]any); unavailable["description"] != "Unavailable" {
+		t.Errorf("Expected the route's response to take precedence, got %v", unavailable)
+	}
+	if _, ok := level["requestBody"]; !ok {
+		t.Error("Expected a request body")
+	}
+
+	if _, ok := paths["/health"]["get"].(map[string]any)["responses"].(map[string]any)["503"]; ok {
+		t.Error("Expected shared responses to stay within their group")
+	}
+}
@@ -0,0 +1,291 @@
+package router
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"myip/internal/models"
+)
+
+// Route stability levels
+const (
+	StabilityStable       = "stable"
+	StabilityBeta         = "beta"
+	StabilityExperimental = "experimental"
+	StabilityDeprecated   = "deprecated"
+)
+
+// Middleware wraps a handler with additional behavior
+type Middleware func(http.Handler) http.Handler
+
+// Router registers method-specific routes such as GET /lookup/{ip} on a ServeMux.
+// Path parameters are read in handlers with r.PathValue.
+type Router struct {
+	mux        *http.ServeMux
+	prefix     string
+	middleware []Middleware
+	auth       string
+	responses  []response
+	routes     *routeTable
+}
+
+// routeTable is shared by a router and its groups
+type routeTable struct {
+	mu      sync.RWMutex
+	methods map[string][]string
+	docs    []*Route
+
+	// paths matches request paths to registered paths regardless of method, for Fallback
+	paths *http.ServeMux
+
+	// enabled and disabled restrict the paths registered; see Restrict
+	enabled, disabled []string
+}
+
+// Route holds the documentation of a registered route
+type Route struct {
+	info      models.RouteInfo
+	params    []Param
+	request   *response
+	responses []response
+
+	// shared are the responses documented on the router for all of its routes
+	shared []response
+}
+
+// Describe sets the route description
+func (rt *Route) Describe(description string) *Route {
+	rt.info.Description = description
+	return rt
+}
+
+// RateLimit sets the rate-limit class the route belongs to
+func (rt *Route) RateLimit(class string) *Route {
+	rt.info.RateLimit = class
+	return rt
+}
+
+// Stability sets the route stability level
+func (rt *Route) Stability(level string) *Route {
+	rt.info.Stability = level
+	return rt
+}
+
+// Example adds an example query string such as "?format=json", shown in the usage documentation
+func (rt *Route) Example(queries ...string) *Route {
+	rt.info.Examples = append(rt.info.Examples, queries...)
+	return rt
+}
+
+// New creates a router registering its routes on mux
+func New(mux *http.ServeMux) *Router {
+	return &Router{
+		mux:    mux,
+		routes: &routeTable{methods: make(map[string][]string), paths: http.NewServeMux()},
+	}
+}
+
+// Use appends middleware applied to routes registered afterwards, outermost first
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Restrict limits the routes registered afterwards to the paths matching an entry of enabled,
+// when it has any, and skips the paths matching an entry of disabled. An entry matches its own
+// path and the paths below it, so "/admin" matches "/admin/maintenance". Skipped routes are left
+// out of Routes and the documentation, and their requests reach Fallback.
+func (r *Router) Restrict(enabled, disabled []string) {
+	r.routes.mu.Lock()
+	defer r.routes.mu.Unlock()
+	r.routes.enabled, r.routes.disabled = enabled, disabled
+}
+
+// allowed reports whether path passes the restrictions set by Restrict
+func (t *routeTable) allowed(path string) bool {
+	if slices.ContainsFunc(t.disabled, func(entry string) bool { return covers(entry, path) }) {
+		return false
+	}
+	return len(t.enabled) == 0 || slices.ContainsFunc(t.enabled, func(entry string) bool { return covers(entry, path) })
+}
+
+// covers reports whether a Restrict entry matches path; "/" matches only the root path
+func covers(entry, path string) bool {
+	entry = strings.TrimSuffix(entry, "/")
+	return path == entry || path == entry+"/" || (entry != "" && strings.HasPrefix(path, entry+"/"))
+}
+
+// Group returns a router for routes under prefix that applies this router's middleware
+// followed by the given middleware
+func (r *Router) Group(prefix string, middleware ...Middleware) *Router {
+	return &Router{
+		mux:        r.mux,
+		prefix:     r.prefix + prefix,
+		middleware: append(append([]Middleware{}, r.middleware...), middleware...),
+		auth:       r.auth,
+		responses:  append([]response(nil), r.responses...),
+		routes:     r.routes,
+	}
+}
+
+// With returns a router applying this router's middleware followed by the given middleware, for
+// composing middleware on individual routes:
+//
+//	r.With(cache, limit).Get("/lookup/{ip}", handler)
+func (r *Router) With(middleware ...Middleware) *Router {
+	return r.Group("", middleware...)
+}
+
+// RequireAuth documents the authentication scheme required by routes registered afterwards.
+// It does not enforce authentication; pair it with the middleware that does.
+func (r *Router) RequireAuth(scheme string) *Router {
+	r.auth = scheme
+	return r
+}
+
+// Returns documents a response shared by the routes registered afterwards, such as the errors of
+// the router's middleware; see Route.Returns. Responses documented on a route take precedence.
+func (r *Router) Returns(status int, description, mediaType string, body any) *Router {
+	r.responses = append(r.responses, response{status, description, mediaType, body})
+	return r
+}
+
+// Handle registers handler for method and path. GET routes also answer HEAD, and every
+// path answers OPTIONS with 204 No Content and an Allow header listing its methods. The root
+// path "/" matches only itself, not every path as in ServeMux; see Fallback for the others.
+// Paths excluded by Restrict are not registered. The returned Route documents the route for /routes and the OpenAPI document.
+func (r *Router) Handle(method, path string, handler http.Handler) *Route {
+	path = r.prefix + path
+
+	auth := r.auth
+	if auth == "" {
+		auth = "none"
+	}
+	route := &Route{info: models.RouteInfo{
+		Method:    method,
+		Path:      path,
+		Auth:      auth,
+		RateLimit: "none",
+		Stability: StabilityStable,
+	}, shared: r.responses}
+
+	r.routes.mu.RLock()
+	allowed := r.routes.allowed(path)
+	r.routes.mu.RUnlock()
+	if !allowed {
+		return route
+	}
+
+	r.mux.Handle(method+" "+pattern(path), r.wrap(handler))
+
+	r.routes.mu.Lock()
+	defer r.routes.mu.Unlock()
+
+	// OPTIONS goes through the middleware of the path's first route, so it is authenticated too
+	if _, ok := r.routes.methods[path]; !ok {
+		r.mux.Handle(http.MethodOptions+" "+pattern(path), r.wrap(r.options(path)))
+		r.routes.paths.Handle(pattern(path), http.NotFoundHandler())
+	}
+	r.routes.methods[path] = append(r.routes.methods[path], method)
+	r.routes.docs = append(r.routes.docs, route)
+	return route
+}
+
+// wrap applies the router's middleware to handler, outermost first
+func (r *Router) wrap(handler http.Handler) http.Handler {
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
+	}
+	return handler
+}
+
+// Get registers a GET (and HEAD) route
+func (r *Router) Get(path string, handler http.HandlerFunc) *Route {
+	return r.Handle(http.MethodGet, path, handler)
+}
+
+// Post registers a POST route
+func (r *Router) Post(path string, handler http.HandlerFunc) *Route {
+	return r.Handle(http.MethodPost, path, handler)
+}
+
+// Put registers a PUT route
+func (r *Router) Put(path string, handler http.HandlerFunc) *Route {
+	return r.Handle(http.MethodPut, path, handler)
+}
+
+// Delete registers a DELETE route
+func (r *Router) Delete(path string, handler http.HandlerFunc) *Route {
+	return r.Handle(http.MethodDelete, path, handler)
+}
+
+// Fallback registers the handlers for requests no route matches: methodNotAllowed for a
+// registered path requested with another method, with the Allow header already set, and
+// notFound for any other path. The router's middleware applies to both.
+func (r *Router) Fallback(notFound, methodNotAllowed http.Handler) {
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.routes.mu.RLock()
+		_, matched := r.routes.paths.Handler(req)
+		r.routes.mu.RUnlock()
+
+		if matched == "" {
+			notFound.ServeHTTP(w, req)
+			return
+		}
+		w.Header().Set("Allow", r.allow(strings.TrimSuffix(matched, "{$}")))
+		methodNotAllowed.ServeHTTP(w, req)
+	})
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
+	}
+	r.mux.Handle("/", handler)
+}
+
+// pattern returns the ServeMux pattern of a route path, anchoring the root path so it does not
+// match every other path
+func pattern(path string) string {
+	if path == "/" {
+		return "/{$}"
+	}
+	return path
+}
+
+// options answers OPTIONS requests for path
+func (r *Router) options(path string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Allow", r.allow(path))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// allow lists the methods registered for path in the format of the Allow header
+func (r *Router) allow(path string) string {
+	r.routes.mu.RLock()
+	defer r.routes.mu.RUnlock()
+
+	var methods []string
+	for _, method := range r.routes.methods[path] {
+		methods = append(methods, method)
+		if method == http.MethodGet {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	return strings.Join(append(methods, http.MethodOptions), ", ")
+}
+
+// Routes returns the registered routes as sorted "METHOD /path" strings
+func (r *Router) Routes() []string {
+	r.routes.mu.RLock()
+	defer r.routes.mu.RUnlock()
+
+	var routes []string
+	for path, methods := range r.routes.methods {
+		for _, method := range methods {
+			routes = append(routes, method+" "+path)
+		}
+	}
+	sort.Strings(routes)
+	return routes
+}
@@ -0,0 +1,268 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestPathParams(t *testing.T) {
+	r := New(http.NewServeMux())
+	r.Get("/lookup/{ip}", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.PathValue("ip")))
+	})
+
+	rr := httptest.NewRecorder()
+	r.mux.ServeHTTP(rr, httptest.NewRequest("GET", "/lookup/203.0.113.1", nil))
+
+	if rr.Code != http.StatusOK || rr.Body.String() != "203.0.113.1" {
+		t.Errorf("Expected 200 with path param, got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestMethods(t *testing.T) {
+	r := New(http.NewServeMux())
+	r.Get("/maintenance", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("status"))
+	})
+	r.Post("/maintenance", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("toggled"))
+	})
+
+	tests := []struct {
+		method        string
+		expectedCode  int
+		expectedBody  string
+		expectedAllow string
+	}{
+		{"GET", http.StatusOK, "status", ""},
+		{"HEAD", http.StatusOK, "status", ""},
+		{"POST", http.StatusOK, "toggled", ""},
+		{"DELETE", http.StatusMethodNotAllowed, "", ""},
+		{"OPTIONS", http.StatusNoContent, "", "GET, HEAD, POST, OPTIONS"},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		r.mux.ServeHTTP(rr, httptest.NewRequest(test.method, "/maintenance", nil))
+
+		if rr.Code != test.expectedCode {
+			t.Errorf("%s: expected status %d, got %d", test.method, test.expectedCode, rr.Code)
+		}
+		if test.expectedBody != "" && rr.Body.String() != test.expectedBody {
+			t.Errorf("%s: expected body %q, got %q", test.method, test.expectedBody, rr.Body.String())
+		}
+		if test.expectedAllow != "" && rr.Header().Get("Allow") != test.expectedAllow {
+			t.Errorf("%s: expected Allow %q, got %q", test.method, test.expectedAllow, rr.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	r := New(http.NewServeMux())
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("X-Middleware", "applied")
+			next.ServeHTTP(w, req)
+		})
+	})
+	ok := func(w http.ResponseWriter, req *http.Request) { w.Write([]byte("ok")) }
+	r.Get("/", ok)
+	r.Get("/lookup/{ip}", ok)
+	r.Get("/docs/", ok)
+	r.Fallback(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "fallback", http.StatusNotFound)
+	}), http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "fallback", http.StatusMethodNotAllowed)
+	}))
+
+	tests := []struct {
+		method        string
+		target        string
+		expectedCode  int
+		expectedAllow string
+	}{
+		{"GET", "/", http.StatusOK, ""},
+		{"GET", "/foo", http.StatusNotFound, ""},
+		{"GET", "/lookup/203.0.113.1", http.StatusOK, ""},
+		{"GET", "/docs/index.html", http.StatusOK, ""},
+		{"GET", "/docs", http.StatusTemporaryRedirect, ""},
+		{"POST", "/", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{"DELETE", "/lookup/203.0.113.1", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{"POST", "/foo", http.StatusNotFound, ""},
+		{"OPTIONS", "/", http.StatusNoContent, "GET, HEAD, OPTIONS"},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		r.mux.ServeHTTP(rr, httptest.NewRequest(test.method, test.target, nil))
+
+		if rr.Code != test.expectedCode {
+			t.Errorf("%s %s: expected status %d, got %d", test.method, test.target, test.expectedCode, rr.Code)
+		}
+		if rr.Header().Get("Allow") != test.expectedAllow {
+			t.Errorf("%s %s: expected Allow %q, got %q", test.method, test.target, test.expectedAllow, rr.Header().Get("Allow"))
+		}
+		if rr.Code >= 400 && rr.Header().Get("X-Middleware") != "applied" {
+			t.Errorf("%s %s: expected the router middleware on the fallback", test.method, test.target)
+		}
+	}
+}
+
+func TestGroupMiddleware(t *testing.T) {
+	var order []string
+	tag := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, req)
+			})
+		}
+	}
+
+	r := New(http.NewServeMux())
+	r.Use(tag("outer"))
+	admin := r.Group("/admin", tag("auth"))
+	r.Use(tag("late"))
+
+	admin.Get("/boot-report", func(w http.ResponseWriter, req *http.Request) {
+		order = append(order, "handler")
+	})
+	r.Get("/health", func(w http.ResponseWriter, req *http.Request) {
+		order = append(order, "handler")
+	})
+
+	r.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/admin/boot-report", nil))
+	if expected := []string{"outer", "auth", "handler"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected group middleware order %v, got %v", expected, order)
+	}
+
+	order = nil
+	r.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+	if expected := []string{"outer", "late", "handler"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected router middleware order %v, got %v", expected, order)
+	}
+}
+
+func TestOptionsMiddleware(t *testing.T) {
+	r := New(http.NewServeMux())
+	admin := r.Group("/admin", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	})
+	admin.Get("/maintenance", func(w http.ResponseWriter, req *http.Request) {})
+
+	rr := httptest.NewRecorder()
+	r.mux.ServeHTTP(rr, httptest.NewRequest("OPTIONS", "/admin/maintenance", nil))
+	if rr.Code != http.StatusUnauthorized || rr.Header().Get("Allow") != "" {
+		t.Errorf("Expected OPTIONS to go through the group middleware, got %d %v", rr.Code, rr.Header())
+	}
+
+	req := httptest.NewRequest("OPTIONS", "/admin/maintenance", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	r.mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Allow") != "GET, HEAD, OPTIONS" {
+		t.Errorf("Expected the allowed methods once authenticated, got %d %v", rr.Code, rr.Header())
+	}
+}
+
+func TestWith(t *testing.T) {
+	var order []string
+	tag := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, req)
+			})
+		}
+	}
+
+	r := New(http.NewServeMux())
+	r.Use(tag("outer"))
+	r.With(tag("route"), tag("inner")).Get("/whois", func(w http.ResponseWriter, req *http.Request) {
+		order = append(order, "handler")
+	})
+	r.Get("/json", func(w http.ResponseWriter, req *http.Request) {
+		order = append(order, "handler")
+	})
+
+	r.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/whois", nil))
+	if expected := []string{"outer", "route", "inner", "handler"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected route middleware order %v, got %v", expected, order)
+	}
+
+	order = nil
+	r.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/json", nil))
+	if expected := []string{"outer", "handler"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected route middleware to apply only to its route, got %v", order)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	r := New(http.NewServeMux())
+	r.Get("/json", func(w http.ResponseWriter, req *http.Request) {})
+	admin := r.Group("/admin")
+	admin.Get("/loglevel", func(w http.ResponseWriter, req *http.Request) {})
+	admin.Put("/loglevel", func(w http.ResponseWriter, req *http.Request) {})
+
+	expected := []string{"GET /admin/loglevel", "GET /json", "PUT /admin/loglevel"}
+	if routes := r.Routes(); !reflect.DeepEqual(routes, expected) {
+		t.Errorf("Expected routes %v, got %v", expected, routes)
+	}
+}
+
+func TestRestrict(t *testing.T) {
+	ok := func(w http.ResponseWriter, req *http.Request) { w.Write([]byte("ok")) }
+	register := func(enabled, disabled []string) *Router {
+		r := New(http.NewServeMux())
+		r.Restrict(enabled, disabled)
+		r.Get("/", ok)
+		r.Get("/json", ok)
+		r.Get("/headers", ok)
+		r.Get("/lookup/{ip}", ok)
+		admin := r.Group("/admin")
+		admin.Get("/loglevel", ok)
+		admin.Get("/config", ok)
+		r.Fallback(http.NotFoundHandler(), http.NotFoundHandler())
+		return r
+	}
+
+	tests := []struct {
+		name     string
+		enabled  []string
+		disabled []string
+		expected []string
+	}{
+		{"Unrestricted", nil, nil, []string{"GET /", "GET /admin/config", "GET /admin/loglevel", "GET /headers", "GET /json", "GET /lookup/{ip}"}},
+		{"Disabled", nil, []string{"/headers", "/admin/"}, []string{"GET /", "GET /json", "GET /lookup/{ip}"}},
+		{"Enabled", []string{"/json", "/lookup", "/admin/config"}, nil, []string{"GET /admin/config", "GET /json", "GET /lookup/{ip}"}},
+		{"Disabled wins", []string{"/admin"}, []string{"/admin/config"}, []string{"GET /admin/loglevel"}},
+		{"Root only", []string{"/"}, nil, []string{"GET /"}},
+		{"No partial segments", nil, []string{"/json", "/look"}, []string{"GET /", "GET /admin/config", "GET /admin/loglevel", "GET /headers", "GET /lookup/{ip}"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := register(test.enabled, test.disabled)
+			if routes := r.Routes(); !reflect.DeepEqual(routes, test.expected) {
+				t.Errorf("Expected routes %v, got %v", test.expected, routes)
+			}
+		})
+	}
+
+	r := register(nil, []string{"/headers"})
+	rr := httptest.NewRecorder()
+	r.mux.ServeHTTP(rr, httptest.NewRequest("GET", "/headers", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected a disabled route to return 404, got %d", rr.Code)
+	}
+	if docs := r.Docs(); len(docs) != 5 {
+		t.Errorf("Expected disabled routes left out of the documentation, got %d routes", len(docs))
+	}
+}
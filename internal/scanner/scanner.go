@@ -0,0 +1,176 @@
+// Package scanner recognizes the paths vulnerability scanners probe for, such as /wp-login.php
+// or /.env, counts requests for them, and optionally bans the scanning clients for a while.
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"myip/internal/cache"
+	"myip/internal/ip"
+	"myip/internal/logging"
+)
+
+var logger = logging.For("scanner")
+
+// Patterns are the path segments that mark a scanner; a segment matches a pattern when it is
+// the pattern or starts with it followed by a dot, so .env also covers .env.local
+var Patterns = []string{
+	".aws",
+	".DS_Store",
+	".env",
+	".git",
+	".htaccess",
+	".svn",
+	"actuator",
+	"boaform",
+	"cgi-bin",
+	"config.php",
+	"phpinfo.php",
+	"phpmyadmin",
+	"phpunit",
+	"wp-admin",
+	"wp-config.php",
+	"wp-content",
+	"wp-login.php",
+	"xmlrpc.php",
+}
+
+// Detector counts scanner requests by pattern and bans clients that send more than a threshold
+// of them within a window
+type Detector struct {
+	threshold   int
+	window      time.Duration
+	banDuration time.Duration
+	clients     *cache.Memory
+
+	mu      sync.Mutex
+	hits    map[string]int64
+	bans    int64
+	blocked int64
+}
+
+// New creates a Detector that bans a client for banDuration once it sends threshold scanner
+// requests within window; a threshold of zero or less only counts them
+func New(threshold int, window, banDuration time.Duration) *Detector {
+	return &Detector{
+		threshold:   threshold,
+		window:      window,
+		banDuration: banDuration,
+		clients:     cache.NewMemory(cache.DefaultMaxEntries),
+		hits:        make(map[string]int64, len(Patterns)),
+	}
+}
+
+// Match returns the pattern matched by a segment of path, or "" when path is not a scanner probe
+func Match(path string) string {
+	for _, segment := range strings.Split(path, "/") {
+		for _, pattern := range Patterns {
+			if len(segment) < len(pattern) || !strings.EqualFold(segment[:len(pattern)], pattern) {
+				continue
+			}
+			if len(segment) == len(pattern) || segment[len(pattern)] == '.' {
+				return pattern
+			}
+		}
+	}
+	return ""
+}
+
+// NotFound counts the scanner probes among the requests answered by next, the handler for
+// unknown paths, and bans clients over the threshold
+func (d *Detector) NotFound(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if pattern := Match(r.URL.Path); pattern != "" {
+			d.record(r, pattern)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// record counts a probe for pattern and bans its client when it reaches the threshold
+func (d *Detector) record(r *http.Request, pattern string) {
+	d.mu.Lock()
+	d.hits[pattern]++
+	d.mu.Unlock()
+
+	clientIP, _ := ip.ExtractClientIP(r)
+	logger.Debugf("Scanner probe %s from %s matched %s", r.URL.Path, clientIP, pattern)
+	if d.threshold <= 0 || clientIP == "" {
+		return
+	}
+
+	// A Memory store never fails
+	ctx := context.Background()
+	count, _, _ := d.clients.Incr(ctx, "hits:"+clientIP, d.window)
+	if count != int64(d.threshold) {
+		return
+	}
+	until := time.Now().Add(d.banDuration)
+	d.clients.Set(ctx, "ban:"+clientIP, strconv.AppendInt(nil, until.Unix(), 10), d.banDuration)
+
+	d.mu.Lock()
+	d.bans++
+	d.mu.Unlock()
+	logging.Warnf("Banned %s for %v after %d scanner requests within %v", clientIP, d.banDuration, count, d.window)
+}
+
+// Middleware refuses requests from banned clients with 403 and a Retry-After header
+func (d *Detector) Middleware(next http.Handler) http.Handler {
+	if d.threshold <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientIP, _ := ip.ExtractClientIP(r)
+		value, banned, _ := d.clients.Get(r.Context(), "ban:"+clientIP)
+		if !banned {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		d.mu.Lock()
+		d.blocked++
+		d.mu.Unlock()
+
+		until, _ := strconv.ParseInt(string(value), 10, 64)
+		retryAfter := max(time.Until(time.Unix(until, 0)), time.Second)
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter/time.Second)))
+		http.Error(w, "Forbidden: client banned after probing for vulnerable paths", http.StatusForbidden)
+	})
+}
+
+// MetricsHandler serves the counters in the Prometheus text exposition format
+func (d *Detector) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	hits := make(map[string]int64, len(d.hits))
+	for pattern, count := range d.hits {
+		hits[pattern] = count
+	}
+	bans, blocked := d.bans, d.blocked
+	d.mu.Unlock()
+
+	patterns := slices.Clone(Patterns)
+	slices.Sort(patterns)
+
+	var b strings.Builder
+	b.WriteString("# HELP myip_scanner_requests_total Requests for paths probed by vulnerability scanners, by matched pattern.\n")
+	b.WriteString("# TYPE myip_scanner_requests_total counter\n")
+	for _, pattern := range patterns {
+		fmt.Fprintf(&b, "myip_scanner_requests_total{pattern=%q} %d\n", pattern, hits[pattern])
+	}
+	b.WriteString("# HELP myip_scanner_bans_total Clients banned for probing scanner paths.\n")
+	b.WriteString("# TYPE myip_scanner_bans_total counter\n")
+	fmt.Fprintf(&b, "myip_scanner_bans_total %d\n", bans)
+	b.WriteString("# HELP myip_scanner_blocked_requests_total Requests refused from banned clients.\n")
+	b.WriteString("# TYPE myip_scanner_blocked_requests_total counter\n")
+	fmt.Fprintf(&b, "myip_scanner_blocked_requests_total %d\n", blocked)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
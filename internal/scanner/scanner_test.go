@@ -0,0 +1,115 @@
+package scanner
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/wp-login.php", "wp-login.php"},
+		{"/blog/WP-Admin/install.php", "wp-admin"},
+		{"/.env", ".env"},
+		{"/app/.env.production", ".env"},
+		{"/.git/config", ".git"},
+		{"/environment", ""},
+		{"/.envy", ""},
+		{"/json", ""},
+		{"/", ""},
+	}
+
+	for _, test := range tests {
+		if result := Match(test.path); result != test.expected {
+			t.Errorf("Match(%q) = %q, expected %q", test.path, result, test.expected)
+		}
+	}
+}
+
+// probe sends a request for path from remoteAddr through the ban middleware and the counting
+// not-found handler
+func probe(handler http.Handler, path, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	req.RemoteAddr = remoteAddr
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestBan(t *testing.T) {
+	d := New(3, time.Minute, time.Hour)
+	handler := d.Middleware(d.NotFound(http.NotFoundHandler()))
+
+	for range 3 {
+		if rr := probe(handler, "/wp-login.php", "203.0.113.9:1234"); rr.Code != http.StatusNotFound {
+			t.Fatalf("Expected 404 before the ban, got %d", rr.Code)
+		}
+	}
+
+	rr := probe(handler, "/json", "203.0.113.9:1234")
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("Expected the scanner to be banned, got %d", rr.Code)
+	}
+	if retryAfter := rr.Header().Get("Retry-After"); retryAfter != "3600" && retryAfter != "3599" {
+		t.Errorf("Expected Retry-After of an hour, got %q", retryAfter)
+	}
+
+	if rr := probe(handler, "/json", "198.51.100.7:1234"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected other clients unaffected, got %d", rr.Code)
+	}
+	// Unknown paths that are not scanner probes do not count towards a ban
+	for range 5 {
+		probe(handler, "/favicon.ico", "198.51.100.7:1234")
+	}
+	if rr := probe(handler, "/json", "198.51.100.7:1234"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected no ban for ordinary unknown paths, got %d", rr.Code)
+	}
+}
+
+func TestCountOnly(t *testing.T) {
+	d := New(0, time.Minute, time.Hour)
+	handler := d.Middleware(d.NotFound(http.NotFoundHandler()))
+
+	for range 10 {
+		if rr := probe(handler, "/.env", "203.0.113.9:1234"); rr.Code != http.StatusNotFound {
+			t.Fatalf("Expected no bans with a zero threshold, got %d", rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	d.MetricsHandler(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(rr.Body.String(), `myip_scanner_requests_total{pattern=".env"} 10`) {
+		t.Errorf("Expected 10 .env probes, got %s", rr.Body.String())
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	d := New(1, time.Minute, time.Hour)
+	handler := d.Middleware(d.NotFound(http.NotFoundHandler()))
+	probe(handler, "/phpmyadmin/index.php", "203.0.113.9:1234")
+	probe(handler, "/", "203.0.113.9:1234")
+
+	rr := httptest.NewRecorder()
+	d.MetricsHandler(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	if contentType := rr.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Expected the Prometheus text format, got %s", contentType)
+	}
+	body := rr.Body.String()
+	for _, expected := range []string{
+		"# TYPE myip_scanner_requests_total counter\n",
+		`myip_scanner_requests_total{pattern="phpmyadmin"} 1` + "\n",
+		`myip_scanner_requests_total{pattern="wp-login.php"} 0` + "\n",
+		"myip_scanner_bans_total 1\n",
+		"myip_scanner_blocked_requests_total 1\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected metrics to contain %q, got %s", expected, body)
+		}
+	}
+}
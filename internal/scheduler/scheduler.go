@@ -0,0 +1,202 @@
+// Package scheduler runs the periodic background tasks of the service, such as feed refreshes
+// and cluster heartbeats, on jittered intervals, reports per-task metrics, and waits for running
+// tasks on shutdown.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"myip/internal/logging"
+)
+
+// Jitter is the fraction by which each wait is randomly shortened or lengthened, so replicas
+// started together do not hit the same upstreams at the same moment
+const Jitter = 0.1
+
+var logger = logging.For("scheduler")
+
+// Task is a function run every Interval until the scheduler stops
+type Task struct {
+	Name     string
+	Interval time.Duration
+	// Immediate runs the task once when the scheduler starts instead of after the first interval
+	Immediate bool
+	Run       func(ctx context.Context) error
+}
+
+// taskStats are the counters of one task
+type taskStats struct {
+	successes   int64
+	failures    int64
+	lastRun     time.Duration
+	lastSuccess time.Time
+}
+
+// Scheduler runs tasks on their intervals
+type Scheduler struct {
+	mu      sync.Mutex
+	tasks   []Task
+	stats   map[string]*taskStats
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+	now     func() time.Time
+}
+
+// New creates a scheduler without tasks
+func New() *Scheduler {
+	return &Scheduler{stats: make(map[string]*taskStats), now: time.Now}
+}
+
+// Add registers a task, run once the scheduler starts. Tasks without a positive interval are
+// ignored; a nil scheduler ignores every task.
+func (s *Scheduler) Add(task Task) {
+	if s == nil || task.Interval <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks = append(s.tasks, task)
+	s.stats[task.Name] = &taskStats{}
+}
+
+// Start runs every registered task in its own goroutine until ctx is done or Stop is called
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, s.cancel = context.WithCancel(ctx)
+	for _, task := range s.tasks {
+		s.running.Add(1)
+		go func() {
+			defer s.running.Done()
+			s.loop(ctx, task)
+		}()
+	}
+}
+
+// Stop cancels the tasks and waits for those running to return, or for ctx to be done. Stopping
+// a nil scheduler does nothing.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.mu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// loop runs task on its jittered interval until ctx is done
+func (s *Scheduler) loop(ctx context.Context, task Task) {
+	if task.Immediate {
+		s.run(ctx, task)
+	}
+
+	timer := time.NewTimer(jittered(task.Interval))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			s.run(ctx, task)
+			timer.Reset(jittered(task.Interval))
+		}
+	}
+}
+
+// run runs task once, recording its outcome
+func (s *Scheduler) run(ctx context.Context, task Task) {
+	start := s.now()
+	err := task.Run(ctx)
+	elapsed := s.now().Sub(start)
+	logger.Debugf("Task %s took %v, err=%v", task.Name, elapsed, err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats[task.Name]
+	stats.lastRun = elapsed
+	if err != nil {
+		stats.failures++
+		if ctx.Err() == nil {
+			logging.Warnf("Task %s failed: %v", task.Name, err)
+		}
+		return
+	}
+	stats.successes++
+	stats.lastSuccess = start
+}
+
+// jittered returns interval shortened or lengthened by up to Jitter of it
+func jittered(interval time.Duration) time.Duration {
+	spread := int64(float64(interval) * Jitter)
+	if spread <= 0 {
+		return interval
+	}
+	return interval + time.Duration(rand.Int64N(2*spread+1)-spread)
+}
+
+// WriteMetrics writes the run counts, last run duration, and last success time of every task in
+// the Prometheus text exposition format. A nil scheduler writes nothing.
+func (s *Scheduler) WriteMetrics(w io.Writer) error {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	names := make([]string, 0, len(s.stats))
+	for name := range s.stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	snapshot := make([]taskStats, len(names))
+	for i, name := range names {
+		snapshot[i] = *s.stats[name]
+	}
+	s.mu.Unlock()
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	var runs, duration, success strings.Builder
+	runs.WriteString("# HELP myip_scheduler_runs_total Runs of each background task by result.\n")
+	runs.WriteString("# TYPE myip_scheduler_runs_total counter\n")
+	duration.WriteString("# HELP myip_scheduler_last_run_duration_seconds Duration of the latest run of each background task.\n")
+	duration.WriteString("# TYPE myip_scheduler_last_run_duration_seconds gauge\n")
+	success.WriteString("# HELP myip_scheduler_last_success_timestamp_seconds Unix time of the latest successful run of each background task, 0 before one.\n")
+	success.WriteString("# TYPE myip_scheduler_last_success_timestamp_seconds gauge\n")
+	for i, name := range names {
+		fmt.Fprintf(&runs, "myip_scheduler_runs_total{task=%q,result=\"success\"} %d\n", name, snapshot[i].successes)
+		fmt.Fprintf(&runs, "myip_scheduler_runs_total{task=%q,result=\"failure\"} %d\n", name, snapshot[i].failures)
+		fmt.Fprintf(&duration, "myip_scheduler_last_run_duration_seconds{task=%q} %g\n", name, snapshot[i].lastRun.Seconds())
+		var ts int64
+		if !snapshot[i].lastSuccess.IsZero() {
+			ts = snapshot[i].lastSuccess.Unix()
+		}
+		fmt.Fprintf(&success, "myip_scheduler_last_success_timestamp_seconds{task=%q} %d\n", name, ts)
+	}
+	_, err := io.WriteString(w, runs.String()+duration.String()+success.String())
+	return err
+}
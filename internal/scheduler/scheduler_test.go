@@ -0,0 +1,127 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	s := New()
+	var runs, immediate atomic.Int32
+	ran := make(chan struct{}, 10)
+	s.Add(Task{Name: "periodic", Interval: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+		if runs.Add(1) == 1 {
+			return errors.New("upstream failed")
+		}
+		ran <- struct{}{}
+		return nil
+	}})
+	s.Add(Task{Name: "immediate", Interval: time.Hour, Immediate: true, Run: func(ctx context.Context) error {
+		immediate.Add(1)
+		return nil
+	}})
+	s.Add(Task{Name: "disabled", Run: func(ctx context.Context) error {
+		t.Error("Expected a task without an interval not to run")
+		return nil
+	}})
+	s.Start(context.Background())
+
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the periodic task to run again after failing")
+	}
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if immediate.Load() != 1 {
+		t.Errorf("Expected the immediate task to run once at start, got %d runs", immediate.Load())
+	}
+
+	var out strings.Builder
+	if err := s.WriteMetrics(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`myip_scheduler_runs_total{task="periodic",result="failure"} 1`,
+		`myip_scheduler_runs_total{task="immediate",result="success"} 1`,
+		`myip_scheduler_last_run_duration_seconds{task="periodic"}`,
+		"# TYPE myip_scheduler_last_success_timestamp_seconds gauge",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected metrics to contain %s, got:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "disabled") {
+		t.Errorf("Expected no metrics for the disabled task, got:\n%s", out.String())
+	}
+}
+
+func TestStopWaitsForRunningTasks(t *testing.T) {
+	s := New()
+	started := make(chan struct{})
+	var finished atomic.Bool
+	s.Add(Task{Name: "slow", Interval: time.Hour, Immediate: true, Run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		finished.Store(true)
+		return ctx.Err()
+	}})
+	s.Start(context.Background())
+	<-started
+
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if !finished.Load() {
+		t.Error("Expected Stop to wait for the running task")
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	s := New()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	s.Add(Task{Name: "stuck", Interval: time.Hour, Immediate: true, Run: func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}})
+	s.Start(context.Background())
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Stop to give up at the deadline, got %v", err)
+	}
+}
+
+func TestJittered(t *testing.T) {
+	for range 100 {
+		if d := jittered(time.Minute); d < 54*time.Second || d > 66*time.Second {
+			t.Fatalf("Expected a jittered minute within 10%%, got %v", d)
+		}
+	}
+	if d := jittered(time.Nanosecond); d != time.Nanosecond {
+		t.Errorf("Expected intervals too short to jitter unchanged, got %v", d)
+	}
+}
+
+func TestNilScheduler(t *testing.T) {
+	var s *Scheduler
+	s.Add(Task{Name: "ignored", Interval: time.Second})
+	if err := s.Stop(context.Background()); err != nil {
+		t.Errorf("Expected stopping a nil scheduler to succeed, got %v", err)
+	}
+	var out strings.Builder
+	if err := s.WriteMetrics(&out); err != nil || out.Len() != 0 {
+		t.Errorf("Expected no metrics, got %q, %v", out.String(), err)
+	}
+}
@@ -0,0 +1,306 @@
+// Package selftest implements "myip selftest": checking a myip deployment's address detection
+// against an external IP echo service such as ipify, along with its response formats and the
+// accessibility of its endpoints. The results are machine-readable for deployment pipelines.
+package selftest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"myip/internal/models"
+)
+
+// Default reference services and timeout
+const (
+	DefaultIPv4Reference = "https://api.ipify.org"
+	DefaultIPv6Reference = "https://api64.ipify.org"
+	DefaultTimeout       = 15 * time.Second
+	userAgent            = "myip-selftest"
+)
+
+// Check outcomes
+const (
+	StatusPass = "pass"
+	StatusFail = "fail"
+	StatusSkip = "skip"
+)
+
+// Options configures a self-test run
+type Options struct {
+	// URL is the base URL of the myip deployment to test
+	URL string
+
+	// IPv4Reference and IPv6Reference are plain-text IP echo services reporting the address the
+	// deployment is expected to detect; DefaultIPv4Reference and DefaultIPv6Reference when empty.
+	// IPv6Reference may answer with an IPv4 address when the host has no IPv6 connectivity.
+	IPv4Reference string
+	IPv6Reference string
+
+	// Client sends the requests; a client with DefaultTimeout when nil
+	Client *http.Client
+}
+
+// Check is the outcome of a single check
+type Check struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// Report is the result of a self-test run
+type Report struct {
+	URL    string  `json:"url"`
+	Passed bool    `json:"passed"`
+	Checks []Check `json:"checks"`
+}
+
+// Failed returns the number of failed checks
+func (r *Report) Failed() int {
+	failed := 0
+	for _, check := range r.Checks {
+		if check.Status == StatusFail {
+			failed++
+		}
+	}
+	return failed
+}
+
+// WriteText writes the report as one line per check followed by a summary line
+func (r *Report) WriteText(w io.Writer) error {
+	for _, check := range r.Checks {
+		line := fmt.Sprintf("%-4s  %-18s  %s", strings.ToUpper(check.Status), check.Name, check.Message)
+		if check.Expected != "" || check.Actual != "" {
+			line += fmt.Sprintf(" (expected %q, got %q)", check.Expected, check.Actual)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	summary := "PASSED"
+	if !r.Passed {
+		summary = "FAILED"
+	}
+	_, err := fmt.Fprintf(w, "%s: %d checks, %d failed against %s\n", summary, len(r.Checks), r.Failed(), r.URL)
+	return err
+}
+
+// runner holds the state of a run
+type runner struct {
+	ctx    context.Context
+	opts   Options
+	report *Report
+}
+
+// Run performs every check against the deployment and reports the results. It fails only checks,
+// never the run: an unreachable reference service or deployment is reported as a failed check.
+func Run(ctx context.Context, opts Options) *Report {
+	opts.URL = strings.TrimSuffix(opts.URL, "/")
+	if opts.IPv4Reference == "" {
+		opts.IPv4Reference = DefaultIPv4Reference
+	}
+	if opts.IPv6Reference == "" {
+		opts.IPv6Reference = DefaultIPv6Reference
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	r := &runner{ctx: ctx, opts: opts, report: &Report{URL: opts.URL, Checks: []Check{}}}
+	ipv4, ipv6 := r.references()
+	r.checkIPv4(ipv4)
+	r.checkIPv6(ipv6)
+	r.checkJSON(ipv4, ipv6)
+	r.checkFormats(ipv4)
+	r.checkEndpoints()
+
+	r.report.Passed = r.report.Failed() == 0
+	return r.report
+}
+
+// add records a check
+func (r *runner) add(check Check) {
+	r.report.Checks = append(r.report.Checks, check)
+}
+
+// get requests path from the deployment, or url itself when it is absolute, returning the
+// response with its body read and trimmed
+func (r *runner) get(url string) (*http.Response, string, error) {
+	if strings.HasPrefix(url, "/") {
+		url = r.opts.URL + url
+	}
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := r.opts.Client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, "", err
+	}
+	return resp, strings.TrimSpace(string(body)), nil
+}
+
+// references asks the reference services for the host's public addresses, returning "" for an
+// address family the host does not have
+func (r *runner) references() (ipv4, ipv6 string) {
+	if _, body, err := r.get(r.opts.IPv4Reference); err != nil {
+		r.add(Check{Name: "ipv4-reference", Status: StatusFail, Message: "Reference service unreachable: " + err.Error()})
+	} else if addr, err := netip.ParseAddr(body); err != nil || !addr.Unmap().Is4() {
+		r.add(Check{Name: "ipv4-reference", Status: StatusFail, Message: "Reference service did not return an IPv4 address", Actual: body})
+	} else {
+		ipv4 = addr.Unmap().String()
+	}
+
+	// Without IPv6 connectivity the dual-stack reference answers over IPv4
+	if _, body, err := r.get(r.opts.IPv6Reference); err == nil {
+		if addr, err := netip.ParseAddr(body); err == nil && addr.Is6() && !addr.Is4In6() {
+			ipv6 = body
+		}
+	}
+	return ipv4, ipv6
+}
+
+// checkIPv4 compares the address served by / with the IPv4 reference
+func (r *runner) checkIPv4(expected string) {
+	if expected == "" {
+		r.add(Check{Name: "ipv4-detection", Status: StatusSkip, Message: "No IPv4 reference address"})
+		return
+	}
+	_, actual, err := r.get("/")
+	switch {
+	case err != nil:
+		r.add(Check{Name: "ipv4-detection", Status: StatusFail, Message: "Request failed: " + err.Error()})
+	case actual != expected:
+		r.add(Check{Name: "ipv4-detection", Status: StatusFail, Message: "Detected IPv4 address differs from the reference", Expected: expected, Actual: actual})
+	default:
+		r.add(Check{Name: "ipv4-detection", Status: StatusPass, Message: "Detected IPv4 address matches the reference", Actual: actual})
+	}
+}
+
+// checkIPv6 compares the address served by /ipv6 with the IPv6 reference, or expects 404 when
+// the host has no IPv6 address
+func (r *runner) checkIPv6(expected string) {
+	resp, actual, err := r.get("/ipv6")
+	switch {
+	case err != nil:
+		r.add(Check{Name: "ipv6-detection", Status: StatusFail, Message: "Request failed: " + err.Error()})
+	case expected == "" && resp.StatusCode == http.StatusNotFound:
+		r.add(Check{Name: "ipv6-detection", Status: StatusPass, Message: "No IPv6 connectivity, and /ipv6 answers 404"})
+	case expected == "":
+		r.add(Check{Name: "ipv6-detection", Status: StatusFail, Message: fmt.Sprintf("No IPv6 connectivity, but /ipv6 answers %d", resp.StatusCode), Expected: "404", Actual: actual})
+	case actual != expected:
+		r.add(Check{Name: "ipv6-detection", Status: StatusFail, Message: "Detected IPv6 address differs from the reference", Expected: expected, Actual: actual})
+	default:
+		r.add(Check{Name: "ipv6-detection", Status: StatusPass, Message: "Detected IPv6 address matches the reference", Actual: actual})
+	}
+}
+
+// checkJSON validates /json: its content type, required fields, and detected addresses
+func (r *runner) checkJSON(ipv4, ipv6 string) {
+	const name = "json"
+	resp, body, err := r.get("/json")
+	if err != nil {
+		r.add(Check{Name: name, Status: StatusFail, Message: "Request failed: " + err.Error()})
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		r.add(Check{Name: name, Status: StatusFail, Message: fmt.Sprintf("/json answers %d", resp.StatusCode), Expected: "200"})
+		return
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "application/json" {
+		r.add(Check{Name: name, Status: StatusFail, Message: "Unexpected content type", Expected: "application/json", Actual: contentType})
+		return
+	}
+
+	var info models.IPInfo
+	if err := json.Unmarshal([]byte(body), &info); err != nil {
+		r.add(Check{Name: name, Status: StatusFail, Message: "Invalid JSON: " + err.Error()})
+		return
+	}
+	switch {
+	case info.DetectedVia == "" || info.Timestamp == "":
+		r.add(Check{Name: name, Status: StatusFail, Message: "detected_via or timestamp is missing"})
+	case ipv4 != "" && info.IPv4Address != ipv4:
+		r.add(Check{Name: name, Status: StatusFail, Message: "ipv4_address differs from the reference", Expected: ipv4, Actual: info.IPv4Address})
+	case ipv6 != "" && info.IPv6Address != ipv6:
+		r.add(Check{Name: name, Status: StatusFail, Message: "ipv6_address differs from the reference", Expected: ipv6, Actual: info.IPv6Address})
+	default:
+		r.add(Check{Name: name, Status: StatusPass, Message: "Valid response, detected via " + info.DetectedVia})
+	}
+}
+
+// checkFormats validates the plain-text, JSON, and JSONP forms of /
+func (r *runner) checkFormats(ipv4 string) {
+	formats := []struct {
+		name, query, contentType string
+		valid                    func(body string) bool
+	}{
+		{"format-text", "", "text/plain", func(body string) bool { return isAddress(body) }},
+		{"format-json", "?format=json", "application/json", func(body string) bool {
+			var response struct{ IP string }
+			return json.Unmarshal([]byte(body), &response) == nil && isAddress(response.IP)
+		}},
+		{"format-jsonp", "?format=jsonp&callback=selftest", "application/javascript", func(body string) bool {
+			inner, ok := strings.CutPrefix(body, "selftest(")
+			inner, ok2 := strings.CutSuffix(inner, ");")
+			var response struct{ IP string }
+			return ok && ok2 && json.Unmarshal([]byte(inner), &response) == nil && isAddress(response.IP)
+		}},
+	}
+
+	for _, format := range formats {
+		resp, body, err := r.get("/" + format.query)
+		switch {
+		case err != nil:
+			r.add(Check{Name: format.name, Status: StatusFail, Message: "Request failed: " + err.Error()})
+		case ipv4 == "" && resp.StatusCode == http.StatusNotFound:
+			r.add(Check{Name: format.name, Status: StatusSkip, Message: "No IPv4 address to format"})
+		case resp.StatusCode != http.StatusOK:
+			r.add(Check{Name: format.name, Status: StatusFail, Message: fmt.Sprintf("/%s answers %d", format.query, resp.StatusCode), Expected: "200"})
+		case !strings.HasPrefix(resp.Header.Get("Content-Type"), format.contentType):
+			r.add(Check{Name: format.name, Status: StatusFail, Message: "Unexpected content type", Expected: format.contentType, Actual: resp.Header.Get("Content-Type")})
+		case !format.valid(body):
+			r.add(Check{Name: format.name, Status: StatusFail, Message: "Malformed response", Actual: body})
+		default:
+			r.add(Check{Name: format.name, Status: StatusPass, Message: "Valid " + format.contentType + " response"})
+		}
+	}
+}
+
+// checkEndpoints expects 200 from the status and debugging endpoints
+func (r *runner) checkEndpoints() {
+	for _, path := range []string{"/health", "/info", "/headers"} {
+		name := "endpoint" + strings.ReplaceAll(path, "/", "-")
+		resp, _, err := r.get(path)
+		switch {
+		case err != nil:
+			r.add(Check{Name: name, Status: StatusFail, Message: "Request failed: " + err.Error()})
+		case resp.StatusCode != http.StatusOK:
+			r.add(Check{Name: name, Status: StatusFail, Message: fmt.Sprintf("%s answers %d", path, resp.StatusCode), Expected: "200"})
+		default:
+			r.add(Check{Name: name, Status: StatusPass, Message: path + " is accessible"})
+		}
+	}
+}
+
+// isAddress reports whether s is an IP address
+func isAddress(s string) bool {
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
@@ -0,0 +1,191 @@
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// reference serves addr as a plain-text IP echo service
+func reference(t *testing.T, addr string) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, addr)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// deployment serves a minimal myip detecting ipv4 and ipv6, answering 404 for a missing family
+func deployment(t *testing.T, ipv4, ipv6 string) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	plain := func(addr string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if addr == "" {
+				http.NotFound(w, r)
+				return
+			}
+			switch r.URL.Query().Get("format") {
+			case "json":
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"ip":%q}`, addr)
+			case "jsonp":
+				w.Header().Set("Content-Type", "application/javascript")
+				fmt.Fprintf(w, `%s({"ip":%q});`, r.URL.Query().Get("callback"), addr)
+			default:
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				fmt.Fprintln(w, addr)
+			}
+		}
+	}
+	mux.HandleFunc("/{$}", plain(ipv4))
+	mux.HandleFunc("/ipv6", plain(ipv6))
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"client_ip":%q,"ipv4_address":%q,"ipv6_address":%q,"detected_via":"X-Forwarded-For","timestamp":"2024-01-01T00:00:00Z"}`, ipv4, ipv4, ipv6)
+	})
+	for _, path := range []string{"/health", "/info", "/headers"} {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {})
+	}
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// statuses maps each check of the report to its status
+func statuses(report *Report) map[string]string {
+	result := make(map[string]string, len(report.Checks))
+	for _, check := range report.Checks {
+		result[check.Name] = check.Status
+	}
+	return result
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		ipv4, ipv6   string // detected by the deployment
+		refV4, refV6 string // reported by the reference services
+		wantPassed   bool
+		wantStatuses map[string]string
+	}{
+		{
+			name: "dual stack", ipv4: "203.0.113.7", ipv6: "2001:db8::7",
+			refV4: "203.0.113.7", refV6: "2001:db8::7",
+			wantPassed: true,
+			wantStatuses: map[string]string{
+				"ipv4-detection": StatusPass, "ipv6-detection": StatusPass, "json": StatusPass,
+				"format-text": StatusPass, "format-json": StatusPass, "format-jsonp": StatusPass,
+				"endpoint-health": StatusPass, "endpoint-info": StatusPass, "endpoint-headers": StatusPass,
+			},
+		},
+		{
+			name: "no IPv6 connectivity", ipv4: "203.0.113.7",
+			refV4: "203.0.113.7", refV6: "203.0.113.7",
+			wantPassed:   true,
+			wantStatuses: map[string]string{"ipv4-detection": StatusPass, "ipv6-detection": StatusPass, "json": StatusPass},
+		},
+		{
+			name: "wrong IPv4 detected", ipv4: "10.0.0.1",
+			refV4: "203.0.113.7", refV6: "203.0.113.7",
+			wantStatuses: map[string]string{"ipv4-detection": StatusFail, "json": StatusFail, "format-text": StatusPass},
+		},
+		{
+			name: "IPv6 detected without connectivity", ipv4: "203.0.113.7", ipv6: "2001:db8::7",
+			refV4: "203.0.113.7", refV6: "203.0.113.7",
+			wantStatuses: map[string]string{"ipv4-detection": StatusPass, "ipv6-detection": StatusFail},
+		},
+		{
+			name: "IPv6-only host", ipv6: "2001:db8::7",
+			refV4: "2001:db8::7", refV6: "2001:db8::7",
+			wantStatuses: map[string]string{
+				"ipv4-reference": StatusFail, "ipv4-detection": StatusSkip, "ipv6-detection": StatusPass,
+				"format-text": StatusSkip,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Run(context.Background(), Options{
+				URL:           deployment(t, tt.ipv4, tt.ipv6) + "/",
+				IPv4Reference: reference(t, tt.refV4),
+				IPv6Reference: reference(t, tt.refV6),
+			})
+
+			if report.Passed != tt.wantPassed {
+				t.Errorf("Passed = %v, want %v: %+v", report.Passed, tt.wantPassed, report.Checks)
+			}
+			got := statuses(report)
+			for name, want := range tt.wantStatuses {
+				if got[name] != want {
+					t.Errorf("check %s = %q, want %q", name, got[name], want)
+				}
+			}
+			if strings.HasSuffix(report.URL, "/") {
+				t.Errorf("URL = %q, want the trailing slash trimmed", report.URL)
+			}
+		})
+	}
+}
+
+func TestRunUnreachableDeployment(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	report := Run(context.Background(), Options{
+		URL:           url,
+		IPv4Reference: reference(t, "203.0.113.7"),
+		IPv6Reference: reference(t, "203.0.113.7"),
+	})
+	if report.Passed {
+		t.Fatal("Passed = true against an unreachable deployment")
+	}
+	if failed := report.Failed(); failed != len(report.Checks) {
+		t.Errorf("Failed() = %d, want every one of %d checks", failed, len(report.Checks))
+	}
+}
+
+func TestReportOutput(t *testing.T) {
+	report := &Report{
+		URL: "https://ip.example.com",
+		Checks: []Check{
+			{Name: "ipv4-detection", Status: StatusFail, Message: "Detected IPv4 address differs from the reference", Expected: "203.0.113.7", Actual: "10.0.0.1"},
+			{Name: "endpoint-health", Status: StatusPass, Message: "/health is accessible"},
+		},
+	}
+
+	var text bytes.Buffer
+	if err := report.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`FAIL  ipv4-detection      Detected IPv4 address differs from the reference (expected "203.0.113.7", got "10.0.0.1")`,
+		"PASS  endpoint-health     /health is accessible",
+		"FAILED: 2 checks, 1 failed against https://ip.example.com",
+	} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("text output missing %q:\n%s", want, text.String())
+		}
+	}
+
+	encoded, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["passed"] != false || len(decoded["checks"].([]any)) != 2 {
+		t.Errorf("unexpected JSON report: %s", encoded)
+	}
+}
@@ -0,0 +1,286 @@
+// Package simulate implements "myip simulate": replaying requests as common reverse proxies and
+// CDNs would forward them against a running instance, reporting the client IP and detection
+// method the instance settled on for each. It shows whether TRUSTED_PROXIES and the header
+// configuration trust the intended proxies before they carry production traffic.
+package simulate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"myip/internal/models"
+)
+
+// Defaults of a simulation
+const (
+	// DefaultClientIP is the client address the simulated proxies forward, from TEST-NET-3
+	DefaultClientIP = "203.0.113.10"
+	DefaultTimeout  = 10 * time.Second
+
+	// spoofedIP is the address a client supplies itself in the spoofed profile, from TEST-NET-2
+	spoofedIP = "198.51.100.66"
+	userAgent = "myip-simulate"
+)
+
+// Outcomes of a profile
+const (
+	// StatusTrusted means the instance detected the simulated client
+	StatusTrusted = "trusted"
+	// StatusIgnored means the instance ignored the headers and used the connection's address,
+	// as it does when the simulating host is not in TRUSTED_PROXIES
+	StatusIgnored = "ignored"
+	// StatusMismatch means the instance detected an address other than the simulated client
+	StatusMismatch = "mismatch"
+	// StatusError means the request failed
+	StatusError = "error"
+)
+
+// profile is a set of headers a proxy sends, with {client} standing for the client address
+type profile struct {
+	name, description string
+	headers           [][2]string
+}
+
+// profiles are the built-in header sets, in report order
+var profiles = []profile{
+	{"cloudflare", "Cloudflare", [][2]string{
+		{"CF-Connecting-IP", "{client}"},
+		{"X-Forwarded-For", "{client}"},
+		{"X-Forwarded-Proto", "https"},
+		{"CF-Ray", "8a1b2c3d4e5f6a7b-AMS"},
+		{"CF-IPCountry", "US"},
+		{"CF-Visitor", `{"scheme":"https"}`},
+	}},
+	{"aws-alb", "AWS Application Load Balancer", [][2]string{
+		{"X-Forwarded-For", "{client}"},
+		{"X-Forwarded-Proto", "https"},
+		{"X-Forwarded-Port", "443"},
+		{"X-Amzn-Trace-Id", "Root=1-67891233-abcdef012345678912345678"},
+	}},
+	{"nginx", "nginx with proxy_set_header X-Real-IP and X-Forwarded-For", [][2]string{
+		{"X-Real-IP", "{client}"},
+		{"X-Forwarded-For", "{client}"},
+		{"X-Forwarded-Proto", "https"},
+	}},
+	{"envoy", "Envoy as the edge proxy", [][2]string{
+		{"X-Forwarded-For", "{client}"},
+		{"X-Envoy-External-Address", "{client}"},
+		{"X-Forwarded-Proto", "https"},
+		{"X-Request-Id", "6f1c4f4e-7b0e-4a8b-9d3e-2c1f0a9b8c7d"},
+	}},
+	{"spoofed", "A proxy appending to an X-Forwarded-For the client supplied itself", [][2]string{
+		{"X-Forwarded-For", spoofedIP + ", {client}"},
+	}},
+}
+
+// Profiles returns the names of the built-in profiles
+func Profiles() []string {
+	names := make([]string, len(profiles))
+	for i, p := range profiles {
+		names[i] = p.name
+	}
+	return names
+}
+
+// Options configures a simulation
+type Options struct {
+	// URL is the base URL of the running instance
+	URL string
+
+	// ClientIP is the client address the simulated proxies forward; DefaultClientIP when empty
+	ClientIP string
+
+	// Profiles names the built-in profiles to replay; all of them when empty
+	Profiles []string
+
+	// Headers, when not empty, are replayed as an extra "custom" profile. Values may contain
+	// {client}, which is replaced by the client address.
+	Headers http.Header
+
+	// Client sends the requests; a client with DefaultTimeout when nil
+	Client *http.Client
+}
+
+// Result is the outcome of replaying one profile
+type Result struct {
+	Profile     string            `json:"profile"`
+	Description string            `json:"description"`
+	Headers     map[string]string `json:"headers"`
+	Status      string            `json:"status"`
+	ClientIP    string            `json:"client_ip,omitempty"`
+	DetectedVia string            `json:"detected_via,omitempty"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// Report is the result of a simulation
+type Report struct {
+	URL      string   `json:"url"`
+	ClientIP string   `json:"simulated_client_ip"`
+	Results  []Result `json:"results"`
+}
+
+// Errors returns the number of profiles whose request failed
+func (r *Report) Errors() int {
+	errors := 0
+	for _, result := range r.Results {
+		if result.Status == StatusError {
+			errors++
+		}
+	}
+	return errors
+}
+
+// Run replays the selected profiles against the instance's /json endpoint. It returns an error
+// only for invalid options; failed requests are reported as results with StatusError.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	if opts.ClientIP == "" {
+		opts.ClientIP = DefaultClientIP
+	}
+	if _, err := netip.ParseAddr(opts.ClientIP); err != nil {
+		return nil, fmt.Errorf("invalid client IP %q", opts.ClientIP)
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	selected, err := selectProfiles(opts.Profiles)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Headers) > 0 {
+		custom := profile{name: "custom", description: "Headers given on the command line"}
+		for _, name := range sortedKeys(opts.Headers) {
+			for _, value := range opts.Headers[name] {
+				custom.headers = append(custom.headers, [2]string{name, value})
+			}
+		}
+		selected = append(selected, custom)
+	}
+
+	report := &Report{URL: strings.TrimSuffix(opts.URL, "/"), ClientIP: opts.ClientIP, Results: []Result{}}
+	for _, p := range selected {
+		report.Results = append(report.Results, replay(ctx, opts.Client, report.URL, opts.ClientIP, p))
+	}
+	return report, nil
+}
+
+// selectProfiles returns the named profiles, or all of them when names is empty
+func selectProfiles(names []string) ([]profile, error) {
+	if len(names) == 0 {
+		return slices.Clone(profiles), nil
+	}
+	selected := make([]profile, 0, len(names))
+	for _, name := range names {
+		i := slices.IndexFunc(profiles, func(p profile) bool { return p.name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown profile %q, expected one of %s", name, strings.Join(Profiles(), ", "))
+		}
+		selected = append(selected, profiles[i])
+	}
+	return selected, nil
+}
+
+// replay sends one profile's headers to the instance and classifies what it detected
+func replay(ctx context.Context, client *http.Client, base, clientIP string, p profile) Result {
+	result := Result{Profile: p.name, Description: p.description, Headers: make(map[string]string, len(p.headers))}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/json", nil)
+	if err != nil {
+		result.Status, result.Error = StatusError, err.Error()
+		return result
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for _, header := range p.headers {
+		value := strings.ReplaceAll(header[1], "{client}", clientIP)
+		req.Header.Add(header[0], value)
+		result.Headers[header[0]] = value
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Status, result.Error = StatusError, err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		result.Status, result.Error = StatusError, fmt.Sprintf("/json answered %d", resp.StatusCode)
+		return result
+	}
+
+	var info models.IPInfo
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&info); err != nil {
+		result.Status, result.Error = StatusError, "invalid /json response: "+err.Error()
+		return result
+	}
+	result.ClientIP, result.DetectedVia = info.ClientIP, info.DetectedVia
+
+	switch {
+	case info.ClientIP == clientIP:
+		result.Status = StatusTrusted
+	case info.DetectedVia == "RemoteAddr":
+		result.Status = StatusIgnored
+	default:
+		result.Status = StatusMismatch
+	}
+	return result
+}
+
+// WriteText writes the report as a table of profiles followed by hints for ignored headers and
+// mismatched addresses
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROFILE\tSTATUS\tDETECTED\tVIA")
+	var ignored, mismatched []string
+	var connecting string
+	for _, result := range r.Results {
+		detected, via := result.ClientIP, result.DetectedVia
+		if result.Status == StatusError {
+			detected, via = "-", result.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Profile, result.Status, detected, via)
+
+		switch result.Status {
+		case StatusIgnored:
+			ignored = append(ignored, result.Profile)
+			connecting = result.ClientIP
+		case StatusMismatch:
+			mismatched = append(mismatched, result.Profile)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(ignored) > 0 {
+		if _, err := fmt.Fprintf(w, "\nHeaders were ignored for %s: the instance sees this host as %s; add it to TRUSTED_PROXIES to trust its headers.\n",
+			strings.Join(ignored, ", "), connecting); err != nil {
+			return err
+		}
+	}
+	if len(mismatched) > 0 {
+		if _, err := fmt.Fprintf(w, "\nAn address other than the simulated client %s was detected for %s; check HEADER_PRIORITY and XFF_STRATEGY.\n",
+			r.ClientIP, strings.Join(mismatched, ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the header names in order, for a stable custom profile
+func sortedKeys(header http.Header) []string {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
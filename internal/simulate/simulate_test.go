@@ -0,0 +1,163 @@
+package simulate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akhfa/myip/ipdetect"
+
+	"myip/internal/models"
+)
+
+// instance serves /json from a detector configured with opts, standing in for a running myip
+func instance(t *testing.T, opts ipdetect.Options) string {
+	t.Helper()
+	detector := ipdetect.New(opts)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/json" {
+			http.NotFound(w, r)
+			return
+		}
+		clientIP, via := detector.ClientIP(r)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.IPInfo{ClientIP: clientIP, DetectedVia: via})
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// statuses maps each profile of the report to its status
+func statuses(report *Report) map[string]string {
+	result := make(map[string]string, len(report.Results))
+	for _, r := range report.Results {
+		result[r.Profile] = r.Status
+	}
+	return result
+}
+
+func TestRun(t *testing.T) {
+	loopback, _ := ipdetect.ParseCIDRs([]string{"127.0.0.0/8", "::1/128"})
+	elsewhere, _ := ipdetect.ParseCIDRs([]string{"10.0.0.0/8"})
+
+	tests := []struct {
+		name string
+		opts ipdetect.Options
+		want map[string]string
+	}{
+		{
+			name: "trusted leftmost",
+			opts: ipdetect.Options{TrustedProxies: loopback},
+			want: map[string]string{
+				"cloudflare": StatusTrusted, "aws-alb": StatusTrusted, "nginx": StatusTrusted,
+				"envoy": StatusTrusted, "spoofed": StatusMismatch,
+			},
+		},
+		{
+			name: "trusted rightmost",
+			opts: ipdetect.Options{TrustedProxies: loopback, Strategy: ipdetect.Rightmost},
+			want: map[string]string{"aws-alb": StatusTrusted, "spoofed": StatusTrusted},
+		},
+		{
+			name: "simulating host not trusted",
+			opts: ipdetect.Options{TrustedProxies: elsewhere},
+			want: map[string]string{
+				"cloudflare": StatusIgnored, "aws-alb": StatusIgnored, "nginx": StatusIgnored,
+				"envoy": StatusIgnored, "spoofed": StatusIgnored,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Run(context.Background(), Options{URL: instance(t, tt.opts) + "/"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Results) != len(profiles) {
+				t.Fatalf("got %d results, want one per profile", len(report.Results))
+			}
+			got := statuses(report)
+			for profile, want := range tt.want {
+				if got[profile] != want {
+					t.Errorf("profile %s = %q, want %q", profile, got[profile], want)
+				}
+			}
+		})
+	}
+}
+
+func TestRunOptions(t *testing.T) {
+	url := instance(t, ipdetect.Options{HeaderPriority: []string{"X-Edge-Client"}})
+
+	report, err := Run(context.Background(), Options{
+		URL:      url,
+		ClientIP: "2001:db8::10",
+		Profiles: []string{"nginx"},
+		Headers:  http.Header{"X-Edge-Client": {"{client}"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := statuses(report)
+	if len(got) != 2 || got["nginx"] != StatusIgnored || got["custom"] != StatusTrusted {
+		t.Errorf("statuses = %v, want nginx ignored and custom trusted", got)
+	}
+	if custom := report.Results[1]; custom.Headers["X-Edge-Client"] != "2001:db8::10" || custom.DetectedVia != "X-Edge-Client" {
+		t.Errorf("custom result = %+v", custom)
+	}
+
+	if _, err := Run(context.Background(), Options{URL: url, Profiles: []string{"haproxy"}}); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+	if _, err := Run(context.Background(), Options{URL: url, ClientIP: "not-an-ip"}); err == nil {
+		t.Error("expected an error for an invalid client IP")
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	report, err := Run(context.Background(), Options{URL: server.URL, Profiles: []string{"nginx"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Errors() != 1 || !strings.Contains(report.Results[0].Error, "404") {
+		t.Errorf("results = %+v, want a 404 error", report.Results)
+	}
+
+	server.Close()
+	report, _ = Run(context.Background(), Options{URL: server.URL})
+	if report.Errors() != len(profiles) {
+		t.Errorf("Errors() = %d against a closed instance, want %d", report.Errors(), len(profiles))
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	report := &Report{
+		ClientIP: DefaultClientIP,
+		Results: []Result{
+			{Profile: "cloudflare", Status: StatusIgnored, ClientIP: "192.0.2.1", DetectedVia: "RemoteAddr"},
+			{Profile: "spoofed", Status: StatusMismatch, ClientIP: spoofedIP, DetectedVia: "X-Forwarded-For"},
+			{Profile: "nginx", Status: StatusError, Error: "connection refused"},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"cloudflare  ignored   192.0.2.1",
+		"nginx       error     -",
+		"the instance sees this host as 192.0.2.1; add it to TRUSTED_PROXIES",
+		"was detected for spoofed",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package slo
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"myip/internal/middleware"
+	"myip/internal/models"
+	"myip/internal/problem"
+)
+
+// latencyBounds are the upper bounds of the latency histogram buckets; the last bucket is unbounded
+var latencyBounds = []time.Duration{
+	1 * time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// latencyObjective is the fraction of requests expected to complete within the latency target (p99)
+const latencyObjective = 0.99
+
+// windows are the rolling windows reported by /slo
+var windows = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"5m", 5 * time.Minute},
+	{"1h", time.Hour},
+}
+
+// bucket aggregates the requests observed during one second
+type bucket struct {
+	second    int64
+	requests  int64
+	errors    int64
+	slow      int64
+	histogram [14]int64 // len(latencyBounds) + 1
+}
+
+// Tracker records request outcomes in one-second buckets covering the longest window
+type Tracker struct {
+	mu                 sync.Mutex
+	buckets            []bucket
+	availabilityTarget float64
+	latencyTarget      time.Duration
+	now                func() time.Time
+
+	// Totals since the tracker was created, beyond the longest window
+	requests int64
+	errors   int64
+}
+
+// NewTracker creates a tracker for the given availability target (e.g. 0.999) and p99 latency target
+func NewTracker(availabilityTarget float64, latencyTarget time.Duration) *Tracker {
+	longest := windows[len(windows)-1].duration
+	return &Tracker{
+		buckets:            make([]bucket, int(longest/time.Second)),
+		availabilityTarget: availabilityTarget,
+		latencyTarget:      latencyTarget,
+		now:                time.Now,
+	}
+}
+
+// Record adds a single request outcome
+func (t *Tracker) Record(duration time.Duration, success bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	second := t.now().Unix()
+	b := &t.buckets[second%int64(len(t.buckets))]
+	if b.second != second {
+		*b = bucket{second: second}
+	}
+
+	t.requests++
+	b.requests++
+	if !success {
+		t.errors++
+		b.errors++
+	}
+	if duration > t.latencyTarget {
+		b.slow++
+	}
+	b.histogram[histogramIndex(duration)]++
+}
+
+// Totals returns the requests recorded since the tracker was created and how many failed
+func (t *Tracker) Totals() models.RequestTotals {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return models.RequestTotals{Total: t.requests, Errors: t.errors}
+}
+
+// histogramIndex returns the histogram bucket for a latency
+func histogramIndex(duration time.Duration) int {
+	for i, bound := range latencyBounds {
+		if duration <= bound {
+			return i
+		}
+	}
+	return len(latencyBounds)
+}
+
+// Report computes the indicators for every rolling window
+func (t *Tracker) Report() *models.SLOReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	report := &models.SLOReport{
+		AvailabilityTarget: t.availabilityTarget,
+		LatencyTargetMs:    durationMs(t.latencyTarget),
+		Windows:            make([]models.SLOWindow, 0, len(windows)),
+		Timestamp:          now.UTC().Format(time.RFC3339),
+	}
+
+	for _, window := range windows {
+		report.Windows = append(report.Windows, t.window(now, window.name, window.duration))
+	}
+	return report
+}
+
+// window aggregates the buckets that fall inside the window ending at now
+func (t *Tracker) window(now time.Time, name string, duration time.Duration) models.SLOWindow {
+	oldest := now.Add(-duration).Unix()
+
+	var requests, errors, slow int64
+	var histogram [14]int64
+	for i := range t.buckets {
+		b := &t.buckets[i]
+		if b.second <= oldest || b.second > now.Unix() {
+			continue
+		}
+		requests += b.requests
+		errors += b.errors
+		slow += b.slow
+		for j, count := range b.histogram {
+			histogram[j] += count
+		}
+	}
+
+	result := models.SLOWindow{
+		Window:               name,
+		Requests:             requests,
+		Errors:               errors,
+		Availability:         1,
+		ErrorBudgetRemaining: 1,
+		SlowRequests:         slow,
+	}
+	if requests == 0 {
+		return result
+	}
+
+	errorRatio := float64(errors) / float64(requests)
+	result.Availability = 1 - errorRatio
+	if budget := 1 - t.availabilityTarget; budget > 0 {
+		result.ErrorBudgetBurnRate = errorRatio / budget
+		result.ErrorBudgetRemaining = 1 - result.ErrorBudgetBurnRate
+	}
+	result.LatencyBurnRate = (float64(slow) / float64(requests)) / (1 - latencyObjective)
+	result.LatencyP99Ms = p99(histogram, requests)
+
+	return result
+}
+
+// p99 returns the upper bound of the histogram bucket containing the 99th percentile
+func p99(histogram [14]int64, total int64) float64 {
+	rank := int64(float64(total)*latencyObjective + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+	for i, count := range histogram {
+		seen += count
+		if seen >= rank {
+			if i < len(latencyBounds) {
+				return durationMs(latencyBounds[i])
+			}
+			break
+		}
+	}
+	// The 99th percentile falls in the unbounded bucket; report the largest finite bound
+	return durationMs(latencyBounds[len(latencyBounds)-1])
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Middleware records latency and success (any non-5xx response) for each request
+func (t *Tracker) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := middleware.NewStatusRecorder(w)
+
+		next.ServeHTTP(rec, r)
+
+		t.Record(time.Since(start), rec.Status < http.StatusInternalServerError)
+	})
+}
+
+// Handler serves the current SLO report
+func (t *Tracker) Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(t.Report()); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, "Failed to encode SLO report")
+		return
+	}
+}
@@ -0,0 +1,156 @@
+package slo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"myip/internal/models"
+)
+
+func newTestTracker(now *time.Time) *Tracker {
+	tracker := NewTracker(0.99, 100*time.Millisecond)
+	tracker.now = func() time.Time { return *now }
+	return tracker
+}
+
+func TestReportEmpty(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	report := newTestTracker(&now).Report()
+
+	if len(report.Windows) != 2 {
+		t.Fatalf("Expected 2 windows, got %d", len(report.Windows))
+	}
+
+	for _, window := range report.Windows {
+		if window.Requests != 0 || window.Availability != 1 || window.ErrorBudgetBurnRate != 0 {
+			t.Errorf("Expected healthy empty window, got %+v", window)
+		}
+	}
+}
+
+func TestReportBurnRate(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newTestTracker(&now)
+
+	// 98 fast successes, 2 failures: 2% errors against a 1% budget
+	for i := 0; i < 98; i++ {
+		tracker.Record(5*time.Millisecond, true)
+	}
+	tracker.Record(5*time.Millisecond, false)
+	tracker.Record(5*time.Millisecond, false)
+
+	window := tracker.Report().Windows[0]
+
+	if window.Requests != 100 || window.Errors != 2 {
+		t.Errorf("Expected 100 requests and 2 errors, got %d and %d", window.Requests, window.Errors)
+	}
+	if window.Availability != 0.98 {
+		t.Errorf("Expected availability 0.98, got %v", window.Availability)
+	}
+	if diff := window.ErrorBudgetBurnRate - 2; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("Expected burn rate 2, got %v", window.ErrorBudgetBurnRate)
+	}
+	if diff := window.ErrorBudgetRemaining + 1; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("Expected remaining budget -1, got %v", window.ErrorBudgetRemaining)
+	}
+	if window.LatencyP99Ms != 5 {
+		t.Errorf("Expected p99 of 5ms, got %v", window.LatencyP99Ms)
+	}
+}
+
+func TestReportLatency(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newTestTracker(&now)
+
+	for i := 0; i < 90; i++ {
+		tracker.Record(time.Millisecond, true)
+	}
+	for i := 0; i < 10; i++ {
+		tracker.Record(400*time.Millisecond, true)
+	}
+
+	window := tracker.Report().Windows[0]
+
+	if window.SlowRequests != 10 {
+		t.Errorf("Expected 10 slow requests, got %d", window.SlowRequests)
+	}
+	if window.LatencyP99Ms != 500 {
+		t.Errorf("Expected p99 bucket of 500ms, got %v", window.LatencyP99Ms)
+	}
+	if diff := window.LatencyBurnRate - 10; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("Expected latency burn rate 10, got %v", window.LatencyBurnRate)
+	}
+}
+
+func TestReportWindowExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newTestTracker(&now)
+
+	tracker.Record(time.Millisecond, false)
+	now = now.Add(10 * time.Minute)
+	tracker.Record(time.Millisecond, true)
+
+	report := tracker.Report()
+
+	if short := report.Windows[0]; short.Requests != 1 || short.Errors != 0 {
+		t.Errorf("Expected 5m window to only include the recent request, got %+v", short)
+	}
+	if long := report.Windows[1]; long.Requests != 2 || long.Errors != 1 {
+		t.Errorf("Expected 1h window to include both requests, got %+v", long)
+	}
+
+	// After an hour the ring slot is reused
+	now = now.Add(2 * time.Hour)
+	if long := tracker.Report().Windows[1]; long.Requests != 0 {
+		t.Errorf("Expected expired requests to drop out, got %+v", long)
+	}
+}
+
+func TestMiddlewareAndHandler(t *testing.T) {
+	tracker := NewTracker(0.999, time.Second)
+
+	failing := tracker.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	notFound := tracker.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+
+	failing.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	notFound.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	rr := httptest.NewRecorder()
+	tracker.Handler(rr, httptest.NewRequest("GET", "/slo", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var report models.SLOReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+
+	// 4xx responses are client errors and do not count against availability
+	if window := report.Windows[0]; window.Requests != 2 || window.Errors != 1 {
+		t.Errorf("Expected 2 requests with 1 error, got %+v", window)
+	}
+}
+
+func TestTotals(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newTestTracker(&now)
+
+	tracker.Record(time.Millisecond, true)
+	tracker.Record(time.Millisecond, false)
+	// Totals outlive the rolling windows
+	now = now.Add(2 * time.Hour)
+	tracker.Record(time.Millisecond, true)
+
+	if totals := tracker.Totals(); totals != (models.RequestTotals{Total: 3, Errors: 1}) {
+		t.Errorf("Expected 3 requests with 1 error, got %+v", totals)
+	}
+}
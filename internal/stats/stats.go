@@ -0,0 +1,287 @@
+// Package stats counts requests to the IP detection endpoints by client country, ASN, detection
+// method, and response format over a sliding window, for operators of public instances.
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"myip/internal/ip"
+	"myip/internal/models"
+	"myip/internal/privacy"
+	"myip/internal/problem"
+)
+
+// Unknown is reported for requests whose country or ASN could not be determined
+const Unknown = "unknown"
+
+// Other collects the requests of keys beyond maxKeys in a bucket
+const Other = "other"
+
+// maxKeys bounds the distinct keys a bucket keeps per dimension, so a flood of requests from many
+// networks cannot grow memory without limit
+const maxKeys = 1000
+
+// DefaultLimit is the number of entries reported per dimension unless ?limit= asks otherwise
+const DefaultLimit = 20
+
+// Lookup returns the autonomous system and country code of ip; ok is false when unknown
+type Lookup func(ip string) (asn uint32, country string, ok bool)
+
+// Dimensions counted for each request
+const (
+	dimCountry = iota
+	dimASN
+	dimMethod
+	dimFormat
+	dimensions
+)
+
+// bucket holds the counts of one minute
+type bucket struct {
+	minute   int64
+	requests int64
+	counts   [dimensions]map[string]int64
+}
+
+// add counts one request under key in dimension dim
+func (b *bucket) add(dim int, key string) {
+	counts := b.counts[dim]
+	if counts == nil {
+		counts = make(map[string]int64)
+		b.counts[dim] = counts
+	}
+	if _, ok := counts[key]; !ok && len(counts) >= maxKeys {
+		key = Other
+	}
+	counts[key]++
+}
+
+// Scopes of the statistics served by Handler
+const (
+	ScopeNode    = "node"
+	ScopeCluster = "cluster"
+)
+
+// Peers returns the statistics of the other replicas in the cluster
+type Peers func(ctx context.Context) []*models.StatsReport
+
+// Counter records requests in one-minute buckets covering its window
+type Counter struct {
+	mu      sync.Mutex
+	buckets []bucket
+	window  time.Duration
+	lookup  Lookup
+	now     func() time.Time
+
+	// peers is set in cluster mode, enabling ?scope=cluster
+	peers atomic.Pointer[Peers]
+}
+
+// New creates a counter over window, rounded up to whole minutes. lookup may be nil, in which
+// case country and ASN are always Unknown.
+func New(window time.Duration, lookup Lookup) *Counter {
+	minutes := int((window + time.Minute - 1) / time.Minute)
+	if minutes < 1 {
+		minutes = 1
+	}
+	return &Counter{
+		buckets: make([]bucket, minutes),
+		window:  time.Duration(minutes) * time.Minute,
+		lookup:  lookup,
+		now:     time.Now,
+	}
+}
+
+// Record counts a request from clientIP detected via method and answered in format
+func (c *Counter) Record(clientIP, method, format string) {
+	country, asn := Unknown, Unknown
+	if c.lookup != nil {
+		if number, code, ok := c.lookup(clientIP); ok {
+			asn = "AS" + strconv.FormatUint(uint64(number), 10)
+			if code != "" && code != "None" {
+				country = code
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	minute := c.now().Unix() / 60
+	b := &c.buckets[minute%int64(len(c.buckets))]
+	if b.minute != minute {
+		*b = bucket{minute: minute}
+	}
+
+	b.requests++
+	b.add(dimCountry, country)
+	b.add(dimASN, asn)
+	b.add(dimMethod, method)
+	b.add(dimFormat, format)
+}
+
+// Report aggregates the buckets inside the window, keeping the limit largest entries of each
+// dimension
+func (c *Counter) Report(limit int) *models.StatsReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	oldest := now.Unix()/60 - int64(len(c.buckets))
+
+	var requests int64
+	var totals [dimensions]map[string]int64
+	for i := range totals {
+		totals[i] = make(map[string]int64)
+	}
+	for i := range c.buckets {
+		b := &c.buckets[i]
+		if b.minute <= oldest || b.minute > now.Unix()/60 {
+			continue
+		}
+		requests += b.requests
+		for dim, counts := range b.counts {
+			for key, count := range counts {
+				totals[dim][key] += count
+			}
+		}
+	}
+
+	return &models.StatsReport{
+		Window:           c.window.String(),
+		Requests:         requests,
+		Countries:        top(totals[dimCountry], limit),
+		ASNs:             top(totals[dimASN], limit),
+		DetectionMethods: top(totals[dimMethod], limit),
+		Formats:          top(totals[dimFormat], limit),
+		Timestamp:        now.UTC().Format(time.RFC3339),
+	}
+}
+
+// Snapshot returns the statistics with every key kept, for merging with other replicas
+func (c *Counter) Snapshot() *models.StatsReport {
+	return c.Report(maxKeys)
+}
+
+// SetPeers enables ?scope=cluster, merging the statistics of the replicas returned by peers
+func (c *Counter) SetPeers(peers Peers) {
+	c.peers.Store(&peers)
+}
+
+// Merge adds the counts of others to report, keeping the limit largest entries of each dimension
+func Merge(report *models.StatsReport, others []*models.StatsReport, limit int) *models.StatsReport {
+	merged := *report
+	var totals [dimensions]map[string]int64
+	for i := range totals {
+		totals[i] = make(map[string]int64)
+	}
+	for _, other := range others {
+		merged.Requests += other.Requests
+	}
+	for _, r := range append([]*models.StatsReport{report}, others...) {
+		for dim, counts := range [dimensions][]models.StatsCount{r.Countries, r.ASNs, r.DetectionMethods, r.Formats} {
+			for _, count := range counts {
+				totals[dim][count.Name] += count.Requests
+			}
+		}
+	}
+	merged.Countries = top(totals[dimCountry], limit)
+	merged.ASNs = top(totals[dimASN], limit)
+	merged.DetectionMethods = top(totals[dimMethod], limit)
+	merged.Formats = top(totals[dimFormat], limit)
+	return &merged
+}
+
+// top returns the limit largest counts, largest first and ties by name
+func top(counts map[string]int64, limit int) []models.StatsCount {
+	result := make([]models.StatsCount, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, models.StatsCount{Name: key, Requests: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Requests != result[j].Requests {
+			return result[i].Requests > result[j].Requests
+		}
+		return result[i].Name < result[j].Name
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// responseFormat names the format of a response from its Content-Type
+func responseFormat(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return Unknown
+	}
+	switch mediaType {
+	case "text/plain":
+		return "text"
+	case "application/json", "application/problem+json":
+		return "json"
+	case "application/javascript":
+		return "jsonp"
+	case "text/html":
+		return "html"
+	}
+	return mediaType
+}
+
+// Middleware counts each request after it is answered
+func (c *Counter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		clientIP, method := ip.ExtractClientIP(r)
+		c.Record(privacy.IP(clientIP), method, responseFormat(w.Header().Get("Content-Type")))
+	})
+}
+
+// Handler serves the current statistics; ?limit= sets the entries reported per dimension, and
+// ?scope=cluster adds the requests of the other replicas in cluster mode
+func (c *Counter) Handler(w http.ResponseWriter, r *http.Request) {
+	limit := DefaultLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxKeys {
+			http.Error(w, "Invalid limit parameter: expected 1 to "+strconv.Itoa(maxKeys), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	var peers Peers
+	switch scope := r.URL.Query().Get("scope"); scope {
+	case "", ScopeNode:
+	case ScopeCluster:
+		if p := c.peers.Load(); p != nil {
+			peers = *p
+		} else {
+			http.Error(w, "Invalid scope parameter: cluster mode is off", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Invalid scope parameter: expected node or cluster", http.StatusBadRequest)
+		return
+	}
+
+	report := c.Report(limit)
+	if peers != nil {
+		report = Merge(c.Snapshot(), peers(r.Context()), limit)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		problem.Error(w, r, http.StatusInternalServerError, "Failed to encode statistics")
+	}
+}
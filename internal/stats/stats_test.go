@@ -0,0 +1,242 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"myip/internal/models"
+	"myip/internal/privacy"
+)
+
+// fakeLookup maps addresses in 203.0.113.0/24 to AS64500 in NL and 198.51.100.1 to AS64501 in US
+func fakeLookup(ip string) (uint32, string, bool) {
+	switch {
+	case ip == "198.51.100.1":
+		return 64501, "US", true
+	case len(ip) > 11 && ip[:11] == "203.0.113.1":
+		return 64500, "NL", true
+	}
+	return 0, "", false
+}
+
+func newTestCounter(now *time.Time, window time.Duration) *Counter {
+	counter := New(window, fakeLookup)
+	counter.now = func() time.Time { return *now }
+	return counter
+}
+
+func TestReport(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	counter := newTestCounter(&now, time.Hour)
+
+	counter.Record("203.0.113.10", "CF-Connecting-IP", "text")
+	counter.Record("203.0.113.11", "CF-Connecting-IP", "json")
+	counter.Record("198.51.100.1", "RemoteAddr", "text")
+	counter.Record("192.0.2.1", "RemoteAddr", "text")
+
+	report := counter.Report(DefaultLimit)
+	if report.Window != "1h0m0s" || report.Requests != 4 {
+		t.Errorf("Expected 4 requests over 1h, got %d over %s", report.Requests, report.Window)
+	}
+
+	expected := map[string][]models.StatsCount{
+		"countries":         {{Name: "NL", Requests: 2}, {Name: "US", Requests: 1}, {Name: Unknown, Requests: 1}},
+		"asns":              {{Name: "AS64500", Requests: 2}, {Name: "AS64501", Requests: 1}, {Name: Unknown, Requests: 1}},
+		"detection_methods": {{Name: "CF-Connecting-IP", Requests: 2}, {Name: "RemoteAddr", Requests: 2}},
+		"formats":           {{Name: "text", Requests: 3}, {Name: "json", Requests: 1}},
+	}
+	got := map[string][]models.StatsCount{
+		"countries":         report.Countries,
+		"asns":              report.ASNs,
+		"detection_methods": report.DetectionMethods,
+		"formats":           report.Formats,
+	}
+	for name, want := range expected {
+		if !reflect.DeepEqual(got[name], want) {
+			t.Errorf("Expected %s %v, got %v", name, want, got[name])
+		}
+	}
+
+	if report := counter.Report(1); len(report.Countries) != 1 || report.Countries[0].Name != "NL" {
+		t.Errorf("Expected only the top country with limit 1, got %v", report.Countries)
+	}
+}
+
+func TestReportWindow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	counter := newTestCounter(&now, 5*time.Minute)
+
+	counter.Record("203.0.113.10", "RemoteAddr", "text")
+	now = now.Add(3 * time.Minute)
+	counter.Record("203.0.113.10", "RemoteAddr", "text")
+
+	if report := counter.Report(DefaultLimit); report.Requests != 2 {
+		t.Errorf("Expected 2 requests within the window, got %d", report.Requests)
+	}
+
+	now = now.Add(3 * time.Minute)
+	if report := counter.Report(DefaultLimit); report.Requests != 1 {
+		t.Errorf("Expected the first request to leave the window, got %d requests", report.Requests)
+	}
+
+	now = now.Add(time.Hour)
+	if report := counter.Report(DefaultLimit); report.Requests != 0 || len(report.Countries) != 0 {
+		t.Errorf("Expected an empty report, got %+v", report)
+	}
+}
+
+func TestNewRoundsWindow(t *testing.T) {
+	if counter := New(90*time.Second, nil); counter.window != 2*time.Minute {
+		t.Errorf("Expected the window rounded up to 2m, got %s", counter.window)
+	}
+	if counter := New(0, nil); counter.window != time.Minute {
+		t.Errorf("Expected a window of at least 1m, got %s", counter.window)
+	}
+}
+
+func TestMaxKeys(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	counter := newTestCounter(&now, time.Minute)
+
+	for i := 0; i < maxKeys+5; i++ {
+		counter.Record("192.0.2.1", fmt.Sprintf("X-Header-%d", i), "text")
+	}
+
+	methods := counter.Report(maxKeys + 5).DetectionMethods
+	if len(methods) != maxKeys+1 || methods[0].Name != Other || methods[0].Requests != 5 {
+		t.Errorf("Expected %d methods led by %d in %q, got %d led by %+v", maxKeys+1, 5, Other, len(methods), methods[0])
+	}
+}
+
+func TestResponseFormat(t *testing.T) {
+	tests := map[string]string{
+		"text/plain":                "text",
+		"text/plain; charset=utf-8": "text",
+		"application/json":          "json",
+		"application/problem+json":  "json",
+		"application/javascript":    "jsonp",
+		"text/html; charset=utf-8":  "html",
+		"application/octet-stream":  "application/octet-stream",
+		"":                          Unknown,
+	}
+	for contentType, want := range tests {
+		if got := responseFormat(contentType); got != want {
+			t.Errorf("responseFormat(%q) = %q, want %q", contentType, got, want)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	counter := newTestCounter(&now, time.Hour)
+
+	handler := counter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}))
+
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.RemoteAddr = "10.0.0.1:12345"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	report := counter.Report(DefaultLimit)
+	if report.Requests != 1 {
+		t.Fatalf("Expected 1 request, got %d", report.Requests)
+	}
+	if report.Countries[0].Name != "US" || report.DetectionMethods[0].Name != "X-Forwarded-For" || report.Formats[0].Name != "json" {
+		t.Errorf("Unexpected report %+v", report)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	counter := newTestCounter(&now, time.Hour)
+	counter.Record("203.0.113.10", "RemoteAddr", "text")
+
+	rr := httptest.NewRecorder()
+	counter.Handler(rr, httptest.NewRequest("GET", "/stats?limit=5", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var report models.StatsReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if report.Requests != 1 || report.ASNs[0].Name != "AS64500" {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	for _, target := range []string{"/stats?limit=0", "/stats?limit=many", "/stats?scope=cluster", "/stats?scope=all"} {
+		rr := httptest.NewRecorder()
+		counter.Handler(rr, httptest.NewRequest("GET", target, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", target, rr.Code)
+		}
+	}
+}
+
+func TestHandlerClusterScope(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	counter := newTestCounter(&now, time.Hour)
+	counter.Record("203.0.113.10", "RemoteAddr", "text")
+	counter.SetPeers(func(ctx context.Context) []*models.StatsReport {
+		return []*models.StatsReport{{
+			Requests:         3,
+			ASNs:             []models.StatsCount{{Name: "AS64501", Requests: 2}, {Name: "AS64500", Requests: 1}},
+			DetectionMethods: []models.StatsCount{{Name: "X-Forwarded-For", Requests: 3}},
+		}}
+	})
+
+	serve := func(target string) models.StatsReport {
+		rr := httptest.NewRecorder()
+		counter.Handler(rr, httptest.NewRequest("GET", target, nil))
+		var report models.StatsReport
+		if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+			t.Fatalf("%s: failed to decode %d %q: %v", target, rr.Code, rr.Body.String(), err)
+		}
+		return report
+	}
+
+	if report := serve("/stats"); report.Requests != 1 {
+		t.Errorf("Expected the node's requests by default, got %d", report.Requests)
+	}
+	report := serve("/stats?scope=cluster&limit=1")
+	if report.Requests != 4 {
+		t.Errorf("Expected the requests of every node, got %d", report.Requests)
+	}
+	want := []models.StatsCount{{Name: "AS64500", Requests: 2}}
+	if !reflect.DeepEqual(report.ASNs, want) {
+		t.Errorf("Expected merged ASNs %+v, got %+v", want, report.ASNs)
+	}
+	if len(report.DetectionMethods) != 1 || report.DetectionMethods[0].Name != "X-Forwarded-For" {
+		t.Errorf("Expected the peer's detection method to lead, got %+v", report.DetectionMethods)
+	}
+}
+
+func TestMiddlewarePrivacyMode(t *testing.T) {
+	defer privacy.Configure(false, false)
+	privacy.Configure(true, false)
+
+	var looked []string
+	counter := New(time.Hour, func(ip string) (uint32, string, bool) {
+		looked = append(looked, ip)
+		return 0, "", false
+	})
+	handler := counter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/json", nil)
+	req.RemoteAddr = "203.0.113.7:12345"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !reflect.DeepEqual(looked, []string{"203.0.113.0"}) {
+		t.Errorf("Expected only the truncated address to be recorded, got %q", looked)
+	}
+}
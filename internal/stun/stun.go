@@ -0,0 +1,171 @@
+package stun
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+
+	"myip/internal/logging"
+)
+
+// STUN message constants from RFC 5389
+const (
+	magicCookie    = 0x2112A442
+	headerLength   = 20
+	bindingRequest = 0x0001
+	bindingSuccess = 0x0101
+
+	attrMappedAddress    = 0x0001
+	attrXORMappedAddress = 0x0020
+	attrSoftware         = 0x8022
+	attrResponseOrigin   = 0x802b
+
+	familyIPv4 = 0x01
+	familyIPv6 = 0x02
+)
+
+// software is advertised in the SOFTWARE attribute of every response
+const software = "myip"
+
+var logger = logging.For("stun")
+
+var (
+	errNotSTUN       = errors.New("not a STUN message")
+	errNotBinding    = errors.New("not a Binding request")
+	errBadLength     = errors.New("message length mismatch")
+	errUnsupportedIP = errors.New("unsupported address")
+)
+
+// parseBindingRequest validates a Binding request and returns its transaction ID
+func parseBindingRequest(msg []byte) ([]byte, error) {
+	if len(msg) < headerLength || msg[0]&0xc0 != 0 {
+		return nil, errNotSTUN
+	}
+	if binary.BigEndian.Uint32(msg[4:8]) != magicCookie {
+		return nil, errNotSTUN
+	}
+	if int(binary.BigEndian.Uint16(msg[2:4])) != len(msg)-headerLength {
+		return nil, errBadLength
+	}
+	if binary.BigEndian.Uint16(msg[0:2]) != bindingRequest {
+		return nil, errNotBinding
+	}
+	return msg[8:20], nil
+}
+
+// bindingResponse builds a Binding success response reporting the client's address as seen
+// by the server, plus the address the response is sent from so clients can compare mappings
+func bindingResponse(transactionID []byte, client *net.UDPAddr, origin *net.UDPAddr) ([]byte, error) {
+	var attrs []byte
+
+	mapped, err := addressValue(client, nil)
+	if err != nil {
+		return nil, err
+	}
+	xored, err := addressValue(client, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs = appendAttribute(attrs, attrXORMappedAddress, xored)
+	attrs = appendAttribute(attrs, attrMappedAddress, mapped)
+	if origin != nil && !origin.IP.IsUnspecified() {
+		if value, err := addressValue(origin, nil); err == nil {
+			attrs = appendAttribute(attrs, attrResponseOrigin, value)
+		}
+	}
+	attrs = appendAttribute(attrs, attrSoftware, []byte(software))
+
+	msg := make([]byte, headerLength, headerLength+len(attrs))
+	binary.BigEndian.PutUint16(msg[0:2], bindingSuccess)
+	binary.BigEndian.PutUint16(msg[2:4], uint16(len(attrs)))
+	binary.BigEndian.PutUint32(msg[4:8], magicCookie)
+	copy(msg[8:20], transactionID)
+	return append(msg, attrs...), nil
+}
+
+// addressValue encodes a (XOR-)MAPPED-ADDRESS value; the address is XORed when transactionID is set
+func addressValue(addr *net.UDPAddr, transactionID []byte) ([]byte, error) {
+	ip := addr.IP.To4()
+	family := byte(familyIPv4)
+	if ip == nil {
+		if ip = addr.IP.To16(); ip == nil {
+			return nil, errUnsupportedIP
+		}
+		family = familyIPv6
+	}
+
+	value := make([]byte, 4+len(ip))
+	value[1] = family
+	port := uint16(addr.Port)
+	copy(value[4:], ip)
+
+	if transactionID != nil {
+		port ^= magicCookie >> 16
+
+		var key [16]byte
+		binary.BigEndian.PutUint32(key[0:4], magicCookie)
+		copy(key[4:], transactionID)
+		for i := range value[4:] {
+			value[4+i] ^= key[i]
+		}
+	}
+	binary.BigEndian.PutUint16(value[2:4], port)
+	return value, nil
+}
+
+// appendAttribute appends a type-length-value attribute padded to a 4-byte boundary
+func appendAttribute(msg []byte, attrType uint16, value []byte) []byte {
+	msg = binary.BigEndian.AppendUint16(msg, attrType)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(value)))
+	msg = append(msg, value...)
+	for len(value)%4 != 0 {
+		msg = append(msg, 0)
+		value = append(value, 0)
+	}
+	return msg
+}
+
+// Serve answers STUN Binding requests on conn until ctx is cancelled or conn fails.
+// Anything that is not a well-formed Binding request is dropped without a reply.
+func Serve(ctx context.Context, conn net.PacketConn) error {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	origin, _ := conn.LocalAddr().(*net.UDPAddr)
+	buf := make([]byte, 1500)
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		client, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+
+		transactionID, err := parseBindingRequest(buf[:n])
+		if err != nil {
+			logger.Debugf("Dropping packet from %s: %v", client, err)
+			continue
+		}
+
+		response, err := bindingResponse(transactionID, client, origin)
+		if err != nil {
+			logger.Debugf("Cannot answer %s: %v", client, err)
+			continue
+		}
+
+		if _, err := conn.WriteTo(response, client); err != nil {
+			logger.Debugf("Failed to reply to %s: %v", client, err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package stun
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func newBindingRequest(transactionID string) []byte {
+	msg := make([]byte, headerLength)
+	binary.BigEndian.PutUint16(msg[0:2], bindingRequest)
+	binary.BigEndian.PutUint32(msg[4:8], magicCookie)
+	copy(msg[8:20], transactionID)
+	return msg
+}
+
+// attributes parses the attributes of a STUN message by type
+func attributes(t *testing.T, msg []byte) map[uint16][]byte {
+	t.Helper()
+
+	attrs := map[uint16][]byte{}
+	body := msg[headerLength:]
+	for len(body) >= 4 {
+		attrType := binary.BigEndian.Uint16(body[0:2])
+		length := int(binary.BigEndian.Uint16(body[2:4]))
+		if 4+length > len(body) {
+			t.Fatalf("Attribute %#x overruns message", attrType)
+		}
+		attrs[attrType] = body[4 : 4+length]
+		body = body[4+(length+3)/4*4:]
+	}
+	return attrs
+}
+
+// decodeXORAddress reverses the XOR-MAPPED-ADDRESS encoding
+func decodeXORAddress(value, transactionID []byte) *net.UDPAddr {
+	port := binary.BigEndian.Uint16(value[2:4]) ^ magicCookie>>16
+
+	var key [16]byte
+	binary.BigEndian.PutUint32(key[0:4], magicCookie)
+	copy(key[4:], transactionID)
+
+	ip := make(net.IP, len(value)-4)
+	for i := range ip {
+		ip[i] = value[4+i] ^ key[i]
+	}
+	return &net.UDPAddr{IP: ip, Port: int(port)}
+}
+
+func TestParseBindingRequest(t *testing.T) {
+	valid := newBindingRequest("abcdefghijkl")
+
+	if id, err := parseBindingRequest(valid); err != nil || string(id) != "abcdefghijkl" {
+		t.Errorf("Expected transaction ID abcdefghijkl, got %q (%v)", id, err)
+	}
+
+	badCookie := newBindingRequest("abcdefghijkl")
+	badCookie[4] = 0
+	indication := newBindingRequest("abcdefghijkl")
+	indication[1] = 0x11
+	badLength := append(newBindingRequest("abcdefghijkl"), 0, 0, 0, 0)
+
+	tests := []struct {
+		name     string
+		msg      []byte
+		expected error
+	}{
+		{"Short", valid[:10], errNotSTUN},
+		{"Bad cookie", badCookie, errNotSTUN},
+		{"Not a binding request", indication, errNotBinding},
+		{"Length mismatch", badLength, errBadLength},
+	}
+
+	for _, test := range tests {
+		if _, err := parseBindingRequest(test.msg); err != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, err)
+		}
+	}
+}
+
+func TestBindingResponse(t *testing.T) {
+	transactionID := []byte("abcdefghijkl")
+
+	tests := []*net.UDPAddr{
+		{IP: net.ParseIP("203.0.113.7"), Port: 54321},
+		{IP: net.ParseIP("2001:db8::1"), Port: 3478},
+	}
+
+	for _, client := range tests {
+		msg, err := bindingResponse(transactionID, client, nil)
+		if err != nil {
+			t.Fatalf("bindingResponse(%s) failed: %v", client, err)
+		}
+
+		if binary.BigEndian.Uint16(msg[0:2]) != bindingSuccess {
+			t.Errorf("Expected Binding success, got %#x", binary.BigEndian.Uint16(msg[0:2]))
+		}
+		if string(msg[8:20]) != string(transactionID) {
+			t.Error("Expected transaction ID to be echoed")
+		}
+		if int(binary.BigEndian.Uint16(msg[2:4])) != len(msg)-headerLength {
+			t.Error("Expected message length to match attributes")
+		}
+
+		attrs := attributes(t, msg)
+		if mapped := decodeXORAddress(attrs[attrXORMappedAddress], transactionID); mapped.String() != client.String() {
+			t.Errorf("Expected XOR-MAPPED-ADDRESS %s, got %s", client, mapped)
+		}
+		if string(attrs[attrSoftware]) != software {
+			t.Errorf("Expected SOFTWARE %q, got %q", software, attrs[attrSoftware])
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	loopback := "127.0.0.1:0"
+	server, err := net.ListenPacket("udp", loopback)
+	if err != nil {
+		// IPv6-only hosts
+		loopback = "[::1]:0"
+		if server, err = net.ListenPacket("udp", loopback); err != nil {
+			t.Skipf("UDP not available: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Serve(ctx, server) }()
+
+	client, err := net.ListenPacket("udp", loopback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// Garbage is ignored; the following Binding request is still answered
+	client.WriteTo([]byte("hello"), server.LocalAddr())
+	client.WriteTo(newBindingRequest("abcdefghijkl"), server.LocalAddr())
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1500)
+	n, _, err := client.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("No STUN response: %v", err)
+	}
+
+	attrs := attributes(t, buf[:n])
+	if mapped := decodeXORAddress(attrs[attrXORMappedAddress], []byte("abcdefghijkl")); mapped.String() != client.LocalAddr().String() {
+		t.Errorf("Expected mapped address %s, got %s", client.LocalAddr(), mapped)
+	}
+	if _, ok := attrs[attrResponseOrigin]; !ok {
+		t.Error("Expected RESPONSE-ORIGIN attribute")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Expected clean shutdown, got %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+// Package watch implements "myip watch": polling a myip server for the machine's public address
+// and POSTing a JSON event to a webhook, or passing it to other Notifiers such as the DNS updater,
+// whenever it changes.
+package watch
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/akhfa/myip/pkg/client"
+
+	"myip/internal/logging"
+)
+
+// Address families that can be watched
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// EventChanged is the Event.Event of every notification
+const EventChanged = "ip_changed"
+
+// SignatureHeader carries the hex HMAC-SHA256 of the body, prefixed with "sha256=", when a
+// secret is configured
+const SignatureHeader = "X-Myip-Signature"
+
+// Watch settings
+const (
+	DefaultInterval = 5 * time.Minute
+	MinInterval     = 10 * time.Second
+	notifyTimeout   = 10 * time.Second
+	userAgent       = "myip-watch"
+)
+
+// Options configures a Watcher
+type Options struct {
+	// Server is the base URL of the myip deployment to poll
+	Server string
+
+	// NotifyURL is the http or https webhook receiving change events; optional when Notifiers
+	// are given
+	NotifyURL string
+
+	// Notifiers are told about each change after the webhook
+	Notifiers []Notifier
+
+	// Interval between polls; DefaultInterval when zero, and at least MinInterval
+	Interval time.Duration
+
+	// Family is FamilyIPv4 (default) or FamilyIPv6
+	Family string
+
+	// StateFile remembers the last notified address across restarts; without one the first
+	// address seen is reported as a change
+	StateFile string
+
+	// Secret signs each event in SignatureHeader so the receiver can verify it; empty sends
+	// events unsigned
+	Secret string
+}
+
+// Event is the JSON body POSTed to the webhook
+type Event struct {
+	Event      string    `json:"event"`
+	IP         string    `json:"ip"`
+	PreviousIP string    `json:"previous_ip,omitempty"`
+	Family     string    `json:"family"`
+	Server     string    `json:"server"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// Notifier is told when the public address changes
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// Watcher polls for the public address and notifies the webhook and Notifiers of changes
+type Watcher struct {
+	opts      Options
+	client    *client.Client
+	notifiers []Notifier
+	now       func() time.Time
+
+	// last is the address the notifiers were last told about
+	last string
+}
+
+// New validates opts and creates a Watcher, loading the last address from the state file
+func New(opts Options) (*Watcher, error) {
+	if opts.Interval == 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Interval < MinInterval {
+		return nil, fmt.Errorf("interval must be at least %s, got %s", MinInterval, opts.Interval)
+	}
+	if opts.Family == "" {
+		opts.Family = FamilyIPv4
+	}
+	if opts.Family != FamilyIPv4 && opts.Family != FamilyIPv6 {
+		return nil, fmt.Errorf("family must be %s or %s, got %q", FamilyIPv4, FamilyIPv6, opts.Family)
+	}
+
+	c, err := client.New(client.Options{BaseURL: opts.Server, UserAgent: userAgent})
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		opts:   opts,
+		client: c,
+		now:    time.Now,
+	}
+	if opts.NotifyURL != "" {
+		notify, err := url.Parse(opts.NotifyURL)
+		if err != nil || (notify.Scheme != "http" && notify.Scheme != "https") || notify.Host == "" {
+			return nil, fmt.Errorf("invalid notify URL %q: must be an absolute http or https URL", opts.NotifyURL)
+		}
+		w.notifiers = append(w.notifiers, &webhook{
+			url:    opts.NotifyURL,
+			secret: opts.Secret,
+			http:   &http.Client{Timeout: notifyTimeout},
+		})
+	}
+	w.notifiers = append(w.notifiers, opts.Notifiers...)
+	if len(w.notifiers) == 0 {
+		return nil, errors.New("nothing to notify: set a notify URL")
+	}
+	if opts.StateFile != "" {
+		data, err := os.ReadFile(opts.StateFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("reading state file: %w", err)
+		}
+		w.last = strings.TrimSpace(string(data))
+	}
+	return w, nil
+}
+
+// Run polls until ctx is cancelled. Failed polls and deliveries are logged and retried on the
+// next poll.
+func (w *Watcher) Run(ctx context.Context) error {
+	logging.Infof("Watching the public %s address at %s every %s", w.opts.Family, w.opts.Server, w.opts.Interval)
+
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if err := w.Check(ctx); err != nil && ctx.Err() == nil {
+			logging.Warnf("Watch check failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check polls the server once and notifies the webhook and Notifiers when the address differs
+// from the last one delivered. The address is only recorded once every notifier accepts the
+// event, so a failed delivery is repeated, to all of them, by the next check.
+func (w *Watcher) Check(ctx context.Context) error {
+	var ip string
+	var err error
+	if w.opts.Family == FamilyIPv6 {
+		ip, err = w.client.GetIPv6(ctx)
+	} else {
+		ip, err = w.client.GetIPv4(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("looking up address: %w", err)
+	}
+	if ip == w.last {
+		return nil
+	}
+
+	event := Event{
+		Event:      EventChanged,
+		IP:         ip,
+		PreviousIP: w.last,
+		Family:     w.opts.Family,
+		Server:     w.opts.Server,
+		ChangedAt:  w.now().UTC(),
+	}
+	for _, notifier := range w.notifiers {
+		if err := notifier.Notify(ctx, event); err != nil {
+			return err
+		}
+	}
+	logging.Infof("Public %s address changed from %q to %s", w.opts.Family, w.last, ip)
+
+	w.last = ip
+	if w.opts.StateFile != "" {
+		if err := os.WriteFile(w.opts.StateFile, []byte(ip+"\n"), 0o644); err != nil {
+			return fmt.Errorf("writing state file: %w", err)
+		}
+	}
+	return nil
+}
+
+// webhook POSTs events to a URL
+type webhook struct {
+	url    string
+	secret string
+	http   *http.Client
+}
+
+// Notify POSTs event to the webhook, treating any 2xx status as delivered
+func (h *webhook) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	if h.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(h.secret, body))
+	}
+
+	resp, err := h.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("notifying %s: %w", h.url, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notifying %s: webhook answered %s", h.url, resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the SignatureHeader value for body: "sha256=" followed by the hex HMAC-SHA256
+// of body keyed with secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,202 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hookServer records the events it receives, answering with status
+type hookServer struct {
+	mu         sync.Mutex
+	status     int
+	events     []Event
+	signatures []string
+}
+
+func (h *hookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var event Event
+	json.Unmarshal(body, &event)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event)
+	h.signatures = append(h.signatures, r.Header.Get(SignatureHeader))
+	w.WriteHeader(h.status)
+}
+
+// newServer returns a myip server answering with the address in ip
+func newServer(t *testing.T, ip *string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"ip": *ip})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCheckNotifiesChanges(t *testing.T) {
+	ip := "203.0.113.7"
+	server := newServer(t, &ip)
+	hook := &hookServer{status: http.StatusNoContent}
+	receiver := httptest.NewServer(hook)
+	defer receiver.Close()
+
+	state := filepath.Join(t.TempDir(), "last-ip")
+	w, err := New(Options{Server: server.URL, NotifyURL: receiver.URL, StateFile: state, Secret: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// The first address is a change, the same address again is not
+	for i := 0; i < 2; i++ {
+		if err := w.Check(ctx); err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+	}
+	ip = "198.51.100.4"
+	if err := w.Check(ctx); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	if len(hook.events) != 2 {
+		t.Fatalf("Expected 2 events, got %+v", hook.events)
+	}
+	first, second := hook.events[0], hook.events[1]
+	if first.Event != EventChanged || first.IP != "203.0.113.7" || first.PreviousIP != "" || first.Family != FamilyIPv4 {
+		t.Errorf("Unexpected first event %+v", first)
+	}
+	if second.IP != "198.51.100.4" || second.PreviousIP != "203.0.113.7" || second.Server != server.URL {
+		t.Errorf("Unexpected second event %+v", second)
+	}
+	if !strings.HasPrefix(hook.signatures[0], "sha256=") || len(hook.signatures[0]) != len("sha256=")+64 {
+		t.Errorf("Expected a signature, got %q", hook.signatures[0])
+	}
+
+	if data, _ := os.ReadFile(state); strings.TrimSpace(string(data)) != "198.51.100.4" {
+		t.Errorf("Expected the state file to hold the last address, got %q", data)
+	}
+
+	// A restarted watcher picks up the state file and stays quiet
+	restarted, err := New(Options{Server: server.URL, NotifyURL: receiver.URL, StateFile: state})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.Check(ctx); err != nil || len(hook.events) != 2 {
+		t.Errorf("Expected no event after a restart, got %v and %d events", err, len(hook.events))
+	}
+}
+
+func TestCheckRetriesFailedDelivery(t *testing.T) {
+	ip := "203.0.113.7"
+	server := newServer(t, &ip)
+	hook := &hookServer{status: http.StatusBadGateway}
+	receiver := httptest.NewServer(hook)
+	defer receiver.Close()
+
+	w, err := New(Options{Server: server.URL, NotifyURL: receiver.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Check(context.Background()); err == nil {
+		t.Fatal("Expected a failed delivery to be reported")
+	}
+
+	hook.status = http.StatusOK
+	if err := w.Check(context.Background()); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(hook.events) != 2 || hook.events[1].IP != "203.0.113.7" || hook.signatures[1] != "" {
+		t.Errorf("Expected the event to be delivered again unsigned, got %+v", hook.events)
+	}
+}
+
+// recorder is a Notifier remembering the addresses it was told about
+type recorder struct {
+	ips []string
+	err error
+}
+
+func (r *recorder) Notify(ctx context.Context, event Event) error {
+	r.ips = append(r.ips, event.IP)
+	return r.err
+}
+
+func TestCheckNotifiers(t *testing.T) {
+	ip := "2001:db8::7"
+	server := newServer(t, &ip)
+	failing := &recorder{err: errors.New("provider unavailable")}
+	ok := &recorder{}
+
+	w, err := New(Options{Server: server.URL, Family: FamilyIPv6, Notifiers: []Notifier{ok, failing}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Check(context.Background()); err == nil {
+		t.Fatal("Expected the failing notifier's error")
+	}
+
+	// Every notifier hears about the address again until all of them accept it
+	failing.err = nil
+	if err := w.Check(context.Background()); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if err := w.Check(context.Background()); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(ok.ips) != 2 || len(failing.ips) != 2 || ok.ips[1] != "2001:db8::7" {
+		t.Errorf("Expected two notifications each, got %v and %v", ok.ips, failing.ips)
+	}
+}
+
+func TestNewValidates(t *testing.T) {
+	valid := Options{Server: "https://ip.example.com", NotifyURL: "https://hooks.example.com/ip"}
+
+	tests := []struct {
+		name   string
+		change func(*Options)
+	}{
+		{"short interval", func(o *Options) { o.Interval = time.Second }},
+		{"unknown family", func(o *Options) { o.Family = "ipx" }},
+		{"relative notify URL", func(o *Options) { o.NotifyURL = "/hook" }},
+		{"bad server", func(o *Options) { o.Server = "ftp://ip.example.com" }},
+		{"nothing to notify", func(o *Options) { o.NotifyURL = "" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := valid
+			tt.change(&opts)
+			if _, err := New(opts); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+
+	w, err := New(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.opts.Interval != DefaultInterval || w.opts.Family != FamilyIPv4 {
+		t.Errorf("Expected defaults, got %+v", w.opts)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// RFC 4231 test case 2
+	got := Sign("Jefe", []byte("what do ya want for nothing?"))
+	want := "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,171 @@
+// Package wellknown serves /.well-known/ paths (RFC 8615): static files such as security.txt from
+// a directory, and ACME HTTP-01 challenge responses registered through the admin API, so the
+// service can obtain certificates and publish metadata without a web server in front of it.
+package wellknown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"myip/internal/cache"
+	"myip/internal/logging"
+	"myip/internal/problem"
+)
+
+var logger = logging.For("wellknown")
+
+// ChallengeTTL is how long a registered ACME challenge is served; certificate authorities
+// validate within minutes, and clients remove challenges once they are done
+const ChallengeTTL = time.Hour
+
+// Limits on admin challenge requests
+const (
+	maxKeyAuthorization = 512
+	storeTimeout        = 2 * time.Second
+)
+
+// challengePrefix is the /.well-known/ path of ACME HTTP-01 challenges (RFC 8555 section 8.3)
+const challengePrefix = "acme-challenge/"
+
+// tokenPattern matches ACME tokens, which are base64url without padding
+var tokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// Handler serves /.well-known/ paths
+type Handler struct {
+	// root holds the static files; nil serves none
+	root *os.Root
+
+	// store holds the ACME challenges; nil disables them
+	store cache.Store
+}
+
+// New creates a Handler serving the files in dir, when it is not empty, and the ACME challenges
+// in store, when it is not nil. Challenges are kept in the shared cache so that whichever replica
+// the certificate authority reaches can answer.
+func New(dir string, store cache.Store) (*Handler, error) {
+	h := &Handler{store: store}
+	if dir != "" {
+		root, err := os.OpenRoot(dir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid WELL_KNOWN_DIR: %w", err)
+		}
+		h.root = root
+	}
+	return h, nil
+}
+
+// ServeHTTP serves /.well-known/{path...}: a registered ACME challenge, otherwise a file from the
+// directory. Directories are not listed, and paths cannot leave the directory, even through
+// symbolic links.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("path")
+
+	if token, ok := strings.CutPrefix(name, challengePrefix); ok && h.store != nil && tokenPattern.MatchString(token) {
+		ctx, cancel := context.WithTimeout(r.Context(), storeTimeout)
+		keyAuthorization, found, err := h.store.Get(ctx, challengeKey(token))
+		cancel()
+		if err != nil {
+			logging.Warnf("ACME challenge lookup for %s failed: %v", token, err)
+		}
+		if found {
+			logger.Debugf("Answering ACME challenge %s", token)
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(keyAuthorization)
+			return
+		}
+	}
+
+	if h.root == nil {
+		http.NotFound(w, r)
+		return
+	}
+	h.serveFile(w, r, name)
+}
+
+// serveFile serves a regular file below the root
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	name = path.Clean("/" + name)[1:]
+	if name == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	file, err := h.root.Open(name)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logger.Debugf("Refusing /.well-known/%s: %v", name, err)
+		}
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+// Challenge serves the admin endpoint registering ACME challenges: PUT
+// /admin/acme-challenge/{token} with the key authorization as the body serves it for
+// ChallengeTTL, and DELETE removes it
+func (h *Handler) Challenge(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	if !tokenPattern.MatchString(token) {
+		http.Error(w, "Invalid ACME token", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), storeTimeout)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxKeyAuthorization))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Key authorization longer than %d bytes", maxKeyAuthorization), http.StatusBadRequest)
+			return
+		}
+		// A key authorization is the token and the account key thumbprint joined by a dot
+		keyAuthorization := strings.TrimSpace(string(body))
+		thumbprint, ok := strings.CutPrefix(keyAuthorization, token+".")
+		if !ok || !tokenPattern.MatchString(thumbprint) {
+			http.Error(w, "Body must be the key authorization, <token>.<thumbprint>", http.StatusBadRequest)
+			return
+		}
+		if err := h.store.Set(ctx, challengeKey(token), []byte(keyAuthorization), ChallengeTTL); err != nil {
+			logging.Warnf("Storing ACME challenge %s failed: %v", token, err)
+			problem.Error(w, r, http.StatusServiceUnavailable, "Challenge store unavailable")
+			return
+		}
+		logging.Infof("Registered ACME challenge %s", token)
+	case http.MethodDelete:
+		if err := h.store.Delete(ctx, challengeKey(token)); err != nil {
+			logging.Warnf("Removing ACME challenge %s failed: %v", token, err)
+			problem.Error(w, r, http.StatusServiceUnavailable, "Challenge store unavailable")
+			return
+		}
+		logging.Infof("Removed ACME challenge %s", token)
+	default:
+		w.Header().Set("Allow", "PUT, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// challengeKey is the cache key of a challenge
+func challengeKey(token string) string {
+	return "acme:" + token
+}
@@ -0,0 +1,116 @@
+package wellknown
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"myip/internal/cache"
+)
+
+// serve routes a request the way setupRoutes does
+func serve(h *Handler, method, target, body string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/{path...}", h.ServeHTTP)
+	mux.HandleFunc("PUT /admin/acme-challenge/{token}", h.Challenge)
+	mux.HandleFunc("DELETE /admin/acme-challenge/{token}", h.Challenge)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func TestServeFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "security.txt"), []byte("Contact: mailto:security@example.com\n"), 0o644)
+	os.Mkdir(filepath.Join(dir, "pki-validation"), 0o755)
+	outside := filepath.Join(t.TempDir(), "secret")
+	os.WriteFile(outside, []byte("secret"), 0o644)
+	os.Symlink(outside, filepath.Join(dir, "escape"))
+
+	h, err := New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(h, http.MethodGet, "/.well-known/security.txt", "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "Contact:") {
+		t.Errorf("Expected security.txt, got %d %q", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Expected text/plain, got %q", ct)
+	}
+
+	for _, target := range []string{
+		"/.well-known/missing",
+		"/.well-known/pki-validation",
+		"/.well-known/escape",
+		"/.well-known/acme-challenge/token",
+	} {
+		if w := serve(h, http.MethodGet, target, ""); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", target, w.Code)
+		}
+	}
+}
+
+func TestACMEChallenges(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "acme-challenge"), 0o755)
+	os.WriteFile(filepath.Join(dir, "acme-challenge", "webroot"), []byte("webroot.thumb"), 0o644)
+
+	h, err := New(dir, cache.NewMemory(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyAuthorization := "LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0.9jg46WB3rR_AHD-EBXdN7cBkH1WOu0tA3M9fm21mqTI"
+
+	if w := serve(h, http.MethodPut, "/admin/acme-challenge/LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0", keyAuthorization+"\n"); w.Code != http.StatusNoContent {
+		t.Fatalf("PUT = %d %q, want 204", w.Code, w.Body.String())
+	}
+	w := serve(h, http.MethodGet, "/.well-known/acme-challenge/LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0", "")
+	if w.Code != http.StatusOK || w.Body.String() != keyAuthorization {
+		t.Errorf("GET challenge = %d %q, want the key authorization", w.Code, w.Body.String())
+	}
+
+	// Files written by a webroot ACME client are served too
+	if w := serve(h, http.MethodGet, "/.well-known/acme-challenge/webroot", ""); w.Body.String() != "webroot.thumb" {
+		t.Errorf("Expected the webroot challenge file, got %d %q", w.Code, w.Body.String())
+	}
+
+	if w := serve(h, http.MethodDelete, "/admin/acme-challenge/LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d, want 204", w.Code)
+	}
+	if w := serve(h, http.MethodGet, "/.well-known/acme-challenge/LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected the removed challenge to be gone, got %d", w.Code)
+	}
+}
+
+func TestChallengeValidation(t *testing.T) {
+	h, err := New("", cache.NewMemory(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, target, body string
+	}{
+		{"token with dots", "/admin/acme-challenge/a.b", "a.b.c"},
+		{"body for another token", "/admin/acme-challenge/abc", "xyz.thumb"},
+		{"missing thumbprint", "/admin/acme-challenge/abc", "abc."},
+		{"oversized body", "/admin/acme-challenge/abc", "abc." + strings.Repeat("x", 600)},
+	}
+	for _, tt := range tests {
+		if w := serve(h, http.MethodPut, tt.target, tt.body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want 400", tt.name, w.Code)
+		}
+	}
+}
+
+func TestNewInvalidDir(t *testing.T) {
+	if _, err := New(filepath.Join(t.TempDir(), "missing"), nil); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
@@ -0,0 +1,169 @@
+package ipdetect
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// Private IP ranges (IPv4)
+var privateIPRanges = []netip.Prefix{
+	// RFC 1918
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	// RFC 3927
+	netip.MustParsePrefix("169.254.0.0/16"),
+	// RFC 5735
+	netip.MustParsePrefix("127.0.0.0/8"),
+}
+
+// Private IPv6 ranges
+var privateIPv6Ranges = []netip.Prefix{
+	// RFC 4193 - Unique Local Addresses
+	netip.MustParsePrefix("fc00::/7"),
+	// RFC 4291 - Link-Local
+	netip.MustParsePrefix("fe80::/10"),
+	// RFC 4291 - Loopback
+	netip.MustParsePrefix("::1/128"),
+}
+
+// Bogon ranges beyond the private ones that never appear as a genuine client address
+var bogonRanges = []netip.Prefix{
+	// RFC 1122 - "this network"
+	netip.MustParsePrefix("0.0.0.0/8"),
+	// RFC 6598 - Shared address space (carrier-grade NAT)
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// parseAddr parses an address without a zone, as found in headers and peer addresses.
+// IPv4-mapped IPv6 addresses are unmapped, so they are IPv4 addresses to the range checks.
+func parseAddr(ip string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || addr.Zone() != "" {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// IsValid checks if the given string is a valid IP address
+func IsValid(ip string) bool {
+	_, ok := parseAddr(ip)
+	return ok
+}
+
+// Normalize returns an IPv4-mapped IPv6 address such as "::ffff:203.0.113.1", which dual-stack
+// listeners and some proxies report for IPv4 clients, as the dotted-quad IPv4 address. Other
+// values are returned unchanged.
+func Normalize(ip string) string {
+	if !strings.Contains(ip, ":") {
+		return ip
+	}
+	if addr, ok := parseAddr(ip); ok && addr.Is4() {
+		return addr.String()
+	}
+	return ip
+}
+
+// IsIPv4 reports whether ip is a valid IPv4 address, including an IPv4-mapped IPv6 address
+func IsIPv4(ip string) bool {
+	addr, ok := parseAddr(ip)
+	return ok && addr.Is4()
+}
+
+// IsIPv6 reports whether ip is a valid IPv6 address. IPv4-mapped addresses are not: the client
+// connected over IPv4.
+func IsIPv6(ip string) bool {
+	addr, ok := parseAddr(ip)
+	return ok && addr.Is6()
+}
+
+// IsPrivate checks if the given IP address is in a private, link-local, or loopback range
+func IsPrivate(ip string) bool {
+	addr, ok := parseAddr(ip)
+	return ok && isPrivate(addr)
+}
+
+func isPrivate(addr netip.Addr) bool {
+	ranges := privateIPv6Ranges
+	if addr.Is4() {
+		ranges = privateIPRanges
+	}
+	for _, prefix := range ranges {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBogon reports whether ip is private or otherwise not routable on the public internet:
+// unspecified, multicast, "this network", or carrier-grade NAT shared address space
+func IsBogon(ip string) bool {
+	addr, ok := parseAddr(ip)
+	return ok && isBogon(addr)
+}
+
+func isBogon(addr netip.Addr) bool {
+	if isPrivate(addr) || addr.IsUnspecified() || addr.IsMulticast() {
+		return true
+	}
+	for _, prefix := range bogonRanges {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixes converts trusted proxy networks to netip prefixes, with IPv4 networks given in 16-byte
+// form unmapped so they match IPv4 peers. Networks with a non-contiguous mask, which ParseCIDRs
+// never returns, are dropped.
+func prefixes(networks []*net.IPNet) []netip.Prefix {
+	list := make([]netip.Prefix, 0, len(networks))
+	for _, network := range networks {
+		addr, ok := netip.AddrFromSlice(network.IP)
+		ones, bits := network.Mask.Size()
+		if !ok || bits == 0 {
+			continue
+		}
+		addr = addr.Unmap()
+		switch {
+		case addr.Is4() && bits == 128:
+			ones = max(ones-96, 0)
+		case addr.Is6() && bits == 32:
+			continue
+		}
+		list = append(list, netip.PrefixFrom(addr, ones).Masked())
+	}
+	return list
+}
+
+// ParseCIDRs parses a list of CIDR ranges; bare addresses are treated as single-host ranges
+func ParseCIDRs(list []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(list))
+	for _, item := range list {
+		item = strings.TrimSpace(item)
+		if !strings.Contains(item, "/") {
+			parsedIP := net.ParseIP(item)
+			if parsedIP == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", item)
+			}
+			bits := 128
+			if parsedIP.To4() != nil {
+				parsedIP = parsedIP.To4()
+				bits = 32
+			}
+			networks = append(networks, &net.IPNet{IP: parsedIP, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", item, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
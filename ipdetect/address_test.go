@@ -0,0 +1,152 @@
+package ipdetect
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsPrivate(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.1.1", true},
+		{"127.0.0.1", true},
+		{"fd00::1", true},
+		{"fe80::1", true},
+		{"::1", true},
+		{"::ffff:192.168.1.1", true},
+		{"fe80::1%eth0", false},
+		{"203.0.113.1", false},
+		{"2001:db8::1", false},
+		{"", false},
+		{"garbage", false},
+	}
+
+	for _, test := range tests {
+		if result := IsPrivate(test.ip); result != test.expected {
+			t.Errorf("IsPrivate(%q) = %t, expected %t", test.ip, result, test.expected)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{"::ffff:203.0.113.1", "203.0.113.1"},
+		{"::FFFF:203.0.113.1", "203.0.113.1"},
+		{"::ffff:cb00:7101", "203.0.113.1"},
+		{"203.0.113.1", "203.0.113.1"},
+		{"2001:db8::1", "2001:db8::1"},
+		{"::203.0.113.1", "::203.0.113.1"},
+		{"garbage", "garbage"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if result := Normalize(test.ip); result != test.expected {
+			t.Errorf("Normalize(%q) = %q, expected %q", test.ip, result, test.expected)
+		}
+		if IsIPv6(test.expected) != IsIPv6(test.ip) {
+			t.Errorf("IsIPv6(%q) disagrees with its normalized form", test.ip)
+		}
+	}
+	if IsIPv6("::ffff:203.0.113.1") || !IsIPv4("::ffff:203.0.113.1") {
+		t.Error("Expected an IPv4-mapped address to be IPv4")
+	}
+}
+
+func TestIsBogon(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{"192.168.1.1", true},
+		{"0.0.0.0", true},
+		{"0.1.2.3", true},
+		{"100.64.0.1", true},
+		{"224.0.0.1", true},
+		{"ff02::1", true},
+		{"::", true},
+		{"203.0.113.1", false},
+		{"2001:db8::1", false},
+		{"garbage", false},
+	}
+
+	for _, test := range tests {
+		if result := IsBogon(test.ip); result != test.expected {
+			t.Errorf("IsBogon(%q) = %t, expected %t", test.ip, result, test.expected)
+		}
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	networks, err := ParseCIDRs([]string{"10.0.0.0/8", " 203.0.113.5 ", "2001:db8::1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"10.0.0.0/8", "203.0.113.5/32", "2001:db8::1/128"}
+	if len(networks) != len(expected) {
+		t.Fatalf("Expected %d networks, got %d", len(expected), len(networks))
+	}
+	for i, network := range networks {
+		if network.String() != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], network.String())
+		}
+	}
+
+	for _, invalid := range []string{"not-an-ip", "10.0.0.0/33"} {
+		if _, err := ParseCIDRs([]string{invalid}); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestIsValidRejectsZones(t *testing.T) {
+	for _, ip := range []string{"fe80::1%eth0", "::ffff:203.0.113.1%1"} {
+		if IsValid(ip) || IsIPv4(ip) || IsIPv6(ip) {
+			t.Errorf("Expected %q with a zone to be invalid", ip)
+		}
+		if result := Normalize(ip); result != ip {
+			t.Errorf("Normalize(%q) = %q, expected it unchanged", ip, result)
+		}
+	}
+}
+
+// TestTrustedNetworkForms checks that trusted networks match the same peers whether their IPv4
+// addresses are given in 4- or 16-byte form
+func TestTrustedNetworkForms(t *testing.T) {
+	networks := []*net.IPNet{
+		{IP: net.ParseIP("10.0.0.0"), Mask: net.CIDRMask(8, 32)},
+		{IP: net.ParseIP("192.168.0.0"), Mask: net.CIDRMask(112, 128)},
+		{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(32, 128)},
+		{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(16, 32)},
+		{IP: net.ParseIP("172.16.0.0"), Mask: net.IPMask{255, 0, 255, 0}},
+	}
+	d := New(Options{TrustedProxies: networks})
+
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{"10.1.2.3", true},
+		{"::ffff:10.1.2.3", true},
+		{"192.168.3.4", true},
+		{"192.169.0.1", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+		{"172.16.0.1", false},
+	}
+	for _, test := range tests {
+		addr, _ := parseAddr(test.ip)
+		if result := d.isTrusted(addr); result != test.expected {
+			t.Errorf("isTrusted(%q) = %t, expected %t", test.ip, result, test.expected)
+		}
+	}
+}
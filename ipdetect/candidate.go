@@ -0,0 +1,88 @@
+package ipdetect
+
+import (
+	"strings"
+	"sync"
+)
+
+// candidatePool recycles the slices holding the comma-separated addresses of a header value
+var candidatePool = sync.Pool{
+	New: func() any {
+		candidates := make([]string, 0, 8)
+		return &candidates
+	},
+}
+
+// candidate returns the address in a comma-separated header value chosen by the detector's
+// strategy among those accepted by match, normalized by Normalize, or "" when none is
+func (d *Detector) candidate(value string, match func(string) bool) string {
+	candidates := splitCandidates(value)
+	found := d.choose(*candidates, match)
+	releaseCandidates(candidates)
+	return found
+}
+
+// splitCandidates returns the trimmed addresses of a comma-separated header value in a pooled
+// slice, to be handed back with releaseCandidates
+func splitCandidates(value string) *[]string {
+	candidates := candidatePool.Get().(*[]string)
+
+	list := (*candidates)[:0]
+	for value != "" {
+		var part string
+		part, value, _ = strings.Cut(value, ",")
+		list = append(list, strings.TrimSpace(part))
+	}
+	*candidates = list
+	return candidates
+}
+
+// releaseCandidates returns a slice from splitCandidates to the pool
+func releaseCandidates(candidates *[]string) {
+	// Keep the grown slice for the next request without pinning this request's strings
+	clear(*candidates)
+	*candidates = (*candidates)[:0]
+	candidatePool.Put(candidates)
+}
+
+// choose returns the address in list chosen by the detector's strategy among those accepted by
+// match, normalized by Normalize, or "" when none is
+func (d *Detector) choose(list []string, match func(string) bool) string {
+	var found string
+	switch d.strategy {
+	case RightmostUntrusted:
+		found = d.rightmostUntrusted(list, match)
+	case Rightmost:
+		for i := len(list) - 1; i >= 0; i-- {
+			if match(list[i]) {
+				found = list[i]
+				break
+			}
+		}
+	default:
+		for _, candidate := range list {
+			if match(candidate) {
+				found = candidate
+				break
+			}
+		}
+	}
+	return Normalize(found)
+}
+
+// rightmostUntrusted returns the last address in list accepted by match that is not a trusted
+// proxy. When every accepted address is a trusted proxy the leftmost one is returned, as it is the
+// closest to the client.
+func (d *Detector) rightmostUntrusted(list []string, match func(string) bool) string {
+	found := ""
+	for i := len(list) - 1; i >= 0; i-- {
+		if !match(list[i]) {
+			continue
+		}
+		found = list[i]
+		if addr, ok := parseAddr(found); ok && !d.isTrusted(addr) {
+			return found
+		}
+	}
+	return found
+}
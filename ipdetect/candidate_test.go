@@ -0,0 +1,86 @@
+package ipdetect
+
+import "testing"
+
+func TestCandidate(t *testing.T) {
+	d := New(Options{})
+
+	tests := []struct {
+		value    string
+		match    func(string) bool
+		expected string
+	}{
+		{"203.0.113.1", IsValid, "203.0.113.1"},
+		{"unknown, 203.0.113.1, 10.0.0.1", IsValid, "203.0.113.1"},
+		{" 2001:db8::1 ,203.0.113.1", IsIPv4, "203.0.113.1"},
+		{"203.0.113.1,2001:db8::1", IsIPv6, "2001:db8::1"},
+		{",,203.0.113.1,", IsValid, "203.0.113.1"},
+		{"unknown, garbage", IsValid, ""},
+	}
+
+	for _, tc := range tests {
+		if got := d.candidate(tc.value, tc.match); got != tc.expected {
+			t.Errorf("candidate(%q) = %q, want %q", tc.value, got, tc.expected)
+		}
+	}
+}
+
+func TestCandidateRightmostUntrusted(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8", "198.51.100.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := New(Options{TrustedProxies: trusted, Strategy: RightmostUntrusted})
+
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"203.0.113.1", "203.0.113.1"},
+		{"192.0.2.66, 203.0.113.1, 198.51.100.7, 10.0.0.1", "203.0.113.1"},
+		{"203.0.113.1, garbage", "203.0.113.1"},
+		{"198.51.100.7, 10.0.0.1", "198.51.100.7"},
+		{"unknown", ""},
+	}
+
+	for _, tc := range tests {
+		if got := d.candidate(tc.value, IsValid); got != tc.expected {
+			t.Errorf("candidate(%q) = %q, want %q", tc.value, got, tc.expected)
+		}
+	}
+}
+
+func TestCandidateRightmost(t *testing.T) {
+	d := New(Options{Strategy: Rightmost})
+
+	tests := []struct {
+		value    string
+		match    func(string) bool
+		expected string
+	}{
+		{"203.0.113.1", IsValid, "203.0.113.1"},
+		{"192.0.2.66, 203.0.113.1, 10.0.0.1", IsValid, "10.0.0.1"},
+		{"203.0.113.1, garbage", IsValid, "203.0.113.1"},
+		{"203.0.113.1, 2001:db8::1", IsIPv4, "203.0.113.1"},
+		{"unknown", IsValid, ""},
+	}
+
+	for _, tc := range tests {
+		if got := d.candidate(tc.value, tc.match); got != tc.expected {
+			t.Errorf("candidate(%q) = %q, want %q", tc.value, got, tc.expected)
+		}
+	}
+}
+
+// TestCandidateDoesNotAllocate guards the pooled candidate slice: splitting a header value
+// must not allocate once the pool is warm
+func TestCandidateDoesNotAllocate(t *testing.T) {
+	d := New(Options{})
+	value := "unknown, 198.51.100.7, 203.0.113.1, 10.0.0.1"
+	match := func(s string) bool { return s == "10.0.0.1" }
+	d.candidate(value, match)
+
+	if allocs := testing.AllocsPerRun(100, func() { d.candidate(value, match) }); allocs != 0 {
+		t.Errorf("Expected no allocations, got %.1f per call", allocs)
+	}
+}
@@ -0,0 +1,123 @@
+package ipdetect
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Hop classes reported in Hop.Class
+const (
+	HopPublic  = "public"
+	HopPrivate = "private"
+	HopUnknown = "unknown"
+)
+
+// Hop is one address along the path of a request, as reconstructed by Detector.Chain
+type Hop struct {
+	// IP is the hop's address, or the identifier given in its place, such as "unknown" or an
+	// obfuscated "_node" in a Forwarded header
+	IP string
+
+	// Source is the header the hop was taken from, or SourceRemoteAddr for the peer
+	Source string
+
+	// Class is HopPublic, HopPrivate for private and other non-routable addresses, or
+	// HopUnknown when IP is not an address
+	Class string
+
+	// Trusted reports whether the address is one of the trusted proxies
+	Trusted bool
+
+	// Via is the Via header entry, such as "1.1 varnish", matched to the hop by position; empty
+	// when the proxies sent fewer entries
+	Via string
+}
+
+// Chain reconstructs the path of a request from the client to the server: the addresses in the
+// Forwarded header, or X-Forwarded-For when there is none, in the order proxies appended them,
+// followed by the peer address. Via entries are matched to the proxies after the client in the
+// same order. Headers are only read from trusted proxies, as for ClientIP; otherwise the chain
+// is the peer alone.
+func (d *Detector) Chain(r *http.Request) []Hop {
+	var hops []Hop
+	if d.trustedHeaders(r) != nil {
+		hops = forwardedHops(r.Header)
+		if len(hops) == 0 {
+			hops = forwardedForHops(r.Header)
+		}
+	}
+	hops = append(hops, Hop{IP: peerHost(r), Source: SourceRemoteAddr})
+
+	var via []string
+	if len(hops) > 1 {
+		via = headerList(r.Header, "Via")
+	}
+	for i := range hops {
+		hop := &hops[i]
+		hop.IP = hostOnly(hop.IP)
+		hop.Class = HopUnknown
+		if addr, ok := parseAddr(hop.IP); ok {
+			hop.Class = HopPublic
+			if isBogon(addr) {
+				hop.Class = HopPrivate
+			}
+			hop.Trusted = d.isTrusted(addr)
+		}
+		// The first proxy's Via entry describes the proxy the client connected to, the second hop
+		if i > 0 && i <= len(via) {
+			hop.Via = via[i-1]
+		}
+	}
+	return hops
+}
+
+// forwardedHops returns the for= parameters of the RFC 7239 Forwarded header
+func forwardedHops(header http.Header) []Hop {
+	var hops []Hop
+	for _, element := range headerList(header, "Forwarded") {
+		for _, pair := range strings.Split(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(key, "for") {
+				hops = append(hops, Hop{IP: strings.Trim(value, `"`), Source: "Forwarded"})
+			}
+		}
+	}
+	return hops
+}
+
+// forwardedForHops returns the addresses of the X-Forwarded-For header
+func forwardedForHops(header http.Header) []Hop {
+	var hops []Hop
+	for _, value := range headerList(header, "X-Forwarded-For") {
+		hops = append(hops, Hop{IP: value, Source: "X-Forwarded-For"})
+	}
+	return hops
+}
+
+// headerList returns the comma-separated entries of every occurrence of a header, in order
+func headerList(header http.Header, name string) []string {
+	var list []string
+	for _, value := range header.Values(name) {
+		for value != "" {
+			var entry string
+			entry, value, _ = strings.Cut(value, ",")
+			if entry = strings.TrimSpace(entry); entry != "" {
+				list = append(list, entry)
+			}
+		}
+	}
+	return list
+}
+
+// hostOnly strips the port and IPv6 brackets from an address such as "[2001:db8::1]:4711" or
+// "192.0.2.60:8080", and normalizes IPv4-mapped addresses, returning other values unchanged
+func hostOnly(addr string) string {
+	if IsValid(addr) {
+		return Normalize(addr)
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return Normalize(host)
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
@@ -0,0 +1,98 @@
+package ipdetect
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8", "198.51.100.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := New(Options{TrustedProxies: trusted})
+
+	tests := []struct {
+		name    string
+		headers map[string][]string
+		remote  string
+		want    []Hop
+	}{
+		{
+			name:   "direct",
+			remote: "203.0.113.7:41234",
+			want:   []Hop{{IP: "203.0.113.7", Source: SourceRemoteAddr, Class: HopPublic}},
+		},
+		{
+			name: "x-forwarded-for with via",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"203.0.113.7, 198.51.100.4", "192.168.1.2"},
+				"Via":             {"1.1 cdn-edge, 1.1 lb"},
+			},
+			remote: "10.0.0.2:41234",
+			want: []Hop{
+				{IP: "203.0.113.7", Source: "X-Forwarded-For", Class: HopPublic},
+				{IP: "198.51.100.4", Source: "X-Forwarded-For", Class: HopPublic, Trusted: true, Via: "1.1 cdn-edge"},
+				{IP: "192.168.1.2", Source: "X-Forwarded-For", Class: HopPrivate, Via: "1.1 lb"},
+				{IP: "10.0.0.2", Source: SourceRemoteAddr, Class: HopPrivate, Trusted: true},
+			},
+		},
+		{
+			name: "forwarded takes precedence",
+			headers: map[string][]string{
+				"Forwarded":       {`for=192.0.2.60;proto=http;by=203.0.113.43, For="[2001:db8:cafe::17]:4711"`, "for=_hidden"},
+				"X-Forwarded-For": {"203.0.113.99"},
+			},
+			remote: "10.0.0.2:41234",
+			want: []Hop{
+				{IP: "192.0.2.60", Source: "Forwarded", Class: HopPublic},
+				{IP: "2001:db8:cafe::17", Source: "Forwarded", Class: HopPublic},
+				{IP: "_hidden", Source: "Forwarded", Class: HopUnknown},
+				{IP: "10.0.0.2", Source: SourceRemoteAddr, Class: HopPrivate, Trusted: true},
+			},
+		},
+		{
+			name: "untrusted peer",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"192.0.2.66"},
+				"Via":             {"1.1 spoofed"},
+			},
+			remote: "203.0.113.7:41234",
+			want:   []Hop{{IP: "203.0.113.7", Source: SourceRemoteAddr, Class: HopPublic}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remote
+			for name, values := range tt.headers {
+				for _, value := range values {
+					req.Header.Add(name, value)
+				}
+			}
+
+			if got := d.Chain(req); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Chain() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostOnly(t *testing.T) {
+	tests := map[string]string{
+		"203.0.113.7":         "203.0.113.7",
+		"203.0.113.7:8080":    "203.0.113.7",
+		"2001:db8::1":         "2001:db8::1",
+		"[2001:db8::1]:4711":  "2001:db8::1",
+		"[2001:db8::1]":       "2001:db8::1",
+		"unknown":             "unknown",
+		"_obfuscated:_port42": "_obfuscated",
+	}
+	for addr, want := range tests {
+		if got := hostOnly(addr); got != want {
+			t.Errorf("hostOnly(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package ipdetect_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+
+	"github.com/akhfa/myip/ipdetect"
+)
+
+func Example() {
+	trusted, err := ipdetect.ParseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		panic(err)
+	}
+	detector := ipdetect.New(ipdetect.Options{TrustedProxies: trusted})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.2:41234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+
+	clientIP, source := detector.ClientIP(req)
+	fmt.Println(clientIP, source)
+	// Output: 203.0.113.7 X-Forwarded-For
+}
+
+func Example_rightmostUntrusted() {
+	trusted, err := ipdetect.ParseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		panic(err)
+	}
+	detector := ipdetect.New(ipdetect.Options{
+		HeaderPriority: []string{"X-Forwarded-For"},
+		TrustedProxies: trusted,
+		Strategy:       ipdetect.RightmostUntrusted,
+	})
+
+	// The client prepended a fake address; its real address was appended by the proxy
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.2:41234"
+	req.Header.Set("X-Forwarded-For", "192.0.2.66, 203.0.113.7, 10.0.0.1")
+
+	clientIP, _ := detector.ClientIP(req)
+	fmt.Println(clientIP)
+	// Output: 203.0.113.7
+}
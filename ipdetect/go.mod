@@ -0,0 +1,3 @@
+module github.com/akhfa/myip/ipdetect
+
+go 1.24.1
@@ -0,0 +1,424 @@
+// Package ipdetect determines the client IP of an HTTP request from proxy headers and the peer
+// address. It is the detection logic behind the myip server, published as its own module so other
+// Go services can report exactly the same client IP.
+//
+//	trusted, _ := ipdetect.ParseCIDRs([]string{"10.0.0.0/8"})
+//	detector := ipdetect.New(ipdetect.Options{TrustedProxies: trusted})
+//	clientIP, source := detector.ClientIP(r)
+//
+// A Detector is immutable and safe for concurrent use; build a new one to change its options.
+package ipdetect
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// SourceRemoteAddr is the source reported when the client IP is the connection's peer address
+// rather than a header value
+const SourceRemoteAddr = "RemoteAddr"
+
+// unixPeer is the RemoteAddr net/http reports for connections on a Unix domain socket, whose peer
+// is necessarily a local process such as a reverse proxy
+const unixPeer = "@"
+
+// DefaultHeaderPriority is the header priority order for IP detection unless configured otherwise
+var DefaultHeaderPriority = []string{
+	"CF-Connecting-IP",    // Cloudflare
+	"True-Client-IP",      // Cloudflare Enterprise
+	"X-Real-IP",           // nginx proxy/FastCGI
+	"X-Forwarded-For",     // Standard proxy header
+	"X-Client-IP",         // Apache mod_proxy_http
+	"X-Cluster-Client-IP", // Cluster environments
+	"X-Forwarded",         // Less common
+	"Forwarded-For",       // Less common
+	"Forwarded",           // Less common
+}
+
+// Strategy selects which address of a comma-separated header value such as X-Forwarded-For is
+// taken as the client
+type Strategy int
+
+const (
+	// Leftmost takes the first valid address, the client as reported to the outermost proxy.
+	// This is the default and what the myip server uses.
+	Leftmost Strategy = iota
+
+	// RightmostUntrusted takes the last valid address outside TrustedProxies. Clients can put
+	// anything at the start of X-Forwarded-For, but not after the address their proxy appends, so
+	// this resists spoofing when every proxy in front of the service is listed in TrustedProxies.
+	RightmostUntrusted
+
+	// Rightmost takes the last valid address, the one appended by the proxy closest to the
+	// service. It suits a single proxy whose addresses are not known in advance.
+	Rightmost
+)
+
+// String returns the strategy name
+func (s Strategy) String() string {
+	switch s {
+	case Leftmost:
+		return "leftmost"
+	case RightmostUntrusted:
+		return "rightmost-untrusted"
+	case Rightmost:
+		return "rightmost"
+	}
+	return "Strategy(" + strconv.Itoa(int(s)) + ")"
+}
+
+// ParseStrategy parses a strategy name as returned by Strategy.String
+func ParseStrategy(name string) (Strategy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "leftmost":
+		return Leftmost, nil
+	case "rightmost-untrusted":
+		return RightmostUntrusted, nil
+	case "rightmost":
+		return Rightmost, nil
+	}
+	return Leftmost, fmt.Errorf("unknown strategy %q", name)
+}
+
+// Options configures a Detector
+type Options struct {
+	// HeaderPriority lists the headers consulted for the client IP, highest priority first.
+	// Empty means DefaultHeaderPriority.
+	HeaderPriority []string
+
+	// TrustedProxies restricts header-based detection to requests whose peer address falls in one
+	// of these ranges, or that arrived over a Unix domain socket. When empty, headers are trusted
+	// from any peer.
+	TrustedProxies []*net.IPNet
+
+	// Strategy selects the address taken from multi-address headers
+	Strategy Strategy
+
+	// Debugf, when set, receives a trace of each detection decision
+	Debugf func(format string, args ...any)
+}
+
+// Detector extracts client addresses from requests according to its Options
+type Detector struct {
+	headers  []string
+	keys     []string
+	trusted  []netip.Prefix
+	strategy Strategy
+	debugf   func(format string, args ...any)
+}
+
+// New creates a Detector. The options' slices are copied, so later changes to them do not
+// affect the detector.
+func New(opts Options) *Detector {
+	headers := opts.HeaderPriority
+	if len(headers) == 0 {
+		headers = DefaultHeaderPriority
+	}
+
+	debugf := opts.Debugf
+	if debugf == nil {
+		debugf = func(string, ...any) {}
+	}
+
+	keys := make([]string, len(headers))
+	for i, header := range headers {
+		keys[i] = textproto.CanonicalMIMEHeaderKey(header)
+	}
+
+	return &Detector{
+		headers:  append([]string(nil), headers...),
+		keys:     keys,
+		trusted:  prefixes(opts.TrustedProxies),
+		strategy: opts.Strategy,
+		debugf:   debugf,
+	}
+}
+
+// HeaderPriority returns the headers consulted for the client IP, highest priority first
+func (d *Detector) HeaderPriority() []string {
+	return append([]string(nil), d.headers...)
+}
+
+// trustedHeaders returns the headers to consult for the request, or nil when the
+// request did not arrive from a trusted proxy and only RemoteAddr may be used
+func (d *Detector) trustedHeaders(r *http.Request) []string {
+	if len(d.trusted) == 0 || r.RemoteAddr == unixPeer {
+		return d.headers
+	}
+
+	peer, ok := parseAddr(peerHost(r))
+	if !ok {
+		return nil
+	}
+	if d.isTrusted(peer) {
+		return d.headers
+	}
+	return nil
+}
+
+// headerValue returns the first value of the i-th header in priority order. It is looked up by
+// its canonical key, sparing Header.Get from canonicalizing names such as CF-Connecting-IP on
+// every request.
+func (d *Detector) headerValue(r *http.Request, i int) string {
+	if values := r.Header[d.keys[i]]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// isTrusted reports whether ip is in one of the trusted proxy ranges
+func (d *Detector) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range d.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// peerHost returns the host part of the request's peer address, with IPv4-mapped addresses from
+// dual-stack listeners normalized to IPv4
+func peerHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return Normalize(host)
+}
+
+// ClientIP returns the client IP of the request and where it was found: the name of the header,
+// or SourceRemoteAddr for the peer address
+func (d *Detector) ClientIP(r *http.Request) (string, string) {
+	// Check headers in priority order
+	for i, header := range d.trustedHeaders(r) {
+		value := d.headerValue(r, i)
+		if value != "" {
+			if ip := d.candidate(value, IsValid); ip != "" {
+				d.debugf("Client IP %s detected via %s (peer %s)", ip, header, r.RemoteAddr)
+				return ip, header
+			}
+			d.debugf("Ignoring %s header without a valid IP: %q", header, value)
+		}
+	}
+
+	// Fall back to RemoteAddr
+	d.debugf("No usable proxy header, falling back to RemoteAddr %s", r.RemoteAddr)
+	return peerHost(r), SourceRemoteAddr
+}
+
+// IPv4 returns the client's IPv4 address from the trusted headers or the peer address, or ""
+// when there is none
+func (d *Detector) IPv4(r *http.Request) string {
+	return d.find(r, IsIPv4)
+}
+
+// IPv6 returns the client's IPv6 address from the trusted headers or the peer address, or ""
+// when there is none
+func (d *Detector) IPv6(r *http.Request) string {
+	return d.find(r, IsIPv6)
+}
+
+// find returns the first address accepted by match in the trusted headers, falling back to the
+// peer address
+func (d *Detector) find(r *http.Request, match func(string) bool) string {
+	for i := range d.trustedHeaders(r) {
+		if value := d.headerValue(r, i); value != "" {
+			if ip := d.candidate(value, match); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	if host := peerHost(r); match(host) {
+		return host
+	}
+	return ""
+}
+
+// Result holds the addresses Extract finds in a request: what ClientIP, IPv4, and IPv6 return
+type Result struct {
+	// ClientIP is the client IP and Source where it was found, as returned by ClientIP
+	ClientIP string
+	Source   string
+
+	// IPv4 and IPv6 are the client's addresses of each family, or "" when there is none
+	IPv4 string
+	IPv6 string
+}
+
+// Extract returns the results of ClientIP, IPv4, and IPv6 from a single pass over the trusted
+// headers, for callers that need more than one of them
+func (d *Detector) Extract(r *http.Request) Result {
+	var result Result
+	for i, header := range d.trustedHeaders(r) {
+		value := d.headerValue(r, i)
+		if value == "" {
+			continue
+		}
+
+		candidates := splitCandidates(value)
+		if result.ClientIP == "" {
+			if ip := d.choose(*candidates, IsValid); ip != "" {
+				d.debugf("Client IP %s detected via %s (peer %s)", ip, header, r.RemoteAddr)
+				result.ClientIP, result.Source = ip, header
+			} else {
+				d.debugf("Ignoring %s header without a valid IP: %q", header, value)
+			}
+		}
+		if result.IPv4 == "" {
+			result.IPv4 = d.choose(*candidates, IsIPv4)
+		}
+		if result.IPv6 == "" {
+			result.IPv6 = d.choose(*candidates, IsIPv6)
+		}
+		releaseCandidates(candidates)
+
+		if result.ClientIP != "" && result.IPv4 != "" && result.IPv6 != "" {
+			return result
+		}
+	}
+
+	host := peerHost(r)
+	if result.ClientIP == "" {
+		d.debugf("No usable proxy header, falling back to RemoteAddr %s", r.RemoteAddr)
+		result.ClientIP, result.Source = host, SourceRemoteAddr
+	}
+	if result.IPv4 == "" && IsIPv4(host) {
+		result.IPv4 = host
+	}
+	if result.IPv6 == "" && IsIPv6(host) {
+		result.IPv6 = host
+	}
+	return result
+}
+
+// ClientPort returns the source TCP port of a direct connection, or 0 when the client IP is taken
+// from a proxy header and the connection's port belongs to the proxy
+func (d *Detector) ClientPort(r *http.Request) int {
+	if _, source := d.ClientIP(r); source != SourceRemoteAddr {
+		return 0
+	}
+	return RemotePort(r)
+}
+
+// RemotePort returns the port of the request's peer address, or 0 when it has none
+func RemotePort(r *http.Request) int {
+	_, port, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return 0
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return 0
+	}
+	return n
+}
+
+// Candidate is a public address found in a request, with the header it was first found in or
+// SourceRemoteAddr
+type Candidate struct {
+	IP     string
+	Source string
+}
+
+// Candidates returns every distinct public IP in the trusted headers and RemoteAddr, in header
+// priority order, each annotated with the first source it was found in
+func (d *Detector) Candidates(r *http.Request) []Candidate {
+	var candidates []Candidate
+	seen := make(map[string]bool)
+	add := func(ip, source string) {
+		if !IsValid(ip) || IsPrivate(ip) || seen[ip] {
+			return
+		}
+		seen[ip] = true
+		candidates = append(candidates, Candidate{IP: ip, Source: source})
+	}
+
+	for i, header := range d.trustedHeaders(r) {
+		for value := d.headerValue(r, i); value != ""; {
+			var ip string
+			ip, value, _ = strings.Cut(value, ",")
+			add(Normalize(strings.TrimSpace(ip)), header)
+		}
+	}
+	add(peerHost(r), SourceRemoteAddr)
+	return candidates
+}
+
+// Decision explains how ClientIP chose the client IP of a request
+type Decision struct {
+	// ClientIP and Source are what ClientIP returns
+	ClientIP string
+	Source   string
+
+	// Rejected lists the headers consulted before Source whose value held no valid address
+	Rejected []string
+
+	// Untrusted is set when the request carried proxy headers that were ignored because its peer
+	// is not a trusted proxy
+	Untrusted bool
+}
+
+// Decide returns the client IP of the request as ClientIP does, along with the headers passed over
+// on the way to it
+func (d *Detector) Decide(r *http.Request) Decision {
+	var decision Decision
+	headers := d.trustedHeaders(r)
+	for i, header := range headers {
+		value := d.headerValue(r, i)
+		if value == "" {
+			continue
+		}
+		if ip := d.candidate(value, IsValid); ip != "" {
+			decision.ClientIP, decision.Source = ip, header
+			return decision
+		}
+		decision.Rejected = append(decision.Rejected, header)
+	}
+
+	if headers == nil {
+		for i := range d.headers {
+			if d.headerValue(r, i) != "" {
+				decision.Untrusted = true
+				break
+			}
+		}
+	}
+	decision.ClientIP, decision.Source = peerHost(r), SourceRemoteAddr
+	return decision
+}
+
+// Inconsistency describes why the client IP taken from a proxy header cannot be right, or returns
+// "" when it is plausible. A header naming an unspecified or multicast address is always wrong, and
+// one naming a private or bogon address is wrong when the peer itself is public, since a public
+// peer cannot have seen the client on a private network. Either usually points at a
+// misconfigured proxy chain.
+func (d *Detector) Inconsistency(r *http.Request) string {
+	clientIP, source := d.ClientIP(r)
+	if source == SourceRemoteAddr {
+		return ""
+	}
+
+	client, ok := parseAddr(clientIP)
+	if !ok {
+		return ""
+	}
+	if client.IsUnspecified() || client.IsMulticast() {
+		return fmt.Sprintf("%s reports %s, which cannot be a client address", source, clientIP)
+	}
+	if !isBogon(client) {
+		return ""
+	}
+
+	host := peerHost(r)
+	peer, ok := parseAddr(host)
+	if !ok || isBogon(peer) {
+		return ""
+	}
+	return fmt.Sprintf("%s reports non-public %s, but the request arrived from public %s", source, clientIP, host)
+}
@@ -0,0 +1,340 @@
+package ipdetect
+
+import (
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		remoteAddr string
+		expectedIP string
+		source     string
+	}{
+		{"RemoteAddr", nil, "198.51.100.7:1234", "198.51.100.7", SourceRemoteAddr},
+		{"Header priority", map[string]string{"X-Forwarded-For": "203.0.113.2", "CF-Connecting-IP": "203.0.113.1"}, "10.0.0.1:1234", "203.0.113.1", "CF-Connecting-IP"},
+		{"Leftmost forwarded address", map[string]string{"X-Forwarded-For": "unknown, 203.0.113.1, 10.0.0.1"}, "10.0.0.1:1234", "203.0.113.1", "X-Forwarded-For"},
+		{"Invalid header", map[string]string{"X-Real-IP": "garbage"}, "198.51.100.7:1234", "198.51.100.7", SourceRemoteAddr},
+		{"RemoteAddr without port", nil, "198.51.100.7", "198.51.100.7", SourceRemoteAddr},
+	}
+
+	d := New(Options{})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			for key, value := range test.headers {
+				req.Header.Set(key, value)
+			}
+			req.RemoteAddr = test.remoteAddr
+
+			ip, source := d.ClientIP(req)
+			if ip != test.expectedIP || source != test.source {
+				t.Errorf("Expected %s via %s, got %s via %s", test.expectedIP, test.source, ip, source)
+			}
+		})
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := New(Options{TrustedProxies: trusted})
+
+	tests := []struct {
+		remoteAddr string
+		expectedIP string
+	}{
+		{"10.1.2.3:1234", "203.0.113.1"},
+		{"198.51.100.7:1234", "198.51.100.7"},
+		{"@", "203.0.113.1"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Forwarded-For", "203.0.113.1")
+		req.RemoteAddr = test.remoteAddr
+
+		if ip, _ := d.ClientIP(req); ip != test.expectedIP {
+			t.Errorf("Peer %s: expected %s, got %s", test.remoteAddr, test.expectedIP, ip)
+		}
+	}
+}
+
+func TestHeaderPriority(t *testing.T) {
+	headers := []string{"X-Custom-IP"}
+	d := New(Options{HeaderPriority: headers})
+	headers[0] = "X-Changed"
+
+	if got := d.HeaderPriority(); !reflect.DeepEqual(got, []string{"X-Custom-IP"}) {
+		t.Errorf("Expected the detector to keep its own copy of the headers, got %v", got)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+	req.Header.Set("X-Custom-IP", "203.0.113.2")
+	if ip, source := d.ClientIP(req); ip != "203.0.113.2" || source != "X-Custom-IP" {
+		t.Errorf("Expected 203.0.113.2 via X-Custom-IP, got %s via %s", ip, source)
+	}
+
+	if got := New(Options{}).HeaderPriority(); !reflect.DeepEqual(got, DefaultHeaderPriority) {
+		t.Errorf("Expected the default header priority, got %v", got)
+	}
+}
+
+func TestIPv4IPv6(t *testing.T) {
+	d := New(Options{})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", "2001:db8::1, 203.0.113.1")
+	req.RemoteAddr = "[2001:db8::2]:1234"
+
+	if ip := d.IPv4(req); ip != "203.0.113.1" {
+		t.Errorf("Expected IPv4 203.0.113.1, got %q", ip)
+	}
+	if ip := d.IPv6(req); ip != "2001:db8::1" {
+		t.Errorf("Expected IPv6 2001:db8::1, got %q", ip)
+	}
+
+	req.Header.Del("X-Forwarded-For")
+	if ip := d.IPv4(req); ip != "" {
+		t.Errorf("Expected no IPv4, got %q", ip)
+	}
+	if ip := d.IPv6(req); ip != "2001:db8::2" {
+		t.Errorf("Expected IPv6 from RemoteAddr, got %q", ip)
+	}
+}
+
+// TestExtract checks that the single-pass Extract agrees with ClientIP, IPv4, and IPv6
+func TestExtract(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := []struct {
+		remoteAddr string
+		headers    map[string]string
+	}{
+		{"203.0.113.9:1234", nil},
+		{"[2001:db8::9]:1234", nil},
+		{"10.0.0.1:1234", map[string]string{"X-Forwarded-For": "2001:db8::1, 203.0.113.1"}},
+		{"10.0.0.1:1234", map[string]string{"CF-Connecting-IP": "bogus", "X-Real-IP": "203.0.113.2"}},
+		{"10.0.0.1:1234", map[string]string{"CF-Connecting-IP": "2001:db8::3", "X-Forwarded-For": "203.0.113.3, 10.0.0.2"}},
+		{"[2001:db8::9]:1234", map[string]string{"X-Forwarded-For": "203.0.113.4"}},
+		{"198.51.100.1:1234", map[string]string{"X-Forwarded-For": "203.0.113.5"}},
+	}
+
+	for _, strategy := range []Strategy{Leftmost, Rightmost, RightmostUntrusted} {
+		for _, proxies := range [][]*net.IPNet{nil, trusted} {
+			d := New(Options{TrustedProxies: proxies, Strategy: strategy})
+			for _, tt := range requests {
+				req := httptest.NewRequest("GET", "/", nil)
+				req.RemoteAddr = tt.remoteAddr
+				for name, value := range tt.headers {
+					req.Header.Set(name, value)
+				}
+
+				clientIP, source := d.ClientIP(req)
+				expected := Result{ClientIP: clientIP, Source: source, IPv4: d.IPv4(req), IPv6: d.IPv6(req)}
+				if got := d.Extract(req); got != expected {
+					t.Errorf("%v, trusted %v, %s %v: expected %+v, got %+v", strategy, proxies, tt.remoteAddr, tt.headers, expected, got)
+				}
+			}
+		}
+	}
+}
+
+// TestDualStack covers a listener on [::] accepting IPv4 clients, which reports them as
+// IPv4-mapped IPv6 addresses
+func TestDualStack(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := New(Options{TrustedProxies: trusted})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "[::ffff:203.0.113.1]:1234"
+	if ip, source := d.ClientIP(req); ip != "203.0.113.1" || source != SourceRemoteAddr {
+		t.Errorf("Expected 203.0.113.1 from RemoteAddr, got %q from %s", ip, source)
+	}
+	if ip := d.IPv4(req); ip != "203.0.113.1" {
+		t.Errorf("Expected IPv4 203.0.113.1, got %q", ip)
+	}
+	if ip := d.IPv6(req); ip != "" {
+		t.Errorf("Expected no IPv6 for a mapped address, got %q", ip)
+	}
+
+	// A proxy reached over the mapped address is still trusted, and mapped header values are
+	// normalized and deduplicated
+	req.RemoteAddr = "[::ffff:10.0.0.1]:1234"
+	req.Header.Set("X-Forwarded-For", "::ffff:198.51.100.7, 198.51.100.7")
+	if ip, source := d.ClientIP(req); ip != "198.51.100.7" || source != "X-Forwarded-For" {
+		t.Errorf("Expected 198.51.100.7 from X-Forwarded-For, got %q from %s", ip, source)
+	}
+	if ip := d.IPv6(req); ip != "" {
+		t.Errorf("Expected no IPv6, got %q", ip)
+	}
+	expected := []Candidate{{IP: "198.51.100.7", Source: "X-Forwarded-For"}}
+	if got := d.Candidates(req); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestClientPort(t *testing.T) {
+	d := New(Options{})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "198.51.100.7:54321"
+	if port := d.ClientPort(req); port != 54321 {
+		t.Errorf("Expected port 54321, got %d", port)
+	}
+
+	req.Header.Set("X-Real-IP", "203.0.113.1")
+	if port := d.ClientPort(req); port != 0 {
+		t.Errorf("Expected no port behind a proxy, got %d", port)
+	}
+
+	req.RemoteAddr = "@"
+	if port := RemotePort(req); port != 0 {
+		t.Errorf("Expected no port for a unix socket peer, got %d", port)
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	d := New(Options{})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+	req.Header.Set("X-Forwarded-For", "203.0.113.1, 10.0.0.1, 198.51.100.2")
+	req.RemoteAddr = "192.0.2.9:1234"
+
+	expected := []Candidate{
+		{IP: "203.0.113.1", Source: "CF-Connecting-IP"},
+		{IP: "198.51.100.2", Source: "X-Forwarded-For"},
+		{IP: "192.0.2.9", Source: SourceRemoteAddr},
+	}
+	if got := d.Candidates(req); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	if got := d.Candidates(req); got != nil {
+		t.Errorf("Expected no public candidates, got %v", got)
+	}
+}
+
+func TestDecide(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := New(Options{TrustedProxies: trusted})
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		remoteAddr string
+		expected   Decision
+	}{
+		{"Header", map[string]string{"X-Forwarded-For": "203.0.113.1"}, "10.0.0.1:1234",
+			Decision{ClientIP: "203.0.113.1", Source: "X-Forwarded-For"}},
+		{"Rejected headers", map[string]string{"CF-Connecting-IP": "garbage", "X-Real-IP": "unknown", "X-Forwarded-For": "203.0.113.1"}, "10.0.0.1:1234",
+			Decision{ClientIP: "203.0.113.1", Source: "X-Forwarded-For", Rejected: []string{"CF-Connecting-IP", "X-Real-IP"}}},
+		{"Every header rejected", map[string]string{"X-Real-IP": "garbage"}, "10.0.0.1:1234",
+			Decision{ClientIP: "10.0.0.1", Source: SourceRemoteAddr, Rejected: []string{"X-Real-IP"}}},
+		{"Untrusted peer", map[string]string{"X-Forwarded-For": "203.0.113.1"}, "198.51.100.7:1234",
+			Decision{ClientIP: "198.51.100.7", Source: SourceRemoteAddr, Untrusted: true}},
+		{"No headers", nil, "198.51.100.7:1234",
+			Decision{ClientIP: "198.51.100.7", Source: SourceRemoteAddr}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			for key, value := range test.headers {
+				req.Header.Set(key, value)
+			}
+			req.RemoteAddr = test.remoteAddr
+
+			decision := d.Decide(req)
+			if !reflect.DeepEqual(decision, test.expected) {
+				t.Errorf("Expected %+v, got %+v", test.expected, decision)
+			}
+			if ip, source := d.ClientIP(req); ip != decision.ClientIP || source != decision.Source {
+				t.Errorf("Expected ClientIP to agree, got %s via %s", ip, source)
+			}
+		})
+	}
+}
+
+func TestInconsistency(t *testing.T) {
+	d := New(Options{})
+
+	tests := []struct {
+		header       string
+		remoteAddr   string
+		inconsistent bool
+	}{
+		{"", "203.0.113.9:1234", false},
+		{"198.51.100.1", "203.0.113.9:1234", false},
+		{"192.168.1.5", "10.0.0.2:1234", false},
+		{"192.168.1.5", "203.0.113.9:1234", true},
+		{"100.64.1.1", "203.0.113.9:1234", true},
+		{"0.0.0.0", "10.0.0.2:1234", true},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if test.header != "" {
+			req.Header.Set("X-Forwarded-For", test.header)
+		}
+		req.RemoteAddr = test.remoteAddr
+
+		if reason := d.Inconsistency(req); (reason != "") != test.inconsistent {
+			t.Errorf("%s via %s: expected inconsistent=%t, got %q", test.header, test.remoteAddr, test.inconsistent, reason)
+		}
+	}
+}
+
+func TestStrategy(t *testing.T) {
+	for _, strategy := range []Strategy{Leftmost, RightmostUntrusted, Rightmost} {
+		parsed, err := ParseStrategy(strategy.String())
+		if err != nil || parsed != strategy {
+			t.Errorf("ParseStrategy(%q) = %v, %v", strategy.String(), parsed, err)
+		}
+	}
+
+	if parsed, err := ParseStrategy(""); err != nil || parsed != Leftmost {
+		t.Errorf("Expected empty strategy to mean leftmost, got %v %v", parsed, err)
+	}
+	if _, err := ParseStrategy("random"); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+	if s := Strategy(7).String(); s != "Strategy(7)" {
+		t.Errorf("Unexpected name for an unknown strategy: %s", s)
+	}
+}
+
+func TestDebugf(t *testing.T) {
+	var trace []string
+	d := New(Options{Debugf: func(format string, args ...any) {
+		trace = append(trace, fmt.Sprintf(format, args...))
+	}})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Real-IP", "203.0.113.1")
+	d.ClientIP(req)
+
+	if len(trace) != 1 || !strings.Contains(trace[0], "203.0.113.1 detected via X-Real-IP") {
+		t.Errorf("Unexpected trace %v", trace)
+	}
+}
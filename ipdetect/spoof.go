@@ -0,0 +1,127 @@
+package ipdetect
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+)
+
+// cloudflareHeaders are set by Cloudflare on every request it forwards
+var cloudflareHeaders = []string{"CF-Connecting-IP", "CF-Ray"}
+
+// Spoofing lists the ways the request's proxy headers contradict its peer address or each other
+// that no honest proxy chain produces, or returns nil when there are none:
+//
+//   - the Inconsistency of the detected client IP
+//   - a forwarding header naming the server's own address, which the server never forwards to
+//     itself
+//   - Cloudflare headers from a private peer outside TrustedProxies, while Cloudflare only
+//     connects from public addresses
+//   - CF-Connecting-IP and True-Client-IP naming different clients, while Cloudflare sets both
+//     to the same address
+//
+// Unlike ClientIP it reads headers from untrusted peers as well, since forged headers are what
+// it looks for.
+func (d *Detector) Spoofing(r *http.Request) []string {
+	var reasons []string
+	if reason := d.Inconsistency(r); reason != "" {
+		reasons = append(reasons, reason)
+	}
+
+	if local := localIP(r); local.IsValid() {
+		hops := append(forwardedHops(r.Header), forwardedForHops(r.Header)...)
+		for i, header := range d.headers {
+			if header == "Forwarded" || header == "X-Forwarded-For" {
+				continue
+			}
+			for _, value := range headerList(r.Header, d.keys[i]) {
+				hops = append(hops, Hop{IP: value, Source: header})
+			}
+		}
+		for _, hop := range hops {
+			if addr, ok := parseAddr(hostOnly(hop.IP)); ok && addr == local {
+				reasons = append(reasons, fmt.Sprintf("%s contains the server's own address %s", hop.Source, local))
+				break
+			}
+		}
+	}
+
+	if len(d.trusted) > 0 && r.RemoteAddr != unixPeer {
+		host := peerHost(r)
+		if peer, ok := parseAddr(host); ok && isBogon(peer) && !d.isTrusted(peer) {
+			for _, header := range cloudflareHeaders {
+				if r.Header.Get(header) != "" {
+					reasons = append(reasons, fmt.Sprintf("%s sent by %s, a private peer outside the trusted proxies", header, host))
+					break
+				}
+			}
+		}
+	}
+
+	// Canonical names, which Header.Get looks up without converting
+	connecting, connectingOK := parseAddr(r.Header.Get("Cf-Connecting-Ip"))
+	trueClient, trueClientOK := parseAddr(r.Header.Get("True-Client-Ip"))
+	if connectingOK && trueClientOK && connecting != trueClient {
+		reasons = append(reasons, fmt.Sprintf("CF-Connecting-IP %s and True-Client-IP %s name different clients", connecting, trueClient))
+	}
+	return reasons
+}
+
+// HeaderClient is the client address a proxy header names
+type HeaderClient struct {
+	Header string
+	IP     string
+}
+
+// HeaderClients returns the public client address each header in the priority list names, in
+// priority order. Each header's address is the one its strategy selects; headers naming a bogon or
+// nothing valid are left out. Like Spoofing it reads the headers of untrusted peers as well.
+func (d *Detector) HeaderClients(r *http.Request) []HeaderClient {
+	var clients []HeaderClient
+	for i, header := range d.headers {
+		value := d.headerValue(r, i)
+		if value == "" {
+			continue
+		}
+		ip := d.candidate(value, IsValid)
+		addr, ok := parseAddr(ip)
+		if !ok || isBogon(addr) {
+			continue
+		}
+		clients = append(clients, HeaderClient{Header: header, IP: ip})
+	}
+	return clients
+}
+
+// Conflicts returns HeaderClients when at least two of the headers name different addresses, or
+// nil when they agree; an honest proxy chain never names two different clients.
+func (d *Detector) Conflicts(r *http.Request) []HeaderClient {
+	clients := d.HeaderClients(r)
+	for _, client := range clients {
+		if client.IP != clients[0].IP {
+			return clients
+		}
+	}
+	return nil
+}
+
+// localIP returns the server address the request arrived on, or the zero Addr when it is unknown
+// or a loopback or unspecified address, which proxies on the same host legitimately forward from
+func localIP(r *http.Request) netip.Addr {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		return netip.Addr{}
+	}
+	var ip netip.Addr
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		ip = addr.AddrPort().Addr().Unmap().WithZone("")
+	default:
+		ip, _ = parseAddr(hostOnly(addr.String()))
+	}
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsUnspecified() {
+		return netip.Addr{}
+	}
+	return ip
+}
@@ -0,0 +1,127 @@
+package ipdetect
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSpoofing(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := New(Options{TrustedProxies: trusted})
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		remoteAddr string
+		local      net.Addr
+		reason     string
+	}{
+		{"plain request", nil, "203.0.113.9:1234", nil, ""},
+		{"honest proxy chain", map[string]string{"X-Forwarded-For": "203.0.113.9, 10.0.0.2"}, "10.0.0.1:1234",
+			&net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 443}, ""},
+		{"server address in X-Forwarded-For", map[string]string{"X-Forwarded-For": "203.0.113.9, 198.51.100.1"}, "10.0.0.1:1234",
+			&net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 443}, "X-Forwarded-For contains the server's own address 198.51.100.1"},
+		{"server address in Forwarded", map[string]string{"Forwarded": `for="[2001:db8::1]:4711"`}, "203.0.113.9:1234",
+			&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443}, "Forwarded contains the server's own address 2001:db8::1"},
+		{"loopback server address", map[string]string{"X-Forwarded-For": "203.0.113.9, 127.0.0.1"}, "10.0.0.1:1234",
+			&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080}, ""},
+		{"Cloudflare headers from an untrusted private peer", map[string]string{"CF-Connecting-IP": "203.0.113.9"}, "192.168.1.5:1234", nil,
+			"CF-Connecting-IP sent by 192.168.1.5, a private peer outside the trusted proxies"},
+		{"Cloudflare headers from a trusted proxy", map[string]string{"CF-Ray": "8a1b2c3d4e5f6789-SIN"}, "10.0.0.1:1234", nil, ""},
+		{"Cloudflare client headers disagree", map[string]string{"CF-Connecting-IP": "203.0.113.9", "True-Client-IP": "198.51.100.7"}, "10.0.0.1:1234", nil,
+			"CF-Connecting-IP 203.0.113.9 and True-Client-IP 198.51.100.7 name different clients"},
+		{"Cloudflare client headers agree", map[string]string{"CF-Connecting-IP": "203.0.113.9", "True-Client-IP": "203.0.113.9"}, "10.0.0.1:1234", nil, ""},
+		{"private client over a Unix socket", map[string]string{"X-Forwarded-For": "192.168.1.5"}, "@", nil, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			for key, value := range test.headers {
+				req.Header.Set(key, value)
+			}
+			req.RemoteAddr = test.remoteAddr
+			if test.local != nil {
+				req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, test.local))
+			}
+
+			reasons := d.Spoofing(req)
+			if test.reason == "" {
+				if reasons != nil {
+					t.Errorf("Expected no reasons, got %q", reasons)
+				}
+				return
+			}
+			if len(reasons) != 1 || reasons[0] != test.reason {
+				t.Errorf("Expected %q, got %q", test.reason, reasons)
+			}
+		})
+	}
+}
+
+func TestSpoofingIncludesInconsistency(t *testing.T) {
+	d := New(Options{})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", "192.168.1.5")
+	req.RemoteAddr = "203.0.113.9:1234"
+
+	reasons := d.Spoofing(req)
+	if len(reasons) != 1 || !strings.Contains(reasons[0], "non-public 192.168.1.5") {
+		t.Errorf("Expected the inconsistency, got %q", reasons)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := New(Options{TrustedProxies: trusted})
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		remoteAddr string
+		expected   []HeaderClient
+	}{
+		{"no headers", nil, "203.0.113.9:1234", nil},
+		{"single header", map[string]string{"X-Forwarded-For": "203.0.113.9, 198.51.100.7"}, "10.0.0.1:1234", nil},
+		{"headers agree", map[string]string{"CF-Connecting-IP": "203.0.113.9", "X-Forwarded-For": "203.0.113.9, 10.0.0.2"}, "10.0.0.1:1234", nil},
+		{"private address ignored", map[string]string{"X-Real-IP": "192.168.1.5", "X-Forwarded-For": "203.0.113.9"}, "10.0.0.1:1234", nil},
+		{"headers disagree", map[string]string{"CF-Connecting-IP": "203.0.113.9", "X-Real-IP": "203.0.113.9", "X-Forwarded-For": "198.51.100.7"}, "10.0.0.1:1234",
+			[]HeaderClient{{"CF-Connecting-IP", "203.0.113.9"}, {"X-Real-IP", "203.0.113.9"}, {"X-Forwarded-For", "198.51.100.7"}}},
+		{"untrusted peer", map[string]string{"X-Real-IP": "203.0.113.9", "X-Forwarded-For": "198.51.100.7"}, "198.51.100.20:1234",
+			[]HeaderClient{{"X-Real-IP", "203.0.113.9"}, {"X-Forwarded-For", "198.51.100.7"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			for key, value := range test.headers {
+				req.Header.Set(key, value)
+			}
+			req.RemoteAddr = test.remoteAddr
+
+			if conflicts := d.Conflicts(req); !reflect.DeepEqual(conflicts, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, conflicts)
+			}
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.9")
+	req.Header.Set("X-Real-IP", "192.168.1.5")
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 10.0.0.2")
+	expected := []HeaderClient{{"CF-Connecting-IP", "203.0.113.9"}, {"X-Forwarded-For", "203.0.113.9"}}
+	if clients := d.HeaderClients(req); !reflect.DeepEqual(clients, expected) {
+		t.Errorf("Expected header clients %v, got %v", expected, clients)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"myip/internal/config"
+	"myip/internal/listener"
+	"myip/internal/logging"
+	"myip/internal/models"
+)
+
+// Route sets, the groups of endpoints a listener can serve
+const (
+	// routesIP holds the IP detection and lookup endpoints, /ping, and /.well-known/
+	routesIP = "ip"
+	// routesDocs holds /docs, /routes, /openapi.json, and the Swagger UI
+	routesDocs = "docs"
+	// routesHealth holds /health, /livez, /readyz, /version, and /slo
+	routesHealth = "health"
+	// routesMetrics holds /metrics and /stats
+	routesMetrics = "metrics"
+	// routesAdmin holds the /admin/ endpoints and the /events stream
+	routesAdmin = "admin"
+	// routesDebug holds the /debug/pprof/ profiling endpoints enabled by PPROF_ENABLED and the
+	// /debug/requests capture enabled by REQUEST_CAPTURE_SIZE
+	routesDebug = "debug"
+)
+
+// allRouteSets lists every route set, served by the main listener unless ROUTES says otherwise
+var allRouteSets = []string{routesIP, routesDocs, routesHealth, routesMetrics, routesAdmin, routesDebug}
+
+// routeSet is the set of route groups served by a listener
+type routeSet map[string]bool
+
+// parseRouteSet parses route sets joined by "+", such as "health+metrics", or "all"
+func parseRouteSet(value string) (routeSet, error) {
+	sets := make(routeSet, len(allRouteSets))
+	for _, name := range strings.Split(value, "+") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == "all":
+			for _, set := range allRouteSets {
+				sets[set] = true
+			}
+		case slices.Contains(allRouteSets, name):
+			sets[name] = true
+		default:
+			return nil, fmt.Errorf("unknown route set %q (valid: all, %s)", name, strings.Join(allRouteSets, ", "))
+		}
+	}
+	return sets, nil
+}
+
+// extraListener is an additional TCP listener from LISTENERS serving its own route sets
+type extraListener struct {
+	addr   string
+	routes routeSet
+}
+
+// parseListeners parses LISTENERS entries of the form "addr=sets", such as
+// "127.0.0.1:9090=health+metrics+admin"
+func parseListeners(entries []string) ([]extraListener, error) {
+	listeners := make([]extraListener, 0, len(entries))
+	for _, entry := range entries {
+		addr, sets, ok := strings.Cut(entry, "=")
+		addr = strings.TrimSpace(addr)
+		if !ok || addr == "" {
+			return nil, fmt.Errorf("invalid listener %q, expected addr=sets", entry)
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("invalid listener address %q: %v", addr, err)
+		}
+		routes, err := parseRouteSet(sets)
+		if err != nil {
+			return nil, fmt.Errorf("listener %s: %v", addr, err)
+		}
+		listeners = append(listeners, extraListener{addr: addr, routes: routes})
+	}
+	return listeners, nil
+}
+
+// startListeners opens the LISTENERS sockets and serves each on its own server and mux, returning
+// the servers for shutdown. PROXY protocol is not read on them: they are meant for internal
+// clients such as monitoring, which connect directly.
+func startListeners(ctx context.Context, cfg *config.Config, svc *services) ([]*http.Server, error) {
+	plain := *cfg
+	plain.ProxyProtocol = listener.ProxyOff
+
+	servers := make([]*http.Server, 0, len(svc.listeners))
+	for _, extra := range svc.listeners {
+		mux := http.NewServeMux()
+		registerRoutes(mux, cfg, svc, extra.routes)
+		server := newServer(cfg, extra.addr, mux)
+
+		listeners, err := listener.Listen(ctx, "tcp", extra.addr, 1)
+		if err != nil {
+			for _, started := range servers {
+				started.Close()
+			}
+			return nil, err
+		}
+		logging.Infof("Serving %s on %s", strings.Join(extra.routes.names(), "+"), extra.addr)
+
+		go func() {
+			if err := serve(server, &plain, listeners); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logging.Errorf("Listener %s failed: %v", extra.addr, err)
+			}
+		}()
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
+
+// names returns the route sets in s in their documented order
+func (s routeSet) names() []string {
+	var names []string
+	for _, set := range allRouteSets {
+		if s[set] {
+			names = append(names, set)
+		}
+	}
+	return names
+}
+
+// listenerTransports describes the LISTENERS sockets for the boot report
+func listenerTransports(cfg *config.Config, listeners []extraListener) []models.BootTransport {
+	protocol := "http"
+	if cfg.TLSEnabled() {
+		protocol = "https"
+	}
+	transports := make([]models.BootTransport, 0, len(listeners))
+	for _, extra := range listeners {
+		transports = append(transports, models.BootTransport{Protocol: protocol, Address: extra.addr})
+	}
+	return transports
+}
@@ -1,66 +1,1021 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	httpSwagger "github.com/swaggo/http-swagger/v2"
-	"myip/docs"
+	"myip/internal/bootreport"
+	"myip/internal/breaker"
+	"myip/internal/cache"
+	"myip/internal/capture"
+	"myip/internal/cdn"
+	"myip/internal/cluster"
 	"myip/internal/config"
+	"myip/internal/connectivity"
+	"myip/internal/crawl"
+	"myip/internal/events"
+	"myip/internal/features"
+	"myip/internal/geo"
+	"myip/internal/guide"
 	"myip/internal/handlers"
+	"myip/internal/ip"
+	"myip/internal/listener"
+	"myip/internal/logging"
+	"myip/internal/maintenance"
+	"myip/internal/middleware"
+	"myip/internal/models"
+	"myip/internal/outbound"
+	"myip/internal/privacy"
+	"myip/internal/proxyprofile"
+	"myip/internal/quota"
+	"myip/internal/ratelimit"
+	"myip/internal/requestid"
+	"myip/internal/respcache"
+	"myip/internal/router"
+	"myip/internal/scanner"
+	"myip/internal/scheduler"
+	"myip/internal/slo"
+	"myip/internal/stats"
+	"myip/internal/wellknown"
+
+	"github.com/akhfa/myip/ipdetect"
+)
+
+// Build information, set via -ldflags at release time
+var (
+	version = "dev"
+	commit  = "unknown"
+	date    = "unknown"
 )
 
-// @title MyIP API
-// @version 1.0
-// @description A lightweight, high-performance HTTP service for detecting client IP addresses with comprehensive proxy header support.
-// @termsOfService http://swagger.io/terms/
+// services holds the stateful components shared by the HTTP endpoints
+type services struct {
+	mode       *maintenance.Mode
+	boot       *bootreport.Store
+	slo        *slo.Tracker
+	dnsLimiter *ratelimit.Limiter
+	inFlight   *ratelimit.Concurrency
+	quotas     *quota.Quotas
+	cache      cache.Store
+	stats      *stats.Counter
+	captured   *capture.Buffer
+	events     *events.Hub
+	cluster    *cluster.Node
+	scanner    *scanner.Detector
+	budgets    middleware.Budgets
+	routes     routeSet
+	listeners  []extraListener
+	wellKnown  *wellknown.Handler
+	robots     []byte
+	profile    *profileServices
+	jobs       *scheduler.Scheduler
+
+	// proxyRanges holds the trusted ranges of the proxy profiles
+	proxyRanges *proxyprofile.Ranges
+
+	// applied is the configuration last applied by applyRuntimeConfig, served by /admin/config
+	applied atomic.Pointer[config.Config]
+}
 
-// @contact.name API Support
-// @contact.url http://www.swagger.io/support
-// @contact.email support@swagger.io
+// proxyRangesTimeout bounds each download of published proxy ranges
+const proxyRangesTimeout = 10 * time.Second
 
-// @license.name MIT
-// @license.url https://opensource.org/licenses/MIT
+func init() {
+	features.Register("ip", "maintenance", "slo", "stats", "scanner", "admin", "config-reload", "cluster", "quota", "events", "docs")
+}
+
+// newServices builds the stateful components from the configuration
+func newServices(cfg *config.Config) (*services, error) {
+	mode, err := maintenance.New(cfg.MaintenanceMode, cfg.MaintenanceMessage, cfg.MaintenanceRetryAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := geo.CheckEmbedded(); err != nil {
+		return nil, err
+	}
+
+	profile, err := newProfileServices(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.ResponseCacheEntries > 0 {
+		handlers.SetResponseCache(respcache.New(cfg.ResponseCacheEntries, time.Minute))
+	} else {
+		handlers.SetResponseCache(nil)
+	}
+	handlers.SetPlainText(handlers.PlainText{Newline: cfg.PlainTextNewline, Charset: cfg.PlainTextCharset})
+	handlers.SetDefaultLocale(cfg.DefaultLocale)
+
+	named, err := handlers.LoadTemplates(cfg.TemplateDir)
+	if err != nil {
+		return nil, err
+	}
+	handlers.SetTemplates(handlers.Templates{Inline: cfg.TemplateInline, Named: named})
+
+	store, err := newCache(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	budgets, err := middleware.ParseBudgets(cfg.RequestTimeout, cfg.RequestTimeouts)
+	if err != nil {
+		return nil, err
+	}
 
-// @host localhost:8080
-// @BasePath /
+	routes, err := parseRouteSet(cfg.Routes)
+	if err != nil {
+		return nil, err
+	}
+	listeners, err := parseListeners(cfg.Listeners)
+	if err != nil {
+		return nil, err
+	}
+
+	robots, err := crawl.LoadRobots(cfg.RobotsTxtFile)
+	if err != nil {
+		return nil, err
+	}
+
+	svc := &services{
+		mode:        mode,
+		boot:        bootreport.NewStore(),
+		slo:         slo.NewTracker(cfg.SLOAvailabilityTarget, cfg.SLOLatencyTarget),
+		dnsLimiter:  ratelimit.New(cfg.DNSRateLimit, time.Minute),
+		inFlight:    ratelimit.NewConcurrency(cfg.MaxInFlight, cfg.MaxInFlightPerIP),
+		quotas:      quota.New(store),
+		events:      events.New(events.Lookup(profile.networkLookup())),
+		scanner:     scanner.New(cfg.ScannerBanThreshold, cfg.ScannerBanWindow, cfg.ScannerBanDuration),
+		budgets:     budgets,
+		routes:      routes,
+		listeners:   listeners,
+		robots:      robots,
+		cache:       store,
+		profile:     profile,
+		jobs:        scheduler.New(),
+		proxyRanges: proxyprofile.NewRanges(outbound.NewHTTPClient(cfg.OutboundIPPreference, proxyRangesTimeout)),
+	}
+	svc.dnsLimiter.SetAlgorithm(cfg.DNSRateLimitAlgorithm)
+	svc.dnsLimiter.Share(store, "dns")
+	handlers.SetRequestCounter(svc.slo)
+	svc.profile.reportProxyRanges(svc.proxyRanges.Status)
+	if cfg.WellKnownDir != "" || cfg.ACMEChallenges {
+		var challenges cache.Store
+		if cfg.ACMEChallenges {
+			challenges = store
+		}
+		if svc.wellKnown, err = wellknown.New(cfg.WellKnownDir, challenges); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.StatsWindow > 0 {
+		svc.stats = stats.New(cfg.StatsWindow, profile.networkLookup())
+	}
+	if cfg.RequestCaptureSize > 0 {
+		svc.captured = capture.New(cfg.RequestCaptureSize)
+	}
+	if cfg.ClusterEnabled {
+		if svc.cluster, err = newClusterNode(cfg, svc); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyRuntimeConfig(cfg, svc); err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
+
+// newClusterNode builds the node's cluster membership, sharing its request statistics and
+// reloading its configuration when another node asks
+func newClusterNode(cfg *config.Config, svc *services) (*cluster.Node, error) {
+	id := cfg.ClusterNodeID
+	if id == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("CLUSTER_NODE_ID is unset and the hostname is unavailable: %w", err)
+		}
+		id = hostname
+	}
+
+	opts := cluster.Options{
+		ID:          id,
+		URL:         cfg.ClusterAdvertiseURL,
+		Version:     version,
+		Interval:    cfg.ClusterHeartbeat,
+		Maintenance: svc.mode.Enabled,
+		Reload:      func() { reloadConfig(svc) },
+	}
+	if svc.stats != nil {
+		opts.Stats = svc.stats.Snapshot
+	}
+	node := cluster.New(svc.cache, opts)
+	if svc.stats != nil {
+		svc.stats.SetPeers(node.PeerStats)
+	}
+	return node, nil
+}
+
+// newCache builds the lookup cache selected by CACHE_BACKEND
+func newCache(cfg *config.Config) (cache.Store, error) {
+	if cfg.CacheBackend == cache.BackendRedis {
+		return cache.NewRedis(cfg.RedisURL, cfg.CachePrefix)
+	}
+	return cache.NewMemory(cache.DefaultMaxEntries), nil
+}
+
+// applyRuntimeConfig applies the hot-reloadable settings to the running components.
+// Nothing is changed unless every setting is valid.
+func applyRuntimeConfig(cfg *config.Config, svc *services) error {
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+
+	// The proxy profiles' ranges are trusted along with TRUSTED_PROXIES
+	if _, err := proxyprofile.Lookup(cfg.ProxyProfiles); err != nil {
+		return err
+	}
+	trustedProxies, err := ip.ParseCIDRs(append(slices.Clone(cfg.TrustedProxies), svc.proxyRanges.For(cfg.ProxyProfiles)...))
+	if err != nil {
+		return err
+	}
+
+	strategy, err := ip.ParseStrategy(cfg.XFFStrategy)
+	if err != nil {
+		return err
+	}
+	// Without trusted proxies every address counts as untrusted, silently taking the rightmost
+	if strategy == ipdetect.RightmostUntrusted && len(trustedProxies) == 0 {
+		return errors.New("XFF_STRATEGY=rightmost-untrusted requires TRUSTED_PROXIES")
+	}
+
+	nat64Prefixes, err := ip.ParseNAT64Prefixes(cfg.NAT64Prefixes)
+	if err != nil {
+		return err
+	}
+
+	var shadow *ip.ShadowSettings
+	if len(cfg.ShadowHeaderPriority) > 0 || cfg.ShadowXFFStrategy != "" {
+		shadow = &ip.ShadowSettings{HeaderPriority: cfg.ShadowHeaderPriority, Strategy: strategy}
+		if cfg.ShadowXFFStrategy != "" {
+			if shadow.Strategy, err = ip.ParseStrategy(cfg.ShadowXFFStrategy); err != nil {
+				return err
+			}
+		}
+		if shadow.Strategy == ipdetect.RightmostUntrusted && len(trustedProxies) == 0 {
+			return errors.New("SHADOW_XFF_STRATEGY=rightmost-untrusted requires TRUSTED_PROXIES")
+		}
+	}
+
+	quotas, err := quota.Parse(cfg.APIKeys, cfg.QuotaTiers)
+	if err != nil {
+		return err
+	}
+
+	responseHeader := cfg.ClientIPResponseHeader
+	if responseHeader == "off" {
+		responseHeader = ""
+	}
+
+	if err := logging.SetDebugModules(cfg.LogDebugModules); err != nil {
+		return err
+	}
+
+	logging.SetLevel(level)
+	ip.Configure(ip.Settings{
+		HeaderPriority:   cfg.HeaderPriority,
+		TrustedProxies:   trustedProxies,
+		Strategy:         strategy,
+		StrictValidation: cfg.StrictValidation,
+		NAT64Prefixes:    nat64Prefixes,
+		ResponseHeader:   responseHeader,
+		Shadow:           shadow,
+	})
+	privacy.Configure(cfg.PrivacyMode, cfg.PrivacyOmitUserAgent)
+	breaker.Configure(breaker.Settings{
+		Threshold:  cfg.BreakerThreshold,
+		Backoff:    cfg.BreakerBackoff,
+		MaxBackoff: cfg.BreakerMaxBackoff,
+	})
+	svc.dnsLimiter.SetLimit(cfg.DNSRateLimit)
+	svc.inFlight.SetLimits(cfg.MaxInFlight, cfg.MaxInFlightPerIP)
+	svc.quotas.Configure(quotas)
+	if svc.cluster != nil {
+		svc.cluster.SetConfigHash(cfg.Hash())
+	}
+	handlers.SetAbout(models.AboutInfo{
+		Name:             cfg.InstanceName,
+		ContactEmail:     cfg.ContactEmail,
+		PrivacyPolicyURL: cfg.PrivacyPolicyURL,
+		RateLimits: models.RateLimits{
+			DNSLookupsPerMinute: max(cfg.DNSRateLimit, 0),
+			MaxInFlight:         cfg.MaxInFlight,
+			MaxInFlightPerIP:    cfg.MaxInFlightPerIP,
+		},
+	})
+	svc.applied.Store(cfg)
+	return nil
+}
+
+// reloadConfig re-reads the configuration and applies the reloadable settings,
+// keeping the current settings if the new configuration is invalid
+func reloadConfig(svc *services) {
+	cfg, err := config.Read()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		logging.Errorf("Configuration reload failed: %v", err)
+		return
+	}
+
+	if err := applyRuntimeConfig(cfg, svc); err != nil {
+		logging.Errorf("Configuration reload rejected: %v", err)
+		return
+	}
+
+	logging.Infof("Configuration reloaded (config hash %s)", cfg.Hash())
+}
+
+// watchReload reloads the configuration on SIGHUP and, when a config file is set, whenever it changes
+func watchReload(ctx context.Context, cfg *config.Config, svc *services) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	if cfg.ConfigFile != "" {
+		go config.Watch(ctx, cfg.ConfigFile, cfg.ConfigWatchInterval, func() {
+			logging.Infof("Config file %s changed, reloading", cfg.ConfigFile)
+			reloadConfig(svc)
+		})
+	}
+
+	go func() {
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				logging.Infof("Received SIGHUP, reloading configuration")
+				reloadConfig(svc)
+			}
+		}
+	}()
+}
+
+// refreshProxyRanges fetches the published ranges of the proxy profiles before serving, applying
+// them, and schedules their refresh every PROXY_RANGES_REFRESH, reloading the configuration when
+// they change. The bundled ranges stay in use while the published ones cannot be fetched.
+func refreshProxyRanges(ctx context.Context, cfg *config.Config, svc *services) {
+	if len(cfg.ProxyProfiles) == 0 || cfg.ProxyRangesRefresh <= 0 {
+		return
+	}
+
+	if changed, err := svc.proxyRanges.Refresh(ctx, cfg.ProxyProfiles); err != nil {
+		logging.Errorf("Proxy ranges incomplete at startup: %v", err)
+	} else if changed {
+		if err := applyRuntimeConfig(cfg, svc); err != nil {
+			logging.Errorf("Proxy ranges not applied: %v", err)
+		}
+	}
 
-func setupRoutes() {
-	http.HandleFunc("/", handlers.IPv4Handler)
-	http.HandleFunc("/ipv6", handlers.IPv6Handler)
-	http.HandleFunc("/info", handlers.InfoHandler)
-	http.HandleFunc("/json", handlers.JSONHandler)
-	http.HandleFunc("/headers", handlers.HeadersHandler)
-	http.HandleFunc("/health", handlers.HealthHandler)
-	http.Handle("/swagger/", httpSwagger.WrapHandler)
+	svc.jobs.Add(scheduler.Task{
+		Name:     "proxy-ranges",
+		Interval: cfg.ProxyRangesRefresh,
+		Run: func(ctx context.Context) error {
+			changed, err := svc.proxyRanges.Refresh(ctx, cfg.ProxyProfiles)
+			if changed {
+				logging.Infof("Proxy ranges changed, reloading")
+				reloadConfig(svc)
+			}
+			return err
+		},
+	})
 }
 
-func createServer(cfg *config.Config) *http.Server {
-	return &http.Server{
-		Addr:              cfg.GetAddr(),
-		Handler:           nil, // Use default ServeMux
+// setupRoutes registers the endpoints of the main listener on mux and returns the registered routes
+func setupRoutes(mux *http.ServeMux, cfg *config.Config, svc *services) []string {
+	return registerRoutes(mux, cfg, svc, svc.routes)
+}
+
+// registerRoutes registers the endpoints in sets on mux and returns the registered routes. Each
+// listener has its own mux, so its /routes, /openapi.json, and 404 responses list only the
+// endpoints it serves.
+func registerRoutes(mux *http.ServeMux, cfg *config.Config, svc *services, sets routeSet) []string {
+	r := router.New(mux)
+	r.Restrict(cfg.EnabledEndpoints, cfg.DisabledEndpoints)
+
+	// Middleware shared by every route, outermost first: the request and CDN IDs are assigned
+	// before the access log and event stream so they can report them, and panics are recovered
+	// inside both so the resulting 500 is reported. Banned scanners are refused before anything else runs;
+	// every other response, errors included, carries the client IP header.
+	r.Use(
+		requestid.Middleware,
+		cdn.Middleware,
+		middleware.AccessLog,
+		svc.events.Middleware,
+		middleware.Recover,
+		svc.scanner.Middleware,
+		ip.ResponseHeaderMiddleware,
+		func(next http.Handler) http.Handler {
+			return middleware.LimitBody(cfg.MaxBodyBytes, next)
+		},
+	)
+
+	if sets[routesIP] {
+		// Service endpoints apply the concurrency limits, API key quotas, maintenance mode, SLO
+		// tracking, and request budgets. Maintenance wraps SLO tracking so planned downtime does not
+		// burn the error budget; the concurrency limits and quotas sit outside both so rejected
+		// floods never reach the handlers, and timeouts sit inside so they count against the SLOs.
+		service := r.Group("", svc.inFlight.Middleware, svc.quotas.Middleware, svc.mode.Middleware, svc.slo.Middleware,
+			func(next http.Handler) http.Handler {
+				return middleware.Timeout(svc.budgets, next)
+			}).
+			Returns(http.StatusUnauthorized, "Unknown X-API-Key", mediaProblem, models.Problem{}).
+			Returns(http.StatusTooManyRequests, "Too many requests in flight for the client IP, or API key quota exceeded", mediaProblem, models.Problem{}).
+			Returns(http.StatusServiceUnavailable, "Maintenance mode, or too many requests in flight", mediaProblem, models.Problem{}).
+			Returns(http.StatusGatewayTimeout, "Request exceeded its REQUEST_TIMEOUT budget", mediaProblem, models.Problem{})
+		svc.profile.registerServiceRoutes(service, cfg, svc)
+		service.Get("/ping", handlers.PingHandler).
+			Describe("Receive timestamp for latency and coarse bandwidth measurement").
+			Example("?t=1700000000000", "?chunks=10&chunk_size=65536").
+			Query(router.Param{Name: "t", Type: "integer", Description: "Client send time in Unix milliseconds, echoed with a one-way latency estimate"},
+				router.Param{Name: "chunks", Type: "integer", Description: "Stream this many flushed chunks instead of the JSON response (1-100)"},
+				router.Param{Name: "chunk_size", Type: "integer", Description: "Size of each chunk in bytes (1-65536, default: 1024)"}).
+			Returns(http.StatusOK, "Receive time, or the requested chunks", mediaJSON, models.PingResponse{}).
+			Returns(http.StatusOK, "Receive time, or the requested chunks", "application/octet-stream", "").
+			Returns(http.StatusBadRequest, "Invalid parameter", mediaText, "")
+		service.Get("/time", handlers.TimeHandler).
+			Describe("Server time in several formats, matching the Date header, with the skew of the client clock").
+			Example("?t=1700000000", "?t=2024-01-01T12:00:00Z").
+			Query(router.Param{Name: "t", Description: "Client time as Unix seconds, Unix milliseconds, or RFC 3339, compared with the server's"}).
+			Returns(http.StatusOK, "Server time", mediaJSON, models.TimeResponse{}).
+			Returns(http.StatusBadRequest, "Invalid client time", mediaText, "")
+		service.Get("/connectivity", connectivity.Handler(cfg.ConnectivityIPv4URL, cfg.ConnectivityIPv6URL)).
+			Describe("IPv4 and IPv6 connectivity test run by the browser against the CONNECTIVITY_IPV4_URL and CONNECTIVITY_IPV6_URL hostnames, or its test plan").
+			Returns(http.StatusOK, "Connectivity test page", mediaHTML, "").
+			Returns(http.StatusOK, "Connectivity test plan", mediaJSON, models.ConnectivityPlan{})
+
+		// /geo needs the ASN database or the country table compiled in with the geodata tag
+		var geoLookup geo.Lookup
+		if cfg.IPASNDB != "" || cfg.MaxMindLicenseKey != "" {
+			geoLookup = geo.Lookup(svc.profile.networkLookup())
+		}
+		if geoLookup != nil || geo.Embedded() {
+			service.Get("/geo", geo.Handler(geoLookup)).
+				Describe("Country of the client IP from the ASN database, or from the embedded country table").
+				Returns(http.StatusOK, "Country of the client IP", mediaJSON, models.GeoResponse{}).
+				Returns(http.StatusBadRequest, "Client IP is private or invalid", mediaText, "").
+				Returns(http.StatusNotFound, "No country data for the address", mediaText, "")
+		}
+
+		// IP detection endpoints, counted in the request statistics
+		detect := service.Group("", ip.StrictMiddleware, ip.ShadowMiddleware, ip.DetectionMetricsMiddleware, ip.AnomalyMiddleware).
+			Returns(http.StatusBadRequest, "Inconsistent client address with STRICT_VALIDATION=reject", mediaText, "")
+		if svc.stats != nil {
+			detect.Use(svc.stats.Middleware)
+		}
+		if svc.captured != nil {
+			detect.Use(svc.captured.Middleware)
+		}
+		if cfg.DelayEnabled {
+			detect.Use(func(next http.Handler) http.Handler {
+				return middleware.Delay(cfg.DelayMax, next)
+			})
+		}
+		// Only the bare addresses depend on nothing but the client IP headers a cache varies on, so
+		// the endpoints echoing headers, ports, or timestamps are never stored with EDGE_CACHE=vary
+		addresses := detect
+		if cfg.EdgeCache != middleware.EdgeCacheOff {
+			edgeCache := func(mode string) router.Middleware {
+				return func(next http.Handler) http.Handler {
+					return middleware.EdgeCache(mode, cfg.EdgeCacheMaxAge, func() []string {
+						return ip.CurrentSettings().HeaderPriority
+					}, next)
+				}
+			}
+			addresses = detect.With(edgeCache(cfg.EdgeCache))
+			detect = detect.With(edgeCache(middleware.EdgeCacheNoStore))
+		}
+		withFormats(addresses.Get("/", handlers.IPv4Handler), "IPv4 address", ipBody, "No IPv4 address found").
+			Describe("IPv4 address").
+			Example("?format=json", "?format=jsonp&callback=getip", "?verbose=1").
+			Query(newlineParam, verboseParam)
+		withFormats(addresses.Get("/ipv6", handlers.IPv6Handler), "IPv6 address", ipBody, "No IPv6 address found").
+			Describe("IPv6 address").
+			Example("?format=json", "?format=jsonp&callback=getip", "?compress=false", "?verbose=1").
+			Query(newlineParam, verboseParam,
+				router.Param{Name: "compress", Type: "boolean", Description: "Set to false for the fully expanded address (default: true)"})
+		detect.Get("/ipv6/expand", handlers.IPv6ExpandHandler).
+			Describe("Compressed and fully expanded IPv6 address with its /64 prefix").
+			Returns(http.StatusOK, "IPv6 address forms", mediaJSON, models.IPv6Forms{}).
+			Returns(http.StatusNotFound, "No IPv6 address found", mediaJSON, models.ErrorResponse{})
+		detect.Get("/ipv6/analyze", handlers.IPv6AnalyzeHandler).
+			Describe("Interface identifier of the IPv6 address: EUI-64 with the embedded MAC and its vendor, or a privacy address").
+			Returns(http.StatusOK, "IPv6 interface identifier analysis", mediaJSON, models.IPv6Analysis{}).
+			Returns(http.StatusNotFound, "No IPv6 address found", mediaJSON, models.ErrorResponse{})
+		detect.Get("/nat64", handlers.NAT64Handler).
+			Describe("Whether an IPv6 address is in a NAT64 prefix, and the IPv4 address it maps to").
+			Example("?ip=64:ff9b::c000:2aa").
+			Query(router.Param{Name: "ip", Description: "IPv6 address to check, such as one synthesized by DNS64 for ipv4only.arpa (default: the client's IPv6 address)"}).
+			Returns(http.StatusOK, "NAT64 mapping", mediaJSON, models.NAT64Info{}).
+			Returns(http.StatusBadRequest, "Invalid ip parameter", mediaJSON, models.ErrorResponse{}).
+			Returns(http.StatusNotFound, "No IPv6 address found", mediaJSON, models.ErrorResponse{})
+		addresses.Get("/both", handlers.BothHandler).Describe("IPv4 and IPv6 addresses in one response").
+			Example("?format=jsonp&callback=getip").
+			Query(router.Param{Name: "format", Description: "Response format, JSON when omitted", Enum: []string{"json", "jsonp"}},
+				formatParams[1]).
+			Returns(http.StatusOK, "IPv4 and IPv6 addresses, null when not available", mediaJSON, models.DualStack{}).
+			Returns(http.StatusOK, "IPv4 and IPv6 addresses, null when not available", mediaJSONP, "")
+		withFormats(detect.Get("/port", handlers.PortHandler), "Source port", portBody, "Source port not available behind a proxy").
+			Describe("Source TCP port of the connection").
+			Example("?format=json")
+		detect.Get("/pad", handlers.PadHandler).
+			Describe("Client IP padded to an exact response size for path MTU and truncation probing").
+			Example("?size=1500").
+			Query(router.Param{Name: "size", Type: "integer", Required: true, Description: "Body size in bytes (1-65536)"}).
+			Returns(http.StatusOK, "Client IP, dot padding, and a final newline", mediaText, "").
+			Returns(http.StatusBadRequest, "Invalid size parameter", mediaText, "")
+		detect.Get("/info", handlers.InfoHandler).Describe("Detailed IP information").
+			Example("?template=%7B%7B.ClientIP%7D%7D%20via%20%7B%7B.DetectedVia%7D%7D").
+			Query(router.Param{Name: "template", Description: "Go text/template rendering the IP information, or @name for a template from TEMPLATE_DIR"},
+				tzParam).
+			Returns(http.StatusOK, "Detailed IP information", mediaText, "").
+			Returns(http.StatusBadRequest, "Unknown, invalid, or failing template, or unknown time zone", mediaText, "")
+		detect.Get("/json", svc.profile.jsonHandler()).Describe("Comprehensive JSON response").
+			Example("?verbose=1", "?fields=client_ip,ipv4_address,is_cloudflare", "?tz=Europe/Berlin").
+			Query(router.Param{Name: "verbose", Type: "boolean", Description: "Set to 1 to include the reconstructed proxy chain as hops and the detection time in ms"},
+				router.Param{Name: "fields", Description: "Comma-separated IPInfo fields to return, in that order, e.g. client_ip,ipv4_address"},
+				tzParam).
+			Returns(http.StatusOK, "IP information", mediaJSON, models.IPInfo{}).
+			Returns(http.StatusNotModified, "Unchanged since the ETag in If-None-Match", "", nil).
+			Returns(http.StatusBadRequest, "Unknown field in fields or unknown time zone", mediaJSON, models.ErrorResponse{}).
+			Returns(http.StatusInternalServerError, "Failed to encode the response", mediaJSON, models.ErrorResponse{})
+		withFormats(detect.Get("/headers", handlers.HeadersHandler), "Request headers and connection details", models.HeadersResponse{}, "").
+			Describe("HTTP headers and IP details").
+			Example("?format=json", "?format=json&filter=X-Forwarded-,CF-").
+			Query(router.Param{Name: "filter", Description: "Comma-separated, case-insensitive header name prefixes to include, e.g. X-Forwarded-,CF-"})
+	}
+
+	// Health, liveness, SLO, version, and documentation endpoints stay available during maintenance
+	if sets[routesHealth] {
+		r.Get("/health", handlers.HealthHandler).Describe("Health check with uptime, runtime, and request statistics").
+			Returns(http.StatusOK, "Service health status", mediaJSON, models.HealthResponse{})
+		r.Get("/livez", handlers.LivezHandler).Describe("Liveness probe").
+			Returns(http.StatusOK, "Service liveness status", mediaJSON, models.HealthResponse{})
+		r.Get("/readyz", svc.profile.readyHandler()).Describe("Readiness probe with enrichment provider degradation state and proxy range refreshes").
+			Returns(http.StatusOK, "Service is ready", mediaJSON, models.ReadinessStatus{}).
+			Returns(http.StatusServiceUnavailable, "A required enrichment provider is unavailable", mediaProblem, models.Problem{})
+		r.Get("/version", handlers.VersionHandler(newVersionInfo())).
+			Describe("Build information, profile, and compiled-in modules").
+			Returns(http.StatusOK, "Build information", mediaJSON, models.VersionInfo{})
+		r.Get("/about", handlers.AboutHandler).
+			Describe("Instance name, operator contact, privacy policy, and rate limits").
+			Returns(http.StatusOK, "Instance metadata", mediaJSON, models.AboutInfo{})
+		r.Get("/slo", svc.slo.Handler).Describe("Availability and latency SLIs with error budget burn rates").
+			Returns(http.StatusOK, "Current SLO report", mediaJSON, models.SLOReport{})
+	}
+	if sets[routesDocs] {
+		svc.profile.registerDocRoutes(r.Group("", svc.mode.Middleware), cfg)
+		r.Get("/routes", r.Handler).Describe("Registered routes with auth, rate-limit class, and stability").
+			Returns(http.StatusOK, "Registered routes", mediaJSON, []models.RouteInfo{})
+		r.Get("/openapi.json", r.OpenAPIHandler("MyIP API", version)).
+			Describe("OpenAPI 3 document generated from the registered routes").
+			Returns(http.StatusOK, "OpenAPI document", mediaJSON, nil)
+		r.Get("/docs", guide.Handler("MyIP", version, r.Docs)).
+			Describe("Usage examples for every endpoint, generated from the registered routes").
+			Returns(http.StatusOK, "Usage documentation", mediaHTML, "").
+			Returns(http.StatusOK, "Usage documentation", mediaText, "")
+	}
+
+	// Crawl control stays available during maintenance so crawlers are not told to drop the site
+	if sets[routesIP] {
+		r.Get("/robots.txt", crawl.RobotsHandler(svc.robots)).
+			Describe("Crawler rules, keeping crawlers out of the pages echoing request details unless ROBOTS_TXT_FILE replaces them").
+			Returns(http.StatusOK, "robots.txt", mediaText, "")
+		r.Get("/favicon.ico", crawl.FaviconHandler).
+			Describe("Site icon").
+			Returns(http.StatusOK, "Icon", "image/x-icon", "")
+	}
+
+	// Well-known URIs stay available during maintenance so certificate renewals keep working
+	if sets[routesIP] && svc.wellKnown != nil {
+		r.Get("/.well-known/{path...}", svc.wellKnown.ServeHTTP).
+			Describe("Files from WELL_KNOWN_DIR, such as security.txt, and registered ACME HTTP-01 challenges").
+			Returns(http.StatusOK, "File or ACME key authorization", "application/octet-stream", "").
+			Returns(http.StatusNotFound, "No such file or challenge", mediaText, "")
+	}
+
+	// Admin endpoints
+	if sets[routesAdmin] {
+		admin := r.Group("/admin", func(next http.Handler) http.Handler {
+			return middleware.AdminAuth(cfg.AdminToken, next)
+		}).RequireAuth("bearer").
+			Returns(http.StatusUnauthorized, "Missing or invalid bearer token", mediaText, "").
+			Returns(http.StatusNotFound, "Admin endpoints disabled without ADMIN_TOKEN", mediaText, "")
+		admin.Get("/maintenance", svc.mode.Handler).Describe("Maintenance mode status").
+			Returns(http.StatusOK, "Maintenance mode status", mediaJSON, models.MaintenanceStatus{})
+		admin.Post("/maintenance", svc.mode.Handler).Describe("Toggle maintenance mode").
+			Accepts("application/x-www-form-urlencoded", struct {
+				Enabled bool   `json:"enabled"`
+				Message string `json:"message,omitempty"`
+			}{}).
+			Returns(http.StatusOK, "Maintenance mode status", mediaJSON, models.MaintenanceStatus{}).
+			Returns(http.StatusBadRequest, "Invalid enabled parameter or message template", mediaText, "")
+		admin.Get("/boot-report", svc.boot.Handler).Describe("Latest startup report").
+			Returns(http.StatusOK, "Startup report", mediaJSON, models.BootReport{})
+		admin.Get("/config", config.DumpHandler(svc.applied.Load)).Describe("Effective configuration with secrets masked").
+			Returns(http.StatusOK, "Every setting with its value and origin", mediaJSON, models.ConfigDump{})
+		admin.Get("/loglevel", logging.Handler).Describe("Runtime log level and debug modules").
+			Returns(http.StatusOK, "Log configuration", mediaJSON, models.LogLevelStatus{})
+		admin.Put("/loglevel", logging.Handler).Describe("Change the log level and debug modules").
+			Accepts(mediaJSON, struct {
+				Level        string   `json:"level,omitempty"`
+				DebugModules []string `json:"debug_modules,omitempty"`
+			}{}).
+			Returns(http.StatusOK, "Log configuration", mediaJSON, models.LogLevelStatus{}).
+			Returns(http.StatusBadRequest, "Invalid body, level, or module", mediaText, "")
+		if svc.cluster != nil {
+			admin.Post("/cluster/reload", svc.cluster.ReloadHandler).
+				Describe("Reload the configuration of every node in the cluster").
+				Returns(http.StatusAccepted, "Reloaded here; the other nodes reload on their next heartbeat", "", nil).
+				Returns(http.StatusServiceUnavailable, "Cluster state unavailable", mediaProblem, models.Problem{})
+		}
+		if cfg.ACMEChallenges {
+			admin.Put("/acme-challenge/{token}", svc.wellKnown.Challenge).
+				Describe("Serve an ACME HTTP-01 challenge at /.well-known/acme-challenge/{token}").
+				Accepts(mediaText, "").
+				Returns(http.StatusNoContent, "Challenge registered for an hour", "", nil).
+				Returns(http.StatusBadRequest, "Invalid token or key authorization", mediaText, "").
+				Returns(http.StatusServiceUnavailable, "Challenge store unavailable", mediaProblem, models.Problem{})
+			admin.Delete("/acme-challenge/{token}", svc.wellKnown.Challenge).
+				Describe("Stop serving an ACME HTTP-01 challenge").
+				Returns(http.StatusNoContent, "Challenge removed", "", nil).
+				Returns(http.StatusBadRequest, "Invalid token", mediaText, "").
+				Returns(http.StatusServiceUnavailable, "Challenge store unavailable", mediaProblem, models.Problem{})
+		}
+	}
+
+	// Captured requests share the admin token
+	if sets[routesDebug] && svc.captured != nil {
+		r.Group("", func(next http.Handler) http.Handler {
+			return middleware.AdminAuth(cfg.AdminToken, next)
+		}).RequireAuth("bearer").Get("/debug/requests", svc.captured.Handler).
+			Describe("Recent requests to the IP detection endpoints with their detection results, newest first").
+			Query(router.Param{Name: "limit", Type: "integer", Description: "Maximum requests returned (default: all captured)"},
+				router.Param{Name: "ip", Type: "string", Description: "Only requests detected as this client IP"}).
+			Returns(http.StatusOK, "Captured requests", mediaJSON, models.CapturedRequests{}).
+			Returns(http.StatusBadRequest, "Invalid limit or ip parameter", mediaText, "").
+			Returns(http.StatusUnauthorized, "Missing or invalid bearer token", mediaText, "")
+	}
+
+	// The live request stream shares the admin token
+	if sets[routesAdmin] {
+		r.Group("", func(next http.Handler) http.Handler {
+			return middleware.AdminAuth(cfg.AdminToken, next)
+		}).RequireAuth("bearer").Get("/events", svc.events.Handler).
+			Describe("Server-sent events stream summarizing each request as it is answered: client IP, country, endpoint, and status").
+			Returns(http.StatusOK, "Stream of request events holding RequestEvent objects", "text/event-stream", models.RequestEvent{}).
+			Returns(http.StatusUnauthorized, "Missing or invalid bearer token", mediaText, "").
+			Returns(http.StatusServiceUnavailable, "Too many open event streams", mediaProblem, models.Problem{})
+	}
+
+	// The cluster view shares the admin token, as it lists the nodes' addresses
+	if sets[routesAdmin] && svc.cluster != nil {
+		r.Group("", func(next http.Handler) http.Handler {
+			return middleware.AdminAuth(cfg.AdminToken, next)
+		}).RequireAuth("bearer").Get("/cluster", svc.cluster.Handler).
+			Describe("Nodes in the cluster with their health, version, and configuration hash").
+			Returns(http.StatusOK, "Cluster status", mediaJSON, models.ClusterStatus{}).
+			Returns(http.StatusUnauthorized, "Missing or invalid bearer token", mediaText, "").
+			Returns(http.StatusServiceUnavailable, "Cluster state unavailable", mediaProblem, models.Problem{})
+	}
+
+	// Profiling endpoints share the admin token
+	if sets[routesDebug] && cfg.PprofEnabled {
+		debug := r.Group("/debug/pprof", func(next http.Handler) http.Handler {
+			return middleware.AdminAuth(cfg.AdminToken, next)
+		}).RequireAuth("bearer").
+			Returns(http.StatusUnauthorized, "Missing or invalid bearer token", mediaText, "")
+		debug.Get("/", pprof.Index).Describe("Index of the runtime profiles; /debug/pprof/{name} serves heap, goroutine, allocs, block, mutex, or threadcreate").
+			Query(router.Param{Name: "debug", Type: "integer", Description: "Set to 1 or 2 for a text profile instead of the pprof format"}).
+			Returns(http.StatusOK, "Profile index or profile", mediaHTML, "").
+			Returns(http.StatusOK, "Profile index or profile", "application/octet-stream", "")
+		debug.Get("/cmdline", pprof.Cmdline).Describe("Command line of the running process").
+			Returns(http.StatusOK, "Command line arguments separated by NUL bytes", mediaText, "")
+		debug.Get("/profile", untimed(pprof.Profile)).Describe("CPU profile").
+			Query(router.Param{Name: "seconds", Type: "integer", Description: "Profiling duration (default: 30)"}).
+			Returns(http.StatusOK, "CPU profile in the pprof format", "application/octet-stream", "").
+			Returns(http.StatusBadRequest, "Invalid seconds parameter", mediaText, "")
+		debug.Get("/symbol", pprof.Symbol).Describe("Number of available symbols").
+			Returns(http.StatusOK, "Symbol count", mediaText, "")
+		debug.Post("/symbol", pprof.Symbol).Describe("Function names of program counters").
+			Accepts(mediaText, "").
+			Returns(http.StatusOK, "Program counters with their function names", mediaText, "")
+		debug.Get("/trace", untimed(pprof.Trace)).Describe("Execution trace").
+			Query(router.Param{Name: "seconds", Type: "integer", Description: "Tracing duration (default: 1)"}).
+			Returns(http.StatusOK, "Execution trace", "application/octet-stream", "").
+			Returns(http.StatusBadRequest, "Invalid seconds parameter", mediaText, "")
+	}
+
+	if sets[routesMetrics] {
+		r.Get("/metrics", func(w http.ResponseWriter, r *http.Request) {
+			svc.scanner.MetricsHandler(w, r)
+			ip.WriteShadowMetrics(w)
+			ip.WriteDetectionMetrics(w)
+			ip.WriteAnomalyMetrics(w)
+			breaker.WriteMetrics(w)
+			svc.jobs.WriteMetrics(w)
+		}).
+			Describe("Scanner probe, ban, and blocked request counters, detection sources, conflicting header anomalies, shadow detection agreement, circuit breaker states, and background task runs in the Prometheus text format").
+			Returns(http.StatusOK, "Prometheus metrics", mediaText, "")
+
+		// Request statistics share the admin token
+		if svc.stats != nil {
+			r.Group("", func(next http.Handler) http.Handler {
+				return middleware.AdminAuth(cfg.AdminToken, next)
+			}).RequireAuth("bearer").Get("/stats", svc.stats.Handler).
+				Describe("Requests per country, ASN, detection method, and format over a sliding window").
+				Query(router.Param{Name: "limit", Type: "integer", Description: "Entries per dimension (default: 20)"},
+					router.Param{Name: "scope", Description: "node for this replica, or cluster to add the other replicas in cluster mode (default: node)", Enum: []string{stats.ScopeNode, stats.ScopeCluster}}).
+				Returns(http.StatusOK, "Request statistics", mediaJSON, models.StatsReport{}).
+				Returns(http.StatusBadRequest, "Invalid limit or scope parameter", mediaText, "").
+				Returns(http.StatusUnauthorized, "Missing or invalid bearer token", mediaText, "")
+		}
+	}
+
+	// Unknown paths get a 404 listing the endpoints instead of falling through to /; scanner
+	// probes are among them
+	r.Fallback(svc.scanner.NotFound(handlers.NotFoundHandler(r.Routes)), http.HandlerFunc(handlers.MethodNotAllowedHandler))
+
+	return r.Routes()
+}
+
+// untimed lets a profile run as long as its seconds parameter asks. It clears the write deadline,
+// and hides the server from pprof, which refuses durations over the write timeout on older Go
+// releases.
+func untimed(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		handler(w, r.WithContext(context.WithValue(r.Context(), http.ServerContextKey, nil)))
+	}
+}
+
+// createServer builds the HTTP server of the main listener around mux
+func createServer(cfg *config.Config, mux *http.ServeMux) *http.Server {
+	return newServer(cfg, cfg.GetAddr(), mux)
+}
+
+// unnormalizedPaths are left exactly as requested: swagger assets, and the ACME challenge routes whose
+// tokens are case-sensitive
+var unnormalizedPaths = []string{"/swagger/", "/.well-known/", "/admin/acme-challenge/"}
+
+// newServer builds an HTTP server for addr around mux. Paths are normalized before routing so
+// that /IPv6 and /ipv6/ reach /ipv6 rather than the 404 fallback, since "/" matches only itself.
+func newServer(cfg *config.Config, addr string, mux *http.ServeMux) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           middleware.NormalizePath(cfg.PathNormalization, unnormalizedPaths, mux),
 		ReadTimeout:       15 * time.Second,
 		WriteTimeout:      15 * time.Second,
-		IdleTimeout:       60 * time.Second,
+		IdleTimeout:       cfg.IdleTimeout,
 		ReadHeaderTimeout: 5 * time.Second,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	server.SetKeepAlivesEnabled(cfg.KeepAlive)
+	return server
+}
+
+// newBootReport describes the running instance for the startup log and /admin/boot-report
+func newBootReport(cfg *config.Config, endpoints []string) *models.BootReport {
+	protocol := "http"
+	if cfg.TLSEnabled() {
+		protocol = "https"
+	}
+
+	address := cfg.GetAddr()
+	if cfg.UnixSocket != "" {
+		address = "unix:" + cfg.UnixSocket
+	}
+	transports := []models.BootTransport{
+		{Protocol: protocol, Address: address},
+	}
+	transports = append(transports, profileTransports(cfg)...)
+
+	return &models.BootReport{
+		Version:    version,
+		Commit:     commit,
+		BuildDate:  date,
+		GoVersion:  runtime.Version(),
+		StartedAt:  time.Now().UTC().Format(time.RFC3339),
+		Transports: transports,
+		Endpoints:  endpoints,
+		Datasets:   []models.BootDataset{},
+		ConfigHash: cfg.Hash(),
+	}
+}
+
+// newVersionInfo describes the build for /version
+func newVersionInfo() *models.VersionInfo {
+	return &models.VersionInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: date,
+		GoVersion: runtime.Version(),
+		Profile:   features.Profile,
+		Features:  features.Compiled(),
 	}
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "watch":
+			if err := runWatch(os.Args[2:]); err != nil {
+				log.Fatal("Watch failed: ", err)
+			}
+			return
+		case "ddns":
+			if err := runDDNS(os.Args[2:]); err != nil {
+				log.Fatal("DDNS failed: ", err)
+			}
+			return
+		case "selftest":
+			if err := runSelftest(os.Args[2:]); err != nil {
+				log.Fatal("Self-test failed: ", err)
+			}
+			return
+		case "simulate":
+			if err := runSimulate(os.Args[2:]); err != nil {
+				log.Fatal("Simulation failed: ", err)
+			}
+			return
+		}
+	}
+
+	// Every log line passes through the privacy filter, which truncates addresses in privacy mode
+	log.SetOutput(privacy.Writer(log.Writer()))
 
-	cfg := config.Load()
+	configFile := flag.String("config", "", "path to a YAML or KEY=VALUE config file (overrides CONFIG_FILE)")
+	flag.Parse()
 
-	// Update Swagger host dynamically
-	docs.SwaggerInfo.Host = cfg.Host
+	config.SetFile(*configFile)
+	cfg, err := config.Read()
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+
+	svc, err := newServices(cfg)
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+
+	// Importing net/http/pprof registers its handlers on the default mux without authentication,
+	// so the main listener serves its own mux and profiling only as configured
+	mux := http.NewServeMux()
+	endpoints := setupRoutes(mux, cfg, svc)
+	watchReload(context.Background(), cfg, svc)
+	refreshProxyRanges(context.Background(), cfg, svc)
+	if svc.cluster != nil {
+		svc.jobs.Add(scheduler.Task{Name: "cluster-heartbeat", Interval: cfg.ClusterHeartbeat, Immediate: true, Run: svc.cluster.Heartbeat})
+	}
+
+	if err := svc.profile.start(context.Background(), cfg, svc.jobs); err != nil {
+		log.Fatal("Startup failed:", err)
+	}
+	svc.jobs.Start(context.Background())
 
-	setupRoutes()
+	server := createServer(cfg, mux)
+	server.RegisterOnShutdown(svc.events.Close)
 
-	server := createServer(cfg)
+	report := newBootReport(cfg, endpoints)
+	report.Transports = append(report.Transports, listenerTransports(cfg, svc.listeners)...)
+	report.Datasets = append(report.Datasets, svc.profile.datasets()...)
+	svc.boot.Set(report)
+	bootreport.Log(report)
 
-	log.Printf("Server starting on port %s", cfg.Port)
+	listeners, err := listen(context.Background(), cfg, server.Addr)
+	if err != nil {
+		log.Fatal("Server failed to start:", err)
+	}
 
-	if err := server.ListenAndServe(); err != nil {
+	extra, err := startListeners(context.Background(), cfg, svc)
+	if err != nil {
 		log.Fatal("Server failed to start:", err)
 	}
+
+	done := shutdownOnSignal(svc.jobs, append(extra, server)...)
+	if err := serve(server, cfg, listeners); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal("Server failed:", err)
+	}
+	<-done
+}
+
+// listen opens the sockets to serve on: those passed in by systemd socket activation, otherwise
+// the configured Unix domain socket, otherwise the TCP port
+func listen(ctx context.Context, cfg *config.Config, addr string) ([]net.Listener, error) {
+	activated, err := listener.Activated()
+	if err != nil {
+		return nil, err
+	}
+	if len(activated) > 0 {
+		logging.Infof("Serving on %d socket-activated listeners", len(activated))
+		return activated, nil
+	}
+
+	if cfg.UnixSocket != "" {
+		l, err := listener.ListenUnix(ctx, cfg.UnixSocket, cfg.UnixSocketMode)
+		if err != nil {
+			return nil, err
+		}
+		logging.Infof("Serving on unix socket %s", cfg.UnixSocket)
+		return []net.Listener{l}, nil
+	}
+
+	listeners, err := listener.Listen(ctx, "tcp", addr, cfg.ListenSockets)
+	if err != nil {
+		return nil, err
+	}
+	if len(listeners) > 1 {
+		logging.Infof("Accepting on %d SO_REUSEPORT sockets", len(listeners))
+	}
+	return listeners, nil
+}
+
+// shutdownTimeout bounds how long in-flight requests may take to finish during shutdown
+const shutdownTimeout = 10 * time.Second
+
+// shutdownOnSignal shuts the servers down gracefully on SIGINT or SIGTERM, then stops the
+// background jobs, and returns a channel closed once both have. Shutdown closes the listeners,
+// which removes a Unix socket file.
+func shutdownOnSignal(jobs *scheduler.Scheduler, servers ...*http.Server) <-chan struct{} {
+	done := make(chan struct{})
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		defer close(done)
+		sig := <-stop
+		signal.Stop(stop)
+
+		logging.Infof("Received %v, shutting down", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		var wg sync.WaitGroup
+		for _, server := range servers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := server.Shutdown(ctx); err != nil {
+					logging.Errorf("Shutdown of %s did not complete: %v", server.Addr, err)
+				}
+			}()
+		}
+		wg.Wait()
+		if err := jobs.Stop(ctx); err != nil {
+			logging.Errorf("Background jobs did not stop: %v", err)
+		}
+	}()
+	return done
+}
+
+// serve runs an accept loop for each listener, applying the configured TCP options and PROXY
+// protocol handling to accepted connections, and returns when the first of them fails
+func serve(server *http.Server, cfg *config.Config, listeners []net.Listener) error {
+	errs := make(chan error, len(listeners))
+	options := listener.TCPOptions{NoDelay: cfg.TCPNoDelay, Linger: cfg.TCPLinger}
+	for _, l := range listeners {
+		// Tune needs the accepted *net.TCPConn, so it goes beneath the PROXY protocol reader
+		l = listener.ProxyProtocol(listener.Tune(l, options), cfg.ProxyProtocol)
+		go func(l net.Listener) {
+			if cfg.TLSEnabled() {
+				errs <- server.ServeTLS(l, cfg.TLSCertFile, cfg.TLSKeyFile)
+			} else {
+				errs <- server.Serve(l)
+			}
+		}(l)
+	}
+
+	err := <-errs
+	listener.Close(listeners)
+	return err
 }
@@ -0,0 +1,188 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	httpSwagger "github.com/swaggo/http-swagger/v2"
+	"myip/internal/config"
+	"myip/internal/dns"
+	"myip/internal/enrich"
+	"myip/internal/features"
+	"myip/internal/handlers"
+	"myip/internal/ip"
+	"myip/internal/iptype"
+	"myip/internal/logging"
+	"myip/internal/models"
+	"myip/internal/outbound"
+	"myip/internal/rdap"
+	"myip/internal/reputation"
+	"myip/internal/router"
+	"myip/internal/scheduler"
+	"myip/internal/stats"
+	"myip/internal/stun"
+)
+
+func init() {
+	features.Register("swagger", "enrichment", "dns", "whois", "threat-feeds", "ip-type", "stun")
+}
+
+// geoLiteTimeout bounds each download of a GeoLite2 edition
+const geoLiteTimeout = 5 * time.Minute
+
+// profileServices holds the components only compiled into the full profile
+type profileServices struct {
+	enricher   *enrich.Enricher
+	threats    *reputation.Lists
+	classifier *iptype.Classifier
+}
+
+// newProfileServices builds the full-profile components from the configuration
+func newProfileServices(cfg *config.Config) (*profileServices, error) {
+	policies, err := enrich.ParsePolicies(cfg.EnrichPolicies)
+	if err != nil {
+		return nil, err
+	}
+
+	feeds, err := reputation.ParseFeeds(cfg.ThreatFeeds)
+	if err != nil {
+		return nil, err
+	}
+
+	classifier, err := iptype.New(cfg.IPASNDB, cfg.IPTypePrefixes)
+	if err != nil {
+		return nil, err
+	}
+	ip.SetTypeClassifier(classifier)
+
+	p := &profileServices{
+		enricher:   enrich.New(policies, cfg.EnrichTimeout, cfg.EnrichStaleTTL),
+		classifier: classifier,
+	}
+
+	if len(feeds) > 0 {
+		p.threats = reputation.New(outbound.NewHTTPClient(cfg.OutboundIPPreference, cfg.ThreatFeedTimeout), feeds)
+		ip.SetListChecker(p.threats)
+	} else {
+		ip.SetListChecker(nil)
+	}
+	return p, nil
+}
+
+// registerServiceRoutes registers the lookup endpoints subject to maintenance mode and SLO tracking
+func (p *profileServices) registerServiceRoutes(service *router.Router, cfg *config.Config, svc *services) {
+	dnsHandler := dns.NewHandler(net.DefaultResolver, cfg.DNSAllowlist, svc.dnsLimiter, cfg.DNSTimeout)
+	if cfg.RDNSCacheTTL > 0 {
+		dnsHandler.CachePTR(svc.cache, cfg.RDNSCacheTTL)
+	}
+	service.Get("/dns", dnsHandler.ServeHTTP).
+		Describe("Resolve a hostname from the server's vantage point").
+		RateLimit("dns").
+		Example("?name=example.com", "?name=example.com&type=MX").
+		Query(router.Param{Name: "name", Required: true, Description: "Hostname to resolve"},
+			router.Param{Name: "type", Enum: []string{"MX", "TXT"}, Description: "Additional record type to include"}).
+		Returns(http.StatusOK, "Resolved records", mediaJSON, models.DNSResponse{}).
+		Returns(http.StatusBadRequest, "Missing or invalid hostname, or unsupported record type", mediaText, "").
+		Returns(http.StatusForbidden, "Hostname not allowed by DNS_ALLOWLIST", mediaText, "").
+		Returns(http.StatusNotFound, "Hostname not found", mediaText, "").
+		Returns(http.StatusTooManyRequests, "DNS_RATE_LIMIT exceeded", mediaProblem, models.Problem{}).
+		Returns(http.StatusBadGateway, "DNS lookup failed", mediaProblem, models.Problem{}).
+		Returns(http.StatusServiceUnavailable, "DNS lookups suspended by the circuit breaker", mediaProblem, models.Problem{})
+	service.Get("/hostname", dnsHandler.Hostname).
+		Describe("Reverse DNS name of the client IP in punycode and Unicode forms").
+		RateLimit("dns").
+		Returns(http.StatusOK, "PTR name of the client IP", mediaJSON, models.HostnameResponse{}).
+		Returns(http.StatusNotFound, "No PTR record", mediaText, "").
+		Returns(http.StatusTooManyRequests, "DNS_RATE_LIMIT exceeded", mediaProblem, models.Problem{}).
+		Returns(http.StatusBadGateway, "DNS lookup failed", mediaProblem, models.Problem{}).
+		Returns(http.StatusServiceUnavailable, "DNS lookups suspended by the circuit breaker", mediaProblem, models.Problem{})
+
+	rdapClient := rdap.NewClient(outbound.NewHTTPClient(cfg.OutboundIPPreference, cfg.RDAPTimeout),
+		cfg.RDAPURL, cfg.RDAPCacheTTL, cfg.RDAPRateLimit)
+	rdapClient.SetStore(svc.cache)
+	service.Get("/whois", rdap.NewHandler(rdapClient).ServeHTTP).
+		Describe("RDAP registry information for the client IP").
+		RateLimit("rdap").
+		Returns(http.StatusOK, "Registry information", mediaJSON, models.WhoisResponse{}).
+		Returns(http.StatusBadRequest, "Client IP is private or invalid", mediaText, "").
+		Returns(http.StatusNotFound, "No registry data for the address", mediaText, "").
+		Returns(http.StatusBadGateway, "RDAP lookup failed", mediaProblem, models.Problem{}).
+		Returns(http.StatusServiceUnavailable, "RDAP query budget exhausted or lookups suspended by the circuit breaker", mediaProblem, models.Problem{})
+}
+
+// registerDocRoutes registers the Swagger UI, which renders the /openapi.json document
+func (p *profileServices) registerDocRoutes(r *router.Router, cfg *config.Config) {
+	r.Get("/swagger/", httpSwagger.Handler(httpSwagger.URL("/openapi.json"))).
+		Describe("Interactive API documentation").
+		Returns(http.StatusOK, "Swagger UI", mediaHTML, "")
+}
+
+// jsonHandler serves /json with enrichment sections
+func (p *profileServices) jsonHandler() http.HandlerFunc {
+	return handlers.EnrichedJSONHandler(p.enricher)
+}
+
+// reportProxyRanges adds the refresh state of the proxy ranges to the readiness probe
+func (p *profileServices) reportProxyRanges(status func() []models.ProxyRangesStatus) {
+	p.enricher.SetProxyRanges(status)
+}
+
+// readyHandler reports the degradation state of the enrichment providers
+func (p *profileServices) readyHandler() http.HandlerFunc {
+	return p.enricher.ReadyHandler
+}
+
+// start loads the threat feeds, schedules their refresh and the GeoLite2 updates, and starts the
+// STUN responder
+func (p *profileServices) start(ctx context.Context, cfg *config.Config, jobs *scheduler.Scheduler) error {
+	// Threat feeds are loaded before serving so the first responses already report listings
+	if p.threats != nil {
+		if err := p.threats.Refresh(ctx); err != nil {
+			logging.Errorf("Threat feeds incomplete at startup: %v", err)
+		}
+		jobs.Add(scheduler.Task{Name: "threat-feeds", Interval: cfg.ThreatFeedRefresh, Run: p.threats.Refresh})
+	}
+
+	// The first GeoLite2 download runs in the background; IP_ASN_DB's ranges serve until it completes
+	if cfg.MaxMindLicenseKey != "" {
+		updater := iptype.NewUpdater(p.classifier, outbound.NewHTTPClient(cfg.OutboundIPPreference, geoLiteTimeout),
+			cfg.GeoIPDownloadURL, cfg.MaxMindAccountID, cfg.MaxMindLicenseKey)
+		jobs.Add(scheduler.Task{Name: "geoip-update", Interval: cfg.GeoIPUpdateInterval, Immediate: true, Run: updater.Update})
+	}
+
+	if cfg.STUNAddr != "" {
+		conn, err := net.ListenPacket("udp", cfg.STUNAddr)
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := stun.Serve(ctx, conn); err != nil {
+				logging.Errorf("STUN listener stopped: %v", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// networkLookup maps client IPs to their ASN and country for the request statistics, using the
+// ASN database when IP_ASN_DB is set
+func (p *profileServices) networkLookup() stats.Lookup {
+	return p.classifier.Network
+}
+
+// datasets describes the IP type lists and loaded threat feeds for the boot report
+func (p *profileServices) datasets() []models.BootDataset {
+	return append(p.classifier.Datasets(), p.threats.Datasets()...)
+}
+
+// profileTransports lists the listeners besides HTTP enabled by the configuration
+func profileTransports(cfg *config.Config) []models.BootTransport {
+	if cfg.STUNAddr == "" {
+		return nil
+	}
+	return []models.BootTransport{{Protocol: "stun", Address: cfg.STUNAddr}}
+}
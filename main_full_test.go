@@ -0,0 +1,61 @@
+//go:build !minimal
+
+package main
+
+import (
+	"testing"
+
+	"myip/internal/config"
+	"myip/internal/features"
+	"myip/internal/models"
+)
+
+// profileRoutes are the routes only compiled into the full profile
+var profileRoutes = []routeCase{
+	{"/dns", map[string]string{}, "192.168.1.1:12345"},                                    // Missing name returns 400, not 404
+	{"/whois", map[string]string{"CF-Connecting-IP": "192.168.1.1"}, "192.168.1.1:12345"}, // Private IP returns 400 without a registry query
+}
+
+func TestNewBootReportSTUN(t *testing.T) {
+	cfg := &config.Config{Port: "3000", STUNAddr: ":3478"}
+
+	report := newBootReport(cfg, nil)
+	if len(report.Transports) != 2 || report.Transports[1] != (models.BootTransport{Protocol: "stun", Address: ":3478"}) {
+		t.Errorf("Expected stun transport on :3478, got %+v", report.Transports)
+	}
+}
+
+func TestNewServicesInvalidEnrichPolicy(t *testing.T) {
+	cfg := &config.Config{MaintenanceMessage: config.DefaultMaintenanceMessage, LogLevel: "info", EnrichPolicies: []string{"geo=ignore"}}
+
+	if _, err := newServices(cfg); err == nil {
+		t.Error("Expected error for unknown enrichment policy")
+	}
+}
+
+func TestNewServicesInvalidThreatFeed(t *testing.T) {
+	cfg := &config.Config{MaintenanceMessage: config.DefaultMaintenanceMessage, LogLevel: "info", ThreatFeeds: []string{"drop"}}
+
+	if _, err := newServices(cfg); err == nil {
+		t.Error("Expected error for threat feed without a source")
+	}
+}
+
+func TestFullProfileFeatures(t *testing.T) {
+	if features.Profile != features.ProfileFull {
+		t.Errorf("Expected full profile, got %s", features.Profile)
+	}
+	for _, module := range []string{"swagger", "enrichment", "dns", "whois"} {
+		if !features.Enabled(module) {
+			t.Errorf("Expected module %s in the full profile", module)
+		}
+	}
+}
+
+func TestNewServicesMissingIPTypePrefixes(t *testing.T) {
+	cfg := &config.Config{MaintenanceMessage: config.DefaultMaintenanceMessage, LogLevel: "info", IPTypePrefixes: "/nonexistent/prefixes.txt"}
+
+	if _, err := newServices(cfg); err == nil {
+		t.Error("Expected error for missing IP type prefix file")
+	}
+}
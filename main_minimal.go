@@ -0,0 +1,65 @@
+//go:build minimal
+
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"myip/internal/config"
+	"myip/internal/handlers"
+	"myip/internal/models"
+	"myip/internal/router"
+	"myip/internal/scheduler"
+	"myip/internal/stats"
+)
+
+// profileServices is empty in the minimal profile: only the core IP endpoints are compiled in
+type profileServices struct{}
+
+// newProfileServices returns the minimal profile's components
+func newProfileServices(cfg *config.Config) (*profileServices, error) {
+	return &profileServices{}, nil
+}
+
+// registerServiceRoutes registers nothing; /dns, /hostname, and /whois are not compiled in
+func (p *profileServices) registerServiceRoutes(service *router.Router, cfg *config.Config, svc *services) {
+}
+
+// registerDocRoutes registers nothing; the Swagger UI is not compiled in
+func (p *profileServices) registerDocRoutes(r *router.Router, cfg *config.Config) {}
+
+// jsonHandler serves /json without enrichment
+func (p *profileServices) jsonHandler() http.HandlerFunc {
+	return handlers.JSONHandler
+}
+
+// readyHandler reports ready; there are no enrichment providers to degrade
+func (p *profileServices) readyHandler() http.HandlerFunc {
+	return handlers.ReadyHandler
+}
+
+// reportProxyRanges adds the refresh state of the proxy ranges to the readiness probe
+func (p *profileServices) reportProxyRanges(status func() []models.ProxyRangesStatus) {
+	handlers.SetProxyRanges(status)
+}
+
+// start has nothing to start
+func (p *profileServices) start(ctx context.Context, cfg *config.Config, jobs *scheduler.Scheduler) error {
+	return nil
+}
+
+// networkLookup returns nil; without the ASN database statistics report countries and ASNs as unknown
+func (p *profileServices) networkLookup() stats.Lookup {
+	return nil
+}
+
+// datasets reports no datasets
+func (p *profileServices) datasets() []models.BootDataset {
+	return nil
+}
+
+// profileTransports reports no listeners besides HTTP
+func profileTransports(cfg *config.Config) []models.BootTransport {
+	return nil
+}
@@ -0,0 +1,34 @@
+//go:build minimal
+
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"myip/internal/config"
+	"myip/internal/features"
+)
+
+// profileRoutes are the routes only compiled into the full profile; the minimal profile has none
+var profileRoutes []routeCase
+
+func TestMinimalProfileOmitsOptionalRoutes(t *testing.T) {
+	mux := http.NewServeMux()
+	cfg := config.Load()
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, endpoint := range setupRoutes(mux, cfg, svc) {
+		switch endpoint {
+		case "GET /dns", "GET /hostname", "GET /whois", "GET /swagger/":
+			t.Errorf("Expected %s not to be registered in the minimal profile", endpoint)
+		}
+	}
+
+	if features.Profile != features.ProfileMinimal || features.Enabled("swagger") || features.Enabled("enrichment") {
+		t.Errorf("Expected minimal profile without swagger or enrichment, got %s %v", features.Profile, features.Compiled())
+	}
+}
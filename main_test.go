@@ -1,14 +1,31 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
 	"myip/internal/config"
+	"myip/internal/features"
 	"myip/internal/handlers"
+	"myip/internal/ip"
+	"myip/internal/listener"
+	"myip/internal/logging"
+	"myip/internal/models"
+
+	"github.com/akhfa/myip/ipdetect"
 )
 
 // Integration tests for the main application endpoints
@@ -166,27 +183,47 @@ func TestConfigurationIntegration(t *testing.T) {
 	}
 }
 
+// routeCase is a request expected to reach a registered route
+type routeCase struct {
+	route   string
+	headers map[string]string
+	addr    string
+}
+
 // Test the extracted setupRoutes function
 func TestSetupRoutes(t *testing.T) {
-	// Clear any existing routes
-	http.DefaultServeMux = http.NewServeMux()
+	mux := http.NewServeMux()
 
 	// Call setupRoutes
-	setupRoutes()
+	cfg := config.Load()
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoints := setupRoutes(mux, cfg, svc)
 
 	// Test that routes are registered by making requests
-	testCases := []struct {
-		route   string
-		headers map[string]string
-		addr    string
-	}{
+	testCases := []routeCase{
 		{"/", map[string]string{"CF-Connecting-IP": "203.0.113.1"}, "192.168.1.1:12345"},
 		{"/ipv6", map[string]string{"CF-Connecting-IP": "2001:db8::1"}, "[::1]:12345"}, // IPv6 needs IPv6 IP
+		{"/ipv6/expand", map[string]string{"CF-Connecting-IP": "2001:db8::1"}, "[::1]:12345"},
+		{"/both", map[string]string{"X-Forwarded-For": "203.0.113.1, 2001:db8::1"}, "192.168.1.1:12345"},
+		{"/port", map[string]string{}, "203.0.113.1:54321"},
+		{"/pad", map[string]string{}, "203.0.113.1:54321"},
 		{"/info", map[string]string{"CF-Connecting-IP": "203.0.113.1"}, "192.168.1.1:12345"},
 		{"/json", map[string]string{"CF-Connecting-IP": "203.0.113.1"}, "192.168.1.1:12345"},
 		{"/headers", map[string]string{"CF-Connecting-IP": "203.0.113.1"}, "192.168.1.1:12345"},
+		{"/ping", map[string]string{}, "192.168.1.1:12345"},
 		{"/health", map[string]string{}, "192.168.1.1:12345"}, // Health doesn't need IP headers
+		{"/livez", map[string]string{}, "192.168.1.1:12345"},
+		{"/readyz", map[string]string{}, "192.168.1.1:12345"},
+		{"/slo", map[string]string{}, "192.168.1.1:12345"},
+		{"/version", map[string]string{}, "192.168.1.1:12345"},
+		{"/routes", map[string]string{}, "192.168.1.1:12345"},
+		{"/openapi.json", map[string]string{}, "192.168.1.1:12345"},
+		{"/docs", map[string]string{}, "192.168.1.1:12345"},
 	}
+	testCases = append(testCases, profileRoutes...)
 
 	for _, tc := range testCases {
 		req := httptest.NewRequest("GET", tc.route, nil)
@@ -196,20 +233,617 @@ func TestSetupRoutes(t *testing.T) {
 		req.RemoteAddr = tc.addr
 
 		rr := httptest.NewRecorder()
-		http.DefaultServeMux.ServeHTTP(rr, req)
+		mux.ServeHTTP(rr, req)
 
 		// Should not return 404 (route not found)
 		if rr.Code == http.StatusNotFound {
 			t.Errorf("Route %s not registered - got 404", tc.route)
 		}
+
+		if rr.Header().Get("X-Request-ID") == "" {
+			t.Errorf("Route %s missing X-Request-ID header", tc.route)
+		}
+	}
+
+	// Every route is reported for the boot report
+	for _, tc := range testCases {
+		found := false
+		for _, endpoint := range endpoints {
+			if endpoint == "GET "+tc.route {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Route %s missing from returned endpoints %v", tc.route, endpoints)
+		}
+	}
+
+	// Read-only routes reject other methods
+	for _, route := range []string{"/", "/json", "/health"} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("POST", route, nil))
+
+		if rr.Code != http.StatusMethodNotAllowed {
+			t.Errorf("Expected POST %s to return 405, got %d", route, rr.Code)
+		}
+	}
+
+	// Unknown paths no longer fall through to the IPv4 handler at /
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/foo?format=json", nil))
+	var response models.ErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode 404 %q: %v", rr.Body.String(), err)
+	}
+	if rr.Code != http.StatusNotFound || response.Error != models.ErrorNotFound || !slices.Contains(response.Endpoints, "GET /json") {
+		t.Errorf("Expected a 404 listing the endpoints, got %d %+v", rr.Code, response)
+	}
+}
+
+// TestOpenAPIDocument checks that every registered route documents its responses and that the
+// IP endpoints model their formats
+func TestOpenAPIDocument(t *testing.T) {
+	mux := http.NewServeMux()
+	cfg := config.Load()
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupRoutes(mux, cfg, svc)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/openapi.json", nil))
+
+	var document struct {
+		Paths map[string]map[string]struct {
+			Parameters []struct {
+				Name string `json:"name"`
+			} `json:"parameters"`
+			Responses map[string]struct {
+				Content map[string]struct {
+					Schema map[string]any `json:"schema"`
+				} `json:"content"`
+			} `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&document); err != nil {
+		t.Fatalf("Failed to decode OpenAPI document: %v", err)
+	}
+
+	for path, operations := range document.Paths {
+		for method, operation := range operations {
+			if _, ok := operation.Responses["default"]; ok {
+				t.Errorf("%s %s does not document its responses", method, path)
+			}
+		}
+	}
+
+	root := document.Paths["/"]["get"]
+	var params []string
+	for _, param := range root.Parameters {
+		params = append(params, param.Name)
+	}
+	if strings.Join(params, ",") != "format,callback,newline,verbose" {
+		t.Errorf("Expected format, callback, newline, and verbose parameters on /, got %v", params)
+	}
+	for _, mediaType := range []string{"text/plain", "application/json", "application/javascript"} {
+		if _, ok := root.Responses["200"].Content[mediaType]; !ok {
+			t.Errorf("Expected a %s response on /", mediaType)
+		}
+	}
+	if ref := root.Responses["404"].Content["application/json"].Schema["$ref"]; ref != "#/components/schemas/ErrorResponse" {
+		t.Errorf("Expected the JSON 404 to use ErrorResponse, got %v", ref)
+	}
+	if _, ok := root.Responses["503"].Content["application/problem+json"]; !ok {
+		t.Error("Expected the maintenance 503 on /")
+	}
+	if ref := document.Paths["/json"]["get"].Responses["200"].Content["application/json"].Schema["$ref"]; ref != "#/components/schemas/IPInfo" {
+		t.Errorf("Expected /json to use IPInfo, got %v", ref)
+	}
+	if _, ok := document.Components.Schemas["Problem"]; !ok {
+		t.Error("Expected the Problem schema")
+	}
+}
+
+// TestStatsRoute counts IP detection requests and serves them to the admin token holder
+func TestStatsRoute(t *testing.T) {
+	mux := http.NewServeMux()
+	os.Setenv("ADMIN_TOKEN", "secret")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	cfg := config.Load()
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupRoutes(mux, cfg, svc)
+
+	for _, route := range []string{"/", "/json", "/health"} {
+		req := httptest.NewRequest("GET", route, nil)
+		req.RemoteAddr = "203.0.113.1:54321"
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/stats", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected /stats without a token to return 401, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/stats", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	if rr.Code != http.StatusOK || !strings.Contains(body, `"requests":2,`) || !strings.Contains(body, `{"name":"json","requests":1}`) {
+		t.Errorf("Expected the two IP detection requests to be counted, got %d %s", rr.Code, body)
+	}
+}
+
+func TestRequestCaptureRoute(t *testing.T) {
+	mux := http.NewServeMux()
+	os.Setenv("ADMIN_TOKEN", "secret")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	cfg := config.Load()
+	cfg.RequestCaptureSize = 10
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupRoutes(mux, cfg, svc)
+
+	for _, route := range []string{"/", "/json", "/health"} {
+		req := httptest.NewRequest("GET", route, nil)
+		req.RemoteAddr = "203.0.113.1:54321"
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/debug/requests", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected /debug/requests without a token to return 401, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/debug/requests", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	var report models.CapturedRequests
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Expected captured requests, got %d %s", rr.Code, rr.Body.String())
+	}
+	if len(report.Requests) != 2 || report.Requests[0].Path != "/json" || report.Requests[0].ClientIP != "203.0.113.1" {
+		t.Errorf("Expected the two IP detection requests newest first, got %+v", report.Requests)
+	}
+}
+
+// TestRootExactMatch checks that "/" answers only the root path: any other path is a 404 rather
+// than the client's IPv4 address
+func TestRootExactMatch(t *testing.T) {
+	mux := http.NewServeMux()
+	cfg := config.Load()
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupRoutes(mux, cfg, svc)
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.RemoteAddr = "203.0.113.1:12345"
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve("GET", "/"); rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != "203.0.113.1" {
+		t.Fatalf("Expected the IPv4 address at /, got %d %q", rr.Code, rr.Body.String())
+	}
+	if rr := serve("GET", "/?format=json"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "203.0.113.1") {
+		t.Errorf("Expected the IPv4 address at /?format=json, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	for _, target := range []string{"/random", "/random/path", "/index.html", "/JSON", "/json/", "/ipv4", "/favicon.png"} {
+		for _, method := range []string{"GET", "HEAD"} {
+			rr := serve(method, target)
+			if rr.Code != http.StatusNotFound {
+				t.Errorf("%s %s: expected 404, got %d", method, target, rr.Code)
+			}
+			if strings.Contains(rr.Body.String(), "203.0.113.1") {
+				t.Errorf("%s %s: leaked the client address: %q", method, target, rr.Body.String())
+			}
+		}
+	}
+}
+
+// TestCrawlRoutes checks that /robots.txt and /favicon.ico are answered rather than 404
+func TestCrawlRoutes(t *testing.T) {
+	mux := http.NewServeMux()
+	cfg := config.Load()
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupRoutes(mux, cfg, svc)
+
+	for target, contentType := range map[string]string{
+		"/robots.txt":  "text/plain; charset=utf-8",
+		"/favicon.ico": "image/x-icon",
+	} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != contentType {
+			t.Errorf("%s: expected 200 %s, got %d %s", target, contentType, rr.Code, rr.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestEdgeCacheRoutes(t *testing.T) {
+	mux := http.NewServeMux()
+	os.Setenv("EDGE_CACHE", "no-store")
+	defer os.Unsetenv("EDGE_CACHE")
+
+	cfg := config.Load()
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupRoutes(mux, cfg, svc)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/json", nil))
+	if rr.Header().Get("Cache-Control") != "private, no-store" || !strings.Contains(rr.Header().Get("Vary"), "CF-Connecting-IP") {
+		t.Errorf("Expected edge cache headers on /json, got %v", rr.Header())
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+	if rr.Header().Get("CDN-Cache-Control") != "" {
+		t.Errorf("Expected no edge cache headers on /health, got %v", rr.Header())
+	}
+}
+
+// With EDGE_CACHE=vary only the bare addresses may be stored by shared caches
+func TestEdgeCacheVaryRoutes(t *testing.T) {
+	mux := http.NewServeMux()
+	t.Setenv("EDGE_CACHE", "vary")
+
+	cfg := config.Load()
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupRoutes(mux, cfg, svc)
+
+	for _, path := range []string{"/", "/ipv6", "/both"} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if cacheControl := rr.Header().Get("Cache-Control"); !strings.HasPrefix(cacheControl, "public, max-age=") {
+			t.Errorf("Expected %s to be cacheable, got Cache-Control %q", path, cacheControl)
+		}
+	}
+	for _, path := range []string{"/headers", "/json", "/info", "/port"} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if cacheControl := rr.Header().Get("Cache-Control"); strings.Contains(cacheControl, "public") || rr.Header().Get("CDN-Cache-Control") != "no-store" {
+			t.Errorf("Expected %s never to be stored, got %v", path, rr.Header())
+		}
+	}
+}
+
+func TestScannerRoutes(t *testing.T) {
+	mux := http.NewServeMux()
+	os.Setenv("SCANNER_BAN_THRESHOLD", "2")
+	defer os.Unsetenv("SCANNER_BAN_THRESHOLD")
+
+	cfg := config.Load()
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupRoutes(mux, cfg, svc)
+
+	for _, path := range []string{"/wp-login.php", "/.env"} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("Expected 404 for %s, got %d", path, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected the scanner to be banned from /, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.RemoteAddr = "198.51.100.7:1234"
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "myip_scanner_bans_total 1") ||
+		!strings.Contains(rr.Body.String(), "myip_shadow_detections_total") ||
+		!strings.Contains(rr.Body.String(), "myip_detection_source_total") {
+		t.Errorf("Expected the ban in /metrics, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestWellKnownRoutes(t *testing.T) {
+	mux := http.NewServeMux()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "security.txt"), []byte("Contact: mailto:security@example.com\n"), 0o644)
+	os.Setenv("ADMIN_TOKEN", "secret")
+	os.Setenv("ACME_CHALLENGES", "true")
+	os.Setenv("WELL_KNOWN_DIR", dir)
+	defer os.Unsetenv("ADMIN_TOKEN")
+	defer os.Unsetenv("ACME_CHALLENGES")
+	defer os.Unsetenv("WELL_KNOWN_DIR")
+
+	cfg := config.Load()
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupRoutes(mux, cfg, svc)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/.well-known/security.txt", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "security@example.com") {
+		t.Errorf("Expected security.txt, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	put := func(token string) int {
+		req := httptest.NewRequest("PUT", "/admin/acme-challenge/tok3n", strings.NewReader("tok3n.thumbprint"))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := put(""); code != http.StatusUnauthorized {
+		t.Errorf("Expected registering a challenge without a token to return 401, got %d", code)
+	}
+	if code := put("secret"); code != http.StatusNoContent {
+		t.Fatalf("Expected the challenge to be registered, got %d", code)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/.well-known/acme-challenge/tok3n", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "tok3n.thumbprint" {
+		t.Errorf("Expected the key authorization, got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+// ACME tokens are case-sensitive, so the main listener must not fold them while normalizing paths
+func TestACMEChallengeMixedCaseToken(t *testing.T) {
+	mux := http.NewServeMux()
+	t.Setenv("ADMIN_TOKEN", "secret")
+	t.Setenv("ACME_CHALLENGES", "true")
+
+	cfg := config.Load()
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupRoutes(mux, cfg, svc)
+	handler := createServer(cfg, mux).Handler
+
+	const token = "LoqXcYV8q5ONbJQx"
+	req := httptest.NewRequest("PUT", "/admin/acme-challenge/"+token, strings.NewReader(token+".Thumb"))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected the challenge to be registered, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/.well-known/acme-challenge/"+token, nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != token+".Thumb" {
+		t.Errorf("Expected the key authorization for %s, got %d %q", token, rr.Code, rr.Body.String())
+	}
+}
+
+func TestDisabledEndpoints(t *testing.T) {
+	mux := http.NewServeMux()
+	t.Setenv("DISABLED_ENDPOINTS", "/headers,/lookup")
+
+	cfg := config.Load()
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoints := setupRoutes(mux, cfg, svc)
+	if slices.Contains(endpoints, "GET /headers") || slices.Contains(endpoints, "GET /lookup/{ip}") || !slices.Contains(endpoints, "GET /json") {
+		t.Errorf("Expected /headers and /lookup left out, got %v", endpoints)
+	}
+
+	for target, expected := range map[string]int{"/headers": http.StatusNotFound, "/lookup/203.0.113.1": http.StatusNotFound, "/json": http.StatusOK} {
+		req := httptest.NewRequest("GET", target, nil)
+		req.RemoteAddr = "203.0.113.10:1234"
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != expected {
+			t.Errorf("GET %s: expected %d, got %d", target, expected, rr.Code)
+		}
+	}
+}
+
+func TestAPIKeyQuotas(t *testing.T) {
+	mux := http.NewServeMux()
+	t.Setenv("API_KEYS", "k3y=free")
+	t.Setenv("QUOTA_TIERS", "free=1/day")
+
+	cfg := config.Load()
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupRoutes(mux, cfg, svc)
+
+	request := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.RemoteAddr = "203.0.113.10:1234"
+		req.Header.Set("X-API-Key", "k3y")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := request("/json"); rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("Expected the request counted against the quota, got %d %v", rr.Code, rr.Header())
+	}
+	if rr := request("/json"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the second request over the quota, got %d", rr.Code)
+	}
+	if rr := request("/health"); rr.Code != http.StatusOK {
+		t.Errorf("Expected /health outside the quotas, got %d", rr.Code)
+	}
+}
+
+func TestAdminConfigRoute(t *testing.T) {
+	mux := http.NewServeMux()
+	t.Setenv("ADMIN_TOKEN", "secret")
+	t.Setenv("LOG_LEVEL", "warn")
+
+	cfg := config.Load()
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupRoutes(mux, cfg, svc)
+
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/admin/config", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := get(""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the configuration to require the admin token, got %d", rr.Code)
+	}
+
+	rr := get("secret")
+	var dump models.ConfigDump
+	if err := json.Unmarshal(rr.Body.Bytes(), &dump); rr.Code != http.StatusOK || err != nil {
+		t.Fatalf("Expected the configuration, got %d %v", rr.Code, err)
+	}
+	if dump.Hash != cfg.Hash() || strings.Contains(rr.Body.String(), `"secret"`) {
+		t.Errorf("Expected the applied configuration with the token masked, got %s", rr.Body.String())
+	}
+	if !slices.Contains(dump.Settings, models.ConfigSetting{Key: "LOG_LEVEL", Value: "warn", Source: "env"}) {
+		t.Errorf("Expected LOG_LEVEL from the environment, got %+v", dump.Settings)
+	}
+}
+
+func TestEventsRoute(t *testing.T) {
+	mux := http.NewServeMux()
+	t.Setenv("ADMIN_TOKEN", "secret")
+
+	cfg := config.Load()
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupRoutes(mux, cfg, svc)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/events", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the event stream to require the admin token, got %d", rr.Code)
+	}
+
+	// An authorized stream ends when the server shuts down
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	req, _ := http.NewRequest("GET", server.URL+"/events", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d %q", response.StatusCode, response.Header.Get("Content-Type"))
+	}
+	svc.events.Close()
+	if _, err := io.ReadAll(response.Body); err != nil {
+		t.Errorf("Expected the stream to end cleanly, got %v", err)
+	}
+}
+
+// TestServeIPv6Loopback runs the full server on an IPv6-only listener, as on IPv6-only hosts
+func TestServeIPv6Loopback(t *testing.T) {
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback not available: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	cfg := config.Load()
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupRoutes(mux, cfg, svc)
+
+	server := createServer(cfg, mux)
+	go server.Serve(listener)
+	defer server.Close()
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/ipv6")
+	if err != nil {
+		t.Fatalf("Request over IPv6 failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "::1" {
+		t.Errorf("Expected /ipv6 to report ::1, got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestNewBootReport(t *testing.T) {
+	cfg := &config.Config{Port: "3000"}
+
+	report := newBootReport(cfg, []string{"/", "/json"})
+
+	if report.Version != version || report.Commit != commit {
+		t.Errorf("Expected build info in report, got %+v", report)
+	}
+
+	if len(report.Transports) != 1 || report.Transports[0].Address != ":3000" {
+		t.Errorf("Expected http transport on :3000, got %+v", report.Transports)
+	}
+
+	if len(report.Endpoints) != 2 {
+		t.Errorf("Expected 2 endpoints, got %v", report.Endpoints)
+	}
+
+	if report.ConfigHash != cfg.Hash() {
+		t.Errorf("Expected config hash %s, got %s", cfg.Hash(), report.ConfigHash)
+	}
+
+	if _, err := time.Parse(time.RFC3339, report.StartedAt); err != nil {
+		t.Errorf("StartedAt is not in RFC3339 format: %v", err)
 	}
 }
 
 // Test the extracted createServer function
 func TestCreateServer(t *testing.T) {
-	cfg := &config.Config{Port: "3000"}
+	cfg := &config.Config{Port: "3000", IdleTimeout: 60 * time.Second, MaxHeaderBytes: 8 << 10, KeepAlive: true}
 
-	server := createServer(cfg)
+	server := createServer(cfg, http.NewServeMux())
 
 	if server.Addr != ":3000" {
 		t.Errorf("Expected server address :3000, got %s", server.Addr)
@@ -231,7 +865,411 @@ func TestCreateServer(t *testing.T) {
 		t.Errorf("Expected ReadHeaderTimeout 5s, got %v", server.ReadHeaderTimeout)
 	}
 
-	if server.Handler != nil {
-		t.Errorf("Expected Handler to be nil (use default ServeMux), got %v", server.Handler)
+	if server.MaxHeaderBytes != 8<<10 {
+		t.Errorf("Expected MaxHeaderBytes 8192, got %d", server.MaxHeaderBytes)
+	}
+
+	if server.Handler == nil {
+		t.Fatal("Expected Handler to wrap the default ServeMux with path normalization")
+	}
+}
+
+func TestNewServicesInvalidMaintenanceMessage(t *testing.T) {
+	cfg := &config.Config{MaintenanceMessage: "{{.RetryAfter"}
+
+	if _, err := newServices(cfg); err == nil {
+		t.Error("Expected error for invalid maintenance message template")
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	defer ip.Configure(ip.Settings{})
+	defer logging.SetLevel(logging.LevelInfo)
+	defer logging.SetDebugModules(nil)
+
+	path := filepath.Join(t.TempDir(), "myip.env")
+	if err := os.WriteFile(path, []byte("LOG_LEVEL=debug\nLOG_DEBUG_MODULES=dns\nTRUSTED_PROXIES=10.0.0.0/8\nHEADER_PRIORITY=X-Real-IP\nDNS_RATE_LIMIT=1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	svc, err := newServices(config.Load())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CONFIG_FILE", path)
+	defer os.Unsetenv("CONFIG_FILE")
+
+	reloadConfig(svc)
+
+	if logging.GetLevel() != logging.LevelDebug {
+		t.Errorf("Expected log level debug after reload, got %v", logging.GetLevel())
+	}
+
+	if modules := logging.DebugModules(); len(modules) != 1 || modules[0] != "dns" {
+		t.Errorf("Expected dns debug logging after reload, got %v", modules)
+	}
+
+	settings := ip.CurrentSettings()
+	if len(settings.HeaderPriority) != 1 || settings.HeaderPriority[0] != "X-Real-IP" {
+		t.Errorf("Expected reloaded header priority, got %v", settings.HeaderPriority)
+	}
+	if len(settings.TrustedProxies) != 1 || settings.TrustedProxies[0].String() != "10.0.0.0/8" {
+		t.Errorf("Expected reloaded trusted proxies, got %v", settings.TrustedProxies)
+	}
+
+	if ok, _ := svc.dnsLimiter.Allow("203.0.113.1"); !ok {
+		t.Error("Expected first DNS lookup to be allowed")
+	}
+	if ok, _ := svc.dnsLimiter.Allow("203.0.113.1"); ok {
+		t.Error("Expected reloaded DNS rate limit of 1 to apply")
+	}
+
+	// An invalid file keeps the current settings
+	if err := os.WriteFile(path, []byte("LOG_LEVEL=loud\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reloadConfig(svc)
+
+	if logging.GetLevel() != logging.LevelDebug {
+		t.Errorf("Expected log level to remain debug after invalid reload, got %v", logging.GetLevel())
+	}
+}
+
+func TestNewServicesInvalidTrustedProxies(t *testing.T) {
+	cfg := config.Load()
+	cfg.TrustedProxies = []string{"not-a-cidr"}
+
+	if _, err := newServices(cfg); err == nil {
+		t.Error("Expected error for invalid trusted proxies")
+	}
+}
+
+func TestNewServicesProxyProfiles(t *testing.T) {
+	defer ip.Configure(ip.Settings{})
+
+	cfg := config.Load()
+	cfg.ProxyProfiles = []string{"aws-alb"}
+	cfg.TrustedProxies = []string{"192.0.2.1"}
+	if _, err := newServices(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	var trusted []string
+	for _, network := range ip.CurrentSettings().TrustedProxies {
+		trusted = append(trusted, network.String())
+	}
+	if want := []string{"192.0.2.1/32", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}; !slices.Equal(trusted, want) {
+		t.Errorf("Expected TRUSTED_PROXIES and the profile's ranges, got %v", trusted)
+	}
+
+	cfg.ProxyProfiles = []string{"haproxy"}
+	if _, err := newServices(cfg); err == nil {
+		t.Error("Expected error for an unknown proxy profile")
+	}
+}
+
+func TestNewServicesXFFStrategy(t *testing.T) {
+	defer ip.Configure(ip.Settings{})
+
+	cfg := config.Load()
+	cfg.XFFStrategy = "rightmost-untrusted"
+	if _, err := newServices(cfg); err == nil {
+		t.Error("Expected an error for rightmost-untrusted without trusted proxies")
+	}
+
+	cfg.TrustedProxies = []string{"10.0.0.0/8"}
+	if _, err := newServices(cfg); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", "192.0.2.66, 203.0.113.1, 10.0.0.2")
+	req.RemoteAddr = "10.0.0.1:1234"
+	if clientIP, _ := ip.ExtractClientIP(req); clientIP != "203.0.113.1" {
+		t.Errorf("Expected the rightmost untrusted address, got %s", clientIP)
+	}
+}
+
+func TestNewServicesShadowDetection(t *testing.T) {
+	defer ip.Configure(ip.Settings{})
+
+	cfg := config.Load()
+	if _, err := newServices(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if shadow := ip.CurrentSettings().Shadow; shadow != nil {
+		t.Errorf("Expected shadow detection off by default, got %+v", shadow)
+	}
+
+	cfg.ShadowXFFStrategy = "rightmost"
+	if _, err := newServices(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if shadow := ip.CurrentSettings().Shadow; shadow == nil || shadow.Strategy != ipdetect.Rightmost {
+		t.Errorf("Expected a rightmost shadow strategy, got %+v", shadow)
+	}
+
+	cfg.ShadowXFFStrategy = "rightmost-untrusted"
+	if _, err := newServices(cfg); err == nil {
+		t.Error("Expected an error for a rightmost-untrusted shadow without trusted proxies")
+	}
+}
+
+func TestNewServicesRequestTimeouts(t *testing.T) {
+	cfg := config.Load()
+	cfg.RequestTimeouts = []string{"/whois=soon"}
+	if _, err := newServices(cfg); err == nil {
+		t.Error("Expected an error for an invalid request timeout")
+	}
+
+	cfg.RequestTimeouts = []string{"/whois=8s"}
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.budgets.For("/whois") != 8*time.Second || svc.budgets.For("/json") != cfg.RequestTimeout {
+		t.Errorf("Unexpected budgets %+v", svc.budgets)
+	}
+}
+
+func TestPprofRoutes(t *testing.T) {
+	cfg := config.Load()
+	cfg.AdminToken = "secret"
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(mux *http.ServeMux, path, token string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	mux := http.NewServeMux()
+	registerRoutes(mux, cfg, svc, svc.routes)
+	if code := serve(mux, "/debug/pprof/", "secret"); code != http.StatusNotFound {
+		t.Errorf("Expected no profiling without PPROF_ENABLED, got %d", code)
+	}
+
+	cfg.PprofEnabled = true
+	mux = http.NewServeMux()
+	registerRoutes(mux, cfg, svc, svc.routes)
+	if code := serve(mux, "/debug/pprof/goroutine?debug=1", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected profiles to require the admin token, got %d", code)
+	}
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap?debug=1", "/debug/pprof/cmdline"} {
+		if code := serve(mux, path, "secret"); code != http.StatusOK {
+			t.Errorf("Expected %s to be served, got %d", path, code)
+		}
+	}
+}
+
+// Profiles may run past the write timeout of the server
+func TestUntimed(t *testing.T) {
+	server := httptest.NewUnstartedServer(untimed(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(http.ServerContextKey).(*http.Server); ok {
+			t.Error("Expected the server to be hidden from the handler")
+		}
+		time.Sleep(300 * time.Millisecond)
+		io.WriteString(w, "profile")
+	}))
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || string(body) != "profile" {
+		t.Errorf("Expected the response after the write timeout, got %q, %v", body, err)
+	}
+}
+
+func TestParseListeners(t *testing.T) {
+	listeners, err := parseListeners([]string{"127.0.0.1:9090=health+Metrics+admin", ":9091=all"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != 2 || listeners[0].addr != "127.0.0.1:9090" ||
+		!slices.Equal(listeners[0].routes.names(), []string{routesHealth, routesMetrics, routesAdmin}) ||
+		!slices.Equal(listeners[1].routes.names(), allRouteSets) {
+		t.Errorf("Unexpected listeners %+v", listeners)
+	}
+
+	for _, invalid := range []string{":9090", "=health", "9090=health", ":9090=bogus", ":9090="} {
+		if _, err := parseListeners([]string{invalid}); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestRegisterRoutesSets(t *testing.T) {
+	cfg := config.Load()
+	cfg.Listeners = []string{"127.0.0.1:0=health+metrics"}
+	svc, err := newServices(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	endpoints := registerRoutes(mux, cfg, svc, svc.listeners[0].routes)
+	if !slices.Contains(endpoints, "GET /metrics") || !slices.Contains(endpoints, "GET /health") ||
+		slices.Contains(endpoints, "GET /") || slices.Contains(endpoints, "GET /admin/maintenance") {
+		t.Errorf("Unexpected endpoints %v", endpoints)
+	}
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		return rr
+	}
+	if rr := serve("/health"); rr.Code != http.StatusOK {
+		t.Errorf("Expected /health on the internal listener, got %d", rr.Code)
+	}
+	if rr := serve("/"); rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), "/metrics") {
+		t.Errorf("Expected a 404 listing the internal endpoints for /, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestNewServicesRoutes(t *testing.T) {
+	cfg := config.Load()
+	cfg.Routes = "ip+bogus"
+	if _, err := newServices(cfg); err == nil {
+		t.Error("Expected an error for an unknown route set")
+	}
+
+	cfg.Routes = "ip"
+	cfg.Listeners = []string{"localhost=admin"}
+	if _, err := newServices(cfg); err == nil {
+		t.Error("Expected an error for a listener address without a port")
+	}
+}
+
+func TestNewVersionInfo(t *testing.T) {
+	info := newVersionInfo()
+
+	if info.Version != version || info.Profile != features.Profile {
+		t.Errorf("Unexpected version info %+v", info)
+	}
+
+	for _, module := range []string{"ip", "maintenance"} {
+		if !features.Enabled(module) {
+			t.Errorf("Expected core module %s to be compiled in, got %v", module, info.Features)
+		}
+	}
+}
+
+func TestServeMultipleListeners(t *testing.T) {
+	listeners, err := listener.Listen(context.Background(), "tcp", "127.0.0.1:0", 2)
+	if err != nil {
+		t.Skipf("SO_REUSEPORT unavailable: %v", err)
+	}
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	})}
+	done := make(chan error, 1)
+	go func() { done <- serve(server, &config.Config{}, listeners) }()
+
+	for i := 0; i < 10; i++ {
+		resp, err := http.Get("http://" + listeners[0].Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	server.Close()
+	if err := <-done; !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("Expected ErrServerClosed, got %v", err)
+	}
+}
+
+func TestNewBootReportUnixSocket(t *testing.T) {
+	cfg := &config.Config{Port: "3000", UnixSocket: "/run/myip/myip.sock"}
+
+	report := newBootReport(cfg, nil)
+	if report.Transports[0].Address != "unix:/run/myip/myip.sock" {
+		t.Errorf("Expected the unix socket transport, got %+v", report.Transports)
+	}
+}
+
+func TestServeUnixSocketShutdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myip.sock")
+	cfg := &config.Config{UnixSocket: path, UnixSocketMode: 0o600, ListenSockets: 1}
+
+	listeners, err := listen(context.Background(), cfg, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.RemoteAddr)
+	})}
+	shutdown := shutdownOnSignal(nil, server)
+	served := make(chan error, 1)
+	go func() { served <- serve(server, cfg, listeners) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://myip/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "@" {
+		t.Errorf("Expected the unix socket peer address @, got %q", body)
+	}
+
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := process.Signal(syscall.SIGTERM); err != nil {
+		t.Skipf("Cannot signal the test process: %v", err)
+	}
+
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("Expected ErrServerClosed, got %v", err)
+	}
+	<-shutdown
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the socket file to be removed on shutdown, got %v", err)
+	}
+}
+
+func TestCreateServerKeepAliveDisabled(t *testing.T) {
+	cfg := &config.Config{Port: "0", MaxHeaderBytes: 1 << 20, KeepAlive: false}
+	server := createServer(cfg, http.NewServeMux())
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	listeners, err := listener.Listen(context.Background(), "tcp", "127.0.0.1:0", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go serve(server, cfg, listeners)
+	defer server.Close()
+
+	resp, err := http.Get("http://" + listeners[0].Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if !resp.Close {
+		t.Error("Expected the server to close the connection with keep-alives disabled")
 	}
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+
+	"myip/internal/models"
+	"myip/internal/router"
+)
+
+// Media types of the documented responses
+const (
+	mediaText    = "text/plain"
+	mediaJSON    = "application/json"
+	mediaJSONP   = "application/javascript"
+	mediaProblem = "application/problem+json"
+	mediaHTML    = "text/html"
+)
+
+// formatParams documents how the IP endpoints select their response format
+var formatParams = []router.Param{
+	{Name: "format", Description: "Response format, plain text when omitted", Enum: []string{"json", "jsonp"}},
+	{Name: "callback", Description: "JSONP callback function name, only used with format=jsonp (default: callback)"},
+}
+
+// newlineParam documents the per-request override of PLAIN_TEXT_NEWLINE
+var newlineParam = router.Param{
+	Name:        "newline",
+	Type:        "boolean",
+	Description: "End the plain-text response with a newline (default: PLAIN_TEXT_NEWLINE)",
+}
+
+// verboseParam documents the detection details of / and /ipv6
+var verboseParam = router.Param{
+	Name:        "verbose",
+	Type:        "boolean",
+	Description: "Set to 1 for a JSON object with the detection method, all candidate IPs, and the detection time in ms",
+}
+
+// tzParam documents the local timestamp of /info and /json
+var tzParam = router.Param{
+	Name:        "tz",
+	Description: "IANA time zone, e.g. Europe/Berlin, in which to add the timestamp, or auto for the zone in Cloudflare's CF-Timezone header",
+}
+
+// JSON bodies of the IP and port endpoints, which are not models of their own
+var (
+	ipBody struct {
+		IP string `json:"ip"`
+	}
+	portBody struct {
+		Port int `json:"port"`
+	}
+)
+
+// withFormats documents a response in plain text, JSON, or JSONP as selected by formatParams,
+// and the matching 404 when notFound is set
+func withFormats(route *router.Route, description string, body any, notFound string) *router.Route {
+	route.Query(formatParams...).
+		Returns(http.StatusOK, description, mediaText, "").
+		Returns(http.StatusOK, description, mediaJSON, body).
+		Returns(http.StatusOK, description, mediaJSONP, "")
+	if notFound != "" {
+		route.Returns(http.StatusNotFound, notFound, mediaText, "").
+			Returns(http.StatusNotFound, notFound, mediaJSON, models.ErrorResponse{}).
+			Returns(http.StatusNotFound, notFound, mediaJSONP, "")
+	}
+	return route
+}
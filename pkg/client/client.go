@@ -0,0 +1,250 @@
+// Package client calls a myip deployment from Go programs. It is published as its own module so
+// services can look up their public addresses without depending on the server.
+//
+//	c, err := client.New(client.Options{BaseURL: "https://ip.example.com"})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	ipv4, err := c.GetIPv4(ctx)
+//
+// Requests failing with a network error, 429 Too Many Requests, or a 502, 503, or 504 status are
+// retried with exponential backoff, honouring the server's Retry-After hint. A Client is safe for
+// concurrent use.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults applied to zero Options fields
+const (
+	DefaultRetries    = 3
+	DefaultMinBackoff = 200 * time.Millisecond
+	DefaultMaxBackoff = 5 * time.Second
+	DefaultTimeout    = 10 * time.Second
+	DefaultUserAgent  = "myip-go-client"
+)
+
+// maxBodySize bounds how much of a response is read
+const maxBodySize = 1 << 20
+
+// ErrNoAddress is matched by the error GetIPv4 or GetIPv6 return when the request reached the
+// server without an address of that family
+var ErrNoAddress = errors.New("no address of the requested family")
+
+// Options configures a Client
+type Options struct {
+	// BaseURL is the root of the myip deployment, such as "https://ip.example.com"; paths are
+	// resolved against it, so a deployment under a path prefix works too
+	BaseURL string
+
+	// HTTPClient sends the requests. Nil means a client with DefaultTimeout. To look up a specific
+	// address family, give it a transport that dials only tcp4 or tcp6.
+	HTTPClient *http.Client
+
+	// Retries is how many times a failed request is retried: DefaultRetries when zero, none when
+	// negative
+	Retries int
+
+	// MinBackoff and MaxBackoff bound the jittered exponential delay between attempts; a
+	// Retry-After hint is honoured up to MaxBackoff. Zero means DefaultMinBackoff and
+	// DefaultMaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// UserAgent is sent with every request; empty means DefaultUserAgent
+	UserAgent string
+}
+
+// Client calls a myip deployment
+type Client struct {
+	base       *url.URL
+	http       *http.Client
+	retries    int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	userAgent  string
+}
+
+// New creates a Client, returning an error when BaseURL is not an absolute http or https URL
+func New(opts Options) (*Client, error) {
+	base, err := url.Parse(opts.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL %q: %w", opts.BaseURL, err)
+	}
+	if (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: must be an absolute http or https URL", opts.BaseURL)
+	}
+	// Resolve paths below the base rather than replacing its last segment
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	c := &Client{
+		base:       base,
+		http:       opts.HTTPClient,
+		retries:    opts.Retries,
+		minBackoff: opts.MinBackoff,
+		maxBackoff: opts.MaxBackoff,
+		userAgent:  opts.UserAgent,
+	}
+	if c.http == nil {
+		c.http = &http.Client{Timeout: DefaultTimeout}
+	}
+	if c.retries == 0 {
+		c.retries = DefaultRetries
+	} else if c.retries < 0 {
+		c.retries = 0
+	}
+	if c.minBackoff <= 0 {
+		c.minBackoff = DefaultMinBackoff
+	}
+	if c.maxBackoff <= 0 {
+		c.maxBackoff = DefaultMaxBackoff
+	}
+	if c.maxBackoff < c.minBackoff {
+		c.maxBackoff = c.minBackoff
+	}
+	if c.userAgent == "" {
+		c.userAgent = DefaultUserAgent
+	}
+	return c, nil
+}
+
+// address is the JSON body of the address endpoints
+type address struct {
+	IP string `json:"ip"`
+}
+
+// GetIPv4 returns the IPv4 address the server sees the client connecting from. The error matches
+// ErrNoAddress when the request carried no IPv4 address, such as over an IPv6-only path.
+func (c *Client) GetIPv4(ctx context.Context) (string, error) {
+	var body address
+	if err := c.get(ctx, "", &body); err != nil {
+		return "", err
+	}
+	return body.IP, nil
+}
+
+// GetIPv6 returns the IPv6 address the server sees the client connecting from. The error matches
+// ErrNoAddress when the request carried no IPv6 address.
+func (c *Client) GetIPv6(ctx context.Context) (string, error) {
+	var body address
+	if err := c.get(ctx, "ipv6", &body); err != nil {
+		return "", err
+	}
+	return body.IP, nil
+}
+
+// GetInfo returns the detailed information the server reports about the client
+func (c *Client) GetInfo(ctx context.Context) (*Info, error) {
+	info := &Info{}
+	if err := c.get(ctx, "json", info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// get requests the JSON format of path and decodes the response into out, retrying transient
+// failures
+func (c *Client) get(ctx context.Context, path string, out any) error {
+	target := c.base.ResolveReference(&url.URL{Path: path, RawQuery: "format=json"})
+
+	for attempt := 0; ; attempt++ {
+		body, retryAfter, err := c.do(ctx, target.String())
+		if err == nil {
+			if err := json.Unmarshal(body, out); err != nil {
+				return fmt.Errorf("decoding response from %s: %w", target, err)
+			}
+			return nil
+		}
+		if attempt >= c.retries || !retryable(ctx, err) {
+			return err
+		}
+
+		timer := time.NewTimer(c.backoff(attempt, retryAfter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// do sends a single request and returns the body of a 200 response, or an error along with the
+// server's Retry-After hint
+func (c *Client) do(ctx context.Context, target string) ([]byte, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return body, 0, nil
+	}
+	return nil, retryAfter(resp.Header.Get("Retry-After")), newError(resp.StatusCode, body)
+}
+
+// retryable reports whether a failed attempt may succeed when repeated
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	// Transport errors such as refused or reset connections
+	return true
+}
+
+// backoff returns the delay before retrying after the given attempt: the server's Retry-After hint
+// when it sent one, otherwise a jittered exponential delay, capped at the maximum backoff
+func (c *Client) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return min(retryAfter, c.maxBackoff)
+	}
+	delay := c.maxBackoff
+	if attempt < 30 {
+		delay = min(c.minBackoff<<attempt, c.maxBackoff)
+	}
+	// Full jitter over the upper half spreads out clients failing together
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// retryAfter parses a Retry-After header given in seconds, returning 0 when it is absent or an
+// HTTP date
+func retryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,252 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a Client for a test server running handler, with short backoffs
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts Options) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	opts.BaseURL = server.URL + opts.BaseURL
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = time.Millisecond
+	}
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = 5 * time.Millisecond
+	}
+	c, err := New(opts)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return c
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		wantErr bool
+	}{
+		{"https://ip.example.com", false},
+		{"http://localhost:8080/myip/", false},
+		{"", true},
+		{"ip.example.com", true},
+		{"ftp://ip.example.com", true},
+		{"https://", true},
+		{"://bad", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.baseURL, func(t *testing.T) {
+			_, err := New(Options{BaseURL: tt.baseURL})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New(%q) error = %v, wantErr %v", tt.baseURL, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetAddresses(t *testing.T) {
+	var userAgent string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.UserAgent()
+		if r.URL.Query().Get("format") != "json" {
+			t.Errorf("format = %q, want json", r.URL.Query().Get("format"))
+		}
+		switch r.URL.Path {
+		case "/myip/":
+			w.Write([]byte(`{"ip":"203.0.113.7"}`))
+		case "/myip/ipv6":
+			w.Write([]byte(`{"ip":"2001:db8::7"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}, Options{BaseURL: "/myip", UserAgent: "probe/1.0"})
+
+	ipv4, err := c.GetIPv4(context.Background())
+	if err != nil || ipv4 != "203.0.113.7" {
+		t.Errorf("GetIPv4() = %q, %v, want 203.0.113.7", ipv4, err)
+	}
+	ipv6, err := c.GetIPv6(context.Background())
+	if err != nil || ipv6 != "2001:db8::7" {
+		t.Errorf("GetIPv6() = %q, %v, want 2001:db8::7", ipv6, err)
+	}
+	if userAgent != "probe/1.0" {
+		t.Errorf("User-Agent = %q, want probe/1.0", userAgent)
+	}
+}
+
+func TestGetInfo(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"client_ip":"203.0.113.7","detected_via":"X-Forwarded-For","ipv4_address":"203.0.113.7",` +
+			`"ipv6_address":"","is_private_ip":false,"is_cloudflare":true,"user_agent":"probe","timestamp":"2025-01-01T00:00:00Z",` +
+			`"all_candidates":[{"ip":"203.0.113.7","source":"X-Forwarded-For"}],"is_listed":false,` +
+			`"cdn":{"provider":"cloudflare","ray_id":"8f1a"},"enrichment":{"geo":{"country":"NL"}}}`))
+	}, Options{})
+
+	info, err := c.GetInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetInfo() error = %v", err)
+	}
+	if info.ClientIP != "203.0.113.7" || info.DetectedVia != "X-Forwarded-For" || !info.IsCloudflare {
+		t.Errorf("GetInfo() = %+v", info)
+	}
+	if len(info.AllCandidates) != 1 || info.AllCandidates[0].Source != "X-Forwarded-For" {
+		t.Errorf("AllCandidates = %+v", info.AllCandidates)
+	}
+	if info.IsListed == nil || *info.IsListed {
+		t.Errorf("IsListed = %v, want false", info.IsListed)
+	}
+	if info.CDN == nil || info.CDN.RayID != "8f1a" {
+		t.Errorf("CDN = %+v", info.CDN)
+	}
+	if string(info.Enrichment["geo"]) != `{"country":"NL"}` {
+		t.Errorf("Enrichment = %s", info.Enrichment["geo"])
+	}
+}
+
+func TestNoAddress(t *testing.T) {
+	var attempts atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"ipv6_not_found","message":"No IPv6 address found","status":404,"request_id":"abc"}`))
+	}, Options{})
+
+	_, err := c.GetIPv6(context.Background())
+	if !errors.Is(err, ErrNoAddress) {
+		t.Fatalf("GetIPv6() error = %v, want ErrNoAddress", err)
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.RequestID != "abc" {
+		t.Errorf("GetIPv6() error = %#v", err)
+	}
+	if attempts.Load() != 1 {
+		t.Errorf("attempts = %d, want 1 (404 is not retried)", attempts.Load())
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var attempts atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch attempts.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "1")
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"type":"about:blank","title":"Too Many Requests","status":429}`))
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(`{"ip":"203.0.113.7"}`))
+		}
+	}, Options{})
+
+	start := time.Now()
+	ipv4, err := c.GetIPv4(context.Background())
+	if err != nil || ipv4 != "203.0.113.7" {
+		t.Fatalf("GetIPv4() = %q, %v, want 203.0.113.7", ipv4, err)
+	}
+	if attempts.Load() != 3 {
+		t.Errorf("attempts = %d, want 3", attempts.Load())
+	}
+	// Retry-After is capped at MaxBackoff
+	if elapsed := time.Since(start); elapsed > time.Second/2 {
+		t.Errorf("GetIPv4() took %v, want Retry-After capped at MaxBackoff", elapsed)
+	}
+}
+
+func TestRetriesExhausted(t *testing.T) {
+	tests := []struct {
+		retries int
+		want    int32
+	}{
+		{0, DefaultRetries + 1},
+		{1, 2},
+		{-1, 1},
+	}
+
+	for _, tt := range tests {
+		var attempts atomic.Int32
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"type":"about:blank","title":"Service Unavailable","status":503,"detail":"Maintenance"}`))
+		}, Options{Retries: tt.retries})
+
+		_, err := c.GetInfo(context.Background())
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || apiErr.Message != "Maintenance" {
+			t.Errorf("Retries %d: GetInfo() error = %v", tt.retries, err)
+		}
+		if attempts.Load() != tt.want {
+			t.Errorf("Retries %d: attempts = %d, want %d", tt.retries, attempts.Load(), tt.want)
+		}
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		w.WriteHeader(http.StatusBadGateway)
+	}, Options{MinBackoff: time.Hour, MaxBackoff: time.Hour})
+
+	if _, err := c.GetIPv4(ctx); !errors.Is(err, context.Canceled) {
+		var apiErr *Error
+		if !errors.As(err, &apiErr) {
+			t.Errorf("GetIPv4() error = %v, want context.Canceled or the 502 response", err)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c, err := New(Options{BaseURL: "https://ip.example.com", MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt, upper := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		if got := c.backoff(attempt, 0); got < upper/2 || got > upper {
+			t.Errorf("backoff(%d) = %v, want between %v and %v", attempt, got, upper/2, upper)
+		}
+	}
+	if got := c.backoff(100, 0); got > time.Second {
+		t.Errorf("backoff(100) = %v, want at most 1s", got)
+	}
+	if got := c.backoff(0, 3*time.Second); got != time.Second {
+		t.Errorf("backoff with Retry-After 3s = %v, want 1s", got)
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{404, `{"error":"ipv4_not_found","message":"No IPv4 address found"}`, "myip: 404 ipv4_not_found: No IPv4 address found"},
+		{503, `{"title":"Service Unavailable","status":503}`, "myip: 503: Service Unavailable"},
+		{502, `<html>Bad Gateway</html>`, "myip: 502: Bad Gateway"},
+	}
+
+	for _, tt := range tests {
+		if got := newError(tt.status, []byte(tt.body)).Error(); got != tt.want {
+			t.Errorf("newError(%d, %s) = %q, want %q", tt.status, tt.body, got, tt.want)
+		}
+	}
+}
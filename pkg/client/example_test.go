@@ -0,0 +1,39 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/akhfa/myip/pkg/client"
+)
+
+func Example() {
+	// A stand-in for a myip deployment
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ipv6" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"ipv6_not_found","message":"No IPv6 address found","status":404}`))
+			return
+		}
+		w.Write([]byte(`{"ip":"203.0.113.7"}`))
+	}))
+	defer server.Close()
+
+	c, err := client.New(client.Options{BaseURL: server.URL})
+	if err != nil {
+		panic(err)
+	}
+
+	ipv4, err := c.GetIPv4(context.Background())
+	fmt.Println(ipv4, err)
+
+	if _, err := c.GetIPv6(context.Background()); errors.Is(err, client.ErrNoAddress) {
+		fmt.Println("no IPv6")
+	}
+	// Output:
+	// 203.0.113.7 <nil>
+	// no IPv6
+}
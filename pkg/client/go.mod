@@ -0,0 +1,3 @@
+module github.com/akhfa/myip/pkg/client
+
+go 1.24.1
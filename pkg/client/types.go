@@ -0,0 +1,139 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Info is the detailed information served by the /json endpoint
+type Info struct {
+	ClientIP     string `json:"client_ip"`
+	DetectedVia  string `json:"detected_via"`
+	IPv4Address  string `json:"ipv4_address"`
+	IPv6Address  string `json:"ipv6_address"`
+	IsPrivateIP  bool   `json:"is_private_ip"`
+	IsCloudflare bool   `json:"is_cloudflare"`
+	UserAgent    string `json:"user_agent"`
+	Timestamp    string `json:"timestamp"`
+
+	// Port is the client's source TCP port, or 0 when the client IP came from a proxy header
+	Port int `json:"port,omitempty"`
+
+	// AllCandidates lists every distinct public IP the server found in the request
+	AllCandidates []Candidate `json:"all_candidates,omitempty"`
+
+	// TunnelType names the IPv6 transition mechanism (6to4, teredo, or isatap) carrying IPv6Address
+	// and EmbeddedIPv4 the IPv4 address inside it, when tunneled
+	TunnelType   string `json:"tunnel_type,omitempty"`
+	EmbeddedIPv4 string `json:"embedded_ipv4,omitempty"`
+
+	// IPType is the network type of the client IP (residential, mobile, hosting, or vpn), when known
+	IPType string `json:"ip_type,omitempty"`
+
+	// IsListed and ThreatFeeds report threat feed listings; IsListed is nil when the deployment has
+	// reputation lists disabled
+	IsListed    *bool    `json:"is_listed,omitempty"`
+	ThreatFeeds []string `json:"threat_feeds,omitempty"`
+
+	// CDN identifies the CDN request that carried the client's request, when behind a known CDN
+	CDN *CDNTrace `json:"cdn,omitempty"`
+
+	// Warning explains why the detected client IP looks wrong, when the deployment validates it
+	Warning string `json:"warning,omitempty"`
+
+	// SpoofingSuspected reports proxy headers that contradict the connection or each other, with
+	// the explanations in SpoofingReasons
+	SpoofingSuspected bool     `json:"spoofing_suspected"`
+	SpoofingReasons   []string `json:"spoofing_reasons,omitempty"`
+
+	// Confidence rates how far ClientIP can be trusted, from servers that report it
+	Confidence *Confidence `json:"confidence,omitempty"`
+
+	// Enrichment holds provider sections keyed by provider name, left undecoded since providers
+	// vary between deployments
+	Enrichment map[string]json.RawMessage `json:"enrichment,omitempty"`
+}
+
+// Candidate is a public IP found in the request and where it was found: a header name or
+// "RemoteAddr"
+type Candidate struct {
+	IP     string `json:"ip"`
+	Source string `json:"source"`
+}
+
+// CDNTrace is the request ID assigned by the CDN in front of the deployment
+type CDNTrace struct {
+	Provider string `json:"provider"`
+	RayID    string `json:"ray_id"`
+}
+
+// Confidence rates the detected client IP from the agreement between the connection and the
+// proxy headers. Level is "high" from a Score of 0.8, "medium" from 0.5, and "low" below; Factors
+// name the observations behind the score, and Fallback the headers passed over for holding no
+// valid address.
+type Confidence struct {
+	Score    float64  `json:"score"`
+	Level    string   `json:"level"`
+	Factors  []string `json:"factors"`
+	Fallback []string `json:"fallback,omitempty"`
+}
+
+// Error is a non-200 response from the server
+type Error struct {
+	StatusCode int
+
+	// Code is the machine-readable error code, such as "ipv4_not_found", when the server sent one
+	Code string
+
+	// Message describes the error, and RequestID identifies the request in the server's logs
+	Message   string
+	RequestID string
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	message := e.Message
+	if message == "" {
+		message = http.StatusText(e.StatusCode)
+	}
+	if e.Code != "" {
+		return fmt.Sprintf("myip: %d %s: %s", e.StatusCode, e.Code, message)
+	}
+	return fmt.Sprintf("myip: %d: %s", e.StatusCode, message)
+}
+
+// Is reports whether the error is ErrNoAddress
+func (e *Error) Is(target error) bool {
+	return target == ErrNoAddress && (e.Code == "ipv4_not_found" || e.Code == "ipv6_not_found")
+}
+
+// errorBody covers both error bodies the server sends: the JSON error response of the address
+// endpoints and the problem details of 5xx and 429 responses
+type errorBody struct {
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	Title     string `json:"title"`
+	Detail    string `json:"detail"`
+	RequestID string `json:"request_id"`
+}
+
+// newError builds an Error from a response status and body, which need not be JSON
+func newError(status int, body []byte) *Error {
+	e := &Error{StatusCode: status}
+
+	var parsed errorBody
+	if json.Unmarshal(body, &parsed) != nil {
+		return e
+	}
+	e.Code = parsed.Error
+	e.Message = parsed.Message
+	if e.Message == "" {
+		e.Message = parsed.Detail
+	}
+	if e.Message == "" {
+		e.Message = parsed.Title
+	}
+	e.RequestID = parsed.RequestID
+	return e
+}
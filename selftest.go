@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"myip/internal/outbound"
+	"myip/internal/selftest"
+)
+
+// runSelftest runs "myip selftest": checking a deployment's address detection against a
+// reference service, its response formats, and its endpoints, failing when any check fails
+func runSelftest(args []string) error {
+	flags := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	var opts selftest.Options
+	flags.StringVar(&opts.URL, "url", os.Getenv("SELFTEST_URL"), "base URL of the myip deployment to test (SELFTEST_URL)")
+	flags.StringVar(&opts.IPv4Reference, "ipv4-reference", selftest.DefaultIPv4Reference, "plain-text service reporting the expected IPv4 address")
+	flags.StringVar(&opts.IPv6Reference, "ipv6-reference", selftest.DefaultIPv6Reference, "plain-text service reporting the expected IPv6 address")
+	timeout := flags.Duration("timeout", selftest.DefaultTimeout, "timeout of each request")
+	preference := flags.String("ip-preference", "auto", "address family tried first: auto, ipv6, or ipv4")
+	format := flags.String("format", "text", "output format: text or json")
+	if err := flags.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if opts.URL == "" {
+		return errors.New("selftest requires -url")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown output format %q, expected text or json", *format)
+	}
+	opts.Client = outbound.NewHTTPClient(*preference, *timeout)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	report := selftest.Run(ctx, opts)
+
+	var err error
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !report.Passed {
+		return fmt.Errorf("%d of %d checks failed", report.Failed(), len(report.Checks))
+	}
+	return nil
+}
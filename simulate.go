@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"myip/internal/simulate"
+)
+
+// headerFlags collects repeated -header "Name: value" flags
+type headerFlags http.Header
+
+func (h headerFlags) String() string { return "" }
+
+func (h headerFlags) Set(value string) error {
+	name, val, ok := strings.Cut(value, ":")
+	if name = strings.TrimSpace(name); !ok || name == "" {
+		return fmt.Errorf("header %q is not in Name: value form", value)
+	}
+	http.Header(h).Add(textproto.CanonicalMIMEHeaderKey(name), strings.TrimSpace(val))
+	return nil
+}
+
+// runSimulate runs "myip simulate": replaying requests as common proxies forward them against a
+// running instance and reporting the client IP and method detected for each
+func runSimulate(args []string) error {
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	opts := simulate.Options{Headers: http.Header{}}
+	flags.StringVar(&opts.URL, "url", os.Getenv("SIMULATE_URL"), "base URL of the running myip instance (SIMULATE_URL)")
+	flags.StringVar(&opts.ClientIP, "client-ip", simulate.DefaultClientIP, "client address the simulated proxies forward")
+	profiles := flags.String("profile", "", "comma-separated profiles to replay: "+strings.Join(simulate.Profiles(), ", ")+" (default all)")
+	flags.Var(headerFlags(opts.Headers), "header", "header of an extra custom profile as \"Name: value\", where {client} stands for -client-ip; repeatable")
+	timeout := flags.Duration("timeout", simulate.DefaultTimeout, "timeout of each request")
+	format := flags.String("format", "text", "output format: text or json")
+	if err := flags.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if opts.URL == "" {
+		return errors.New("simulate requires -url")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("unknown output format %q, expected text or json", *format)
+	}
+	for _, name := range strings.Split(*profiles, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			opts.Profiles = append(opts.Profiles, name)
+		}
+	}
+	opts.Client = &http.Client{Timeout: *timeout}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	report, err := simulate.Run(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+
+	if failed := report.Errors(); failed > 0 {
+		return fmt.Errorf("%d of %d requests failed", failed, len(report.Results))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"myip/internal/ddns"
+	"myip/internal/watch"
+)
+
+// watchFlags registers the flags shared by "myip watch" and "myip ddns" on flags
+func watchFlags(flags *flag.FlagSet, opts *watch.Options) *bool {
+	flags.StringVar(&opts.Server, "server", os.Getenv("WATCH_SERVER"), "base URL of the myip server to poll (WATCH_SERVER)")
+	flags.StringVar(&opts.NotifyURL, "notify-url", os.Getenv("WATCH_NOTIFY_URL"), "webhook receiving a JSON POST when the address changes (WATCH_NOTIFY_URL)")
+	flags.DurationVar(&opts.Interval, "interval", watch.DefaultInterval, "time between polls")
+	flags.StringVar(&opts.Family, "family", watch.FamilyIPv4, "address family to watch: ipv4 or ipv6")
+	flags.StringVar(&opts.StateFile, "state-file", "", "file remembering the last notified address across restarts")
+	// The secret is only read from the environment, keeping it out of process listings
+	opts.Secret = os.Getenv("WATCH_SECRET")
+	return flags.Bool("once", false, "check once and exit, for running from cron")
+}
+
+// runWatch runs "myip watch": polling a myip server and notifying a webhook when the public
+// address changes, until interrupted
+func runWatch(args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	var opts watch.Options
+	once := watchFlags(flags, &opts)
+	if err := flags.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if opts.Server == "" || opts.NotifyURL == "" {
+		return errors.New("watch requires -server and -notify-url")
+	}
+	return runWatcher(opts, *once)
+}
+
+// runDDNS runs "myip ddns": the watch loop pointing DNS records at the public address through a
+// DNS provider's API, optionally notifying a webhook as well
+func runDDNS(args []string) error {
+	flags := flag.NewFlagSet("ddns", flag.ContinueOnError)
+	var opts watch.Options
+	once := watchFlags(flags, &opts)
+	provider := flags.String("provider", os.Getenv("DDNS_PROVIDER"), "DNS provider: "+strings.Join(ddns.Providers(), ", ")+" (DDNS_PROVIDER)")
+	records := flags.String("record", os.Getenv("DDNS_RECORDS"), "comma-separated record names to update (DDNS_RECORDS)")
+	ttl := flags.Int("ttl", ddns.DefaultTTL, "TTL of the records in seconds")
+	if err := flags.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if opts.Server == "" || *provider == "" || *records == "" {
+		return errors.New("ddns requires -server, -provider, and -record")
+	}
+	p, err := ddns.NewProvider(*provider, os.Getenv)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, name := range strings.Split(*records, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	updater, err := ddns.NewUpdater(p, names, *ttl)
+	if err != nil {
+		return err
+	}
+	opts.Notifiers = []watch.Notifier{updater}
+	return runWatcher(opts, *once)
+}
+
+// runWatcher checks once or watches until interrupted
+func runWatcher(opts watch.Options, once bool) error {
+	w, err := watch.New(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if once {
+		return w.Check(ctx)
+	}
+	return w.Run(ctx)
+}